@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/progress"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+// chunksFile is a file of chunks that search and rag embed in memory for one run (--chunks)
+var chunksFile string
+
+const (
+	// adHocBatch is the number of chunks sent per embedding request for a temporary index
+	adHocBatch = 16
+	// adHocLarge is the chunk count above which --chunks suggests embedding once with embed
+	adHocLarge = 2000
+)
+
+// addChunksFlag registers --chunks on a command that searches embeddings
+func addChunksFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&chunksFile, "chunks", "",
+		"JSON or JSONL file of chunks without embeddings to embed in memory for this run only, instead of --embeddings or --collection")
+}
+
+// loadAdHocCorpus reads the chunks of a --chunks file into a temporary in-memory corpus. When
+// embed is set, chunks without an embedding are embedded first, showing progress when
+// progress is set. Nothing is written to disk, so every run embeds the chunks again.
+func loadAdHocCorpus(path string, embed, progress bool) (*corpus, error) {
+	all, err := vectorstore.ReadItems(path)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(all))
+	items := make([]embeddingItem, 0, len(all))
+	for _, it := range all {
+		if it.Content == "" || (it.ID != "" && seen[it.ID]) {
+			continue
+		}
+		seen[it.ID] = true
+		items = append(items, it)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no chunks with content in %s", path)
+	}
+	if !embed {
+		return &corpus{items: items}, nil
+	}
+
+	// Chunks that already carry vectors keep them, so the rest must use the same model
+	model := recordedModel(searchableItems(items))
+	if queryEmbedModel != "" {
+		if model != "" && !sameModel(queryEmbedModel, model) {
+			return nil, fmt.Errorf("--embed-model %s does not match %s, the model some chunks in %s were embedded with", queryEmbedModel, model, path)
+		}
+		model = queryEmbedModel
+	}
+	if model == "" {
+		models, err := llmClient.ListModels()
+		if err != nil {
+			return nil, err
+		}
+		if model, err = selectEmbeddingModel(models); err != nil {
+			return nil, err
+		}
+	}
+
+	if progress {
+		if n := len(items) - len(searchableItems(items)); n > adHocLarge {
+			fmt.Printf("Note: %d chunks are embedded again on every run; embed them once with 'embed --file %s --all --out <file>' and search that instead\n", n, path)
+		}
+	}
+	if err := embedMissing(items, model, progress); err != nil {
+		return nil, fmt.Errorf("embedding chunks of %s: %w", path, err)
+	}
+
+	items = searchableItems(items)
+	dim, err := vectorstore.Dimension(items)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &corpus{items: items, model: model, dimension: dim}, nil
+}
+
+// embedMissing embeds the items without an embedding with model, adHocBatch at a time, and
+// records the model in their provenance. A progress line is shown when show is set.
+func embedMissing(items []embeddingItem, model string, show bool) error {
+	var pending []int
+	for i, it := range items {
+		if len(it.Embedding) == 0 {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	var bar *progress.Bar
+	if show {
+		bar = progress.New(os.Stdout, "Embedding chunks with "+model, len(pending))
+	}
+	for n := 0; n < len(pending); n += adHocBatch {
+		batch := pending[n:min(n+adHocBatch, len(pending))]
+		texts := make([]string, len(batch))
+		for j, i := range batch {
+			texts[j] = items[i].Content
+		}
+		vectors, err := llmClient.EmbeddingBatchContext(context.Background(), model, texts)
+		if err != nil {
+			if bar != nil {
+				bar.Finish()
+			}
+			return err
+		}
+		for j, i := range batch {
+			items[i].Embedding = vectors[j]
+			items[i].Metadata = stampEmbedding(items[i].Metadata, model)
+		}
+		bar.Add(len(batch))
+	}
+	if bar != nil {
+		fmt.Println(bar.Finish())
+	}
+	return nil
+}
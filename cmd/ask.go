@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/license"
+	"kirk-ai/internal/progress"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/rag"
+	"kirk-ai/internal/robots"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/cobra"
+)
+
+var (
+	askDepth        int
+	askMaxPages     int
+	askDelay        time.Duration
+	askFetchTimeout time.Duration
+	askChunkTokens  int
+	askContextSize  int
+	askMaxAge       time.Duration
+	askRefresh      bool
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask <url-or-collection> [question]",
+	Short: "Crawl, embed, and index a site, then answer a question from it",
+	Long: `Run the whole pipeline in one command. Given a URL, ask crawls the site from that page (same
+host only, up to --depth links away and --max-pages pages, honouring robots.txt), extracts and
+chunks each page, embeds the chunks, and indexes them in a collection in --store named after the
+URL. It then answers the question from the index, citing the excerpts it used.
+
+The index is cached: asking again about the same URL within --max-age reuses it and only embeds
+the question. After --max-age, or with --refresh, the site is crawled again and only chunks whose
+content changed are embedded again. Use --refresh after changing --depth or --max-pages.
+
+Given the name of a collection instead of a URL, ask answers from that collection. Without a
+question, ask only builds or refreshes the index.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runAskCommand,
+}
+
+func runAskCommand(cmd *cobra.Command, args []string) {
+	applyRedact()
+	target := args[0]
+	question := strings.Join(args[1:], " ")
+
+	collection := target
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		name, err := buildAskIndex(target)
+		if err != nil {
+			fmt.Printf("Error building index: %v\n", err)
+			os.Exit(1)
+		}
+		collection = name
+	} else if question == "" {
+		fmt.Println("Please give a question to ask the collection")
+		os.Exit(1)
+	}
+	if question == "" {
+		return
+	}
+
+	corp, err := loadCorpus("", collection)
+	if err != nil {
+		fmt.Printf("Error loading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	queryEmbedding, err := corp.embedQuery(context.Background(), question, "")
+	if err != nil {
+		fmt.Printf("Error generating query embedding: %v\n", err)
+		os.Exit(1)
+	}
+	threshold := ragThreshold(corp, askContextSize)
+	results, err := corp.Search(queryEmbedding, candidateCount(askContextSize, 0), threshold)
+	if err != nil {
+		fmt.Printf("Error searching embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	results, err = applySourceTrust(results, askContextSize)
+	if err != nil {
+		fmt.Printf("Error weighting sources: %v\n", err)
+		os.Exit(1)
+	}
+	context, used := rag.BuildCitedContext(results, rag.DefaultMaxContextLength)
+	if len(used) == 0 {
+		fmt.Printf("No relevant context found for question: %s\n", question)
+		fmt.Printf("Try rebuilding with a larger --depth or --max-pages, or asking a different question.\n")
+		return
+	}
+
+	if stream {
+		fmt.Println("Thinking...")
+	}
+	out, err := openTee()
+	if err != nil {
+		fmt.Printf("Error opening --tee file: %v\n", err)
+		os.Exit(1)
+	}
+	answer, err := generateRAGAnswer(rag.BuildCitedPrompt(question, context), out)
+	out.Write("\n")
+	if err == nil && ragAttribution {
+		out.Write("\nAttribution:\n" + rag.Attribution(used))
+	}
+	if teeErr := out.Close(); teeErr != nil {
+		fmt.Printf("Error writing --tee file: %v\n", teeErr)
+	}
+	if err != nil {
+		fmt.Printf("Error generating answer: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	if !stream {
+		fmt.Printf("Answer: %s\n", answer)
+	}
+	fmt.Println("\nSources:")
+	for i, r := range used {
+		fmt.Printf("  [%d] %s\n", i+1, citationLabel(r.Item))
+		if verbose {
+			fmt.Printf("      %s (similarity: %.3f)\n", truncateText(r.Item.Content, 100), r.Similarity)
+		}
+	}
+	if ragAttribution {
+		fmt.Printf("\nAttribution:\n%s", rag.Attribution(used))
+	}
+}
+
+// askCollectionName names the collection that caches the index of a site crawled from
+// start: its host, for people listing the store, and a hash of the full URL
+func askCollectionName(start string) string {
+	u, _ := url.Parse(start)
+	sum := sha256.Sum256([]byte(start))
+	return "ask-" + urlutil.Sanitize(u.Hostname()) + "-" + hex.EncodeToString(sum[:4])
+}
+
+// buildAskIndex crawls, chunks, embeds, and indexes the site at start into its collection,
+// unless a copy younger than --max-age exists, and returns the collection's name
+func buildAskIndex(start string) (string, error) {
+	if vectorstore.IsQdrant(storeDir) {
+		return "", fmt.Errorf("ask keeps its indexes in a local --store, not %s", storeDir)
+	}
+	normalized := urlutil.Normalize(start)
+	if normalized == "" {
+		return "", fmt.Errorf("%s is not an absolute URL", start)
+	}
+	start = normalized
+	name := askCollectionName(start)
+	store := vectorstore.NewStore(storeDir)
+
+	// Embeddings of the previous build are reused for chunks whose content is unchanged
+	previous := map[string]embeddingItem{}
+	if info, err := store.Info(name); err == nil {
+		age := time.Since(info.UpdatedAt)
+		if !askRefresh && age < askMaxAge {
+			fmt.Printf("Using index %s (%d chunks, built %v ago; --refresh to rebuild)\n", name, info.Count, age.Round(time.Minute))
+			return name, nil
+		}
+		if items, _, err := store.Load(name); err == nil {
+			for _, it := range items {
+				if len(it.Embedding) > 0 {
+					previous[metadataString(it.Metadata, "content_hash")] = it
+				}
+			}
+		}
+	}
+
+	embeddingModel, err := resolveEmbeddingModel()
+	if err != nil {
+		return "", err
+	}
+	pages := crawlSite(start)
+	if len(pages) == 0 {
+		return "", fmt.Errorf("no pages with text could be fetched from %s", start)
+	}
+
+	chunking := provenance.Chunking{Strategy: chunker.Strategy, MaxTokens: askChunkTokens}
+	prov := provenance.Record{
+		CrawlRunID:       provenance.NewRunID(),
+		Crawler:          "ask",
+		FetchedAt:        provenance.Now(),
+		ProcessorVersion: provenance.Version(),
+		Chunker:          &chunking,
+	}
+	crawledAt := time.Now().Format(time.RFC3339)
+	var items []embeddingItem
+	for _, page := range pages {
+		for i, sp := range chunker.ChunkSpans(page.Content, askChunkTokens) {
+			hash := chunker.ContentHash(sp.Text)
+			metadata := chunker.SpanMetadata(page.Content, sp, page.Sections)
+			metadata["crawled_at"] = crawledAt
+			metadata["source_url"] = page.URL
+			metadata["title"] = page.Title
+			metadata["content_hash"] = hash
+			metadata["word_count"] = len(strings.Fields(sp.Text))
+			metadata["char_count"] = len(sp.Text)
+			if !page.License.IsZero() {
+				metadata[license.MetadataKey] = page.License.Map()
+			}
+			item := embeddingItem{
+				ID:         fmt.Sprintf("%s#chunk_%d", page.URL, i),
+				ChunkIndex: i,
+				Content:    sp.Text,
+				Metadata:   metadata,
+			}
+			provenance.Update(item.Metadata, prov)
+			if prev, ok := previous[hash]; ok && sameModel(provenance.Get(prev.Metadata).EmbeddingModel, embeddingModel) {
+				item.ReuseEmbedding(prev, embeddingModel)
+			}
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return "", fmt.Errorf("no usable text found on %d pages of %s", len(pages), start)
+	}
+	reused := len(searchableItems(items))
+	if err := embedMissing(items, embeddingModel, true); err != nil {
+		return "", fmt.Errorf("embedding chunks: %w", err)
+	}
+	if reused > 0 {
+		fmt.Printf("Reused %d unchanged chunks from the previous index\n", reused)
+	}
+
+	// The collection is replaced, so pages that disappeared from the site leave the index
+	if _, err := store.Info(name); err == nil {
+		if err := store.Delete(name); err != nil {
+			return "", err
+		}
+	}
+	if _, err := store.Upsert(name, embeddingModel, items, encryptOutput); err != nil {
+		return "", err
+	}
+
+	corp, _, err := openCorpus("", name)
+	if err != nil {
+		return "", err
+	}
+	ann, err := vectorstore.BuildHNSW(corp.items, vectorstore.DefaultHNSWParams())
+	if err != nil {
+		return "", fmt.Errorf("building approximate index: %w", err)
+	}
+	if err := vectorstore.WriteHNSW(corp.annPath, ann); err != nil {
+		return "", err
+	}
+	if err := vectorstore.WriteKeywordIndex(corp.keywordPath, vectorstore.BuildKeywordIndex(corp.items)); err != nil {
+		return "", err
+	}
+	fmt.Printf("Indexed %d chunks from %d pages in collection %s\n", len(items), len(pages), name)
+	return name, nil
+}
+
+// crawlSite fetches pages breadth-first from start, following links on the same host up to
+// --depth links away, until --max-pages pages are queued. Pages robots.txt disallows are
+// skipped, and failed fetches are reported and skipped.
+func crawlSite(start string) []extract.Page {
+	client := &http.Client{Timeout: askFetchTimeout}
+	checker := robots.New(client, "")
+	checker.Identify(extract.DefaultUserAgent, "")
+	base, _ := url.Parse(start)
+
+	type queued struct {
+		url   string
+		depth int
+	}
+	queue := []queued{{start, 0}}
+	seen := map[string]bool{start: true}
+	bar := progress.New(os.Stdout, "Crawling "+base.Hostname(), 1)
+	var pages []extract.Page
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if !checker.Allowed(context.Background(), extract.DefaultUserAgent, next.url) {
+			fmt.Fprintf(bar, "Skipping %s: disallowed by robots.txt\n", next.url)
+			bar.Fail(1)
+			continue
+		}
+		if len(pages) > 0 && askDelay > 0 {
+			time.Sleep(askDelay)
+		}
+		doc, _, err := extract.Fetch(context.Background(), client, next.url)
+		if err != nil {
+			fmt.Fprintf(bar, "Skipping %s: %v\n", next.url, err)
+			bar.Fail(1)
+			continue
+		}
+		if page := extract.FromDocument(next.url, doc); strings.TrimSpace(page.Content) != "" {
+			pages = append(pages, page)
+		}
+
+		if pageURL, err := url.Parse(next.url); err == nil && next.depth < askDepth {
+			doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+				href, _ := s.Attr("href")
+				ref, err := url.Parse(href)
+				if err != nil || len(seen) >= askMaxPages {
+					return
+				}
+				link := pageURL.ResolveReference(ref)
+				abs := urlutil.Normalize(link.String())
+				if abs == "" || seen[abs] || (link.Scheme != "http" && link.Scheme != "https") || !strings.EqualFold(link.Hostname(), base.Hostname()) {
+					return
+				}
+				seen[abs] = true
+				queue = append(queue, queued{abs, next.depth + 1})
+				bar.AddTotal(1)
+			})
+		}
+		bar.Add(1)
+	}
+	fmt.Println(bar.Finish())
+	return pages
+}
+
+func init() {
+	rootCmd.AddCommand(askCmd)
+	requireServer(askCmd, "")
+
+	addTeeFlag(askCmd)
+	addRedactFlags(askCmd)
+	askCmd.Flags().IntVar(&askDepth, "depth", 1, "How many links away from the URL to follow (0 fetches only the URL)")
+	askCmd.Flags().IntVar(&askMaxPages, "max-pages", 25, "Maximum number of pages to crawl")
+	askCmd.Flags().DurationVar(&askDelay, "delay", 500*time.Millisecond, "Pause between page requests")
+	askCmd.Flags().DurationVar(&askFetchTimeout, "fetch-timeout", 20*time.Second, "Timeout for fetching each page")
+	askCmd.Flags().IntVar(&askChunkTokens, "chunk-tokens", chunker.DefaultMaxTokens, "Approximate tokens per chunk")
+	askCmd.Flags().IntVar(&askContextSize, "context-size", 5, "Number of excerpts to answer from")
+	askCmd.Flags().DurationVar(&askMaxAge, "max-age", 24*time.Hour, "Reuse an index of the URL built within this long instead of crawling again")
+	askCmd.Flags().BoolVar(&askRefresh, "refresh", false, "Crawl the site again even when its index is younger than --max-age")
+	askCmd.Flags().StringVar(&ragModel, "rag-model", "", "Chat model used to answer (auto-select if not specified)")
+	askCmd.Flags().BoolVar(&ragAttribution, "attribution", false, "Print the sources' titles, URLs, copyrights, and licenses after the answer")
+	askCmd.Flags().BoolVar(&ragPreferFast, "prefer-fast", false, "Prefer smaller/faster models (lower latency, possibly lower quality)")
+}
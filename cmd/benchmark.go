@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
 	"strings"
 	"time"
@@ -10,9 +12,14 @@ import (
 )
 
 var (
-	benchmarkAll   bool
-	benchmarkModel string
-	benchmarkQuick bool
+	benchmarkAll     bool
+	benchmarkModel   string
+	benchmarkQuick   bool
+	benchmarkQuant   string
+	benchmarkJudge   string
+	benchmarkRepeat  int
+	benchmarkWarmup  int
+	benchmarkIsolate bool
 )
 
 // benchmarkCmd represents the benchmark command
@@ -20,12 +27,30 @@ var benchmarkCmd = &cobra.Command{
 	Use:   "benchmark",
 	Short: "Benchmark model performance",
 	Long: `Benchmark the performance of available models with standardized tests.
-This helps you understand which models work best for different tasks.`,
+This helps you understand which models work best for different tasks.
+
+With --embed, embedding models are benchmarked instead: throughput, latency percentiles,
+and, given a labeled Q/A set with --qa, how well questions retrieve their answers.`,
 	Run: runBenchmarkCommand,
 }
 
 func runBenchmarkCommand(cmd *cobra.Command, args []string) {
-	models, err := ollamaClient.ListModels()
+	benchmarkProvider = commandProvider(cmd)
+	benchmarkServer = serverURL(cmd, benchmarkProvider)
+	if benchmarkQuant != "" {
+		runQuantBenchmark(benchmarkQuant)
+		return
+	}
+	if benchmarkRetrieval != "" {
+		runRetrievalBenchmark(benchmarkRetrieval)
+		return
+	}
+	if benchmarkEmbed {
+		runEmbedBenchmark()
+		return
+	}
+
+	models, err := llmClient.ListModels()
 	if err != nil {
 		fmt.Printf("Error getting models: %v\n", err)
 		os.Exit(1)
@@ -89,50 +114,128 @@ func runBenchmarkCommand(cmd *cobra.Command, args []string) {
 	for _, modelName := range modelsToTest {
 		fmt.Printf("Testing model: %s\n", modelName)
 		fmt.Println(strings.Repeat("-", 50))
+		if benchmarkIsolate {
+			unloadRunningModels()
+		}
+		results[modelName] = runBenchmarkTests(modelName, tests)
+		if benchmarkIsolate {
+			if err := llmClient.Unload(context.Background(), modelName); err != nil && verbose {
+				fmt.Printf("Could not unload %s: %v\n", modelName, err)
+			}
+		}
+		fmt.Println()
+	}
+
+	// Print summary
+	printBenchmarkSummary(results)
+	saveChatBenchmark(results)
+}
+
+// unloadRunningModels evicts every loaded model so the next model is measured without
+// competing for memory
+func unloadRunningModels() {
+	ctx := context.Background()
+	running, err := llmClient.RunningModels(ctx)
+	if err != nil {
+		if verbose {
+			fmt.Printf("Could not list running models: %v\n", err)
+		}
+		return
+	}
+	for _, r := range running {
+		if err := llmClient.Unload(ctx, r.Name); err != nil && verbose {
+			fmt.Printf("Could not unload %s: %v\n", r.Name, err)
+		}
+	}
+}
+
+// runBenchmarkTests runs each test against modelName --repeat times after --warmup untimed
+// requests, printing progress as it goes
+func runBenchmarkTests(modelName string, tests []BenchmarkTest) []BenchmarkResult {
+	modelResults := make([]BenchmarkResult, 0, len(tests))
+
+	if benchmarkWarmup > 0 && len(tests) > 0 {
+		fmt.Printf("Warming up (%d untimed request(s))... ", benchmarkWarmup)
+		for i := 0; i < benchmarkWarmup; i++ {
+			if _, err := llmClient.Chat(modelName, tests[0].Prompt); err != nil {
+				fmt.Printf("FAILED (%v)\n", err)
+				break
+			}
+		}
+		fmt.Println("done")
+	}
 
-		modelResults := make([]BenchmarkResult, 0, len(tests))
+	repeat := benchmarkRepeat
+	if repeat < 1 {
+		repeat = 1
+	}
 
-		for i, test := range tests {
-			fmt.Printf("[%d/%d] %s... ", i+1, len(tests), test.Name)
+	for i, test := range tests {
+		fmt.Printf("[%d/%d] %s... ", i+1, len(tests), test.Name)
 
+		result := BenchmarkResult{TestName: test.Name, Runs: repeat}
+		var durations, speeds []float64
+		for run := 0; run < repeat; run++ {
 			start := time.Now()
-			response, err := ollamaClient.Chat(modelName, test.Prompt)
+			response, err := llmClient.Chat(modelName, test.Prompt)
 			duration := time.Since(start)
 
 			if err != nil {
-				fmt.Printf("FAILED (%v)\n", err)
-				modelResults = append(modelResults, BenchmarkResult{
-					TestName: test.Name,
-					Success:  false,
-					Duration: duration,
-					Error:    err.Error(),
-				})
-				continue
+				result.Error = err.Error()
+				result.Duration = duration
+				break
 			}
 
-			tokensPerSecond := 0.0
+			durations = append(durations, duration.Seconds())
 			if response.EvalCount > 0 && response.EvalDuration > 0 {
-				tokensPerSecond = float64(response.EvalCount) / (float64(response.EvalDuration) / 1e9)
+				speeds = append(speeds, float64(response.EvalCount)/(float64(response.EvalDuration)/1e9))
 			}
+			result.ResponseLength = len(response.Message.Content)
+			result.TotalTokens = response.EvalCount
+			result.Response = response.Message.Content
+		}
 
-			fmt.Printf("OK (%.2fs, %.1f tokens/s)\n", duration.Seconds(), tokensPerSecond)
+		if result.Error != "" {
+			fmt.Printf("FAILED (%v)\n", result.Error)
+			modelResults = append(modelResults, result)
+			continue
+		}
 
-			modelResults = append(modelResults, BenchmarkResult{
-				TestName:        test.Name,
-				Success:         true,
-				Duration:        duration,
-				TokensPerSecond: tokensPerSecond,
-				ResponseLength:  len(response.Message.Content),
-				TotalTokens:     response.EvalCount,
-			})
+		meanDuration, sdDuration := meanStdDev(durations)
+		result.Success = true
+		result.Duration = time.Duration(meanDuration * float64(time.Second))
+		result.DurationStdDev = time.Duration(sdDuration * float64(time.Second))
+		result.TokensPerSecond, result.TokensPerSecondStdDev = meanStdDev(speeds)
+
+		if repeat > 1 {
+			fmt.Printf("OK (%.2fs ± %.2fs, %.1f ± %.1f tokens/s over %d runs)\n",
+				meanDuration, sdDuration, result.TokensPerSecond, result.TokensPerSecondStdDev, repeat)
+		} else {
+			fmt.Printf("OK (%.2fs, %.1f tokens/s)\n", meanDuration, result.TokensPerSecond)
 		}
 
-		results[modelName] = modelResults
-		fmt.Println()
+		modelResults = append(modelResults, result)
 	}
 
-	// Print summary
-	printBenchmarkSummary(results)
+	return modelResults
+}
+
+// meanStdDev returns the mean and sample standard deviation of xs (0 deviation for fewer than two values)
+func meanStdDev(xs []float64) (mean, stddev float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	for _, x := range xs {
+		stddev += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(xs)-1))
 }
 
 type BenchmarkTest struct {
@@ -141,13 +244,17 @@ type BenchmarkTest struct {
 }
 
 type BenchmarkResult struct {
-	TestName        string
-	Success         bool
-	Duration        time.Duration
-	TokensPerSecond float64
-	ResponseLength  int
-	TotalTokens     int
-	Error           string
+	TestName              string
+	Success               bool
+	Runs                  int
+	Duration              time.Duration // mean over Runs
+	DurationStdDev        time.Duration
+	TokensPerSecond       float64 // mean over Runs
+	TokensPerSecondStdDev float64
+	ResponseLength        int
+	TotalTokens           int
+	Response              string
+	Error                 string
 }
 
 func getBenchmarkTests(quick bool) []BenchmarkTest {
@@ -221,6 +328,15 @@ func printBenchmarkSummary(results map[string][]BenchmarkResult) {
 			}
 		}
 
+		// Show run-to-run spread when tests were repeated
+		for _, result := range modelResults {
+			if result.Success && result.Runs > 1 {
+				fmt.Printf("  %s: %.2fs ± %.2fs, %.1f ± %.1f tokens/s (n=%d)\n", result.TestName,
+					result.Duration.Seconds(), result.DurationStdDev.Seconds(),
+					result.TokensPerSecond, result.TokensPerSecondStdDev, result.Runs)
+			}
+		}
+
 		// Show failed tests
 		for _, result := range modelResults {
 			if !result.Success {
@@ -296,8 +412,24 @@ func printBenchmarkSummary(results map[string][]BenchmarkResult) {
 
 func init() {
 	rootCmd.AddCommand(benchmarkCmd)
+	requireServer(benchmarkCmd, "")
 
 	benchmarkCmd.Flags().BoolVarP(&benchmarkAll, "all", "a", false, "Test all available models")
 	benchmarkCmd.Flags().StringVarP(&benchmarkModel, "model", "m", "", "Test specific model")
 	benchmarkCmd.Flags().BoolVarP(&benchmarkQuick, "quick", "q", false, "Run quick benchmark (fewer tests)")
+	benchmarkCmd.Flags().StringVar(&benchmarkQuant, "quant", "", "Compare the installed quantizations of a base model (e.g. llama3.1:8b) and recommend one")
+	benchmarkCmd.Flags().StringVar(&benchmarkJudge, "judge-model", "", "Model that scores answer quality for --quant (default: the highest-precision variant)")
+	benchmarkCmd.Flags().StringVar(&benchmarkRetrieval, "retrieval", "", "Sweep similarity thresholds and top-k over a labeled query set (JSON or JSONL of {\"query\", \"relevant\"}) and report precision and recall")
+	benchmarkCmd.Flags().StringVar(&benchmarkEmbeddings, "embeddings", "", "Embeddings file searched by --retrieval")
+	benchmarkCmd.Flags().StringVar(&benchmarkCollection, "collection", "", "Collection searched by --retrieval (registered with 'collections create', or in --store)")
+	benchmarkCmd.Flags().Float64SliceVar(&benchmarkThresholds, "thresholds", []float64{0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}, "Similarity thresholds swept by --retrieval")
+	benchmarkCmd.Flags().IntSliceVar(&benchmarkTopKs, "top-ks", []int{1, 3, 5, 10}, "Top-k values swept by --retrieval")
+	benchmarkCmd.Flags().StringVar(&benchmarkRetrievalTo, "sweep-out", "", "Export the --retrieval sweep as CSV, or JSON when the file ends in .json")
+	benchmarkCmd.Flags().BoolVar(&benchmarkEmbed, "embed", false, "Benchmark embedding models instead of chat: embeddings/sec, latency percentiles, and with --qa retrieval quality")
+	benchmarkCmd.Flags().StringVar(&benchmarkQA, "qa", "", "Labeled Q/A set for --embed (JSON or JSONL of {\"question\", \"answer\"}); each question should retrieve its own answer")
+	benchmarkCmd.Flags().IntVar(&benchmarkEmbedRequests, "embed-requests", 20, "Single-text requests timed for --embed latency percentiles")
+	benchmarkCmd.Flags().IntVar(&benchmarkBatchSize, "batch-size", 16, "Texts per request when --embed measures throughput")
+	benchmarkCmd.Flags().IntVar(&benchmarkRepeat, "repeat", 1, "Run each test this many times and report mean and standard deviation")
+	benchmarkCmd.Flags().IntVar(&benchmarkWarmup, "warmup", 0, "Untimed requests sent to each model before its tests (absorbs model load time)")
+	benchmarkCmd.Flags().BoolVar(&benchmarkIsolate, "isolate", false, "Unload all models before each model's run and unload it afterwards (keep_alive 0)")
 }
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/sweep"
+)
+
+var (
+	benchmarkEmbed         bool
+	benchmarkQA            string
+	benchmarkEmbedRequests int
+	benchmarkBatchSize     int
+)
+
+// embedBenchmarkTexts are the passages embedded for timing: varied in topic and length, as
+// a crawled corpus's chunks are
+var embedBenchmarkTexts = []string{
+	"The conference opens on Friday morning with a keynote, followed by breakout sessions on campus organizing, fundraising, and media training.",
+	"Refunds are issued to the original payment method within ten business days of the request. Tickets bought at a discount cannot be refunded, but they can be transferred to another attendee until the week before the event.",
+	"func Factorial(n int) int {\n\tif n <= 1 {\n\t\treturn 1\n\t}\n\treturn n * Factorial(n-1)\n}",
+	"Photosynthesis converts light energy into chemical energy stored in glucose.",
+	"Chapters must register with the student activities office each semester, name a faculty advisor, and hold at least two public events a year to stay in good standing.",
+	"The shuttle leaves the north parking garage every fifteen minutes between 7 a.m. and 11 p.m.; the last run on Sunday is at 6 p.m.",
+	"Volunteers check in at the registration desk, pick up a badge and a radio, and report to their shift lead. Shifts last four hours, and every volunteer gets a meal voucher for each shift worked.",
+	"In 1787 the delegates in Philadelphia settled on a bicameral legislature, balancing representation by population in the House against equal representation of the states in the Senate.",
+	"Error 503 means the server is overloaded or down for maintenance; retry after the delay given in the Retry-After header.",
+	"Membership includes a quarterly newsletter, early access to event registration, and discounts at partner bookstores.",
+	"To reset your password, open the sign-in page, choose \"Forgot password\", and follow the link sent to your email address. The link expires after one hour.",
+	"A balanced diet includes vegetables, fruit, whole grains, lean protein, and enough water throughout the day.",
+}
+
+// embedBenchmarkResult is one embedding model's benchmark
+type embedBenchmarkResult struct {
+	Model         string
+	Dimension     int
+	PerSecond     float64 // texts embedded per second in batches
+	P50, P90, P99 time.Duration
+	QA            *sweep.QAScore
+	Error         string
+}
+
+// runEmbedBenchmark measures each embedding model's batched throughput, single-request
+// latency, and, with --qa, how well its questions find their answers
+func runEmbedBenchmark() {
+	if benchmarkBatchSize < 1 || benchmarkEmbedRequests < 1 {
+		fmt.Println("--batch-size and --embed-requests must be positive")
+		os.Exit(1)
+	}
+	var pairs []sweep.QA
+	if benchmarkQA != "" {
+		var err error
+		if pairs, err = sweep.LoadQA(benchmarkQA); err != nil {
+			fmt.Printf("Error loading Q/A set: %v\n", err)
+			os.Exit(1)
+		}
+		if len(pairs) < 2 {
+			fmt.Printf("%s needs at least two question and answer pairs\n", benchmarkQA)
+			os.Exit(1)
+		}
+	}
+
+	models, err := llmClient.ListModels()
+	if err != nil {
+		fmt.Printf("Error getting models: %v\n", err)
+		os.Exit(1)
+	}
+	modelsToTest := embeddingModelsToBenchmark(models)
+	if len(modelsToTest) == 0 {
+		fmt.Println("No embedding models found for benchmarking")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Benchmarking %d embedding model(s)", len(modelsToTest))
+	if len(pairs) > 0 {
+		fmt.Printf(" with %d Q/A pairs from %s", len(pairs), benchmarkQA)
+	}
+	fmt.Print("...\n\n")
+	var results []embedBenchmarkResult
+	for _, name := range modelsToTest {
+		fmt.Printf("Testing model: %s\n", name)
+		fmt.Println(strings.Repeat("-", 50))
+		if benchmarkIsolate {
+			unloadRunningModels()
+		}
+		results = append(results, benchmarkEmbeddingModel(name, pairs))
+		if benchmarkIsolate {
+			if err := llmClient.Unload(context.Background(), name); err != nil && verbose {
+				fmt.Printf("Could not unload %s: %v\n", name, err)
+			}
+		}
+		fmt.Println()
+	}
+	printEmbedBenchmarkSummary(results)
+	saveEmbedBenchmark(results)
+}
+
+// embeddingModelsToBenchmark returns the models matching --model, every embedding model with
+// --all, or else the one auto-selection picks
+func embeddingModelsToBenchmark(models []string) []string {
+	var selected []string
+	switch {
+	case benchmarkModel != "":
+		for _, m := range models {
+			if strings.Contains(strings.ToLower(m), strings.ToLower(benchmarkModel)) {
+				selected = append(selected, m)
+			}
+		}
+	case benchmarkAll:
+		if facts, err := llmClient.ModelFacts(context.Background(), models); err == nil {
+			for _, f := range facts {
+				if hasEmbeddingModel([]config.ModelFacts{f}) {
+					selected = append(selected, f.Name)
+				}
+			}
+		} else {
+			for _, m := range models {
+				if strings.Contains(strings.ToLower(m), "embed") {
+					selected = append(selected, m)
+				}
+			}
+		}
+	default:
+		if m := llmClient.SelectEmbeddingModel(models); m != "" {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
+
+// benchmarkEmbeddingModel runs the embedding tests against one model, printing progress
+func benchmarkEmbeddingModel(name string, pairs []sweep.QA) embedBenchmarkResult {
+	result := embedBenchmarkResult{Model: name}
+	fail := func(err error) embedBenchmarkResult {
+		fmt.Printf("FAILED (%v)\n", err)
+		result.Error = err.Error()
+		return result
+	}
+
+	// Untimed requests absorb the model's load, which would otherwise count as latency
+	warmups := benchmarkWarmup
+	if warmups < 1 {
+		warmups = 1
+	}
+	fmt.Printf("Warming up (%d untimed request(s))... ", warmups)
+	for i := 0; i < warmups; i++ {
+		resp, err := llmClient.Embedding(name, embedBenchmarkTexts[0])
+		if err != nil {
+			return fail(err)
+		}
+		result.Dimension = len(resp.Embedding)
+	}
+	fmt.Println("done")
+
+	fmt.Printf("[1/2] Latency over %d single-text requests... ", benchmarkEmbedRequests)
+	latencies := make([]time.Duration, 0, benchmarkEmbedRequests)
+	for i := 0; i < benchmarkEmbedRequests; i++ {
+		start := time.Now()
+		if _, err := llmClient.Embedding(name, embedBenchmarkTexts[i%len(embedBenchmarkTexts)]); err != nil {
+			return fail(err)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50, result.P90, result.P99 = durationPercentile(latencies, 50), durationPercentile(latencies, 90), durationPercentile(latencies, 99)
+	fmt.Printf("OK (p50 %v, p90 %v, p99 %v)\n", roundMs(result.P50), roundMs(result.P90), roundMs(result.P99))
+
+	texts := make([]string, 4*benchmarkBatchSize)
+	for i := range texts {
+		texts[i] = embedBenchmarkTexts[i%len(embedBenchmarkTexts)]
+	}
+	fmt.Printf("[2/2] Throughput over %d texts in batches of %d... ", len(texts), benchmarkBatchSize)
+	start := time.Now()
+	if _, err := embedInBatches(name, texts); err != nil {
+		return fail(err)
+	}
+	result.PerSecond = float64(len(texts)) / time.Since(start).Seconds()
+	fmt.Printf("OK (%.1f embeddings/s)\n", result.PerSecond)
+
+	if len(pairs) > 0 {
+		fmt.Printf("Q/A retrieval over %d pairs... ", len(pairs))
+		questions := make([]string, len(pairs))
+		answers := make([]string, len(pairs))
+		for i, p := range pairs {
+			questions[i], answers[i] = p.Question, p.Answer
+		}
+		qv, err := embedInBatches(name, questions)
+		if err != nil {
+			return fail(err)
+		}
+		av, err := embedInBatches(name, answers)
+		if err != nil {
+			return fail(err)
+		}
+		score := sweep.EvaluateQA(qv, av, 5)
+		result.QA = &score
+		fmt.Printf("OK (top-1 %.2f, hit@%d %.2f, MRR %.2f, margin %.3f)\n", score.Top1, score.K, score.HitAtK, score.MRR, score.Margin)
+	}
+	return result
+}
+
+// embedInBatches embeds texts in requests of --batch-size texts each
+func embedInBatches(name string, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, 0, len(texts))
+	for i := 0; i < len(texts); i += benchmarkBatchSize {
+		end := min(i+benchmarkBatchSize, len(texts))
+		batch, err := llmClient.EmbeddingBatch(name, texts[i:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// durationPercentile returns the nearest-rank p-th percentile of sorted durations
+func durationPercentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p + 99) / 100
+	if i < 1 {
+		i = 1
+	}
+	return sorted[i-1]
+}
+
+// printEmbedBenchmarkSummary prints the models side by side, the fastest first
+func printEmbedBenchmarkSummary(results []embedBenchmarkResult) {
+	sort.SliceStable(results, func(i, j int) bool { return results[i].PerSecond > results[j].PerSecond })
+	fmt.Println("Summary")
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf("%-28s %5s %8s %8s %8s %8s", "model", "dim", "emb/s", "p50", "p90", "p99")
+	withQA := false
+	for _, r := range results {
+		withQA = withQA || r.QA != nil
+	}
+	if withQA {
+		fmt.Printf(" %6s %6s %6s", "top-1", "hit@5", "MRR")
+	}
+	fmt.Println()
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Printf("%-28s failed: %s\n", r.Model, r.Error)
+			continue
+		}
+		fmt.Printf("%-28s %5d %8.1f %8v %8v %8v", r.Model, r.Dimension, r.PerSecond, roundMs(r.P50), roundMs(r.P90), roundMs(r.P99))
+		if r.QA != nil {
+			fmt.Printf(" %6.2f %6.2f %6.2f", r.QA.Top1, r.QA.HitAtK, r.QA.MRR)
+		}
+		fmt.Println()
+	}
+	if withQA && len(results) > 1 {
+		best := results[0]
+		for _, r := range results[1:] {
+			if r.QA != nil && (best.QA == nil || r.QA.MRR > best.QA.MRR) {
+				best = r
+			}
+		}
+		if best.QA != nil {
+			fmt.Printf("\nBest retrieval: %s (MRR %.2f)\n", best.Model, best.QA.MRR)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kirk-ai/internal/models"
+)
+
+// quantTagRE finds a quantization level in a model tag, e.g. "q4_K_M" in "llama3.1:8b-instruct-q4_K_M"
+var quantTagRE = regexp.MustCompile(`(?i)(?:^|[-_:])(q[2-8](?:_[0-9a-z]+)*|fp16|f16|bf16|fp32|f32)(?:$|[-_])`)
+
+var judgeScoreRE = regexp.MustCompile(`\b(10|[1-9])\b`)
+
+// quantVariant is one installed quantization of a base model and its benchmark outcome
+type quantVariant struct {
+	Name     string
+	Level    string // e.g. Q4_K_M, F16
+	Bits     int    // bits per weight, used to order variants by precision
+	DiskSize int64
+	Memory   int64 // bytes in memory while loaded (0 = unknown)
+	VRAM     int64 // bytes of Memory on the GPU
+	Results  []BenchmarkResult
+	Quality  float64 // mean judge score, 1-10 (0 = not scored)
+}
+
+// quantLevel returns a model's quantization level from its details or, failing that, its tag
+func quantLevel(m models.Model) string {
+	if m.Details.QuantizationLevel != "" {
+		return strings.ToUpper(m.Details.QuantizationLevel)
+	}
+	if match := quantTagRE.FindStringSubmatch(m.Name); match != nil {
+		return strings.ToUpper(match[1])
+	}
+	return ""
+}
+
+// quantBits returns the bits per weight implied by a quantization level (0 when unknown)
+func quantBits(level string) int {
+	level = strings.ToUpper(level)
+	switch {
+	case strings.HasPrefix(level, "Q") && len(level) > 1:
+		n, _ := strconv.Atoi(level[1:2])
+		return n
+	case strings.Contains(level, "16"):
+		return 16
+	case strings.Contains(level, "32"):
+		return 32
+	}
+	return 0
+}
+
+// findQuantVariants returns the installed models that are quantizations of base, lowest precision first
+func findQuantVariants(installed []models.Model, base string) []*quantVariant {
+	base = strings.ToLower(base)
+	var variants []*quantVariant
+	for _, m := range installed {
+		name := strings.ToLower(m.Name)
+		if name != base && !strings.HasPrefix(name, base+"-") && !strings.HasPrefix(name, base+":") {
+			continue
+		}
+		level := quantLevel(m)
+		if level == "" {
+			continue
+		}
+		variants = append(variants, &quantVariant{Name: m.Name, Level: level, Bits: quantBits(level), DiskSize: m.Size})
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		if variants[i].Bits != variants[j].Bits {
+			return variants[i].Bits < variants[j].Bits
+		}
+		return variants[i].Name < variants[j].Name
+	})
+	return variants
+}
+
+func runQuantBenchmark(base string) {
+	ctx := context.Background()
+	installed, err := llmClient.ListModelDetails(ctx)
+	if err != nil {
+		fmt.Printf("Error getting models: %v\n", err)
+		os.Exit(1)
+	}
+	variants := findQuantVariants(installed, base)
+	if len(variants) == 0 {
+		fmt.Printf("No quantized variants of '%s' installed (pull e.g. '%s-q4_K_M' and '%s-q8_0')\n", base, base, base)
+		os.Exit(1)
+	}
+
+	judge := benchmarkJudge
+	if judge == "" {
+		judge = variants[len(variants)-1].Name
+	}
+
+	fmt.Printf("Benchmarking %d quantization(s) of %s (judge: %s)...\n\n", len(variants), base, judge)
+	tests := getBenchmarkTests(benchmarkQuick)
+	for _, v := range variants {
+		fmt.Printf("Testing variant: %s (%s)\n", v.Name, v.Level)
+		fmt.Println(strings.Repeat("-", 50))
+		if benchmarkIsolate {
+			unloadRunningModels()
+		}
+		v.Results = runBenchmarkTests(v.Name, tests)
+
+		// The variant is still loaded right after its tests, so /api/ps reports its footprint
+		if running, err := llmClient.RunningModels(ctx); err == nil {
+			for _, r := range running {
+				if r.Name == v.Name {
+					v.Memory, v.VRAM = r.Size, r.SizeVRAM
+				}
+			}
+		} else if verbose {
+			fmt.Printf("Could not read memory usage: %v\n", err)
+		}
+		if benchmarkIsolate {
+			llmClient.Unload(ctx, v.Name)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("Scoring answers...")
+	for _, v := range variants {
+		v.Quality = judgeResults(judge, tests, v.Results)
+	}
+	fmt.Println()
+
+	printQuantSummary(variants)
+	saveQuantBenchmark(variants)
+}
+
+// judgeResults asks the judge model to score each successful answer from 1 to 10 and returns the mean
+func judgeResults(judge string, tests []BenchmarkTest, results []BenchmarkResult) float64 {
+	total, scored := 0.0, 0
+	for i, r := range results {
+		if !r.Success {
+			continue
+		}
+		prompt := fmt.Sprintf("Rate the following answer to the question on a scale of 1 to 10 for correctness, "+
+			"completeness, and clarity. Reply with the number only.\n\nQuestion: %s\n\nAnswer: %s", tests[i].Prompt, r.Response)
+		resp, err := llmClient.Chat(judge, prompt)
+		if err != nil {
+			if verbose {
+				fmt.Printf("Judge error on %s: %v\n", r.TestName, err)
+			}
+			continue
+		}
+		match := judgeScoreRE.FindString(resp.Message.Content)
+		if match == "" {
+			continue
+		}
+		score, _ := strconv.Atoi(match)
+		total += float64(score)
+		scored++
+	}
+	if scored == 0 {
+		return 0
+	}
+	return total / float64(scored)
+}
+
+// quantStats summarizes a variant's results
+func quantStats(v *quantVariant) (successRate, tokensPerSecond float64) {
+	success, speedSum, speedN := 0, 0.0, 0
+	for _, r := range v.Results {
+		if r.Success {
+			success++
+			if r.TokensPerSecond > 0 {
+				speedSum += r.TokensPerSecond
+				speedN++
+			}
+		}
+	}
+	if len(v.Results) > 0 {
+		successRate = float64(success) / float64(len(v.Results))
+	}
+	if speedN > 0 {
+		tokensPerSecond = speedSum / float64(speedN)
+	}
+	return successRate, tokensPerSecond
+}
+
+// formatBytes renders a byte count in GB, or "n/a" when unknown
+func formatBytes(n int64) string {
+	if n <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+}
+
+// recommendQuant picks the fastest reliable variant whose quality is within a point of the best,
+// preferring variants that fit entirely in GPU memory when any do
+func recommendQuant(variants []*quantVariant) (*quantVariant, string) {
+	bestQuality := 0.0
+	anyOnGPU := false
+	for _, v := range variants {
+		if v.Quality > bestQuality {
+			bestQuality = v.Quality
+		}
+		if v.Memory > 0 && v.VRAM >= v.Memory {
+			anyOnGPU = true
+		}
+	}
+
+	var pick *quantVariant
+	pickSpeed := -1.0
+	for _, v := range variants {
+		rate, speed := quantStats(v)
+		if rate < 0.8 || (bestQuality > 0 && v.Quality < bestQuality-1) {
+			continue
+		}
+		if anyOnGPU && v.VRAM < v.Memory {
+			continue
+		}
+		if speed > pickSpeed {
+			pick, pickSpeed = v, speed
+		}
+	}
+	if pick == nil {
+		return nil, "no variant passed at least 80% of the tests with quality close to the best"
+	}
+
+	reason := fmt.Sprintf("fastest variant (%.1f tokens/sec) scoring within 1 point of the best quality (%.1f/10)", pickSpeed, bestQuality)
+	if anyOnGPU {
+		reason += " that fits entirely in GPU memory"
+	} else if pick.Memory > 0 {
+		reason += "; no variant fits entirely in GPU memory on this machine"
+	}
+	return pick, reason
+}
+
+func printQuantSummary(variants []*quantVariant) {
+	fmt.Println("QUANTIZATION SUMMARY")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("%-36s %-8s %8s %9s %10s %10s %8s\n", "Variant", "Quant", "Passed", "Tok/s", "Memory", "GPU", "Quality")
+	for _, v := range variants {
+		rate, speed := quantStats(v)
+		quality := "n/a"
+		if v.Quality > 0 {
+			quality = fmt.Sprintf("%.1f", v.Quality)
+		}
+		memory := v.Memory
+		if memory == 0 {
+			memory = v.DiskSize
+		}
+		gpu := "n/a"
+		if v.Memory > 0 {
+			gpu = fmt.Sprintf("%.0f%%", float64(v.VRAM)/float64(v.Memory)*100)
+		}
+		fmt.Printf("%-36s %-8s %7.0f%% %9.1f %10s %10s %8s\n", v.Name, v.Level, rate*100, speed, formatBytes(memory), gpu, quality)
+	}
+
+	pick, reason := recommendQuant(variants)
+	fmt.Println()
+	if pick == nil {
+		fmt.Printf("No recommendation: %s\n", reason)
+		return
+	}
+	fmt.Printf("✅ Recommended: %s (%s)\n", pick.Name, pick.Level)
+	fmt.Printf("   %s\n", reason)
+}
@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/secure"
+	"kirk-ai/internal/sweep"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchmarkOut              string
+	benchmarkCompareThreshold float64
+	benchmarkFailOnRegression bool
+	// benchmarkProvider and benchmarkServer are what the benchmark ran against, for reports
+	benchmarkProvider string
+	benchmarkServer   string
+)
+
+// benchmarkReport is a benchmark run as --out saves it and 'benchmark compare' reads it
+type benchmarkReport struct {
+	Kind      string                 `json:"kind"` // "chat", "quant", or "embed"
+	CreatedAt time.Time              `json:"created_at"`
+	Machine   benchmarkMachine       `json:"machine"`
+	Settings  map[string]interface{} `json:"settings"`
+	Models    []benchmarkModelReport `json:"models"`
+}
+
+// benchmarkMachine is where a run happened, since timings only compare on the same machine
+type benchmarkMachine struct {
+	Hostname      string `json:"hostname"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	CPUs          int    `json:"cpus"`
+	Provider      string `json:"provider"`
+	Server        string `json:"server"`
+	ServerVersion string `json:"server_version,omitempty"`
+}
+
+// benchmarkModelReport is one model's results. Digest identifies the model's version, so a
+// comparison can tell a re-pulled model from a changed machine.
+type benchmarkModelReport struct {
+	Name    string                `json:"name"`
+	Digest  string                `json:"digest,omitempty"`
+	Tests   []benchmarkTestReport `json:"tests,omitempty"`
+	Memory  int64                 `json:"memory_bytes,omitempty"` // --quant: bytes in memory while loaded
+	VRAM    int64                 `json:"vram_bytes,omitempty"`
+	Quality float64               `json:"quality,omitempty"` // --quant: mean judge score, 1-10
+	Embed   *embedReport          `json:"embed,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// benchmarkTestReport is one chat test's outcome, averaged over its runs
+type benchmarkTestReport struct {
+	Name                  string  `json:"name"`
+	Success               bool    `json:"success"`
+	Runs                  int     `json:"runs"`
+	Seconds               float64 `json:"seconds"`
+	SecondsStdDev         float64 `json:"seconds_stddev,omitempty"`
+	TokensPerSecond       float64 `json:"tokens_per_second"`
+	TokensPerSecondStdDev float64 `json:"tokens_per_second_stddev,omitempty"`
+	Tokens                int     `json:"tokens"`
+	Error                 string  `json:"error,omitempty"`
+}
+
+// embedReport is one embedding model's --embed outcome
+type embedReport struct {
+	Dimension int            `json:"dimension"`
+	PerSecond float64        `json:"embeddings_per_second"`
+	P50Ms     float64        `json:"p50_ms"`
+	P90Ms     float64        `json:"p90_ms"`
+	P99Ms     float64        `json:"p99_ms"`
+	QA        *sweep.QAScore `json:"qa,omitempty"`
+}
+
+// newBenchmarkReport starts a report of kind for the current machine and flags
+func newBenchmarkReport(kind string) *benchmarkReport {
+	host, _ := os.Hostname()
+	r := &benchmarkReport{
+		Kind:      kind,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Machine: benchmarkMachine{
+			Hostname: host,
+			OS:       runtime.GOOS,
+			Arch:     runtime.GOARCH,
+			CPUs:     runtime.NumCPU(),
+			Provider: benchmarkProvider,
+			Server:   benchmarkServer,
+		},
+		Settings: map[string]interface{}{"warmup": benchmarkWarmup, "isolate": benchmarkIsolate},
+	}
+	if v, err := llmClient.ServerVersion(context.Background()); err == nil {
+		r.Machine.ServerVersion = v
+	}
+	switch kind {
+	case "embed":
+		r.Settings["embed_requests"] = benchmarkEmbedRequests
+		r.Settings["batch_size"] = benchmarkBatchSize
+		if benchmarkQA != "" {
+			r.Settings["qa"] = benchmarkQA
+		}
+	default:
+		r.Settings["quick"] = benchmarkQuick
+		r.Settings["repeat"] = benchmarkRepeat
+	}
+	return r
+}
+
+// modelDigests returns the installed models' digests by name, or none when the provider
+// does not report them
+func modelDigests() map[string]string {
+	digests := make(map[string]string)
+	installed, err := llmClient.ListModelDetails(context.Background())
+	if err != nil {
+		return digests
+	}
+	for _, m := range installed {
+		digests[m.Name] = m.Digest
+	}
+	return digests
+}
+
+// testReports converts a model's chat test results for a report
+func testReports(results []BenchmarkResult) []benchmarkTestReport {
+	tests := make([]benchmarkTestReport, 0, len(results))
+	for _, r := range results {
+		tests = append(tests, benchmarkTestReport{
+			Name:                  r.TestName,
+			Success:               r.Success,
+			Runs:                  r.Runs,
+			Seconds:               r.Duration.Seconds(),
+			SecondsStdDev:         r.DurationStdDev.Seconds(),
+			TokensPerSecond:       r.TokensPerSecond,
+			TokensPerSecondStdDev: r.TokensPerSecondStdDev,
+			Tokens:                r.TotalTokens,
+			Error:                 r.Error,
+		})
+	}
+	return tests
+}
+
+// saveChatBenchmark writes the chat benchmark's results to --out, if given
+func saveChatBenchmark(results map[string][]BenchmarkResult) {
+	if benchmarkOut == "" {
+		return
+	}
+	report := newBenchmarkReport("chat")
+	digests := modelDigests()
+	for name, modelResults := range results {
+		report.Models = append(report.Models, benchmarkModelReport{Name: name, Digest: digests[name], Tests: testReports(modelResults)})
+	}
+	sort.Slice(report.Models, func(i, j int) bool { return report.Models[i].Name < report.Models[j].Name })
+	saveBenchmarkReport(report)
+}
+
+// saveQuantBenchmark writes the --quant benchmark's results to --out, if given
+func saveQuantBenchmark(variants []*quantVariant) {
+	if benchmarkOut == "" {
+		return
+	}
+	report := newBenchmarkReport("quant")
+	digests := modelDigests()
+	for _, v := range variants {
+		report.Models = append(report.Models, benchmarkModelReport{
+			Name: v.Name, Digest: digests[v.Name], Tests: testReports(v.Results),
+			Memory: v.Memory, VRAM: v.VRAM, Quality: v.Quality,
+		})
+	}
+	saveBenchmarkReport(report)
+}
+
+// saveEmbedBenchmark writes the --embed benchmark's results to --out, if given
+func saveEmbedBenchmark(results []embedBenchmarkResult) {
+	if benchmarkOut == "" {
+		return
+	}
+	report := newBenchmarkReport("embed")
+	digests := modelDigests()
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	for _, r := range results {
+		m := benchmarkModelReport{Name: r.Model, Digest: digests[r.Model], Error: r.Error}
+		if r.Error == "" {
+			m.Embed = &embedReport{Dimension: r.Dimension, PerSecond: r.PerSecond,
+				P50Ms: ms(r.P50), P90Ms: ms(r.P90), P99Ms: ms(r.P99), QA: r.QA}
+		}
+		report.Models = append(report.Models, m)
+	}
+	saveBenchmarkReport(report)
+}
+
+// saveBenchmarkReport writes report to --out as CSV, or as JSON when the file ends in .json
+func saveBenchmarkReport(report *benchmarkReport) {
+	if err := writeBenchmarkReport(benchmarkOut, report); err != nil {
+		fmt.Printf("Error writing %s: %v\n", benchmarkOut, err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nResults written to %s\n", benchmarkOut)
+}
+
+// writeBenchmarkReport writes report to path, as JSON when it ends in .json and CSV otherwise
+func writeBenchmarkReport(path string, report *benchmarkReport) error {
+	f, err := atomicfile.Create(path, 0o644, false)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeBenchmarkCSV(f, report)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// writeBenchmarkCSV writes one row per chat test, or per embedding model, each carrying the
+// run's time and machine so rows from several runs can be appended into one sheet
+func writeBenchmarkCSV(w io.Writer, report *benchmarkReport) error {
+	cw := csv.NewWriter(w)
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	head := []string{report.CreatedAt.Format(time.RFC3339), report.Machine.Hostname, report.Machine.ServerVersion, report.Kind}
+	if report.Kind == "embed" {
+		cw.Write([]string{"created_at", "hostname", "server_version", "kind", "model", "digest", "dimension",
+			"embeddings_per_second", "p50_ms", "p90_ms", "p99_ms", "top1", "hit_at_k", "mrr", "error"})
+		for _, m := range report.Models {
+			row := append(append([]string(nil), head...), m.Name, m.Digest)
+			if e := m.Embed; e != nil {
+				row = append(row, strconv.Itoa(e.Dimension), num(e.PerSecond), num(e.P50Ms), num(e.P90Ms), num(e.P99Ms))
+				if e.QA != nil {
+					row = append(row, num(e.QA.Top1), num(e.QA.HitAtK), num(e.QA.MRR))
+				} else {
+					row = append(row, "", "", "")
+				}
+			} else {
+				row = append(row, "", "", "", "", "", "", "", "")
+			}
+			cw.Write(append(row, m.Error))
+		}
+	} else {
+		cw.Write([]string{"created_at", "hostname", "server_version", "kind", "model", "digest", "test", "success", "runs",
+			"seconds", "seconds_stddev", "tokens_per_second", "tokens_per_second_stddev", "tokens", "error"})
+		for _, m := range report.Models {
+			for _, t := range m.Tests {
+				cw.Write(append(append([]string(nil), head...), m.Name, m.Digest, t.Name, strconv.FormatBool(t.Success),
+					strconv.Itoa(t.Runs), num(t.Seconds), num(t.SecondsStdDev), num(t.TokensPerSecond),
+					num(t.TokensPerSecondStdDev), strconv.Itoa(t.Tokens), t.Error))
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// loadBenchmarkReport reads a report saved with --out as JSON
+func loadBenchmarkReport(path string) (*benchmarkReport, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		return nil, fmt.Errorf("%s: compare reads the JSON reports (save them with --out results.json)", path)
+	}
+	data, err := secure.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report benchmarkReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+var benchmarkCompareCmd = &cobra.Command{
+	Use:   "compare <old.json> <new.json>",
+	Short: "Compare two saved benchmark runs",
+	Long: `Compare two benchmark runs saved with --out as JSON, model by model and test by test, and
+flag regressions: tests that now fail, and timings, throughput, or retrieval quality that got
+worse by more than --threshold percent. Models whose digest changed between the runs were
+pulled again, which is usually what a comparison is looking for.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runBenchmarkCompare,
+}
+
+func runBenchmarkCompare(cmd *cobra.Command, args []string) {
+	old, err := loadBenchmarkReport(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	cur, err := loadBenchmarkReport(args[1])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if old.Kind != cur.Kind {
+		fmt.Printf("Error: %s holds a %s benchmark and %s a %s benchmark\n", args[0], old.Kind, args[1], cur.Kind)
+		os.Exit(1)
+	}
+
+	describe := func(label, path string, r *benchmarkReport) {
+		fmt.Printf("%s %s: %s on %s", label, path, r.CreatedAt.Local().Format("2006-01-02 15:04"), r.Machine.Hostname)
+		if r.Machine.ServerVersion != "" {
+			fmt.Printf(", server %s", r.Machine.ServerVersion)
+		}
+		fmt.Println()
+	}
+	describe("old", args[0], old)
+	describe("new", args[1], cur)
+	if old.Machine.Hostname != cur.Machine.Hostname || old.Machine.CPUs != cur.Machine.CPUs {
+		fmt.Println("Note: the runs come from different machines, so their timings are not directly comparable")
+	}
+
+	c := &benchmarkComparison{threshold: benchmarkCompareThreshold / 100}
+	oldModels := make(map[string]benchmarkModelReport, len(old.Models))
+	for _, m := range old.Models {
+		oldModels[m.Name] = m
+	}
+	for _, m := range cur.Models {
+		o, ok := oldModels[m.Name]
+		if !ok {
+			fmt.Printf("\n%s: only in the new run\n", m.Name)
+			continue
+		}
+		delete(oldModels, m.Name)
+		fmt.Printf("\n%s", m.Name)
+		if o.Digest != "" && m.Digest != "" && o.Digest != m.Digest {
+			fmt.Printf(" (updated: %s -> %s)", shortDigest(o.Digest), shortDigest(m.Digest))
+		}
+		fmt.Println()
+		c.compareModel(o, m)
+	}
+	for _, m := range old.Models {
+		if _, ok := oldModels[m.Name]; ok {
+			fmt.Printf("\n%s: only in the old run\n", m.Name)
+		}
+	}
+
+	fmt.Println()
+	if c.regressions == 0 {
+		fmt.Printf("No regressions beyond %.0f%%\n", benchmarkCompareThreshold)
+		return
+	}
+	fmt.Printf("%d regression(s) beyond %.0f%%\n", c.regressions, benchmarkCompareThreshold)
+	if benchmarkFailOnRegression {
+		os.Exit(1)
+	}
+}
+
+// benchmarkComparison prints metric changes and counts the regressions among them
+type benchmarkComparison struct {
+	threshold   float64 // relative change that counts, e.g. 0.1
+	regressions int
+}
+
+func (c *benchmarkComparison) compareModel(o, m benchmarkModelReport) {
+	switch {
+	case m.Embed != nil && o.Embed != nil:
+		c.metric("embeddings/s", o.Embed.PerSecond, m.Embed.PerSecond, true, "%.1f")
+		c.metric("p50 latency ms", o.Embed.P50Ms, m.Embed.P50Ms, false, "%.1f")
+		c.metric("p99 latency ms", o.Embed.P99Ms, m.Embed.P99Ms, false, "%.1f")
+		if o.Embed.QA != nil && m.Embed.QA != nil {
+			c.metric("top-1", o.Embed.QA.Top1, m.Embed.QA.Top1, true, "%.2f")
+			c.metric("MRR", o.Embed.QA.MRR, m.Embed.QA.MRR, true, "%.2f")
+		}
+		return
+	case m.Error != "" && o.Error == "":
+		fmt.Printf("  failed: %s  REGRESSION\n", m.Error)
+		c.regressions++
+		return
+	}
+	oldTests := make(map[string]benchmarkTestReport, len(o.Tests))
+	for _, t := range o.Tests {
+		oldTests[t.Name] = t
+	}
+	for _, t := range m.Tests {
+		ot, ok := oldTests[t.Name]
+		if !ok {
+			continue
+		}
+		switch {
+		case ot.Success && !t.Success:
+			fmt.Printf("  %s: now fails (%s)  REGRESSION\n", t.Name, t.Error)
+			c.regressions++
+		case !ot.Success && t.Success:
+			fmt.Printf("  %s: now passes\n", t.Name)
+		case t.Success:
+			c.metric(t.Name+" seconds", ot.Seconds, t.Seconds, false, "%.2f")
+			c.metric(t.Name+" tokens/s", ot.TokensPerSecond, t.TokensPerSecond, true, "%.1f")
+		}
+	}
+	if o.Quality > 0 && m.Quality > 0 {
+		c.metric("judge score", o.Quality, m.Quality, true, "%.1f")
+	}
+}
+
+// metric prints one metric's change, marking it a regression when it moved the wrong way
+// by more than the threshold; higherBetter tells which way is wrong
+func (c *benchmarkComparison) metric(name string, old, cur float64, higherBetter bool, format string) {
+	if old == 0 && cur == 0 {
+		return
+	}
+	line := fmt.Sprintf("  %-32s "+format+" -> "+format, name, old, cur)
+	if old == 0 {
+		fmt.Println(line)
+		return
+	}
+	change := (cur - old) / math.Abs(old)
+	line += fmt.Sprintf(" (%+.0f%%)", change*100)
+	worse := change < -c.threshold
+	if !higherBetter {
+		worse = change > c.threshold
+	}
+	if worse {
+		line += "  REGRESSION"
+		c.regressions++
+	}
+	fmt.Println(line)
+}
+
+// shortDigest abbreviates a model digest for display
+func shortDigest(d string) string {
+	d = strings.TrimPrefix(d, "sha256:")
+	if len(d) > 12 {
+		d = d[:12]
+	}
+	return d
+}
+
+func init() {
+	benchmarkCmd.AddCommand(benchmarkCompareCmd)
+	benchmarkCmd.Flags().StringVar(&benchmarkOut, "out", "", "Save the results with a timestamp and machine info as CSV, or JSON when the file ends in .json (compare JSON runs with 'benchmark compare')")
+	benchmarkCompareCmd.Flags().Float64Var(&benchmarkCompareThreshold, "threshold", 10, "Percent change in the wrong direction that counts as a regression")
+	benchmarkCompareCmd.Flags().BoolVar(&benchmarkFailOnRegression, "fail-on-regression", false, "Exit with status 1 when any regression is found, e.g. in CI")
+}
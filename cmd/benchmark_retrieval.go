@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/sweep"
+)
+
+var (
+	benchmarkRetrieval   string
+	benchmarkEmbeddings  string
+	benchmarkCollection  string
+	benchmarkThresholds  []float64
+	benchmarkTopKs       []int
+	benchmarkRetrievalTo string
+)
+
+// runRetrievalBenchmark embeds every query of a labeled set once, retrieves enough results
+// to cover the loosest setting, and reports precision and recall at each threshold and top-k
+func runRetrievalBenchmark(queryFile string) {
+	if benchmarkEmbeddings == "" && benchmarkCollection == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag or a collection with --collection")
+		os.Exit(1)
+	}
+	if len(benchmarkThresholds) == 0 || len(benchmarkTopKs) == 0 {
+		fmt.Println("--thresholds and --top-ks need at least one value each")
+		os.Exit(1)
+	}
+	thresholds := append([]float64(nil), benchmarkThresholds...)
+	sort.Float64s(thresholds)
+	topKs := append([]int(nil), benchmarkTopKs...)
+	sort.Ints(topKs)
+	if topKs[0] <= 0 {
+		fmt.Println("--top-ks values must be positive")
+		os.Exit(1)
+	}
+
+	queries, err := sweep.LoadQueries(queryFile)
+	if err != nil {
+		fmt.Printf("Error loading queries: %v\n", err)
+		os.Exit(1)
+	}
+	if len(queries) == 0 {
+		fmt.Printf("No queries in %s\n", queryFile)
+		os.Exit(1)
+	}
+	corp, err := loadCorpus(benchmarkEmbeddings, benchmarkCollection)
+	if err != nil {
+		fmt.Printf("Error loading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Retrieval benchmark: %d labeled queries against %d chunks", len(queries), corp.Len())
+	if corp.model != "" {
+		fmt.Printf(" (%s)", corp.model)
+	}
+	fmt.Println()
+	ranked := make([]sweep.Ranked, 0, len(queries))
+	for i, q := range queries {
+		qe, err := corp.embedQuery(context.Background(), q.Query, "")
+		if err != nil {
+			fmt.Printf("Error embedding query %d: %v\n", i+1, err)
+			os.Exit(1)
+		}
+		results, err := corp.Search(qe, topKs[len(topKs)-1], thresholds[0])
+		if err != nil {
+			fmt.Printf("Error searching embeddings: %v\n", err)
+			os.Exit(1)
+		}
+		if verbose {
+			fmt.Printf("[%d/%d] %q: %d results\n", i+1, len(queries), q.Query, len(results))
+		}
+		ranked = append(ranked, sweep.Ranked{Query: q, Results: results})
+	}
+
+	points := sweep.Evaluate(ranked, thresholds, topKs)
+	for _, k := range topKs {
+		fmt.Printf("\ntop-k %d\n", k)
+		fmt.Printf("%-9s %9s %7s %6s %8s %6s %9s\n", "threshold", "precision", "recall", "F1", "hit rate", "empty", "retrieved")
+		for _, p := range points {
+			if p.TopK != k {
+				continue
+			}
+			fmt.Printf("%-9.2f %9.2f %7.2f %6.2f %8.2f %6.2f %9.1f  %s\n", p.Threshold, p.Precision, p.Recall, p.F1,
+				p.HitRate, p.Empty, p.Retrieved, strings.Repeat("#", int(p.F1*20+0.5)))
+		}
+	}
+
+	best := sweep.Best(points)
+	fmt.Printf("\nBest F1 %.2f at --threshold %.2f --top-k %d (precision %.2f, recall %.2f, hit rate %.2f)\n",
+		best.F1, best.Threshold, best.TopK, best.Precision, best.Recall, best.HitRate)
+	current := defaultSearchThreshold
+	if corp.calibration != nil {
+		current = corp.calibration.Search
+	}
+	if current >= thresholds[0] && defaultSearchTopK <= topKs[len(topKs)-1] {
+		p := sweep.Evaluate(ranked, []float64{current}, []int{defaultSearchTopK})[0]
+		fmt.Printf("Current search default (--threshold %.2f --top-k %d): F1 %.2f (precision %.2f, recall %.2f)\n",
+			current, defaultSearchTopK, p.F1, p.Precision, p.Recall)
+	}
+
+	if benchmarkRetrievalTo != "" {
+		if err := writeRetrievalSweep(benchmarkRetrievalTo, points, best, len(queries)); err != nil {
+			fmt.Printf("Error writing %s: %v\n", benchmarkRetrievalTo, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Sweep written to %s\n", benchmarkRetrievalTo)
+	}
+}
+
+// writeRetrievalSweep exports the sweep as CSV, or as JSON when path ends in .json
+func writeRetrievalSweep(path string, points []sweep.Point, best sweep.Point, queries int) error {
+	f, err := atomicfile.Create(path, 0o644, false)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Queries int           `json:"queries"`
+			Best    sweep.Point   `json:"best"`
+			Points  []sweep.Point `json:"points"`
+		}{queries, best, points})
+	} else {
+		err = sweep.WriteCSV(f, points)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Commit()
+}
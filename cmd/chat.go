@@ -1,44 +1,56 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"kirk-ai/internal/conversation"
 	"kirk-ai/internal/models"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	chatSession       string
+	chatContextTokens int
+	chatKeepMessages  int
+	chatInteractive   bool
+	noHistory         bool
+)
+
 // chatCmd represents the chat command
 var chatCmd = &cobra.Command{
 	Use:   "chat [text]",
 	Short: "Send a chat message to the AI model",
-	Long:  `Send a text prompt to the specified AI model and receive a response.`,
-	Args:  cobra.MinimumNArgs(1),
-	Run:   runChatCommand,
+	Long: `Send a text prompt to the specified AI model and receive a response.
+
+With --interactive, open a multi-turn conversation instead: each reply sees the whole history,
+and slash commands (/help, /reset, /model, /save, /exit) manage the session.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if chatInteractive {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: runChatCommand,
 }
 
 func runChatCommand(cmd *cobra.Command, args []string) {
+	applyReplyLang()
+	applyRedact()
+	if chatInteractive {
+		runChatREPL(strings.Join(args, " "))
+		return
+	}
 	prompt := strings.Join(args, " ")
 
-	selectedModel := model
-	if selectedModel == "" {
-		// Auto-select the first available chat model
-		models, err := ollamaClient.ListModels()
-		if err != nil {
-			fmt.Printf("Error getting models: %v\n", err)
-			os.Exit(1)
-		}
-		if len(models) == 0 {
-			fmt.Println("No models found. Please install a model first using 'ollama pull <model-name>'")
-			os.Exit(1)
-		}
-		selectedModel = ollamaClient.SelectChatModel(models)
-		if selectedModel == "" {
-			fmt.Println("No suitable chat model found")
-			os.Exit(1)
-		}
+	selectedModel, err := chatModel()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
 	if verbose {
@@ -50,30 +62,51 @@ func runChatCommand(cmd *cobra.Command, args []string) {
 		fmt.Println("---")
 	}
 
-	var response *models.ChatResponse
-	var err error
-
-	if stream {
-		// Use streaming mode
-		response, err = ollamaClient.ChatStream(selectedModel, prompt, func(chunk *models.StreamingChatResponse) error {
-			// Print each chunk as it arrives
-			fmt.Print(chunk.Message.Content)
-			return nil
-		})
-		fmt.Println() // Add newline after streaming
-	} else {
-		// Use non-streaming mode
-		response, err = ollamaClient.Chat(selectedModel, prompt)
-		if err == nil {
-			fmt.Printf("%s\n", response.Message.Content)
+	// A session carries the conversation across invocations, summarizing older turns
+	// once the history outgrows --context-tokens
+	conv := &conversation.Conversation{}
+	if chatSession != "" {
+		conv, err = conversation.Load(chatSession)
+		if err != nil {
+			fmt.Printf("Error loading session: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	conv.Add("user", prompt)
+	if chatSession != "" {
+		summarized, err := conv.Compact(context.Background(), chatContextTokens, chatKeepMessages, chatSummarizer(selectedModel))
+		if err != nil {
+			fmt.Printf("Error in chat: %v\n", err)
+			os.Exit(1)
+		}
+		if summarized && verbose {
+			fmt.Printf("Summarized older turns (%d messages in summary so far)\n", conv.SummarizedTurns)
 		}
 	}
+	out, err := openTee()
+	if err != nil {
+		fmt.Printf("Error opening --tee file: %v\n", err)
+		os.Exit(1)
+	}
+	response, err := sendChat(context.Background(), selectedModel, conv, out)
+	out.Write("\n")
+	if teeErr := out.Close(); teeErr != nil {
+		fmt.Printf("Error writing --tee file: %v\n", teeErr)
+	}
 
 	if err != nil {
 		fmt.Printf("Error in chat: %v\n", err)
 		os.Exit(1)
 	}
 
+	if chatSession != "" {
+		conv.Add("assistant", response.Message.Content)
+		if err := conv.Save(chatSession, encryptOutput); err != nil {
+			fmt.Printf("Error saving session: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if verbose {
 		fmt.Printf("\n--- Response metadata ---\n")
 		fmt.Printf("Model: %s\n", response.Model)
@@ -86,6 +119,104 @@ func runChatCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// chatModel returns --model, or auto-selects the first available chat model
+func chatModel() (string, error) {
+	if model != "" {
+		return model, nil
+	}
+	models, err := llmClient.ListModels()
+	if err != nil {
+		return "", fmt.Errorf("Error getting models: %v", err)
+	}
+	if len(models) == 0 {
+		return "", fmt.Errorf("No models found. Please install a model first using 'ollama pull <model-name>'")
+	}
+	selected := llmClient.SelectChatModel(models)
+	if selected == "" {
+		return "", fmt.Errorf("No suitable chat model found")
+	}
+	return selected, nil
+}
+
+// sendChat sends the conversation to the model and prints the reply, streaming it when
+// --stream is set, and copies it to out
+func sendChat(ctx context.Context, selectedModel string, conv *conversation.Conversation, out *tee) (*models.ChatResponse, error) {
+	return sendChatMessages(ctx, selectedModel, conv.ChatMessages(), out)
+}
+
+// sendChatMessages is sendChat for messages put together by the caller
+func sendChatMessages(ctx context.Context, selectedModel string, messages []models.Message, out *tee) (*models.ChatResponse, error) {
+	request := models.ChatRequest{Model: selectedModel, Messages: messages}
+	if !stream {
+		response, err := llmClient.ChatWithRequest(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if got := wrongReplyLang(response.Message.Content); got != "" {
+			if verbose {
+				fmt.Printf("The reply came back in %s; asking again for %s\n", got, replyLang)
+			}
+			request.Messages = remindReplyLang(request.Messages)
+			if response, err = llmClient.ChatWithRequest(ctx, request); err != nil {
+				return nil, err
+			}
+		}
+		response.Message.Content = redactAnswer(response.Message.Content)
+		fmt.Printf("%s\n", response.Message.Content)
+		warnReplyLang(response.Message.Content)
+		out.Write(response.Message.Content)
+		return response, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 300*time.Second)
+	defer cancel()
+	stats := newStreamStats()
+	response, err := llmClient.ChatStreamWithRequest(ctx, request, stats.observe(func(chunk *models.StreamingChatResponse) error {
+		// Print each chunk as it arrives
+		fmt.Print(chunk.Message.Content)
+		out.Write(chunk.Message.Content)
+		return nil
+	}))
+	fmt.Println() // Add newline after streaming
+	if err == nil {
+		warnReplyLang(response.Message.Content)
+		stats.print(response)
+	}
+	return response, err
+}
+
+// remindReplyLang returns messages with the --reply-lang reminder added to the last one, for
+// asking again after a reply in the wrong language; the conversation itself is left as it was
+func remindReplyLang(messages []models.Message) []models.Message {
+	out := append([]models.Message(nil), messages...)
+	out[len(out)-1].Content += replyLangReminder()
+	return out
+}
+
+// chatSummarizer summarizes older conversation turns with the chat model itself
+func chatSummarizer(chatModel string) conversation.Summarizer {
+	return func(ctx context.Context, prompt string) (string, error) {
+		resp, err := llmClient.ChatContext(ctx, chatModel, prompt)
+		if err != nil {
+			return "", err
+		}
+		return resp.Message.Content, nil
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(chatCmd)
+	requireServer(chatCmd, "")
+
+	addTeeFlag(chatCmd)
+	addGenerationFlags(chatCmd)
+	addReplyLangFlag(chatCmd)
+	addRedactFlags(chatCmd)
+	chatCmd.Flags().BoolVarP(&chatInteractive, "interactive", "i", false, "Open an interactive multi-turn chat; a prompt given on the command line is sent first")
+	chatCmd.Flags().BoolVar(&noHistory, "no-history", false, "With --interactive, do not read or save the input history in ~/.kirk-ai/history")
+	chatCmd.Flags().StringVar(&chatSession, "session", "", "Continue the conversation stored in this JSON file (created if missing)")
+	chatCmd.Flags().IntVar(&chatContextTokens, "context-tokens", conversation.DefaultBudget,
+		"With --session or --interactive, summarize older turns once the history exceeds this many tokens")
+	chatCmd.Flags().IntVar(&chatKeepMessages, "keep-messages", conversation.DefaultKeepRecent,
+		"With --session or --interactive, number of most recent messages always kept verbatim")
 }
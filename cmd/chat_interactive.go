@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/conversation"
+	"kirk-ai/internal/lineedit"
+)
+
+const chatREPLHelp = `Commands:
+  /help              Show this help
+  /reset             Forget the conversation and start over
+  /model [name]      Show the current model and the installed ones, or switch to name
+  /save [file]       Save the conversation (default: the --session file)
+  /history           Show the conversation so far
+  /exit, /quit       Leave (Ctrl-D works too)
+End a line with \ to continue the message on the next line. Ctrl-C stops a reply.
+Up/Down recall earlier input (kept in ~/.kirk-ai/history/chat); Ctrl-R searches it.`
+
+// chatREPL is an interactive multi-turn chat with the conversation kept in memory
+type chatREPL struct {
+	model string
+	conv  *conversation.Conversation
+	out   *tee
+}
+
+// runChatREPL reads prompts from stdin until /exit or end of input, sending the whole
+// history with each one. first, when set, is sent before the first prompt is read.
+func runChatREPL(first string) {
+	selectedModel, err := chatModel()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	r := &chatREPL{model: selectedModel, conv: &conversation.Conversation{}}
+	if chatSession != "" {
+		r.conv, err = conversation.Load(chatSession)
+		if err != nil {
+			fmt.Printf("Error loading session: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	r.out, err = openTee()
+	if err != nil {
+		fmt.Printf("Error opening --tee file: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := r.out.Close(); err != nil {
+			fmt.Printf("Error writing --tee file: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("Chatting with %s. Type /help for commands, /exit to leave.\n", r.model)
+	if n := len(r.conv.Messages); n > 0 {
+		fmt.Printf("Continuing %s (%d messages)\n", chatSession, n)
+	}
+	if first != "" {
+		fmt.Printf(">>> %s\n", first)
+		r.send(first)
+	}
+
+	in := lineedit.New(os.Stdin, os.Stdout, replHistory("chat"))
+	for {
+		line, err := readChatInput(in)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("Error reading input: %v\n", err)
+			}
+			fmt.Println()
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "/"):
+			if !r.command(line) {
+				return
+			}
+		default:
+			r.send(line)
+		}
+	}
+}
+
+// replHistory opens the input history of an interactive mode, or returns nil with
+// --no-history. A history that cannot be read is reported and not kept.
+func replHistory(mode string) *lineedit.History {
+	if noHistory {
+		return nil
+	}
+	h, err := lineedit.LoadHistory(config.Path("history", mode), lineedit.DefaultHistorySize)
+	if err != nil {
+		fmt.Printf("Warning: not keeping input history: %v\n", err)
+		return nil
+	}
+	return h
+}
+
+// readChatInput prompts for one message, joining lines that end with a backslash
+func readChatInput(in *lineedit.Editor) (string, error) {
+	prompt := ">>> "
+	var lines []string
+	for {
+		line, err := in.ReadLine(prompt)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasSuffix(line, `\`) {
+			lines = append(lines, strings.TrimSuffix(line, `\`))
+			prompt = "... "
+			continue
+		}
+		return strings.Join(append(lines, line), "\n"), nil
+	}
+}
+
+// send adds the prompt to the history and prints the reply. A failed or interrupted turn
+// is dropped from the history so the next prompt does not follow an unanswered one.
+func (r *chatREPL) send(prompt string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	r.conv.Add("user", prompt)
+	summarized, err := r.conv.Compact(ctx, chatContextTokens, chatKeepMessages, chatSummarizer(r.model))
+	if err != nil {
+		fmt.Printf("Error in chat: %v\n", err)
+		r.conv.Messages = r.conv.Messages[:len(r.conv.Messages)-1]
+		return
+	}
+	if summarized && verbose {
+		fmt.Printf("Summarized older turns (%d messages in summary so far)\n", r.conv.SummarizedTurns)
+	}
+
+	r.out.Write(">>> " + prompt + "\n")
+	response, err := sendChat(ctx, r.model, r.conv, r.out)
+	r.out.Write("\n\n")
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Println("(interrupted)")
+		} else {
+			fmt.Printf("Error in chat: %v\n", err)
+		}
+		r.conv.Messages = r.conv.Messages[:len(r.conv.Messages)-1]
+		return
+	}
+	r.conv.Add("assistant", response.Message.Content)
+
+	if chatSession != "" {
+		if err := r.conv.Save(chatSession, encryptOutput); err != nil {
+			fmt.Printf("Error saving session: %v\n", err)
+		}
+	}
+	if verbose && response.EvalCount > 0 && response.EvalDuration > 0 {
+		fmt.Printf("(%d tokens, %.2f tokens/s)\n", response.EvalCount, float64(response.EvalCount)/(float64(response.EvalDuration)/1e9))
+	}
+}
+
+// command runs a slash command and reports whether the REPL should keep going
+func (r *chatREPL) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch strings.ToLower(name) {
+	case "/exit", "/quit", "/bye":
+		return false
+	case "/help", "/?":
+		fmt.Println(chatREPLHelp)
+	case "/reset", "/clear":
+		r.conv = &conversation.Conversation{}
+		fmt.Println("Conversation cleared.")
+	case "/model":
+		if arg == "" {
+			fmt.Printf("Current model: %s\n", r.model)
+			if models, err := llmClient.ListModels(); err == nil {
+				fmt.Printf("Installed: %s\n", strings.Join(models, ", "))
+			}
+			return true
+		}
+		selected, err := resolveChatModel(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return true
+		}
+		r.model = selected
+		fmt.Printf("Switched to %s; the conversation continues with it.\n", r.model)
+	case "/save":
+		path := arg
+		if path == "" {
+			path = chatSession
+		}
+		if path == "" {
+			fmt.Println("Usage: /save <file> (or start with --session to save automatically)")
+			return true
+		}
+		if err := r.conv.Save(path, encryptOutput); err != nil {
+			fmt.Printf("Error saving session: %v\n", err)
+			return true
+		}
+		fmt.Printf("Saved %d messages to %s (continue with: kirk-ai chat -i --session %s)\n", len(r.conv.Messages), path, path)
+	case "/history":
+		if r.conv.Summary != "" {
+			fmt.Printf("[summary of %d earlier messages]\n%s\n\n", r.conv.SummarizedTurns, r.conv.Summary)
+		}
+		for _, m := range r.conv.Messages {
+			fmt.Printf("[%s]\n%s\n\n", m.Role, m.Content)
+		}
+	default:
+		fmt.Printf("Unknown command %s. Type /help for commands.\n", name)
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/templates"
+
+	"github.com/spf13/cobra"
+)
+
+var codeTemplate string
+
+// codeCmd represents the code command
+var codeCmd = &cobra.Command{
+	Use:   "code [request]",
+	Short: "Generate, review, or debug code with a coding model",
+	Long: `Send a coding request to the best installed coding model (gemma3:4b preferred), wrapped in a
+prompt template. Pass --embeddings or --collection to inject matching internal API docs as context.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runCodeCommand,
+}
+
+func runCodeCommand(cmd *cobra.Command, args []string) {
+	request := strings.Join(args, " ")
+
+	name := codeTemplate
+	if name == "" {
+		name = templates.GetOptimalTemplate(request)
+		if name == "" {
+			name = "code_generation"
+		}
+	}
+	prompt, err := templates.ApplyTemplate(name, map[string]string{"prompt": request})
+	if err != nil {
+		fmt.Printf("Error: %v (available: %s)\n", err, strings.Join(templateNames(), ", "))
+		os.Exit(1)
+	}
+
+	prompt, err = withReference(prompt, request)
+	if err != nil {
+		fmt.Printf("Error retrieving reference material: %v\n", err)
+		os.Exit(1)
+	}
+
+	selectedModel, err := selectModelFor(config.CapabilityCode)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if verbose {
+		fmt.Printf("Using model: %s (template: %s)\n", selectedModel, name)
+		fmt.Println("---")
+	}
+
+	if err := printCompletion(selectedModel, prompt); err != nil {
+		fmt.Printf("Error generating code: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// templateNames lists the prompt template names, sorted
+func templateNames() []string {
+	names := make([]string, 0)
+	for name := range templates.ListTemplates() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectModelFor returns --model or the best installed model for capability
+func selectModelFor(capability config.ModelCapability) (string, error) {
+	if model != "" {
+		return model, nil
+	}
+	modelsList, err := llmClient.ListModels()
+	if err != nil {
+		return "", fmt.Errorf("error getting models: %w", err)
+	}
+	if len(modelsList) == 0 {
+		return "", fmt.Errorf("no models found. Please install a model first using 'ollama pull <model-name>'")
+	}
+	selected := llmClient.SelectModelByCapability(modelsList, string(capability))
+	if selected == "" {
+		return "", fmt.Errorf("no suitable %s model found", capability)
+	}
+	return selected, nil
+}
+
+// printCompletion sends prompt to selectedModel and prints the reply, streaming it with
+// --stream and copying it to --tee
+func printCompletion(selectedModel, prompt string) error {
+	out, err := openTee()
+	if err != nil {
+		return fmt.Errorf("opening --tee file: %w", err)
+	}
+
+	if stream {
+		stats := newStreamStats()
+		var response *models.ChatResponse
+		response, err = llmClient.ChatStream(selectedModel, prompt, stats.observe(func(chunk *models.StreamingChatResponse) error {
+			fmt.Print(chunk.Message.Content)
+			out.Write(chunk.Message.Content)
+			return nil
+		}))
+		fmt.Println()
+		if err == nil {
+			stats.print(response)
+		}
+	} else {
+		var response *models.ChatResponse
+		response, err = llmClient.Chat(selectedModel, prompt)
+		if err == nil {
+			fmt.Println(response.Message.Content)
+			out.Write(response.Message.Content)
+		}
+	}
+	out.Write("\n")
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing --tee file: %w", closeErr)
+	}
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(codeCmd)
+	requireServer(codeCmd, "")
+
+	codeCmd.Flags().StringVar(&codeTemplate, "template", "", "Prompt template: code_generation, code_review, debugging, ... (default: chosen from the request)")
+	addReferenceFlags(codeCmd)
+	addTeeFlag(codeCmd)
+	addGenerationFlags(codeCmd)
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/collections"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	collectionEmbeddings  string
+	collectionInStore     string
+	collectionDescription string
+)
+
+// collectionsCmd manages the registry of named collections
+var collectionsCmd = &cobra.Command{
+	Use:   "collections",
+	Short: "Register embeddings files and stores under friendly names",
+	Long: `Register an embeddings file, shard manifest, or store collection under a name kept in
+~/.kirk-ai/collections.json (or $KIRK_AI_HOME), so every command that takes --collection
+finds it from any directory:
+
+  kirk-ai collections create tpusa --embeddings tpusa_crawl/embeddings/embeddings.json
+  kirk-ai search --collection tpusa "campus chapters"
+
+A --collection name that is not registered is a collection in --store, as before.`,
+}
+
+var collectionsCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Register an embeddings file or a store collection under a name",
+	Long: `Register name for the embeddings file or shard manifest given with --embeddings or, without
+it, for the collection of the same name (or --store-collection) in --store. Relative paths
+are stored as absolute ones.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runCollectionsCreateCommand,
+}
+
+var collectionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered collections and the collections in --store",
+	Args:  cobra.NoArgs,
+	Run:   runCollectionsListCommand,
+}
+
+var collectionsInfoCmd = &cobra.Command{
+	Use:   "info [name]",
+	Short: "Show where a collection points, its embedding model, and its size",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCollectionsInfoCommand,
+}
+
+var collectionsDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Unregister a collection; its files are left in place",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCollectionsDeleteCommand,
+}
+
+// collectionTarget is where a --collection name points: an embeddings file, or a
+// collection in a store
+type collectionTarget struct {
+	file       string
+	store      string
+	collection string
+}
+
+// remote reports whether the target lives on a Qdrant server rather than on disk
+func (t collectionTarget) remote() bool {
+	return vectorstore.IsQdrant(t.file) || (t.collection != "" && vectorstore.IsQdrant(t.store))
+}
+
+// resolveCollection looks name up in the collections registry. A registered name stands for
+// its file or store collection; any other name is a collection in --store.
+func resolveCollection(name string) (collectionTarget, error) {
+	reg, err := collections.Load(collections.Path())
+	if err != nil {
+		return collectionTarget{}, err
+	}
+	if e, ok := reg.Get(name); ok {
+		if e.File != "" {
+			return collectionTarget{file: e.File}, nil
+		}
+		return collectionTarget{store: e.Store, collection: e.StoreCollection()}, nil
+	}
+	return collectionTarget{store: storeDir, collection: name}, nil
+}
+
+// writableCollection resolves name for commands that add to a collection, which cannot
+// write into a registered embeddings file
+func writableCollection(name string) (collectionTarget, error) {
+	target, err := resolveCollection(name)
+	if err == nil && target.file != "" {
+		err = fmt.Errorf("collection %q is registered to the file %s; write it with --out instead", name, target.file)
+	}
+	return target, err
+}
+
+func loadRegistry() *collections.Registry {
+	reg, err := collections.Load(collections.Path())
+	if err != nil {
+		fmt.Printf("Error reading collections: %v\n", err)
+		os.Exit(1)
+	}
+	return reg
+}
+
+func runCollectionsCreateCommand(cmd *cobra.Command, args []string) {
+	name := args[0]
+	entry := collections.Entry{Name: name, Description: collectionDescription}
+	if collectionEmbeddings != "" {
+		if cmd.Flags().Changed("store-collection") {
+			fmt.Println("Use either --embeddings or --store-collection, not both")
+			os.Exit(1)
+		}
+		if _, err := os.Stat(collectionEmbeddings); err != nil {
+			fmt.Printf("Error in --embeddings: %v\n", err)
+			os.Exit(1)
+		}
+		entry.File = collectionEmbeddings
+	} else {
+		entry.Store, entry.Collection = storeDir, collectionInStore
+	}
+
+	reg := loadRegistry()
+	added, err := reg.Add(entry)
+	if err != nil {
+		fmt.Printf("Error registering collection: %v\n", err)
+		os.Exit(1)
+	}
+	if err := reg.Save(); err != nil {
+		fmt.Printf("Error saving collections: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Registered collection %s -> %s\n", added.Name, added.Target())
+	if added.Kind() == "store" {
+		if _, err := vectorstore.NewStore(added.Store).Info(added.StoreCollection()); err != nil {
+			fmt.Printf("Note: %s does not exist yet; fill it with 'embed --collection %s'\n", added.Target(), added.Name)
+		}
+	}
+}
+
+func runCollectionsListCommand(cmd *cobra.Command, args []string) {
+	reg := loadRegistry()
+	entries := reg.List()
+	if len(entries) == 0 {
+		fmt.Println("No registered collections (add one with 'collections create')")
+	} else {
+		fmt.Println("Registered collections:")
+		for _, e := range entries {
+			fmt.Printf("  %-20s %-6s %s\n", e.Name, e.Kind(), e.Target())
+			if e.Description != "" {
+				fmt.Printf("  %-20s %-6s %s\n", "", "", e.Description)
+			}
+		}
+	}
+
+	if vectorstore.IsQdrant(storeDir) {
+		return
+	}
+	infos, err := vectorstore.NewStore(storeDir).List()
+	if err != nil {
+		fmt.Printf("Error listing %s: %v\n", storeDir, err)
+		os.Exit(1)
+	}
+	var unregistered []*vectorstore.CollectionInfo
+	for _, info := range infos {
+		if _, ok := reg.Get(info.Name); !ok {
+			unregistered = append(unregistered, info)
+		}
+	}
+	if len(unregistered) > 0 {
+		fmt.Printf("\nIn --store %s:\n", storeDir)
+		for _, info := range unregistered {
+			fmt.Printf("  %-20s %d chunks, %s\n", info.Name, info.Count, info.EmbeddingModel)
+		}
+	}
+}
+
+func runCollectionsInfoCommand(cmd *cobra.Command, args []string) {
+	name := args[0]
+	if e, ok := loadRegistry().Get(name); ok {
+		fmt.Printf("Name: %s\n", e.Name)
+		fmt.Printf("Kind: %s\n", e.Kind())
+		fmt.Printf("Target: %s\n", e.Target())
+		if e.Description != "" {
+			fmt.Printf("Description: %s\n", e.Description)
+		}
+		fmt.Printf("Registered: %s\n", e.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	} else {
+		fmt.Printf("Name: %s (not registered; a collection in %s)\n", name, storeDir)
+	}
+
+	corp, calibrationPath, err := openCorpus("", name)
+	if err != nil {
+		fmt.Printf("Error opening collection: %v\n", err)
+		os.Exit(1)
+	}
+	model := corp.model
+	if model == "" {
+		model = "(not recorded)"
+	}
+	fmt.Printf("Embedding model: %s\n", model)
+	fmt.Printf("Chunks: %d\n", corp.Len())
+	if len(corp.failed) > 0 {
+		fmt.Printf("Failed chunks: %s\n", vectorstore.FormatErrorCounts(corp.failed))
+	}
+	if calibrationPath != "" {
+		if cal, err := vectorstore.ReadCalibration(calibrationPath); err == nil && cal != nil {
+			fmt.Printf("Calibrated: search %.2f, rag %.2f\n", cal.Search, cal.RAG)
+		}
+	}
+	if corp.annPath != "" {
+		if _, err := os.Stat(corp.annPath); err == nil {
+			fmt.Printf("Approximate index: %s\n", corp.annPath)
+		}
+	}
+}
+
+func runCollectionsDeleteCommand(cmd *cobra.Command, args []string) {
+	reg := loadRegistry()
+	e, ok := reg.Get(args[0])
+	if !ok {
+		fmt.Printf("Collection %q is not registered\n", args[0])
+		os.Exit(1)
+	}
+	reg.Remove(e.Name)
+	if err := reg.Save(); err != nil {
+		fmt.Printf("Error saving collections: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Unregistered collection %s; %s was left in place\n", e.Name, e.Target())
+}
+
+func init() {
+	rootCmd.AddCommand(collectionsCmd)
+	collectionsCmd.AddCommand(collectionsCreateCmd, collectionsListCmd, collectionsInfoCmd, collectionsDeleteCmd)
+
+	collectionsCreateCmd.Flags().StringVar(&collectionEmbeddings, "embeddings", "",
+		"Embeddings file or shard manifest to register (default: a collection in --store)")
+	collectionsCreateCmd.Flags().StringVar(&collectionInStore, "store-collection", "",
+		"Collection in --store to register, when its name differs from the registered name")
+	collectionsCreateCmd.Flags().StringVar(&collectionDescription, "description", "",
+		"Optional description shown by list and info")
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/corpusdiff"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	corpusDiffText  bool
+	corpusDiffLimit int
+	corpusDiffJSON  bool
+)
+
+// corpusCmd groups commands working on the processed output of crawls
+var corpusCmd = &cobra.Command{
+	Use:   "corpus",
+	Short: "Inspect the processed pages of crawl runs",
+}
+
+var corpusDiffCmd = &cobra.Command{
+	Use:   "diff [runA] [runB]",
+	Short: "Show pages added, removed, or changed between two crawl runs",
+	Long: `Compare the processed pages of two crawl runs, such as two scheduled crawls of the same
+site. Each run is a processed_pages.json file or a crawl directory containing
+processed_data/processed_pages.json (for example a copy of tpusa_crawl).
+
+Pages are matched by URL. Pages whose text changed are split into chunks the way embedprep
+splits them, and the chunks that differ are summarized; add --text to see the changed words.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runCorpusDiffCommand,
+}
+
+func runCorpusDiffCommand(cmd *cobra.Command, args []string) {
+	oldRun, err := corpusdiff.Load(args[0])
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	newRun, err := corpusdiff.Load(args[1])
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", args[1], err)
+		os.Exit(1)
+	}
+
+	diff := corpusdiff.Compare(oldRun, newRun, chunker.DefaultMaxTokens)
+	if corpusDiffJSON {
+		b, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Printf("Error encoding diff: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	fmt.Printf("Corpus diff: %s -> %s\n", oldRun.Path, newRun.Path)
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf("Pages: %d -> %d\n", diff.OldPages, diff.NewPages)
+	fmt.Printf("Added: %d\n", len(diff.Added))
+	fmt.Printf("Removed: %d\n", len(diff.Removed))
+	fmt.Printf("Changed: %d\n", len(diff.Changed))
+	fmt.Printf("Unchanged: %d\n", diff.Unchanged)
+
+	if len(diff.Added) > 0 {
+		fmt.Println("\nAdded pages:")
+		printCorpusPages("+", diff.Added)
+	}
+	if len(diff.Removed) > 0 {
+		fmt.Println("\nRemoved pages:")
+		printCorpusPages("-", diff.Removed)
+	}
+	if len(diff.Changed) == 0 {
+		return
+	}
+	fmt.Println("\nChanged pages:")
+	for i, c := range diff.Changed {
+		if corpusDiffLimit > 0 && i == corpusDiffLimit {
+			fmt.Printf("  ... and %d more (raise --limit to see them)\n", len(diff.Changed)-i)
+			break
+		}
+		added, removed := 0, 0
+		for _, e := range c.Edits {
+			if e.New != "" {
+				added++
+			}
+			if e.Old != "" {
+				removed++
+			}
+		}
+		fmt.Printf("  ~ %s\n", c.Key)
+		fmt.Printf("      chunks %d -> %d (%d kept, +%d -%d), words %d -> %d\n",
+			c.OldChunks, c.NewChunks, c.Kept, added, removed, c.OldWords, c.NewWords)
+		if !corpusDiffText {
+			continue
+		}
+		for _, e := range c.Edits {
+			oldText, newText := e.Summarize(200)
+			if oldText != "" {
+				fmt.Printf("      - %s\n", oldText)
+			}
+			if newText != "" {
+				fmt.Printf("      + %s\n", newText)
+			}
+		}
+	}
+}
+
+func printCorpusPages(prefix string, pages []*corpusdiff.Page) {
+	for i, p := range pages {
+		if corpusDiffLimit > 0 && i == corpusDiffLimit {
+			fmt.Printf("  ... and %d more (raise --limit to see them)\n", len(pages)-i)
+			return
+		}
+		line := fmt.Sprintf("  %s %s", prefix, p.Key)
+		if p.Title != "" {
+			line += " (" + p.Title + ")"
+		}
+		fmt.Printf("%s, %d words\n", line, p.Words)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(corpusCmd)
+	corpusCmd.AddCommand(corpusDiffCmd)
+
+	corpusDiffCmd.Flags().BoolVar(&corpusDiffText, "text", false,
+		"Show the changed words of each changed chunk")
+	corpusDiffCmd.Flags().IntVar(&corpusDiffLimit, "limit", 20,
+		"Pages listed per section (0 lists all)")
+	corpusDiffCmd.Flags().BoolVar(&corpusDiffJSON, "json", false,
+		"Print the full diff, with the text of every changed chunk, as JSON")
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"kirk-ai/internal/crawl"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// crawlCmd represents the crawl command
+var crawlCmd = &cobra.Command{
+	Use:   "crawl",
+	Short: "Crawl pages into the workspace for processing",
+	Long: `Fetch pages for the corpus with one of several crawlers. Every crawler honours robots.txt
+and the per-host delays of the crawl config, and writes its raw results under the workspace
+(tpusa_crawl/ unless --workspace names another), where 'process content' picks them up.
+
+Start with requests, which needs nothing but HTTP; use hybrid when some pages only render
+their content with JavaScript, since it opens a headless browser only for those pages.`,
+}
+
+func init() {
+	rootCmd.AddCommand(crawlCmd)
+	for _, t := range crawl.Tools {
+		crawlCmd.AddCommand(toolCommand(t.Name, t.Short, t.Setup, crawl.Finish))
+	}
+}
+
+// toolCommand wraps a crawl or process tool in a subcommand: setup registers the tool's flags
+// and returns its run function, and finish (when set) runs after it
+func toolCommand(name, short string, setup func(*pflag.FlagSet) func(bool), finish func()) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: short,
+		Args:  cobra.NoArgs,
+	}
+	run := setup(cmd.Flags())
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		run(verbose)
+		if finish != nil {
+			finish()
+		}
+	}
+	return cmd
+}
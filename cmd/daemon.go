@@ -0,0 +1,566 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+// daemonSocketEnv overrides where the daemon listens and where search and rag look for it
+const daemonSocketEnv = "KIRK_AI_DAEMON_SOCKET"
+
+var (
+	daemonSocket     string
+	daemonEmbeddings string
+	daemonCollection string
+	noDaemon         bool
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep indexes loaded in the background for fast search and rag",
+	Long: `Run in the foreground and keep embeddings loaded between commands. While the daemon runs,
+search and rag hand their --embeddings or --collection to it over a unix socket instead of
+reading the embeddings and approximate index themselves, so each run skips the load and starts
+answering at once. The daemon also embeds their queries, keeping its connection to the server
+open.
+
+A corpus is loaded on first use (or at startup with --embeddings or --collection) and kept
+until the daemon exits. It is loaded again when its files change, for example after 'embed'
+or 'embeddings index'.
+
+The socket is ~/.kirk-ai/daemon.sock, or $` + daemonSocketEnv + `. Pass --no-daemon to search or
+rag to load the corpus in the process as usual. The daemon embeds queries with the server and
+--provider it was started with.`,
+	Args: cobra.NoArgs,
+	Run:  runDaemonCommand,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon runs and which corpora it holds",
+	Args:  cobra.NoArgs,
+	Run:   runDaemonStatusCommand,
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running daemon",
+	Args:  cobra.NoArgs,
+	Run:   runDaemonStopCommand,
+}
+
+// daemonTarget identifies a corpus held by the daemon by absolute paths, since the daemon
+// and its clients may run in different directories
+type daemonTarget struct {
+	File       string `json:"file,omitempty"`
+	Store      string `json:"store,omitempty"`
+	Collection string `json:"collection,omitempty"`
+}
+
+func (t daemonTarget) String() string {
+	if t.Collection != "" {
+		return t.Collection + " in " + t.Store
+	}
+	return t.File
+}
+
+// daemonOpenResponse describes a corpus the daemon has loaded
+type daemonOpenResponse struct {
+	Count       int                           `json:"count"`
+	Model       string                        `json:"model,omitempty"`
+	Dimension   int                           `json:"dimension"`
+	Calibration *vectorstore.Calibration      `json:"calibration,omitempty"`
+	Failed      map[vectorstore.ErrorKind]int `json:"failed,omitempty"`
+}
+
+// daemonSearchRequest is a vector or keyword search of a corpus held by the daemon
+type daemonSearchRequest struct {
+	Target    daemonTarget `json:"target"`
+	Embedding []float64    `json:"embedding,omitempty"`
+	Query     string       `json:"query,omitempty"` // keyword searches only
+	TopK      int          `json:"top_k"`
+	Threshold float64      `json:"threshold"`
+	Filter    []string     `json:"filter,omitempty"`
+	Exact     bool         `json:"exact,omitempty"`
+}
+
+// daemonSearchResponse holds the results of a daemon search
+type daemonSearchResponse struct {
+	Results []searchResult `json:"results"`
+}
+
+// daemonEmbedRequest asks the daemon to embed a query for searching a corpus
+type daemonEmbedRequest struct {
+	Target daemonTarget `json:"target"`
+	Query  string       `json:"query"`
+	Model  string       `json:"model,omitempty"` // --embed-model
+}
+
+// daemonEmbedResponse is the embedded query
+type daemonEmbedResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// daemonStatus is the reply of GET /v1/status
+type daemonStatus struct {
+	PID     int                 `json:"pid"`
+	Started time.Time           `json:"started"`
+	Corpora []daemonCorpusState `json:"corpora"`
+}
+
+// daemonCorpusState describes one corpus held by the daemon
+type daemonCorpusState struct {
+	Target   daemonTarget `json:"target"`
+	Chunks   int          `json:"chunks"`
+	LoadedAt time.Time    `json:"loaded_at"`
+	Queries  int          `json:"queries"`
+}
+
+// daemonServer holds loaded corpora and answers requests for them
+type daemonServer struct {
+	mu      sync.Mutex
+	corpora map[daemonTarget]*daemonEntry
+	started time.Time
+	stop    context.CancelFunc
+}
+
+// daemonEntry is a loaded corpus and the state of its files when it was loaded
+type daemonEntry struct {
+	// mu serializes keyword searches, which build the keyword index on first use
+	mu       sync.Mutex
+	corp     *corpus
+	stamp    string
+	loadedAt time.Time
+	queries  int
+}
+
+func runDaemonCommand(cmd *cobra.Command, args []string) {
+	if client := dialDaemon(daemonSocket); client != nil {
+		fmt.Printf("A daemon is already listening on %s\n", daemonSocket)
+		os.Exit(1)
+	}
+	// A socket left by a daemon that was killed refuses connections; replace it
+	if err := os.Remove(daemonSocket); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error removing stale socket: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(daemonSocket), 0o700); err != nil {
+		fmt.Printf("Error creating socket directory: %v\n", err)
+		os.Exit(1)
+	}
+	ln, err := net.Listen("unix", daemonSocket)
+	if err != nil {
+		fmt.Printf("Error listening on %s: %v\n", daemonSocket, err)
+		os.Exit(1)
+	}
+	// Only the owner may use the daemon, which reads any file it is asked to
+	if err := os.Chmod(daemonSocket, 0o600); err != nil {
+		ln.Close()
+		fmt.Printf("Error securing socket: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	d := &daemonServer{corpora: map[daemonTarget]*daemonEntry{}, started: time.Now(), stop: stop}
+	if daemonEmbeddings != "" || daemonCollection != "" {
+		target, err := corpusTarget(daemonEmbeddings, daemonCollection)
+		if err == nil {
+			_, err = d.open(newDaemonTarget(target))
+		}
+		if err != nil {
+			ln.Close()
+			fmt.Printf("Error loading embeddings: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/open", daemonPost(d.handleOpen))
+	mux.HandleFunc("/v1/search", daemonPost(d.handleSearch))
+	mux.HandleFunc("/v1/keyword", daemonPost(d.handleKeyword))
+	mux.HandleFunc("/v1/embed", daemonPost(d.handleEmbed))
+	mux.HandleFunc("/v1/status", d.handleStatus)
+	mux.HandleFunc("/v1/stop", daemonPost(d.handleStop))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Daemon listening on %s (pid %d)\n", daemonSocket, os.Getpid())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error serving: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Daemon stopped")
+}
+
+// open returns the loaded corpus for target, loading it on first use and again when its
+// files changed since
+func (d *daemonServer) open(target daemonTarget) (*daemonEntry, error) {
+	stamp := target.stamp()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.corpora[target]; ok && e.stamp == stamp {
+		return e, nil
+	}
+	start := time.Now()
+	corp, err := loadTarget(collectionTarget{file: target.File, store: target.Store, collection: target.Collection})
+	if err != nil {
+		return nil, err
+	}
+	e := &daemonEntry{corp: corp, stamp: stamp, loadedAt: time.Now()}
+	d.corpora[target] = e
+	fmt.Printf("Loaded %d embeddings from %s in %v\n", corp.Len(), target, time.Since(start).Round(time.Millisecond))
+	return e, nil
+}
+
+// count records a query against e
+func (d *daemonServer) count(e *daemonEntry) {
+	d.mu.Lock()
+	e.queries++
+	d.mu.Unlock()
+}
+
+func (d *daemonServer) handleOpen(w http.ResponseWriter, r *http.Request) {
+	var target daemonTarget
+	if !decodeRequest(w, r, &target) {
+		return
+	}
+	e, err := d.open(target)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	c := e.corp
+	writeJSON(w, http.StatusOK, daemonOpenResponse{Count: c.Len(), Model: c.model, Dimension: c.dimension, Calibration: c.calibration, Failed: c.failed})
+}
+
+func (d *daemonServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	req, e, filter, ok := d.searchRequest(w, r)
+	if !ok {
+		return
+	}
+	// Unlike keyword searches, these only read the corpus: the approximate index keeps its
+	// visit marks per search, so concurrent requests need not wait for each other
+	results, err := e.corp.searchWith(req.Embedding, req.TopK, req.Threshold, filter, !req.Exact)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, daemonSearchResponse{Results: results})
+}
+
+func (d *daemonServer) handleKeyword(w http.ResponseWriter, r *http.Request) {
+	req, e, filter, ok := d.searchRequest(w, r)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	results, err := e.corp.keywordSearchWith(req.Query, req.TopK, filter)
+	e.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, daemonSearchResponse{Results: results})
+}
+
+// searchRequest decodes a search and opens its corpus, answering the request itself when
+// either fails
+func (d *daemonServer) searchRequest(w http.ResponseWriter, r *http.Request) (daemonSearchRequest, *daemonEntry, vectorstore.Filter, bool) {
+	var req daemonSearchRequest
+	if !decodeRequest(w, r, &req) {
+		return req, nil, nil, false
+	}
+	filter, err := vectorstore.ParseFilter(req.Filter)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return req, nil, nil, false
+	}
+	e, err := d.open(req.Target)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return req, nil, nil, false
+	}
+	d.count(e)
+	return req, e, filter, true
+}
+
+func (d *daemonServer) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req daemonEmbedRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	e, err := d.open(req.Target)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	emb, err := e.corp.embedQuery(r.Context(), req.Query, req.Model)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, daemonEmbedResponse{Embedding: emb})
+}
+
+func (d *daemonServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	status := daemonStatus{PID: os.Getpid(), Started: d.started, Corpora: []daemonCorpusState{}}
+	for target, e := range d.corpora {
+		status.Corpora = append(status.Corpora, daemonCorpusState{Target: target, Chunks: e.corp.Len(), LoadedAt: e.loadedAt, Queries: e.queries})
+	}
+	d.mu.Unlock()
+	sort.Slice(status.Corpora, func(i, j int) bool { return status.Corpora[i].LoadedAt.Before(status.Corpora[j].LoadedAt) })
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (d *daemonServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]bool{"stopping": true})
+	d.stop()
+}
+
+// daemonPost wraps a daemon handler that accepts only POST requests
+func daemonPost(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// newDaemonTarget makes the paths of a resolved corpus absolute for the daemon
+func newDaemonTarget(t collectionTarget) daemonTarget {
+	abs := func(path string) string {
+		if path == "" {
+			return ""
+		}
+		if a, err := filepath.Abs(path); err == nil {
+			return a
+		}
+		return path
+	}
+	if t.collection != "" {
+		return daemonTarget{Store: abs(t.store), Collection: t.collection}
+	}
+	return daemonTarget{File: abs(t.file)}
+}
+
+// stamp summarizes the size and modification time of the files a corpus is loaded from,
+// so a corpus whose files changed is loaded again
+func (t daemonTarget) stamp() string {
+	var paths []string
+	if t.Collection != "" {
+		s := vectorstore.NewStore(t.Store)
+		paths = []string{s.ItemsPath(t.Collection), s.ANNPath(t.Collection), s.KeywordPath(t.Collection), s.CalibrationPath(t.Collection)}
+	} else {
+		paths = []string{t.File, vectorstore.ANNPath(t.File), vectorstore.KeywordPath(t.File), vectorstore.CalibrationPath(t.File)}
+	}
+	var b bytes.Buffer
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil {
+			fmt.Fprintf(&b, "%d:%d;", fi.Size(), fi.ModTime().UnixNano())
+		} else {
+			b.WriteString("-;")
+		}
+	}
+	return b.String()
+}
+
+// daemonCorpus is the client side of a corpus held by the daemon
+type daemonCorpus struct {
+	client *http.Client
+	target daemonTarget
+	count  int
+	exact  bool
+	filter []string // --filter expressions, parsed by the daemon
+}
+
+func (d *daemonCorpus) search(queryEmbedding []float64, topK int, threshold float64) ([]searchResult, error) {
+	var resp daemonSearchResponse
+	err := daemonCall(context.Background(), d.client, "/v1/search", daemonSearchRequest{
+		Target: d.target, Embedding: queryEmbedding, TopK: topK, Threshold: threshold, Filter: d.filter, Exact: d.exact,
+	}, &resp)
+	return resp.Results, err
+}
+
+func (d *daemonCorpus) keywordSearch(query string, topK int) ([]searchResult, error) {
+	var resp daemonSearchResponse
+	err := daemonCall(context.Background(), d.client, "/v1/keyword", daemonSearchRequest{
+		Target: d.target, Query: query, TopK: topK, Filter: d.filter,
+	}, &resp)
+	return resp.Results, err
+}
+
+func (d *daemonCorpus) embed(ctx context.Context, query, requested string) ([]float64, error) {
+	var resp daemonEmbedResponse
+	err := daemonCall(ctx, d.client, "/v1/embed", daemonEmbedRequest{Target: d.target, Query: query, Model: requested}, &resp)
+	return resp.Embedding, err
+}
+
+// loadSearchCorpus is loadCorpus for search and rag. When a daemon is running it holds the
+// corpus, so the embeddings are not read again on every run.
+func loadSearchCorpus(filename, collection string) (*corpus, error) {
+	target, err := corpusTarget(filename, collection)
+	if err != nil {
+		return nil, err
+	}
+	var client *http.Client
+	if !noDaemon && !target.remote() {
+		client = dialDaemon(daemonSocketPath())
+	}
+	if client == nil {
+		return loadTarget(target)
+	}
+	dt := newDaemonTarget(target)
+	var open daemonOpenResponse
+	if err := daemonCall(context.Background(), client, "/v1/open", dt, &open); err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Printf("Using the daemon at %s\n", daemonSocketPath())
+	}
+	c := &corpus{
+		model:       open.Model,
+		dimension:   open.Dimension,
+		calibration: open.Calibration,
+		failed:      open.Failed,
+		daemon:      &daemonCorpus{client: client, target: dt, count: open.Count, exact: exactSearch},
+	}
+	c.reportFailed()
+	return c, nil
+}
+
+// daemonSocketPath is where the daemon listens: $KIRK_AI_DAEMON_SOCKET, or daemon.sock in
+// the kirk-ai directory
+func daemonSocketPath() string {
+	if path := os.Getenv(daemonSocketEnv); path != "" {
+		return path
+	}
+	return config.Path("daemon.sock")
+}
+
+// dialDaemon returns a client for the daemon listening on socket, or nil when none answers
+func dialDaemon(socket string) *http.Client {
+	conn, err := net.DialTimeout("unix", socket, 200*time.Millisecond)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+// daemonCall sends a request to the daemon and decodes its reply into resp; a nil req
+// makes a GET request
+func daemonCall(ctx context.Context, client *http.Client, path string, req, resp interface{}) error {
+	method, body := http.MethodGet, []byte(nil)
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		method, body = http.MethodPost, b
+	}
+	r, err := http.NewRequestWithContext(ctx, method, "http://daemon"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(r)
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(res.Body).Decode(&e) != nil || e.Error == "" {
+			e.Error = res.Status
+		}
+		return errors.New(e.Error)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+func runDaemonStatusCommand(cmd *cobra.Command, args []string) {
+	client := dialDaemon(daemonSocket)
+	if client == nil {
+		fmt.Printf("No daemon is listening on %s\n", daemonSocket)
+		os.Exit(1)
+	}
+	var status daemonStatus
+	if err := daemonCall(context.Background(), client, "/v1/status", nil, &status); err != nil {
+		fmt.Printf("Error reading daemon status: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Daemon pid %d on %s, up %v\n", status.PID, daemonSocket, time.Since(status.Started).Round(time.Second))
+	if len(status.Corpora) == 0 {
+		fmt.Println("No corpora loaded yet")
+		return
+	}
+	for _, c := range status.Corpora {
+		fmt.Printf("  %s: %d chunks, loaded %s, %d queries\n", c.Target, c.Chunks, c.LoadedAt.Local().Format(time.DateTime), c.Queries)
+	}
+}
+
+func runDaemonStopCommand(cmd *cobra.Command, args []string) {
+	client := dialDaemon(daemonSocket)
+	if client == nil {
+		fmt.Printf("No daemon is listening on %s\n", daemonSocket)
+		return
+	}
+	var resp map[string]bool
+	if err := daemonCall(context.Background(), client, "/v1/stop", struct{}{}, &resp); err != nil {
+		fmt.Printf("Error stopping daemon: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Daemon stopping")
+}
+
+// addDaemonFlag registers --no-daemon on a command that can search through the daemon
+func addDaemonFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noDaemon, "no-daemon", false,
+		"Load the embeddings in this process even when a daemon is running")
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonStatusCmd, daemonStopCmd)
+
+	daemonCmd.PersistentFlags().StringVar(&daemonSocket, "socket", daemonSocketPath(),
+		"Unix socket the daemon listens on; search and rag look for it at $"+daemonSocketEnv+" or the default")
+	daemonCmd.Flags().StringVar(&daemonEmbeddings, "embeddings", "",
+		"Embeddings file to load at startup instead of on first use")
+	daemonCmd.Flags().StringVar(&daemonCollection, "collection", "",
+		"Collection to load at startup instead of on first use")
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/license"
+	"kirk-ai/internal/rag"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/cobra"
+)
+
+var (
+	docChunkTokens  int
+	docContextSize  int
+	docFetchTimeout time.Duration
+	docNoCache      bool
+)
+
+// docCmd groups one-off operations on a single document
+var docCmd = &cobra.Command{
+	Use:   "doc",
+	Short: "Work with a single document without building a corpus",
+	Long:  `Tools that fetch, chunk, and embed one document in memory instead of a persistent embeddings file.`,
+}
+
+var docAskCmd = &cobra.Command{
+	Use:   "ask <file-or-url> [question]",
+	Short: "Answer a question about one document, with citations",
+	Long: `Fetch a URL or read a local file (HTML, Markdown, or plain text), chunk it in memory, embed the
+chunks, and answer the question from the most relevant ones. Each answer cites the excerpts it
+used by number, and the sources list links back to their location in the document.
+
+Chunk embeddings are cached per document under ~/.kirk-ai/doc-cache, so asking again about an
+unchanged document only embeds the question.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  runDocAskCommand,
+}
+
+var markdownHeadingRE = regexp.MustCompile(`(?m)^(#{1,6})[ \t]+(.+?)[ \t#]*$`)
+
+func runDocAskCommand(cmd *cobra.Command, args []string) {
+	applyRedact()
+	source := args[0]
+	question := strings.Join(args[1:], " ")
+
+	page, err := loadDocument(source)
+	if err != nil {
+		fmt.Printf("Error loading document: %v\n", err)
+		os.Exit(1)
+	}
+	spans := chunker.ChunkSpans(page.Content, docChunkTokens)
+	if len(spans) == 0 {
+		fmt.Printf("No usable text found in %s\n", source)
+		os.Exit(1)
+	}
+
+	embeddingModel, err := resolveEmbeddingModel()
+	if err != nil {
+		fmt.Printf("Error selecting embedding model: %v\n", err)
+		os.Exit(1)
+	}
+
+	items, err := embedDocument(page, spans, embeddingModel)
+	if err != nil {
+		fmt.Printf("Error embedding document: %v\n", err)
+		os.Exit(1)
+	}
+
+	queryEmbedding, err := generateQueryEmbedding(question, embeddingModel, "")
+	if err != nil {
+		fmt.Printf("Error generating query embedding: %v\n", err)
+		os.Exit(1)
+	}
+	results := vectorstore.Search(queryEmbedding, items, docContextSize, -1)
+	context, used := rag.BuildCitedContext(results, rag.DefaultMaxContextLength)
+
+	if stream {
+		fmt.Println("Thinking...")
+	}
+	out, err := openTee()
+	if err != nil {
+		fmt.Printf("Error opening --tee file: %v\n", err)
+		os.Exit(1)
+	}
+	answer, err := generateRAGAnswer(rag.BuildCitedPrompt(question, context), out)
+	out.Write("\n")
+	if err == nil && ragAttribution {
+		out.Write("\nAttribution:\n" + rag.Attribution(used))
+	}
+	if teeErr := out.Close(); teeErr != nil {
+		fmt.Printf("Error writing --tee file: %v\n", teeErr)
+	}
+	if err != nil {
+		fmt.Printf("Error generating answer: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	if !stream {
+		fmt.Printf("Answer: %s\n", answer)
+	}
+	fmt.Println("\nSources:")
+	for i, r := range used {
+		fmt.Printf("  [%d] %s\n", i+1, citationLabel(r.Item))
+		if verbose {
+			fmt.Printf("      %s (similarity: %.3f)\n", truncateText(r.Item.Content, 100), r.Similarity)
+		}
+	}
+	if ragAttribution {
+		fmt.Printf("\nAttribution:\n%s", rag.Attribution(used))
+	}
+}
+
+// loadDocument fetches an http(s) URL or reads a local file. HTML is reduced to its main
+// text; Markdown and plain text are used as is, with Markdown headings as sections.
+func loadDocument(source string) (extract.Page, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		doc, _, err := extract.Fetch(context.Background(), &http.Client{Timeout: docFetchTimeout}, source)
+		if err != nil {
+			return extract.Page{}, err
+		}
+		return extract.FromDocumentWithOptions(source, doc, extract.Options{})[0], nil
+	}
+
+	// Local files are identified by absolute path so the embedding cache survives a cd
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+	b, err := os.ReadFile(source)
+	if err != nil {
+		return extract.Page{}, err
+	}
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".html", ".htm":
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(b)))
+		if err != nil {
+			return extract.Page{}, err
+		}
+		return extract.FromDocumentWithOptions(source, doc, extract.Options{})[0], nil
+	}
+	text := string(b)
+	page := extract.Page{URL: source, Title: filepath.Base(source), Content: text}
+	for _, m := range markdownHeadingRE.FindAllStringSubmatchIndex(text, -1) {
+		page.Sections = append(page.Sections, chunker.Section{
+			Heading: text[m[4]:m[5]],
+			Level:   m[3] - m[2],
+			Offset:  m[0],
+		})
+	}
+	return page, nil
+}
+
+// embedDocument embeds the document's chunks, reusing cached embeddings of chunks whose
+// content and model are unchanged
+func embedDocument(page extract.Page, spans []chunker.Span, embeddingModel string) ([]embeddingItem, error) {
+	sum := sha256.Sum256([]byte(page.URL))
+	cacheFile := config.Path("doc-cache", hex.EncodeToString(sum[:8])+".json")
+
+	cached := map[string][]float64{}
+	if !docNoCache {
+		if prev, err := vectorstore.ReadItems(cacheFile); err == nil {
+			for _, it := range prev {
+				if metadataString(it.Metadata, "embedding_model") == embeddingModel && len(it.Embedding) > 0 {
+					cached[metadataString(it.Metadata, "content_hash")] = it.Embedding
+				}
+			}
+		}
+	}
+
+	start := time.Now()
+	items := make([]embeddingItem, 0, len(spans))
+	embedded := 0
+	for i, sp := range spans {
+		hash := chunker.ContentHash(sp.Text)
+		metadata := chunker.SpanMetadata(page.Content, sp, page.Sections)
+		metadata["source_url"] = page.URL
+		metadata["title"] = page.Title
+		metadata["content_hash"] = hash
+		metadata["embedding_model"] = embeddingModel
+		if !page.License.IsZero() {
+			metadata[license.MetadataKey] = page.License.Map()
+		}
+
+		embedding, ok := cached[hash]
+		if !ok {
+			resp, err := llmClient.Embedding(embeddingModel, sp.Text)
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d: %w", i, err)
+			}
+			embedding = resp.Embedding
+			embedded++
+		}
+		items = append(items, embeddingItem{
+			ID:         fmt.Sprintf("%s#chunk_%d", page.URL, i),
+			ChunkIndex: i,
+			Content:    sp.Text,
+			Metadata:   metadata,
+			Embedding:  embedding,
+		})
+	}
+	if verbose {
+		fmt.Printf("Embedded %d of %d chunks with %s in %v (%d from cache)\n",
+			embedded, len(items), embeddingModel, time.Since(start), len(items)-embedded)
+	}
+
+	if !docNoCache && embedded > 0 {
+		if err := os.MkdirAll(filepath.Dir(cacheFile), 0o755); err != nil {
+			return nil, err
+		}
+		if err := vectorstore.WriteItems(cacheFile, items, false); err != nil && verbose {
+			fmt.Printf("Could not write embedding cache: %v\n", err)
+		}
+	}
+	return items, nil
+}
+
+// citationLabel describes where a cited chunk sits: its section, its position, and a deep
+// link for web pages or the character range for local files
+func citationLabel(it embeddingItem) string {
+	var parts []string
+	if section := metadataString(it.Metadata, "section"); section != "" {
+		parts = append(parts, fmt.Sprintf("%q", section))
+	}
+	if pos, ok := it.Metadata["position"].(float64); ok {
+		parts = append(parts, fmt.Sprintf("%.0f%% through", pos*100))
+	}
+	src := metadataString(it.Metadata, "source_url")
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		parts = append(parts, vectorstore.DeepLink(it))
+	} else if start, ok := it.Metadata["char_start"].(int); ok {
+		parts = append(parts, fmt.Sprintf("%s chars %d-%d", src, start, it.Metadata["char_end"]))
+	}
+	return strings.Join(parts, " — ")
+}
+
+func init() {
+	rootCmd.AddCommand(docCmd)
+	docCmd.AddCommand(docAskCmd)
+	requireServer(docAskCmd, "")
+
+	addTeeFlag(docAskCmd)
+	addRedactFlags(docAskCmd)
+	docAskCmd.Flags().IntVar(&docChunkTokens, "chunk-tokens", 200, "Approximate tokens per chunk")
+	docAskCmd.Flags().IntVar(&docContextSize, "context-size", 4, "Number of excerpts to answer from")
+	docAskCmd.Flags().DurationVar(&docFetchTimeout, "fetch-timeout", 20*time.Second, "Timeout for fetching a URL")
+	docAskCmd.Flags().BoolVar(&docNoCache, "no-cache", false, "Embed every chunk again instead of reusing cached embeddings")
+	docAskCmd.Flags().StringVar(&ragModel, "rag-model", "", "Chat model used to answer (auto-select if not specified)")
+	docAskCmd.Flags().BoolVar(&ragAttribution, "attribution", false, "Print the document's title, URL, copyright, and license after the answer")
+	docAskCmd.Flags().BoolVar(&ragPreferFast, "prefer-fast", false, "Prefer smaller/faster models (lower latency, possibly lower quality)")
+}
@@ -1,26 +1,50 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"kirk-ai/internal/adaptive"
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/client"
+	"kirk-ai/internal/lang"
+	"kirk-ai/internal/progress"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/secure"
+	"kirk-ai/internal/sink"
+	"kirk-ai/internal/vectorstore"
+
 	"github.com/spf13/cobra"
 )
 
 var (
 	// new flags
-	embedFile    string
-	embedChunk   int
-	embedAll     bool
-	embedOut     string
-	embedBatch   int     // number of chunks a worker will try to collect/process at once
-	embedConc    int     // number of concurrent workers
-	embedRateRps float64 // requests per second global rate limit
+	embedFile       string
+	embedChunk      int
+	embedAll        bool
+	embedOut        string
+	embedOutFormat  string
+	embedBatch      int     // number of chunks a worker will try to collect/process at once
+	embedConc       int     // number of concurrent workers
+	embedRateRps    float64 // requests per second global rate limit
+	embedAdaptive   bool    // adjust concurrency to the server's latency and errors
+	embedCollection string
+	embedShardSize  int
+	embedDryRun     bool
+	embedEstimate   bool
+	embedSamples    int
+	embedPrice      float64
+	embedCheckpoint string
+	embedResume     bool
+	embedFull       bool
+	embedLangs      []string
 )
 
 // Named types (single source of truth) so both the command and worker functions share the same types.
@@ -32,14 +56,7 @@ type crawledChunk struct {
 	TotalChunks int                    `json:"total_chunks"`
 }
 
-type outItem struct {
-	ID         string                 `json:"id"`
-	ChunkIndex int                    `json:"chunk_index"`
-	Content    string                 `json:"content,omitempty"`  // Store original content
-	Metadata   map[string]interface{} `json:"metadata,omitempty"` // Store metadata
-	Embedding  []float64              `json:"embedding,omitempty"`
-	Error      string                 `json:"error,omitempty"`
-}
+type outItem = vectorstore.Item
 
 // embedCmd represents the embed command
 var embedCmd = &cobra.Command{
@@ -60,7 +77,11 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 
 	// FILE PATH FLOW
 	if embedFile != "" {
-		b, err := os.ReadFile(embedFile)
+		if err := checkOutFormat(); err != nil {
+			fmt.Printf("Error in --out-format: %v\n", err)
+			os.Exit(1)
+		}
+		b, err := secure.ReadFile(embedFile)
 		if err != nil {
 			fmt.Printf("Error reading file '%s': %v\n", embedFile, err)
 			os.Exit(1)
@@ -96,21 +117,10 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 			fmt.Printf("Removed %d duplicate chunks, %d unique chunks remaining\n", duplicateCount, len(chunks))
 		}
 
-		// Model selection (reuse existing logic)
-		selectedModel := model
-		if selectedModel == "" {
-			models, err := ollamaClient.ListModels()
-			if err != nil {
-				fmt.Printf("Error getting models: %v\n", err)
-				os.Exit(1)
-			}
-			if len(models) == 0 {
-				fmt.Println("No models found. Please install a model first using 'ollama pull <model-name>'")
-				os.Exit(1)
-			}
-			selectedModel = ollamaClient.SelectEmbeddingModel(models)
-			if selectedModel == "" {
-				fmt.Println("No suitable embedding model found")
+		if len(embedLangs) > 0 {
+			chunks = chunksInLanguages(chunks, embedLangs)
+			if len(chunks) == 0 {
+				fmt.Printf("No chunks in %s found in file\n", strings.Join(embedLangs, ", "))
 				os.Exit(1)
 			}
 		}
@@ -137,18 +147,110 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 			toEmbed = append(toEmbed, chunks[0])
 		}
 
+		selected := toEmbed
+
+		// Chunks a previous run already embedded come from its checkpoint instead of the model
+		checkpointPath := embedCheckpoint
+		if checkpointPath == "" {
+			checkpointPath = embedFile + ".progress.jsonl"
+		}
+		var resumed []outItem
+		if embedResume {
+			resumed, toEmbed, err = resumeFromCheckpoint(checkpointPath, toEmbed)
+			if err != nil {
+				fmt.Printf("Error reading checkpoint '%s': %v\n", checkpointPath, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Resuming from %s: %d chunks already embedded, %d to go\n", checkpointPath, len(resumed), len(toEmbed))
+		}
+
+		// Chunks whose content an earlier run already embedded into the outputs keep their vectors
+		var reused, carried []outItem
+		if !embedFull {
+			prev, err := loadPreviousEmbeddings()
+			if err != nil {
+				fmt.Printf("Error reading existing embeddings: %v (use --full to embed everything again)\n", err)
+				os.Exit(1)
+			}
+			if prev != nil {
+				if model != "" && prev.model != "" && !sameModel(model, prev.model) {
+					fmt.Printf("%s was embedded with %s, not %s; use --full to embed everything again\n", prev.source, prev.model, model)
+					os.Exit(1)
+				}
+				if model == "" {
+					model = prev.model
+				}
+				reused, toEmbed = prev.reuseUnchanged(toEmbed)
+				carried = prev.untouched(selected)
+				fmt.Printf("Reusing %d unchanged chunks from %s; %d new or changed to embed\n", len(reused), prev.source, len(toEmbed))
+			}
+		}
+
+		if embedDryRun {
+			printEmbedPlan(len(chunks), duplicateCount, toEmbed, embedRate(cmd))
+			return
+		}
+
+		if embedEstimate {
+			if len(toEmbed) == 0 {
+				fmt.Println("Nothing left to embed")
+				return
+			}
+			selectedModel, err := resolveEmbeddingModel()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := printEmbedEstimate(toEmbed, selectedModel, embedWorkers(cmd), embedRate(cmd)); err != nil {
+				fmt.Printf("Error estimating embed run: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		// Model selection (reuse existing logic)
+		selectedModel := model
+		if selectedModel == "" {
+			models, err := llmClient.ListModels()
+			if err != nil {
+				fmt.Printf("Error getting models: %v\n", err)
+				os.Exit(1)
+			}
+			if len(models) == 0 {
+				fmt.Println("No models found. Please install a model first using 'ollama pull <model-name>'")
+				os.Exit(1)
+			}
+			if selectedModel, err = selectEmbeddingModel(models); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if m := recordedModel(resumed); m != "" && !sameModel(m, selectedModel) {
+			fmt.Printf("Checkpoint %s was embedded with %s, not %s; rerun without --resume to start over\n", checkpointPath, m, selectedModel)
+			os.Exit(1)
+		}
+
 		// Prepare concurrency / rate limiting / batching
 		if embedBatch <= 0 {
 			embedBatch = 1
 		}
-		if embedConc <= 0 {
-			embedConc = 4
+		workers := embedWorkers(cmd)
+		var limiter *adaptive.Limiter
+		if embedAdaptive {
+			limiter = adaptive.New(1, workers)
+			if verbose {
+				limiter.OnChange = func(limit int, reason string) {
+					fmt.Printf("Concurrency now %d (%s)\n", limit, reason)
+				}
+			}
 		}
-		rateEnabled := embedRateRps > 0.0
+		rate := embedRate(cmd)
+		rateEnabled := rate > 0.0
 		var rateTicker *time.Ticker
 		var rateCh <-chan time.Time
 		if rateEnabled {
-			interval := time.Duration(float64(time.Second) / embedRateRps)
+			interval := time.Duration(float64(time.Second) / rate)
 			if interval <= 0 {
 				interval = time.Millisecond // fallback minimal interval
 			}
@@ -157,9 +259,79 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 			defer rateTicker.Stop()
 		}
 
-		// Output collection
-		var outMu sync.Mutex
-		var out []outItem
+		// Output collection: one sink shared by every worker, feeding the file and/or collection
+		var backends []sink.Backend[outItem]
+		var itemsOut *sink.Items
+		var jsonlOut *sink.JSONL[outItem]
+		var storeOut *sink.Store
+		var qdrantOuts []*vectorstore.Qdrant
+		if vectorstore.IsQdrant(embedOut) {
+			q, err := openQdrant(embedOut, "")
+			if err != nil {
+				fmt.Printf("Error in --out: %v\n", err)
+				os.Exit(1)
+			}
+			qdrantOuts = append(qdrantOuts, q)
+			backends = append(backends, sink.NewQdrant(q, selectedModel))
+		} else if embedOut != "" && embedOutFormat == "jsonl" {
+			jsonlOut = sink.NewJSONL[outItem](embedOut)
+			backends = append(backends, jsonlOut)
+		} else if embedOut != "" {
+			itemsOut = sink.NewItems(embedOut, embedShardSize, selectedModel, encryptOutput)
+			backends = append(backends, itemsOut)
+		}
+		if embedCollection != "" {
+			target, err := writableCollection(embedCollection)
+			if err != nil {
+				fmt.Printf("Error in --collection: %v\n", err)
+				os.Exit(1)
+			}
+			if vectorstore.IsQdrant(target.store) {
+				q, err := openQdrant(target.store, target.collection)
+				if err != nil {
+					fmt.Printf("Error in --store: %v\n", err)
+					os.Exit(1)
+				}
+				qdrantOuts = append(qdrantOuts, q)
+				backends = append(backends, sink.NewQdrant(q, selectedModel))
+			} else {
+				storeOut = sink.NewStore(vectorstore.NewStore(target.store), target.collection, selectedModel, encryptOutput)
+				backends = append(backends, storeOut)
+			}
+		}
+		// The checkpoint goes last so it is only removed once every output has committed
+		var checkpoint *sink.Checkpoint
+		if len(backends) > 0 {
+			if !embedResume {
+				if _, err := os.Stat(checkpointPath); err == nil {
+					fmt.Printf("Starting over: discarding the progress in %s (use --resume to continue it)\n", checkpointPath)
+				}
+			}
+			checkpoint, err = sink.NewCheckpoint(checkpointPath, resumed, encryptOutput)
+			if err != nil {
+				fmt.Printf("Error creating checkpoint: %v\n", err)
+				os.Exit(1)
+			}
+			backends = append(backends, checkpoint)
+		} else if embedResume {
+			fmt.Println("--resume needs --out or --collection to write the embeddings to")
+			os.Exit(1)
+		}
+		// Items of the existing file the run leaves alone are carried over as they were
+		if len(carried) > 0 {
+			if itemsOut != nil {
+				err = itemsOut.Write(carried)
+			} else if jsonlOut != nil {
+				err = jsonlOut.Write(carried)
+			}
+			if err != nil {
+				fmt.Printf("Error writing embeddings: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		out := sink.New(sink.Options{BufferSize: embedBatch}, backends...)
+		out.Add(resumed...)
+		out.Add(reused...)
 
 		// Jobs channel
 		jobs := make(chan crawledChunk, len(toEmbed))
@@ -169,11 +341,17 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 		close(jobs)
 
 		var wg sync.WaitGroup
-		wg.Add(embedConc)
+		failed := &failureTally{}
+		wg.Add(workers)
 
 		// Shared progress counter (use atomic to avoid data race)
 		var processed int64
 		total := len(toEmbed)
+		// A single chunk prints its vector and --verbose each batch; other runs show a progress line
+		var bar *progress.Bar
+		if !verbose && total > 1 {
+			bar = progress.New(os.Stdout, "Embedding chunks", total)
+		}
 
 		// Worker function - simplified to avoid duplicate processing
 		worker := func(id int) {
@@ -188,7 +366,7 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 					if !ok {
 						// Channel closed - process any remaining batch and exit
 						if len(batch) > 0 {
-							processBatch(batch, selectedModel, rateCh, rateEnabled, &outMu, &out)
+							processBatch(batch, selectedModel, rateCh, rateEnabled, limiter, out, failed, bar)
 							atomic.AddInt64(&processed, int64(len(batch)))
 							if verbose {
 								cur := atomic.LoadInt64(&processed)
@@ -201,7 +379,7 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 				}
 
 				// Process the collected batch
-				processBatch(batch, selectedModel, rateCh, rateEnabled, &outMu, &out)
+				processBatch(batch, selectedModel, rateCh, rateEnabled, limiter, out, failed, bar)
 
 				// Progress reporting
 				atomic.AddInt64(&processed, int64(len(batch)))
@@ -213,22 +391,40 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 		}
 
 		// start workers
-		for i := 0; i < embedConc; i++ {
+		for i := 0; i < workers; i++ {
 			go worker(i)
 		}
 
 		// wait for all workers to finish
 		wg.Wait()
+		if bar != nil {
+			fmt.Println(bar.Finish())
+		}
+		if limiter != nil {
+			fmt.Printf("Adaptive concurrency: ended at %d, peaked at %d of up to %d\n", limiter.Limit(), limiter.Peak(), workers)
+		}
 
-		// Optionally write full embeddings to a JSON file
-		if embedOut != "" {
-			ob, _ := json.MarshalIndent(out, "", "  ")
-			if err := os.WriteFile(embedOut, ob, 0644); err != nil {
-				fmt.Printf("Error writing output to '%s': %v\n", embedOut, err)
-				os.Exit(1)
-			}
+		if err := out.Close(); err != nil {
+			fmt.Printf("Error writing embeddings: %v\n", err)
+			os.Exit(1)
+		}
+		if itemsOut != nil && itemsOut.Manifest != nil {
+			fmt.Printf("Embeddings written to %d shards (manifest %s)\n", len(itemsOut.Manifest.Shards), embedOut)
+		} else if itemsOut != nil || jsonlOut != nil {
 			fmt.Printf("Embeddings written to %s\n", embedOut)
 		}
+		if storeOut != nil {
+			fmt.Printf("Collection %s updated (%d chunks, model %s)\n", storeOut.Info.Name, storeOut.Info.Count, storeOut.Info.EmbeddingModel)
+		}
+		for _, q := range qdrantOuts {
+			fmt.Printf("Qdrant collection %s at %s updated (model %s)\n", q.Collection, q.URL, selectedModel)
+		}
+		if n := failed.total(); n > 0 {
+			fmt.Printf("%d chunks failed to embed (%s); they are kept without embeddings and left out of search\n", n, vectorstore.FormatErrorCounts(failed.counts))
+		}
+		if checkpoint != nil && checkpoint.Failed > 0 {
+			fmt.Printf("%d chunks failed; progress kept in %s, rerun with --resume to retry them\n", checkpoint.Failed, checkpointPath)
+		}
 		return
 	}
 
@@ -238,7 +434,7 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 	selectedModel := model
 	if selectedModel == "" {
 		// Auto-select an embedding model
-		models, err := ollamaClient.ListModels()
+		models, err := llmClient.ListModels()
 		if err != nil {
 			fmt.Printf("Error getting models: %v\n", err)
 			os.Exit(1)
@@ -247,9 +443,8 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 			fmt.Println("No models found. Please install a model first using 'ollama pull <model-name>'")
 			os.Exit(1)
 		}
-		selectedModel = ollamaClient.SelectEmbeddingModel(models)
-		if selectedModel == "" {
-			fmt.Println("No suitable embedding model found")
+		if selectedModel, err = selectEmbeddingModel(models); err != nil {
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 	}
@@ -260,7 +455,7 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 		fmt.Println("---")
 	}
 
-	response, err := ollamaClient.Embedding(selectedModel, text)
+	response, err := llmClient.Embedding(selectedModel, text)
 	if err != nil {
 		fmt.Printf("Error generating embeddings: %v\n", err)
 		os.Exit(1)
@@ -281,72 +476,393 @@ func runEmbedCommand(cmd *cobra.Command, args []string) {
 	fmt.Println("]")
 }
 
-// processBatch processes provided chunks sequentially, respecting the provided rate channel.
-// rateCh is nil when rate limiting is disabled.
-func processBatch(batch []crawledChunk, selectedModel string, rateCh <-chan time.Time, rateEnabled bool, outMu *sync.Mutex, out *[]outItem) {
-	for _, c := range batch {
-		// wait for rate token if enabled
-		if rateEnabled {
-			<-rateCh
-		}
+// batchContext marks bulk embedding requests so that interactive requests sharing the client,
+// such as a library host's RAG queries, are sent ahead of them
+func batchContext() context.Context {
+	return client.WithPriority(context.Background(), client.PriorityBatch)
+}
 
+// failureTally counts the chunks of a run that failed to embed, by kind
+type failureTally struct {
+	mu     sync.Mutex
+	counts map[vectorstore.ErrorKind]int
+}
+
+func (t *failureTally) add(kind vectorstore.ErrorKind) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts == nil {
+		t.counts = map[vectorstore.ErrorKind]int{}
+	}
+	t.counts[kind]++
+}
+
+func (t *failureTally) total() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, c := range t.counts {
+		n += c
+	}
+	return n
+}
+
+// processBatch embeds the chunks in one request, waiting for a token from the rate channel
+// and a slot from the adaptive limiter, when there is one, first. When the batched request
+// fails, each chunk is retried on its own so one bad chunk does not fail the rest.
+func processBatch(batch []crawledChunk, selectedModel string, rateCh <-chan time.Time, rateEnabled bool, limiter *adaptive.Limiter, out *sink.Sink[outItem], failed *failureTally, bar *progress.Bar) {
+	// wait for rate token if enabled
+	if rateEnabled {
+		<-rateCh
+	}
+	var slot *adaptive.Request
+	if limiter != nil {
+		slot = limiter.Acquire()
+	}
+
+	if verbose {
+		fmt.Printf("Embedding %d chunks (ids %s..%s)...\n", len(batch), batch[0].ID, batch[len(batch)-1].ID)
+	}
+	texts := make([]string, len(batch))
+	for i, c := range batch {
+		texts[i] = c.Content
+	}
+	embeddings, err := llmClient.EmbeddingBatchContext(batchContext(), selectedModel, texts)
+	if slot != nil {
+		slot.Done(len(batch), err != nil && overloaded(err))
+	}
+	if err != nil && len(batch) > 1 {
 		if verbose {
-			fmt.Printf("Embedding chunk %d (id=%s)...\n", c.ChunkIndex, c.ID)
+			fmt.Printf("Batch of %d chunks failed (%v); embedding them one at a time\n", len(batch), err)
 		}
-		resp, err := ollamaClient.Embedding(selectedModel, c.Content)
-		outMu.Lock()
-		if err != nil {
-			fmt.Printf("Error embedding chunk %d: %v\n", c.ChunkIndex, err)
-			*out = append(*out, outItem{
-				ID:         c.ID,
-				ChunkIndex: c.ChunkIndex,
-				Content:    c.Content,  // Store content even on error
-				Metadata:   c.Metadata, // Store metadata even on error
-				Error:      err.Error(),
-			})
-			outMu.Unlock()
+		for _, c := range batch {
+			processBatch([]crawledChunk{c}, selectedModel, rateCh, rateEnabled, limiter, out, failed, bar)
+		}
+		return
+	}
+	if err != nil {
+		c := batch[0]
+		itemErr := vectorstore.NewItemError(err)
+		// Reported above the progress line when one is shown
+		var w io.Writer = os.Stdout
+		if bar != nil {
+			w = bar
+			bar.Fail(1)
+		}
+		fmt.Fprintf(w, "Error embedding chunk %d (%s): %v\n", c.ChunkIndex, itemErr.Kind, err)
+		failed.add(itemErr.Kind)
+		out.Add(outItem{
+			ID:         c.ID,
+			ChunkIndex: c.ChunkIndex,
+			Content:    c.Content,  // Store content even on error
+			Metadata:   c.Metadata, // Store metadata even on error
+			Error:      itemErr,
+		})
+		return
+	}
+
+	for i, c := range batch {
+		embedding := embeddings[i]
+		metadata := stampEmbedding(c.Metadata, selectedModel) // Store metadata for additional context
+		metadata["content_hash"] = chunker.ContentHash(c.Content)
+		out.Add(outItem{
+			ID:         c.ID,
+			ChunkIndex: c.ChunkIndex,
+			Content:    c.Content, // Store content for search/RAG
+			Metadata:   metadata,
+			Embedding:  embedding,
+		})
+		if bar != nil {
+			bar.Add(1)
 			continue
 		}
-		// Print a concise representation to stdout
-		fmt.Printf("Chunk %d (id=%s) embedding dimension=%d\n", c.ChunkIndex, c.ID, len(resp.Embedding))
+
+		// Print a concise representation to stdout in one write so workers don't interleave
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Chunk %d (id=%s) embedding dimension=%d\n[", c.ChunkIndex, c.ID, len(embedding))
 		previewN := 8
-		if len(resp.Embedding) < previewN {
-			previewN = len(resp.Embedding)
+		if len(embedding) < previewN {
+			previewN = len(embedding)
 		}
-		fmt.Print("[")
 		for i := 0; i < previewN; i++ {
 			if i > 0 {
-				fmt.Print(", ")
+				sb.WriteString(", ")
 			}
-			fmt.Printf("%.6f", resp.Embedding[i])
+			fmt.Fprintf(&sb, "%.6f", embedding[i])
 		}
-		if previewN < len(resp.Embedding) {
-			fmt.Print(", ...")
+		if previewN < len(embedding) {
+			sb.WriteString(", ...")
 		}
-		fmt.Println("]")
+		fmt.Println(sb.String() + "]")
+	}
+}
 
-		*out = append(*out, outItem{
-			ID:         c.ID,
-			ChunkIndex: c.ChunkIndex,
-			Content:    c.Content,  // Store content for search/RAG
-			Metadata:   c.Metadata, // Store metadata for additional context
-			Embedding:  resp.Embedding,
-		})
-		outMu.Unlock()
+// overloaded reports whether an embedding error means the server is past capacity, as
+// opposed to a problem with the chunks themselves
+func overloaded(err error) bool {
+	switch vectorstore.NewItemError(err).Kind {
+	case vectorstore.KindTooLong, vectorstore.KindNoContent:
+		return false
+	}
+	return true
+}
+
+// adaptiveMaxConcurrency is the most workers --adaptive runs when --concurrency is not given
+const adaptiveMaxConcurrency = 16
+
+// embedWorkers returns the number of workers to start: --concurrency, which --adaptive
+// treats as a ceiling and raises to adaptiveMaxConcurrency when it is not given
+func embedWorkers(cmd *cobra.Command) int {
+	if embedAdaptive && !cmd.Flags().Changed("concurrency") {
+		return adaptiveMaxConcurrency
+	}
+	if embedConc <= 0 {
+		return 4
+	}
+	return embedConc
+}
+
+// embedRate returns the requests-per-second limit in effect: --rate, which --adaptive
+// applies only when it is given explicitly
+func embedRate(cmd *cobra.Command) float64 {
+	if embedAdaptive && !cmd.Flags().Changed("rate") {
+		return 0
+	}
+	return embedRateRps
+}
+
+// resumeFromCheckpoint reads the items a previous run recorded at path and returns those
+// among toEmbed, with the chunks still left to embed. A missing checkpoint resumes nothing.
+func resumeFromCheckpoint(path string, toEmbed []crawledChunk) (resumed []outItem, remaining []crawledChunk, err error) {
+	items, err := sink.ReadCheckpoint(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	wanted := make(map[string]bool, len(toEmbed))
+	for _, c := range toEmbed {
+		wanted[c.ID] = true
+	}
+	done := make(map[string]bool, len(items))
+	for _, it := range items {
+		if wanted[it.ID] && !done[it.ID] {
+			done[it.ID] = true
+			resumed = append(resumed, it)
+		}
+	}
+	remaining = make([]crawledChunk, 0, len(toEmbed)-len(resumed))
+	for _, c := range toEmbed {
+		if !done[c.ID] {
+			remaining = append(remaining, c)
+		}
+	}
+	return resumed, remaining, nil
+}
+
+// checkOutFormat validates --out-format against the other output flags. jsonl streams each
+// batch to the file as it completes, so it can't be sharded or encrypted as a whole.
+func checkOutFormat() error {
+	switch embedOutFormat {
+	case "json":
+		return nil
+	case "jsonl":
+	default:
+		return fmt.Errorf("unknown format %q (use json or jsonl)", embedOutFormat)
+	}
+	switch {
+	case embedOut == "" || vectorstore.IsQdrant(embedOut):
+		return fmt.Errorf("jsonl needs a file --out")
+	case embedShardSize > 0:
+		return fmt.Errorf("jsonl output can't be combined with --shard-size")
+	case encryptOutput:
+		return fmt.Errorf("jsonl output can't be combined with --encrypt")
+	}
+	return nil
+}
+
+// printEmbedPlan reports what an embed run would do without contacting Ollama or writing output
+func printEmbedPlan(total, duplicates int, toEmbed []crawledChunk, rate float64) {
+	fmt.Println("Dry run: no embeddings will be generated or written")
+	fmt.Printf("Chunks in file: %d (%d duplicates removed)\n", total+duplicates, duplicates)
+	batchSize := embedBatch
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	requests := (len(toEmbed) + batchSize - 1) / batchSize
+	fmt.Printf("Would embed: %d chunks (%d embedding requests of up to %d chunks)\n", len(toEmbed), requests, batchSize)
+	for i, c := range toEmbed {
+		if i == 5 {
+			fmt.Printf("  ... %d more\n", len(toEmbed)-i)
+			break
+		}
+		fmt.Printf("  %s\n", c.ID)
+	}
+	if model != "" {
+		fmt.Printf("Model: %s\n", model)
+	} else {
+		fmt.Println("Model: auto-selected at run time")
+	}
+	if rate > 0 {
+		fmt.Printf("Minimum duration at --rate %.1f/s: ~%v\n", rate,
+			time.Duration(float64(requests)/rate*float64(time.Second)).Round(time.Second))
+	}
+	switch {
+	case embedOut != "" && embedShardSize > 0:
+		fmt.Printf("Would write: %d shards with manifest %s\n", (len(toEmbed)+embedShardSize-1)/embedShardSize, embedOut)
+	case embedOut != "" && embedOutFormat == "jsonl":
+		fmt.Printf("Would stream: %s (one JSON line per chunk)\n", embedOut)
+	case embedOut != "":
+		fmt.Printf("Would write: %s\n", embedOut)
+	}
+	if embedCollection != "" {
+		if target, err := writableCollection(embedCollection); err != nil {
+			fmt.Printf("Would fail: %v\n", err)
+		} else {
+			fmt.Printf("Would update collection: %s in %s\n", target.collection, target.store)
+		}
+	}
+}
+
+// printEmbedEstimate counts the chunks and tokens to embed, times a few sample embedding calls,
+// and projects the duration (and cost, when --price-per-mtok is set) of the full run with
+// conc workers and a rate limit of rate requests per second (0 for none)
+func printEmbedEstimate(toEmbed []crawledChunk, selectedModel string, conc int, rate float64) error {
+	tokens := 0
+	for _, c := range toEmbed {
+		tokens += chunker.EstimateTokens(c.Content)
+	}
+	fmt.Printf("Model: %s\n", selectedModel)
+	fmt.Printf("Chunks to embed: %d (~%d tokens, ~%d per chunk)\n", len(toEmbed), tokens, tokens/len(toEmbed))
+
+	// Sample batches spread evenly across the run so size differences are represented
+	batchSize := embedBatch
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if batchSize > len(toEmbed) {
+		batchSize = len(toEmbed)
+	}
+	requests := (len(toEmbed) + batchSize - 1) / batchSize
+	n := embedSamples
+	if n <= 0 {
+		n = 1
+	}
+	if n > requests {
+		n = requests
+	}
+	var elapsed time.Duration
+	sampledTokens := 0
+	for i := 0; i < n; i++ {
+		first := i * (len(toEmbed) - batchSize) / n
+		batch := toEmbed[first : first+batchSize]
+		texts := make([]string, len(batch))
+		for j, c := range batch {
+			texts[j] = c.Content
+			sampledTokens += chunker.EstimateTokens(c.Content)
+		}
+		start := time.Now()
+		if _, err := llmClient.EmbeddingBatchContext(batchContext(), selectedModel, texts); err != nil {
+			return fmt.Errorf("sample embedding of chunks %d-%d: %w", batch[0].ChunkIndex, batch[len(batch)-1].ChunkIndex, err)
+		}
+		took := time.Since(start)
+		elapsed += took
+		if verbose {
+			fmt.Printf("Sample batch of %d from chunk %d (id=%s): %v\n", len(batch), batch[0].ChunkIndex, batch[0].ID, took.Round(time.Millisecond))
+		}
+	}
+	perRequest := elapsed / time.Duration(n)
+	fmt.Printf("Sampled %d requests of %d chunks: %v per request, ~%.0f tokens/s\n", n, batchSize, perRequest.Round(time.Millisecond),
+		float64(sampledTokens)/elapsed.Seconds())
+
+	// Workers run in parallel, but the global rate limit caps throughput regardless of concurrency.
+	// An --adaptive run is projected at its ceiling, which it reaches only if the server keeps up.
+	rps := float64(conc) / perRequest.Seconds()
+	limit := fmt.Sprintf("--concurrency %d", conc)
+	if embedAdaptive {
+		limit = fmt.Sprintf("--adaptive, at most %d workers", conc)
+	}
+	if rate > 0 && rate < rps {
+		rps = rate
+		limit = fmt.Sprintf("--rate %.1f/s", rate)
+	}
+	projected := time.Duration(float64(requests) / rps * float64(time.Second))
+	fmt.Printf("Projected time: ~%v (%d requests at %.1f/s, limited by %s)\n", projected.Round(time.Second), requests, rps, limit)
+
+	if embedPrice > 0 {
+		fmt.Printf("Projected cost: ~$%.4f at $%.4f per 1M tokens\n", float64(tokens)/1e6*embedPrice, embedPrice)
+	} else {
+		fmt.Println("Projected cost: none (local model; set --price-per-mtok for a paid provider)")
+	}
+	return nil
+}
+
+// stampEmbedding records the embedding model in the chunk's provenance, creating metadata if needed
+func stampEmbedding(metadata map[string]interface{}, model string) map[string]interface{} {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	provenance.Update(metadata, provenance.Record{EmbeddingModel: model, EmbeddedAt: provenance.Now()})
+	return metadata
+}
+
+// chunksInLanguages keeps the chunks whose metadata lang is one of langs; chunks from before
+// languages were recorded count as undetermined
+func chunksInLanguages(chunks []crawledChunk, langs []string) []crawledChunk {
+	want := make(map[string]bool, len(langs))
+	for _, l := range langs {
+		want[lang.Code(l)] = true
+	}
+	var kept []crawledChunk
+	for _, c := range chunks {
+		l := metadataString(c.Metadata, "lang")
+		if l == "" {
+			l = lang.Undetermined
+		}
+		if want[l] {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// resolveEmbeddingModel returns the --model flag or auto-selects an installed embedding model
+func resolveEmbeddingModel() (string, error) {
+	if model != "" {
+		return model, nil
+	}
+	models, err := llmClient.ListModels()
+	if err != nil {
+		return "", fmt.Errorf("error getting models: %w", err)
+	}
+	if len(models) == 0 {
+		return "", fmt.Errorf("no models found. Please install a model first using 'ollama pull <model-name>'")
 	}
+	return selectEmbeddingModel(models)
 }
 
 func init() {
 	rootCmd.AddCommand(embedCmd)
+	requireServer(embedCmd, "dry-run")
 
 	// Register new flags
 	embedCmd.Flags().StringVar(&embedFile, "file", "", "Path to embeddings-ready JSON file (e.g. tpusa_crawl/embeddings/tpusa_embeddings_ready.json)")
 	embedCmd.Flags().BoolVar(&embedAll, "all", false, "Embed all chunks contained in --file")
 	embedCmd.Flags().IntVar(&embedChunk, "chunk", -1, "Embed a specific chunk index from --file (0-based)")
-	embedCmd.Flags().StringVar(&embedOut, "out", "", "Optional path to write embeddings JSON output")
+	embedCmd.Flags().StringVar(&embedOut, "out", "", "Optional path to write embeddings JSON output, or qdrant://[host:port/]collection")
+	embedCmd.Flags().StringVar(&embedOutFormat, "out-format", "json", "Format of a file --out: json (written once at the end) or jsonl (each chunk appended as its batch completes, for runs too large to hold in memory)")
+	embedCmd.Flags().IntVar(&embedShardSize, "shard-size", 0, "Split --out into numbered shard files of this many chunks plus a manifest at --out (0 = single file)")
+	embedCmd.Flags().StringVar(&embedCollection, "collection", "", "Optional collection to add the embeddings to: a name registered with 'collections create', or a collection in --store")
+	embedCmd.Flags().BoolVar(&embedDryRun, "dry-run", false, "With --file, print what would be embedded and written without calling Ollama")
+	embedCmd.Flags().BoolVar(&embedEstimate, "estimate", false, "With --file, time a few sample embedding calls and print the projected duration and cost of the run")
+	embedCmd.Flags().IntVar(&embedSamples, "estimate-samples", 3, "Number of sample embedding calls made by --estimate")
+	embedCmd.Flags().Float64Var(&embedPrice, "price-per-mtok", 0, "Embedding price in USD per million tokens for --estimate cost projection (0 = local, free)")
+	embedCmd.Flags().StringVar(&embedCheckpoint, "checkpoint", "", "Progress file recording each embedded chunk as the run goes (default <file>.progress.jsonl); removed when the run completes")
+	embedCmd.Flags().BoolVar(&embedResume, "resume", false, "Continue an interrupted run: reuse the chunks in the checkpoint and embed only the rest")
+	embedCmd.Flags().StringSliceVar(&embedLangs, "lang", nil, "With --file, embed only chunks in these languages (ISO 639-1 codes such as es,pt), so each language can go to its own --model and --collection")
+	embedCmd.Flags().BoolVar(&embedFull, "full", false, "Embed every chunk again instead of reusing the vectors of unchanged chunks already in --out or --collection, and replace a file --out")
 
 	// Batching / rate limiting flags
-	embedCmd.Flags().IntVar(&embedBatch, "batch-size", 10, "Number of chunks a worker will collect and process at once (internal batching)")
+	embedCmd.Flags().IntVar(&embedBatch, "batch-size", 10, "Number of chunks sent to the embedding model in one request")
 	embedCmd.Flags().IntVar(&embedConc, "concurrency", 4, "Number of concurrent workers embedding chunks")
 	embedCmd.Flags().Float64Var(&embedRateRps, "rate", 5.0, "Global embedding requests per second (set to 0 to disable rate limiting)")
+	embedCmd.Flags().BoolVar(&embedAdaptive, "adaptive", false, "Adjust concurrency to the server: start with one request in flight, add one while latency holds, and halve on timeouts, 429s, server errors, or rising latency. --concurrency becomes the ceiling (default 16) and --rate applies only when given")
 }
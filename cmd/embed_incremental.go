@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/vectorstore"
+)
+
+// previousEmbeddings are the chunks an earlier embed run already wrote to this run's outputs
+type previousEmbeddings struct {
+	// base holds the items of an existing file --out, which the run merges its chunks into
+	base   []outItem
+	model  string             // embedding model the previous chunks were embedded with ("" when unknown)
+	byHash map[string]outItem // embedded chunks by content hash
+	source string             // where they were read from, for messages
+}
+
+// loadPreviousEmbeddings reads what earlier runs wrote to a file --out and a local --collection,
+// so chunks whose content is unchanged can keep their vectors. Qdrant outputs are not read,
+// and a missing output is simply a first run.
+func loadPreviousEmbeddings() (*previousEmbeddings, error) {
+	prev := &previousEmbeddings{byHash: map[string]outItem{}}
+	var sources []string
+	addItems := func(items []outItem, model, source string) error {
+		if model == "" {
+			model = recordedModel(items)
+		}
+		if model != "" && prev.model != "" && !sameModel(model, prev.model) {
+			return fmt.Errorf("%s was embedded with %s but %s with %s", source, model, sources[0], prev.model)
+		}
+		if prev.model == "" {
+			prev.model = model
+		}
+		for _, it := range items {
+			if it.Searchable() && it.Content != "" {
+				prev.byHash[itemContentHash(it)] = it
+			}
+		}
+		sources = append(sources, source)
+		return nil
+	}
+
+	if embedOut != "" && !vectorstore.IsQdrant(embedOut) {
+		if _, err := os.Stat(embedOut); err == nil {
+			items, err := vectorstore.ReadItems(embedOut)
+			if err != nil {
+				return nil, fmt.Errorf("read existing --out %s: %w", embedOut, err)
+			}
+			prev.base = items
+			if err := addItems(items, "", embedOut); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if embedCollection != "" {
+		target, err := writableCollection(embedCollection)
+		if err != nil {
+			return nil, err
+		}
+		if !vectorstore.IsQdrant(target.store) {
+			st := vectorstore.NewStore(target.store)
+			if _, err := os.Stat(st.ItemsPath(target.collection)); err == nil {
+				items, info, err := st.Load(target.collection)
+				if err != nil {
+					return nil, fmt.Errorf("read collection %s: %w", target.collection, err)
+				}
+				if err := addItems(items, info.EmbeddingModel, "collection "+target.collection); err != nil {
+					return nil, err
+				}
+			}
+		} else if verbose {
+			fmt.Println("Qdrant collections are not read back; every chunk is embedded")
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	prev.source = sources[0]
+	if len(sources) > 1 {
+		prev.source += " and " + sources[1]
+	}
+	return prev, nil
+}
+
+// itemContentHash returns the content hash recorded on an item, or computes it for items
+// written before hashes were recorded
+func itemContentHash(it outItem) string {
+	if h := metadataString(it.Metadata, "content_hash"); h != "" {
+		return h
+	}
+	return chunker.ContentHash(it.Content)
+}
+
+// reuseUnchanged splits toEmbed into chunks whose content an earlier run already embedded,
+// returned as items carrying the earlier vectors, and the chunks that are new or changed.
+// Matching is by content, so a chunk that moved to another ID keeps its vector too.
+func (p *previousEmbeddings) reuseUnchanged(toEmbed []crawledChunk) (reused []outItem, remaining []crawledChunk) {
+	remaining = make([]crawledChunk, 0, len(toEmbed))
+	for _, c := range toEmbed {
+		hash := chunker.ContentHash(c.Content)
+		prev, ok := p.byHash[hash]
+		if !ok {
+			remaining = append(remaining, c)
+			continue
+		}
+		metadata := c.Metadata
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		metadata["content_hash"] = hash
+		item := outItem{
+			ID:         c.ID,
+			ChunkIndex: c.ChunkIndex,
+			Content:    c.Content,
+			Metadata:   metadata,
+		}
+		item.ReuseEmbedding(prev, p.model)
+		reused = append(reused, item)
+	}
+	return reused, remaining
+}
+
+// untouched returns the items of an existing file --out that this run does not rewrite,
+// which are carried over into the new file
+func (p *previousEmbeddings) untouched(selected []crawledChunk) []outItem {
+	ids := make(map[string]bool, len(selected))
+	for _, c := range selected {
+		ids[c.ID] = true
+	}
+	var kept []outItem
+	for _, it := range p.base {
+		if !ids[it.ID] {
+			kept = append(kept, it)
+		}
+	}
+	return kept
+}
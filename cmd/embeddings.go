@@ -0,0 +1,390 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kirk-ai/internal/cluster"
+	"kirk-ai/internal/secure"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFrom        string
+	migrateOut         string
+	migrateConcurrency int
+
+	clusterEmbeddingsFile string
+	clusterCollection     string
+	clusterK              int
+	clusterMaxIter        int
+	clusterSamples        int
+	clusterNoLabel        bool
+	clusterLabelModel     string
+	clusterOut            string
+)
+
+// embeddingsCmd groups operations on existing embeddings files
+var embeddingsCmd = &cobra.Command{
+	Use:   "embeddings",
+	Short: "Operate on existing embeddings files",
+	Long:  `Tools for working with embeddings that have already been generated.`,
+}
+
+var embeddingsMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Re-embed an embeddings file with a different model",
+	Long: `Re-embed every chunk of an embeddings file with the model given by --model, reusing the
+stored content and preserving IDs and metadata, so switching embedding models does not
+require re-crawling.`,
+	Args: cobra.NoArgs,
+	Run:  runEmbeddingsMigrateCommand,
+}
+
+var embeddingsClusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Group chunks into topics and summarize what the corpus contains",
+	Long: `Run k-means over the embedding vectors, label each cluster with a chat model using its most
+representative chunks, and print a topic breakdown. Use --out to export the clusters as JSON.`,
+	Args: cobra.NoArgs,
+	Run:  runEmbeddingsClusterCommand,
+}
+
+// topicCluster is one entry of the exported topic breakdown
+type topicCluster struct {
+	ID              int            `json:"id"`
+	Label           string         `json:"label,omitempty"`
+	Count           int            `json:"count"`
+	Share           float64        `json:"share"`
+	Representatives []topicExample `json:"representatives"`
+	Members         []string       `json:"members"`
+}
+
+// topicExample is a representative chunk of a cluster
+type topicExample struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+func runEmbeddingsMigrateCommand(cmd *cobra.Command, args []string) {
+	if migrateFrom == "" || migrateOut == "" {
+		fmt.Println("Please specify the source with --from and the destination with --out")
+		os.Exit(1)
+	}
+	if model == "" {
+		fmt.Println("Please specify the new embedding model with --model")
+		os.Exit(1)
+	}
+
+	items, err := vectorstore.ReadItems(migrateFrom)
+	if err != nil {
+		fmt.Printf("Error reading file '%s': %v\n", migrateFrom, err)
+		os.Exit(1)
+	}
+
+	// Record the dimensions found in the source for the report
+	oldDims := make(map[int]int)
+	for _, it := range items {
+		if it.Searchable() {
+			oldDims[len(it.Embedding)]++
+		}
+	}
+
+	out := make([]outItem, len(items))
+	copy(out, items)
+
+	jobs := make(chan int, len(items))
+	for i, it := range items {
+		if it.Tombstoned {
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	total := len(jobs)
+
+	if migrateConcurrency <= 0 {
+		migrateConcurrency = 1
+	}
+
+	var processed, failed, skipped int64
+	var wg sync.WaitGroup
+	for w := 0; w < migrateConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				it := &out[i]
+				if it.Content == "" {
+					// Nothing to re-embed from; drop the stale vector rather than mixing models
+					it.Embedding = nil
+					it.Error = &vectorstore.ItemError{Kind: vectorstore.KindNoContent, Message: "no stored content to re-embed"}
+					atomic.AddInt64(&skipped, 1)
+					continue
+				}
+				resp, err := llmClient.EmbeddingContext(batchContext(), model, it.Content)
+				if err != nil {
+					fmt.Printf("Error embedding chunk %d (id=%s): %v\n", it.ChunkIndex, it.ID, err)
+					it.Embedding = nil
+					it.Error = vectorstore.NewItemError(err)
+					atomic.AddInt64(&failed, 1)
+					continue
+				}
+				it.Embedding = resp.Embedding
+				it.Error = nil
+				it.Metadata = stampEmbedding(it.Metadata, model)
+				cur := atomic.AddInt64(&processed, 1)
+				if verbose {
+					fmt.Printf("Re-embedded chunk %d (id=%s) (progress %d/%d)\n", it.ChunkIndex, it.ID, cur, total)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	newDims := make(map[int]int)
+	for _, it := range out {
+		if it.Searchable() {
+			newDims[len(it.Embedding)]++
+		}
+	}
+
+	if vectorstore.IsShardManifest(migrateFrom) {
+		src, err := vectorstore.ReadShardManifest(migrateFrom)
+		if err != nil {
+			fmt.Printf("Error reading manifest '%s': %v\n", migrateFrom, err)
+			os.Exit(1)
+		}
+		if _, err := vectorstore.WriteShards(migrateOut, out, src.ShardSize, model, encryptOutput); err != nil {
+			fmt.Printf("Error writing shards for '%s': %v\n", migrateOut, err)
+			os.Exit(1)
+		}
+	} else if err := vectorstore.WriteItems(migrateOut, out, encryptOutput); err != nil {
+		fmt.Printf("Error writing output to '%s': %v\n", migrateOut, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Migrated %s -> %s using %s\n", migrateFrom, migrateOut, model)
+	fmt.Printf("Chunks re-embedded: %d, failed: %d, skipped (no content): %d, tombstoned: %d\n",
+		processed, failed, skipped, len(items)-total)
+	fmt.Printf("Dimension: %s -> %s\n", formatDims(oldDims), formatDims(newDims))
+	if formatDims(oldDims) != formatDims(newDims) {
+		fmt.Println("Note: the embedding dimension changed; snapshots and collections built with the old model are not comparable.")
+	}
+}
+
+func runEmbeddingsClusterCommand(cmd *cobra.Command, args []string) {
+	if clusterEmbeddingsFile == "" && clusterCollection == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag or a collection with --collection")
+		os.Exit(1)
+	}
+
+	var items []embeddingItem
+	target := collectionTarget{file: clusterEmbeddingsFile}
+	if clusterCollection != "" {
+		var err error
+		if target, err = resolveCollection(clusterCollection); err != nil {
+			fmt.Printf("Error in --collection: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if target.collection != "" {
+		loaded, _, err := vectorstore.NewStore(target.store).Load(target.collection)
+		if err != nil {
+			fmt.Printf("Error loading collection '%s': %v\n", clusterCollection, err)
+			os.Exit(1)
+		}
+		items = searchableItems(loaded)
+	} else {
+		loaded, err := loadEmbeddings(target.file)
+		if err != nil {
+			fmt.Printf("Error loading embeddings: %v\n", err)
+			os.Exit(1)
+		}
+		items = loaded
+	}
+	if len(items) < 2 {
+		fmt.Println("Need at least two embedded chunks to cluster")
+		os.Exit(1)
+	}
+
+	vectors := make([][]float64, len(items))
+	for i, it := range items {
+		vectors[i] = it.Embedding
+	}
+
+	k := clusterK
+	if k <= 0 {
+		k = cluster.DefaultK(len(items))
+	}
+	start := time.Now()
+	res := cluster.KMeans(vectors, k, clusterMaxIter, 1)
+	if verbose {
+		fmt.Printf("Clustered %d chunks into %d clusters in %d iterations (%v)\n",
+			len(items), len(res.Centroids), res.Iterations, time.Since(start))
+	}
+
+	topics := make([]topicCluster, len(res.Centroids))
+	for c := range topics {
+		topics[c].ID = c
+	}
+	for i, c := range res.Assignments {
+		topics[c].Count++
+		topics[c].Members = append(topics[c].Members, vectorstore.DedupKey(items[i]))
+	}
+	for c := range topics {
+		topics[c].Share = float64(topics[c].Count) / float64(len(items))
+		for _, idx := range res.Representatives(vectors, c, clusterSamples) {
+			topics[c].Representatives = append(topics[c].Representatives, topicExample{
+				ID:      items[idx].ID,
+				Content: items[idx].Content,
+			})
+		}
+	}
+
+	if !clusterNoLabel {
+		labelModel, err := resolveChatModel(clusterLabelModel)
+		if err != nil {
+			fmt.Printf("Error selecting chat model for labels: %v (use --no-label to skip)\n", err)
+			os.Exit(1)
+		}
+		for c := range topics {
+			label, err := labelCluster(labelModel, topics[c].Representatives)
+			if err != nil {
+				fmt.Printf("Error labeling cluster %d: %v\n", c, err)
+				continue
+			}
+			topics[c].Label = label
+		}
+	}
+
+	sort.SliceStable(topics, func(i, j int) bool { return topics[i].Count > topics[j].Count })
+
+	fmt.Printf("Topic breakdown (%d chunks, %d clusters)\n", len(items), len(topics))
+	fmt.Println(strings.Repeat("=", 50))
+	for _, t := range topics {
+		label := t.Label
+		if label == "" {
+			label = fmt.Sprintf("Cluster %d", t.ID)
+		}
+		fmt.Printf("\n%s — %d chunks (%.1f%%)\n", label, t.Count, t.Share*100)
+		for _, ex := range t.Representatives {
+			fmt.Printf("  - %s\n", truncateText(ex.Content, 120))
+		}
+	}
+
+	if clusterOut != "" {
+		b, err := json.MarshalIndent(topics, "", "  ")
+		if err != nil {
+			fmt.Printf("Error encoding clusters: %v\n", err)
+			os.Exit(1)
+		}
+		if err := secure.WriteFile(clusterOut, b, 0644, encryptOutput); err != nil {
+			fmt.Printf("Error writing output to '%s': %v\n", clusterOut, err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nClusters written to %s\n", clusterOut)
+	}
+}
+
+// resolveChatModel returns the installed model matching requested or, when requested is
+// empty, auto-selects an installed chat model
+func resolveChatModel(requested string) (string, error) {
+	models, err := llmClient.ListModels()
+	if err != nil {
+		return "", err
+	}
+	if requested != "" {
+		for _, m := range models {
+			if strings.EqualFold(m, requested) || strings.Contains(strings.ToLower(m), strings.ToLower(requested)) {
+				return m, nil
+			}
+		}
+		return "", fmt.Errorf("requested model %q not found. Available models: %v", requested, models)
+	}
+	if selected := selectChatModel(models); selected != "" {
+		return selected, nil
+	}
+	return "", fmt.Errorf("no suitable chat model found")
+}
+
+// labelCluster asks the chat model for a short topic label describing the examples
+func labelCluster(chatModel string, examples []topicExample) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("The following text excerpts belong to one topic cluster. ")
+	sb.WriteString("Reply with a short topic label of 2 to 5 words and nothing else.\n\n")
+	for i, ex := range examples {
+		fmt.Fprintf(&sb, "Excerpt %d: %s\n\n", i+1, truncateText(ex.Content, 500))
+	}
+	resp, err := llmClient.Chat(chatModel, sb.String())
+	if err != nil {
+		return "", err
+	}
+	label := strings.TrimSpace(resp.Message.Content)
+	label = strings.Trim(strings.SplitN(label, "\n", 2)[0], `"'*. `)
+	return truncateText(label, 60), nil
+}
+
+// truncateText shortens s to at most n characters on a single line
+func truncateText(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// formatDims renders a dimension histogram, e.g. "768" or "768 (10 chunks), 1024 (2 chunks)"
+func formatDims(dims map[int]int) string {
+	if len(dims) == 0 {
+		return "none"
+	}
+	if len(dims) == 1 {
+		for d := range dims {
+			return fmt.Sprintf("%d", d)
+		}
+	}
+	keys := make([]int, 0, len(dims))
+	for d := range dims {
+		keys = append(keys, d)
+	}
+	sort.Ints(keys)
+	s := ""
+	for _, d := range keys {
+		if s != "" {
+			s += ", "
+		}
+		s += fmt.Sprintf("%d (%d chunks)", d, dims[d])
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(embeddingsCmd)
+	embeddingsCmd.AddCommand(embeddingsMigrateCmd)
+	requireServer(embeddingsMigrateCmd, "")
+	requireServer(embeddingsClusterCmd, "no-label")
+
+	embeddingsMigrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Embeddings file or shard manifest to migrate (required)")
+	embeddingsMigrateCmd.Flags().StringVar(&migrateOut, "out", "", "Path to write the re-embedded file (required)")
+	embeddingsCmd.AddCommand(embeddingsClusterCmd)
+
+	embeddingsMigrateCmd.Flags().IntVar(&migrateConcurrency, "concurrency", 4, "Number of concurrent embedding requests")
+
+	embeddingsClusterCmd.Flags().StringVar(&clusterEmbeddingsFile, "embeddings", "", "Embeddings file or shard manifest to cluster")
+	embeddingsClusterCmd.Flags().StringVar(&clusterCollection, "collection", "", "Cluster a named collection (registered with 'collections create', or in --store) instead of a file")
+	embeddingsClusterCmd.Flags().IntVar(&clusterK, "k", 0, "Number of clusters (0 = choose from corpus size)")
+	embeddingsClusterCmd.Flags().IntVar(&clusterMaxIter, "max-iter", 50, "Maximum k-means iterations")
+	embeddingsClusterCmd.Flags().IntVar(&clusterSamples, "samples", 3, "Representative chunks to show and use for labeling per cluster")
+	embeddingsClusterCmd.Flags().BoolVar(&clusterNoLabel, "no-label", false, "Skip LLM labeling of clusters")
+	embeddingsClusterCmd.Flags().StringVar(&clusterLabelModel, "label-model", "", "Chat model used to label clusters (auto-select if not specified)")
+	embeddingsClusterCmd.Flags().StringVar(&clusterOut, "out", "", "Write the topic breakdown as JSON to this file")
+}
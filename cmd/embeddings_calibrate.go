@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/rag"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	calibrateEmbeddingsFile string
+	calibrateCollection     string
+	calibrateSamples        int
+	calibrateNegatives      int
+	calibrateQueryModel     string
+	calibrateExcerpts       bool
+	calibrateSeed           int64
+	calibrateDryRun         bool
+)
+
+var embeddingsCalibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Measure similarity scores for a corpus and record default thresholds",
+	Long: `Sample chunks from a corpus, write a query for each one, and compare the query's similarity to
+its own chunk against its similarity to unrelated chunks. The score distributions show how the
+embedding model behaves on this corpus; the recommended thresholds are recorded next to the
+embeddings file (or in the collection) and used by search, rag, code and translate whenever no
+threshold is given on the command line.`,
+	Args: cobra.NoArgs,
+	Run:  runEmbeddingsCalibrateCommand,
+}
+
+func runEmbeddingsCalibrateCommand(cmd *cobra.Command, args []string) {
+	if calibrateEmbeddingsFile == "" && calibrateCollection == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag or a collection with --collection")
+		os.Exit(1)
+	}
+
+	corp, calibrationPath, err := openCorpus(calibrateEmbeddingsFile, calibrateCollection)
+	if err != nil {
+		fmt.Printf("Error loading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	if corp.remote != nil {
+		fmt.Println("Calibration needs local embeddings; Qdrant collections are not supported")
+		os.Exit(1)
+	}
+	items := corp.items
+	if corp.shards != nil {
+		all, err := corp.shards.ReadAll()
+		if err != nil {
+			fmt.Printf("Error loading shards: %v\n", err)
+			os.Exit(1)
+		}
+		items = searchableItems(all)
+	}
+	if len(items) < 2 {
+		fmt.Println("Need at least two embedded chunks to calibrate")
+		os.Exit(1)
+	}
+
+	if corp.model == "" {
+		// Find the model whose vectors match the chunks', embedding a chunk as the probe
+		corp.model, _, err = detectEmbeddingModel(context.Background(), items[0].Text(), corp.dimension)
+		if err != nil {
+			fmt.Printf("Error selecting embedding model: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	embeddingModel := corp.model
+
+	queryModel := ""
+	if !calibrateExcerpts {
+		queryModel, err = resolveChatModel(calibrateQueryModel)
+		if err != nil {
+			fmt.Printf("Error selecting chat model for queries: %v (use --excerpts to skip)\n", err)
+			os.Exit(1)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(calibrateSeed))
+	sample := rng.Perm(len(items))
+	if calibrateSamples > 0 && len(sample) > calibrateSamples {
+		sample = sample[:calibrateSamples]
+	}
+
+	fmt.Printf("Calibrating %d chunks with %s", len(sample), embeddingModel)
+	if queryModel != "" {
+		fmt.Printf(" (queries written by %s)", queryModel)
+	}
+	fmt.Println("...")
+
+	start := time.Now()
+	var positive, negative []float64
+	for n, idx := range sample {
+		item := items[idx]
+		content := rag.ContentOf(item)
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		query, err := calibrationQuery(queryModel, content)
+		if err != nil || query == "" {
+			if verbose {
+				fmt.Printf("Skipping %s: could not write a query (%v)\n", item.ID, err)
+			}
+			continue
+		}
+		queryEmbedding, err := corp.embedQuery(context.Background(), query, "")
+		if err != nil {
+			fmt.Printf("Error embedding query: %v\n", err)
+			os.Exit(1)
+		}
+
+		pos := vectorstore.CosineSimilarity(queryEmbedding, item.Embedding)
+		positive = append(positive, pos)
+		negative = append(negative, negativeScores(queryEmbedding, items, idx, rng)...)
+		if verbose {
+			fmt.Printf("[%d/%d] %.3f  %s\n", n+1, len(sample), pos, truncateText(query, 80))
+		}
+	}
+	if len(positive) == 0 || len(negative) == 0 {
+		fmt.Println("Not enough scored pairs to calibrate (chunks need content)")
+		os.Exit(1)
+	}
+
+	cal := &vectorstore.Calibration{
+		EmbeddingModel: embeddingModel,
+		Samples:        len(positive),
+		Positive:       vectorstore.Distribute(positive),
+		Negative:       vectorstore.Distribute(negative),
+		CalibratedAt:   time.Now().UTC(),
+	}
+	cal.Recommend()
+	if verbose {
+		fmt.Printf("Scored %d queries in %v\n", len(positive), time.Since(start))
+	}
+
+	printCalibration(cal, positive, negative)
+
+	if calibrateDryRun {
+		return
+	}
+	if err := vectorstore.WriteCalibration(calibrationPath, cal); err != nil {
+		fmt.Printf("Error writing calibration to '%s': %v\n", calibrationPath, err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nCalibration written to %s\n", calibrationPath)
+}
+
+// calibrationQuery asks the chat model for a question the chunk answers, or with no model
+// uses the chunk's opening words as the query
+func calibrationQuery(chatModel, content string) (string, error) {
+	if chatModel == "" {
+		words := strings.Fields(content)
+		if len(words) > 12 {
+			words = words[:12]
+		}
+		return strings.Join(words, " "), nil
+	}
+	prompt := "Write one short question a user might search for that the following text answers. " +
+		"Do not quote the text. Reply with the question only.\n\nText: " + truncateText(content, 1500)
+	resp, err := llmClient.Chat(chatModel, prompt)
+	if err != nil {
+		return "", err
+	}
+	query := strings.TrimSpace(strings.SplitN(strings.TrimSpace(resp.Message.Content), "\n", 2)[0])
+	return strings.Trim(query, `"'*`), nil
+}
+
+// negativeScores scores the query against random chunks from other pages than items[self]
+func negativeScores(query []float64, items []embeddingItem, self int, rng *rand.Rand) []float64 {
+	source := vectorstore.SourceURL(items[self])
+	var scores []float64
+	for tries := 0; len(scores) < calibrateNegatives && tries < calibrateNegatives*5; tries++ {
+		j := rng.Intn(len(items))
+		if j == self || (source != "" && vectorstore.SourceURL(items[j]) == source) {
+			continue
+		}
+		scores = append(scores, vectorstore.CosineSimilarity(query, items[j].Embedding))
+	}
+	return scores
+}
+
+func printCalibration(cal *vectorstore.Calibration, positive, negative []float64) {
+	fmt.Println()
+	fmt.Printf("Similarity distribution (%s)\n", cal.EmbeddingModel)
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf("%-18s %6s %6s %6s %6s %6s %6s\n", "", "min", "p10", "p50", "p90", "p95", "max")
+	for _, row := range []struct {
+		name string
+		d    vectorstore.Distribution
+	}{
+		{fmt.Sprintf("matching (%d)", cal.Positive.Count), cal.Positive},
+		{fmt.Sprintf("unrelated (%d)", cal.Negative.Count), cal.Negative},
+	} {
+		fmt.Printf("%-18s %6.3f %6.3f %6.3f %6.3f %6.3f %6.3f\n", row.name, row.d.Min, row.d.P10, row.d.P50, row.d.P90, row.d.P95, row.d.Max)
+	}
+
+	recall, rejection := cal.Separation(positive, negative)
+	fmt.Println()
+	fmt.Println("Recommended thresholds")
+	fmt.Printf("  rag:               %.2f (keeps %.0f%% of matches, rejects %.0f%% of unrelated chunks)\n", cal.RAG, recall*100, rejection*100)
+	fmt.Printf("  rag (large/prog.): %.2f\n", cal.RAGStrict)
+	fmt.Printf("  search:            %.2f\n", cal.Search)
+	if cal.Positive.P50 <= cal.Negative.P95 {
+		fmt.Println("\n⚠️  Matching and unrelated scores overlap heavily; this embedding model separates the corpus poorly.")
+	}
+}
+
+func init() {
+	embeddingsCmd.AddCommand(embeddingsCalibrateCmd)
+	requireServer(embeddingsCalibrateCmd, "")
+
+	embeddingsCalibrateCmd.Flags().StringVar(&calibrateEmbeddingsFile, "embeddings", "", "Embeddings file or shard manifest to calibrate")
+	embeddingsCalibrateCmd.Flags().StringVar(&calibrateCollection, "collection", "", "Calibrate a named collection (registered with 'collections create', or in --store) instead of a file")
+	embeddingsCalibrateCmd.Flags().IntVar(&calibrateSamples, "samples", 30, "Number of chunks to write queries for")
+	embeddingsCalibrateCmd.Flags().IntVar(&calibrateNegatives, "negatives", 10, "Unrelated chunks scored against each query")
+	embeddingsCalibrateCmd.Flags().StringVar(&calibrateQueryModel, "query-model", "", "Chat model that writes the sample queries (auto-select if not specified)")
+	embeddingsCalibrateCmd.Flags().BoolVar(&calibrateExcerpts, "excerpts", false, "Use each chunk's opening words as its query instead of asking a chat model")
+	embeddingsCalibrateCmd.Flags().Int64Var(&calibrateSeed, "seed", 1, "Random seed for choosing sample and unrelated chunks")
+	embeddingsCalibrateCmd.Flags().BoolVar(&calibrateDryRun, "dry-run", false, "Print the analysis without recording the thresholds")
+}
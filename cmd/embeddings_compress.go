@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/secure"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	compressEmbeddingsFile string
+	compressCollection     string
+	compressOff            bool
+)
+
+var embeddingsCompressCmd = &cobra.Command{
+	Use:   "compress",
+	Short: "Store chunk content compressed with a dictionary trained on the corpus",
+	Long: `Train a zstd dictionary on the chunk content of an embeddings file or collection, save it next
+to the embeddings (<file>.dict, or embeddings.json.dict in the collection), and rewrite the
+embeddings with each chunk's content compressed by it. Text-heavy corpora shrink to a fraction of
+their size.
+
+search, rag, code and translate keep the content compressed in memory and decompress only the
+chunks that make it into results. embed and index refresh keep compressing with the same dictionary
+when they rewrite the file; run compress again to retrain it on the current content, or pass --off
+to store the content as plain text again.`,
+	Args: cobra.NoArgs,
+	Run:  runEmbeddingsCompressCommand,
+}
+
+func runEmbeddingsCompressCommand(cmd *cobra.Command, args []string) {
+	if compressEmbeddingsFile == "" && compressCollection == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag or a collection with --collection")
+		os.Exit(1)
+	}
+	target, err := corpusTarget(compressEmbeddingsFile, compressCollection)
+	if err != nil {
+		fmt.Printf("Error in --collection: %v\n", err)
+		os.Exit(1)
+	}
+	path := target.file
+	if path == "" {
+		path = vectorstore.NewStore(target.store).ItemsPath(target.collection)
+	}
+	if target.remote() || vectorstore.IsShardManifest(path) {
+		fmt.Println("Content compression applies to embeddings files and collections; Qdrant and sharded indexes are not supported")
+		os.Exit(1)
+	}
+
+	before, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	// An encrypted file stays encrypted without --encrypt
+	encrypt := encryptOutput || secure.IsEncrypted(before)
+
+	if compressOff {
+		err = vectorstore.DecompressContent(path, encrypt)
+	} else {
+		err = vectorstore.CompressContent(path, encrypt)
+	}
+	if err != nil {
+		fmt.Printf("Error rewriting %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("Error reading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	if compressOff {
+		fmt.Printf("Content of %s stored as plain text: %s -> %s\n", path, formatSize(int64(len(before))), formatSize(after.Size()))
+		return
+	}
+	dict, err := os.Stat(vectorstore.DictPath(path))
+	if err != nil {
+		fmt.Printf("Error reading dictionary: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Content of %s compressed: %s -> %s, plus a %s dictionary at %s\n", path,
+		formatSize(int64(len(before))), formatSize(after.Size()), formatSize(dict.Size()), vectorstore.DictPath(path))
+}
+
+// formatSize renders a file size in kB or MB
+func formatSize(n int64) string {
+	if n < 1<<20 {
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+func init() {
+	embeddingsCmd.AddCommand(embeddingsCompressCmd)
+
+	embeddingsCompressCmd.Flags().StringVar(&compressEmbeddingsFile, "embeddings", "", "Embeddings file to compress")
+	embeddingsCompressCmd.Flags().StringVar(&compressCollection, "collection", "", "Compress a named collection (registered with 'collections create', or in --store) instead of a file")
+	embeddingsCompressCmd.Flags().BoolVar(&compressOff, "off", false, "Store the content as plain text again and remove the dictionary")
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	annEmbeddingsFile string
+	annCollection     string
+	annM              int
+	annEfConstruction int
+	annEfSearch       int
+)
+
+var embeddingsIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build an approximate nearest-neighbor index for fast search",
+	Long: `Build an HNSW graph over an embeddings file or collection and save it next to the embeddings
+(<file>.hnsw, or index.hnsw in the collection). search, rag, code and translate then walk the graph
+instead of comparing the query against every embedding, which keeps lookups fast on large corpora.
+
+A BM25 keyword index for search and rag --hybrid is saved alongside (<file>.bm25, or index.bm25
+in the collection).
+
+The indexes record which embeddings they were built from; after the embeddings change they are
+ignored with a warning until rebuilt. Use --exact on search or rag to bypass the graph.`,
+	Args: cobra.NoArgs,
+	Run:  runEmbeddingsIndexCommand,
+}
+
+func runEmbeddingsIndexCommand(cmd *cobra.Command, args []string) {
+	if annEmbeddingsFile == "" && annCollection == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag or a collection with --collection")
+		os.Exit(1)
+	}
+
+	corp, _, err := openCorpus(annEmbeddingsFile, annCollection)
+	if err != nil {
+		fmt.Printf("Error loading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	if corp.remote != nil || corp.shards != nil {
+		fmt.Println("Approximate indexes are built for embeddings files and collections; Qdrant and sharded indexes are not supported")
+		os.Exit(1)
+	}
+	if len(corp.items) == 0 {
+		fmt.Println("No embedded chunks to index")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Indexing %d embeddings...\n", len(corp.items))
+	start := time.Now()
+	ann, err := vectorstore.BuildHNSW(corp.items, vectorstore.HNSWParams{
+		M:              annM,
+		EfConstruction: annEfConstruction,
+		EfSearch:       annEfSearch,
+	})
+	if err != nil {
+		fmt.Printf("Error building index: %v\n", err)
+		os.Exit(1)
+	}
+	if verbose {
+		fmt.Printf("Built graph with %d levels in %v\n", ann.MaxLevel+1, time.Since(start))
+	}
+
+	if err := vectorstore.WriteHNSW(corp.annPath, ann); err != nil {
+		fmt.Printf("Error writing index to '%s': %v\n", corp.annPath, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Index of %d embeddings written to %s in %v\n", ann.Len(), corp.annPath, time.Since(start))
+
+	keywords := vectorstore.BuildKeywordIndex(corp.items)
+	if err := vectorstore.WriteKeywordIndex(corp.keywordPath, keywords); err != nil {
+		fmt.Printf("Error writing keyword index to '%s': %v\n", corp.keywordPath, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Keyword index of %d chunks (%d terms) written to %s\n", keywords.Len(), len(keywords.Postings), corp.keywordPath)
+}
+
+func init() {
+	embeddingsCmd.AddCommand(embeddingsIndexCmd)
+
+	embeddingsIndexCmd.Flags().StringVar(&annEmbeddingsFile, "embeddings", "", "Embeddings file to index")
+	embeddingsIndexCmd.Flags().StringVar(&annCollection, "collection", "", "Index a named collection (registered with 'collections create', or in --store) instead of a file")
+	embeddingsIndexCmd.Flags().IntVar(&annM, "m", vectorstore.DefaultHNSWM, "Links per node; higher improves recall at the cost of memory and build time")
+	embeddingsIndexCmd.Flags().IntVar(&annEfConstruction, "ef-construction", vectorstore.DefaultHNSWEfConstruction, "Candidates considered while linking each node")
+	embeddingsIndexCmd.Flags().IntVar(&annEfSearch, "ef-search", vectorstore.DefaultHNSWEfSearch, "Candidates explored per query; higher improves recall at the cost of speed")
+}
@@ -0,0 +1,403 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/rag"
+	"kirk-ai/internal/rerank"
+	"kirk-ai/internal/sweep"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	evalEmbeddings  string
+	evalCollection  string
+	evalContextSize int
+	evalThreshold   float64
+	evalModel       string
+	evalJudge       string
+	evalOut         string
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval <cases.jsonl>",
+	Short: "Measure RAG answers against expected answers",
+	Long: `Run every question of an evaluation set through rag's retrieval and generation and report
+how often the right context was retrieved, how faithful the answers are to it, how well they
+match the expected answers, and how long each step took, so changes to chunking, thresholds,
+reranking, or models can be measured.
+
+The set is a JSON array or one JSON object per line:
+
+  {"question": "When does the conference open?", "expected": "Friday morning", "relevant": ["https://example.org/schedule"]}
+
+"relevant" lists the chunk IDs or source URLs the context should come from; a question counts
+as a retrieval hit when any of them is retrieved. Without it, the judge model decides whether
+the retrieved context holds the expected answer. The judge also scores each answer from 1 to
+10 for faithfulness (every claim supported by the context) and correctness (agreement with
+the expected answer). A judge larger than the answering model scores more reliably.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runEvalCommand,
+}
+
+// evalCase is one question's outcome
+type evalCase struct {
+	Question string `json:"question"`
+	Expected string `json:"expected"`
+	Answer   string `json:"answer"`
+	Hit      bool   `json:"hit"`
+	// HitJudged is set when the judge decided Hit, the case listing no relevant chunks
+	HitJudged    bool    `json:"hit_judged,omitempty"`
+	Faithfulness int     `json:"faithfulness"` // judge score, 1-10 (0 = not scored)
+	Correctness  int     `json:"correctness"`  // judge score, 1-10 (0 = not scored)
+	Chunks       int     `json:"chunks"`
+	RetrievalMs  float64 `json:"retrieval_ms"`
+	GenerationMs float64 `json:"generation_ms"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// evalSummary aggregates the cases; scores are means over the cases that were scored
+type evalSummary struct {
+	Cases           int     `json:"cases"`
+	Errors          int     `json:"errors"`
+	HitRate         float64 `json:"hit_rate"`
+	Faithfulness    float64 `json:"faithfulness"`
+	Correctness     float64 `json:"correctness"`
+	RetrievalP50Ms  float64 `json:"retrieval_p50_ms"`
+	RetrievalP90Ms  float64 `json:"retrieval_p90_ms"`
+	GenerationP50Ms float64 `json:"generation_p50_ms"`
+	GenerationP90Ms float64 `json:"generation_p90_ms"`
+}
+
+// evalReport is what --out saves: the settings evaluated, the summary, and every case
+type evalReport struct {
+	CreatedAt      time.Time   `json:"created_at"`
+	Source         string      `json:"source"`
+	EmbeddingModel string      `json:"embedding_model,omitempty"`
+	Model          string      `json:"model"`
+	Judge          string      `json:"judge"`
+	ContextSize    int         `json:"context_size"`
+	Threshold      float64     `json:"threshold"`
+	Hybrid         bool        `json:"hybrid,omitempty"`
+	Rerank         string      `json:"rerank,omitempty"`
+	Summary        evalSummary `json:"summary"`
+	Cases          []evalCase  `json:"cases"`
+}
+
+func runEvalCommand(cmd *cobra.Command, args []string) {
+	if evalEmbeddings == "" && evalCollection == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag or a collection with --collection")
+		os.Exit(1)
+	}
+	if evalContextSize < 1 {
+		fmt.Println("--context-size must be positive")
+		os.Exit(1)
+	}
+	checkRerankFlags()
+	cases, err := sweep.LoadCases(args[0])
+	if err != nil {
+		fmt.Printf("Error loading evaluation set: %v\n", err)
+		os.Exit(1)
+	}
+	if len(cases) == 0 {
+		fmt.Printf("No cases in %s\n", args[0])
+		os.Exit(1)
+	}
+	corp, err := loadSearchCorpus(evalEmbeddings, evalCollection)
+	if err != nil {
+		fmt.Printf("Error loading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	corp.setFilter(searchFilters)
+	model, err := selectRAGModel(evalModel, false)
+	if err != nil {
+		fmt.Printf("Error selecting model: %v\n", err)
+		os.Exit(1)
+	}
+	judge, err := resolveChatModel(evalJudge)
+	if err != nil {
+		fmt.Printf("Error selecting judge model: %v\n", err)
+		os.Exit(1)
+	}
+	threshold := evalThreshold
+	if threshold == 0 {
+		threshold = ragThreshold(corp, evalContextSize)
+	}
+
+	report := evalReport{
+		CreatedAt:      time.Now().UTC(),
+		Source:         evalEmbeddings,
+		EmbeddingModel: corp.model,
+		Model:          model,
+		Judge:          judge,
+		ContextSize:    evalContextSize,
+		Threshold:      threshold,
+		Hybrid:         hybridSearch,
+	}
+	if evalCollection != "" {
+		report.Source = evalCollection
+	}
+	if rerankMethod != rerank.Off {
+		report.Rerank = rerankMethod
+	}
+	fmt.Printf("Evaluating %d question(s) against %d chunks with %s (judge: %s, threshold %.2f)\n",
+		len(cases), corp.Len(), model, judge, threshold)
+	if judge == model {
+		fmt.Println("Note: the judge is the answering model; --judge-model with a larger model scores more reliably")
+	}
+	fmt.Println()
+	for i, c := range cases {
+		r := evalOne(corp, c, model, judge, threshold)
+		report.Cases = append(report.Cases, r)
+		status := "miss"
+		if r.Hit {
+			status = "hit"
+		}
+		if r.Error != "" {
+			status = "error: " + r.Error
+		}
+		fmt.Printf("[%d/%d] %s\n", i+1, len(cases), truncateText(c.Question, 70))
+		fmt.Printf("       %s, faithfulness %s, correctness %s (retrieval %v, generation %v)\n", status,
+			evalScore(r.Faithfulness), evalScore(r.Correctness),
+			roundMs(msDuration(r.RetrievalMs)), roundMs(msDuration(r.GenerationMs)))
+		if verbose && r.Answer != "" {
+			fmt.Printf("       answer: %s\n", truncateText(strings.ReplaceAll(r.Answer, "\n", " "), 200))
+		}
+	}
+
+	report.Summary = summarizeEval(report.Cases)
+	printEvalSummary(report.Summary)
+	if evalOut != "" {
+		if err := writeEvalReport(evalOut, &report); err != nil {
+			fmt.Printf("Error writing %s: %v\n", evalOut, err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nResults written to %s\n", evalOut)
+	}
+}
+
+// evalOne retrieves context for a case the way rag does, answers from it, and has the judge
+// score the outcome
+func evalOne(corp *corpus, c sweep.Case, model, judge string, threshold float64) evalCase {
+	r := evalCase{Question: c.Question, Expected: c.Expected}
+	start := time.Now()
+	queryEmbedding, err := corp.embedQuery(context.Background(), c.Question, "")
+	if err != nil {
+		r.Error = fmt.Sprintf("embedding question: %v", err)
+		return r
+	}
+	results, err := retrieveContext(corp, c.Question, queryEmbedding, evalContextSize, threshold)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.RetrievalMs = float64(time.Since(start).Microseconds()) / 1000
+
+	budget, contextTokens, windowed := contextBudget(model, rag.BuildPrompt(c.Question, ""))
+	results = budget.Fit(results, contextTokens)
+	maxLength := rag.DefaultMaxContextLength
+	if windowed {
+		maxLength = math.MaxInt32
+	}
+	contextText, used := rag.BuildContext(results, maxLength)
+	r.Chunks = len(used)
+	if len(c.Relevant) > 0 {
+		r.Hit = sweep.Found(c.Relevant, used)
+	} else if len(used) > 0 {
+		r.HitJudged = true
+		r.Hit, err = judgeYes(judge, fmt.Sprintf("Does the context below contain the information needed to give the expected answer "+
+			"to the question? Reply yes or no.\n\nQuestion: %s\n\nExpected answer: %s\n\nContext:\n%s", c.Question, c.Expected, contextText))
+		if err != nil && verbose {
+			fmt.Printf("Judge error: %v\n", err)
+		}
+	}
+	if len(used) == 0 {
+		r.Error = "no relevant context found"
+		return r
+	}
+
+	start = time.Now()
+	resp, err := llmClient.Chat(model, rag.BuildPrompt(c.Question, contextText))
+	if err != nil {
+		r.Error = fmt.Sprintf("generating answer: %v", err)
+		return r
+	}
+	r.GenerationMs = float64(time.Since(start).Microseconds()) / 1000
+	r.Answer = strings.TrimSpace(resp.Message.Content)
+
+	r.Faithfulness = judgeScore(judge, fmt.Sprintf("Rate from 1 to 10 how faithful the answer is to the context: 10 if every claim "+
+		"in it is supported by the context, 1 if it is mostly unsupported. Reply with the number only.\n\n"+
+		"Context:\n%s\n\nQuestion: %s\n\nAnswer: %s", contextText, c.Question, r.Answer))
+	r.Correctness = judgeScore(judge, fmt.Sprintf("Rate from 1 to 10 how well the answer agrees with the expected answer: 10 if it "+
+		"says the same thing, 1 if it contradicts or misses it. Reply with the number only.\n\n"+
+		"Question: %s\n\nExpected answer: %s\n\nAnswer: %s", c.Question, c.Expected, r.Answer))
+	return r
+}
+
+// judgeScore asks the judge for a score from 1 to 10, returning 0 when it gives none
+func judgeScore(judge, prompt string) int {
+	resp, err := llmClient.Chat(judge, prompt)
+	if err != nil {
+		if verbose {
+			fmt.Printf("Judge error: %v\n", err)
+		}
+		return 0
+	}
+	score, _ := strconv.Atoi(judgeScoreRE.FindString(resp.Message.Content))
+	return score
+}
+
+// judgeYes asks the judge a yes or no question
+func judgeYes(judge, prompt string) (bool, error) {
+	resp, err := llmClient.Chat(judge, prompt)
+	if err != nil {
+		return false, err
+	}
+	reply := strings.ToLower(strings.TrimSpace(resp.Message.Content))
+	return strings.HasPrefix(strings.TrimLeft(reply, "*\"' "), "yes"), nil
+}
+
+func evalScore(score int) string {
+	if score == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/10", score)
+}
+
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// summarizeEval aggregates the cases: the hit rate over all of them, judge scores over those
+// scored, and latency percentiles over those that got that far
+func summarizeEval(cases []evalCase) evalSummary {
+	s := evalSummary{Cases: len(cases)}
+	var retrieval, generation []time.Duration
+	var faithful, correct, faithfulN, correctN int
+	for _, c := range cases {
+		if c.Error != "" {
+			s.Errors++
+		}
+		if c.Hit {
+			s.HitRate++
+		}
+		if c.Faithfulness > 0 {
+			faithful += c.Faithfulness
+			faithfulN++
+		}
+		if c.Correctness > 0 {
+			correct += c.Correctness
+			correctN++
+		}
+		if c.RetrievalMs > 0 {
+			retrieval = append(retrieval, msDuration(c.RetrievalMs))
+		}
+		if c.GenerationMs > 0 {
+			generation = append(generation, msDuration(c.GenerationMs))
+		}
+	}
+	if len(cases) > 0 {
+		s.HitRate /= float64(len(cases))
+	}
+	if faithfulN > 0 {
+		s.Faithfulness = float64(faithful) / float64(faithfulN)
+	}
+	if correctN > 0 {
+		s.Correctness = float64(correct) / float64(correctN)
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	if len(retrieval) > 0 {
+		sort.Slice(retrieval, func(i, j int) bool { return retrieval[i] < retrieval[j] })
+		s.RetrievalP50Ms, s.RetrievalP90Ms = ms(durationPercentile(retrieval, 50)), ms(durationPercentile(retrieval, 90))
+	}
+	if len(generation) > 0 {
+		sort.Slice(generation, func(i, j int) bool { return generation[i] < generation[j] })
+		s.GenerationP50Ms, s.GenerationP90Ms = ms(durationPercentile(generation, 50)), ms(durationPercentile(generation, 90))
+	}
+	return s
+}
+
+func printEvalSummary(s evalSummary) {
+	fmt.Println()
+	fmt.Println("Summary")
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf("Questions:          %d (%d failed)\n", s.Cases, s.Errors)
+	fmt.Printf("Retrieval hit rate: %.2f\n", s.HitRate)
+	fmt.Printf("Faithfulness:       %.1f/10\n", s.Faithfulness)
+	fmt.Printf("Correctness:        %.1f/10\n", s.Correctness)
+	fmt.Printf("Retrieval latency:  p50 %v, p90 %v\n", roundMs(msDuration(s.RetrievalP50Ms)), roundMs(msDuration(s.RetrievalP90Ms)))
+	fmt.Printf("Generation latency: p50 %v, p90 %v\n", roundMs(msDuration(s.GenerationP50Ms)), roundMs(msDuration(s.GenerationP90Ms)))
+}
+
+// writeEvalReport writes report to path, as JSON when it ends in .json and otherwise as CSV
+// with one row per question
+func writeEvalReport(path string, report *evalReport) error {
+	f, err := atomicfile.Create(path, 0o644, false)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeEvalCSV(f, report)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+func writeEvalCSV(w io.Writer, report *evalReport) error {
+	cw := csv.NewWriter(w)
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	cw.Write([]string{"created_at", "model", "judge", "context_size", "threshold", "question", "expected", "answer",
+		"hit", "hit_judged", "faithfulness", "correctness", "chunks", "retrieval_ms", "generation_ms", "error"})
+	for _, c := range report.Cases {
+		cw.Write([]string{report.CreatedAt.Format(time.RFC3339), report.Model, report.Judge,
+			strconv.Itoa(report.ContextSize), strconv.FormatFloat(report.Threshold, 'f', 2, 64),
+			c.Question, c.Expected, c.Answer, strconv.FormatBool(c.Hit), strconv.FormatBool(c.HitJudged),
+			strconv.Itoa(c.Faithfulness), strconv.Itoa(c.Correctness), strconv.Itoa(c.Chunks),
+			num(c.RetrievalMs), num(c.GenerationMs), c.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+	requireServer(evalCmd, "")
+
+	evalCmd.Flags().StringVar(&evalEmbeddings, "embeddings", "",
+		"Path to embeddings JSON file (required unless --collection is set)")
+	evalCmd.Flags().StringVar(&evalCollection, "collection", "",
+		"Evaluate a named collection instead of an embeddings file")
+	evalCmd.Flags().IntVar(&evalContextSize, "context-size", 3,
+		"Number of context chunks to answer from, as with rag")
+	evalCmd.Flags().Float64Var(&evalThreshold, "similarity-threshold", 0.0,
+		"Similarity threshold for the context (0.0 = calibrated or auto, as with rag)")
+	evalCmd.Flags().StringVar(&evalModel, "rag-model", "",
+		"Chat model that answers (default: the one rag would pick)")
+	evalCmd.Flags().StringVar(&evalJudge, "judge-model", "",
+		"Chat model that scores retrieval and answers (default: an installed chat model)")
+	evalCmd.Flags().StringVar(&evalOut, "out", "",
+		"Save the results: JSON when the file ends in .json, otherwise CSV with one row per question")
+	addFilterFlag(evalCmd)
+	addHybridFlag(evalCmd)
+	addRerankFlags(evalCmd)
+}
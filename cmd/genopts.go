@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"kirk-ai/internal/models"
+
+	"github.com/spf13/cobra"
+)
+
+// Generation options of the commands that talk to a chat model
+var (
+	genTemperature   float64
+	genTopP          float64
+	genTopK          int
+	genNumCtx        int
+	genRepeatPenalty float64
+	genSeed          int
+)
+
+// addGenerationFlags registers the sampling and context flags on a command that generates text
+func addGenerationFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&genTemperature, "temperature", 0, "Sampling temperature; lower is more focused and repeatable (default: the model's, or the config file's)")
+	cmd.Flags().Float64Var(&genTopP, "top-p", 0, "Sample only from the most likely tokens whose probabilities add up to this, 0 to 1 (default: the model's)")
+	cmd.Flags().IntVar(&genTopK, "top-k", 0, "Sample only from this many of the most likely tokens (default: the model's)")
+	cmd.Flags().IntVar(&genNumCtx, "num-ctx", 0, "Context window in tokens; raise it for long prompts and RAG contexts (default: the model's)")
+	cmd.Flags().Float64Var(&genRepeatPenalty, "repeat-penalty", 0, "Penalize repeated tokens; above 1 discourages repetition (default: the model's)")
+	cmd.Flags().IntVar(&genSeed, "seed", 0, "Random seed, so the same prompt gives the same output (default: random)")
+}
+
+// generationFlags returns the generation options given on cmd's command line, or nil when
+// none is. Options not given fall back to the config file, then to the model's defaults.
+func generationFlags(cmd *cobra.Command) *models.Options {
+	var opts models.Options
+	changed := func(name string) bool {
+		return cmd.Flags().Lookup(name) != nil && cmd.Flags().Changed(name)
+	}
+	if changed("temperature") {
+		opts.Temperature = &genTemperature
+	}
+	if changed("top-p") {
+		opts.TopP = &genTopP
+	}
+	if changed("top-k") {
+		opts.TopK = &genTopK
+	}
+	if changed("num-ctx") {
+		opts.NumCtx = &genNumCtx
+	}
+	if changed("repeat-penalty") {
+		opts.RepeatPenalty = &genRepeatPenalty
+	}
+	if changed("seed") {
+		opts.Seed = &genSeed
+	}
+	return opts.WithDefaults(nil)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var hybridSearch bool // fuse keyword and vector results (search and rag --hybrid)
+
+// addHybridFlag registers --hybrid on a command that searches embeddings
+func addHybridFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&hybridSearch, "hybrid", false,
+		"Also search chunk text by keyword (BM25) and fuse both rankings, which finds exact names and quotes")
+}
+
+// keywordSearch returns up to topK chunks ranked by BM25 for query. The index saved by
+// `embeddings index` is used when it is current; otherwise one is built in memory.
+func (c *corpus) keywordSearch(query string, topK int) ([]searchResult, error) {
+	if c.daemon != nil {
+		return c.daemon.keywordSearch(query, topK)
+	}
+	return c.keywordSearchWith(query, topK, c.filter)
+}
+
+// keywordSearchWith is keywordSearch with the filter given. It is not safe for concurrent
+// use until the keyword index has been read or built.
+func (c *corpus) keywordSearchWith(query string, topK int, filter vectorstore.Filter) ([]searchResult, error) {
+	if c.remote != nil || c.shards != nil {
+		return nil, fmt.Errorf("keyword search needs an embeddings file or collection; Qdrant and sharded indexes are not supported")
+	}
+	if c.keywords == nil {
+		k, err := vectorstore.ReadKeywordIndex(c.keywordPath)
+		if err != nil {
+			return nil, err
+		}
+		if k != nil {
+			if err := k.Attach(c.items); err != nil {
+				fmt.Printf("Warning: ignoring %s: %v (rebuild with 'embeddings index')\n", c.keywordPath, err)
+				k = nil
+			} else if verbose {
+				fmt.Printf("Using keyword index %s\n", c.keywordPath)
+			}
+		}
+		if k == nil {
+			k = vectorstore.BuildKeywordIndex(c.items)
+			if verbose {
+				fmt.Printf("Built keyword index over %d chunks (save it with 'embeddings index')\n", k.Len())
+			}
+		}
+		c.keywords = k
+	}
+	if len(filter) == 0 {
+		return c.keywords.Search(query, topK), nil
+	}
+	var matched []searchResult
+	for _, r := range c.keywords.Search(query, 0) {
+		if filter.Match(r.Item) {
+			matched = append(matched, r)
+			if len(matched) == topK {
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// hybridResults fuses the best keyword matches for query into the vector results with
+// reciprocal rank fusion, returning up to topK. Every result is given its cosine similarity
+// to the query, so scores and thresholds downstream keep their meaning; keyword matches
+// are kept even below the similarity threshold, since finding them is the point.
+func hybridResults(corp *corpus, query string, queryEmbedding []float64, results []searchResult, topK int) ([]searchResult, error) {
+	if !hybridSearch {
+		return results, nil
+	}
+	keyword, err := corp.keywordSearch(query, topK)
+	if err != nil {
+		return nil, err
+	}
+	fused := vectorstore.FuseRanks(vectorstore.DefaultRRFK, topK, results, keyword)
+	for i := range fused {
+		fused[i].Similarity = vectorstore.CosineSimilarity(queryEmbedding, fused[i].Item.Embedding)
+	}
+	if verbose {
+		fmt.Printf("Fused %d vector and %d keyword results\n", len(results), len(keyword))
+	}
+	return fused, nil
+}
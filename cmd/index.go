@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/license"
+	"kirk-ai/internal/plugin"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	indexEmbeddingsFile string
+	indexOut            string
+	indexTTL            time.Duration
+	indexFetchTimeout   time.Duration
+	indexExtractor      string
+	indexForce          bool
+)
+
+// indexCmd groups maintenance operations on an embeddings index
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Maintain an embeddings index",
+	Long:  `Maintenance operations that keep an embeddings index current without full rebuilds.`,
+}
+
+var indexRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Re-crawl stale sources and re-embed only changed pages",
+	Long: `Re-crawl every source URL whose chunks are older than --ttl, re-embed only the chunks
+whose content hash changed, and tombstone chunks whose source page has been removed.`,
+	Args: cobra.NoArgs,
+	Run:  runIndexRefreshCommand,
+}
+
+// refreshTokenizers holds the tokenizers recorded in chunk provenance, loaded once each
+var refreshTokenizers = map[string]chunker.Tokenizer{}
+
+// refreshTokenizer loads the tokenizer a page was chunked with. One that can no longer be
+// read falls back to the estimate, which may move chunk boundaries and re-embed the page.
+func refreshTokenizer(path string) chunker.Tokenizer {
+	if path == "" {
+		return chunker.Estimate
+	}
+	if tok, ok := refreshTokenizers[path]; ok {
+		return tok
+	}
+	tok, err := chunker.LoadTokenizer(path)
+	if err != nil {
+		fmt.Printf("Warning: could not load tokenizer %s: %v (estimating tokens)\n", path, err)
+		tok = chunker.Estimate
+	}
+	refreshTokenizers[path] = tok
+	return tok
+}
+
+// refreshStats summarizes the outcome of an index refresh
+type refreshStats struct {
+	Sources    int
+	Fresh      int
+	Unchanged  int
+	Updated    int
+	Tombstoned int
+	Failed     int
+	ReEmbedded int
+	Reused     int
+}
+
+func runIndexRefreshCommand(cmd *cobra.Command, args []string) {
+	if indexEmbeddingsFile == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag")
+		os.Exit(1)
+	}
+
+	items, err := vectorstore.ReadItems(indexEmbeddingsFile)
+	if err != nil {
+		fmt.Printf("Error reading file '%s': %v\n", indexEmbeddingsFile, err)
+		os.Exit(1)
+	}
+
+	// Group chunk positions by source URL, preserving first-seen order
+	bySource := make(map[string][]int)
+	var sources []string
+	for i, it := range items {
+		src := metadataString(it.Metadata, "source_url")
+		if src == "" || it.Tombstoned {
+			continue
+		}
+		if _, ok := bySource[src]; !ok {
+			sources = append(sources, src)
+		}
+		bySource[src] = append(bySource[src], i)
+	}
+
+	if len(sources) == 0 {
+		fmt.Println("No chunks with a source_url in metadata; nothing to refresh")
+		return
+	}
+
+	// Changed chunks are embedded with the model the index was built with, since vectors
+	// from another model cannot be compared with the ones kept
+	selectedModel := recordedModel(items)
+	switch {
+	case model != "" && selectedModel != "" && !sameModel(model, selectedModel) && !indexForce:
+		fmt.Printf("%s was embedded with %s, not %s; use --force to embed changed chunks with %s anyway\n",
+			indexEmbeddingsFile, selectedModel, model, model)
+		os.Exit(1)
+	case model != "":
+		selectedModel = model
+	case selectedModel == "":
+		if selectedModel, err = resolveEmbeddingModel(); err != nil {
+			fmt.Printf("Error selecting embedding model: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var extractor *plugin.Manifest
+	if indexExtractor != "" {
+		if pluginLoadErr != nil {
+			fmt.Printf("Error loading plugins: %v\n", pluginLoadErr)
+			os.Exit(1)
+		}
+		extractor = plugin.FindExtractor(loadedPlugins, indexExtractor)
+		if extractor == nil {
+			fmt.Printf("No plugin provides extractor %q\n", indexExtractor)
+			os.Exit(1)
+		}
+	}
+
+	httpClient := &http.Client{Timeout: indexFetchTimeout}
+	stats := refreshStats{Sources: len(sources)}
+	replacements := make(map[string][]outItem)
+
+	refreshRunID := provenance.NewRunID()
+	for _, src := range sources {
+		positions := bySource[src]
+		if !isStale(items, positions, indexTTL) {
+			stats.Fresh++
+			continue
+		}
+
+		if verbose {
+			fmt.Printf("Refreshing %s (%d chunks)\n", src, len(positions))
+		}
+
+		doc, status, err := extract.Fetch(context.Background(), httpClient, src)
+		if status == http.StatusNotFound || status == http.StatusGone {
+			replacements[src] = tombstoneChunks(items, positions)
+			stats.Tombstoned++
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Error fetching %s: %v (keeping existing chunks)\n", src, err)
+			stats.Failed++
+			continue
+		}
+
+		page := extract.FromDocument(src, doc)
+		if extractor != nil {
+			html, _ := doc.Html()
+			p, err := extractor.Extract(context.Background(), indexExtractor, src, html)
+			if err != nil {
+				fmt.Printf("Error extracting %s: %v (keeping existing chunks)\n", src, err)
+				stats.Failed++
+				continue
+			}
+			page = extract.Page{URL: src, Title: p.Title, Content: p.Content}
+		}
+		// Re-chunk with the settings recorded on the page's chunks, so a page chunked with the
+		// profile of its type, or semantically with overlap, keeps them
+		chunking := provenance.Chunking{Strategy: chunker.Strategy, MaxTokens: chunker.DefaultMaxTokens}
+		first := items[positions[0]].Metadata
+		if prev := provenance.Get(first).Chunker; prev != nil && prev.MaxTokens > 0 {
+			chunking = *prev
+		}
+		pageType, _ := first["page_type"].(string)
+		chunks := chunker.Split(page.Content, chunker.Options{Strategy: chunking.Strategy, MaxTokens: chunking.MaxTokens,
+			Overlap: chunking.Overlap, Tokenizer: refreshTokenizer(chunking.Tokenizer), Sections: page.Sections})
+		if len(chunks) == 0 {
+			replacements[src] = tombstoneChunks(items, positions)
+			stats.Tombstoned++
+			continue
+		}
+
+		// Index existing embeddings by content hash so unchanged chunks are reused
+		existing := make(map[string]outItem, len(positions))
+		for _, pos := range positions {
+			it := items[pos]
+			if it.Error == nil && len(it.Embedding) > 0 {
+				existing[chunker.ContentHash(it.Content)] = it
+			}
+		}
+
+		fetchedAt := time.Now().Format(time.RFC3339)
+		prov := provenance.Record{
+			CrawlRunID:       refreshRunID,
+			Crawler:          "index-refresh",
+			FetchedAt:        provenance.Now(),
+			ProcessorVersion: provenance.Version(),
+			Chunker:          &chunking,
+		}
+		changed := len(chunks) != len(positions)
+		refreshed := make([]outItem, 0, len(chunks))
+		for i, sp := range chunks {
+			c := sp.Text
+			hash := chunker.ContentHash(c)
+			metadata := chunker.SpanMetadata(page.Content, sp, page.Sections)
+			metadata["crawled_at"] = fetchedAt
+			metadata["source_url"] = src
+			metadata["title"] = page.Title
+			metadata["content_hash"] = hash
+			metadata["word_count"] = len(strings.Fields(c))
+			metadata["char_count"] = len(c)
+			if pageType != "" {
+				metadata["page_type"] = pageType
+			}
+			if !page.License.IsZero() {
+				metadata[license.MetadataKey] = page.License.Map()
+			}
+			item := outItem{
+				ID:         fmt.Sprintf("%s#chunk_%d", src, i),
+				ChunkIndex: i,
+				Content:    c,
+				Metadata:   metadata,
+			}
+
+			provenance.Update(item.Metadata, prov)
+			if prev, ok := existing[hash]; ok && sameModel(provenance.Get(prev.Metadata).EmbeddingModel, selectedModel) {
+				item.ReuseEmbedding(prev, selectedModel)
+				stats.Reused++
+			} else {
+				changed = true
+				resp, err := llmClient.EmbeddingContext(batchContext(), selectedModel, c)
+				if err != nil {
+					fmt.Printf("Error embedding chunk %d of %s: %v\n", i, src, err)
+					item.Error = vectorstore.NewItemError(err)
+				} else {
+					item.Embedding = resp.Embedding
+				}
+				provenance.Update(item.Metadata, provenance.Record{EmbeddingModel: selectedModel, EmbeddedAt: provenance.Now()})
+				stats.ReEmbedded++
+			}
+			refreshed = append(refreshed, item)
+		}
+
+		replacements[src] = refreshed
+		if changed {
+			stats.Updated++
+		} else {
+			stats.Unchanged++
+		}
+	}
+
+	// Rebuild the output, substituting refreshed sources in place of their old chunks
+	out := make([]outItem, 0, len(items))
+	emitted := make(map[string]bool)
+	for _, it := range items {
+		src := metadataString(it.Metadata, "source_url")
+		repl, ok := replacements[src]
+		if !ok || it.Tombstoned {
+			out = append(out, it)
+			continue
+		}
+		if !emitted[src] {
+			out = append(out, repl...)
+			emitted[src] = true
+		}
+	}
+
+	dest := indexOut
+	if dest == "" {
+		dest = indexEmbeddingsFile
+	}
+	if err := vectorstore.WriteItems(dest, out, encryptOutput); err != nil {
+		fmt.Printf("Error writing output to '%s': %v\n", dest, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Refreshed index written to %s\n", dest)
+	fmt.Printf("Sources: %d (fresh: %d, unchanged: %d, updated: %d, tombstoned: %d, failed: %d)\n",
+		stats.Sources, stats.Fresh, stats.Unchanged, stats.Updated, stats.Tombstoned, stats.Failed)
+	fmt.Printf("Chunks re-embedded: %d, reused: %d\n", stats.ReEmbedded, stats.Reused)
+}
+
+// isStale reports whether the oldest chunk of a source is older than ttl.
+// Chunks without a parseable crawled_at timestamp are always considered stale.
+func isStale(items []outItem, positions []int, ttl time.Duration) bool {
+	for _, pos := range positions {
+		ts := metadataString(items[pos].Metadata, "crawled_at")
+		crawledAt, err := time.Parse(time.RFC3339, ts)
+		if err != nil || time.Since(crawledAt) >= ttl {
+			return true
+		}
+	}
+	return false
+}
+
+// tombstoneChunks marks every chunk of a removed source so search and rag ignore it
+func tombstoneChunks(items []outItem, positions []int) []outItem {
+	now := time.Now().Format(time.RFC3339)
+	out := make([]outItem, 0, len(positions))
+	for _, pos := range positions {
+		it := items[pos]
+		it.Tombstoned = true
+		it.Embedding = nil
+		if it.Metadata == nil {
+			it.Metadata = map[string]interface{}{}
+		}
+		it.Metadata["tombstoned_at"] = now
+		out = append(out, it)
+	}
+	return out
+}
+
+// metadataString returns a string metadata value or "" when absent
+func metadataString(metadata map[string]interface{}, key string) string {
+	if metadata == nil {
+		return ""
+	}
+	s, _ := metadata[key].(string)
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexRefreshCmd)
+	requireServer(indexRefreshCmd, "")
+
+	indexRefreshCmd.Flags().StringVar(&indexEmbeddingsFile, "embeddings", "",
+		"Path to embeddings JSON file to refresh (required)")
+	indexRefreshCmd.Flags().StringVar(&indexOut, "out", "",
+		"Write the refreshed index here instead of updating --embeddings in place")
+	indexRefreshCmd.Flags().DurationVar(&indexTTL, "ttl", 7*24*time.Hour,
+		"Re-crawl sources whose chunks are older than this")
+	indexRefreshCmd.Flags().DurationVar(&indexFetchTimeout, "fetch-timeout", 20*time.Second,
+		"Timeout for fetching each source page")
+	indexRefreshCmd.Flags().StringVar(&indexExtractor, "extractor", "",
+		"Use a plugin extractor instead of the built-in paragraph extraction")
+	indexRefreshCmd.Flags().BoolVar(&indexForce, "force", false,
+		"Embed changed chunks with --model even when the index was embedded with another model")
+
+	indexRefreshCmd.MarkFlagRequired("embeddings")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -18,7 +19,7 @@ var modelsCmd = &cobra.Command{
 }
 
 func runModelsCommand(cmd *cobra.Command, args []string) {
-	models, err := ollamaClient.ListModels()
+	models, err := llmClient.ListModels()
 	if err != nil {
 		fmt.Printf("Error getting models: %v\n", err)
 		os.Exit(1)
@@ -32,28 +33,51 @@ func runModelsCommand(cmd *cobra.Command, args []string) {
 	fmt.Println("Available models:")
 	fmt.Println("=================")
 
+	// What the server reports about each model, where it can; names alone otherwise
+	facts := make(map[string]config.ModelFacts)
+	if list, err := llmClient.ModelFacts(context.Background(), models); err == nil {
+		for _, f := range list {
+			facts[f.Name] = f
+		}
+	} else if verbose {
+		fmt.Printf("Could not describe models: %v\n", err)
+	}
+
 	for _, modelName := range models {
 		modelInfo, hasInfo := config.GetModelInfo(modelName)
+		f, hasFacts := facts[modelName]
+		fmt.Printf("\n📦 %s\n", modelName)
 		if hasInfo {
-			fmt.Printf("\n📦 %s\n", modelName)
 			fmt.Printf("   Description: %s\n", modelInfo.Description)
 			fmt.Printf("   Priority: %d\n", modelInfo.Priority)
+		} else if !hasFacts {
+			fmt.Printf("   Description: Unknown model\n")
+		}
+		if hasFacts && f.ParameterSize != "" {
+			fmt.Printf("   Size: %s parameters", f.ParameterSize)
+			if f.Quantization != "" {
+				fmt.Printf(", %s", f.Quantization)
+			}
+			fmt.Println()
+		}
+		capabilities := modelInfo.Capabilities
+		if hasFacts {
+			capabilities = config.CapabilitiesOf(f)
+		}
+		if len(capabilities) > 0 {
 			fmt.Printf("   Capabilities: ")
-			for i, cap := range modelInfo.Capabilities {
+			for i, cap := range capabilities {
 				if i > 0 {
 					fmt.Print(", ")
 				}
 				fmt.Printf("%s", cap)
 			}
 			fmt.Println()
-		} else {
-			fmt.Printf("\n📦 %s\n", modelName)
-			fmt.Printf("   Description: Unknown model\n")
 		}
 	}
 
 	fmt.Printf("\n\nRecommended for coding tasks: ")
-	bestCoding := config.SelectBestModel(models, config.CapabilityCode)
+	bestCoding := llmClient.SelectModelByCapability(models, string(config.CapabilityCode))
 	if bestCoding != "" {
 		fmt.Printf("%s ✨\n", bestCoding)
 	} else {
@@ -61,7 +85,7 @@ func runModelsCommand(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Printf("Recommended for embeddings: ")
-	bestEmbedding := config.SelectBestModel(models, config.CapabilityEmbedding)
+	bestEmbedding := llmClient.SelectModelByCapability(models, string(config.CapabilityEmbedding))
 	if bestEmbedding != "" {
 		fmt.Printf("%s ✨\n", bestEmbedding)
 	} else {
@@ -71,4 +95,5 @@ func runModelsCommand(cmd *cobra.Command, args []string) {
 
 func init() {
 	rootCmd.AddCommand(modelsCmd)
+	requireServer(modelsCmd, "")
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/workspace"
+	"kirk-ai/pkg/ollamatest"
+)
+
+// runCLI runs the command line args and returns what it printed
+func runCLI(t *testing.T, args ...string) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	w.Close()
+	os.Stdout = stdout
+	printed := <-out
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", strings.Join(args, " "), err, printed)
+	}
+	return printed
+}
+
+func TestModelCommands(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.WithModels("gemma3:4b"))
+	defer srv.Close()
+	t.Setenv(config.HomeEnvVar, t.TempDir())
+	t.Setenv(workspace.EnvVar, t.TempDir())
+
+	steps := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"pull", "embeddinggemma"}, []string{"Pulled embeddinggemma (4 MB"}},
+		{[]string{"show", "embeddinggemma"}, []string{"embeddinggemma:latest", "Family:", "bert", "8 dimensions"}},
+		{[]string{"show", "gemma3"}, []string{"gemma3:4b", "8192 used (num_ctx)", "num_ctx 8192"}},
+		{[]string{"warmup", "gemma3"}, []string{"Loaded gemma3:4b", "In memory:", "  gemma3:4b:"}},
+		{[]string{"rm", "embeddinggemma"}, []string{"Deleted embeddinggemma:latest"}},
+	}
+	for _, step := range steps {
+		out := runCLI(t, append([]string{"--url", srv.URL}, step.args...)...)
+		for _, want := range step.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output lacks %q:\n%s", strings.Join(step.args, " "), want, out)
+			}
+		}
+	}
+
+	if got, want := srv.Models(), []string{"gemma3:4b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("installed models %v, want %v", got, want)
+	}
+	if got, want := srv.Running(), []string{"gemma3:4b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("running models %v, want %v", got, want)
+	}
+}
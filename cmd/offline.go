@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"kirk-ai/internal/client"
+
+	"github.com/spf13/cobra"
+)
+
+// needsServer is the annotation marking a command that cannot run without the LLM server.
+// Its value names a flag that lets the command run offline, or is empty.
+const needsServer = "needs-server"
+
+// requireServer marks cmd as needing the server, unless offlineFlag (if not empty) is given
+func requireServer(cmd *cobra.Command, offlineFlag string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[needsServer] = offlineFlag
+}
+
+// offlineCommands are what still works with the server down, shown by the unreachable report
+var offlineCommands = [][2]string{
+	{"search --keyword", "rank chunks by keyword (BM25) without embedding the query"},
+	{"embed --file ... --dry-run", "show what would be embedded"},
+	{"embeddings index", "build the approximate and keyword indexes"},
+	{"embeddings cluster --no-label", "group chunks into topics"},
+	{"collections, snapshot", "manage collections and snapshots"},
+	{"corpus diff", "compare two crawl runs"},
+	{"benchmark compare", "compare two saved benchmark runs"},
+}
+
+// checkServer makes sure the server answers before a command that needs it runs, and
+// otherwise prints what was tried and what to do instead of letting the command fail with
+// a raw network error
+func checkServer(cmd *cobra.Command) {
+	offlineFlag, ok := cmd.Annotations[needsServer]
+	if !ok || (offlineFlag != "" && cmd.Flags().Changed(offlineFlag)) {
+		return
+	}
+	providerName := commandProvider(cmd)
+	r := client.Probe(context.Background(), serverURL(cmd, providerName), client.DefaultProbeTimeout)
+	if r.OK() {
+		return
+	}
+	printUnreachable(r, providerName)
+	os.Exit(1)
+}
+
+// printUnreachable explains why the server at r.URL could not be reached
+func printUnreachable(r client.Reachability, providerName string) {
+	name := "Ollama"
+	if providerName == client.ProviderOpenAI {
+		name = "OpenAI-compatible"
+	}
+	fmt.Printf("Error: cannot reach the %s server at %s\n", name, r.URL)
+	switch {
+	case r.Address == "":
+		fmt.Printf("  The URL is not valid: %v\n", r.Err)
+	case !r.Resolved:
+		fmt.Printf("  The host name could not be resolved: %v\n", r.Err)
+	case !r.PortOpen && errors.Is(r.Err, syscall.ECONNREFUSED):
+		fmt.Printf("  Nothing is listening on %s (connection refused).\n", r.Address)
+	case !r.PortOpen && r.TimedOut():
+		fmt.Printf("  %s did not answer within %s; the host may be down or a firewall may drop the connection.\n", r.Address, client.DefaultProbeTimeout)
+	case !r.PortOpen:
+		fmt.Printf("  Could not connect to %s: %v\n", r.Address, r.Err)
+	default:
+		fmt.Printf("  Port %s is open, but the server did not answer HTTP: %v\n", r.Address, r.Err)
+		fmt.Println("  Check that --url points at the right server (and http vs https) and that --provider matches it.")
+	}
+
+	fmt.Println()
+	if providerName == client.ProviderOpenAI {
+		fmt.Println("To fix: start your server (llama.cpp, vLLM, LM Studio, ...) or point --url at a running one, including /v1.")
+	} else {
+		fmt.Println("To fix: start Ollama with 'ollama serve' (or open the Ollama app), or point --url at a running server.")
+		if host, _, err := net.SplitHostPort(r.Address); err == nil && r.Resolved && !r.PortOpen && host != "localhost" && !strings.HasPrefix(host, "127.") {
+			fmt.Println("  A remote Ollama must listen beyond localhost: set OLLAMA_HOST=0.0.0.0 on that machine.")
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("These commands work without a server:")
+	for _, c := range offlineCommands {
+		fmt.Printf("  %-32s %s\n", c[0], c[1])
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/plugin"
+
+	"github.com/spf13/cobra"
+)
+
+// pluginDirEnvVar overrides the plugin discovery directory (default ~/.kirk-ai/plugins)
+const pluginDirEnvVar = "KIRK_AI_PLUGIN_DIR"
+
+var (
+	loadedPlugins []*plugin.Manifest
+	pluginLoadErr error
+
+	pluginFetchOut string
+)
+
+// pluginsCmd represents the plugins command
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List plugins and run plugin ingestion sources",
+	Long: `Plugins extend kirk-ai without forking it. Each plugin is a directory under
+~/.kirk-ai/plugins (or $KIRK_AI_PLUGIN_DIR) containing a plugin.json manifest and an
+executable that speaks JSON-RPC 2.0 over stdin/stdout. A plugin can contribute top-level
+commands, ingestion sources, and HTML extractors.`,
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List discovered plugins and what they provide",
+	Args:  cobra.NoArgs,
+	Run:   runPluginsListCommand,
+}
+
+var pluginsFetchCmd = &cobra.Command{
+	Use:   "fetch [source] [args...]",
+	Short: "Fetch documents from a plugin ingestion source",
+	Long: `Run a plugin ingestion source and write the returned pages as JSON in the same
+format as the crawler's processed pages, ready for the processor's embedprep step.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runPluginsFetchCommand,
+}
+
+// pluginDir returns the directory plugins are discovered from
+func pluginDir() string {
+	if dir := os.Getenv(pluginDirEnvVar); dir != "" {
+		return dir
+	}
+	return config.Path("plugins")
+}
+
+// registerPluginCommands discovers plugins and adds their commands to the root command.
+// Commands that would shadow a built-in command are skipped.
+func registerPluginCommands() {
+	loadedPlugins, pluginLoadErr = plugin.Discover(pluginDir())
+	for _, p := range loadedPlugins {
+		for _, spec := range p.Commands {
+			if existing, _, err := rootCmd.Find([]string{spec.Name}); err == nil && existing != rootCmd {
+				continue
+			}
+			rootCmd.AddCommand(newPluginCommand(p, spec))
+		}
+	}
+}
+
+func newPluginCommand(p *plugin.Manifest, spec plugin.CommandSpec) *cobra.Command {
+	short := spec.Short
+	if short == "" {
+		short = fmt.Sprintf("Provided by plugin %s", p.Name)
+	}
+	return &cobra.Command{
+		Use:                spec.Name,
+		Short:              short,
+		Long:               spec.Long,
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			res, err := p.RunCommand(context.Background(), spec.Name, args)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(res.Output)
+			if res.Output != "" && !strings.HasSuffix(res.Output, "\n") {
+				fmt.Println()
+			}
+			if res.ExitCode != 0 {
+				os.Exit(res.ExitCode)
+			}
+		},
+	}
+}
+
+func runPluginsListCommand(cmd *cobra.Command, args []string) {
+	if pluginLoadErr != nil {
+		fmt.Printf("Error loading plugins: %v\n", pluginLoadErr)
+		os.Exit(1)
+	}
+	if len(loadedPlugins) == 0 {
+		fmt.Printf("No plugins found in %s\n", pluginDir())
+		return
+	}
+
+	fmt.Printf("Plugins in %s:\n", pluginDir())
+	for _, p := range loadedPlugins {
+		fmt.Printf("\n%s", p.Name)
+		if p.Description != "" {
+			fmt.Printf(" - %s", p.Description)
+		}
+		fmt.Println()
+		for _, spec := range p.Commands {
+			fmt.Printf("  command:   %s\n", spec.Name)
+		}
+		for _, s := range p.Sources {
+			fmt.Printf("  source:    %s\n", s)
+		}
+		for _, x := range p.Extractors {
+			fmt.Printf("  extractor: %s\n", x)
+		}
+	}
+}
+
+func runPluginsFetchCommand(cmd *cobra.Command, args []string) {
+	if pluginLoadErr != nil {
+		fmt.Printf("Error loading plugins: %v\n", pluginLoadErr)
+		os.Exit(1)
+	}
+	source := args[0]
+	p := plugin.FindSource(loadedPlugins, source)
+	if p == nil {
+		fmt.Printf("No plugin provides ingestion source %q\n", source)
+		os.Exit(1)
+	}
+
+	pages, err := p.Fetch(context.Background(), source, args[1:])
+	if err != nil {
+		fmt.Printf("Error fetching from %s: %v\n", source, err)
+		os.Exit(1)
+	}
+
+	b, err := json.MarshalIndent(pages, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding pages: %v\n", err)
+		os.Exit(1)
+	}
+	if pluginFetchOut == "" {
+		fmt.Println(string(b))
+		return
+	}
+	if err := atomicfile.WriteFile(pluginFetchOut, b, 0o644, true); err != nil {
+		fmt.Printf("Error writing output to '%s': %v\n", pluginFetchOut, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d pages from %s to %s\n", len(pages), source, pluginFetchOut)
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+	pluginsCmd.AddCommand(pluginsListCmd)
+	pluginsCmd.AddCommand(pluginsFetchCmd)
+
+	pluginsFetchCmd.Flags().StringVar(&pluginFetchOut, "out", "",
+		"Write pages JSON to this file instead of stdout")
+}
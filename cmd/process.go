@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"kirk-ai/internal/process"
+
+	"github.com/spf13/cobra"
+)
+
+// processCmd represents the process command
+var processCmd = &cobra.Command{
+	Use:   "process",
+	Short: "Turn crawl results into pages and chunks ready to embed",
+	Long: `Prepare crawled pages for embedding in two steps: content cleans the raw HTML the crawlers
+saved into processed_data/processed_pages.json in the workspace (tpusa_crawl/ by default), and
+embedprep splits those pages into chunks in embeddings/tpusa_embeddings_ready.json, which embed
+then reads.`,
+}
+
+func init() {
+	rootCmd.AddCommand(processCmd)
+	process.Chat = func(model, prompt string) (string, error) {
+		resp, err := llmClient.Chat(model, prompt)
+		if err != nil {
+			return "", err
+		}
+		return resp.Message.Content, nil
+	}
+	for _, t := range process.Tools {
+		processCmd.AddCommand(toolCommand(t.Name, t.Short, t.Setup, nil))
+	}
+}
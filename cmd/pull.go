@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/progress"
+
+	"github.com/spf13/cobra"
+)
+
+var pullCmd = &cobra.Command{
+	Use:   "pull <model...>",
+	Short: "Download models to the Ollama server",
+	Long: `Download models from the Ollama registry, as 'ollama pull' does, showing the download's
+progress. Pulling an installed model fetches its newest version, downloading only changed layers.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runPullCommand,
+}
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <model...>",
+	Short: "Delete models from the Ollama server",
+	Long: `Delete installed models from the Ollama server's disk, as 'ollama rm' does. Names must match an
+installed model exactly; the ":latest" tag may be left out.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runRmCommand,
+}
+
+func runPullCommand(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for _, name := range args {
+		if err := pullModel(ctx, name); err != nil {
+			fmt.Printf("Error pulling %s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func runRmCommand(cmd *cobra.Command, args []string) {
+	installed, err := llmClient.ListModels()
+	if err != nil {
+		fmt.Printf("Error getting models: %v\n", err)
+		os.Exit(1)
+	}
+	failed := false
+	for _, arg := range args {
+		name := installedName(installed, arg)
+		if name == "" {
+			fmt.Printf("Error: model %q is not installed\n", arg)
+			failed = true
+			continue
+		}
+		if err := llmClient.Delete(context.Background(), name); err != nil {
+			fmt.Printf("Error deleting %s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Deleted %s\n", name)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// installedName returns the installed model named name, with or without its ":latest" tag,
+// or "" when none is
+func installedName(installed []string, name string) string {
+	for _, m := range installed {
+		if m == name || m == name+":latest" {
+			return m
+		}
+	}
+	return ""
+}
+
+// pullModel pulls name, showing the download's progress in megabytes
+func pullModel(ctx context.Context, name string) error {
+	const mb = 1 << 20
+	bar := progress.New(os.Stdout, "Pulling "+name+" (MB)", 0)
+	layers := make(map[string]int64) // megabytes reported done, by layer digest
+	err := llmClient.Pull(ctx, name, func(p models.PullProgress) {
+		if p.Digest == "" {
+			if verbose {
+				fmt.Fprintln(bar, p.Status)
+			}
+			return
+		}
+		done, seen := layers[p.Digest]
+		if !seen && p.Total > 0 {
+			bar.AddTotal(int((p.Total + mb - 1) / mb))
+		}
+		if now := p.Completed / mb; now > done {
+			bar.Add(int(now - done))
+			layers[p.Digest] = now
+		} else if !seen {
+			layers[p.Digest] = 0
+		}
+	})
+	summary := bar.Finish()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pulled %s (%d MB in %v)\n", name, summary.Total, summary.Elapsed.Round(100*time.Millisecond))
+	return nil
+}
+
+// selectEmbeddingModel selects the installed embedding model, first pulling the recommended
+// one when the server reports none installed, unless --no-pull is set. installed lists the
+// installed models.
+func selectEmbeddingModel(installed []string) (string, error) {
+	if facts, err := llmClient.ModelFacts(context.Background(), installed); err == nil && !hasEmbeddingModel(facts) {
+		recommended := config.RecommendedModel(config.CapabilityEmbedding)
+		if noPull || recommended == "" {
+			return "", fmt.Errorf("no embedding model installed; pull one with 'kirk-ai pull %s'", recommended)
+		}
+		fmt.Printf("No embedding model installed; pulling %s (--no-pull to skip)\n", recommended)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := pullModel(ctx, recommended); err != nil {
+			return "", fmt.Errorf("pulling %s: %w", recommended, err)
+		}
+		installed = append(installed, recommended)
+	}
+	if selected := llmClient.SelectEmbeddingModel(installed); selected != "" {
+		return selected, nil
+	}
+	return "", fmt.Errorf("no suitable embedding model found")
+}
+
+// hasEmbeddingModel reports whether any of the described models suits embeddings
+func hasEmbeddingModel(facts []config.ModelFacts) bool {
+	for _, f := range facts {
+		for _, c := range config.CapabilitiesOf(f) {
+			if c == config.CapabilityEmbedding {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(pullCmd)
+	rootCmd.AddCommand(rmCmd)
+	requireServer(pullCmd, "")
+	requireServer(rmCmd, "")
+}
@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
-	"kirk-ai/internal/client"
+	"kirk-ai/internal/chunker"
 	"kirk-ai/internal/models"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/rag"
+	"kirk-ai/internal/route"
+	"kirk-ai/internal/vectorstore"
 
 	"github.com/spf13/cobra"
 )
@@ -18,44 +25,128 @@ var (
 	ragContextSize         int
 	ragSimilarityThreshold float64
 	ragMaxContextLength    int
+	ragMaxContextTokens    int
 	ragProgressive         bool
-	ragTimeout             int
 	ragPreferFast          bool   // new flag: prefer faster models for lower latency
 	ragModel               string // new flag: explicit chat model to use for RAG (was ragChatModel)
+	ragCollection          string
+	ragLinkGraph           string
+	ragAuthority           float64
+	ragAttribution         bool
+	ragCitations           bool
+	ragCiteInline          bool
+	ragJSON                bool
+	ragInteractive         bool
+	ragAnswerTokens        int
+	ragTokenizer           string
+	ragTopicShift          float64
 )
 
 var ragCmd = &cobra.Command{
 	Use:   "rag [question]",
 	Short: "Answer questions using retrieval-augmented generation",
-	Long:  `Use semantic search to find relevant context from embeddings and generate informed answers using RAG (Retrieval-Augmented Generation).`,
-	Args:  cobra.MinimumNArgs(1),
-	Run:   runRAGCommand,
+	Long: `Use semantic search to find relevant context from embeddings and generate informed answers using RAG (Retrieval-Augmented Generation).
+
+With --interactive, hold a conversation instead: follow-up questions are answered from the
+context already retrieved together with the answers so far, a question on another topic
+retrieves a new context, and slash commands (/sources, /source, /page) show and widen it.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if ragInteractive {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: runRAGCommand,
+}
+
+// ragJSONOutput is what rag prints with --json
+type ragJSONOutput struct {
+	Question    string         `json:"question"`
+	Answer      string         `json:"answer"`
+	Citations   []rag.Citation `json:"citations,omitempty"`
+	Attribution string         `json:"attribution,omitempty"`
+	Threshold   float64        `json:"threshold"`
+	RoutedTo    []route.Choice `json:"routed_to,omitempty"` // collections picked by --route
+	Error       string         `json:"error,omitempty"`     // why no answer was generated
+}
+
+func printRAGJSON(out ragJSONOutput) {
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding output: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(b))
 }
 
 func runRAGCommand(cmd *cobra.Command, args []string) {
 	start := time.Now()
 	question := strings.Join(args, " ")
 
-	if ragEmbeddingsFile == "" {
-		fmt.Println("Please specify embeddings file with --embeddings flag")
+	if ragRoute && (ragEmbeddingsFile != "" || ragCollection != "" || chunksFile != "") {
+		fmt.Println("--route picks the collection itself; drop --embeddings, --collection, and --chunks")
+		os.Exit(1)
+	}
+	if ragEmbeddingsFile == "" && ragCollection == "" && chunksFile == "" && !ragRoute {
+		fmt.Println("Please specify embeddings file with --embeddings flag, a collection with --collection, or chunks with --chunks")
 		os.Exit(1)
 	}
+	if chunksFile != "" && (ragEmbeddingsFile != "" || ragCollection != "") {
+		fmt.Println("--chunks cannot be combined with --embeddings or --collection")
+		os.Exit(1)
+	}
+	if ragInteractive && (ragRoute || ragJSON) {
+		fmt.Println("--interactive cannot be combined with --route or --json")
+		os.Exit(1)
+	}
+	if ragJSON {
+		// Streamed chunks would interleave with the JSON document
+		stream = false
+	}
+	if ragCiteInline {
+		ragCitations = true
+	}
+	checkRerankFlags()
+	applyReplyLang()
+	applyRedact()
+	if ragInteractive {
+		runRAGREPL(question, cmd.Flags().Changed("max-context-length"))
+		return
+	}
 
 	// Load embeddings with content
 	loadStart := time.Now()
-	embeddings, err := loadEmbeddings(ragEmbeddingsFile)
+	var corp *corpus
+	var routed []route.Choice
+	var err error
+	if ragRoute {
+		choices, routeErr := routeQuestion(question)
+		if routeErr != nil {
+			fmt.Printf("Error routing question: %v\n", routeErr)
+			os.Exit(1)
+		}
+		corp, routed, err = loadRoutedCorpus(choices)
+		if err == nil && !ragJSON {
+			fmt.Printf("Routed to %s\n", formatRouted(routed))
+		}
+	} else if chunksFile != "" {
+		corp, err = loadAdHocCorpus(chunksFile, true, !ragJSON)
+	} else {
+		corp, err = loadSearchCorpus(ragEmbeddingsFile, ragCollection)
+	}
 	if err != nil {
 		fmt.Printf("Error loading embeddings: %v\n", err)
 		os.Exit(1)
 	}
 
 	if verbose {
-		fmt.Printf("Loaded %d embeddings for RAG in %v\n", len(embeddings), time.Since(loadStart))
+		fmt.Printf("Loaded %d embeddings for RAG in %v\n", corp.Len(), time.Since(loadStart))
 	}
+	corp.setFilter(searchFilters)
 
 	// Generate embedding for question
 	embedStart := time.Now()
-	queryEmbedding, err := generateQueryEmbedding(question)
+	queryEmbedding, err := corp.embedQuery(context.Background(), question, queryEmbedModel)
 	if err != nil {
 		fmt.Printf("Error generating query embedding: %v\n", err)
 		os.Exit(1)
@@ -82,6 +173,9 @@ func runRAGCommand(cmd *cobra.Command, args []string) {
 		// Only override threshold if user didn't specify one explicitly
 		if ragSimilarityThreshold == 0.0 {
 			similarityThreshold = 0.5 // More aggressive filtering for progressive loading
+			if corp.calibration != nil {
+				similarityThreshold = corp.calibration.RAGStrict
+			}
 		}
 		if verbose {
 			fmt.Printf("Using progressive context loading: starting with %d chunks (threshold: %.2f)\n", contextSize, similarityThreshold)
@@ -90,16 +184,16 @@ func runRAGCommand(cmd *cobra.Command, args []string) {
 
 	// Dynamic similarity threshold based on context size
 	if similarityThreshold == 0.0 {
-		if ragContextSize > 20 {
-			similarityThreshold = 0.5 // More aggressive for large contexts
-		} else {
-			similarityThreshold = 0.3 // Default threshold
-		}
+		similarityThreshold = ragThreshold(corp, ragContextSize)
 	}
 
 	// Search for relevant context
 	searchStart := time.Now()
-	results := searchSimilar(queryEmbedding, embeddings, contextSize, similarityThreshold)
+	results, err := retrieveContext(corp, question, queryEmbedding, contextSize, similarityThreshold)
+	if err != nil {
+		fmt.Printf("Error %v\n", err)
+		os.Exit(1)
+	}
 
 	if verbose {
 		fmt.Printf("Search completed in %v (found %d results with threshold %.2f)\n",
@@ -107,6 +201,10 @@ func runRAGCommand(cmd *cobra.Command, args []string) {
 	}
 
 	if len(results) == 0 {
+		if ragJSON {
+			printRAGJSON(ragJSONOutput{Question: question, Threshold: similarityThreshold, RoutedTo: routed, Error: "no relevant context found"})
+			return
+		}
 		fmt.Printf("No relevant context found for question: %s\n", question)
 		fmt.Printf("Try lowering the similarity threshold (current: %.2f) or asking a different question.\n", similarityThreshold)
 		return
@@ -114,72 +212,45 @@ func runRAGCommand(cmd *cobra.Command, args []string) {
 
 	// Build context with length limit
 	contextStart := time.Now()
-	var contextParts []string
-	var usedResults []searchResult
-	totalLength := 0
 	maxLength := ragMaxContextLength
 	if maxLength == 0 {
-		maxLength = 8000 // Default max context length
+		maxLength = rag.DefaultMaxContextLength
 	}
-
-	seenKeys := map[string]bool{}
-	for _, result := range results {
-		// Deduplicate by ID or content prefix
-		key := result.Item.ID
-		if key == "" {
-			key = getContentFromEmbedding(result.Item)
-			if key == "" {
-				key = fmt.Sprintf("chunk_%d", result.Item.ChunkIndex)
-			}
-			if len(key) > 200 {
-				key = key[:200]
-			}
-		}
-		if seenKeys[key] {
-			continue
-		}
-		seenKeys[key] = true
-
-		content := getContentFromEmbedding(result.Item)
-		if content != "" {
-			remaining := maxLength - totalLength
-			if remaining <= 0 {
-				break
-			}
-			if len(content) > remaining {
-				if remaining > 100 { // Only add if meaningful
-					content = content[:remaining] + "..."
-					contextParts = append(contextParts, content)
-					totalLength += len(content)
-					usedResults = append(usedResults, result)
-				}
-				break
-			}
-			contextParts = append(contextParts, content)
-			totalLength += len(content)
-			usedResults = append(usedResults, result)
-		}
+	buildContext, buildPrompt := rag.BuildContext, rag.BuildPrompt
+	if ragCiteInline {
+		// Number the chunks and ask the model to cite them as [n]
+		buildContext, buildPrompt = rag.BuildCitedContext, rag.BuildCitedPrompt
+	}
+	// The context is sized in tokens to the chat model's window, beside the prompt and the
+	// answer; the character cap then applies only when given or when the window is unknown
+	selectedModel, err := selectRAGModel(ragModel, ragPreferFast)
+	if err != nil {
+		fmt.Printf("Error selecting model: %v\n", err)
+		os.Exit(1)
 	}
+	budget, contextTokens, windowed := contextBudget(selectedModel, buildPrompt(question, ""))
+	results = budget.Fit(results, contextTokens)
+	if windowed && !cmd.Flags().Changed("max-context-length") {
+		maxLength = math.MaxInt32
+	}
+	context, usedResults := buildContext(results, maxLength)
 
-	if len(contextParts) == 0 {
+	if len(usedResults) == 0 {
+		if ragJSON {
+			printRAGJSON(ragJSONOutput{Question: question, Threshold: similarityThreshold, RoutedTo: routed, Error: "no content available for context"})
+			return
+		}
 		fmt.Println("Found similar embeddings but no content available for context.")
 		fmt.Println("Make sure your embeddings file includes content data.")
 		return
 	}
 
-	context := strings.Join(contextParts, "\n\n")
-
-	// Extra safety: final truncate to avoid exceeding max
-	if len(context) > maxLength {
-		context = context[:maxLength]
-	}
-
 	if verbose {
 		fmt.Printf("Context built in %v (%d characters, %d chunks, %d duplicates removed)\n",
-			time.Since(contextStart), len(context), len(contextParts), len(results)-len(usedResults))
+			time.Since(contextStart), len(context), len(usedResults), len(results)-len(usedResults))
 	}
 
-	// Generate answer using context with custom timeout if specified
+	// Generate answer using context.
 	// If streaming is enabled, stream the response and print chunks as they arrive.
 	if stream {
 		// Show a waiting message while the model prepares; the actual "Answer:" label
@@ -187,8 +258,24 @@ func runRAGCommand(cmd *cobra.Command, args []string) {
 		fmt.Println("Thinking...")
 	}
 
+	out, err := openTee()
+	if err != nil {
+		fmt.Printf("Error opening --tee file: %v\n", err)
+		os.Exit(1)
+	}
 	answerStart := time.Now()
-	answer, err := generateRAGAnswerWithTimeout(question, context, time.Duration(ragTimeout)*time.Second)
+	answer, err := generateRAGAnswer(buildPrompt(question, context), out)
+	out.Write("\n")
+	citations := rag.Citations(usedResults)
+	if err == nil && ragCitations {
+		out.Write("\nSources:\n" + rag.FormatCitations(citations))
+	}
+	if err == nil && ragAttribution {
+		out.Write("\nAttribution:\n" + rag.Attribution(usedResults))
+	}
+	if teeErr := out.Close(); teeErr != nil {
+		fmt.Printf("Error writing --tee file: %v\n", teeErr)
+	}
 	if err != nil {
 		fmt.Printf("Error generating answer: %v\n", err)
 		os.Exit(1)
@@ -198,12 +285,30 @@ func runRAGCommand(cmd *cobra.Command, args []string) {
 		fmt.Printf("Answer generated in %v\n", time.Since(answerStart))
 	}
 
+	if ragJSON {
+		res := ragJSONOutput{Question: question, Answer: answer, Threshold: similarityThreshold, RoutedTo: routed}
+		if ragCitations {
+			res.Citations = citations
+		}
+		if ragAttribution {
+			res.Attribution = rag.Attribution(usedResults)
+		}
+		printRAGJSON(res)
+		return
+	}
+
 	// Display results
 	// Do not print the user's question to avoid including 'Question: ...' in the output
 	fmt.Println(strings.Repeat("=", 60))
 	if !stream {
 		fmt.Printf("Answer: %s\n", answer)
 	}
+	if ragCitations {
+		fmt.Printf("\nSources:\n%s", rag.FormatCitations(citations))
+	}
+	if ragAttribution {
+		fmt.Printf("\nAttribution:\n%s", rag.Attribution(usedResults))
+	}
 
 	if verbose {
 		fmt.Printf("\nPerformance Summary:\n")
@@ -212,74 +317,113 @@ func runRAGCommand(cmd *cobra.Command, args []string) {
 		for i, result := range usedResults {
 			fmt.Printf("  [%d] Chunk %d (similarity: %.3f)\n",
 				i+1, result.Item.ChunkIndex, result.Similarity)
+			if src := vectorstore.DeepLink(result.Item); src != "" {
+				fmt.Printf("      source: %s\n", src)
+			}
+			if section := metadataString(result.Item.Metadata, "section"); section != "" {
+				fmt.Printf("      section: %s\n", section)
+			}
+			if prov := provenance.Get(result.Item.Metadata).String(); prov != "" {
+				fmt.Printf("      provenance: %s\n", prov)
+			}
+		}
+		if windowed {
+			fmt.Printf("- Context length: %d characters, about %d tokens (max: %d tokens)\n", len(context), budget.Tokens(context), contextTokens)
+		} else {
+			fmt.Printf("- Context length: %d characters (max: %d)\n", len(context), maxLength)
 		}
-		fmt.Printf("- Context length: %d characters (max: %d)\n", len(context), maxLength)
 	}
 }
 
-func getContentFromEmbedding(item embeddingItem) string {
-	// First try direct content field
-	if item.Content != "" {
-		return item.Content
+// retrieveContext finds the chunks to answer question with: a vector search, merged with
+// keyword results and weighted by link authority and source trust as configured, then
+// reranked down to contextSize
+func retrieveContext(corp *corpus, question string, queryEmbedding []float64, contextSize int, threshold float64) ([]searchResult, error) {
+	candidates := candidateCount(contextSize, ragAuthority)
+	results, err := corp.Search(queryEmbedding, candidates, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("searching embeddings: %w", err)
 	}
-
-	// Try to extract content from metadata
-	if item.Metadata != nil {
-		if content, ok := item.Metadata["content"].(string); ok && content != "" {
-			return content
-		}
+	results, err = hybridResults(corp, question, queryEmbedding, results, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("searching by keyword: %w", err)
 	}
-
-	return ""
-}
-
-func generateRAGAnswerWithTimeout(question, context string, timeout time.Duration) (string, error) {
-	// Select chat model optimized for RAG
-	modelsList, err := ollamaClient.ListModels()
+	results, err = applyAuthority(results, ragLinkGraph, ragAuthority, rerankPool(contextSize, candidates))
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("loading link graph: %w", err)
 	}
+	results, err = applySourceTrust(results, rerankPool(contextSize, candidates))
+	if err != nil {
+		return nil, fmt.Errorf("weighting sources: %w", err)
+	}
+	results, err = rerankResults(question, results, contextSize)
+	if err != nil {
+		return nil, fmt.Errorf("reranking context: %w", err)
+	}
+	return results, nil
+}
 
-	// Honor explicit chat model flag if provided
-	var selectedModel string
-	if ragModel != "" {
-		// Try to match the provided model string against available models (exact or substring, case-insensitive)
-		for _, m := range modelsList {
-			if strings.EqualFold(m, ragModel) || strings.Contains(strings.ToLower(m), strings.ToLower(ragModel)) {
-				selectedModel = m
-				break
-			}
-		}
-		if selectedModel == "" {
-			return "", fmt.Errorf("requested model %q not found. Available models: %v", ragModel, modelsList)
+// contextBudget sizes the context for the chat model: how many tokens of it fit in the
+// model's context window beside prompt, everything sent but the context, and the answer,
+// and no more than --max-context-tokens. windowed reports whether the window could be read
+// from the server; when it cannot, the count is --max-context-tokens alone.
+func contextBudget(selectedModel, prompt string) (b rag.Budget, tokens int, windowed bool) {
+	b.Answer = ragAnswerTokens
+	if o := llmClient.Options; o != nil && o.NumPredict != nil && *o.NumPredict > 0 {
+		b.Answer = *o.NumPredict
+	}
+	if ragTokenizer != "" {
+		tok, err := chunker.LoadTokenizer(ragTokenizer)
+		if err != nil {
+			fmt.Printf("Error loading --tokenizer: %v\n", err)
+			os.Exit(1)
 		}
-	} else {
-		// Use RAG-optimized model selection
-		selectedModel = ollamaClient.SelectModelByCapability(modelsList, "rag")
-		if ragPreferFast {
-			// Prefer smaller/faster model candidates when requested
-			fastCandidates := []string{"1b", "2.5", "qwen2.5", "llama3", "mistral", "gemma2"}
-			for _, pref := range fastCandidates {
-				for _, m := range modelsList {
-					if strings.Contains(strings.ToLower(m), strings.ToLower(pref)) {
-						selectedModel = m
-						break
-					}
-				}
-				if selectedModel != "" {
-					break
-				}
-			}
+		b.Count = tok.Count
+	}
+	window, trained, err := llmClient.ContextWindow(context.Background(), selectedModel)
+	if err != nil {
+		if verbose {
+			fmt.Printf("Could not read the context window of %s (%v); sizing the context by characters\n", selectedModel, err)
 		}
+		return b, ragMaxContextTokens, false
+	}
+	b.Window = window
+	tokens = b.ContextTokens(llmClient.System + "\n" + prompt)
+	if verbose {
+		fmt.Printf("Context window of %s: %d tokens (trained with %d): %d for the answer, up to %d for context\n",
+			selectedModel, window, trained, b.Answer, tokens)
+	}
+	if tokens == 0 {
+		fmt.Printf("Warning: the prompt and answer fill the %d-token context window of %s; only the best chunk is used. Raise it with --num-ctx.\n", window, selectedModel)
+		tokens = 1
+	}
+	if ragMaxContextTokens > 0 && ragMaxContextTokens < tokens {
+		tokens = ragMaxContextTokens
+	}
+	return b, tokens, true
+}
 
-		if selectedModel == "" {
-			// Fallback to regular chat model
-			selectedModel = selectChatModel(modelsList)
-		}
+// ragThreshold is the similarity threshold for RAG context when none is given: the corpus's
+// calibrated threshold, else one that gets stricter for large contexts
+func ragThreshold(corp *corpus, contextSize int) float64 {
+	switch {
+	case corp.calibration != nil && contextSize > 20:
+		return corp.calibration.RAGStrict
+	case corp.calibration != nil:
+		return corp.calibration.RAG
+	case contextSize > 20:
+		return 0.5 // More aggressive for large contexts
+	default:
+		return 0.3 // Default threshold
 	}
+}
 
-	if selectedModel == "" {
-		return "", fmt.Errorf("no suitable chat model found")
+// generateRAGAnswer sends a RAG prompt to the --rag-model (or a RAG-optimized model), copying
+// the answer to out as it arrives
+func generateRAGAnswer(prompt string, out *tee) (string, error) {
+	selectedModel, err := selectRAGModel(ragModel, ragPreferFast)
+	if err != nil {
+		return "", err
 	}
 
 	if verbose {
@@ -293,66 +437,96 @@ func generateRAGAnswerWithTimeout(question, context string, timeout time.Duratio
 		}
 	}
 
-	// Build RAG prompt with explicit brevity instruction
-	prompt := fmt.Sprintf(`Answer concisely (limit ~250 words). Based on the following context, please answer the question. If the answer is not clearly available in the context, say so.
-
-Context:
-%s
+	// llmClient carries the --timeout and --retries resolved for this command
+	if stream {
+		once := &sync.Once{}
+		stats := newStreamStats()
+		resp, err := llmClient.ChatStream(selectedModel, prompt, stats.observe(func(chunk *models.StreamingChatResponse) error {
+			once.Do(func() { fmt.Printf("Answer: ") })
+			fmt.Print(chunk.Message.Content)
+			out.Write(chunk.Message.Content)
+			return nil
+		}))
+		// Ensure newline after stream
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		warnReplyLang(resp.Message.Content)
+		stats.print(resp)
+		return resp.Message.Content, nil
+	}
 
-Question: %s
+	chatResponse, err := llmClient.Chat(selectedModel, prompt)
+	if err != nil {
+		return "", err
+	}
+	if got := wrongReplyLang(chatResponse.Message.Content); got != "" {
+		if verbose {
+			fmt.Printf("The answer came back in %s; asking again for %s\n", got, replyLang)
+		}
+		if chatResponse, err = llmClient.Chat(selectedModel, prompt+replyLangReminder()); err != nil {
+			return "", err
+		}
+		if !ragJSON {
+			warnReplyLang(chatResponse.Message.Content)
+		}
+	}
+	answer := redactAnswer(chatResponse.Message.Content)
+	out.Write(answer)
+	return answer, nil
+}
 
-Answer:`, context, question)
+// selectRAGModel returns the available model matching requested (exact or substring,
+// case-insensitive), or a RAG-optimized model when requested is empty. preferFast picks a
+// smaller, faster model instead.
+func selectRAGModel(requested string, preferFast bool) (string, error) {
+	modelsList, err := llmClient.ListModels()
+	if err != nil {
+		return "", err
+	}
 
-	// Use custom client with timeout if specified
-	if timeout > 0 {
-		// Create client with custom timeout
-		customClient := client.NewOllamaClientWithTimeout(baseURL, timeout)
-		if stream {
-			// Stream using custom client
-			once := &sync.Once{}
-			resp, err := customClient.ChatStream(selectedModel, prompt, func(chunk *models.StreamingChatResponse) error {
-				once.Do(func() { fmt.Printf("Answer: ") })
-				fmt.Print(chunk.Message.Content)
-				return nil
-			})
-			// Ensure newline after stream
-			fmt.Println()
-			if err != nil {
-				return "", err
+	// Honor explicit chat model flag if provided
+	var selectedModel string
+	if requested != "" {
+		for _, m := range modelsList {
+			if strings.EqualFold(m, requested) || strings.Contains(strings.ToLower(m), strings.ToLower(requested)) {
+				selectedModel = m
+				break
 			}
-			return resp.Message.Content, nil
 		}
-
-		// Non-streaming with custom timeout
-		chatResponse, err := customClient.Chat(selectedModel, prompt)
-		if err != nil {
-			return "", err
+		if selectedModel == "" {
+			return "", fmt.Errorf("requested model %q not found. Available models: %v", requested, modelsList)
 		}
-		return chatResponse.Message.Content, nil
-	} else {
-		// Use default client
-		if stream {
-			once := &sync.Once{}
-			resp, err := ollamaClient.ChatStream(selectedModel, prompt, func(chunk *models.StreamingChatResponse) error {
-				once.Do(func() { fmt.Printf("Answer: ") })
-				fmt.Print(chunk.Message.Content)
-				return nil
-			})
-			// Ensure newline after stream
-			fmt.Println()
-			if err != nil {
-				return "", err
+		return selectedModel, nil
+	}
+
+	// Use RAG-optimized model selection
+	selectedModel = llmClient.SelectModelByCapability(modelsList, "rag")
+	if preferFast {
+		// Prefer smaller/faster model candidates when requested
+		fastCandidates := []string{"1b", "2.5", "qwen2.5", "llama3", "mistral", "gemma2"}
+		for _, pref := range fastCandidates {
+			for _, m := range modelsList {
+				if strings.Contains(strings.ToLower(m), strings.ToLower(pref)) {
+					selectedModel = m
+					break
+				}
+			}
+			if selectedModel != "" {
+				break
 			}
-			return resp.Message.Content, nil
 		}
+	}
 
-		// Non-streaming default
-		chatResponse, err := ollamaClient.Chat(selectedModel, prompt)
-		if err != nil {
-			return "", err
-		}
-		return chatResponse.Message.Content, nil
+	if selectedModel == "" {
+		// Fallback to regular chat model
+		selectedModel = selectChatModel(modelsList)
 	}
+	if selectedModel == "" {
+		return "", fmt.Errorf("no suitable chat model found")
+	}
+	return selectedModel, nil
 }
 
 // Helper function to select a chat model (non-embedding model)
@@ -384,23 +558,69 @@ func selectChatModel(models []string) string {
 
 func init() {
 	rootCmd.AddCommand(ragCmd)
+	requireServer(ragCmd, "")
 
+	addTeeFlag(ragCmd)
+	addGenerationFlags(ragCmd)
+	addReplyLangFlag(ragCmd)
+	addRedactFlags(ragCmd)
 	ragCmd.Flags().StringVar(&ragEmbeddingsFile, "embeddings", "",
-		"Path to embeddings JSON file (required)")
+		"Path to embeddings JSON file (required unless --collection is set)")
+	ragCmd.Flags().StringVar(&ragCollection, "collection", "",
+		"Answer from a named collection (registered with 'collections create', or in --store) instead of an embeddings file")
 	ragCmd.Flags().IntVar(&ragContextSize, "context-size", 3,
 		"Number of context chunks to use for answer generation")
 	ragCmd.Flags().Float64Var(&ragSimilarityThreshold, "similarity-threshold", 0.0,
-		"Similarity threshold for filtering context (0.0 = auto, higher = more strict)")
+		"Similarity threshold for filtering context (0.0 = calibrated or auto, higher = more strict)")
 	ragCmd.Flags().IntVar(&ragMaxContextLength, "max-context-length", 8000,
-		"Maximum total character length for context to prevent timeouts")
+		"Maximum total character length for context; applies by default only when the model's context window cannot be read")
+	ragCmd.Flags().IntVar(&ragMaxContextTokens, "max-context-tokens", 0,
+		"Maximum total tokens of the context chunks, below what fits in the model's context window (0 = as many as fit)")
 	ragCmd.Flags().BoolVar(&ragProgressive, "progressive", false,
 		"Use progressive context loading for large context sizes")
-	ragCmd.Flags().IntVar(&ragTimeout, "timeout", 0,
-		"Custom timeout in seconds for answer generation (0 = use default)")
 	ragCmd.Flags().BoolVar(&ragPreferFast, "prefer-fast", false,
 		"Prefer smaller/faster models for RAG (lower latency, possibly lower quality)")
 	ragCmd.Flags().StringVar(&ragModel, "rag-model", "",
 		"Specify chat model to use for RAG (overrides automatic selection)")
-
-	ragCmd.MarkFlagRequired("embeddings")
+	ragCmd.Flags().BoolVar(&ragAttribution, "attribution", false,
+		"Print the title, URL, copyright, and license of each cited source after the answer")
+	ragCmd.Flags().BoolVar(&ragCitations, "citations", false,
+		"List the numbered sources of the answer (title, URL, chunk index) after it")
+	ragCmd.Flags().BoolVar(&ragCiteInline, "cite-inline", false,
+		"Number the context chunks and ask the model to cite them as [1], [2] in the answer; implies --citations")
+	ragCmd.Flags().BoolVar(&ragJSON, "json", false,
+		"Print the answer, and its citations and attribution when requested, as a JSON document")
+	ragCmd.Flags().BoolVar(&exactSearch, "exact", false,
+		"Compare against every embedding even when an approximate index exists")
+	ragCmd.Flags().StringVar(&queryEmbedModel, "embed-model", "",
+		"Embedding model for the question; must match the model the embeddings were built with (default: the recorded model, else auto-select)")
+	ragCmd.Flags().Float64Var(&ragAuthority, "authority-weight", 0,
+		"Blend link-graph PageRank into context ranking with this weight (0 = similarity only, max 1)")
+	ragCmd.Flags().StringVar(&ragLinkGraph, "link-graph", "",
+		"Link graph JSONL written by the requests crawler (default "+defaultLinkGraphFile+")")
+	ragCmd.Flags().BoolVar(&ragRoute, "route", false,
+		"Pick the collections most relevant to the question among those registered and in --store, instead of --embeddings or --collection")
+	ragCmd.Flags().StringVar(&ragRouteMethod, "route-method", route.Centroid,
+		"How --route compares collections: centroid (question vs. each collection's mean embedding) or llm (ask a chat model)")
+	ragCmd.Flags().IntVar(&ragRouteTop, "route-top", 1,
+		"Number of collections --route answers from; they must share an embedding model")
+	ragCmd.Flags().StringVar(&ragRouteModel, "route-model", "",
+		"Chat model for --route-method llm (default: a fast installed model)")
+	ragCmd.Flags().StringSliceVar(&ragRouteAmong, "route-among", nil,
+		"Collections --route chooses between (default: all)")
+	ragCmd.Flags().IntVar(&ragAnswerTokens, "answer-tokens", rag.DefaultAnswerTokens,
+		"Tokens of the model's context window kept free for the answer when sizing the context (a num_predict option takes precedence)")
+	ragCmd.Flags().StringVar(&ragTokenizer, "tokenizer", "",
+		"tokenizer.json of the chat model, to count prompt and context tokens exactly instead of estimating them")
+	ragCmd.Flags().BoolVarP(&ragInteractive, "interactive", "i", false,
+		"Hold a conversation: follow-ups reuse the retrieved context and the answers so far; a question given on the command line is asked first")
+	ragCmd.Flags().Float64Var(&ragTopicShift, "topic-shift", 0.6,
+		"With --interactive, retrieve a new context when a question's search query is less similar than this to the one that retrieved the current context")
+	ragCmd.Flags().BoolVar(&noHistory, "no-history", false, "With --interactive, do not read or save the input history in ~/.kirk-ai/history")
+	addSourceWeightsFlag(ragCmd)
+	addDaemonFlag(ragCmd)
+	addChunksFlag(ragCmd)
+	addFilterFlag(ragCmd)
+	addHybridFlag(ragCmd)
+	addRerankFlags(ragCmd)
 }
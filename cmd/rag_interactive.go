@@ -0,0 +1,388 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"kirk-ai/internal/conversation"
+	"kirk-ai/internal/lineedit"
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/rag"
+	"kirk-ai/internal/vectorstore"
+)
+
+const ragREPLHelp = `Commands:
+  /help              Show this help
+  /sources           List the excerpts in the current context
+  /source <n>        Show excerpt [n] in full, with its link, section, and provenance
+  /page <n>          Add more of excerpt [n]'s page to the context
+  /retrieve          Search afresh for the next question, even on the same topic
+  /reset             Forget the conversation and the context and start over
+  /exit, /quit       Leave (Ctrl-D works too)
+Follow-up questions keep the current context; a question on another topic retrieves a new one.
+End a line with \ to continue the message on the next line. Ctrl-C stops a reply.
+Up/Down recall earlier input (kept in ~/.kirk-ai/history/rag); Ctrl-R searches it.`
+
+// ragREPL is an interactive RAG conversation. The retrieved excerpts and the chat history
+// carry over between questions, so a follow-up is answered from the same sources and only a
+// question on another topic searches the corpus afresh.
+type ragREPL struct {
+	corp      *corpus
+	model     string
+	threshold float64
+	conv      *conversation.Conversation
+	// sources is the context: the excerpts retrieved for the current topic, oldest first so
+	// their numbers stay put as follow-ups add to them
+	sources []searchResult
+	// topic is the embedding of the query that retrieved sources (nil when there are none)
+	topic []float64
+	// cited are the excerpts the last answer was given, numbered as it cites them
+	cited []searchResult
+	// refresh makes the next question retrieve afresh whatever its topic
+	refresh bool
+	// charCap applies --max-context-length even when the model's context window is known
+	charCap bool
+	out     *tee
+}
+
+// runRAGREPL answers questions from stdin until /exit or end of input. first, when set, is
+// asked before the first question is read. charCap is whether --max-context-length was given.
+func runRAGREPL(first string, charCap bool) {
+	var corp *corpus
+	var err error
+	if chunksFile != "" {
+		corp, err = loadAdHocCorpus(chunksFile, true, true)
+	} else {
+		corp, err = loadSearchCorpus(ragEmbeddingsFile, ragCollection)
+	}
+	if err != nil {
+		fmt.Printf("Error loading embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	corp.setFilter(searchFilters)
+
+	r := &ragREPL{corp: corp, threshold: ragSimilarityThreshold, conv: &conversation.Conversation{}, charCap: charCap}
+	if r.threshold == 0 {
+		r.threshold = ragThreshold(corp, ragContextSize)
+	}
+	r.model, err = selectRAGModel(ragModel, ragPreferFast)
+	if err != nil {
+		fmt.Printf("Error selecting model: %v\n", err)
+		os.Exit(1)
+	}
+	r.out, err = openTee()
+	if err != nil {
+		fmt.Printf("Error opening --tee file: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := r.out.Close(); err != nil {
+			fmt.Printf("Error writing --tee file: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("Asking %d chunks with %s. Type /help for commands, /exit to leave.\n", corp.Len(), r.model)
+	if first != "" {
+		fmt.Printf(">>> %s\n", first)
+		r.ask(first)
+	}
+
+	in := lineedit.New(os.Stdin, os.Stdout, replHistory("rag"))
+	for {
+		line, err := readChatInput(in)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("Error reading input: %v\n", err)
+			}
+			fmt.Println()
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "/"):
+			if !r.command(line) {
+				return
+			}
+		default:
+			r.ask(line)
+		}
+	}
+}
+
+// ask answers question from the context, retrieving a new one first when the question has
+// moved to another topic. A failed or interrupted turn is dropped from the history.
+func (r *ragREPL) ask(question string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// A follow-up such as "who funds it?" is searched for as the question it stands for
+	query := question
+	if len(r.conv.Messages) > 0 {
+		query = r.rewrite(ctx, question)
+		if query != question {
+			fmt.Printf("(searching for: %s)\n", query)
+		}
+	}
+	queryEmbedding, err := r.corp.embedQuery(ctx, query, queryEmbedModel)
+	if err != nil {
+		fmt.Printf("Error generating query embedding: %v\n", err)
+		return
+	}
+	if !r.retrieve(query, queryEmbedding) {
+		return
+	}
+
+	r.conv.Add("user", question)
+	summarized, err := r.conv.Compact(ctx, conversation.DefaultBudget, conversation.DefaultKeepRecent, chatSummarizer(r.model))
+	if err != nil {
+		fmt.Printf("Error in chat: %v\n", err)
+		r.conv.Messages = r.conv.Messages[:len(r.conv.Messages)-1]
+		return
+	}
+	if summarized && verbose {
+		fmt.Printf("Summarized older turns (%d messages in summary so far)\n", r.conv.SummarizedTurns)
+	}
+
+	// The excerpts get what the conversation leaves of the model's context window
+	history := r.conv.ChatMessages()
+	prompt := rag.BuildConversationPrompt("")
+	for _, m := range history {
+		prompt += "\n" + m.Content
+	}
+	budget, contextTokens, windowed := contextBudget(r.model, prompt)
+	maxLength := ragMaxContextLength
+	if maxLength == 0 {
+		maxLength = rag.DefaultMaxContextLength
+	}
+	if windowed && !r.charCap {
+		maxLength = math.MaxInt32
+	}
+	excerpts, used := rag.BuildCitedContext(budget.Fit(r.sources, contextTokens), maxLength)
+	if len(used) == 0 {
+		fmt.Println("Found similar embeddings but no content available for context.")
+		r.conv.Messages = r.conv.Messages[:len(r.conv.Messages)-1]
+		return
+	}
+
+	// The excerpts go in a system message ahead of the conversation, so they are sent once
+	// per turn however long the conversation grows
+	messages := append([]models.Message{{Role: "system", Content: rag.BuildConversationPrompt(excerpts)}}, history...)
+	r.out.Write(">>> " + question + "\n")
+	response, err := sendChatMessages(ctx, r.model, messages, r.out)
+	if err != nil {
+		r.out.Write("\n\n")
+		if ctx.Err() != nil {
+			fmt.Println("(interrupted)")
+		} else {
+			fmt.Printf("Error generating answer: %v\n", err)
+		}
+		r.conv.Messages = r.conv.Messages[:len(r.conv.Messages)-1]
+		return
+	}
+	r.conv.Add("assistant", response.Message.Content)
+	r.cited = used
+
+	sources := rag.FormatCitations(rag.Citations(used))
+	r.out.Write("\n\nSources:\n" + sources + "\n")
+	fmt.Printf("\nSources:\n%s", sources)
+}
+
+// retrieve searches the corpus for query and updates the context, reporting whether there
+// is one to answer from. A query close to the one that retrieved the context is a follow-up:
+// the context is kept and any new excerpts the search turns up are added after it. A query
+// below --topic-shift similarity replaces the context.
+func (r *ragREPL) retrieve(query string, queryEmbedding []float64) bool {
+	results, err := retrieveContext(r.corp, query, queryEmbedding, ragContextSize, r.threshold)
+	if err != nil {
+		fmt.Printf("Error %v\n", err)
+		return false
+	}
+
+	if r.topic != nil && !r.refresh {
+		if sim := vectorstore.CosineSimilarity(queryEmbedding, r.topic); sim >= ragTopicShift {
+			added := r.add(results, 2*ragContextSize)
+			if verbose {
+				fmt.Printf("Same topic (similarity %.2f): kept %d excerpts, added %d\n", sim, len(r.sources)-added, added)
+			}
+			return true
+		} else if verbose {
+			fmt.Printf("New topic (similarity %.2f < %.2f)\n", sim, ragTopicShift)
+		}
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No relevant context found for: %s\n", query)
+		fmt.Printf("Try lowering the similarity threshold (current: %.2f) or asking a different question.\n", r.threshold)
+		return false
+	}
+	if r.topic != nil {
+		fmt.Printf("(new topic: retrieved %d excerpts)\n", len(results))
+	}
+	r.sources, r.topic, r.refresh = results, queryEmbedding, false
+	return true
+}
+
+// add appends the results not already in the context until it holds limit excerpts, and
+// returns how many it added
+func (r *ragREPL) add(results []searchResult, limit int) int {
+	have := make(map[string]bool, len(r.sources))
+	for _, s := range r.sources {
+		have[s.Item.ID] = true
+	}
+	added := 0
+	for _, res := range results {
+		if len(r.sources) >= limit {
+			break
+		}
+		if !have[res.Item.ID] {
+			have[res.Item.ID] = true
+			r.sources = append(r.sources, res)
+			added++
+		}
+	}
+	return added
+}
+
+// rewrite asks the model for question as a standalone search query, resolving its
+// references from the recent conversation. The question itself is searched for when the
+// model fails or returns nothing usable.
+func (r *ragREPL) rewrite(ctx context.Context, question string) string {
+	var transcript strings.Builder
+	if r.conv.Summary != "" {
+		fmt.Fprintf(&transcript, "(earlier) %s\n", r.conv.Summary)
+	}
+	recent := r.conv.Messages
+	if len(recent) > 4 {
+		recent = recent[len(recent)-4:]
+	}
+	for _, m := range recent {
+		content := m.Content
+		if len(content) > 600 {
+			content = content[:600] + "..."
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", m.Role, content)
+	}
+
+	resp, err := llmClient.ChatContext(ctx, r.model, rag.BuildRewritePrompt(transcript.String(), question))
+	if err != nil {
+		if verbose {
+			fmt.Printf("Could not rewrite the question, searching for it as asked: %v\n", err)
+		}
+		return question
+	}
+	query := strings.TrimSpace(resp.Message.Content)
+	if line, _, _ := strings.Cut(query, "\n"); line != "" {
+		query = line
+	}
+	query = strings.Trim(strings.TrimPrefix(query, "Standalone query:"), " \"'`")
+	if query == "" {
+		return question
+	}
+	return query
+}
+
+// command runs a slash command and reports whether the REPL should keep going
+func (r *ragREPL) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch strings.ToLower(name) {
+	case "/exit", "/quit", "/bye":
+		return false
+	case "/help", "/?":
+		fmt.Println(ragREPLHelp)
+	case "/reset", "/clear":
+		r.conv = &conversation.Conversation{}
+		r.sources, r.topic, r.cited, r.refresh = nil, nil, nil, false
+		fmt.Println("Conversation and context cleared.")
+	case "/retrieve":
+		r.refresh = true
+		fmt.Println("The next question will retrieve a new context.")
+	case "/sources":
+		if len(r.sources) == 0 {
+			fmt.Println("No context yet; ask a question first.")
+			return true
+		}
+		fmt.Print(rag.FormatCitations(rag.Citations(r.sources)))
+	case "/source":
+		if i, ok := r.citedExcerpt(arg, name); ok {
+			r.showSource(i)
+		}
+	case "/page":
+		if i, ok := r.citedExcerpt(arg, name); ok {
+			r.expandPage(r.cited[i])
+		}
+	default:
+		fmt.Printf("Unknown command %s. Type /help for commands.\n", name)
+	}
+	return true
+}
+
+// citedExcerpt returns the index in cited of excerpt [arg] of the last answer
+func (r *ragREPL) citedExcerpt(arg, name string) (int, bool) {
+	n, err := strconv.Atoi(strings.Trim(arg, "[]"))
+	if err != nil {
+		fmt.Printf("Usage: %s <n>, where [n] is a source of the last answer\n", name)
+		return 0, false
+	}
+	if n < 1 || n > len(r.cited) {
+		fmt.Printf("The last answer has %d sources; there is no [%d].\n", len(r.cited), n)
+		return 0, false
+	}
+	return n - 1, true
+}
+
+// showSource prints excerpt i of the last answer in full with where it came from
+func (r *ragREPL) showSource(i int) {
+	res := r.cited[i]
+	it := res.Item
+	fmt.Println(rag.Citations(r.cited)[i])
+	if link := vectorstore.DeepLink(it); link != "" {
+		fmt.Printf("link: %s\n", link)
+	}
+	if section := metadataString(it.Metadata, "section"); section != "" {
+		fmt.Printf("section: %s\n", section)
+	}
+	if prov := provenance.Get(it.Metadata).String(); prov != "" {
+		fmt.Printf("provenance: %s\n", prov)
+	}
+	fmt.Printf("similarity: %.3f\n\n%s\n", res.Similarity, rag.ContentOf(it))
+}
+
+// expandPage adds the chunks of an excerpt's page that best match the current topic to the
+// context, so the next answer can draw on more of the page than the search turned up
+func (r *ragREPL) expandPage(res searchResult) {
+	src := vectorstore.SourceURL(res.Item)
+	if src == "" {
+		fmt.Println("This excerpt has no source page recorded.")
+		return
+	}
+	if r.corp.remote != nil || r.corp.daemon != nil {
+		fmt.Println("/page needs the chunks loaded locally; it is not available for Qdrant collections or through the daemon.")
+		return
+	}
+	filter := append(append(vectorstore.Filter{}, r.corp.filter...), vectorstore.Condition{Field: "source_url", Op: "=", Value: src})
+	results, err := r.corp.searchWith(r.topic, ragContextSize, 0, filter, false)
+	if err != nil {
+		fmt.Printf("Error searching %s: %v\n", src, err)
+		return
+	}
+	added := r.add(results, len(r.sources)+ragContextSize)
+	if added == 0 {
+		fmt.Printf("The context already holds the parts of %s that match this topic.\n", src)
+		return
+	}
+	fmt.Printf("Added %d more excerpts from %s; the next answer can use them.\n", added, src)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/redact"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	redactAnswers  bool
+	redactModel    string
+	answerRedactor *redact.Redactor
+)
+
+// addRedactFlags registers --redact and --redact-model on a command that shows generated answers
+func addRedactFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&redactAnswers, "redact", false,
+		`Mask email addresses, phone numbers, card and social security numbers, IP addresses, and the patterns under "redact" in ~/.kirk-ai/config.json in the answer before it is shown; turns off --stream`)
+	cmd.Flags().StringVar(&redactModel, "redact-model", "",
+		"Also ask this chat model for personal information the patterns miss, such as names and street addresses; implies --redact")
+}
+
+// applyRedact loads the redaction patterns for --redact. Answers are then printed whole, since
+// a streamed answer is shown before it can be checked.
+func applyRedact() {
+	if !redactAnswers && redactModel == "" {
+		return
+	}
+	r, err := redact.Load()
+	if err != nil {
+		fmt.Printf("Error loading redaction patterns: %v\n", err)
+		os.Exit(1)
+	}
+	answerRedactor = r
+	stream = false
+}
+
+// redactAnswer masks personal information in a generated answer when --redact is set. If the
+// --redact-model cannot be asked, the answer is shown with the pattern matches masked.
+func redactAnswer(answer string) string {
+	if answerRedactor == nil {
+		return answer
+	}
+	var ask redact.Ask
+	if redactModel != "" {
+		ask = func(prompt string) (string, error) {
+			resp, err := llmClient.Chat(redactModel, prompt)
+			if err != nil {
+				return "", err
+			}
+			return resp.Message.Content, nil
+		}
+	}
+	masked, counts, err := answerRedactor.RedactWith(answer, ask)
+	if err != nil {
+		fmt.Printf("Warning: could not check the answer with %s (%v); only the patterns were masked\n", redactModel, err)
+	}
+	if verbose && counts.Total() > 0 {
+		fmt.Printf("Redacted %s\n", counts)
+	}
+	return masked
+}
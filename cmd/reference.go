@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"kirk-ai/internal/rag"
+
+	"github.com/spf13/cobra"
+)
+
+// Reference retrieval flags shared by commands that can ground a prompt in an index
+var (
+	refEmbeddingsFile string
+	refCollection     string
+	refTopK           int
+	refThreshold      float64
+)
+
+// addReferenceFlags registers --embeddings/--collection retrieval on a command
+func addReferenceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&refEmbeddingsFile, "embeddings", "", "Embeddings file to retrieve reference material (glossaries, API docs) from")
+	cmd.Flags().StringVar(&refCollection, "collection", "", "Named collection (registered, or in --store) to retrieve reference material from")
+	cmd.Flags().IntVar(&refTopK, "context-size", 3, "Number of reference chunks to inject")
+	cmd.Flags().Float64Var(&refThreshold, "similarity-threshold", 0, "Minimum similarity of injected reference chunks (0 = calibrated threshold, or 0.3)")
+}
+
+// withReference retrieves chunks similar to query from the --embeddings file or --collection
+// and prepends them to prompt. Without either flag the prompt is returned unchanged.
+func withReference(prompt, query string) (string, error) {
+	if refEmbeddingsFile == "" && refCollection == "" {
+		return prompt, nil
+	}
+	corp, err := loadCorpus(refEmbeddingsFile, refCollection)
+	if err != nil {
+		return "", fmt.Errorf("loading reference embeddings: %w", err)
+	}
+	queryEmbedding, err := corp.embedQuery(context.Background(), query, "")
+	if err != nil {
+		return "", fmt.Errorf("embedding query: %w", err)
+	}
+	threshold := refThreshold
+	if threshold == 0 {
+		threshold = 0.3
+		if corp.calibration != nil {
+			threshold = corp.calibration.RAG
+		}
+	}
+	results, err := corp.Search(queryEmbedding, refTopK, threshold)
+	if err != nil {
+		return "", fmt.Errorf("searching reference embeddings: %w", err)
+	}
+	reference, used := rag.BuildContext(results, rag.DefaultMaxContextLength)
+	if verbose {
+		fmt.Printf("Injected %d reference chunks (%d characters)\n", len(used), len(reference))
+		for i, r := range used {
+			fmt.Printf("  [%d] %s (similarity: %.3f)\n", i+1, r.Item.ID, r.Similarity)
+		}
+	}
+	return rag.BuildReferencePrompt(prompt, reference), nil
+}
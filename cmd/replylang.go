@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	"kirk-ai/internal/lang"
+
+	"github.com/spf13/cobra"
+)
+
+// replyLang is the language answers must be written in (--reply-lang); "" leaves it to the model
+var replyLang string
+
+// addReplyLangFlag registers --reply-lang on a command that answers with a chat model
+func addReplyLangFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&replyLang, "reply-lang", "",
+		"Answer in this language, given by name or code (e.g. Spanish or es), whatever the language of the question or the sources; the answer is checked and regenerated once if it comes back in another language")
+}
+
+// applyReplyLang resolves --reply-lang to a language name and asks for it in the system
+// prompt, so every turn of a conversation is answered in it
+func applyReplyLang() {
+	if replyLang == "" {
+		return
+	}
+	name, known := lang.Name(replyLang)
+	replyLang = name
+	if !known && verbose {
+		fmt.Printf("Note: answers cannot be checked for %s; only the instruction is sent\n", name)
+	}
+	instruction := replyLangInstruction()
+	if llmClient.System != "" {
+		instruction = llmClient.System + "\n\n" + instruction
+	}
+	llmClient.System = instruction
+}
+
+// replyLangInstruction tells the model which language to answer in
+func replyLangInstruction() string {
+	return fmt.Sprintf("Always write your answer in %s, whatever the language of the question or of any provided context. Translate quotes and facts taken from the context into %s.", replyLang, replyLang)
+}
+
+// replyLangReminder is added to a prompt that was answered in the wrong language
+func replyLangReminder() string {
+	return fmt.Sprintf("\n\nImportant: your answer must be written in %s.", replyLang)
+}
+
+// wrongReplyLang returns the language answer appears to be written in when it is not
+// --reply-lang, or "" when it is, when it cannot be told, or when no language was asked for
+func wrongReplyLang(answer string) string {
+	if replyLang == "" {
+		return ""
+	}
+	if _, known := lang.Name(replyLang); !known {
+		return ""
+	}
+	g := lang.Detect(answer)
+	if g.Language == "" || g.Language == replyLang {
+		return ""
+	}
+	return g.Language
+}
+
+// warnReplyLang prints a warning when answer is not in --reply-lang
+func warnReplyLang(answer string) {
+	if got := wrongReplyLang(answer); got != "" {
+		fmt.Printf("Warning: the answer appears to be in %s, not %s\n", got, replyLang)
+	}
+}
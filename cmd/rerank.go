@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/rerank"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rerankMethod string
+	rerankModel  string
+	mmrLambda    float64
+)
+
+// addRerankFlags registers --rerank and its settings on a command that searches embeddings
+func addRerankFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&rerankMethod, "rerank", rerank.Off,
+		"Rerank search candidates before use: mmr (drop near-duplicates), llm (score each with a chat model), or off")
+	cmd.Flags().StringVar(&rerankModel, "rerank-model", "",
+		"Chat model that scores candidates for --rerank llm (default: a fast installed model)")
+	cmd.Flags().Float64Var(&mmrLambda, "mmr-lambda", rerank.DefaultLambda,
+		"Relevance weight for --rerank mmr (1 = similarity only, lower = more diverse)")
+}
+
+// checkRerankFlags validates --rerank, exiting on an unknown method
+func checkRerankFlags() {
+	method, err := rerank.ParseMethod(rerankMethod)
+	if err != nil {
+		fmt.Printf("Error in --rerank: %v\n", err)
+		os.Exit(1)
+	}
+	rerankMethod = method
+	if mmrLambda < 0 || mmrLambda > 1 {
+		fmt.Println("--mmr-lambda must be between 0 and 1")
+		os.Exit(1)
+	}
+}
+
+// rerankPool is how many results a search keeps for the rerank step to choose topK from
+func rerankPool(topK, candidates int) int {
+	if rerankMethod == rerank.Off {
+		return topK
+	}
+	return candidates
+}
+
+// rerankResults reorders results with the --rerank method and returns up to topK of them
+func rerankResults(query string, results []searchResult, topK int) ([]searchResult, error) {
+	switch rerankMethod {
+	case rerank.MMR:
+		return rerank.Diversify(results, topK, mmrLambda), nil
+	case rerank.LLM:
+		selectedModel, err := selectRAGModel(rerankModel, true)
+		if err != nil {
+			return nil, err
+		}
+		if verbose {
+			fmt.Printf("Reranking %d candidates with %s\n", len(results), selectedModel)
+		}
+		scorer := &rerank.Scorer{Client: llmClient, Model: selectedModel}
+		return scorer.Rerank(context.Background(), query, results, topK)
+	}
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
@@ -3,21 +3,182 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"kirk-ai/internal/client"
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/workspace"
 
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Global flags
-	baseURL      string
-	model        string
-	verbose      bool
-	stream       bool
-	ollamaClient *client.OllamaClient
+	baseURL       string
+	model         string
+	verbose       bool
+	stream        bool
+	encryptOutput bool
+	storeDir      string
+	workspaceDir  string
+	timeout       secondsDuration
+	retries       int
+	retryBackoff  time.Duration
+	retryMax      time.Duration
+	retryJitter   float64
+	retryOn       []int
+	systemPrompt  string
+	keepAlive     string
+	noPull        bool
+	provider      string
+	llmClient     *client.Client
 )
 
+// secondsDuration is a duration flag that also accepts a bare number of seconds, so
+// "--timeout 90" and "--timeout 90s" mean the same
+type secondsDuration time.Duration
+
+func (d *secondsDuration) String() string { return time.Duration(*d).String() }
+
+func (d *secondsDuration) Type() string { return "duration" }
+
+func (d *secondsDuration) Set(s string) error {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		*d = secondsDuration(n * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = secondsDuration(v)
+	return nil
+}
+
+// newCommandClient builds the LLM client for cmd. The provider comes from --provider, else
+// KIRK_PROVIDER, else Ollama. Timeout, the retry policy, the system prompt, Ollama's
+// keep-alive, and the generation options come from their flags when given, else from the
+// command's entry in ~/.kirk-ai/config.json, else from the settings file's defaults, else
+// the client and model defaults.
+func newCommandClient(cmd *cobra.Command) (*client.Client, error) {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.LoadModelConfigs(); err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	cmdTimeout, cmdRetries, err := settings.For(name)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Flags().Changed("timeout") {
+		cmdTimeout = time.Duration(timeout)
+	}
+	if cmd.Flags().Changed("retries") {
+		cmdRetries = retries
+	}
+	if cmdTimeout <= 0 {
+		cmdTimeout = client.DefaultTimeout
+	}
+	policy, err := retryPolicy(cmd, settings, name, cmdRetries)
+	if err != nil {
+		return nil, err
+	}
+
+	providerName := commandProvider(cmd)
+	var p client.Provider
+	switch providerName {
+	case client.ProviderOllama:
+		oc := client.NewOllamaClientWithTimeout(baseURL, cmdTimeout)
+		oc.RetryPolicy = policy
+		ka := settings.KeepAliveFor(name)
+		if cmd.Flags().Changed("keep-alive") {
+			ka = keepAlive
+		}
+		if oc.KeepAlive, err = client.ParseKeepAlive(ka); err != nil {
+			return nil, err
+		}
+		p = oc
+	case client.ProviderOpenAI:
+		oc := client.NewOpenAIClientWithTimeout(serverURL(cmd, providerName), os.Getenv("OPENAI_API_KEY"), cmdTimeout)
+		oc.RetryPolicy = policy
+		p = oc
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want %s or %s)", providerName, client.ProviderOllama, client.ProviderOpenAI)
+	}
+
+	c := client.New(p)
+	c.System = settings.SystemFor(name)
+	if cmd.Flags().Changed("system") {
+		c.System = systemPrompt
+	}
+	defaults, err := settings.OptionsFor(name)
+	if err != nil {
+		return nil, err
+	}
+	c.Options = generationFlags(cmd).WithDefaults(defaults)
+	if err := c.Options.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid generation options: %w", err)
+	}
+	return c, nil
+}
+
+// commandProvider returns the provider named by --provider, else KIRK_PROVIDER, else Ollama
+func commandProvider(cmd *cobra.Command) string {
+	if !cmd.Flags().Changed("provider") && os.Getenv("KIRK_PROVIDER") != "" {
+		return strings.ToLower(os.Getenv("KIRK_PROVIDER"))
+	}
+	return strings.ToLower(provider)
+}
+
+// serverURL returns the URL cmd talks to: --url, or the provider's default when it is not given
+func serverURL(cmd *cobra.Command, providerName string) string {
+	if providerName == client.ProviderOpenAI && !cmd.Flags().Changed("url") {
+		return client.DefaultOpenAIBaseURL
+	}
+	return baseURL
+}
+
+// retryPolicy resolves cmd's retry policy from the --retry-* flags, the settings file, and
+// the client defaults, in that order
+func retryPolicy(cmd *cobra.Command, settings *config.Settings, name string, cmdRetries int) (client.RetryPolicy, error) {
+	policy := client.DefaultRetryPolicy()
+	policy.Retries = cmdRetries
+	r, err := settings.RetryFor(name)
+	if err != nil {
+		return policy, err
+	}
+	if r.Backoff > 0 {
+		policy.Backoff = r.Backoff
+	}
+	if r.MaxBackoff > 0 {
+		policy.MaxBackoff = r.MaxBackoff
+	}
+	if r.Jitter != nil {
+		policy.Jitter = *r.Jitter
+	}
+	if r.On != nil {
+		policy.RetryOn = r.On
+	}
+	if cmd.Flags().Changed("retry-backoff") {
+		policy.Backoff = retryBackoff
+	}
+	if cmd.Flags().Changed("retry-max-backoff") {
+		policy.MaxBackoff = retryMax
+	}
+	if cmd.Flags().Changed("retry-jitter") {
+		policy.Jitter = retryJitter
+	}
+	if cmd.Flags().Changed("retry-on") {
+		policy.RetryOn = retryOn
+	}
+	return policy, policy.Validate()
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "kirk-ai",
@@ -25,13 +186,32 @@ var rootCmd = &cobra.Command{
 	Long: `Kirk-AI is a command-line interface for interacting with Ollama AI models.
 It supports both chat interactions and text embeddings using various models.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		ollamaClient = client.NewOllamaClient(baseURL)
+		useWorkspace(cmd)
+		c, err := newCommandClient(cmd)
+		if err != nil {
+			fmt.Printf("Error setting up client: %v\n", err)
+			os.Exit(1)
+		}
+		llmClient = c
+		checkServer(cmd)
 	},
 }
 
+// useWorkspace makes --workspace, else KIRK_AI_WORKSPACE, the workspace, and moves cmd's path
+// flags left at their defaults into it
+func useWorkspace(cmd *cobra.Command) {
+	dir := workspaceDir
+	if !cmd.Flags().Changed("workspace") && os.Getenv(workspace.EnvVar) != "" {
+		dir = os.Getenv(workspace.EnvVar)
+	}
+	workspace.SetDir(dir)
+	workspace.Rebase(cmd.Flags())
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	registerPluginCommands()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -40,8 +220,22 @@ func Execute() {
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&baseURL, "url", "http://localhost:11434", "Ollama server URL")
+	rootCmd.PersistentFlags().StringVar(&baseURL, "url", "http://localhost:11434", "Server URL (for --provider openai, the API base such as http://localhost:8000/v1; default "+client.DefaultOpenAIBaseURL+")")
+	rootCmd.PersistentFlags().StringVar(&provider, "provider", client.ProviderOllama, "LLM API to talk to: ollama, or openai for OpenAI-compatible servers like llama.cpp, vLLM, and LM Studio (env KIRK_PROVIDER)")
 	rootCmd.PersistentFlags().StringVar(&model, "model", "", "Model to use (auto-detect if not specified)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&stream, "stream", "s", false, "Enable streaming output (real-time response)")
+	rootCmd.PersistentFlags().StringVar(&workspaceDir, "workspace", workspace.DefaultDir, "Directory holding the crawl output, processed pages, embeddings, collections, snapshots, caches, and audit logs (env "+workspace.EnvVar+")")
+	rootCmd.PersistentFlags().StringVar(&storeDir, "store", workspace.Default(workspace.Store), "Directory holding named embedding collections, or a Qdrant server as qdrant://host:port")
+	timeout = secondsDuration(client.DefaultTimeout)
+	rootCmd.PersistentFlags().Var(&timeout, "timeout", "Timeout for each Ollama request, e.g. 90s or 5m; a bare number is seconds (overrides ~/.kirk-ai/config.json)")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 0, "Retry Ollama requests that fail with a connection error, 429, or 5xx this many times (overrides ~/.kirk-ai/config.json)")
+	rootCmd.PersistentFlags().DurationVar(&retryBackoff, "retry-backoff", client.DefaultRetryBackoff, "Delay before the first retry, doubled after each further attempt (overrides ~/.kirk-ai/config.json)")
+	rootCmd.PersistentFlags().DurationVar(&retryMax, "retry-max-backoff", client.DefaultRetryMaxBackoff, "Longest delay between retries (overrides ~/.kirk-ai/config.json)")
+	rootCmd.PersistentFlags().Float64Var(&retryJitter, "retry-jitter", client.DefaultRetryJitter, "Fraction of each retry delay added at random, 0 to 1 (overrides ~/.kirk-ai/config.json)")
+	rootCmd.PersistentFlags().IntSliceVar(&retryOn, "retry-on", nil, "HTTP statuses to retry, e.g. 500,502,503, instead of 429 and 5xx; connection errors are always retried (overrides ~/.kirk-ai/config.json)")
+	rootCmd.PersistentFlags().StringVar(&systemPrompt, "system", "", "System prompt sent ahead of every chat request, e.g. a persona or standing instructions (overrides ~/.kirk-ai/config.json; \"\" disables)")
+	rootCmd.PersistentFlags().StringVar(&keepAlive, "keep-alive", "", "How long Ollama keeps a model loaded after each request, e.g. 30m; a number is seconds and -1 keeps it loaded (default: the server's, 5m; overrides ~/.kirk-ai/config.json)")
+	rootCmd.PersistentFlags().BoolVar(&noPull, "no-pull", false, "Fail instead of pulling the recommended embedding model when none is installed")
+	rootCmd.PersistentFlags().BoolVar(&encryptOutput, "encrypt", false, "Encrypt written files with AES-GCM (key from KIRK_AI_ENCRYPTION_KEY or the OS keychain)")
 }
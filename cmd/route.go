@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/route"
+	"kirk-ai/internal/vectorstore"
+)
+
+var (
+	ragRoute       bool
+	ragRouteMethod string
+	ragRouteTop    int
+	ragRouteModel  string
+	ragRouteAmong  []string
+)
+
+// routeCandidates lists the collections a question can be routed to: the registered ones
+// and those in --store, or only those named by --route-among. Qdrant collections and shard
+// manifests are left out, since their embeddings are not loaded in memory. Each gets the
+// summary saved next to its embeddings, recomputed when the embeddings changed.
+func routeCandidates() ([]route.Candidate, error) {
+	type source struct {
+		name, description, items, summary string
+	}
+	var sources []source
+	reg := loadRegistry()
+	for _, e := range reg.List() {
+		switch {
+		case e.Kind() == "qdrant":
+		case e.File != "":
+			if !vectorstore.IsShardManifest(e.File) {
+				sources = append(sources, source{e.Name, e.Description, e.File, vectorstore.SummaryPath(e.File)})
+			}
+		default:
+			st := vectorstore.NewStore(e.Store)
+			sources = append(sources, source{e.Name, e.Description, st.ItemsPath(e.StoreCollection()), st.SummaryPath(e.StoreCollection())})
+		}
+	}
+	if !vectorstore.IsQdrant(storeDir) {
+		st := vectorstore.NewStore(storeDir)
+		infos, err := st.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if _, ok := reg.Get(info.Name); !ok {
+				sources = append(sources, source{info.Name, "", st.ItemsPath(info.Name), st.SummaryPath(info.Name)})
+			}
+		}
+	}
+	if len(ragRouteAmong) > 0 {
+		byName := map[string]source{}
+		for _, s := range sources {
+			byName[s.name] = s
+		}
+		sources = sources[:0]
+		for _, name := range ragRouteAmong {
+			s, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("--route-among: no collection %q to route to", name)
+			}
+			sources = append(sources, s)
+		}
+	}
+
+	var candidates []route.Candidate
+	for _, s := range sources {
+		fi, err := os.Stat(s.items)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", s.name, err)
+		}
+		summary, err := vectorstore.ReadSummary(s.summary)
+		if err != nil {
+			return nil, err
+		}
+		if summary == nil || !summary.Current(fi) {
+			corp, _, err := openCorpus("", s.name)
+			if err != nil {
+				return nil, fmt.Errorf("collection %s: %w", s.name, err)
+			}
+			summary = vectorstore.Summarize(corp.items, corp.model)
+			summary.SourceSize, summary.SourceModTime = fi.Size(), fi.ModTime()
+			if err := vectorstore.WriteSummary(s.summary, summary); err != nil {
+				fmt.Printf("Warning: could not save summary of %s: %v\n", s.name, err)
+			}
+			if verbose {
+				fmt.Printf("Summarized collection %s (%d chunks)\n", s.name, summary.Count)
+			}
+		}
+		candidates = append(candidates, route.Candidate{Name: s.name, Description: s.description, Summary: summary})
+	}
+	return candidates, nil
+}
+
+// routeQuestion picks up to --route-top collections for question with --route-method
+func routeQuestion(question string) ([]route.Choice, error) {
+	candidates, err := routeCandidates()
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no collections to route between (register some with 'collections create')")
+	}
+
+	var choices []route.Choice
+	switch ragRouteMethod {
+	case route.Centroid:
+		// The question is embedded once per embedding model among the candidates
+		queries := map[string][]float64{}
+		for _, c := range candidates {
+			m := c.Summary.EmbeddingModel
+			if _, done := queries[m]; done {
+				continue
+			}
+			q, err := queryEmbedding(context.Background(), question, m, "")
+			if err != nil {
+				fmt.Printf("Warning: skipping collections embedded with %q: %v\n", m, err)
+				q = nil
+			}
+			queries[m] = q
+		}
+		for m, q := range queries {
+			if q == nil {
+				delete(queries, m)
+			}
+		}
+		choices = route.ByCentroid(queries, candidates)
+	case route.LLM:
+		selectedModel, err := selectRAGModel(ragRouteModel, true)
+		if err != nil {
+			return nil, err
+		}
+		classifier := &route.Classifier{Client: llmClient, Model: selectedModel}
+		if choices, err = classifier.Route(context.Background(), question, candidates); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown --route-method %q (use centroid or llm)", ragRouteMethod)
+	}
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("no collection could be compared with the question")
+	}
+	if ragRouteTop > 0 && len(choices) > ragRouteTop {
+		choices = choices[:ragRouteTop]
+	}
+	return choices, nil
+}
+
+// loadRoutedCorpus opens the routed collections as one corpus. Collections embedded with a
+// different model than the first choice cannot be searched with the same query embedding
+// and are skipped.
+func loadRoutedCorpus(choices []route.Choice) (*corpus, []route.Choice, error) {
+	first, err := loadCorpus("", choices[0].Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	used := []route.Choice{choices[0]}
+	if len(choices) == 1 {
+		return first, used, nil
+	}
+	merged := &corpus{items: first.items, model: first.model}
+	for _, choice := range choices[1:] {
+		c, err := loadCorpus("", choice.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !sameModel(c.model, first.model) {
+			fmt.Printf("Note: skipping collection %s, embedded with %s rather than %s\n", choice.Name, c.model, first.model)
+			continue
+		}
+		merged.items = append(merged.items, c.items...)
+		used = append(used, choice)
+	}
+	return merged, used, nil
+}
+
+// formatRouted renders routed collections with their scores
+func formatRouted(choices []route.Choice) string {
+	s := ""
+	for i, c := range choices {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%s (%.2f)", c.Name, c.Score)
+	}
+	return s
+}
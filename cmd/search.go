@@ -1,12 +1,18 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"math"
 	"os"
-	"sort"
 	"strings"
+	"sync"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/graph"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/rerank"
+	"kirk-ai/internal/vectorstore"
+	"kirk-ai/internal/workspace"
 
 	"github.com/spf13/cobra"
 )
@@ -15,21 +21,28 @@ var (
 	searchEmbeddingsFile string
 	searchTopK           int
 	searchThreshold      float64
+	searchCollection     string
+	searchLinkGraph      string
+	searchAuthority      float64
+	searchKeywordOnly    bool
+	exactSearch          bool     // skip the approximate index (search and rag --exact)
+	searchFilters        []string // metadata conditions (search and rag --filter)
+	queryEmbedModel      string   // embedding model for the query (search and rag --embed-model)
+	ignoreSourceWeights  bool     // skip the settings file's source weights (search and rag --no-source-weights)
 )
 
-type embeddingItem struct {
-	ID         string                 `json:"id"`
-	ChunkIndex int                    `json:"chunk_index"`
-	Content    string                 `json:"content,omitempty"`
-	Metadata   map[string]interface{} `json:"metadata,omitempty"`
-	Embedding  []float64              `json:"embedding,omitempty"`
-	Error      string                 `json:"error,omitempty"`
-}
+// Defaults of search --top-k and --threshold
+const (
+	defaultSearchTopK      = 5
+	defaultSearchThreshold = 0.7
+)
 
-type searchResult struct {
-	Item       embeddingItem
-	Similarity float64
-}
+// defaultLinkGraphFile is where the requests crawler writes the link graph
+var defaultLinkGraphFile = workspace.Default(workspace.LinkGraph)
+
+type embeddingItem = vectorstore.Item
+
+type searchResult = vectorstore.SearchResult
 
 var searchCmd = &cobra.Command{
 	Use:   "search [query]",
@@ -42,157 +55,611 @@ var searchCmd = &cobra.Command{
 func runSearchCommand(cmd *cobra.Command, args []string) {
 	query := strings.Join(args, " ")
 
-	if searchEmbeddingsFile == "" {
-		fmt.Println("Please specify embeddings file with --embeddings flag")
+	if searchEmbeddingsFile == "" && searchCollection == "" && chunksFile == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag, a collection with --collection, or chunks with --chunks")
+		os.Exit(1)
+	}
+	if chunksFile != "" && (searchEmbeddingsFile != "" || searchCollection != "") {
+		fmt.Println("--chunks cannot be combined with --embeddings or --collection")
+		os.Exit(1)
+	}
+	checkRerankFlags()
+	if searchKeywordOnly && (hybridSearch || rerankMethod == rerank.LLM || searchAuthority > 0) {
+		fmt.Println("--keyword cannot be combined with --hybrid, --rerank llm, or --authority-weight")
 		os.Exit(1)
 	}
 
-	// Load embeddings
-	embeddings, err := loadEmbeddings(searchEmbeddingsFile)
+	// Load embeddings, or embed the --chunks for this run
+	var corp *corpus
+	var err error
+	if chunksFile != "" {
+		corp, err = loadAdHocCorpus(chunksFile, !searchKeywordOnly, true)
+	} else {
+		corp, err = loadSearchCorpus(searchEmbeddingsFile, searchCollection)
+	}
 	if err != nil {
 		fmt.Printf("Error loading embeddings: %v\n", err)
 		os.Exit(1)
 	}
 
 	if verbose {
-		fmt.Printf("Loaded %d embeddings\n", len(embeddings))
+		fmt.Printf("Loaded %d embeddings\n", corp.Len())
+	}
+	corp.setFilter(searchFilters)
+
+	if searchKeywordOnly {
+		runKeywordSearch(corp, query)
+		return
 	}
 
 	// Generate embedding for query
-	queryEmbedding, err := generateQueryEmbedding(query)
+	queryEmbedding, err := corp.embedQuery(context.Background(), query, queryEmbedModel)
 	if err != nil {
 		fmt.Printf("Error generating query embedding: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Search for similar embeddings
-	results := searchSimilar(queryEmbedding, embeddings, searchTopK, searchThreshold)
+	threshold := searchThreshold
+	if !cmd.Flags().Changed("threshold") && corp.calibration != nil {
+		threshold = corp.calibration.Search
+	}
+
+	// Search for similar embeddings; fetch extra candidates when re-ranking
+	candidates := candidateCount(searchTopK, searchAuthority)
+	results, err := corp.Search(queryEmbedding, candidates, threshold)
+	if err != nil {
+		fmt.Printf("Error searching embeddings: %v\n", err)
+		os.Exit(1)
+	}
+	results, err = hybridResults(corp, query, queryEmbedding, results, candidates)
+	if err != nil {
+		fmt.Printf("Error searching by keyword: %v\n", err)
+		os.Exit(1)
+	}
+	results, err = applyAuthority(results, searchLinkGraph, searchAuthority, rerankPool(searchTopK, candidates))
+	if err != nil {
+		fmt.Printf("Error loading link graph: %v\n", err)
+		os.Exit(1)
+	}
+	results, err = applySourceTrust(results, rerankPool(searchTopK, candidates))
+	if err != nil {
+		fmt.Printf("Error weighting sources: %v\n", err)
+		os.Exit(1)
+	}
+	results, err = rerankResults(query, results, searchTopK)
+	if err != nil {
+		fmt.Printf("Error reranking results: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Display results
-	displaySearchResults(query, results)
+	displaySearchResults(query, results, threshold)
+}
+
+// runKeywordSearch ranks chunks by BM25 alone, without embedding the query, so search
+// works while the server is down. Scores are BM25 scores, so no threshold applies.
+func runKeywordSearch(corp *corpus, query string) {
+	results, err := corp.keywordSearch(query, candidateCount(searchTopK, 0))
+	if err != nil {
+		fmt.Printf("Error searching by keyword: %v\n", err)
+		os.Exit(1)
+	}
+	results, err = rerankResults(query, results, searchTopK)
+	if err != nil {
+		fmt.Printf("Error reranking results: %v\n", err)
+		os.Exit(1)
+	}
+	displaySearchResults(query, results, 0)
 }
 
+// loadEmbeddings reads the searchable items of an embeddings file, which must share one
+// dimension
 func loadEmbeddings(filename string) ([]embeddingItem, error) {
-	data, err := os.ReadFile(filename)
+	embeddings, err := vectorstore.ReadItems(filename)
 	if err != nil {
 		return nil, err
 	}
+	items := searchableItems(embeddings)
+	if _, err := vectorstore.Dimension(items); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return items, nil
+}
+
+// corpus is the set of embeddings a query runs against: items loaded into memory, or a
+// sharded index that is streamed shard by shard on each search
+type corpus struct {
+	items  []embeddingItem
+	shards *vectorstore.ShardManifest
+	model  string // embedding model recorded for the corpus ("" when unknown)
+	// dimension is the length of the corpus's vectors (0 when unknown); queries must match it
+	dimension int
+	// detect guards model, which embedQuery sets when it detects the model of a corpus that
+	// does not record one
+	detect sync.Mutex
+	// calibration holds thresholds measured by `embeddings calibrate` (nil when uncalibrated)
+	calibration *vectorstore.Calibration
+	remote      *vectorstore.Qdrant // set when the corpus lives in Qdrant
+	daemon      *daemonCorpus       // set when a running daemon holds the corpus
+	ann         *vectorstore.HNSW   // approximate index built by `embeddings index` (nil for exact search)
+	annPath     string              // where the corpus's approximate index lives ("" when it cannot have one)
+	keywordPath string              // where the corpus's keyword index lives ("" when it cannot have one)
+	// keywords is the BM25 index, read or built on the first keyword search
+	keywords *vectorstore.KeywordIndex
+	// filter holds the --filter conditions chunks must meet to be searched
+	filter vectorstore.Filter
+	// failed counts the chunks left out because they failed to embed, by kind
+	failed map[vectorstore.ErrorKind]int
+}
+
+// Len returns the number of chunks in the corpus
+func (c *corpus) Len() int {
+	if c.daemon != nil {
+		return c.daemon.count
+	}
+	if c.remote != nil {
+		n, _ := c.remote.Count(context.Background())
+		return n
+	}
+	if c.shards != nil {
+		return c.shards.Total
+	}
+	return len(c.items)
+}
+
+// Search returns up to topK results at or above threshold among the chunks passing the
+// corpus filter. A filtered search compares every chunk that passes, bypassing the
+// approximate index.
+func (c *corpus) Search(queryEmbedding []float64, topK int, threshold float64) ([]searchResult, error) {
+	if c.daemon != nil {
+		return c.daemon.search(queryEmbedding, topK, threshold)
+	}
+	return c.searchWith(queryEmbedding, topK, threshold, c.filter, true)
+}
 
-	var embeddings []embeddingItem
-	if err := json.Unmarshal(data, &embeddings); err != nil {
+// searchWith is Search with the filter given and the approximate index used only when
+// approximate is set, so concurrent searches of one corpus can differ in both
+func (c *corpus) searchWith(queryEmbedding []float64, topK int, threshold float64, filter vectorstore.Filter, approximate bool) ([]searchResult, error) {
+	if c.remote != nil {
+		return c.remote.Search(context.Background(), queryEmbedding, topK, threshold)
+	}
+	if c.shards != nil {
+		return c.shards.Search(queryEmbedding, topK, threshold, filter)
+	}
+	if approximate && c.ann != nil && topK > 0 && len(filter) == 0 {
+		return c.ann.Search(queryEmbedding, topK, threshold), nil
+	}
+	return vectorstore.Search(queryEmbedding, filter.Apply(c.items), topK, threshold), nil
+}
+
+// setFilter parses --filter conditions into the corpus, exiting when one is invalid or the
+// corpus lives in Qdrant, whose server-side search cannot apply them
+func (c *corpus) setFilter(exprs []string) {
+	f, err := vectorstore.ParseFilter(exprs)
+	if err != nil {
+		fmt.Printf("Error in --filter: %v\n", err)
+		os.Exit(1)
+	}
+	if len(f) > 0 && c.remote != nil {
+		fmt.Println("--filter is not supported for Qdrant collections")
+		os.Exit(1)
+	}
+	c.filter = f
+	if c.daemon != nil {
+		c.daemon.filter = exprs
+		return
+	}
+	if verbose && len(f) > 0 && c.shards == nil {
+		fmt.Printf("Filter matches %d of %d chunks\n", len(f.Apply(c.items)), len(c.items))
+	}
+}
+
+// loadCorpus loads embeddings from a file or, when collection is set, from the store.
+// Shard manifests are opened without loading the shards, and Qdrant collections (a
+// qdrant:// file or --store) are searched on the server. An up-to-date approximate index
+// is used for searching unless --exact is set.
+func loadCorpus(filename, collection string) (*corpus, error) {
+	target, err := corpusTarget(filename, collection)
+	if err != nil {
 		return nil, err
 	}
+	return loadTarget(target)
+}
 
-	// Filter out items with errors or missing embeddings
-	validEmbeddings := make([]embeddingItem, 0, len(embeddings))
-	for _, item := range embeddings {
-		if item.Error == "" && len(item.Embedding) > 0 {
-			validEmbeddings = append(validEmbeddings, item)
+// loadTarget is loadCorpus for a resolved file or store collection
+func loadTarget(target collectionTarget) (*corpus, error) {
+	c, calibrationPath, err := openTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	c.reportFailed()
+	if c.annPath != "" && !exactSearch {
+		if err := c.loadANN(); err != nil {
+			return nil, err
+		}
+	}
+	if calibrationPath == "" {
+		return c, nil
+	}
+	cal, err := vectorstore.ReadCalibration(calibrationPath)
+	if err != nil {
+		return nil, err
+	}
+	// A calibration measured with another embedding model says nothing about this one
+	if cal != nil && (c.model == "" || sameModel(cal.EmbeddingModel, c.model)) {
+		c.calibration = cal
+		if verbose {
+			fmt.Printf("Using calibrated thresholds from %s\n", calibrationPath)
 		}
 	}
+	return c, nil
+}
 
-	return validEmbeddings, nil
+// openCorpus loads the corpus and returns it with the path of its calibration file ("" for
+// Qdrant collections, which are not calibrated). A collection name registered with
+// `collections create` is opened wherever it points.
+func openCorpus(filename, collection string) (*corpus, string, error) {
+	target, err := corpusTarget(filename, collection)
+	if err != nil {
+		return nil, "", err
+	}
+	return openTarget(target)
 }
 
-func generateQueryEmbedding(query string) ([]float64, error) {
-	// Auto-select embedding model
-	models, err := ollamaClient.ListModels()
+// corpusTarget resolves an embeddings file or collection name to where its embeddings live
+func corpusTarget(filename, collection string) (collectionTarget, error) {
+	if collection == "" {
+		return collectionTarget{file: filename}, nil
+	}
+	return resolveCollection(collection)
+}
+
+// openTarget is openCorpus for a resolved file or store collection
+func openTarget(target collectionTarget) (*corpus, string, error) {
+	filename, storePath, collection := target.file, target.store, target.collection
+	if target.remote() {
+		address := filename
+		if collection != "" {
+			address = storePath
+		}
+		q, err := openQdrant(address, collection)
+		if err != nil {
+			return nil, "", err
+		}
+		model, err := q.EmbeddingModel(context.Background())
+		if err != nil {
+			return nil, "", err
+		}
+		dim, err := q.Dimension(context.Background())
+		if err != nil {
+			return nil, "", err
+		}
+		return &corpus{remote: q, model: model, dimension: dim}, "", nil
+	}
+	if collection == "" {
+		if vectorstore.IsShardManifest(filename) {
+			m, err := vectorstore.ReadShardManifest(filename)
+			if err != nil {
+				return nil, "", err
+			}
+			return &corpus{shards: m, model: m.EmbeddingModel, dimension: m.Dimension, failed: m.Errors}, vectorstore.CalibrationPath(filename), nil
+		}
+		all, err := vectorstore.ReadItemsLazy(filename)
+		if err != nil {
+			return nil, "", err
+		}
+		embeddings := searchableItems(all)
+		dim, err := vectorstore.Dimension(embeddings)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", filename, err)
+		}
+		c := &corpus{items: embeddings, model: recordedModel(embeddings), dimension: dim, annPath: vectorstore.ANNPath(filename), keywordPath: vectorstore.KeywordPath(filename), failed: vectorstore.ErrorCounts(all)}
+		return c, vectorstore.CalibrationPath(filename), nil
+	}
+	store := vectorstore.NewStore(storePath)
+	items, info, err := store.LoadLazy(collection)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	c := &corpus{items: searchableItems(items), model: info.EmbeddingModel, dimension: info.Dimension, annPath: store.ANNPath(collection), keywordPath: store.KeywordPath(collection), failed: vectorstore.ErrorCounts(items)}
+	return c, store.CalibrationPath(collection), nil
+}
 
-	selectedModel := ollamaClient.SelectEmbeddingModel(models)
-	if selectedModel == "" {
-		return nil, fmt.Errorf("no suitable embedding model found")
+// reportFailed warns that chunks which failed to embed are missing from search results,
+// and why
+func (c *corpus) reportFailed() {
+	n := 0
+	for _, count := range c.failed {
+		n += count
 	}
+	if n == 0 {
+		return
+	}
+	fmt.Printf("Warning: %d chunks failed to embed and are left out of the search (%s)\n", n, vectorstore.FormatErrorCounts(c.failed))
+}
 
+// loadANN attaches the corpus's approximate index when one exists. A stale index is
+// ignored with a warning, since exact search still gives correct results.
+func (c *corpus) loadANN() error {
+	ann, err := vectorstore.ReadHNSW(c.annPath)
+	if err != nil || ann == nil {
+		return err
+	}
+	if err := ann.Attach(c.items); err != nil {
+		fmt.Printf("Warning: ignoring %s: %v (rebuild with 'embeddings index')\n", c.annPath, err)
+		return nil
+	}
+	c.ann = ann
 	if verbose {
-		fmt.Printf("Using model for query: %s\n", selectedModel)
+		fmt.Printf("Using approximate index %s\n", c.annPath)
 	}
+	return nil
+}
 
-	response, err := ollamaClient.Embedding(selectedModel, query)
+// openQdrant returns the Qdrant collection addressed by a qdrant:// value; collection, when
+// set, overrides a collection named in the address
+func openQdrant(address, collection string) (*vectorstore.Qdrant, error) {
+	server, name, err := vectorstore.ParseQdrant(address)
 	if err != nil {
 		return nil, err
 	}
+	if collection != "" {
+		name = collection
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%s names no collection (use qdrant://host:port/collection or --collection)", address)
+	}
+	return vectorstore.NewQdrant(server, name), nil
+}
 
-	return response.Embedding, nil
+// searchableItems filters out items with errors, missing embeddings, or removed sources
+func searchableItems(embeddings []embeddingItem) []embeddingItem {
+	validEmbeddings := make([]embeddingItem, 0, len(embeddings))
+	for _, item := range embeddings {
+		if item.Searchable() {
+			validEmbeddings = append(validEmbeddings, item)
+		}
+	}
+	return validEmbeddings
+}
+
+// addFilterFlag registers --filter on a command that searches embeddings
+func addFilterFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&searchFilters, "filter", nil,
+		`Only search chunks whose metadata matches, e.g. 'source_url~tpusa.com/events' or 'crawled_at>2024-01-01' (repeatable; all must match)`)
+}
+
+// addSourceWeightsFlag registers --no-source-weights on a command that ranks search results
+func addSourceWeightsFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&ignoreSourceWeights, "no-source-weights", false,
+		"Rank by similarity alone, ignoring the source weights in "+config.SettingsFile)
 }
 
-func cosineSimilarity(a, b []float64) float64 {
-	if len(a) != len(b) {
-		return 0
+// candidateCount widens the first-stage search when results will be re-ranked
+func candidateCount(topK int, authorityWeight float64) int {
+	trust, _ := sourceTrust()
+	if (authorityWeight > 0 || rerankMethod != rerank.Off || hybridSearch || trust != nil) && topK > 0 {
+		return topK * 3
 	}
+	return topK
+}
 
-	var dotProduct, normA, normB float64
-	for i := range a {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
+// applyAuthority blends PageRank authority from the crawler's link graph into the ranking.
+// A weight of 0 leaves results unchanged.
+func applyAuthority(results []searchResult, graphFile string, weight float64, topK int) ([]searchResult, error) {
+	if weight <= 0 {
+		return results, nil
+	}
+	if weight > 1 {
+		weight = 1
+	}
+	if graphFile == "" {
+		graphFile = workspace.Path(workspace.LinkGraph)
+	}
+	g, err := graph.Load(graphFile)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Printf("Blending authority from %s (%d pages, weight %.2f)\n", graphFile, len(g), weight)
 	}
+	return vectorstore.BlendAuthority(results, graph.Authority(g), weight, topK), nil
+}
 
-	if normA == 0 || normB == 0 {
-		return 0
+// sourceTrust returns the source weights of the settings file, or nil when none are set or
+// --no-source-weights is given. The settings are read once.
+var sourceTrust = sync.OnceValues(func() (*vectorstore.SourceTrust, error) {
+	if ignoreSourceWeights {
+		return nil, nil
 	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	if len(settings.Sources) == 0 {
+		return nil, nil
+	}
+	return vectorstore.NewSourceTrust(settings.Sources)
+})
 
-	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+// applySourceTrust scales each result's score by the weight the settings file gives its
+// source, keeping up to topK. Without source weights results are unchanged.
+func applySourceTrust(results []searchResult, topK int) ([]searchResult, error) {
+	trust, err := sourceTrust()
+	if err != nil {
+		return nil, fmt.Errorf("%s sources: %w", config.SettingsFile, err)
+	}
+	if trust == nil {
+		return results, nil
+	}
+	if verbose {
+		fmt.Printf("Weighting results by source with the sources in %s\n", config.SettingsFile)
+	}
+	return vectorstore.ApplyTrust(results, trust, topK), nil
 }
 
-func searchSimilar(queryEmbedding []float64, embeddings []embeddingItem, topK int, threshold float64) []searchResult {
-	candidates := []searchResult{}
-
-	for _, item := range embeddings {
-		if len(item.Embedding) == 0 {
-			continue
+// recordedModel returns the embedding model named in the items' provenance, or "" when
+// none is recorded or the items disagree
+func recordedModel(items []embeddingItem) string {
+	model := ""
+	for _, it := range items {
+		m := provenance.Get(it.Metadata).EmbeddingModel
+		switch {
+		case m == "":
+		case model == "":
+			model = m
+		case !sameModel(m, model):
+			return ""
 		}
+	}
+	return model
+}
 
-		similarity := cosineSimilarity(queryEmbedding, item.Embedding)
-		if similarity >= threshold {
-			candidates = append(candidates, searchResult{Item: item, Similarity: similarity})
+// sameModel reports whether two model names refer to the same model; a name without a tag
+// means :latest, as in Ollama
+func sameModel(a, b string) bool {
+	normalize := func(name string) string {
+		name = strings.ToLower(name)
+		if !strings.Contains(name, ":") {
+			name += ":latest"
 		}
+		return name
 	}
+	return normalize(a) == normalize(b)
+}
 
-	// Sort by similarity (descending)
-	sort.SliceStable(candidates, func(i, j int) bool {
-		return candidates[i].Similarity > candidates[j].Similarity
-	})
+// generateQueryEmbedding embeds the query with requested (--embed-model) when set, else with
+// corpusModel when known, otherwise auto-selects a model. A requested model must match
+// corpusModel, since vectors from different models cannot be compared.
+func generateQueryEmbedding(query, corpusModel, requested string) ([]float64, error) {
+	return queryEmbedding(context.Background(), query, corpusModel, requested)
+}
 
-	// Deduplicate by ID or content prefix and limit to topK
-	seen := map[string]bool{}
-	out := make([]searchResult, 0, len(candidates))
-	for _, c := range candidates {
-		if topK > 0 && len(out) >= topK {
-			break
+// embedQuery embeds a query for searching the corpus, with requested (--embed-model) when
+// set. The query vector must have the corpus's dimension, since vectors of another model
+// would all score 0. When the corpus records no model, the server's embedding models are
+// tried until one matches the dimension, and that model is kept for later queries.
+func (c *corpus) embedQuery(ctx context.Context, query, requested string) ([]float64, error) {
+	if c.daemon != nil {
+		return c.daemon.embed(ctx, query, requested)
+	}
+	c.detect.Lock()
+	corpusModel := c.model
+	c.detect.Unlock()
+	if corpusModel == "" && requested == "" && c.dimension > 0 {
+		model, emb, err := detectEmbeddingModel(ctx, query, c.dimension)
+		if err != nil {
+			return nil, err
 		}
+		c.detect.Lock()
+		c.model = model
+		c.detect.Unlock()
+		return emb, nil
+	}
+	emb, err := queryEmbedding(ctx, query, corpusModel, requested)
+	if err != nil {
+		return nil, err
+	}
+	if c.dimension > 0 && len(emb) != c.dimension {
+		model := requested
+		if model == "" {
+			model = corpusModel
+		}
+		return nil, fmt.Errorf("%s returns %d-dimensional vectors but the embeddings have %d, so they were built with another model; "+
+			"pass that model with --embed-model, or re-embed with 'embeddings migrate'", model, len(emb), c.dimension)
+	}
+	return emb, nil
+}
 
-		key := c.Item.ID
-		if key == "" {
-			// Fallback to content prefix for deduplication; include chunk index if content missing
-			key = c.Item.Content
-			if key == "" {
-				key = fmt.Sprintf("chunk_%d", c.Item.ChunkIndex)
+// detectEmbeddingModel embeds query with each embedding model on the server, the default
+// choice first, and returns the first whose vectors have dimension dim. Models the server
+// reports another dimension for are not tried.
+func detectEmbeddingModel(ctx context.Context, query string, dim int) (string, []float64, error) {
+	models, err := llmClient.ListModelsContext(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	var candidates []string
+	first := llmClient.SelectEmbeddingModel(models)
+	if facts, err := llmClient.ModelFacts(ctx, models); err == nil {
+		for _, f := range facts {
+			if !hasEmbeddingModel([]config.ModelFacts{f}) || (f.EmbeddingLength > 0 && f.EmbeddingLength != dim) {
+				continue
 			}
-			// Limit key length to avoid excessive map keys
-			if len(key) > 200 {
-				key = key[:200]
+			if f.Name == first {
+				candidates = append([]string{first}, candidates...)
+			} else {
+				candidates = append(candidates, f.Name)
 			}
 		}
+	} else {
+		if first != "" {
+			candidates = append(candidates, first)
+		}
+		for _, m := range models {
+			if strings.Contains(strings.ToLower(m), "embed") && m != first {
+				candidates = append(candidates, m)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil, fmt.Errorf("no installed embedding model returns %d-dimensional vectors like the embeddings (pick one with --embed-model)", dim)
+	}
+	for _, m := range candidates {
+		resp, err := llmClient.EmbeddingContext(ctx, m, query)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(resp.Embedding) == dim {
+			if verbose {
+				fmt.Printf("Using model for query: %s (matches the embeddings' dimension %d)\n", m, dim)
+			}
+			return m, resp.Embedding, nil
+		}
+	}
+	return "", nil, fmt.Errorf("the embeddings record no model and none of %s returns %d-dimensional vectors like them; "+
+		"pass the model they were built with via --embed-model", strings.Join(candidates, ", "), dim)
+}
 
-		if seen[key] {
-			continue
+// queryEmbedding is generateQueryEmbedding with the embedding request bound to ctx
+func queryEmbedding(ctx context.Context, query, corpusModel, requested string) ([]float64, error) {
+	selectedModel := corpusModel
+	if requested != "" {
+		if corpusModel != "" && !sameModel(requested, corpusModel) {
+			return nil, fmt.Errorf("--embed-model %s does not match %s, the model the embeddings were built with", requested, corpusModel)
 		}
-		seen[key] = true
-		out = append(out, c)
+		selectedModel = requested
+	}
+	if selectedModel == "" {
+		// Auto-select embedding model
+		models, err := llmClient.ListModels()
+		if err != nil {
+			return nil, err
+		}
+
+		if selectedModel, err = selectEmbeddingModel(models); err != nil {
+			return nil, err
+		}
+	}
+
+	if verbose {
+		fmt.Printf("Using model for query: %s\n", selectedModel)
 	}
 
-	return out
+	response, err := llmClient.EmbeddingContext(ctx, selectedModel, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Embedding, nil
 }
 
-func displaySearchResults(query string, results []searchResult) {
+func displaySearchResults(query string, results []searchResult, threshold float64) {
 	fmt.Printf("Search results for: \"%s\"\n", query)
 	fmt.Println(strings.Repeat("=", 50))
 
 	if len(results) == 0 {
-		fmt.Printf("No results found above similarity threshold %.3f\n", searchThreshold)
+		fmt.Printf("No results found above similarity threshold %.3f\n", threshold)
 		return
 	}
 
@@ -200,6 +667,12 @@ func displaySearchResults(query string, results []searchResult) {
 		fmt.Printf("\n[%d] Chunk %d (Similarity: %.4f)\n",
 			i+1, result.Item.ChunkIndex, result.Similarity)
 		fmt.Printf("ID: %s\n", result.Item.ID)
+		if link := vectorstore.DeepLink(result.Item); link != "" {
+			fmt.Printf("Source: %s\n", link)
+		}
+		if section := metadataString(result.Item.Metadata, "section"); section != "" {
+			fmt.Printf("Section: %s\n", section)
+		}
 
 		// Display content if available
 		if result.Item.Content != "" {
@@ -220,19 +693,36 @@ func displaySearchResults(query string, results []searchResult) {
 
 	if verbose {
 		fmt.Printf("\nFound %d results above threshold %.3f\n",
-			len(results), searchThreshold)
+			len(results), threshold)
 	}
 }
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
+	requireServer(searchCmd, "keyword")
 
 	searchCmd.Flags().StringVar(&searchEmbeddingsFile, "embeddings", "",
-		"Path to embeddings JSON file (required)")
-	searchCmd.Flags().IntVar(&searchTopK, "top-k", 5,
+		"Path to embeddings JSON file (required unless --collection is set)")
+	searchCmd.Flags().StringVar(&searchCollection, "collection", "",
+		"Search a named collection (registered with 'collections create', or in --store) instead of an embeddings file")
+	searchCmd.Flags().IntVar(&searchTopK, "top-k", defaultSearchTopK,
 		"Number of top results to return")
-	searchCmd.Flags().Float64Var(&searchThreshold, "threshold", 0.7,
-		"Minimum similarity threshold (0.0-1.0)")
-
-	searchCmd.MarkFlagRequired("embeddings")
+	searchCmd.Flags().Float64Var(&searchThreshold, "threshold", defaultSearchThreshold,
+		"Minimum similarity threshold (0.0-1.0); a calibrated corpus uses its recorded threshold unless set")
+	searchCmd.Flags().BoolVar(&exactSearch, "exact", false,
+		"Compare against every embedding even when an approximate index exists")
+	searchCmd.Flags().StringVar(&queryEmbedModel, "embed-model", "",
+		"Embedding model for the query; must match the model the embeddings were built with (default: the recorded model, else auto-select)")
+	searchCmd.Flags().Float64Var(&searchAuthority, "authority-weight", 0,
+		"Blend link-graph PageRank into ranking with this weight (0 = similarity only, max 1)")
+	searchCmd.Flags().StringVar(&searchLinkGraph, "link-graph", "",
+		"Link graph JSONL written by the requests crawler (default "+defaultLinkGraphFile+")")
+	searchCmd.Flags().BoolVar(&searchKeywordOnly, "keyword", false,
+		"Rank chunks by keyword (BM25) only, without embedding the query; works while the server is down")
+	addSourceWeightsFlag(searchCmd)
+	addDaemonFlag(searchCmd)
+	addChunksFlag(searchCmd)
+	addFilterFlag(searchCmd)
+	addHybridFlag(searchCmd)
+	addRerankFlags(searchCmd)
 }
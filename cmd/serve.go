@@ -0,0 +1,459 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/rag"
+	"kirk-ai/internal/vectorstore"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr        string
+	serveEmbeddings  string
+	serveCollection  string
+	serveTopK        int
+	serveContextSize int
+	serveThreshold   float64
+	serveMaxContext  int
+	serveMaxBody     int64
+	serveConcurrent  int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve chat, search, and RAG over a local HTTP API",
+	Long: `Start an HTTP server so web UIs and scripts can use chat, semantic search, and RAG without
+shelling out to the CLI. Endpoints take and return JSON:
+
+  POST /v1/chat    {"prompt": "..."} or {"messages": [...]}, optional "model" and "stream"
+  POST /v1/search  {"query": "...", "top_k": 5, "threshold": 0.7}
+  POST /v1/rag     {"question": "...", "context_size": 3, "threshold": 0.3, "model": "...", "stream": false}
+
+Search and RAG run against the corpus given with --embeddings or --collection, loaded once
+at startup. With "stream": true, replies are newline-delimited JSON sent as they generate.
+At most --max-concurrent replies generate at once; further requests wait for a free slot.
+
+OpenAI SDK clients can use http://<addr>/v1 as their base URL: POST /v1/chat/completions
+(streamed as server-sent events with "stream": true) and GET /v1/models follow the OpenAI
+API. When a corpus is loaded, the passages most similar to the last user message are added
+to it before the model answers.`,
+	Args: cobra.NoArgs,
+	Run:  runServeCommand,
+}
+
+// server answers API requests against a corpus loaded at startup (nil when none was given)
+type server struct {
+	corp *corpus
+	// threshold is the /v1/search default: --threshold, or the corpus's calibrated one
+	threshold float64
+	// slots bounds the replies generated at once (nil = unbounded); searches are not limited
+	slots chan struct{}
+}
+
+// serveChatRequest is the body of POST /v1/chat
+type serveChatRequest struct {
+	Model    string           `json:"model"`
+	Prompt   string           `json:"prompt"`
+	Messages []models.Message `json:"messages"`
+	Stream   bool             `json:"stream"`
+}
+
+// serveSearchRequest is the body of POST /v1/search
+type serveSearchRequest struct {
+	Query     string   `json:"query"`
+	TopK      int      `json:"top_k"`
+	Threshold *float64 `json:"threshold"`
+}
+
+// serveRAGRequest is the body of POST /v1/rag
+type serveRAGRequest struct {
+	Question    string   `json:"question"`
+	Model       string   `json:"model"`
+	ContextSize int      `json:"context_size"`
+	Threshold   *float64 `json:"threshold"`
+	Stream      bool     `json:"stream"`
+}
+
+// serveResult is one chunk returned by search or used as RAG context
+type serveResult struct {
+	ID         string                 `json:"id"`
+	Similarity float64                `json:"similarity"`
+	Title      string                 `json:"title,omitempty"`
+	Source     string                 `json:"source,omitempty"` // deep link to the passage
+	Section    string                 `json:"section,omitempty"`
+	Content    string                 `json:"content,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// serveSearchResponse is the reply of POST /v1/search
+type serveSearchResponse struct {
+	Results   []serveResult `json:"results"`
+	Threshold float64       `json:"threshold"`
+}
+
+// serveRAGResponse is the reply of POST /v1/rag, and the last line of a streamed one
+type serveRAGResponse struct {
+	Answer      string        `json:"answer"`
+	Model       string        `json:"model"`
+	Sources     []serveResult `json:"sources"`
+	Attribution string        `json:"attribution,omitempty"`
+	Done        bool          `json:"done,omitempty"`
+}
+
+// serveChunk is a piece of a streamed RAG answer
+type serveChunk struct {
+	Content string `json:"content"`
+}
+
+func runServeCommand(cmd *cobra.Command, args []string) {
+	s := &server{threshold: serveThreshold}
+	if serveConcurrent > 0 {
+		s.slots = make(chan struct{}, serveConcurrent)
+	}
+	if serveEmbeddings != "" || serveCollection != "" {
+		corp, err := loadCorpus(serveEmbeddings, serveCollection)
+		if err != nil {
+			fmt.Printf("Error loading embeddings: %v\n", err)
+			os.Exit(1)
+		}
+		s.corp = corp
+		if !cmd.Flags().Changed("threshold") && corp.calibration != nil {
+			s.threshold = corp.calibration.Search
+		}
+		fmt.Printf("Loaded %d embeddings\n", corp.Len())
+	} else {
+		fmt.Println("No --embeddings or --collection given; /v1/search and /v1/rag are disabled")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat", s.post(s.handleChat))
+	mux.HandleFunc("/v1/search", s.post(s.handleSearch))
+	mux.HandleFunc("/v1/rag", s.post(s.handleRAG))
+	mux.HandleFunc("/v1/chat/completions", s.openAIRoute(http.MethodPost, s.handleChatCompletions))
+	mux.HandleFunc("/v1/models", s.openAIRoute(http.MethodGet, s.handleModels))
+	srv := &http.Server{Addr: serveAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving on http://%s\n", serveAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error serving: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// post wraps a handler that accepts only POST requests with a JSON body of at most
+// --max-body bytes
+func (s *server) post(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, serveMaxBody)
+		if verbose {
+			fmt.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		}
+		h(w, r)
+	}
+}
+
+func (s *server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req serveChatRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	messages := req.Messages
+	if req.Prompt != "" {
+		messages = append(messages, models.Message{Role: "user", Content: req.Prompt})
+	}
+	if len(messages) == 0 {
+		writeError(w, http.StatusBadRequest, "prompt or messages is required")
+		return
+	}
+	selected := req.Model
+	if selected == "" {
+		var err error
+		if selected, err = chatModel(); err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+	}
+	request := models.ChatRequest{Model: selected, Messages: messages}
+	release, err := s.acquire(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer release()
+	if !req.Stream {
+		resp, err := llmClient.ChatWithRequest(r.Context(), request)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	out := newNDJSON(w)
+	_, err = llmClient.ChatStreamWithRequest(r.Context(), request, func(chunk *models.StreamingChatResponse) error {
+		return out.send(chunk)
+	})
+	if err != nil {
+		out.fail(err)
+	}
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var req serveSearchRequest
+	if !decodeRequest(w, r, &req) || !s.requireCorpus(w) {
+		return
+	}
+	if req.Query == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+	topK := req.TopK
+	if topK <= 0 {
+		topK = serveTopK
+	}
+	threshold := s.threshold
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+	results, err := s.search(r.Context(), req.Query, topK, threshold)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, serveSearchResponse{Results: serveResults(results, true), Threshold: threshold})
+}
+
+func (s *server) handleRAG(w http.ResponseWriter, r *http.Request) {
+	var req serveRAGRequest
+	if !decodeRequest(w, r, &req) || !s.requireCorpus(w) {
+		return
+	}
+	if req.Question == "" {
+		writeError(w, http.StatusBadRequest, "question is required")
+		return
+	}
+	contextSize := req.ContextSize
+	if contextSize <= 0 {
+		contextSize = serveContextSize
+	}
+	threshold := ragThreshold(s.corp, contextSize)
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+	results, err := s.search(r.Context(), req.Question, contextSize, threshold)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	contextText, used := rag.BuildContext(results, serveMaxContext)
+	if len(used) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no relevant context found (threshold %.2f)", threshold))
+		return
+	}
+	requested := req.Model
+	if requested == "" {
+		requested = ragModel
+	}
+	selected, err := selectRAGModel(requested, ragPreferFast)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	answer := serveRAGResponse{Model: selected, Sources: serveResults(used, false), Attribution: rag.Attribution(used)}
+	request := models.ChatRequest{Model: selected, Messages: []models.Message{{Role: "user", Content: rag.BuildPrompt(req.Question, contextText)}}}
+	release, err := s.acquire(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer release()
+	if !req.Stream {
+		resp, err := llmClient.ChatWithRequest(r.Context(), request)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		answer.Answer = resp.Message.Content
+		writeJSON(w, http.StatusOK, answer)
+		return
+	}
+	out := newNDJSON(w)
+	resp, err := llmClient.ChatStreamWithRequest(r.Context(), request, func(chunk *models.StreamingChatResponse) error {
+		return out.send(serveChunk{Content: chunk.Message.Content})
+	})
+	if err != nil {
+		out.fail(err)
+		return
+	}
+	answer.Answer, answer.Done = resp.Message.Content, true
+	_ = out.send(answer)
+}
+
+// acquire waits for a generation slot or for the request to end; the returned func releases it
+func (s *server) acquire(ctx context.Context) (func(), error) {
+	if s.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// search embeds query and returns up to topK chunks at or above threshold. Handlers call it
+// concurrently: the corpus is only read, and its approximate index is safe to search from
+// several goroutines.
+func (s *server) search(ctx context.Context, query string, topK int, threshold float64) ([]searchResult, error) {
+	emb, err := s.corp.embedQuery(ctx, query, queryEmbedModel)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.corp.Search(emb, candidateCount(topK, 0), threshold)
+	if err != nil {
+		return nil, err
+	}
+	return applySourceTrust(results, topK)
+}
+
+// requireCorpus reports whether a corpus is loaded, answering 503 when it is not
+func (s *server) requireCorpus(w http.ResponseWriter) bool {
+	if s.corp == nil {
+		writeError(w, http.StatusServiceUnavailable, "no corpus loaded; start serve with --embeddings or --collection")
+		return false
+	}
+	return true
+}
+
+// serveResults converts search results for a reply; metadata is included when withMetadata
+// is set
+func serveResults(results []searchResult, withMetadata bool) []serveResult {
+	out := make([]serveResult, 0, len(results))
+	for _, r := range results {
+		res := serveResult{
+			ID:         r.Item.ID,
+			Similarity: r.Similarity,
+			Title:      metadataString(r.Item.Metadata, "title"),
+			Source:     vectorstore.DeepLink(r.Item),
+			Section:    metadataString(r.Item.Metadata, "section"),
+			Content:    r.Item.Content,
+		}
+		if withMetadata {
+			res.Metadata = r.Item.Metadata
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+// decodeRequest reads the JSON body into v, answering 400 when it is malformed
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// ndjson streams newline-delimited JSON, flushing each line to the client
+type ndjson struct {
+	w       http.ResponseWriter
+	enc     *json.Encoder
+	started bool
+}
+
+func newNDJSON(w http.ResponseWriter) *ndjson {
+	return &ndjson{w: w, enc: json.NewEncoder(w)}
+}
+
+func (n *ndjson) send(v interface{}) error {
+	if !n.started {
+		n.w.Header().Set("Content-Type", "application/x-ndjson")
+		n.w.WriteHeader(http.StatusOK)
+		n.started = true
+	}
+	if err := n.enc.Encode(v); err != nil {
+		return err
+	}
+	if f, ok := n.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// fail reports err as an error status before anything was streamed, or as a final
+// {"error": ...} line after
+func (n *ndjson) fail(err error) {
+	if !n.started {
+		writeError(n.w, http.StatusBadGateway, err.Error())
+		return
+	}
+	_ = n.send(map[string]string{"error": err.Error()})
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080",
+		"Address to listen on; the API has no authentication, so keep it on localhost unless it sits behind a proxy that adds it")
+	serveCmd.Flags().StringVar(&serveEmbeddings, "embeddings", "",
+		"Embeddings file searched by /v1/search and /v1/rag")
+	serveCmd.Flags().StringVar(&serveCollection, "collection", "",
+		"Search a named collection (registered with 'collections create', or in --store) instead of an embeddings file")
+	serveCmd.Flags().IntVar(&serveTopK, "top-k", 5,
+		"Results returned by /v1/search when the request gives no top_k")
+	serveCmd.Flags().IntVar(&serveContextSize, "context-size", 3,
+		"Context chunks used by /v1/rag when the request gives no context_size")
+	serveCmd.Flags().IntVar(&serveConcurrent, "max-concurrent", 4,
+		"Most replies generated at once; further chat and RAG requests wait for a free slot (0 = unlimited)")
+	serveCmd.Flags().Int64Var(&serveMaxBody, "max-body", 1<<20,
+		"Largest request body accepted, in bytes")
+	serveCmd.Flags().StringVar(&ragModel, "rag-model", "",
+		"Chat model for /v1/rag when the request names none (default: automatic selection)")
+	serveCmd.Flags().Float64Var(&serveThreshold, "threshold", 0.7,
+		"Minimum similarity for /v1/search when the request gives no threshold; a calibrated corpus uses its recorded threshold")
+	serveCmd.Flags().IntVar(&serveMaxContext, "max-context-length", 8000,
+		"Maximum total character length of /v1/rag context")
+	serveCmd.Flags().BoolVar(&exactSearch, "exact", false,
+		"Compare against every embedding even when an approximate index exists")
+	serveCmd.Flags().StringVar(&queryEmbedModel, "embed-model", "",
+		"Embedding model for queries; must match the model the embeddings were built with (default: the recorded model, else auto-select)")
+}
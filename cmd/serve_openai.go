@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/rag"
+)
+
+// The OpenAI-compatible routes let existing OpenAI SDK clients point their base URL at
+// serve. Requests and replies follow the chat completions API; fields kirk-ai does not use
+// (n, tools, logprobs, ...) are accepted and ignored.
+
+// openAIMessage is a chat message in the OpenAI format. Content is a string or a list of
+// content parts, of which the text parts are kept.
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// openAIChatRequest is the body of POST /v1/chat/completions
+type openAIChatRequest struct {
+	Model         string          `json:"model"`
+	Messages      []openAIMessage `json:"messages"`
+	Stream        bool            `json:"stream"`
+	Temperature   *float64        `json:"temperature"`
+	TopP          *float64        `json:"top_p"`
+	MaxTokens     *int            `json:"max_tokens"`
+	MaxCompletion *int            `json:"max_completion_tokens"`
+	Stop          json.RawMessage `json:"stop"` // a string or a list of strings
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIReply struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+type openAIChoice struct {
+	Index        int          `json:"index"`
+	Message      *openAIReply `json:"message,omitempty"`
+	Delta        *openAIReply `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+// openAICompletion is a chat.completion reply, or a chat.completion.chunk when streaming
+type openAICompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+// openAIRoute wraps a handler for an OpenAI-compatible route, answering with OpenAI-style
+// errors
+func (s *server) openAIRoute(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "use "+method)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, serveMaxBody)
+		if verbose {
+			fmt.Printf("%s %s from %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+		}
+		h(w, r)
+	}
+}
+
+// handleModels lists the installed models, so clients can offer them in a model picker
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	names, err := llmClient.ListModels()
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	type entry struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	}
+	data := make([]entry, 0, len(names))
+	for _, name := range names {
+		data = append(data, entry{ID: name, Object: "model", OwnedBy: "kirk-ai"})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": data})
+}
+
+func (s *server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req openAIChatRequest
+	// OpenAI clients send fields kirk-ai has no use for, so unknown fields are not an error
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	messages, err := openAIMessages(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(messages) == 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "messages is required")
+		return
+	}
+	options, err := req.options()
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	grounded, err := s.injectContext(r, messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	selected, err := openAIModel(req.Model, grounded)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	request := models.ChatRequest{Model: selected, Messages: messages, Options: options}
+	id, created := completionID(), time.Now().Unix()
+	release, err := s.acquire(r.Context())
+	if err != nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer release()
+
+	if !req.Stream {
+		resp, err := llmClient.ChatWithRequest(r.Context(), request)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		finish := finishReason(resp.DoneReason)
+		writeJSON(w, http.StatusOK, openAICompletion{
+			ID: id, Object: "chat.completion", Created: created, Model: selected,
+			Choices: []openAIChoice{{Message: &openAIReply{Role: "assistant", Content: resp.Message.Content}, FinishReason: &finish}},
+			Usage:   usage(resp.PromptEvalCount, resp.EvalCount),
+		})
+		return
+	}
+
+	out := newSSE(w)
+	chunk := func(delta *openAIReply, finish *string) openAICompletion {
+		return openAICompletion{ID: id, Object: "chat.completion.chunk", Created: created, Model: selected,
+			Choices: []openAIChoice{{Delta: delta, FinishReason: finish}}}
+	}
+	first := true
+	var promptTokens, completionTokens int
+	var finish string
+	_, err = llmClient.ChatStreamWithRequest(r.Context(), request, func(c *models.StreamingChatResponse) error {
+		if c.Done {
+			promptTokens, completionTokens = c.PromptEvalCount, c.EvalCount
+			finish = finishReason(c.DoneReason)
+		}
+		if c.Message.Content == "" && !first {
+			return nil
+		}
+		delta := &openAIReply{Content: c.Message.Content}
+		if first {
+			delta.Role, first = "assistant", false
+		}
+		return out.send(chunk(delta, nil))
+	})
+	if err != nil {
+		out.fail(err)
+		return
+	}
+	if err := out.send(chunk(&openAIReply{}, &finish)); err != nil {
+		return
+	}
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		final := chunk(nil, nil)
+		final.Choices = []openAIChoice{}
+		final.Usage = usage(promptTokens, completionTokens)
+		_ = out.send(final)
+	}
+	out.done()
+}
+
+// injectContext grounds the conversation in the loaded corpus: the passages most similar to
+// the last user message are added to it as reference material, as rag does. It reports
+// whether any passage was added; without a corpus, or when none passes the threshold, the
+// conversation is left as it is.
+func (s *server) injectContext(r *http.Request, messages []models.Message) (bool, error) {
+	if s.corp == nil {
+		return false, nil
+	}
+	last := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	if last < 0 || strings.TrimSpace(messages[last].Content) == "" {
+		return false, nil
+	}
+	results, err := s.search(r.Context(), messages[last].Content, serveContextSize, ragThreshold(s.corp, serveContextSize))
+	if err != nil {
+		return false, err
+	}
+	contextText, used := rag.BuildContext(results, serveMaxContext)
+	if len(used) == 0 {
+		return false, nil
+	}
+	if verbose {
+		fmt.Printf("Injected %d context chunks into /v1/chat/completions\n", len(used))
+	}
+	messages[last].Content = rag.BuildReferencePrompt(messages[last].Content, contextText)
+	return true, nil
+}
+
+// openAIModel returns the installed model named by requested. Clients often send a model
+// name of their own (gpt-4o, ...); any name that is not installed gets the automatic
+// selection, a RAG-optimized model when context was injected.
+func openAIModel(requested string, grounded bool) (string, error) {
+	if requested != "" {
+		names, err := llmClient.ListModels()
+		if err != nil {
+			return "", err
+		}
+		for _, name := range names {
+			if strings.EqualFold(name, requested) || strings.EqualFold(strings.TrimSuffix(name, ":latest"), requested) {
+				return name, nil
+			}
+		}
+	}
+	if grounded {
+		return selectRAGModel(ragModel, ragPreferFast)
+	}
+	return chatModel()
+}
+
+// openAIMessages converts OpenAI messages, keeping the text of multi-part content
+func openAIMessages(in []openAIMessage) ([]models.Message, error) {
+	out := make([]models.Message, 0, len(in))
+	for i, m := range in {
+		if m.Role == "" {
+			return nil, fmt.Errorf("messages[%d]: role is required", i)
+		}
+		msg := models.Message{Role: m.Role}
+		if len(m.Content) > 0 && string(m.Content) != "null" {
+			if err := json.Unmarshal(m.Content, &msg.Content); err != nil {
+				var parts []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				}
+				if err := json.Unmarshal(m.Content, &parts); err != nil {
+					return nil, fmt.Errorf("messages[%d]: content must be a string or a list of content parts", i)
+				}
+				var texts []string
+				for _, p := range parts {
+					if p.Type == "text" {
+						texts = append(texts, p.Text)
+					}
+				}
+				msg.Content = strings.Join(texts, "\n")
+			}
+		}
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+// options maps the OpenAI sampling fields onto Ollama options
+func (req openAIChatRequest) options() (*models.Options, error) {
+	opts := &models.Options{Temperature: req.Temperature, TopP: req.TopP, NumPredict: req.MaxTokens}
+	if req.MaxCompletion != nil {
+		opts.NumPredict = req.MaxCompletion
+	}
+	if len(req.Stop) > 0 && string(req.Stop) != "null" {
+		var one string
+		if err := json.Unmarshal(req.Stop, &one); err == nil {
+			opts.Stop = []string{one}
+		} else if err := json.Unmarshal(req.Stop, &opts.Stop); err != nil {
+			return nil, fmt.Errorf("stop must be a string or a list of strings")
+		}
+	}
+	if opts.Temperature == nil && opts.TopP == nil && opts.NumPredict == nil && len(opts.Stop) == 0 {
+		return nil, nil
+	}
+	return opts, nil
+}
+
+// finishReason maps Ollama's done_reason onto OpenAI's finish_reason
+func finishReason(doneReason string) string {
+	if doneReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+func usage(prompt, completion int) *openAIUsage {
+	return &openAIUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+func completionID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+// writeOpenAIError answers with an error in the shape OpenAI clients expect
+func writeOpenAIError(w http.ResponseWriter, status int, msg string) {
+	kind := "invalid_request_error"
+	if status >= 500 {
+		kind = "server_error"
+	}
+	writeJSON(w, status, map[string]interface{}{"error": map[string]interface{}{"message": msg, "type": kind, "code": nil}})
+}
+
+// sse streams server-sent events in the format of OpenAI's streaming API
+type sse struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func newSSE(w http.ResponseWriter) *sse {
+	return &sse{w: w}
+}
+
+func (s *sse) send(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write("data: " + string(b) + "\n\n")
+}
+
+func (s *sse) write(event string) error {
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	if _, err := fmt.Fprint(s.w, event); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// done ends the stream with the [DONE] sentinel
+func (s *sse) done() {
+	_ = s.write("data: [DONE]\n\n")
+}
+
+// fail reports err as an error status before anything was streamed, or as a final error
+// event after
+func (s *sse) fail(err error) {
+	if !s.started {
+		writeOpenAIError(s.w, http.StatusBadGateway, err.Error())
+		return
+	}
+	_ = s.send(map[string]interface{}{"error": map[string]interface{}{"message": err.Error(), "type": "server_error"}})
+	s.done()
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"kirk-ai/internal/client"
+	"kirk-ai/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var showLicense bool
+
+var showCmd = &cobra.Command{
+	Use:   "show <model>",
+	Short: "Show a model's details",
+	Long: `Show what Ollama reports about an installed model: its family, parameter size, quantization,
+context length, capabilities, Modelfile parameters, prompt template, and license. The model is
+matched by name as with --model.
+
+The capabilities line shows which kinds of task kirk-ai would pick the model for; these facts,
+not the model's name, drive automatic model selection.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runShowCommand,
+}
+
+func runShowCommand(cmd *cobra.Command, args []string) {
+	name, err := resolveChatModel(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	info, err := llmClient.Show(context.Background(), name)
+	if err != nil {
+		fmt.Printf("Error describing %s: %v\n", name, err)
+		os.Exit(1)
+	}
+
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Printf("  %-15s %s\n", label+":", value)
+		}
+	}
+	block := func(label, text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		fmt.Printf("  %s:\n", label)
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+
+	fmt.Println(name)
+	field("Family", info.Details.Family)
+	field("Parameters", info.Details.ParameterSize)
+	field("Quantization", info.Details.QuantizationLevel)
+	window, trained := llmClient.WindowOf(info)
+	switch {
+	case trained > 0:
+		field("Context length", fmt.Sprintf("%d tokens trained, %d used (num_ctx)", trained, window))
+	default:
+		field("Context length", fmt.Sprintf("%d tokens used (num_ctx)", window))
+	}
+	field("Reports", strings.Join(info.Capabilities, ", "))
+	facts := client.ProbeModel(name, info)
+	if facts.EmbeddingLength > 0 {
+		field("Embedding", fmt.Sprintf("%d dimensions", facts.EmbeddingLength))
+	}
+	var suits []string
+	for _, c := range config.CapabilitiesOf(facts) {
+		suits = append(suits, string(c))
+	}
+	field("Capabilities", strings.Join(suits, ", "))
+	block("Modelfile parameters", info.Parameters)
+	block("Template", info.Template)
+
+	license := strings.TrimSpace(info.License)
+	switch {
+	case license == "":
+	case showLicense:
+		block("License", license)
+	default:
+		lines := strings.Split(license, "\n")
+		first := truncateText(strings.TrimSpace(lines[0]), 100)
+		if len(lines) > 1 {
+			first += fmt.Sprintf(" (%d lines; --license for the full text)", len(lines))
+		}
+		field("License", first)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+	requireServer(showCmd, "")
+
+	showCmd.Flags().BoolVar(&showLicense, "license", false, "Print the full license text rather than its first line")
+}
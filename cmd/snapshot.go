@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"kirk-ai/internal/snapshot"
+	"kirk-ai/internal/workspace"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotDir        string
+	snapshotEmbeddings string
+	snapshotLabel      string
+	snapshotShowIDs    bool
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Create, list, restore, and compare embeddings snapshots",
+	Long: `Manage versioned snapshots of an embeddings file. Each snapshot stores a copy of the
+file together with a manifest of per-chunk content hashes, so you can roll back after a
+bad crawl or compare corpus versions over time.`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a timestamped snapshot of an embeddings file",
+	Args:  cobra.NoArgs,
+	Run:   runSnapshotCreateCommand,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available snapshots",
+	Args:  cobra.NoArgs,
+	Run:   runSnapshotListCommand,
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore [snapshot-id]",
+	Short: "Restore an embeddings file from a snapshot",
+	Args:  cobra.ExactArgs(1),
+	Run:   runSnapshotRestoreCommand,
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff [old-id] [new-id]",
+	Short: "Show chunks added, removed, or changed between two snapshots",
+	Args:  cobra.ExactArgs(2),
+	Run:   runSnapshotDiffCommand,
+}
+
+func runSnapshotCreateCommand(cmd *cobra.Command, args []string) {
+	if snapshotEmbeddings == "" {
+		fmt.Println("Please specify embeddings file with --embeddings flag")
+		os.Exit(1)
+	}
+
+	m, err := snapshot.Create(snapshotDir, snapshotEmbeddings, snapshotLabel)
+	if err != nil {
+		fmt.Printf("Error creating snapshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Snapshot %s created (%d chunks)\n", m.ID, m.ItemCount)
+	if verbose {
+		fmt.Printf("Source: %s\n", m.Source)
+		fmt.Printf("File hash: %s\n", m.FileHash)
+	}
+}
+
+func runSnapshotListCommand(cmd *cobra.Command, args []string) {
+	manifests, err := snapshot.List(snapshotDir)
+	if err != nil {
+		fmt.Printf("Error listing snapshots: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(manifests) == 0 {
+		fmt.Printf("No snapshots found in %s\n", snapshotDir)
+		return
+	}
+
+	fmt.Println("Available snapshots:")
+	fmt.Println("====================")
+	for _, m := range manifests {
+		fmt.Printf("\n📸 %s", m.ID)
+		if m.Label != "" {
+			fmt.Printf(" (%s)", m.Label)
+		}
+		fmt.Println()
+		fmt.Printf("   Created: %s\n", m.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+		fmt.Printf("   Source: %s\n", m.Source)
+		fmt.Printf("   Chunks: %d\n", m.ItemCount)
+	}
+}
+
+func runSnapshotRestoreCommand(cmd *cobra.Command, args []string) {
+	id := args[0]
+
+	m, err := snapshot.Load(snapshotDir, id)
+	if err != nil {
+		fmt.Printf("Error loading snapshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	dest := snapshotEmbeddings
+	if dest == "" {
+		dest = m.Source
+	}
+
+	if _, err := snapshot.Restore(snapshotDir, id, dest); err != nil {
+		fmt.Printf("Error restoring snapshot: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored snapshot %s to %s (%d chunks)\n", id, dest, m.ItemCount)
+}
+
+func runSnapshotDiffCommand(cmd *cobra.Command, args []string) {
+	oldManifest, err := snapshot.Load(snapshotDir, args[0])
+	if err != nil {
+		fmt.Printf("Error loading snapshot: %v\n", err)
+		os.Exit(1)
+	}
+	newManifest, err := snapshot.Load(snapshotDir, args[1])
+	if err != nil {
+		fmt.Printf("Error loading snapshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff := snapshot.Compare(oldManifest, newManifest)
+
+	fmt.Printf("Snapshot diff: %s -> %s\n", oldManifest.ID, newManifest.ID)
+	fmt.Println(strings.Repeat("=", 50))
+	fmt.Printf("Chunks: %d -> %d\n", oldManifest.ItemCount, newManifest.ItemCount)
+	fmt.Printf("Added: %d\n", len(diff.Added))
+	fmt.Printf("Removed: %d\n", len(diff.Removed))
+	fmt.Printf("Changed: %d\n", len(diff.Changed))
+
+	if snapshotShowIDs || verbose {
+		printSnapshotIDs("+", diff.Added)
+		printSnapshotIDs("-", diff.Removed)
+		printSnapshotIDs("~", diff.Changed)
+	}
+}
+
+func printSnapshotIDs(prefix string, ids []string) {
+	for _, id := range ids {
+		fmt.Printf("  %s %s\n", prefix, id)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotCreateCmd, snapshotListCmd, snapshotRestoreCmd, snapshotDiffCmd)
+
+	snapshotCmd.PersistentFlags().StringVar(&snapshotDir, "snapshot-dir", workspace.Default(workspace.Snapshots),
+		"Directory where snapshots are stored")
+
+	snapshotCreateCmd.Flags().StringVar(&snapshotEmbeddings, "embeddings", "",
+		"Path to embeddings JSON file to snapshot (required)")
+	snapshotCreateCmd.Flags().StringVar(&snapshotLabel, "label", "",
+		"Optional human-readable label for the snapshot")
+	snapshotRestoreCmd.Flags().StringVar(&snapshotEmbeddings, "embeddings", "",
+		"Destination path (defaults to the snapshot's original source file)")
+	snapshotDiffCmd.Flags().BoolVar(&snapshotShowIDs, "ids", false,
+		"List the individual chunk IDs that changed")
+
+	snapshotCreateCmd.MarkFlagRequired("embeddings")
+}
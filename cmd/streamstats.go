@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"kirk-ai/internal/models"
+)
+
+// streamStats times a streamed reply as its chunks arrive. The total duration alone hides
+// where the time went: a late first token points at model load or prompt eval, long gaps
+// between tokens at generation.
+type streamStats struct {
+	start  time.Time
+	first  time.Time // when the first chunk with content arrived
+	last   time.Time // when the latest chunk with content arrived
+	chunks int
+	gaps   []time.Duration // time between consecutive chunks with content
+}
+
+// newStreamStats starts timing a stream; call it just before sending the request
+func newStreamStats() *streamStats {
+	return &streamStats{start: time.Now()}
+}
+
+// observe wraps a stream callback so it times each chunk before handing it on
+func (s *streamStats) observe(callback func(chunk *models.StreamingChatResponse) error) func(chunk *models.StreamingChatResponse) error {
+	return func(chunk *models.StreamingChatResponse) error {
+		if chunk.Message.Content != "" {
+			now := time.Now()
+			if s.chunks == 0 {
+				s.first = now
+			} else {
+				s.gaps = append(s.gaps, now.Sub(s.last))
+			}
+			s.last = now
+			s.chunks++
+		}
+		return callback(chunk)
+	}
+}
+
+// print writes the stream's timings with --verbose. resp, the final response, adds the
+// server's own load and prompt eval times, which Ollama reports and OpenAI-compatible
+// servers do not.
+func (s *streamStats) print(resp *models.ChatResponse) {
+	if !verbose || s.chunks == 0 {
+		return
+	}
+	ttft := fmt.Sprintf("Time to first token: %v", roundMs(s.first.Sub(s.start)))
+	if resp != nil && (resp.LoadDuration > 0 || resp.PromptEvalDuration > 0) {
+		ttft += fmt.Sprintf(" (model load %v, prompt eval %v for %d tokens)", roundMs(time.Duration(resp.LoadDuration)),
+			roundMs(time.Duration(resp.PromptEvalDuration)), resp.PromptEvalCount)
+	}
+	fmt.Println(ttft)
+
+	if len(s.gaps) > 0 {
+		sorted := append([]time.Duration(nil), s.gaps...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var sum time.Duration
+		for _, g := range sorted {
+			sum += g
+		}
+		fmt.Printf("Inter-token latency: %v mean, %v p95, %v max over %d chunks\n", roundMs(sum/time.Duration(len(sorted))),
+			roundMs(sorted[(len(sorted)*95)/100]), roundMs(sorted[len(sorted)-1]), s.chunks)
+	}
+
+	generation := s.last.Sub(s.first)
+	duration := fmt.Sprintf("Stream duration: %v (generating %v", roundMs(s.last.Sub(s.start)), roundMs(generation))
+	if resp != nil && resp.EvalCount > 0 && resp.EvalDuration > 0 {
+		duration += fmt.Sprintf(", %.1f tokens/s", float64(resp.EvalCount)/(float64(resp.EvalDuration)/1e9))
+	}
+	fmt.Println(duration + ")")
+}
+
+// roundMs rounds d to the millisecond for display, keeping sub-millisecond gaps visible
+func roundMs(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
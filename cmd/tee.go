@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// teePath is the --tee file of the streaming commands
+var teePath string
+
+// addTeeFlag registers --tee on a command that prints model output
+func addTeeFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&teePath, "tee", "", "Also write the model's output to this file as it arrives (e.g. answer.md)")
+}
+
+// tee copies model output to a file while it is printed, writing every chunk straight
+// through so a long generation survives a lost terminal. A nil *tee discards writes.
+type tee struct {
+	f   *os.File
+	err error
+}
+
+// openTee creates the --tee file, or returns nil when --tee is unset
+func openTee() (*tee, error) {
+	if teePath == "" {
+		return nil, nil
+	}
+	f, err := os.Create(teePath)
+	if err != nil {
+		return nil, err
+	}
+	return &tee{f: f}, nil
+}
+
+// Write appends s to the file, remembering the first error for Close
+func (t *tee) Write(s string) {
+	if t == nil || t.err != nil {
+		return
+	}
+	_, t.err = t.f.WriteString(s)
+}
+
+// Close flushes the file to disk and reports the first write error
+func (t *tee) Close() error {
+	if t == nil {
+		return nil
+	}
+	if err := t.f.Sync(); err != nil && t.err == nil {
+		t.err = err
+	}
+	if err := t.f.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"kirk-ai/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	translateTo   string
+	translateFrom string
+)
+
+// translateCmd represents the translate command
+var translateCmd = &cobra.Command{
+	Use:   "translate [text]",
+	Short: "Translate text with a translation-capable model",
+	Long: `Translate text into --to (default English). Pass --embeddings or --collection to inject a
+matching domain glossary so names and terms are translated consistently.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runTranslateCommand,
+}
+
+func runTranslateCommand(cmd *cobra.Command, args []string) {
+	text := strings.Join(args, " ")
+
+	from := ""
+	if translateFrom != "" {
+		from = " from " + translateFrom
+	}
+	prompt := fmt.Sprintf("Translate the following text%s to %s. Reply with the translation only.\n\nText:\n%s",
+		from, translateTo, text)
+
+	prompt, err := withReference(prompt, text)
+	if err != nil {
+		fmt.Printf("Error retrieving reference material: %v\n", err)
+		os.Exit(1)
+	}
+
+	selectedModel, err := selectModelFor(config.CapabilityTranslation)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if verbose {
+		fmt.Printf("Using model: %s\n", selectedModel)
+		fmt.Println("---")
+	}
+
+	if err := printCompletion(selectedModel, prompt); err != nil {
+		fmt.Printf("Error translating: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(translateCmd)
+	requireServer(translateCmd, "")
+
+	translateCmd.Flags().StringVar(&translateTo, "to", "English", "Target language")
+	translateCmd.Flags().StringVar(&translateFrom, "from", "", "Source language (default: detected by the model)")
+	addReferenceFlags(translateCmd)
+	addTeeFlag(translateCmd)
+	addGenerationFlags(translateCmd)
+}
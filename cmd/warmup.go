@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var warmupEmbeddings bool
+
+var warmupCmd = &cobra.Command{
+	Use:   "warmup [model...]",
+	Short: "Load models into memory ahead of use",
+	Long: `Load models into Ollama's memory without generating anything, so the next command that uses
+them does not wait for the load. Models are matched by name as with --model; with none given,
+--model or the auto-selected chat model is loaded.
+
+Models stay loaded for --keep-alive (default: the server's, 5m); pass --keep-alive -1 to keep
+them until the server stops.`,
+	Run: runWarmupCommand,
+}
+
+func runWarmupCommand(cmd *cobra.Command, args []string) {
+	var names []string
+	for _, arg := range args {
+		name, err := resolveChatModel(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		names = append(names, name)
+	}
+	if len(args) == 0 {
+		name, err := chatModel()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		names = append(names, name)
+	}
+	if warmupEmbeddings {
+		installed, err := llmClient.ListModels()
+		if err != nil {
+			fmt.Printf("Error getting models: %v\n", err)
+			os.Exit(1)
+		}
+		name, err := selectEmbeddingModel(installed)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		names = append(names, name)
+	}
+
+	ctx := context.Background()
+	failed := false
+	for _, name := range names {
+		start := time.Now()
+		if err := llmClient.Warmup(ctx, name); err != nil {
+			fmt.Printf("Error loading %s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Loaded %s in %v\n", name, time.Since(start).Round(time.Millisecond))
+	}
+
+	// Report what is resident and until when, which shows the keep-alive took effect
+	running, err := llmClient.RunningModels(ctx)
+	if err != nil {
+		if verbose {
+			fmt.Printf("Could not list running models: %v\n", err)
+		}
+	} else if len(running) > 0 {
+		fmt.Println("In memory:")
+		for _, m := range running {
+			line := fmt.Sprintf("  %s: %s in memory (%s on GPU)", m.Name, formatBytes(m.Size), formatBytes(m.SizeVRAM))
+			switch {
+			case m.ExpiresAt.IsZero():
+			case m.ExpiresAt.After(time.Now().AddDate(1, 0, 0)):
+				line += ", loaded until the server stops"
+			default:
+				line += ", loaded until " + m.ExpiresAt.Local().Format("15:04:05")
+			}
+			fmt.Println(line)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(warmupCmd)
+	requireServer(warmupCmd, "")
+
+	warmupCmd.Flags().BoolVar(&warmupEmbeddings, "embeddings", false, "Also load the installed embedding model, as rag and search need both")
+}
@@ -0,0 +1,160 @@
+// Package adaptive limits how many requests a worker pool has in flight, raising the limit
+// while the server keeps up and cutting it when the server slows down or fails (AIMD)
+package adaptive
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTolerance is how many times slower than the fastest request seen a request may be
+// before the server counts as overloaded
+const DefaultTolerance = 2.0
+
+// smoothing is the weight of the newest latency in the moving average
+const smoothing = 0.2
+
+// Limiter hands out request slots up to a limit that grows by one slot for every limit's
+// worth of requests that finish in good time, and halves when one fails with an overload
+// error or the average latency rises past Tolerance times the fastest seen. It is safe for
+// concurrent use.
+type Limiter struct {
+	// Tolerance is the latency factor over the fastest request seen that counts as
+	// overload (DefaultTolerance when zero)
+	Tolerance float64
+	// OnChange, when set, is called with the new limit and the reason each time the limit
+	// moves by a whole slot. It is called with the Limiter locked and must not use it.
+	OnChange func(limit int, reason string)
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	min, max int
+	limit    float64
+	peak     int
+	inFlight int
+	fastest  time.Duration // fastest latency per unit of work seen
+	average  time.Duration // moving average of latency per unit of work since the last cut
+	cut      time.Time     // when the limit was last halved
+}
+
+// New returns a Limiter that starts at min slots and never goes above max
+func New(min, max int) *Limiter {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	l := &Limiter{min: min, max: max, limit: float64(min), peak: min}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Request is a slot held by one request
+type Request struct {
+	l     *Limiter
+	start time.Time
+}
+
+// Acquire waits for a free slot
+func (l *Limiter) Acquire() *Request {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inFlight >= int(l.limit) {
+		l.cond.Wait()
+	}
+	l.inFlight++
+	return &Request{l: l, start: time.Now()}
+}
+
+// Done frees the slot and feeds back how the request went: units is the amount of work it
+// carried (such as the chunks in a batch), so latencies of differently sized requests
+// compare, and overloaded reports a failure that means the server is past capacity, such
+// as a timeout or a 429. Failures that say nothing about load should pass false.
+func (r *Request) Done(units int, overloaded bool) {
+	l := r.l
+	elapsed := time.Since(r.start)
+	if units < 1 {
+		units = 1
+	}
+	perUnit := elapsed / time.Duration(units)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	defer l.cond.Broadcast()
+
+	// Requests sent before the last cut saw the old limit; they must not cut it again
+	if r.start.Before(l.cut) {
+		return
+	}
+	if overloaded {
+		l.decrease("request failed")
+		return
+	}
+	if l.fastest == 0 || perUnit < l.fastest {
+		l.fastest = perUnit
+	}
+	if l.average == 0 {
+		l.average = perUnit
+	} else {
+		l.average += time.Duration(smoothing * float64(perUnit-l.average))
+	}
+	tolerance := l.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if float64(l.average) > tolerance*float64(l.fastest) {
+		l.decrease("latency rising")
+		return
+	}
+	l.increase()
+}
+
+// increase adds one slot per limit's worth of good requests
+func (l *Limiter) increase() {
+	if l.limit >= float64(l.max) {
+		return
+	}
+	before := int(l.limit)
+	l.limit += 1 / l.limit
+	if l.limit > float64(l.max) {
+		l.limit = float64(l.max)
+	}
+	if n := int(l.limit); n != before {
+		if n > l.peak {
+			l.peak = n
+		}
+		if l.OnChange != nil {
+			l.OnChange(n, "server keeping up")
+		}
+	}
+}
+
+// decrease halves the limit and starts a fresh latency average
+func (l *Limiter) decrease(reason string) {
+	l.cut = time.Now()
+	l.average = 0
+	before := int(l.limit)
+	l.limit /= 2
+	if l.limit < float64(l.min) {
+		l.limit = float64(l.min)
+	}
+	if n := int(l.limit); n != before && l.OnChange != nil {
+		l.OnChange(n, reason)
+	}
+}
+
+// Limit returns the current number of slots
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Peak returns the highest limit reached
+func (l *Limiter) Peak() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.peak
+}
@@ -0,0 +1,144 @@
+// Package atomicfile writes files so that readers see either the previous version or the
+// complete new one, never a truncated mix, even when the writer is killed or the machine
+// loses power partway through
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// BackupSuffix is appended to a file's path to name the copy of its previous version
+const BackupSuffix = ".bak"
+
+// File is a file being written atomically. Writes go to a temporary file next to the
+// destination; nothing appears at the destination until Commit.
+type File struct {
+	*os.File
+	path   string
+	backup bool
+	done   bool
+}
+
+// Create starts writing path with permissions perm. With backup, Commit keeps the version
+// it replaces at path+BackupSuffix.
+func Create(path string, perm os.FileMode, backup bool) (*File, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &File{File: f, path: path, backup: backup}, nil
+}
+
+// Commit flushes the file to disk and renames it over the destination. The rename itself
+// is synced by syncing the directory, so a crash afterwards keeps the new version.
+func (f *File) Commit() error {
+	if f.done {
+		return errors.New("atomicfile: already committed or aborted")
+	}
+	f.done = true
+	tmp := f.Name()
+	if err := f.Sync(); err != nil {
+		f.File.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if f.backup {
+		if err := keepBackup(f.path); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(f.path))
+	return nil
+}
+
+// Abort discards the file, leaving the destination untouched. It does nothing after Commit,
+// so it can be deferred.
+func (f *File) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// WriteFile atomically replaces path with data, keeping the previous version at
+// path+BackupSuffix when backup is true
+func WriteFile(path string, data []byte, perm os.FileMode, backup bool) error {
+	f, err := Create(path, perm, backup)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// keepBackup makes path+BackupSuffix a copy of path, replacing any older backup. The
+// original stays in place, so readers never find the destination missing. A missing
+// original needs no backup.
+func keepBackup(path string) error {
+	bak := path + BackupSuffix
+	if err := os.Remove(bak); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err := os.Link(path, bak)
+	if err == nil || os.IsNotExist(err) {
+		return nil
+	}
+	// Hard links are unavailable on some filesystems; copy instead
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(bak, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// syncDir flushes a directory entry change to disk where the platform allows it
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
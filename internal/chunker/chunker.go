@@ -0,0 +1,245 @@
+package chunker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultMaxTokens is the chunk size used by the processor when none is configured
+const DefaultMaxTokens = 500
+
+// Strategy names the splitting approach used by Chunk; it is recorded in chunk provenance
+const Strategy = "sentence"
+
+var sentenceSplitRE = regexp.MustCompile(`[.!?]+\s*`)
+
+// IsLowQualityChunk checks if a chunk contains mostly navigation/footer content
+func IsLowQualityChunk(content string) bool {
+	content = strings.TrimSpace(content)
+
+	// Check for minimum word count
+	words := strings.Fields(content)
+	if len(words) < 10 {
+		return true
+	}
+
+	// Check for footer-only content patterns
+	footerPatterns := []string{
+		"Charlie Kirk Benny Johnson Jack Posobiec Alex Clark Stephen Davis View all contributors",
+		"TPUSA Contributors TPUSA curates some of the country's top conservative influencers",
+		"View all contributors",
+		"Sort by: Most recent Most popular OP-EDS",
+	}
+
+	for _, pattern := range footerPatterns {
+		if strings.Contains(content, pattern) && len(words) < 50 {
+			return true
+		}
+	}
+
+	// Check if content is mostly navigation/menu items (lots of "Read more", "Article", etc.)
+	navWords := []string{"Read more", "Article", "Show details", "View all"}
+	navCount := 0
+	for _, navWord := range navWords {
+		navCount += strings.Count(content, navWord)
+	}
+
+	// If more than 30% of the content appears to be navigation
+	if float64(navCount*2)/float64(len(words)) > 0.3 {
+		return true
+	}
+
+	return false
+}
+
+// cleanPatterns are footer blocks removed from page text before chunking
+var cleanPatterns = []string{
+	"TPUSA Contributors TPUSA curates some of the country's top conservative influencers—covering a spectrum of topics ranging from politics to pop culture Charlie Kirk Benny Johnson Jack Posobiec Alex Clark Stephen Davis View all contributors",
+	"Charlie Kirk Benny Johnson Jack Posobiec Alex Clark Stephen Davis View all contributors",
+}
+
+// CleanContent removes common navigation and footer elements
+func CleanContent(text string) string {
+	cleaned, _ := cleanWithOffsets(text)
+	return cleaned
+}
+
+// cleanWithOffsets applies CleanContent and also returns, for every byte of the cleaned
+// text, its index in the original text
+func cleanWithOffsets(text string) (string, []int) {
+	offsets := make([]int, len(text))
+	for i := range offsets {
+		offsets[i] = i
+	}
+	for _, pattern := range cleanPatterns {
+		var sb strings.Builder
+		kept := offsets[:0:0]
+		for cur := 0; ; {
+			i := strings.Index(text[cur:], pattern)
+			if i < 0 {
+				sb.WriteString(text[cur:])
+				kept = append(kept, offsets[cur:]...)
+				break
+			}
+			sb.WriteString(text[cur : cur+i])
+			kept = append(kept, offsets[cur:cur+i]...)
+			cur += i + len(pattern)
+		}
+		text, offsets = sb.String(), kept
+	}
+
+	lead := len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+	trimmed := strings.TrimSpace(text)
+	return trimmed, offsets[lead : lead+len(trimmed)]
+}
+
+// Span is a chunk together with the byte range of the original text it was built from.
+// Sentence punctuation between Start and End is not part of Text.
+type Span struct {
+	Text   string
+	Start  int
+	End    int
+	Tokens int // the chunk's token count, when Split counted it
+}
+
+// Chunk splits text into sentence-aligned chunks of roughly maxTokens tokens,
+// dropping chunks that look like navigation or footer boilerplate.
+func Chunk(text string, maxTokens int) []string {
+	spans := ChunkSpans(text, maxTokens)
+	chunks := make([]string, len(spans))
+	for i, sp := range spans {
+		chunks[i] = sp.Text
+	}
+	return chunks
+}
+
+// ChunkSpans is Chunk, additionally reporting where in text each chunk came from
+func ChunkSpans(text string, maxTokens int) []Span {
+	// Clean the content first, remembering where each byte came from
+	cleaned, offsets := cleanWithOffsets(text)
+
+	if cleaned == "" {
+		return []Span{}
+	}
+
+	spans := []Span{}
+	emit := func(current string, start, end int) {
+		// Before adding the chunk, check if it's high quality
+		if current != "" && !IsLowQualityChunk(current) {
+			spans = append(spans, Span{Text: current, Start: offsets[start], End: offsets[end-1] + 1})
+		}
+	}
+
+	// Split by sentences, tracking the byte range of the chunk being built
+	bounds := append(sentenceSplitRE.FindAllStringIndex(cleaned, -1), []int{len(cleaned), len(cleaned)})
+	current := ""
+	start, end, prev := 0, 0, 0
+	for _, b := range bounds {
+		raw := cleaned[prev:b[0]]
+		prev = b[1]
+		s := strings.TrimSpace(raw)
+		if s == "" {
+			continue
+		}
+		sStart := b[0] - len(raw) + strings.Index(raw, s)
+		sEnd := sStart + len(s)
+
+		est := EstimateTokens(current + " " + s)
+
+		if est > maxTokens && current != "" {
+			emit(current, start, end)
+			current, start, end = s, sStart, sEnd
+		} else {
+			if current == "" {
+				current, start = s, sStart
+			} else {
+				current += " " + s
+			}
+			end = sEnd
+		}
+	}
+
+	// Add the final chunk if it's high quality
+	emit(current, start, end)
+
+	return spans
+}
+
+// Section is a heading of a document and the byte offset in the document's text where
+// the section it introduces begins
+type Section struct {
+	Heading string `json:"heading"`
+	Level   int    `json:"level"`
+	Offset  int    `json:"offset"`
+}
+
+// SpanMetadata returns the metadata that locates a chunk in its document: character
+// offsets, relative position (0 = start, 1 = end), a text fragment for deep links, and the
+// heading of the enclosing section when sections (ordered by Offset) are known
+func SpanMetadata(doc string, sp Span, sections []Section) map[string]interface{} {
+	m := map[string]interface{}{
+		"char_start": sp.Start,
+		"char_end":   sp.End,
+	}
+	if sp.Tokens > 0 {
+		m["token_count"] = sp.Tokens
+	}
+	if len(doc) > 0 {
+		m["position"] = math.Round(float64(sp.Start)/float64(len(doc))*1000) / 1000
+	}
+	if sp.End <= len(doc) {
+		if frag := TextFragment(doc[sp.Start:sp.End]); frag != "" {
+			m["text_fragment"] = frag
+		}
+	}
+	for i := len(sections) - 1; i >= 0; i-- {
+		if sections[i].Offset <= sp.Start {
+			m["section"] = sections[i].Heading
+			m["section_level"] = sections[i].Level
+			break
+		}
+	}
+	return m
+}
+
+// fragmentWords is how many words of each end of a chunk identify it in a text fragment
+const fragmentWords = 4
+
+// TextFragment returns the value of a URL text fragment (the part after "#:~:text=") that
+// highlights segment on the source page: the whole segment when short, otherwise its first
+// and last few words
+func TextFragment(segment string) string {
+	words := strings.Fields(segment)
+	if len(words) == 0 {
+		return ""
+	}
+	if len(words) <= 2*fragmentWords {
+		return fragmentEscape(strings.Join(words, " "))
+	}
+	start := strings.Join(words[:fragmentWords], " ")
+	end := strings.Join(words[len(words)-fragmentWords:], " ")
+	return fragmentEscape(start) + "," + fragmentEscape(end)
+}
+
+// fragmentEscape percent-encodes text for a text fragment, including the characters the
+// fragment syntax reserves
+func fragmentEscape(s string) string {
+	s = url.PathEscape(s)
+	return strings.NewReplacer("-", "%2D", "&", "%26", ",", "%2C").Replace(s)
+}
+
+// EstimateTokens approximates the token count of text from its word count
+func EstimateTokens(text string) int {
+	return int(float64(len(strings.Fields(text))) * 1.3)
+}
+
+// ContentHash returns the hex-encoded SHA-256 of a chunk's content, used to detect changed chunks
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,195 @@
+package chunker
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SemanticStrategy names the splitting approach of Split with its default options; it is
+// recorded in chunk provenance
+const SemanticStrategy = "semantic"
+
+// DefaultOverlap is how many tokens of a chunk the processor repeats at the start of the next
+const DefaultOverlap = 50
+
+// Options configure Split
+type Options struct {
+	// Strategy is SemanticStrategy (the default) or Strategy, the sentence packing of
+	// ChunkSpans, which ignores the other options but MaxTokens
+	Strategy  string
+	MaxTokens int       // chunk size in tokens (DefaultMaxTokens when zero)
+	Overlap   int       // tokens at the end of a chunk repeated at the start of the next
+	Tokenizer Tokenizer // counts tokens (Estimate when nil)
+	// Sections are the text's headings, ordered by Offset; a new section starts a new chunk
+	// unless the chunk so far is small
+	Sections []Section
+}
+
+// The boundaries a chunk may end at, weakest first
+const (
+	inSentence = iota
+	sentenceEnd
+	paragraphEnd
+	sectionStart
+)
+
+var (
+	sentenceEndRE = regexp.MustCompile(`[.!?]+["'”’)\]]*\s+`)
+	paragraphRE   = regexp.MustCompile(`\n\s*\n`)
+	wordRE        = regexp.MustCompile(`\S+`)
+)
+
+// unit is a sentence, or a piece of one too long for a chunk, and the boundary before it
+type unit struct {
+	start, end int // byte range in the cleaned text
+	before     int
+	tokens     int
+}
+
+// Split splits text into chunks of at most MaxTokens tokens, counted with the tokenizer. A
+// chunk ends at a sentence, and where it can at a paragraph break or before a heading, and
+// starts with the last Overlap tokens of the chunk before it so a thought cut by the
+// boundary keeps its context. Each span records its token count. Chunks that look like
+// navigation or footer boilerplate are dropped, as Chunk drops them.
+func Split(text string, opts Options) []Span {
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	if opts.Tokenizer == nil {
+		opts.Tokenizer = Estimate
+	}
+	if opts.Strategy == Strategy {
+		spans := ChunkSpans(text, opts.MaxTokens)
+		for i := range spans {
+			spans[i].Tokens = opts.Tokenizer.Count(spans[i].Text)
+		}
+		return spans
+	}
+	if opts.Overlap >= opts.MaxTokens/2 {
+		opts.Overlap = opts.MaxTokens / 2
+	}
+
+	cleaned, offsets := cleanWithOffsets(text)
+	if cleaned == "" {
+		return []Span{}
+	}
+	units := splitUnits(cleaned, offsets, opts)
+
+	spans := []Span{}
+	emit := func(a, b int) {
+		s := cleaned[units[a].start:units[b-1].end]
+		if !IsLowQualityChunk(s) {
+			spans = append(spans, Span{Text: s, Start: offsets[units[a].start], End: offsets[units[b-1].end-1] + 1, Tokens: opts.Tokenizer.Count(s)})
+		}
+	}
+	tokens := func(a, b int) int {
+		n := 0
+		for _, u := range units[a:b] {
+			n += u.tokens
+		}
+		return n
+	}
+
+	a := 0
+	for i := 0; i < len(units); {
+		u := units[i]
+		sum := tokens(a, i)
+		full := sum+u.tokens > opts.MaxTokens
+		if i == a || !(full || (u.before == sectionStart && sum >= opts.MaxTokens/4)) {
+			i++
+			continue
+		}
+		// A full chunk ends at its last paragraph break or heading past its middle, if any
+		cut := i
+		if full {
+			for k, n := i-1, sum-units[i-1].tokens; k > a && n >= opts.MaxTokens/2; k-- {
+				if units[k].before >= paragraphEnd {
+					cut = k
+					break
+				}
+				n -= units[k-1].tokens
+			}
+		}
+		emit(a, cut)
+		// The next chunk reaches back over the overlap, keeping room for the sentences carried
+		// over and the one that did not fit, but never into another section
+		next, room := cut, opts.MaxTokens-tokens(cut, i)-u.tokens
+		if units[cut].before != sectionStart {
+			for ov := 0; next-1 > a && ov+units[next-1].tokens <= min(opts.Overlap, room) && units[next].before != sectionStart; next-- {
+				ov += units[next-1].tokens
+			}
+		}
+		a = next
+	}
+	if a < len(units) {
+		emit(a, len(units))
+	}
+	return spans
+}
+
+// splitUnits cuts cleaned into sentences at sentence ends, paragraph breaks, and the
+// offsets of sections, splitting sentences too long for a chunk between words
+func splitUnits(cleaned string, offsets []int, opts Options) []unit {
+	bounds := map[int]int{}
+	mark := func(at, kind int) {
+		if at > 0 && at < len(cleaned) && bounds[at] < kind {
+			bounds[at] = kind
+		}
+	}
+	for _, m := range sentenceEndRE.FindAllStringIndex(cleaned, -1) {
+		mark(m[1], sentenceEnd)
+	}
+	for _, m := range paragraphRE.FindAllStringIndex(cleaned, -1) {
+		mark(m[1], paragraphEnd)
+	}
+	for _, s := range opts.Sections {
+		// Sections are located in the original text; find where each begins in the cleaned one
+		mark(sort.SearchInts(offsets, s.Offset), sectionStart)
+	}
+	cuts := make([]int, 0, len(bounds)+1)
+	for at := range bounds {
+		cuts = append(cuts, at)
+	}
+	sort.Ints(cuts)
+	cuts = append(cuts, len(cleaned))
+
+	var units []unit
+	prev, before := 0, inSentence
+	for _, at := range cuts {
+		raw := cleaned[prev:at]
+		s := strings.TrimSpace(raw)
+		if s != "" {
+			start := prev + strings.Index(raw, s)
+			units = append(units, splitLong(cleaned, unit{start: start, end: start + len(s), before: before}, opts)...)
+			before = inSentence
+		}
+		prev = at
+		if bounds[at] > before {
+			before = bounds[at]
+		}
+	}
+	return units
+}
+
+// splitLong counts the tokens of u and, when it does not fit in a chunk, splits it between
+// words into pieces that do
+func splitLong(cleaned string, u unit, opts Options) []unit {
+	u.tokens = opts.Tokenizer.Count(cleaned[u.start:u.end])
+	if u.tokens <= opts.MaxTokens {
+		return []unit{u}
+	}
+	var pieces []unit
+	cur := unit{start: u.start, before: u.before}
+	for _, w := range wordRE.FindAllStringIndex(cleaned[u.start:u.end], -1) {
+		wStart, wEnd := u.start+w[0], u.start+w[1]
+		n := opts.Tokenizer.Count(cleaned[wStart:wEnd])
+		if cur.tokens > 0 && cur.tokens+n > opts.MaxTokens {
+			pieces = append(pieces, cur)
+			cur = unit{start: wStart, before: inSentence}
+		}
+		cur.end = wEnd
+		cur.tokens += n
+	}
+	return append(pieces, cur)
+}
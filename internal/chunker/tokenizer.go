@@ -0,0 +1,329 @@
+package chunker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Tokenizer counts the tokens of text as a model's tokenizer would
+type Tokenizer interface {
+	// Name identifies the tokenizer in chunk provenance: "estimate", or the path of the
+	// vocabulary file, so the same counts can be had again
+	Name() string
+	Count(text string) int
+}
+
+// estimator counts tokens with EstimateTokens
+type estimator struct{}
+
+func (estimator) Name() string          { return "estimate" }
+func (estimator) Count(text string) int { return EstimateTokens(text) }
+
+// Estimate is the tokenizer used when no vocabulary is given: about 1.3 tokens per word
+var Estimate Tokenizer = estimator{}
+
+// LoadTokenizer reads a model's vocabulary: a Hugging Face tokenizer.json of a WordPiece
+// model (BERT, nomic-embed-text, mxbai-embed-large) or a BPE model (GPT-2 style byte-level,
+// or SentencePiece style), or the vocab.txt of a BERT model. An empty path returns Estimate.
+func LoadTokenizer(path string) (Tokenizer, error) {
+	if path == "" {
+		return Estimate, nil
+	}
+	if strings.HasSuffix(path, ".txt") {
+		return loadVocabTxt(path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tj struct {
+		Normalizer   json.RawMessage `json:"normalizer"`
+		PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+		Model        struct {
+			Type                    string          `json:"type"`
+			Vocab                   json.RawMessage `json:"vocab"`
+			Merges                  json.RawMessage `json:"merges"`
+			ContinuingSubwordPrefix *string         `json:"continuing_subword_prefix"`
+			MaxInputCharsPerWord    int             `json:"max_input_chars_per_word"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(b, &tj); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	switch tj.Model.Type {
+	case "WordPiece":
+		var vocab map[string]int
+		if err := json.Unmarshal(tj.Model.Vocab, &vocab); err != nil {
+			return nil, fmt.Errorf("%s: vocab: %w", path, err)
+		}
+		wp := &wordPiece{name: path, vocab: vocab, prefix: "##", maxChars: 100,
+			lowercase: hasComponent(tj.Normalizer, "Lowercase") || bertLowercase(tj.Normalizer)}
+		if tj.Model.ContinuingSubwordPrefix != nil {
+			wp.prefix = *tj.Model.ContinuingSubwordPrefix
+		}
+		if tj.Model.MaxInputCharsPerWord > 0 {
+			wp.maxChars = tj.Model.MaxInputCharsPerWord
+		}
+		wp.stripAccents = wp.lowercase
+		return wp, nil
+	case "BPE":
+		var merges []string
+		if err := json.Unmarshal(tj.Model.Merges, &merges); err != nil {
+			// Newer files give each merge as a pair
+			var pairs [][2]string
+			if err := json.Unmarshal(tj.Model.Merges, &pairs); err != nil {
+				return nil, fmt.Errorf("%s: merges: %w", path, err)
+			}
+			for _, p := range pairs {
+				merges = append(merges, p[0]+" "+p[1])
+			}
+		}
+		bp := &bpe{name: path, ranks: make(map[string]int, len(merges)), cache: make(map[string]int),
+			byteLevel: hasComponent(tj.PreTokenizer, "ByteLevel")}
+		for i, m := range merges {
+			bp.ranks[m] = i
+		}
+		return bp, nil
+	}
+	return nil, fmt.Errorf("%s: tokenizer model %q is not supported; use a WordPiece or BPE tokenizer.json", path, tj.Model.Type)
+}
+
+// hasComponent reports whether a normalizer or pre-tokenizer, or any step of a sequence of
+// them, is of type kind
+func hasComponent(raw json.RawMessage, kind string) bool {
+	var c struct {
+		Type          string            `json:"type"`
+		Normalizers   []json.RawMessage `json:"normalizers"`
+		PreTokenizers []json.RawMessage `json:"pretokenizers"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &c) != nil {
+		return false
+	}
+	if c.Type == kind {
+		return true
+	}
+	for _, step := range append(c.Normalizers, c.PreTokenizers...) {
+		if hasComponent(step, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// bertLowercase reports whether a BertNormalizer lowercases
+func bertLowercase(raw json.RawMessage) bool {
+	var n struct {
+		Type      string `json:"type"`
+		Lowercase bool   `json:"lowercase"`
+	}
+	return len(raw) > 0 && json.Unmarshal(raw, &n) == nil && n.Type == "BertNormalizer" && n.Lowercase
+}
+
+// loadVocabTxt reads a BERT vocab.txt, one token per line. The vocabulary is taken to be
+// uncased unless it has tokens with capitals besides its [SPECIAL] ones.
+func loadVocabTxt(path string) (Tokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	wp := &wordPiece{name: path, vocab: make(map[string]int), prefix: "##", maxChars: 100, lowercase: true, stripAccents: true}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		tok := strings.TrimRight(sc.Text(), "\r")
+		wp.vocab[tok] = len(wp.vocab)
+		if !strings.HasPrefix(tok, "[") && strings.ToLower(tok) != tok {
+			wp.lowercase, wp.stripAccents = false, false
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return wp, nil
+}
+
+// wordPiece counts tokens as BERT's tokenizer does: text is split at whitespace and
+// punctuation, then each word into the longest pieces of the vocabulary, left to right
+type wordPiece struct {
+	name         string
+	vocab        map[string]int
+	prefix       string
+	maxChars     int
+	lowercase    bool
+	stripAccents bool
+}
+
+func (w *wordPiece) Name() string { return w.name }
+
+func (w *wordPiece) Count(text string) int {
+	if w.lowercase {
+		text = strings.ToLower(text)
+	}
+	if w.stripAccents {
+		text = stripMarks(text)
+	}
+	n := 0
+	for _, word := range bertWords(text) {
+		n += w.pieces(word)
+	}
+	return n
+}
+
+// pieces counts the word pieces of word; a word that cannot be split is one unknown token
+func (w *wordPiece) pieces(word string) int {
+	runes := []rune(word)
+	if len(runes) > w.maxChars {
+		return 1
+	}
+	n := 0
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = w.prefix + piece
+			}
+			if _, ok := w.vocab[piece]; ok {
+				break
+			}
+		}
+		if end == start {
+			return 1
+		}
+		n++
+		start = end
+	}
+	return n
+}
+
+// bertWords splits text at whitespace and around each punctuation mark and CJK character
+func bertWords(text string) []string {
+	var words []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsControl(r) || r == 0xfffd:
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.Is(unicode.Han, r):
+			flush()
+			words = append(words, string(r))
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// stripMarks removes accents, as BERT's uncased models do
+func stripMarks(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// bpePretokenRE splits text into words before merging, as GPT-2's pattern does (without its
+// lookahead, which only moves a space between neighbouring words)
+var bpePretokenRE = regexp.MustCompile(`'s|'t|'re|'ve|'m|'ll|'d| ?\pL+| ?\pN+| ?[^\s\pL\pN]+|\s+`)
+
+// bpe counts tokens as a byte-pair encoding does: each word starts as bytes (byte-level) or
+// characters (SentencePiece style) and the vocabulary's merges are applied in rank order
+type bpe struct {
+	name      string
+	ranks     map[string]int
+	byteLevel bool
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+func (b *bpe) Name() string { return b.name }
+
+func (b *bpe) Count(text string) int {
+	var words []string
+	if b.byteLevel {
+		words = bpePretokenRE.FindAllString(text, -1)
+	} else {
+		for _, w := range strings.Fields(text) {
+			words = append(words, "▁"+w)
+		}
+	}
+	n := 0
+	for _, w := range words {
+		n += b.word(w)
+	}
+	return n
+}
+
+// word counts the tokens of one word, caching the result
+func (b *bpe) word(w string) int {
+	b.mu.Lock()
+	n, ok := b.cache[w]
+	b.mu.Unlock()
+	if ok {
+		return n
+	}
+	var symbols []string
+	if b.byteLevel {
+		for _, c := range []byte(w) {
+			symbols = append(symbols, string(byteRunes[c]))
+		}
+	} else {
+		for _, r := range w {
+			symbols = append(symbols, string(r))
+		}
+	}
+	for len(symbols) > 1 {
+		best, at := -1, -1
+		for i := 0; i+1 < len(symbols); i++ {
+			if r, ok := b.ranks[symbols[i]+" "+symbols[i+1]]; ok && (best < 0 || r < best) {
+				best, at = r, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		symbols[at] += symbols[at+1]
+		symbols = append(symbols[:at+1], symbols[at+2:]...)
+	}
+	n = len(symbols)
+	b.mu.Lock()
+	b.cache[w] = n
+	b.mu.Unlock()
+	return n
+}
+
+// byteRunes maps each byte to the printable character byte-level BPE vocabularies spell it
+// with: printable Latin-1 bytes stand for themselves, the rest are moved past U+0100
+var byteRunes = func() [256]rune {
+	var m [256]rune
+	next := rune(256)
+	for i := 0; i < 256; i++ {
+		if (i >= '!' && i <= '~') || (i >= 0xa1 && i <= 0xac) || (i >= 0xae && i <= 0xff) {
+			m[i] = rune(i)
+		} else {
+			m[i] = next
+			next++
+		}
+	}
+	return m
+}()
@@ -0,0 +1,304 @@
+// Package classify tags crawled pages with their type (article, event, product, landing
+// page) so later stages can process each type with its own profile
+package classify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MetadataKey is the key under which the classification is stored in page records
+const MetadataKey = "classification"
+
+// Type is the kind of page
+type Type string
+
+const (
+	Article Type = "article"
+	Event   Type = "event"
+	Product Type = "product"
+	Landing Type = "landing"
+	// Other is a page with too little text to be an article and no sign of another type
+	Other Type = "other"
+)
+
+// Types lists every page type
+var Types = []Type{Article, Event, Product, Landing, Other}
+
+// ParseType returns the type named by s, ignoring case and surrounding punctuation
+func ParseType(s string) (Type, bool) {
+	s = strings.ToLower(strings.Trim(strings.TrimSpace(s), ".,:;\"'`*"))
+	for _, t := range Types {
+		if s == string(t) {
+			return t, true
+		}
+	}
+	if s == "landing page" {
+		return Landing, true
+	}
+	return "", false
+}
+
+// Methods accepted by New
+const (
+	MethodRules = "rules"
+	MethodLLM   = "llm"
+)
+
+// Result is the type given to a page and how it was decided
+type Result struct {
+	Type   Type   `json:"type"`
+	Method string `json:"method"`           // rules or llm
+	Reason string `json:"reason,omitempty"` // the signal that decided it, for rules
+}
+
+// IsZero reports whether the page was not classified
+func (r Result) IsZero() bool {
+	return r.Type == ""
+}
+
+// Get reads the classification stored in a record; a missing entry yields a zero Result
+func Get(m map[string]interface{}) Result {
+	var r Result
+	if m == nil || m[MetadataKey] == nil {
+		return r
+	}
+	b, err := json.Marshal(m[MetadataKey])
+	if err != nil {
+		return r
+	}
+	_ = json.Unmarshal(b, &r)
+	return r
+}
+
+// Map converts the result into the generic form stored in JSON records
+func (r Result) Map() map[string]interface{} {
+	out := map[string]interface{}{}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return out
+	}
+	_ = json.Unmarshal(b, &out)
+	return out
+}
+
+// Page holds what a classifier looks at. Signals reads the markup fields; Content is the
+// page's extracted text.
+type Page struct {
+	URL         string
+	Title       string
+	Content     string
+	SchemaTypes []string // schema.org @type values declared in JSON-LD
+	OGType      string   // og:type
+	Links       int
+}
+
+// Signals reads the markup signals of a page. Call it before extracting text from sel,
+// since extraction strips the scripts that carry JSON-LD.
+func Signals(u string, sel *goquery.Selection) Page {
+	p := Page{
+		URL:    u,
+		Title:  strings.TrimSpace(sel.Find("title").First().Text()),
+		OGType: strings.ToLower(strings.TrimSpace(sel.Find(`meta[property="og:type"]`).AttrOr("content", ""))),
+		Links:  sel.Find("a[href]").Length(),
+	}
+	sel.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &v); err == nil {
+			p.SchemaTypes = append(p.SchemaTypes, schemaTypes(v)...)
+		}
+	})
+	return p
+}
+
+// schemaTypes collects the @type values of a JSON-LD value, including those in @graph and
+// nested objects
+func schemaTypes(v interface{}) []string {
+	var out []string
+	switch x := v.(type) {
+	case []interface{}:
+		for _, e := range x {
+			out = append(out, schemaTypes(e)...)
+		}
+	case map[string]interface{}:
+		switch t := x["@type"].(type) {
+		case string:
+			out = append(out, t)
+		case []interface{}:
+			for _, e := range t {
+				if s, ok := e.(string); ok {
+					out = append(out, s)
+				}
+			}
+		}
+		for k, e := range x {
+			if k != "@type" {
+				out = append(out, schemaTypes(e)...)
+			}
+		}
+	}
+	return out
+}
+
+// Classifier decides the type of a page
+type Classifier interface {
+	Classify(ctx context.Context, p Page) (Result, error)
+}
+
+// Rule tags pages of one type. A page matches when it declares one of SchemaTypes or OGTypes,
+// when its path matches one of URLPatterns, or when its title and text contain at least
+// MinKeywords of Keywords.
+type Rule struct {
+	Type        Type     `json:"type"`
+	SchemaTypes []string `json:"schema_types,omitempty"`
+	OGTypes     []string `json:"og_types,omitempty"`
+	URLPatterns []string `json:"url_patterns,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	MinKeywords int      `json:"min_keywords,omitempty"` // default 2
+}
+
+// DefaultRules recognize the common schema.org types, URL layouts, and shop and event
+// wording
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Type:        Event,
+			SchemaTypes: []string{"Event", "BusinessEvent", "EducationEvent", "Festival", "SocialEvent", "ExhibitionEvent"},
+			URLPatterns: []string{`(?i)/(events?|calendar|tour)(/|$)`},
+			Keywords:    []string{"register now", "get tickets", "tickets", "rsvp", "doors open", "venue", "agenda", "speakers"},
+			MinKeywords: 3,
+		},
+		{
+			Type:        Product,
+			SchemaTypes: []string{"Product", "Offer", "AggregateOffer"},
+			OGTypes:     []string{"product", "og:product", "product.item"},
+			URLPatterns: []string{`(?i)/(shop|store|products?|cart)(/|$)`},
+			Keywords:    []string{"add to cart", "in stock", "out of stock", "sku", "free shipping", "checkout"},
+			MinKeywords: 2,
+		},
+		{
+			Type:        Article,
+			SchemaTypes: []string{"Article", "NewsArticle", "BlogPosting", "OpinionNewsArticle", "Report"},
+			OGTypes:     []string{"article"},
+			URLPatterns: []string{`(?i)/(news|blog|articles?|posts?|press)/.`, `/\d{4}/\d{2}/`},
+		},
+	}
+}
+
+// LoadRules reads a JSON array of rules from path
+func LoadRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Landing pages are told apart from articles by structure: little text between many links
+const (
+	articleMinWords = 150
+	landingMinLinks = 30
+)
+
+// Rules classifies pages with keyword and markup rules, without network calls. Signals are
+// tried strongest first: declared schema.org types, then og:type, the URL, and keywords;
+// within each, rules are tried in order. Pages no rule matches are told apart by structure.
+type Rules struct {
+	rules []Rule
+	urls  [][]*regexp.Regexp
+}
+
+// NewRules compiles rules for classification
+func NewRules(rules []Rule) (*Rules, error) {
+	r := &Rules{rules: rules, urls: make([][]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		if _, ok := ParseType(string(rule.Type)); !ok {
+			return nil, fmt.Errorf("rule %d: unknown page type %q", i, rule.Type)
+		}
+		for _, p := range rule.URLPatterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: url pattern %q: %w", i, p, err)
+			}
+			r.urls[i] = append(r.urls[i], re)
+		}
+	}
+	return r, nil
+}
+
+func (r *Rules) Classify(ctx context.Context, p Page) (Result, error) {
+	result := func(t Type, reason string) (Result, error) {
+		return Result{Type: t, Method: MethodRules, Reason: reason}, nil
+	}
+	for _, rule := range r.rules {
+		for _, declared := range p.SchemaTypes {
+			if containsFold(rule.SchemaTypes, declared) {
+				return result(rule.Type, "schema.org "+declared)
+			}
+		}
+	}
+	if p.OGType != "" {
+		for _, rule := range r.rules {
+			if containsFold(rule.OGTypes, p.OGType) {
+				return result(rule.Type, "og:type "+p.OGType)
+			}
+		}
+	}
+	path := "/"
+	if u, err := url.Parse(p.URL); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	for i, rule := range r.rules {
+		for _, re := range r.urls[i] {
+			if re.MatchString(path) {
+				return result(rule.Type, "url "+path)
+			}
+		}
+	}
+	text := strings.ToLower(p.Title + " " + p.Content)
+	for _, rule := range r.rules {
+		need := rule.MinKeywords
+		if need <= 0 {
+			need = 2
+		}
+		var found []string
+		for _, k := range rule.Keywords {
+			if strings.Contains(text, strings.ToLower(k)) {
+				found = append(found, k)
+			}
+		}
+		if len(rule.Keywords) > 0 && len(found) >= need {
+			return result(rule.Type, "keywords "+strings.Join(found, ", "))
+		}
+	}
+	words := len(strings.Fields(p.Content))
+	switch {
+	case path == "/":
+		return result(Landing, "site root")
+	case words < articleMinWords && p.Links >= landingMinLinks:
+		return result(Landing, fmt.Sprintf("%d words, %d links", words, p.Links))
+	case words >= articleMinWords:
+		return result(Article, fmt.Sprintf("%d words", words))
+	}
+	return result(Other, fmt.Sprintf("%d words", words))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
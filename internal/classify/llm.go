@@ -0,0 +1,92 @@
+package classify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kirk-ai/internal/client"
+	"kirk-ai/internal/models"
+)
+
+// DefaultLLMModel is the chat model the LLM classifier asks unless another is given
+const DefaultLLMModel = "llama3.2"
+
+// llmExcerpt is how much of a page's text the LLM classifier sends
+const llmExcerpt = 1500
+
+const llmPrompt = `Classify this web page as exactly one of: article, event, product, landing, other.
+- article: a news story, blog post, or other prose written to be read
+- event: a page about a specific event, with its date, place, or registration
+- product: something offered for sale
+- landing: a home, section, or signup page that mostly links elsewhere
+- other: none of these
+Answer with the single word only.
+
+URL: %s
+Title: %s
+Text: %s`
+
+// LLM asks a small chat model for the page type. When the request fails or the answer is
+// not a known type, the page is classified by Fallback instead.
+type LLM struct {
+	Client   *client.Client
+	Model    string
+	Fallback Classifier
+}
+
+func (l *LLM) Classify(ctx context.Context, p Page) (Result, error) {
+	text := p.Content
+	if len(text) > llmExcerpt {
+		text = text[:llmExcerpt]
+	}
+	temperature := 0.0
+	resp, err := l.Client.ChatWithRequest(ctx, models.ChatRequest{
+		Model:    l.Model,
+		Messages: []models.Message{{Role: "user", Content: fmt.Sprintf(llmPrompt, p.URL, p.Title, text)}},
+		Options:  &models.Options{Temperature: &temperature},
+	})
+	if err == nil {
+		// Small models sometimes add a sentence; the first word naming a type wins
+		for _, word := range strings.Fields(resp.Message.Content) {
+			if t, ok := ParseType(word); ok {
+				return Result{Type: t, Method: MethodLLM}, nil
+			}
+		}
+		err = fmt.Errorf("unrecognized answer %q", resp.Message.Content)
+	}
+	if l.Fallback == nil {
+		return Result{}, err
+	}
+	return l.Fallback.Classify(ctx, p)
+}
+
+// New returns the classifier selected by method: "rules" (the rules in rulesPath, or
+// DefaultRules when it is empty) or "llm" (model on the Ollama server at baseURL, with the
+// rules as fallback). An empty method or "off" returns nil.
+func New(method, rulesPath, model, baseURL string) (Classifier, error) {
+	if method == "" || method == "off" {
+		return nil, nil
+	}
+	ruleList := DefaultRules()
+	if rulesPath != "" {
+		var err error
+		if ruleList, err = LoadRules(rulesPath); err != nil {
+			return nil, err
+		}
+	}
+	rules, err := NewRules(ruleList)
+	if err != nil {
+		return nil, err
+	}
+	switch method {
+	case MethodRules:
+		return rules, nil
+	case MethodLLM:
+		if model == "" {
+			model = DefaultLLMModel
+		}
+		return &LLM{Client: client.New(client.NewOllamaClient(baseURL)), Model: model, Fallback: rules}, nil
+	}
+	return nil, fmt.Errorf("unknown classification method %q (use rules, llm, or off)", method)
+}
@@ -0,0 +1,66 @@
+package classify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/chunker"
+)
+
+// Profile is how pages of one type are turned into chunks
+type Profile struct {
+	// MaxTokens is the chunk size (chunker.DefaultMaxTokens when zero)
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Captions keeps the image alt text and captions of the page as auxiliary chunks
+	Captions bool `json:"captions"`
+	// Skip leaves pages of this type out of the corpus
+	Skip bool `json:"skip,omitempty"`
+}
+
+// ChunkTokens returns the chunk size of the profile
+func (p Profile) ChunkTokens() int {
+	if p.MaxTokens > 0 {
+		return p.MaxTokens
+	}
+	return chunker.DefaultMaxTokens
+}
+
+// DefaultProfiles keep articles and unclassified pages as before and split the short,
+// fact-dense types into smaller chunks, so an event's date and place or a product's price
+// stay in the chunk that names them. Landing pages are mostly navigation; their images are
+// dropped.
+func DefaultProfiles() map[Type]Profile {
+	return map[Type]Profile{
+		Article: {MaxTokens: chunker.DefaultMaxTokens, Captions: true},
+		Event:   {MaxTokens: 200, Captions: false},
+		Product: {MaxTokens: 200, Captions: true},
+		Landing: {MaxTokens: 150, Captions: false},
+		Other:   {MaxTokens: chunker.DefaultMaxTokens, Captions: true},
+	}
+}
+
+// LoadProfiles reads a JSON object of profiles keyed by page type from path. Types it does
+// not mention keep their default profile.
+func LoadProfiles(path string) (map[Type]Profile, error) {
+	profiles := DefaultProfiles()
+	if path == "" {
+		return profiles, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var overrides map[string]Profile
+	if err := json.Unmarshal(b, &overrides); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name, p := range overrides {
+		t, ok := ParseType(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown page type %q", path, name)
+		}
+		profiles[t] = p
+	}
+	return profiles, nil
+}
@@ -0,0 +1,471 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/errors"
+	"kirk-ai/internal/models"
+)
+
+// Provider names accepted by --provider and KIRK_PROVIDER
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+)
+
+// Provider is an LLM server that can chat, stream, embed, and list its models. Requests
+// and responses use the Ollama shapes in internal/models whatever the server speaks.
+type Provider interface {
+	// ChatWithRequest sends a non-streaming chat request
+	ChatWithRequest(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error)
+	// ChatStreamWithRequest streams a chat request, calling callback for each chunk
+	ChatStreamWithRequest(ctx context.Context, request models.ChatRequest, callback func(chunk *models.StreamingChatResponse) error) (*models.ChatResponse, error)
+	// EmbeddingContext embeds text with model
+	EmbeddingContext(ctx context.Context, model, text string) (*models.EmbeddingResponse, error)
+	// EmbeddingBatchContext embeds several texts in one request, returning them in input order
+	EmbeddingBatchContext(ctx context.Context, model string, texts []string) ([][]float64, error)
+	// ListModelsContext returns the names of the models the server offers
+	ListModelsContext(ctx context.Context) ([]string, error)
+}
+
+// ModelManager is implemented by providers that report model sizes and residency and can
+// evict models from memory; only Ollama does
+type ModelManager interface {
+	ListModelDetails(ctx context.Context) ([]models.Model, error)
+	RunningModels(ctx context.Context) ([]models.RunningModel, error)
+	Unload(ctx context.Context, model string) error
+	// Load loads model into memory ahead of its first request
+	Load(ctx context.Context, model string) error
+	// Show describes an installed model: its Modelfile parameters and weights
+	Show(ctx context.Context, model string) (*models.ShowResponse, error)
+	// Pull downloads model, reporting each step of the download to progress
+	Pull(ctx context.Context, model string, progress func(models.PullProgress)) error
+	// Delete removes model from disk
+	Delete(ctx context.Context, model string) error
+	// Version returns the server's version
+	Version(ctx context.Context) (string, error)
+}
+
+// DefaultNumCtx is the context window Ollama gives a model whose Modelfile and request set none
+const DefaultNumCtx = 4096
+
+// Client is the provider-independent front end the commands use: prompt helpers, the
+// standing system prompt, request priorities, and model selection on top of a Provider.
+// Chat and embedding requests made with a WithPriority(ctx, PriorityBatch) context give
+// way to the client's other requests.
+type Client struct {
+	Provider Provider
+	// System, when set, is sent as a system message ahead of every chat request
+	System string
+	// Options are the generation options of every chat request, for those it leaves unset
+	Options *models.Options
+
+	sched scheduler
+}
+
+// New creates a client that sends requests to p
+func New(p Provider) *Client {
+	return &Client{Provider: p}
+}
+
+// Chat sends a chat request and returns the response
+func (c *Client) Chat(model, prompt string) (*models.ChatResponse, error) {
+	return c.ChatContext(context.Background(), model, prompt)
+}
+
+// ChatContext is like Chat but honors ctx for cancellation
+func (c *Client) ChatContext(ctx context.Context, model, prompt string) (*models.ChatResponse, error) {
+	if prompt == "" {
+		return nil, errors.NewValidationError("prompt", "prompt cannot be empty")
+	}
+	request := models.ChatRequest{
+		Model:    model,
+		Messages: []models.Message{{Role: "user", Content: prompt}},
+	}
+	return c.ChatWithRequest(ctx, request)
+}
+
+// ChatWithRequest sends a fully specified non-streaming chat request, including any
+// conversation history and generation options
+func (c *Client) ChatWithRequest(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error) {
+	request.Messages = c.withSystem(request.Messages)
+	request.Options = request.Options.WithDefaults(c.Options)
+	release, err := c.sched.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Provider.ChatWithRequest(ctx, request)
+}
+
+// ChatStream sends a streaming chat request and calls the callback for each chunk
+func (c *Client) ChatStream(model, prompt string, callback func(chunk *models.StreamingChatResponse) error) (*models.ChatResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+	return c.ChatStreamContext(ctx, model, prompt, callback)
+}
+
+// ChatStreamContext is like ChatStream but honors ctx instead of the default 300s stream timeout
+func (c *Client) ChatStreamContext(ctx context.Context, model, prompt string, callback func(chunk *models.StreamingChatResponse) error) (*models.ChatResponse, error) {
+	if prompt == "" {
+		return nil, errors.NewValidationError("prompt", "prompt cannot be empty")
+	}
+	request := models.ChatRequest{
+		Model:    model,
+		Messages: []models.Message{{Role: "user", Content: prompt}},
+	}
+	return c.ChatStreamWithRequest(ctx, request, callback)
+}
+
+// ChatStreamWithRequest streams a fully specified chat request, calling callback for each chunk
+func (c *Client) ChatStreamWithRequest(ctx context.Context, request models.ChatRequest, callback func(chunk *models.StreamingChatResponse) error) (*models.ChatResponse, error) {
+	request.Messages = c.withSystem(request.Messages)
+	request.Options = request.Options.WithDefaults(c.Options)
+	release, err := c.sched.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Provider.ChatStreamWithRequest(ctx, request, callback)
+}
+
+// withSystem returns messages with c.System prepended as a system message, unless it is
+// unset or the messages already start with it
+func (c *Client) withSystem(messages []models.Message) []models.Message {
+	if c.System == "" || len(messages) == 0 || (messages[0].Role == "system" && messages[0].Content == c.System) {
+		return messages
+	}
+	return append([]models.Message{{Role: "system", Content: c.System}}, messages...)
+}
+
+// Embedding generates embeddings for the given text using the specified model
+func (c *Client) Embedding(model, text string) (*models.EmbeddingResponse, error) {
+	return c.EmbeddingContext(context.Background(), model, text)
+}
+
+// EmbeddingContext is like Embedding but honors ctx for cancellation and priority
+func (c *Client) EmbeddingContext(ctx context.Context, model, text string) (*models.EmbeddingResponse, error) {
+	release, err := c.sched.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Provider.EmbeddingContext(ctx, model, text)
+}
+
+// EmbeddingBatch embeds several texts in one request, returning the embeddings in input order
+func (c *Client) EmbeddingBatch(model string, texts []string) ([][]float64, error) {
+	return c.EmbeddingBatchContext(context.Background(), model, texts)
+}
+
+// EmbeddingBatchContext is like EmbeddingBatch but honors ctx for cancellation and priority
+func (c *Client) EmbeddingBatchContext(ctx context.Context, model string, texts []string) ([][]float64, error) {
+	release, err := c.sched.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Provider.EmbeddingBatchContext(ctx, model, texts)
+}
+
+// ListModels gets the list of available models
+func (c *Client) ListModels() ([]string, error) {
+	return c.Provider.ListModelsContext(context.Background())
+}
+
+// ListModelsContext is like ListModels but honors ctx for cancellation
+func (c *Client) ListModelsContext(ctx context.Context) ([]string, error) {
+	return c.Provider.ListModelsContext(ctx)
+}
+
+// modelManager returns the provider's ModelManager, or an error naming the operation it lacks
+func (c *Client) modelManager(operation string) (ModelManager, error) {
+	if m, ok := c.Provider.(ModelManager); ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("%s is only supported by the %s provider", operation, ProviderOllama)
+}
+
+// ListModelDetails returns the installed models with their size and quantization details
+func (c *Client) ListModelDetails(ctx context.Context) ([]models.Model, error) {
+	m, err := c.modelManager("listing model details")
+	if err != nil {
+		return nil, err
+	}
+	return m.ListModelDetails(ctx)
+}
+
+// RunningModels returns the models currently loaded in memory and how much of each is on the GPU
+func (c *Client) RunningModels(ctx context.Context) ([]models.RunningModel, error) {
+	m, err := c.modelManager("listing running models")
+	if err != nil {
+		return nil, err
+	}
+	return m.RunningModels(ctx)
+}
+
+// Unload evicts model from memory so the next request to it starts from a cold load
+func (c *Client) Unload(ctx context.Context, model string) error {
+	m, err := c.modelManager("unloading models")
+	if err != nil {
+		return err
+	}
+	return m.Unload(ctx, model)
+}
+
+// Warmup loads model into memory so the first real request to it does not wait for the load
+func (c *Client) Warmup(ctx context.Context, model string) error {
+	m, err := c.modelManager("warming up models")
+	if err != nil {
+		return err
+	}
+	return m.Load(ctx, model)
+}
+
+// Show describes an installed model: its details, Modelfile parameters, template, and license
+func (c *Client) Show(ctx context.Context, model string) (*models.ShowResponse, error) {
+	m, err := c.modelManager("describing models")
+	if err != nil {
+		return nil, err
+	}
+	return m.Show(ctx, model)
+}
+
+// Pull downloads model from the registry, calling progress with each step of the download
+func (c *Client) Pull(ctx context.Context, model string, progress func(models.PullProgress)) error {
+	m, err := c.modelManager("pulling models")
+	if err != nil {
+		return err
+	}
+	return m.Pull(ctx, model, progress)
+}
+
+// Delete removes an installed model from disk
+func (c *Client) Delete(ctx context.Context, model string) error {
+	m, err := c.modelManager("deleting models")
+	if err != nil {
+		return err
+	}
+	return m.Delete(ctx, model)
+}
+
+// ServerVersion returns the version of the server behind the provider
+func (c *Client) ServerVersion(ctx context.Context) (string, error) {
+	m, err := c.modelManager("reading the server version")
+	if err != nil {
+		return "", err
+	}
+	return m.Version(ctx)
+}
+
+// ContextWindow returns how many tokens of prompt and answer together a chat request to
+// model can hold: num_ctx from the client's Options, else from the model's Modelfile, else
+// DefaultNumCtx, and never more than the model was trained with. trained is that length, 0
+// when the model does not report it.
+func (c *Client) ContextWindow(ctx context.Context, model string) (window, trained int, err error) {
+	m, err := c.modelManager("reading a model's context window")
+	if err != nil {
+		return 0, 0, err
+	}
+	info, err := m.Show(ctx, model)
+	if err != nil {
+		return 0, 0, err
+	}
+	window, trained = c.WindowOf(info)
+	return window, trained, nil
+}
+
+// WindowOf is ContextWindow for a model already described by Show
+func (c *Client) WindowOf(info *models.ShowResponse) (window, trained int) {
+	trained = ContextLength(info)
+	switch {
+	case c.Options != nil && c.Options.NumCtx != nil:
+		window = *c.Options.NumCtx
+	default:
+		window = DefaultNumCtx
+		for _, line := range strings.Split(info.Parameters, "\n") {
+			if f := strings.Fields(line); len(f) == 2 && f[0] == "num_ctx" {
+				if n, err := strconv.Atoi(f[1]); err == nil && n > 0 {
+					window = n
+				}
+			}
+		}
+	}
+	if trained > 0 && window > trained {
+		window = trained
+	}
+	return window, trained
+}
+
+// ContextLength returns how many tokens the model described by info was trained with, or 0
+// when its model info does not say
+func ContextLength(info *models.ShowResponse) int {
+	for key, v := range info.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+// ProbeModel classifies the model described by info: its family and size, the dimension of
+// its vectors, whether its weights pool them as an embedding model's do, and the
+// capabilities inferred from all that
+func ProbeModel(name string, info *models.ShowResponse) config.ModelFacts {
+	f := config.ModelFacts{
+		Name:          name,
+		Family:        info.Details.Family,
+		ParameterSize: info.Details.ParameterSize,
+		Quantization:  info.Details.QuantizationLevel,
+		ContextLength: ContextLength(info),
+		Capabilities:  info.Capabilities,
+	}
+	for key, v := range info.ModelInfo {
+		switch {
+		case strings.HasSuffix(key, ".embedding_length"):
+			if n, ok := v.(float64); ok {
+				f.EmbeddingLength = int(n)
+			}
+		case strings.HasSuffix(key, ".pooling_type"):
+			// 0 is no pooling; generative models leave the key out
+			if n, ok := v.(float64); !ok || n != 0 {
+				f.Pooling = true
+			}
+		}
+	}
+	f.Inferred = config.InferCapabilities(f)
+	return f
+}
+
+// ModelFacts returns what the server reports about each of the named models that is
+// installed, in the order of names. Facts are cached in the configuration directory by
+// model digest, so only models pulled since the last call are described again.
+func (c *Client) ModelFacts(ctx context.Context, names []string) ([]config.ModelFacts, error) {
+	m, err := c.modelManager("describing models")
+	if err != nil {
+		return nil, err
+	}
+	installed, err := m.ListModelDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.Model, len(installed))
+	for _, model := range installed {
+		byName[model.Name] = model
+	}
+
+	cache := config.LoadModelFacts()
+	changed := false
+	var facts []config.ModelFacts
+	for _, name := range names {
+		model, ok := byName[name]
+		if !ok {
+			continue
+		}
+		// Entries cached before capabilities were inferred are probed again
+		if f, ok := cache[name]; ok && f.Digest != "" && f.Digest == model.Digest && f.Inferred != nil {
+			facts = append(facts, f)
+			continue
+		}
+		info, err := m.Show(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("describing %s: %w", name, err)
+		}
+		f := ProbeModel(name, info)
+		f.Digest = model.Digest
+		cache[name], changed = f, true
+		facts = append(facts, f)
+	}
+	if changed {
+		// A cache that cannot be written only costs the next selection a few requests
+		_ = config.SaveModelFacts(cache)
+	}
+	return facts, nil
+}
+
+// SelectChatModel automatically selects a suitable model for chat
+// Deprecated: Use SelectModelByCapability instead
+func (c *Client) SelectChatModel(models []string) string {
+	return c.SelectModelByCapability(models, "chat")
+}
+
+// SelectEmbeddingModel automatically selects a suitable model for embeddings
+// Deprecated: Use SelectModelByCapability instead
+func (c *Client) SelectEmbeddingModel(models []string) string {
+	return c.SelectModelByCapability(models, "embedding")
+}
+
+// SelectModelByCapability selects the best model for a capability ("chat", "embedding",
+// "rag", or a config.ModelCapability) among models. Models the user prefers for it in
+// models.yaml come first. Otherwise, with Ollama, it goes by what the server reports about
+// each model; other providers, and servers that cannot describe their models, fall back to
+// matching names.
+func (c *Client) SelectModelByCapability(models []string, capability string) string {
+	if preferred := config.PreferredModel(models, config.ModelCapability(capability)); preferred != "" {
+		return preferred
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if facts, err := c.ModelFacts(ctx, models); err == nil {
+		var selected string
+		if capability == "rag" {
+			// For RAG, prefer faster, smaller models for better performance
+			selected = config.SelectSmallestModel(facts, config.CapabilityChat)
+		} else {
+			selected = config.SelectBestModelByFacts(facts, config.ModelCapability(capability))
+		}
+		if selected != "" {
+			return selected
+		}
+	}
+	return selectModelByName(models, capability)
+}
+
+// selectModelByName selects a model for capability from model names alone
+func selectModelByName(models []string, capability string) string {
+	if capability == "embedding" {
+		for _, model := range models {
+			if strings.Contains(strings.ToLower(model), "embed") {
+				return model
+			}
+		}
+	} else if capability == "rag" {
+		// For RAG, prefer faster, smaller models for better performance
+		fastModels := []string{"llama3.2:1b", "gemma3:4b", "qwen2.5:1.5b", "llama3.2:3b"}
+		for _, fast := range fastModels {
+			for _, model := range models {
+				if strings.Contains(strings.ToLower(model), fast) {
+					return model
+				}
+			}
+		}
+		// Fallback to regular chat model selection
+		capability = "chat"
+	}
+
+	if capability == "chat" {
+		// Prefer gemma3:4b for chat and other tasks
+		for _, model := range models {
+			if strings.Contains(strings.ToLower(model), "gemma3") {
+				return model
+			}
+		}
+		// Fallback to non-embedding models
+		for _, model := range models {
+			if !strings.Contains(strings.ToLower(model), "embed") {
+				return model
+			}
+		}
+	} else if capability != "embedding" {
+		return config.SelectBestModel(models, config.ModelCapability(capability))
+	}
+	if len(models) > 0 {
+		return models[0]
+	}
+	return ""
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,10 +16,34 @@ import (
 	"kirk-ai/internal/models"
 )
 
-// OllamaClient represents a client for interacting with Ollama API
+// DefaultTimeout is the HTTP timeout of a client created with NewOllamaClient, generous enough for model loading
+const DefaultTimeout = 120 * time.Second
+
+// OllamaClient is the Provider for the Ollama API; it also implements ModelManager
 type OllamaClient struct {
 	BaseURL string
 	Client  *http.Client
+	// RetryPolicy applies to every request; streams are retried only while connecting
+	RetryPolicy
+	// KeepAlive is sent with chat and embedding requests that do not set their own, to keep
+	// the model loaded that long after the request ("" leaves it to the server, 5m by default)
+	KeepAlive string
+}
+
+// ParseKeepAlive checks a keep-alive setting and returns it as Ollama takes it: a duration
+// such as "30m", or a number of seconds. A negative value keeps the model loaded until the
+// server stops, and 0 unloads it after each request.
+func ParseKeepAlive(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.FormatFloat(n, 'f', -1, 64) + "s", nil
+	}
+	if _, err := time.ParseDuration(s); err != nil {
+		return "", fmt.Errorf("invalid keep-alive %q: want a duration such as 30m, or a number of seconds (-1 keeps the model loaded)", s)
+	}
+	return s, nil
 }
 
 // NewOllamaClient creates a new Ollama client
@@ -26,8 +51,9 @@ func NewOllamaClient(baseURL string) *OllamaClient {
 	return &OllamaClient{
 		BaseURL: baseURL,
 		Client: &http.Client{
-			Timeout: 120 * time.Second, // Increased for model loading
+			Timeout: DefaultTimeout,
 		},
+		RetryPolicy: DefaultRetryPolicy(),
 	}
 }
 
@@ -38,35 +64,41 @@ func NewOllamaClientWithTimeout(baseURL string, timeout time.Duration) *OllamaCl
 		Client: &http.Client{
 			Timeout: timeout,
 		},
+		RetryPolicy: DefaultRetryPolicy(),
 	}
 }
 
-// Chat sends a chat request to Ollama and returns the response
-func (c *OllamaClient) Chat(model, prompt string) (*models.ChatResponse, error) {
-	if model == "" {
-		return nil, errors.NewValidationError("model", "model cannot be empty")
-	}
-	if prompt == "" {
-		return nil, errors.NewValidationError("prompt", "prompt cannot be empty")
-	}
-
-	request := models.ChatRequest{
-		Model: model,
-		Messages: []models.Message{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream: false,
-	}
+// withRetry runs fn, retrying transient failures as c.RetryPolicy allows
+func (c *OllamaClient) withRetry(ctx context.Context, fn func() error) error {
+	return c.RetryPolicy.do(ctx, fn)
+}
 
+// postJSON sends a JSON request to the given API path and returns the response body,
+// retrying transient failures
+func (c *OllamaClient) postJSON(ctx context.Context, path string, request interface{}) ([]byte, error) {
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		return nil, errors.NewNetworkError("marshal request", err)
 	}
 
-	resp, err := c.Client.Post(c.BaseURL+"/api/chat", "application/json", bytes.NewBuffer(jsonData))
+	var body []byte
+	err = c.withRetry(ctx, func() error {
+		var err error
+		body, err = c.doPost(ctx, path, jsonData)
+		return err
+	})
+	return body, err
+}
+
+// doPost makes a single POST attempt
+func (c *OllamaClient) doPost(ctx context.Context, path string, jsonData []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, errors.NewNetworkError("create request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
 	if err != nil {
 		return nil, errors.NewNetworkError("send request", err)
 	}
@@ -80,6 +112,27 @@ func (c *OllamaClient) Chat(model, prompt string) (*models.ChatResponse, error)
 	if resp.StatusCode != http.StatusOK {
 		return nil, errors.NewAPIError(resp.StatusCode, string(body))
 	}
+	return body, nil
+}
+
+// ChatWithRequest sends a fully specified non-streaming chat request, including any
+// conversation history and generation options
+func (c *OllamaClient) ChatWithRequest(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error) {
+	if request.Model == "" {
+		return nil, errors.NewValidationError("model", "model cannot be empty")
+	}
+	if len(request.Messages) == 0 {
+		return nil, errors.NewValidationError("messages", "messages cannot be empty")
+	}
+	request.Stream = false
+	if request.KeepAlive == "" {
+		request.KeepAlive = c.KeepAlive
+	}
+
+	body, err := c.postJSON(ctx, "/api/chat", request)
+	if err != nil {
+		return nil, err
+	}
 
 	var chatResponse models.ChatResponse
 	if err := json.Unmarshal(body, &chatResponse); err != nil {
@@ -89,38 +142,147 @@ func (c *OllamaClient) Chat(model, prompt string) (*models.ChatResponse, error)
 	return &chatResponse, nil
 }
 
-// Embedding generates embeddings for the given text using the specified model
-func (c *OllamaClient) Embedding(model, text string) (*models.EmbeddingResponse, error) {
+// Unload asks Ollama to evict model from memory right away (keep_alive 0), so the next
+// request to it starts from a cold load
+func (c *OllamaClient) Unload(ctx context.Context, model string) error {
 	if model == "" {
-		return nil, errors.NewValidationError("model", "model cannot be empty")
-	}
-	if text == "" {
-		return nil, errors.NewValidationError("text", "text cannot be empty")
+		return errors.NewValidationError("model", "model cannot be empty")
 	}
+	request := models.ChatRequest{Model: model, Messages: []models.Message{}, KeepAlive: "0s"}
+	_, err := c.postJSON(ctx, "/api/chat", request)
+	return err
+}
 
-	request := models.EmbeddingRequest{
-		Model:  model,
-		Prompt: text,
+// Load asks Ollama to load model without generating anything, keeping it for KeepAlive. An
+// embedding model, which cannot chat, is loaded by an embedding request with no input.
+func (c *OllamaClient) Load(ctx context.Context, model string) error {
+	if model == "" {
+		return errors.NewValidationError("model", "model cannot be empty")
+	}
+	request := models.ChatRequest{Model: model, Messages: []models.Message{}, KeepAlive: c.KeepAlive}
+	_, err := c.postJSON(ctx, "/api/chat", request)
+	if apiErr, ok := errors.AsAPIError(err); ok && apiErr.StatusCode == http.StatusBadRequest {
+		_, err = c.postJSON(ctx, "/api/embed", models.BatchEmbeddingRequest{Model: model, Input: []string{}, KeepAlive: c.KeepAlive})
 	}
+	return err
+}
 
-	jsonData, err := json.Marshal(request)
+// Pull downloads model from the registry, calling progress for each status line the server
+// streams. The download is not bound by the client's request timeout, only by ctx.
+func (c *OllamaClient) Pull(ctx context.Context, model string, progress func(models.PullProgress)) error {
+	if model == "" {
+		return errors.NewValidationError("model", "model cannot be empty")
+	}
+	jsonData, err := json.Marshal(models.PullRequest{Model: model, Stream: true})
 	if err != nil {
-		return nil, errors.NewNetworkError("marshal request", err)
+		return errors.NewNetworkError("marshal request", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/api/pull", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return errors.NewNetworkError("create request", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.Client.Post(c.BaseURL+"/api/embeddings", "application/json", bytes.NewBuffer(jsonData))
+	// A pull of several gigabytes outlasts any per-request timeout
+	untimed := *c.Client
+	untimed.Timeout = 0
+	resp, err := untimed.Do(req)
 	if err != nil {
-		return nil, errors.NewNetworkError("send request", err)
+		return errors.NewNetworkError("send request", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return errors.NewAPIError(resp.StatusCode, string(body))
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line models.PullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		if line.Error != "" {
+			return fmt.Errorf("%s", line.Error)
+		}
+		if progress != nil {
+			progress(line)
+		}
+		if line.Status == "success" {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.NewNetworkError("read response", err)
+	}
+	return fmt.Errorf("the server ended the download without reporting success")
+}
+
+// Delete removes model from the server's disk
+func (c *OllamaClient) Delete(ctx context.Context, model string) error {
+	if model == "" {
+		return errors.NewValidationError("model", "model cannot be empty")
+	}
+	jsonData, err := json.Marshal(models.DeleteRequest{Model: model})
 	if err != nil {
-		return nil, errors.NewNetworkError("read response", err)
+		return errors.NewNetworkError("marshal request", err)
 	}
+	return c.withRetry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, "DELETE", c.BaseURL+"/api/delete", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return errors.NewNetworkError("create request", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return errors.NewNetworkError("send request", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return errors.NewAPIError(resp.StatusCode, string(body))
+		}
+		return nil
+	})
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewAPIError(resp.StatusCode, string(body))
+// Show describes an installed model
+func (c *OllamaClient) Show(ctx context.Context, model string) (*models.ShowResponse, error) {
+	if model == "" {
+		return nil, errors.NewValidationError("model", "model cannot be empty")
+	}
+	body, err := c.postJSON(ctx, "/api/show", models.ShowRequest{Model: model})
+	if err != nil {
+		return nil, err
+	}
+	var response models.ShowResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.NewNetworkError("unmarshal response", err)
+	}
+	return &response, nil
+}
+
+// EmbeddingContext generates embeddings for text using model
+func (c *OllamaClient) EmbeddingContext(ctx context.Context, model, text string) (*models.EmbeddingResponse, error) {
+	if model == "" {
+		return nil, errors.NewValidationError("model", "model cannot be empty")
+	}
+	if text == "" {
+		return nil, errors.NewValidationError("text", "text cannot be empty")
+	}
+
+	request := models.EmbeddingRequest{
+		Model:     model,
+		Prompt:    text,
+		KeepAlive: c.KeepAlive,
+	}
+
+	body, err := c.postJSON(ctx, "/api/embeddings", request)
+	if err != nil {
+		return nil, err
 	}
 
 	var embeddingResponse models.EmbeddingResponse
@@ -131,110 +293,128 @@ func (c *OllamaClient) Embedding(model, text string) (*models.EmbeddingResponse,
 	return &embeddingResponse, nil
 }
 
-// ListModels gets the list of available models from Ollama
-func (c *OllamaClient) ListModels() ([]string, error) {
-	resp, err := c.Client.Get(c.BaseURL + "/api/tags")
-	if err != nil {
-		return nil, errors.NewNetworkError("send request", err)
+// EmbeddingBatchContext embeds every text in one /api/embed request, returning the
+// embeddings in input order. Servers without /api/embed (Ollama before 0.3) get one
+// /api/embeddings request per text instead.
+func (c *OllamaClient) EmbeddingBatchContext(ctx context.Context, model string, texts []string) ([][]float64, error) {
+	if model == "" {
+		return nil, errors.NewValidationError("model", "model cannot be empty")
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.NewNetworkError("read response", err)
+	if len(texts) == 0 {
+		return nil, errors.NewValidationError("texts", "texts cannot be empty")
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewAPIError(resp.StatusCode, string(body))
+	body, err := c.postJSON(ctx, "/api/embed", models.BatchEmbeddingRequest{Model: model, Input: texts, KeepAlive: c.KeepAlive})
+	if apiErr, ok := errors.AsAPIError(err); ok && apiErr.StatusCode == http.StatusNotFound && !strings.Contains(apiErr.Message, "model") {
+		embeddings := make([][]float64, len(texts))
+		for i, text := range texts {
+			resp, err := c.EmbeddingContext(ctx, model, text)
+			if err != nil {
+				return nil, err
+			}
+			embeddings[i] = resp.Embedding
+		}
+		return embeddings, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	var response models.ModelsResponse
+	var response models.BatchEmbeddingResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, errors.NewNetworkError("unmarshal response", err)
 	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, errors.NewNetworkError("incomplete response", fmt.Errorf("got %d embeddings for %d inputs", len(response.Embeddings), len(texts)))
+	}
+	return response.Embeddings, nil
+}
+
+// ListModelsContext returns the names of the installed models
+func (c *OllamaClient) ListModelsContext(ctx context.Context) ([]string, error) {
+	details, err := c.ListModelDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	modelNames := make([]string, len(response.Models))
-	for i, model := range response.Models {
+	modelNames := make([]string, len(details))
+	for i, model := range details {
 		modelNames[i] = model.Name
 	}
 
 	return modelNames, nil
 }
 
-// SelectChatModel automatically selects a suitable model for chat
-// Deprecated: Use SelectModelByCapability instead
-func (c *OllamaClient) SelectChatModel(models []string) string {
-	return c.SelectModelByCapability(models, "chat")
+// ListModelDetails returns the installed models with their size and quantization details
+func (c *OllamaClient) ListModelDetails(ctx context.Context) ([]models.Model, error) {
+	var response models.ModelsResponse
+	if err := c.getJSON(ctx, "/api/tags", &response); err != nil {
+		return nil, err
+	}
+	return response.Models, nil
 }
 
-// SelectEmbeddingModel automatically selects a suitable model for embeddings
-// Deprecated: Use SelectModelByCapability instead
-func (c *OllamaClient) SelectEmbeddingModel(models []string) string {
-	return c.SelectModelByCapability(models, "embedding")
+// RunningModels returns the models currently loaded in memory and how much of each is on the GPU
+func (c *OllamaClient) RunningModels(ctx context.Context) ([]models.RunningModel, error) {
+	var response models.RunningModelsResponse
+	if err := c.getJSON(ctx, "/api/ps", &response); err != nil {
+		return nil, err
+	}
+	return response.Models, nil
 }
 
-// SelectModelByCapability selects the best model for a given capability
-func (c *OllamaClient) SelectModelByCapability(models []string, capability string) string {
-	// This will be implemented using the config package
-	// For now, maintain backward compatibility
-	if capability == "embedding" {
-		for _, model := range models {
-			if strings.Contains(strings.ToLower(model), "embed") {
-				return model
-			}
-		}
-	} else if capability == "rag" {
-		// For RAG, prefer faster, smaller models for better performance
-		fastModels := []string{"llama3.2:1b", "gemma3:4b", "qwen2.5:1.5b", "llama3.2:3b"}
-		for _, fast := range fastModels {
-			for _, model := range models {
-				if strings.Contains(strings.ToLower(model), fast) {
-					return model
-				}
-			}
-		}
-		// Fallback to regular chat model selection
-		capability = "chat"
+// Version returns the Ollama server's version, e.g. "0.6.2"
+func (c *OllamaClient) Version(ctx context.Context) (string, error) {
+	var response models.VersionResponse
+	if err := c.getJSON(ctx, "/api/version", &response); err != nil {
+		return "", err
 	}
+	return response.Version, nil
+}
 
-	if capability == "chat" {
-		// Prefer gemma3:4b for chat and other tasks
-		for _, model := range models {
-			if strings.Contains(strings.ToLower(model), "gemma3") {
-				return model
-			}
-		}
-		// Fallback to non-embedding models
-		for _, model := range models {
-			if !strings.Contains(strings.ToLower(model), "embed") {
-				return model
-			}
-		}
+// getJSON fetches an API path and decodes the JSON response into out, retrying transient failures
+func (c *OllamaClient) getJSON(ctx context.Context, path string, out interface{}) error {
+	return c.withRetry(ctx, func() error { return c.doGet(ctx, path, out) })
+}
+
+// doGet makes a single GET attempt
+func (c *OllamaClient) doGet(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+path, nil)
+	if err != nil {
+		return errors.NewNetworkError("create request", err)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return errors.NewNetworkError("send request", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.NewNetworkError("read response", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.NewAPIError(resp.StatusCode, string(body))
 	}
-	if len(models) > 0 {
-		return models[0]
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return errors.NewNetworkError("unmarshal response", err)
 	}
-	return ""
+	return nil
 }
 
-// ChatStream sends a streaming chat request to Ollama and calls the callback for each chunk
-func (c *OllamaClient) ChatStream(model, prompt string, callback func(chunk *models.StreamingChatResponse) error) (*models.ChatResponse, error) {
-	if model == "" {
+// ChatStreamWithRequest streams a fully specified chat request, calling callback for each chunk
+func (c *OllamaClient) ChatStreamWithRequest(ctx context.Context, request models.ChatRequest, callback func(chunk *models.StreamingChatResponse) error) (*models.ChatResponse, error) {
+	if request.Model == "" {
 		return nil, errors.NewValidationError("model", "model cannot be empty")
 	}
-	if prompt == "" {
-		return nil, errors.NewValidationError("prompt", "prompt cannot be empty")
+	if len(request.Messages) == 0 {
+		return nil, errors.NewValidationError("messages", "messages cannot be empty")
 	}
-
-	request := models.ChatRequest{
-		Model: model,
-		Messages: []models.Message{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream: true, // Enable streaming
+	request.Stream = true // Enable streaming
+	if request.KeepAlive == "" {
+		request.KeepAlive = c.KeepAlive
 	}
 
 	jsonData, err := json.Marshal(request)
@@ -242,26 +422,31 @@ func (c *OllamaClient) ChatStream(model, prompt string, callback func(chunk *mod
 		return nil, errors.NewNetworkError("marshal request", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/api/chat", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, errors.NewNetworkError("create request", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+	// Only establishing the stream is retried; once chunks reach the callback a retry would repeat them
+	var resp *http.Response
+	err = c.withRetry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/api/chat", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return errors.NewNetworkError("create request", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.Client.Do(req)
+		resp, err = c.Client.Do(req)
+		if err != nil {
+			return errors.NewNetworkError("send request", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return errors.NewAPIError(resp.StatusCode, string(body))
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.NewNetworkError("send request", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, errors.NewAPIError(resp.StatusCode, string(body))
-	}
-
 	scanner := bufio.NewScanner(resp.Body)
 	var finalResponse *models.ChatResponse
 	fullContent := ""
@@ -295,6 +480,7 @@ func (c *OllamaClient) ChatStream(model, prompt string, callback func(chunk *mod
 				CreatedAt:          chunk.CreatedAt,
 				Message:            models.Message{Role: "assistant", Content: fullContent},
 				Done:               true,
+				DoneReason:         chunk.DoneReason,
 				TotalDuration:      chunk.TotalDuration,
 				LoadDuration:       chunk.LoadDuration,
 				PromptEvalCount:    chunk.PromptEvalCount,
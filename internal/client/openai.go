@@ -0,0 +1,309 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/errors"
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/openai"
+)
+
+// DefaultOpenAIBaseURL is where a local llama.cpp server listens; vLLM uses port 8000 and LM Studio 1234
+const DefaultOpenAIBaseURL = "http://localhost:8080/v1"
+
+// OpenAIClient is the Provider for servers speaking the OpenAI API: llama.cpp server, vLLM,
+// LM Studio, and hosted endpoints. BaseURL includes the version prefix, e.g. http://host:8000/v1.
+type OpenAIClient struct {
+	BaseURL string
+	// APIKey, when set, is sent as a bearer token; local servers usually ignore it
+	APIKey string
+	Client *http.Client
+	// RetryPolicy applies to every request; streams are retried only while connecting
+	RetryPolicy
+}
+
+// NewOpenAIClientWithTimeout creates a client for the OpenAI-compatible API at baseURL
+func NewOpenAIClientWithTimeout(baseURL, apiKey string, timeout time.Duration) *OpenAIClient {
+	return &OpenAIClient{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		Client: &http.Client{
+			Timeout: timeout,
+		},
+		RetryPolicy: DefaultRetryPolicy(),
+	}
+}
+
+// newRequest builds a request to an API path with the JSON and auth headers set
+func (c *OpenAIClient) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.NewNetworkError("create request", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	return req, nil
+}
+
+// call sends a request to an API path and decodes the JSON response into out, retrying
+// transient failures. A nil request makes it a GET.
+func (c *OpenAIClient) call(ctx context.Context, path string, request, out interface{}) error {
+	method := "GET"
+	var jsonData []byte
+	if request != nil {
+		method = "POST"
+		var err error
+		if jsonData, err = json.Marshal(request); err != nil {
+			return errors.NewNetworkError("marshal request", err)
+		}
+	}
+	return c.RetryPolicy.do(ctx, func() error {
+		req, err := c.newRequest(ctx, method, path, jsonData)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return errors.NewNetworkError("send request", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return errors.NewNetworkError("read response", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return errors.NewAPIError(resp.StatusCode, string(body))
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return errors.NewNetworkError("unmarshal response", err)
+		}
+		return nil
+	})
+}
+
+// ChatWithRequest sends a non-streaming request to /chat/completions
+func (c *OpenAIClient) ChatWithRequest(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error) {
+	if request.Model == "" {
+		return nil, errors.NewValidationError("model", "model cannot be empty")
+	}
+	if len(request.Messages) == 0 {
+		return nil, errors.NewValidationError("messages", "messages cannot be empty")
+	}
+	request.Stream = false
+
+	start := time.Now()
+	var completion openai.ChatCompletion
+	if err := c.call(ctx, "/chat/completions", openai.FromOllama(request), &completion); err != nil {
+		return nil, err
+	}
+	if len(completion.Choices) == 0 {
+		return nil, errors.NewNetworkError("incomplete response", fmt.Errorf("no choices in response"))
+	}
+
+	choice := completion.Choices[0]
+	response := &models.ChatResponse{
+		Model:         completion.Model,
+		CreatedAt:     time.Unix(completion.Created, 0),
+		Message:       models.Message{Role: "assistant", Content: choice.Message.Content},
+		Done:          true,
+		DoneReason:    openai.DoneReason(choice.FinishReason),
+		TotalDuration: int64(time.Since(start)),
+	}
+	if completion.Usage != nil {
+		response.PromptEvalCount = completion.Usage.PromptTokens
+		response.EvalCount = completion.Usage.CompletionTokens
+		response.EvalDuration = response.TotalDuration
+	}
+	return response, nil
+}
+
+// ChatStreamWithRequest streams a request to /chat/completions, calling callback for each
+// content delta and once more with Done set when the server ends the stream
+func (c *OpenAIClient) ChatStreamWithRequest(ctx context.Context, request models.ChatRequest, callback func(chunk *models.StreamingChatResponse) error) (*models.ChatResponse, error) {
+	if request.Model == "" {
+		return nil, errors.NewValidationError("model", "model cannot be empty")
+	}
+	if len(request.Messages) == 0 {
+		return nil, errors.NewValidationError("messages", "messages cannot be empty")
+	}
+	request.Stream = true
+
+	jsonData, err := json.Marshal(openai.FromOllama(request))
+	if err != nil {
+		return nil, errors.NewNetworkError("marshal request", err)
+	}
+
+	// Only establishing the stream is retried; once chunks reach the callback a retry would repeat them
+	start := time.Now()
+	var resp *http.Response
+	err = c.RetryPolicy.do(ctx, func() error {
+		req, err := c.newRequest(ctx, "POST", "/chat/completions", jsonData)
+		if err != nil {
+			return err
+		}
+		resp, err = c.Client.Do(req)
+		if err != nil {
+			return errors.NewNetworkError("send request", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return errors.NewAPIError(resp.StatusCode, string(body))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	final := models.ChatResponse{Model: request.Model, CreatedAt: time.Now(), Done: true}
+	var content strings.Builder
+	var finishReason *string
+	finished := false
+
+	// Server-sent events: "data: {json}" lines, ending with "data: [DONE]"
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			finished = true
+			break
+		}
+
+		var chunk openai.ChatCompletion
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			// Skip malformed chunks but don't fail
+			continue
+		}
+		if chunk.Model != "" {
+			final.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			final.PromptEvalCount = chunk.Usage.PromptTokens
+			final.EvalCount = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != nil {
+			finishReason = choice.FinishReason
+			finished = true
+		}
+		if choice.Delta.Content == "" {
+			continue
+		}
+		content.WriteString(choice.Delta.Content)
+		if callback != nil {
+			out := &models.StreamingChatResponse{
+				Model:     final.Model,
+				CreatedAt: time.Now(),
+				Message:   models.Message{Role: "assistant", Content: choice.Delta.Content},
+			}
+			if err := callback(out); err != nil {
+				return nil, fmt.Errorf("callback error: %w", err)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.NewNetworkError("read stream", err)
+	}
+	if !finished {
+		return nil, errors.NewNetworkError("incomplete response", fmt.Errorf("stream ended without a finish reason"))
+	}
+
+	final.Message = models.Message{Role: "assistant", Content: content.String()}
+	final.DoneReason = openai.DoneReason(finishReason)
+	final.TotalDuration = int64(time.Since(start))
+	if final.EvalCount > 0 {
+		final.EvalDuration = final.TotalDuration
+	}
+	if callback != nil {
+		last := &models.StreamingChatResponse{
+			Model:           final.Model,
+			CreatedAt:       time.Now(),
+			Message:         models.Message{Role: "assistant"},
+			Done:            true,
+			DoneReason:      final.DoneReason,
+			TotalDuration:   final.TotalDuration,
+			PromptEvalCount: final.PromptEvalCount,
+			EvalCount:       final.EvalCount,
+			EvalDuration:    final.EvalDuration,
+		}
+		if err := callback(last); err != nil {
+			return nil, fmt.Errorf("callback error: %w", err)
+		}
+	}
+	return &final, nil
+}
+
+// EmbeddingContext embeds text with model through /embeddings
+func (c *OpenAIClient) EmbeddingContext(ctx context.Context, model, text string) (*models.EmbeddingResponse, error) {
+	if text == "" {
+		return nil, errors.NewValidationError("text", "text cannot be empty")
+	}
+	embeddings, err := c.EmbeddingBatchContext(ctx, model, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return &models.EmbeddingResponse{Embedding: embeddings[0]}, nil
+}
+
+// EmbeddingBatchContext embeds every text in one /embeddings request, returning the
+// embeddings in input order
+func (c *OpenAIClient) EmbeddingBatchContext(ctx context.Context, model string, texts []string) ([][]float64, error) {
+	if model == "" {
+		return nil, errors.NewValidationError("model", "model cannot be empty")
+	}
+	if len(texts) == 0 {
+		return nil, errors.NewValidationError("texts", "texts cannot be empty")
+	}
+
+	var response openai.EmbeddingResponse
+	if err := c.call(ctx, "/embeddings", openai.EmbeddingRequest{Model: model, Input: texts}, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Data) != len(texts) {
+		return nil, errors.NewNetworkError("incomplete response", fmt.Errorf("got %d embeddings for %d inputs", len(response.Data), len(texts)))
+	}
+	sort.SliceStable(response.Data, func(i, j int) bool { return response.Data[i].Index < response.Data[j].Index })
+	embeddings := make([][]float64, len(texts))
+	for i, d := range response.Data {
+		embeddings[i] = d.Embedding
+	}
+	return embeddings, nil
+}
+
+// ListModelsContext returns the ids of the models served at /models
+func (c *OpenAIClient) ListModelsContext(ctx context.Context) ([]string, error) {
+	var response openai.ModelList
+	if err := c.call(ctx, "/models", nil, &response); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(response.Data))
+	for i, m := range response.Data {
+		names[i] = m.ID
+	}
+	return names, nil
+}
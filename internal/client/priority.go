@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// Priority orders requests that share a Client
+type Priority int
+
+const (
+	// PriorityInteractive requests are sent at once; it is the default
+	PriorityInteractive Priority = iota
+	// PriorityBatch requests wait while any interactive request is in flight, so bulk
+	// embedding yields the server to a user's query between its requests
+	PriorityBatch
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose requests are scheduled at p
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityOf returns the priority set on ctx, PriorityInteractive if none
+func priorityOf(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// scheduler holds batch requests back while interactive ones are in flight. Requests
+// already sent are not interrupted; a batch request waits at its next call.
+type scheduler struct {
+	mu          sync.Mutex
+	interactive int           // interactive requests in flight
+	idle        chan struct{} // closed when interactive drops to zero; nil when nobody waits
+}
+
+// acquire waits until a request at ctx's priority may be sent and returns the function
+// that marks it finished
+func (s *scheduler) acquire(ctx context.Context) (func(), error) {
+	if priorityOf(ctx) == PriorityInteractive {
+		s.mu.Lock()
+		s.interactive++
+		s.mu.Unlock()
+		return s.releaseInteractive, nil
+	}
+	for {
+		s.mu.Lock()
+		if s.interactive == 0 {
+			s.mu.Unlock()
+			return func() {}, nil
+		}
+		if s.idle == nil {
+			s.idle = make(chan struct{})
+		}
+		idle := s.idle
+		s.mu.Unlock()
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaseInteractive marks an interactive request finished, waking waiting batch requests
+// when it was the last
+func (s *scheduler) releaseInteractive() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interactive--
+	if s.interactive == 0 && s.idle != nil {
+		close(s.idle)
+		s.idle = nil
+	}
+}
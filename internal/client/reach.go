@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultProbeTimeout bounds the reachability check made before commands that need the server
+const DefaultProbeTimeout = 3 * time.Second
+
+// Reachability is what a quick check of a server URL found
+type Reachability struct {
+	URL      string
+	Address  string // host:port dialed
+	Resolved bool   // the host name resolved
+	PortOpen bool   // a TCP connection to Address succeeded
+	Err      error  // why the server is unreachable; nil when it answered HTTP
+}
+
+// OK reports whether the server answered
+func (r Reachability) OK() bool { return r.Err == nil }
+
+// TimedOut reports whether the check gave up waiting rather than being refused
+func (r Reachability) TimedOut() bool {
+	var ne net.Error
+	return errors.As(r.Err, &ne) && ne.Timeout()
+}
+
+// Probe checks whether a server answers at baseURL: it dials the host and port, then sends
+// one GET to the URL. Any HTTP response, even an error status, counts as reachable, so the
+// check works for every provider without knowing its API.
+func Probe(ctx context.Context, baseURL string, timeout time.Duration) Reachability {
+	r := Reachability{URL: baseURL}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		if err == nil {
+			err = errors.New("no host in URL")
+		}
+		r.Err = err
+		return r
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	r.Address = net.JoinHostPort(u.Hostname(), port)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+		r.Err = err
+		return r
+	}
+	r.Resolved = true
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.Address)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	conn.Close()
+	r.PortOpen = true
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	resp.Body.Close()
+	return r
+}
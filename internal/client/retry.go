@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"kirk-ai/internal/errors"
+)
+
+// DefaultRetryBackoff is the delay before the first retry; it doubles on each further attempt
+const DefaultRetryBackoff = 500 * time.Millisecond
+
+// DefaultRetryMaxBackoff caps the doubled delay between retries
+const DefaultRetryMaxBackoff = 30 * time.Second
+
+// DefaultRetryJitter is the fraction of each delay the client constructors add at random, so
+// parallel workers that failed together do not retry in lockstep
+const DefaultRetryJitter = 0.2
+
+// RetryPolicy decides which failed requests are retried and how long to wait between
+// attempts. A request is tried at most Retries+1 times.
+type RetryPolicy struct {
+	// Retries is how many times a request that failed with a transient error is retried
+	Retries int
+	// Backoff is the delay before the first retry (DefaultRetryBackoff when zero)
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries (DefaultRetryMaxBackoff when zero)
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each delay added at random, from 0 to 1
+	Jitter float64
+	// RetryOn lists the HTTP statuses that are retried; nil retries 429 and 5xx. Connection
+	// errors are always retried.
+	RetryOn []int
+}
+
+// DefaultRetryPolicy is the policy of a new client: no retries until Retries is set
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{Backoff: DefaultRetryBackoff, MaxBackoff: DefaultRetryMaxBackoff, Jitter: DefaultRetryJitter}
+}
+
+// Validate checks the jitter fraction and the status list
+func (p RetryPolicy) Validate() error {
+	if p.Retries < 0 {
+		return fmt.Errorf("retries must not be negative, got %d", p.Retries)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %g", p.Jitter)
+	}
+	for _, status := range p.RetryOn {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid HTTP status %d in retry-on list", status)
+		}
+	}
+	return nil
+}
+
+// retryable reports whether err is worth another attempt under p
+func (p RetryPolicy) retryable(err error) bool {
+	if e, ok := errors.AsAPIError(err); ok && p.RetryOn != nil {
+		return slices.Contains(p.RetryOn, e.StatusCode)
+	}
+	return errors.IsRetryable(err)
+}
+
+// delay returns how long to wait before retry number attempt+1
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d, limit := p.Backoff, p.MaxBackoff
+	if d <= 0 {
+		d = DefaultRetryBackoff
+	}
+	if limit <= 0 {
+		limit = DefaultRetryMaxBackoff
+	}
+	for i := 0; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// do runs fn, retrying failures the policy considers transient with exponential backoff
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Retries || !p.retryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(p.delay(attempt)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
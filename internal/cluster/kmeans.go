@@ -0,0 +1,177 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Result is the outcome of clustering a set of vectors
+type Result struct {
+	Centroids   [][]float64
+	Assignments []int // cluster index for each input vector
+	Iterations  int
+}
+
+// KMeans clusters vectors into k groups by cosine similarity (spherical k-means with k-means++
+// seeding). Vectors are normalized internally; the input is not modified.
+func KMeans(vectors [][]float64, k, maxIter int, seed int64) Result {
+	n := len(vectors)
+	if n == 0 || k <= 0 {
+		return Result{}
+	}
+	if k > n {
+		k = n
+	}
+
+	points := make([][]float64, n)
+	for i, v := range vectors {
+		points[i] = normalize(v)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	centroids := seedCentroids(points, k, rng)
+	assignments := make([]int, n)
+	for i := range assignments {
+		assignments[i] = -1
+	}
+
+	iter := 0
+	for ; iter < maxIter; iter++ {
+		changed := false
+		for i, p := range points {
+			best := nearest(p, centroids)
+			if best != assignments[i] {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		// Recompute centroids as normalized means
+		dim := len(points[0])
+		sums := make([][]float64, k)
+		counts := make([]int, k)
+		for c := range sums {
+			sums[c] = make([]float64, dim)
+		}
+		for i, p := range points {
+			c := assignments[i]
+			counts[c]++
+			for d := range p {
+				sums[c][d] += p[d]
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				// Re-seed an empty cluster with a random point
+				centroids[c] = points[rng.Intn(n)]
+				continue
+			}
+			centroids[c] = normalize(sums[c])
+		}
+	}
+
+	return Result{Centroids: centroids, Assignments: assignments, Iterations: iter}
+}
+
+// Representatives returns the indices of up to n members of cluster c closest to its centroid
+func (r Result) Representatives(vectors [][]float64, c, n int) []int {
+	type scored struct {
+		idx int
+		sim float64
+	}
+	var members []scored
+	for i, a := range r.Assignments {
+		if a == c {
+			members = append(members, scored{i, dot(normalize(vectors[i]), r.Centroids[c])})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].sim > members[j].sim })
+	if len(members) > n {
+		members = members[:n]
+	}
+	out := make([]int, len(members))
+	for i, m := range members {
+		out[i] = m.idx
+	}
+	return out
+}
+
+// DefaultK picks a cluster count for n vectors: sqrt(n/2), clamped to [2, 20]
+func DefaultK(n int) int {
+	k := int(math.Sqrt(float64(n) / 2))
+	if k < 2 {
+		k = 2
+	}
+	if k > 20 {
+		k = 20
+	}
+	return k
+}
+
+// seedCentroids chooses initial centroids with k-means++ using cosine distance
+func seedCentroids(points [][]float64, k int, rng *rand.Rand) [][]float64 {
+	centroids := [][]float64{points[rng.Intn(len(points))]}
+	dist := make([]float64, len(points))
+	for len(centroids) < k {
+		var total float64
+		for i, p := range points {
+			d := 1 - dot(p, centroids[nearest(p, centroids)])
+			dist[i] = d * d
+			total += dist[i]
+		}
+		if total == 0 {
+			centroids = append(centroids, points[rng.Intn(len(points))])
+			continue
+		}
+		target := rng.Float64() * total
+		for i, d := range dist {
+			target -= d
+			if target <= 0 {
+				centroids = append(centroids, points[i])
+				break
+			}
+		}
+	}
+	return centroids
+}
+
+func nearest(p []float64, centroids [][]float64) int {
+	best, bestSim := 0, math.Inf(-1)
+	for c, centroid := range centroids {
+		if sim := dot(p, centroid); sim > bestSim {
+			best, bestSim = c, sim
+		}
+	}
+	return best
+}
+
+func dot(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var s float64
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+func normalize(v []float64) []float64 {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	out := make([]float64, len(v))
+	if norm == 0 {
+		return out
+	}
+	norm = math.Sqrt(norm)
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
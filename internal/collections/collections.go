@@ -0,0 +1,157 @@
+// Package collections keeps the registry of named collections: friendly names for an
+// embeddings file, a collection in a store directory, or a Qdrant collection, so commands
+// can take --collection <name> instead of a path
+package collections
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/config"
+	"kirk-ai/internal/vectorstore"
+)
+
+// FileName is the registry file inside the configuration directory
+const FileName = "collections.json"
+
+// Entry is one registered collection. It points at either File or a collection in Store.
+type Entry struct {
+	Name string `json:"name"`
+	// File is an embeddings file or shard manifest, stored as an absolute path
+	File string `json:"file,omitempty"`
+	// Store is a store directory (absolute) or a qdrant:// server
+	Store string `json:"store,omitempty"`
+	// Collection is the collection's name inside Store, when it differs from Name
+	Collection  string    `json:"collection,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Kind describes what the entry points at: file, store, or qdrant
+func (e *Entry) Kind() string {
+	switch {
+	case e.File != "":
+		return "file"
+	case vectorstore.IsQdrant(e.Store):
+		return "qdrant"
+	}
+	return "store"
+}
+
+// StoreCollection returns the collection's name inside Store
+func (e *Entry) StoreCollection() string {
+	if e.Collection != "" {
+		return e.Collection
+	}
+	return e.Name
+}
+
+// Target renders where the entry points
+func (e *Entry) Target() string {
+	if e.File != "" {
+		return e.File
+	}
+	if vectorstore.IsQdrant(e.Store) {
+		return e.Store + "/" + e.StoreCollection()
+	}
+	return filepath.Join(e.Store, e.StoreCollection())
+}
+
+// Registry is the set of registered collections, read from and saved to one file
+type Registry struct {
+	path    string
+	entries map[string]*Entry
+}
+
+// Path returns the registry file in the configuration directory
+func Path() string {
+	return config.Path(FileName)
+}
+
+// Load reads the registry at path; a missing file is an empty registry
+func Load(path string) (*Registry, error) {
+	r := &Registry{path: path, entries: map[string]*Entry{}}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, e := range entries {
+		r.entries[e.Name] = e
+	}
+	return r, nil
+}
+
+// Get returns the entry registered under name
+func (r *Registry) Get(name string) (*Entry, bool) {
+	e, ok := r.entries[name]
+	return e, ok
+}
+
+// Add registers e, making relative paths absolute. A name can be registered only once.
+func (r *Registry) Add(e Entry) (*Entry, error) {
+	if err := vectorstore.ValidateName(e.Name); err != nil {
+		return nil, err
+	}
+	if _, ok := r.entries[e.Name]; ok {
+		return nil, fmt.Errorf("collection %q is already registered (delete it first)", e.Name)
+	}
+	if (e.File == "") == (e.Store == "") {
+		return nil, fmt.Errorf("a collection points at either an embeddings file or a store")
+	}
+	var err error
+	if e.File != "" {
+		if e.File, err = filepath.Abs(e.File); err != nil {
+			return nil, err
+		}
+	} else if !vectorstore.IsQdrant(e.Store) {
+		if e.Store, err = filepath.Abs(e.Store); err != nil {
+			return nil, err
+		}
+	}
+	if e.Collection == e.Name {
+		e.Collection = ""
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	r.entries[e.Name] = &e
+	return &e, nil
+}
+
+// Remove unregisters name, reporting whether it was registered
+func (r *Registry) Remove(name string) bool {
+	_, ok := r.entries[name]
+	delete(r.entries, name)
+	return ok
+}
+
+// List returns the entries sorted by name
+func (r *Registry) List() []*Entry {
+	out := make([]*Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Save atomically writes the registry back to its file
+func (r *Registry) Save() error {
+	b, err := json.MarshalIndent(r.List(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(r.path, append(b, '\n'), 0644, false)
+}
@@ -1,7 +1,19 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+
+	"kirk-ai/internal/atomicfile"
+
+	"gopkg.in/yaml.v3"
 )
 
 // ModelCapability represents what a model is good at
@@ -24,8 +36,129 @@ type ModelConfig struct {
 	Description  string
 }
 
-// GetModelConfigs returns predefined model configurations
+// ModelsFile lets users register their own models and preferences in the configuration
+// directory; its entries are laid over the built-in ones
+const ModelsFile = "models.yaml"
+
+// modelsFile is the layout of ModelsFile
+type modelsFile struct {
+	// Models add models, or change built-in ones of the same name; a field left out keeps
+	// the built-in value
+	Models []struct {
+		Name         string            `yaml:"name"`
+		Capabilities []ModelCapability `yaml:"capabilities"`
+		Priority     *int              `yaml:"priority"`
+		Description  string            `yaml:"description"`
+	} `yaml:"models"`
+	// Prefer lists, by capability ("rag" included), the models to pick first when installed,
+	// ahead of any priority
+	Prefer map[ModelCapability][]string `yaml:"prefer"`
+}
+
+var (
+	modelsOnce    sync.Once
+	modelConfigs  map[string]ModelConfig
+	modelPrefer   map[ModelCapability][]string
+	modelsFileErr error
+)
+
+// LoadModelConfigs reads ModelsFile, reporting whether it is malformed. The model functions
+// read it on first use anyway, falling back to the built-in models when it is.
+func LoadModelConfigs() error {
+	modelsOnce.Do(func() {
+		modelConfigs, modelPrefer, modelsFileErr = readModelsFile(Path(ModelsFile))
+		if modelsFileErr != nil {
+			modelConfigs, modelPrefer = builtinModelConfigs(), nil
+		}
+	})
+	return modelsFileErr
+}
+
+// readModelsFile returns the built-in models with the file's laid over them; a missing file
+// yields the built-ins
+func readModelsFile(path string) (map[string]ModelConfig, map[ModelCapability][]string, error) {
+	configs := builtinModelConfigs()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return configs, nil, nil
+		}
+		return nil, nil, err
+	}
+	var file modelsFile
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, m := range file.Models {
+		if m.Name == "" {
+			return nil, nil, fmt.Errorf("%s: model %d has no name", path, i+1)
+		}
+		for _, c := range m.Capabilities {
+			if !knownCapability(c) {
+				return nil, nil, fmt.Errorf("%s: model %s: unknown capability %q", path, m.Name, c)
+			}
+		}
+		config, ok := configs[m.Name]
+		if !ok {
+			config = ModelConfig{Name: m.Name, Priority: 50}
+		}
+		if len(m.Capabilities) > 0 {
+			config.Capabilities = m.Capabilities
+		}
+		if m.Priority != nil {
+			config.Priority = *m.Priority
+		}
+		if m.Description != "" {
+			config.Description = m.Description
+		}
+		configs[m.Name] = config
+	}
+	for c := range file.Prefer {
+		if c != "rag" && !knownCapability(c) {
+			return nil, nil, fmt.Errorf("%s: prefer: unknown capability %q", path, c)
+		}
+	}
+	return configs, file.Prefer, nil
+}
+
+// knownCapability reports whether c is one of the capabilities above
+func knownCapability(c ModelCapability) bool {
+	switch c {
+	case CapabilityChat, CapabilityCode, CapabilityEmbedding, CapabilityReasoning, CapabilityTranslation, CapabilityCreative:
+		return true
+	}
+	return false
+}
+
+// GetModelConfigs returns the model configurations: the built-in ones with the user's
+// ModelsFile laid over them
 func GetModelConfigs() map[string]ModelConfig {
+	LoadModelConfigs()
+	configs := make(map[string]ModelConfig, len(modelConfigs))
+	for name, config := range modelConfigs {
+		configs[name] = config
+	}
+	return configs
+}
+
+// PreferredModel returns the first model the user prefers for capability that is available,
+// matching names exactly or without their ":latest" tag; "" when none is
+func PreferredModel(availableModels []string, capability ModelCapability) string {
+	LoadModelConfigs()
+	for _, want := range modelPrefer[capability] {
+		for _, name := range availableModels {
+			if name == want || name == want+":latest" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// builtinModelConfigs returns the predefined model configurations
+func builtinModelConfigs() map[string]ModelConfig {
 	return map[string]ModelConfig{
 		"gemma3:4b": {
 			Name:         "gemma3:4b",
@@ -56,6 +189,9 @@ func GetModelConfigs() map[string]ModelConfig {
 
 // SelectBestModel selects the best available model for a given capability
 func SelectBestModel(availableModels []string, capability ModelCapability) string {
+	if preferred := PreferredModel(availableModels, capability); preferred != "" {
+		return preferred
+	}
 	configs := GetModelConfigs()
 	bestModel := ""
 	bestPriority := -1
@@ -110,6 +246,18 @@ func SelectBestModel(availableModels []string, capability ModelCapability) strin
 	return bestModel
 }
 
+// RecommendedModel returns the configured model with the highest priority for capability,
+// the one to install when none is; "" when none is configured
+func RecommendedModel(capability ModelCapability) string {
+	best := ModelConfig{Priority: -1}
+	for _, config := range GetModelConfigs() {
+		if hasCapability(config.Capabilities, capability) && config.Priority > best.Priority {
+			best = config
+		}
+	}
+	return best.Name
+}
+
 // hasCapability checks if a model has a specific capability
 func hasCapability(capabilities []ModelCapability, target ModelCapability) bool {
 	for _, cap := range capabilities {
@@ -138,3 +286,173 @@ func GetModelInfo(modelName string) (ModelConfig, bool) {
 
 	return ModelConfig{}, false
 }
+
+// ModelFacts is what the server reports about an installed model, from Ollama's /api/show
+type ModelFacts struct {
+	Name          string   `json:"name"`
+	Digest        string   `json:"digest,omitempty"`
+	Family        string   `json:"family,omitempty"`         // e.g. "llama", "gemma3", "nomic-bert"
+	ParameterSize string   `json:"parameter_size,omitempty"` // e.g. "8.0B", "567M"
+	Quantization  string   `json:"quantization,omitempty"`
+	ContextLength int      `json:"context_length,omitempty"` // tokens the model was trained with
+	Capabilities  []string `json:"capabilities,omitempty"`   // e.g. "completion", "embedding", "tools"
+	// EmbeddingLength is the width of the model's hidden state: the dimension of the vectors
+	// an embedding model returns
+	EmbeddingLength int `json:"embedding_length,omitempty"`
+	// Pooling is set when the weights pool token states into one vector, as only embedding
+	// models' do
+	Pooling bool `json:"pooling,omitempty"`
+	// Inferred caches InferCapabilities for the facts above
+	Inferred []ModelCapability `json:"inferred,omitempty"`
+}
+
+// Billions returns the parameter count in billions from ParameterSize, or 0 when unknown
+func (f ModelFacts) Billions() float64 {
+	s := strings.ToUpper(strings.TrimSpace(f.ParameterSize))
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "B"):
+		s = strings.TrimSuffix(s, "B")
+	case strings.HasSuffix(s, "M"):
+		s, scale = strings.TrimSuffix(s, "M"), 1e-3
+	case strings.HasSuffix(s, "K"):
+		s, scale = strings.TrimSuffix(s, "K"), 1e-6
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return n * scale
+}
+
+// has reports whether the server lists capability for the model
+func (f ModelFacts) has(capability string) bool {
+	for _, c := range f.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// InferCapabilities returns what a model suits from what the server reports about it: an
+// embedding model suits only embeddings; a completion model suits chat, creative writing,
+// and translation, code when it can fill in the middle or is a code model, and reasoning
+// when it thinks or has 7B parameters or more. Servers that report no capabilities are
+// judged by the weights' pooling, family, and name.
+func InferCapabilities(f ModelFacts) []ModelCapability {
+	name := strings.ToLower(f.Name)
+	family := strings.ToLower(f.Family)
+	switch {
+	case f.has("embedding"),
+		len(f.Capabilities) == 0 && (f.Pooling || strings.Contains(family, "bert") || strings.Contains(name, "embed")):
+		return []ModelCapability{CapabilityEmbedding}
+	case f.has("completion") || len(f.Capabilities) == 0:
+		caps := []ModelCapability{CapabilityChat, CapabilityCreative, CapabilityTranslation}
+		if f.has("insert") || strings.Contains(name, "code") || strings.Contains(family, "code") {
+			caps = append(caps, CapabilityCode)
+		}
+		if f.has("thinking") || f.Billions() >= 7 {
+			caps = append(caps, CapabilityReasoning)
+		}
+		return caps
+	}
+	return nil
+}
+
+// CapabilitiesOf returns what a model suits: the capabilities inferred from the facts, with
+// those configured for the model added
+func CapabilitiesOf(f ModelFacts) []ModelCapability {
+	caps := append([]ModelCapability(nil), f.Inferred...)
+	if caps == nil {
+		caps = InferCapabilities(f)
+	}
+	if config, ok := GetModelInfo(f.Name); ok && len(caps) > 0 && !hasCapability(caps, CapabilityEmbedding) {
+		for _, c := range config.Capabilities {
+			if !hasCapability(caps, c) {
+				caps = append(caps, c)
+			}
+		}
+	}
+	return caps
+}
+
+// SelectBestModelByFacts selects the best model for capability among the reported ones.
+// Configured models rank by their priority; the others rank below them, larger models
+// first up to 10B parameters, and in listing order after that. It returns "" when no
+// model has the capability.
+func SelectBestModelByFacts(facts []ModelFacts, capability ModelCapability) string {
+	var names []string
+	for _, f := range facts {
+		names = append(names, f.Name)
+	}
+	if preferred := PreferredModel(names, capability); preferred != "" {
+		return preferred
+	}
+	bestModel := ""
+	bestScore := -1.0
+	for _, f := range facts {
+		if !hasCapability(CapabilitiesOf(f), capability) {
+			continue
+		}
+		score := 50 + math.Min(f.Billions(), 10)
+		if config, ok := GetModelInfo(f.Name); ok && hasCapability(config.Capabilities, capability) {
+			score = float64(config.Priority)
+		}
+		if score > bestScore {
+			bestModel, bestScore = f.Name, score
+		}
+	}
+	return bestModel
+}
+
+// SelectSmallestModel selects the model with the fewest parameters that has capability,
+// for tasks that favor speed over depth; models of unknown size come last. It returns ""
+// when no model has the capability.
+func SelectSmallestModel(facts []ModelFacts, capability ModelCapability) string {
+	bestModel := ""
+	bestSize := math.Inf(1)
+	for _, f := range facts {
+		if !hasCapability(CapabilitiesOf(f), capability) {
+			continue
+		}
+		size := f.Billions()
+		if size == 0 {
+			size = math.MaxFloat64
+		}
+		if bestModel == "" || size < bestSize {
+			bestModel, bestSize = f.Name, size
+		}
+	}
+	return bestModel
+}
+
+// ModelFactsFile caches the servers' model facts in the configuration directory, so model
+// selection asks about each model once per pull rather than on every command
+const ModelFactsFile = "model-facts.json"
+
+// LoadModelFacts returns the cached model facts by model name; a missing or unreadable
+// cache yields none
+func LoadModelFacts() map[string]ModelFacts {
+	facts := make(map[string]ModelFacts)
+	b, err := os.ReadFile(Path(ModelFactsFile))
+	if err != nil {
+		return facts
+	}
+	if err := json.Unmarshal(b, &facts); err != nil {
+		return make(map[string]ModelFacts)
+	}
+	return facts
+}
+
+// SaveModelFacts replaces the cached model facts
+func SaveModelFacts(facts map[string]ModelFacts) error {
+	b, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir(), 0o755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(Path(ModelFactsFile), b, 0o644, false)
+}
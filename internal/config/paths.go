@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// HomeEnvVar overrides the kirk-ai configuration directory
+const HomeEnvVar = "KIRK_AI_HOME"
+
+// Dir returns the kirk-ai configuration directory: $KIRK_AI_HOME, or ~/.kirk-ai
+func Dir() string {
+	if dir := os.Getenv(HomeEnvVar); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".kirk-ai"
+	}
+	return filepath.Join(home, ".kirk-ai")
+}
+
+// Path returns a path inside the configuration directory
+func Path(elem ...string) string {
+	return filepath.Join(append([]string{Dir()}, elem...)...)
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"kirk-ai/internal/models"
+)
+
+// SettingsFile is the user settings file inside the configuration directory
+const SettingsFile = "config.json"
+
+// CommandSettings holds request timeout, retry, system prompt, and generation settings. Unset fields
+// inherit from the top-level defaults in the settings file, then from the built-in defaults.
+type CommandSettings struct {
+	Timeout         string   `json:"timeout,omitempty"` // Go duration, e.g. "90s" or "5m"
+	Retries         *int     `json:"retries,omitempty"`
+	RetryBackoff    string   `json:"retry_backoff,omitempty"`     // delay before the first retry, doubled after each
+	RetryMaxBackoff string   `json:"retry_max_backoff,omitempty"` // cap on the delay between retries
+	RetryJitter     *float64 `json:"retry_jitter,omitempty"`      // fraction of each delay added at random
+	RetryOn         []int    `json:"retry_on,omitempty"`          // HTTP statuses to retry instead of 429 and 5xx
+	System          string   `json:"system,omitempty"`            // system prompt sent with every chat request
+	KeepAlive       string   `json:"keep_alive,omitempty"`        // how long Ollama keeps models loaded, e.g. "30m" or "-1"
+	// Options are generation options for chat requests, e.g. {"temperature": 0.2, "num_ctx": 8192}
+	Options *models.Options `json:"options,omitempty"`
+}
+
+// Settings is the contents of ~/.kirk-ai/config.json, e.g.
+//
+//	{"timeout": "2m", "retries": 1, "system": "Answer concisely.", "options": {"num_ctx": 8192},
+//	 "commands": {"embed": {"timeout": "30s", "retries": 3, "retry_on": [500, 503]}, "code": {"system": "You write idiomatic Go.", "options": {"temperature": 0.2}}}}
+type Settings struct {
+	CommandSettings
+	Commands map[string]CommandSettings `json:"commands,omitempty"`
+	// Sources weights retrieval scores by source, e.g.
+	// "sources": [{"match": "tpusa.com", "weight": 1.2}, {"match": "*/comments/*", "weight": 0.5}]
+	Sources []SourceWeight `json:"sources,omitempty"`
+	// Redact chooses what --redact masks, e.g.
+	// "redact": {"builtin": ["email", "phone"], "patterns": [{"name": "member_id", "pattern": "TP-[0-9]{6}"}]}
+	Redact RedactSettings `json:"redact"`
+}
+
+// SourceWeight multiplies the retrieval score of chunks whose source URL matches. Match is a
+// domain, covering its subdomains, or a URL pattern where '*' matches anything. Rules are
+// tried in order and the first match applies.
+type SourceWeight struct {
+	Match  string  `json:"match"`
+	Weight float64 `json:"weight"`
+}
+
+// RedactSettings lists the built-in patterns to mask, all of them when Builtin is empty, and
+// patterns of its own
+type RedactSettings struct {
+	Builtin  []string        `json:"builtin,omitempty"`
+	Patterns []RedactPattern `json:"patterns,omitempty"`
+}
+
+// RedactPattern is a regular expression whose matches are masked as [REDACTED:name]
+type RedactPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// LoadSettings reads the settings file; a missing file yields empty settings
+func LoadSettings() (*Settings, error) {
+	path := Path(SettingsFile)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Settings{}, nil
+		}
+		return nil, err
+	}
+	var s Settings
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// For returns the timeout and retry count for command, or zero values when neither the
+// command nor the top-level defaults set them
+func (s *Settings) For(command string) (timeout time.Duration, retries int, err error) {
+	merged := s.CommandSettings
+	if c, ok := s.Commands[command]; ok {
+		if c.Timeout != "" {
+			merged.Timeout = c.Timeout
+		}
+		if c.Retries != nil {
+			merged.Retries = c.Retries
+		}
+	}
+	if merged.Timeout != "" {
+		timeout, err = time.ParseDuration(merged.Timeout)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s: invalid timeout for %s: %w", SettingsFile, command, err)
+		}
+	}
+	if merged.Retries != nil {
+		retries = *merged.Retries
+	}
+	return timeout, retries, nil
+}
+
+// Retry holds a command's backoff settings; zero durations and nil fields are unset
+type Retry struct {
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Jitter     *float64
+	On         []int
+}
+
+// RetryFor returns the backoff settings for command, falling back to the top-level defaults
+func (s *Settings) RetryFor(command string) (Retry, error) {
+	merged := s.CommandSettings
+	if c, ok := s.Commands[command]; ok {
+		if c.RetryBackoff != "" {
+			merged.RetryBackoff = c.RetryBackoff
+		}
+		if c.RetryMaxBackoff != "" {
+			merged.RetryMaxBackoff = c.RetryMaxBackoff
+		}
+		if c.RetryJitter != nil {
+			merged.RetryJitter = c.RetryJitter
+		}
+		if c.RetryOn != nil {
+			merged.RetryOn = c.RetryOn
+		}
+	}
+	r := Retry{Jitter: merged.RetryJitter, On: merged.RetryOn}
+	var err error
+	if merged.RetryBackoff != "" {
+		if r.Backoff, err = time.ParseDuration(merged.RetryBackoff); err != nil {
+			return Retry{}, fmt.Errorf("%s: invalid retry_backoff for %s: %w", SettingsFile, command, err)
+		}
+	}
+	if merged.RetryMaxBackoff != "" {
+		if r.MaxBackoff, err = time.ParseDuration(merged.RetryMaxBackoff); err != nil {
+			return Retry{}, fmt.Errorf("%s: invalid retry_max_backoff for %s: %w", SettingsFile, command, err)
+		}
+	}
+	return r, nil
+}
+
+// SystemFor returns the system prompt for command, or "" when neither the command nor the
+// top-level defaults set one
+func (s *Settings) SystemFor(command string) string {
+	if c, ok := s.Commands[command]; ok && c.System != "" {
+		return c.System
+	}
+	return s.System
+}
+
+// OptionsFor returns the generation options for command, each option set by the command
+// or else by the top-level defaults; nil when none is set
+func (s *Settings) OptionsFor(command string) (*models.Options, error) {
+	opts := s.Commands[command].Options.WithDefaults(s.Options)
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: invalid options for %s: %w", SettingsFile, command, err)
+	}
+	return opts, nil
+}
+
+// KeepAliveFor returns how long models stay loaded after command's requests, or "" when
+// neither the command nor the top-level defaults set it
+func (s *Settings) KeepAliveFor(command string) string {
+	if c, ok := s.Commands[command]; ok && c.KeepAlive != "" {
+		return c.KeepAlive
+	}
+	return s.KeepAlive
+}
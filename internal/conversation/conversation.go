@@ -0,0 +1,130 @@
+// Package conversation keeps multi-turn chat history within a token budget by folding
+// older turns into a rolling summary instead of dropping them.
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/secure"
+)
+
+// DefaultBudget is the token budget for history when none is configured
+const DefaultBudget = 4096
+
+// DefaultKeepRecent is how many of the most recent messages are never summarized
+const DefaultKeepRecent = 4
+
+// Summarizer turns a prompt into a summary, typically by calling a chat model
+type Summarizer func(ctx context.Context, prompt string) (string, error)
+
+// Conversation is a chat history with a rolling summary of the turns that no longer fit
+type Conversation struct {
+	Summary         string           `json:"summary,omitempty"`
+	SummarizedTurns int              `json:"summarized_turns,omitempty"` // messages folded into Summary so far
+	Messages        []models.Message `json:"messages"`
+}
+
+// Load reads a conversation saved with Save, decrypting it when it was saved encrypted; a
+// missing file starts a new conversation
+func Load(path string) (*Conversation, error) {
+	b, err := secure.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Conversation{}, nil
+		}
+		return nil, err
+	}
+	var c Conversation
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse session %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save atomically writes the conversation to path, readable only by its owner since chat
+// history may be private, and encrypted when encrypt is true
+func (c *Conversation) Save(path string, encrypt bool) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return secure.WriteFile(path, b, 0o600, encrypt)
+}
+
+// Add appends a message to the history
+func (c *Conversation) Add(role, content string) {
+	c.Messages = append(c.Messages, models.Message{Role: role, Content: content})
+}
+
+// Tokens estimates the size of the history as sent to the model, summary included
+func (c *Conversation) Tokens() int {
+	n := chunker.EstimateTokens(c.Summary)
+	for _, m := range c.Messages {
+		n += chunker.EstimateTokens(m.Content)
+	}
+	return n
+}
+
+// ChatMessages returns the messages to send to the model: the rolling summary as a system
+// message, followed by the unsummarized turns
+func (c *Conversation) ChatMessages() []models.Message {
+	out := make([]models.Message, 0, len(c.Messages)+1)
+	if c.Summary != "" {
+		out = append(out, models.Message{
+			Role:    "system",
+			Content: "Summary of the earlier conversation:\n" + c.Summary,
+		})
+	}
+	return append(out, c.Messages...)
+}
+
+// Compact folds the oldest messages into the rolling summary while the history exceeds
+// budget tokens, always keeping the keepRecent most recent messages verbatim. It reports
+// whether anything was summarized.
+func (c *Conversation) Compact(ctx context.Context, budget, keepRecent int, summarize Summarizer) (bool, error) {
+	if budget <= 0 || c.Tokens() <= budget {
+		return false, nil
+	}
+	if keepRecent < 1 {
+		keepRecent = 1
+	}
+	cut := len(c.Messages) - keepRecent
+	// Start the kept tail on a user message so a question is never separated from its answer
+	for cut > 0 && c.Messages[cut].Role != "user" {
+		cut--
+	}
+	if cut <= 0 {
+		return false, nil
+	}
+
+	summary, err := summarize(ctx, summaryPrompt(c.Summary, c.Messages[:cut]))
+	if err != nil {
+		return false, fmt.Errorf("summarize conversation: %w", err)
+	}
+	c.Summary = strings.TrimSpace(summary)
+	c.SummarizedTurns += cut
+	c.Messages = append([]models.Message(nil), c.Messages[cut:]...)
+	return true, nil
+}
+
+// summaryPrompt asks for an updated summary covering the previous summary and the older turns
+func summaryPrompt(previous string, turns []models.Message) string {
+	var sb strings.Builder
+	sb.WriteString("Summarize the conversation below so it can replace the original messages as context ")
+	sb.WriteString("for continuing it. Keep facts, names, numbers, decisions, open questions, and the user's goals. ")
+	sb.WriteString("Write a concise third-person summary and nothing else.\n\n")
+	if previous != "" {
+		fmt.Fprintf(&sb, "Summary so far:\n%s\n\n", previous)
+	}
+	sb.WriteString("Conversation:\n")
+	for _, m := range turns {
+		fmt.Fprintf(&sb, "%s: %s\n\n", m.Role, m.Content)
+	}
+	return sb.String()
+}
@@ -0,0 +1,231 @@
+// Package corpusdiff compares the processed pages of two crawl runs: which pages were added
+// or removed, and for pages whose text changed, which of their chunks differ
+package corpusdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/secure"
+	"kirk-ai/internal/workspace"
+)
+
+// ProcessedFile is where the content processor writes a crawl's pages, relative to the
+// crawl directory
+const ProcessedFile = workspace.ProcessedPages
+
+// Page is one processed page of a crawl run
+type Page struct {
+	Key     string `json:"key"`
+	URL     string `json:"url,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Words   int    `json:"words"`
+	content string
+}
+
+// Run is the processed output of one crawl, keyed by page
+type Run struct {
+	Path  string
+	Pages map[string]*Page
+}
+
+// Load reads the processed pages of a crawl run. path is a processed_pages.json file, or a
+// crawl directory holding processed_data/processed_pages.json or processed_pages.json.
+func Load(path string) (*Run, error) {
+	file := path
+	if fi, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if fi.IsDir() {
+		file = filepath.Join(path, ProcessedFile)
+		if _, err := os.Stat(file); err != nil {
+			file = filepath.Join(path, filepath.Base(ProcessedFile))
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = secure.Open(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+
+	run := &Run{Path: file, Pages: make(map[string]*Page, len(records))}
+	for i, rec := range records {
+		p := &Page{Key: pageKey(rec, i)}
+		p.URL, _ = rec["url"].(string)
+		p.Title, _ = rec["title"].(string)
+		p.content, _ = rec["content"].(string)
+		p.Words = len(strings.Fields(p.content))
+		run.Pages[p.Key] = p
+	}
+	return run, nil
+}
+
+// pageKey identifies a page across runs the way embedprep builds chunk IDs: its URL, with
+// the part number of pages the crawler split, or else its snapshot file name
+func pageKey(rec map[string]interface{}, index int) string {
+	key, _ := rec["url"].(string)
+	if key == "" {
+		key, _ = rec["file"].(string)
+	}
+	if key == "" {
+		key = fmt.Sprintf("page_%d", index)
+	}
+	if part, ok := rec["part"].(float64); ok && part > 0 {
+		key = fmt.Sprintf("%s#part_%d", key, int(part))
+	}
+	return key
+}
+
+// ChunkEdit is one chunk whose text differs between the runs. Old is empty for a chunk only
+// in the new page and New for a chunk only in the old one.
+type ChunkEdit struct {
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// PageChange is a page whose text changed between the runs
+type PageChange struct {
+	Key       string      `json:"key"`
+	URL       string      `json:"url,omitempty"`
+	Title     string      `json:"title,omitempty"`
+	OldWords  int         `json:"old_words"`
+	NewWords  int         `json:"new_words"`
+	OldChunks int         `json:"old_chunks"`
+	NewChunks int         `json:"new_chunks"`
+	Kept      int         `json:"kept_chunks"`
+	Edits     []ChunkEdit `json:"edits"`
+}
+
+// Diff is the difference going from one run to another
+type Diff struct {
+	OldPages  int          `json:"old_pages"`
+	NewPages  int          `json:"new_pages"`
+	Added     []*Page      `json:"added"`
+	Removed   []*Page      `json:"removed"`
+	Changed   []PageChange `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// Compare returns the differences going from run a to run b. Changed pages are chunked with
+// maxTokens, as embedprep would, and compared chunk by chunk.
+func Compare(a, b *Run, maxTokens int) Diff {
+	d := Diff{OldPages: len(a.Pages), NewPages: len(b.Pages)}
+	for key, np := range b.Pages {
+		op, ok := a.Pages[key]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, np)
+		case op.content == np.content:
+			d.Unchanged++
+		default:
+			d.Changed = append(d.Changed, comparePage(op, np, maxTokens))
+		}
+	}
+	for key, op := range a.Pages {
+		if _, ok := b.Pages[key]; !ok {
+			d.Removed = append(d.Removed, op)
+		}
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Key < d.Added[j].Key })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Key < d.Removed[j].Key })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Key < d.Changed[j].Key })
+	return d
+}
+
+// comparePage matches the chunks of two versions of a page by their text. Chunks found in
+// only one version are paired up in page order, so an edited chunk shows as one edit.
+func comparePage(op, np *Page, maxTokens int) PageChange {
+	oldChunks := chunker.Chunk(op.content, maxTokens)
+	newChunks := chunker.Chunk(np.content, maxTokens)
+	c := PageChange{
+		Key: np.Key, URL: np.URL, Title: np.Title,
+		OldWords: op.Words, NewWords: np.Words,
+		OldChunks: len(oldChunks), NewChunks: len(newChunks),
+	}
+
+	remaining := map[string]int{}
+	for _, t := range oldChunks {
+		remaining[t]++
+	}
+	var added []string
+	for _, t := range newChunks {
+		if remaining[t] > 0 {
+			remaining[t]--
+			c.Kept++
+		} else {
+			added = append(added, t)
+		}
+	}
+	var removed []string
+	for _, t := range oldChunks {
+		if remaining[t] > 0 {
+			remaining[t]--
+			removed = append(removed, t)
+		}
+	}
+
+	for i := 0; i < len(removed) || i < len(added); i++ {
+		var e ChunkEdit
+		if i < len(removed) {
+			e.Old = removed[i]
+		}
+		if i < len(added) {
+			e.New = added[i]
+		}
+		c.Edits = append(c.Edits, e)
+	}
+	return c
+}
+
+// Summarize shortens an edit to the words that differ, with a few words of context on
+// either side and "…" where text was left out. Each side is cut to at most width runes.
+func (e ChunkEdit) Summarize(width int) (oldText, newText string) {
+	ow, nw := strings.Fields(e.Old), strings.Fields(e.New)
+	prefix := 0
+	for prefix < len(ow) && prefix < len(nw) && ow[prefix] == nw[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ow)-prefix && suffix < len(nw)-prefix && ow[len(ow)-1-suffix] == nw[len(nw)-1-suffix] {
+		suffix++
+	}
+	const context = 3
+	start := prefix - context
+	if start < 0 || e.Old == "" || e.New == "" {
+		start = 0
+	}
+	trail := suffix - context
+	if trail < 0 || e.Old == "" || e.New == "" {
+		trail = 0
+	}
+	return excerpt(ow, start, len(ow)-trail, width), excerpt(nw, start, len(nw)-trail, width)
+}
+
+// excerpt joins words[start:end], marking cut text with "…"
+func excerpt(words []string, start, end, width int) string {
+	if len(words) == 0 {
+		return ""
+	}
+	s := strings.Join(words[start:end], " ")
+	if r := []rune(s); width > 0 && len(r) > width {
+		s = strings.TrimSpace(string(r[:width])) + " …"
+		end = len(words)
+	}
+	if start > 0 {
+		s = "… " + s
+	}
+	if end < len(words) && !strings.HasSuffix(s, "…") {
+		s += " …"
+	}
+	return s
+}
@@ -0,0 +1,111 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/spf13/pflag"
+)
+
+func apiCollector(fs *pflag.FlagSet) func(verbose bool) {
+	var crawlConfigPath string
+	fs.StringVar(&crawlConfigPath, "crawl-config", defaultCrawlConfigPath, "crawl config with the operator contact details (JSON, or YAML for .yaml and .yml files)")
+	return func(bool) {
+		useWorkspaceRobots()
+		cfg, err := loadCrawlConfig(crawlConfigPath)
+		if err != nil {
+			log.Fatalf("api: %v", err)
+		}
+		setIdentity(cfg)
+
+		ensureDir(workspace.Path(workspace.RawHTML))
+		endpoints := []string{
+			"https://tpusa.com/wp-json/wp/v2/posts",
+			"https://tpusa.com/wp-json/wp/v2/pages",
+			"https://tpusa.com/feed/",
+			"https://tpusa.com/sitemap.xml",
+			"https://tpusa.com/robots.txt",
+		}
+
+		client := &http.Client{}
+		available := []map[string]interface{}{}
+		for _, ep := range endpoints {
+			req, err := http.NewRequest(http.MethodHead, ep, nil)
+			if err != nil {
+				fmt.Println("✗ Error accessing:", ep)
+				continue
+			}
+			crawlerIdentity.apply(req.Header)
+			resp, err := client.Do(req)
+			if err != nil {
+				fmt.Println("✗ Error accessing:", ep)
+				continue
+			}
+			if resp.StatusCode == http.StatusOK {
+				available = append(available, map[string]interface{}{
+					"url":          ep,
+					"content_type": resp.Header.Get("Content-Type"),
+					"size":         resp.ContentLength,
+				})
+				fmt.Println("✓ Available:", ep)
+			} else {
+				fmt.Println("✗ Not available:", ep)
+			}
+		}
+
+		if len(available) > 0 {
+			b, _ := json.MarshalIndent(available, "", "  ")
+			if err := atomicfile.WriteFile(workspace.Path(workspace.APIEndpoints), b, 0o644, true); err != nil {
+				log.Printf("write api endpoints: %v", err)
+			}
+		}
+
+		// Parse RSS feed with gofeed
+		feed, err := fetchFeed(client, "https://tpusa.com/feed/")
+		if err == nil && feed != nil {
+			b, _ := json.MarshalIndent(feed.Items, "", "  ")
+			if err := atomicfile.WriteFile(workspace.Path(workspace.FeedItems), b, 0o644, true); err != nil {
+				log.Printf("write feed items: %v", err)
+			}
+			log.Printf("saved %d feed items", len(feed.Items))
+
+			// Record feed item links in the frontier so crawlers can pick them up with --urls
+			frontier, err := openFrontier(workspace.Path(workspace.Frontier))
+			if err != nil {
+				log.Printf("could not open frontier: %v", err)
+			} else {
+				for _, item := range feed.Items {
+					frontier.Record(urlutil.Normalize(item.Link), 1, "https://tpusa.com/feed/", discoveredFeed)
+				}
+				frontier.Close()
+			}
+		} else if err != nil {
+			log.Printf("could not parse feed: %v", err)
+		}
+	}
+}
+
+// fetchFeed downloads and parses an RSS or Atom feed, identifying as the crawler
+func fetchFeed(client *http.Client, u string) (*gofeed.Feed, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	crawlerIdentity.apply(req.Header)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return gofeed.NewParser().Parse(resp.Body)
+}
@@ -0,0 +1,168 @@
+package crawl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/urlutil"
+)
+
+// rawArchive saves the HTML of each fetched page to a snapshot directory, named through its
+// URL mapping as colly and chromedp do, so process content can extract it again later
+// without fetching it
+type rawArchive struct {
+	dir    string
+	urlMap *urlutil.Mapping
+}
+
+// openRawArchive opens the snapshot directory dir; an empty dir returns nil, which saves
+// nothing
+func openRawArchive(dir string) (*rawArchive, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	ensureDir(dir)
+	urlMap, err := urlutil.OpenMapping(filepath.Join(dir, urlutil.MappingFile))
+	if err != nil {
+		return nil, err
+	}
+	return &rawArchive{dir: dir, urlMap: urlMap}, nil
+}
+
+// Save writes the HTML of the page fetched from u
+func (a *rawArchive) Save(u string, page *fetchedPage) error {
+	if a == nil {
+		return nil
+	}
+	fname, err := a.urlMap.Add(u, ".html")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(a.dir, fname), page.body, 0o644, false)
+}
+
+// Close closes the URL mapping
+func (a *rawArchive) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.urlMap.Close()
+}
+
+// warcWriter appends each fetched page to a WARC file as a response record holding the
+// HTTP status line, headers, and body as received, for tools that read web archives. A
+// path ending in .gz is written compressed, one gzip member per record.
+type warcWriter struct {
+	mu   sync.Mutex
+	f    *os.File
+	gzip bool
+}
+
+// openWARC opens path for appending and writes a warcinfo record for the run; an empty path
+// returns nil, which records nothing
+func openWARC(path, runID string) (*warcWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	ensureDir(filepath.Dir(path))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := &warcWriter{f: f, gzip: strings.HasSuffix(path, ".gz")}
+	info := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.0\r\nrun-id: %s\r\n", crawlerIdentity.userAgent, runID)
+	if err := w.record("warcinfo", "", "application/warc-fields", time.Now(), []byte(info), ""); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends a response record for the page fetched from u
+func (w *warcWriter) Write(u string, page *fetchedPage) error {
+	if w == nil {
+		return nil
+	}
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "%s %s\r\n", page.resp.Proto, page.resp.Status)
+	// The client decodes compressed bodies, so the headers describing the encoding no longer apply
+	header := page.resp.Header.Clone()
+	if page.resp.Uncompressed {
+		header.Del("Content-Encoding")
+	}
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", fmt.Sprint(len(page.body)))
+	header.Write(&block)
+	block.WriteString("\r\n")
+	block.Write(page.body)
+	return w.record("response", u, "application/http; msgtype=response", page.at, block.Bytes(), sha1Digest(page.body))
+}
+
+// record writes one WARC record
+func (w *warcWriter) record(kind, target, contentType string, at time.Time, block []byte, payloadDigest string) error {
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "WARC/1.0\r\nWARC-Type: %s\r\nWARC-Record-ID: <urn:uuid:%s>\r\nWARC-Date: %s\r\n",
+		kind, newUUID(), at.UTC().Format(time.RFC3339))
+	if target != "" {
+		fmt.Fprintf(&head, "WARC-Target-URI: %s\r\n", target)
+	}
+	if payloadDigest != "" {
+		fmt.Fprintf(&head, "WARC-Payload-Digest: %s\r\n", payloadDigest)
+	}
+	fmt.Fprintf(&head, "WARC-Block-Digest: %s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n",
+		sha1Digest(block), contentType, len(block))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out io.Writer = w.f
+	var gz *gzip.Writer
+	if w.gzip {
+		gz = gzip.NewWriter(w.f)
+		out = gz
+	}
+	for _, b := range [][]byte{head.Bytes(), block, []byte("\r\n\r\n")} {
+		if _, err := out.Write(b); err != nil {
+			return err
+		}
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// Close closes the WARC file
+func (w *warcWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// sha1Digest is the digest form WARC uses, sha1: and the base32 SHA-1
+func sha1Digest(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
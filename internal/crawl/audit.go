@@ -0,0 +1,168 @@
+package crawl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"kirk-ai/internal/robots"
+	"kirk-ai/internal/workspace"
+)
+
+var defaultAuditDir = workspace.Default(workspace.Audit)
+
+// Audit events
+const (
+	auditRunStart = "run_start"
+	auditRobots   = "robots"
+	auditWait     = "wait"
+	auditSkip     = "skip"
+	auditFetch    = "fetch"
+	auditRender   = "render"
+	auditRunEnd   = "run_end"
+)
+
+// Skip reasons recorded alongside the robots.txt reasons
+const (
+	skipInvalidURL = "invalid URL"
+	skipDuplicate  = "duplicate"
+	skipPageLimit  = "page limit reached"
+	skipCanceled   = "crawl interrupted"
+)
+
+// auditEvent is one line of a crawl audit log
+type auditEvent struct {
+	Time    string                 `json:"time"`
+	RunID   string                 `json:"run_id"`
+	Crawler string                 `json:"crawler"`
+	Event   string                 `json:"event"`
+	URL     string                 `json:"url,omitempty"`
+	Host    string                 `json:"host,omitempty"`
+	Allowed *bool                  `json:"allowed,omitempty"`
+	Reason  string                 `json:"reason,omitempty"`
+	WaitMS  int64                  `json:"wait_ms,omitempty"`
+	Detail  map[string]interface{} `json:"detail,omitempty"`
+}
+
+// auditLog records every robots.txt decision, politeness wait, and skipped URL of one crawl
+// run to its own JSONL file, so operators can show how a site was crawled and find out why
+// a page is missing
+type auditLog struct {
+	runID   string
+	crawler string
+	path    string
+
+	mu sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+}
+
+// audit is the current run's log; nil records nothing
+var audit *auditLog
+
+// openAudit creates <dir>/<run id>-<crawler>.jsonl; an empty dir returns nil, which records nothing
+func openAudit(dir, crawler, runID string) (*auditLog, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	ensureDir(dir)
+	path := filepath.Join(dir, runID+"-"+crawler+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{runID: runID, crawler: crawler, path: path, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// record writes an event, stamping it with the time and run
+func (a *auditLog) record(e auditEvent) {
+	if a == nil {
+		return
+	}
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	e.RunID = a.runID
+	e.Crawler = a.crawler
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Write(b)
+	a.w.WriteByte('\n')
+}
+
+// Start records the identity and politeness settings the run uses
+func (a *auditLog) Start(cfg *crawlConfig, detail map[string]interface{}) {
+	if a == nil {
+		return
+	}
+	d := map[string]interface{}{
+		"user_agent":    crawlerIdentity.userAgent,
+		"robots_agent":  robotsUserAgent,
+		"default_delay": cfg.DefaultDelay,
+		"jitter":        cfg.Jitter,
+	}
+	if crawlerIdentity.from != "" {
+		d["from"] = crawlerIdentity.from
+	}
+	if len(cfg.Hosts) > 0 {
+		d["hosts"] = cfg.Hosts
+	}
+	for k, v := range detail {
+		d[k] = v
+	}
+	a.record(auditEvent{Event: auditRunStart, Detail: d})
+}
+
+// Robots records a robots.txt decision
+func (a *auditLog) Robots(u string, d robots.Decision) {
+	allowed := d.Allowed
+	a.record(auditEvent{Event: auditRobots, URL: u, Host: hostOf(u), Allowed: &allowed, Reason: d.Reason})
+}
+
+// Wait records a politeness delay before a request to host
+func (a *auditLog) Wait(host string, d time.Duration) {
+	a.record(auditEvent{Event: auditWait, Host: host, WaitMS: d.Milliseconds()})
+}
+
+// Skip records a URL that was not fetched and why
+func (a *auditLog) Skip(u, reason string) {
+	a.record(auditEvent{Event: auditSkip, URL: u, Host: hostOf(u), Reason: reason})
+}
+
+// Fetch records a page that was fetched
+func (a *auditLog) Fetch(u string) {
+	a.record(auditEvent{Event: auditFetch, URL: u, Host: hostOf(u)})
+}
+
+// Render records a page that was rendered in a headless browser after a plain fetch
+func (a *auditLog) Render(u string) {
+	a.record(auditEvent{Event: auditRender, URL: u, Host: hostOf(u)})
+}
+
+// Close records the end of the run with its totals, then flushes and closes the file
+func (a *auditLog) Close(detail map[string]interface{}) error {
+	if a == nil {
+		return nil
+	}
+	a.record(auditEvent{Event: auditRunEnd, Detail: detail})
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.w.Flush(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
+
+// robotsAllowed checks robots.txt for u and records the decision in the audit log
+func robotsAllowed(ctx context.Context, u string) bool {
+	d := robotsChecker.Check(ctx, robotsUserAgent, u)
+	audit.Robots(u, d)
+	return d.Allowed
+}
@@ -0,0 +1,100 @@
+package crawl
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/robots"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/chromedp/chromedp"
+	"github.com/spf13/pflag"
+)
+
+func chromedpCrawler(fs *pflag.FlagSet) func(verbose bool) {
+	var urlFile string
+	var crawlConfigPath string
+	var auditDir string
+	fs.StringVar(&urlFile, "urls", defaultDiscoveredPath, "file with URLs to fetch, plain text (as written by discover) or JSONL frontier")
+	fs.StringVar(&crawlConfigPath, "crawl-config", defaultCrawlConfigPath, "crawl config with the operator contact details (JSON, or YAML for .yaml and .yml files)")
+	fs.StringVar(&auditDir, "audit-dir", defaultAuditDir, "write each run's robots decisions and skipped URLs to a JSONL file here (empty disables)")
+	return func(bool) {
+		useWorkspaceRobots()
+		cfg, err := loadCrawlConfig(crawlConfigPath)
+		if err != nil {
+			log.Fatalf("chromedp: %v", err)
+		}
+		setIdentity(cfg)
+		audit, err = openAudit(auditDir, "chromedp", provenance.NewRunID())
+		if err != nil {
+			log.Fatalf("chromedp: open audit log: %v", err)
+		}
+		audit.Start(cfg, map[string]interface{}{"urls": urlFile})
+		saved := 0
+		defer func() {
+			if err := audit.Close(map[string]interface{}{"pages_saved": saved}); err != nil {
+				log.Printf("chromedp: write audit log: %v", err)
+			}
+		}()
+
+		outDir := workspace.Path(workspace.RawHTML)
+		ensureDir(outDir)
+		urlMap, err := urlutil.OpenMapping(filepath.Join(outDir, urlutil.MappingFile))
+		if err != nil {
+			log.Fatalf("chromedp: open URL mapping: %v", err)
+		}
+		defer urlMap.Close()
+
+		// The browser sends the crawler's User-Agent; the From header is not set for browser requests
+		allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(),
+			append(chromedp.DefaultExecAllocatorOptions[:], chromedp.UserAgent(crawlerIdentity.userAgent))...)
+		defer cancelAlloc()
+		ctx, cancel := chromedp.NewContext(allocCtx)
+		defer cancel()
+
+		urls := []string{"https://tpusa.com/"}
+		if _, err := os.Stat(urlFile); err == nil {
+			if u, err := readURLsFromFile(urlFile); err == nil && len(u) > 0 {
+				urls = u
+			}
+		}
+
+		for _, u := range urls {
+			if !robotsAllowed(ctx, u) {
+				log.Printf("chromedp: disallowed by robots.txt: %s", u)
+				audit.Skip(u, robots.ReasonDisallowed)
+				continue
+			}
+			ctx2, cancel := context.WithTimeout(ctx, 30*time.Second)
+			var html string
+			err := chromedp.Run(ctx2,
+				chromedp.Navigate(u),
+				chromedp.WaitReady("body", chromedp.ByQuery),
+				chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+			)
+			cancel()
+			if err != nil {
+				log.Printf("chromedp error for %s: %v", u, err)
+				audit.Skip(u, "fetch failed: "+err.Error())
+				continue
+			}
+			audit.Fetch(u)
+			fname, err := urlMap.Add(u, ".html")
+			if err != nil {
+				log.Printf("chromedp: record file name for %s: %v", u, err)
+			}
+			path := filepath.Join(outDir, fname)
+			if err := atomicfile.WriteFile(path, []byte(html), 0o644, false); err != nil {
+				log.Printf("write html %s: %v", path, err)
+			}
+			saved++
+			log.Printf("chromedp: saved %s", path)
+		}
+	}
+}
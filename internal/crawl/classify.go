@@ -0,0 +1,73 @@
+package crawl
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"kirk-ai/internal/classify"
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/provenance"
+
+	"github.com/spf13/pflag"
+)
+
+// pageClassifier tags each saved page with its type; nil leaves pages untagged
+var pageClassifier classify.Classifier
+
+// classifyFlags holds the page classification flags shared by the crawler tools
+type classifyFlags struct {
+	method, rules, model, ollamaURL string
+}
+
+// addClassifyFlags registers the classification flags; call open once they are parsed
+func addClassifyFlags(fs *pflag.FlagSet) *classifyFlags {
+	f := &classifyFlags{}
+	fs.StringVar(&f.method, "classify", "off", "tag pages as article, event, product, or landing page: rules (keyword and markup rules), llm (ask --classify-model, falling back to the rules), or off")
+	fs.StringVar(&f.rules, "classify-rules", "", "JSON file of classification rules replacing the built-in ones")
+	fs.StringVar(&f.model, "classify-model", classify.DefaultLLMModel, "chat model used by --classify llm")
+	fs.StringVar(&f.ollamaURL, "ollama-url", "http://localhost:11434", "Ollama server used by --classify llm")
+	return f
+}
+
+// open sets pageClassifier from the parsed flags
+func (f *classifyFlags) open() error {
+	var err error
+	pageClassifier, err = classify.New(f.method, f.rules, f.model, f.ollamaURL)
+	return err
+}
+
+// classifyPage returns the type of a page, or a zero result when classification is off or
+// fails. sig must be read before extraction, which strips the document's JSON-LD.
+func classifyPage(ctx context.Context, sig classify.Page, content string) classify.Result {
+	if pageClassifier == nil {
+		return classify.Result{}
+	}
+	sig.Content = content
+	r, err := pageClassifier.Classify(ctx, sig)
+	if err != nil {
+		log.Printf("classify %s: %v", sig.URL, err)
+		return classify.Result{}
+	}
+	return r
+}
+
+// pageRecords converts the pages extracted from one document into result records, tagged
+// with the type of the whole document so overflow parts share it
+func pageRecords(ctx context.Context, sig classify.Page, pages []extract.Page, prov provenance.Record) []map[string]interface{} {
+	var content strings.Builder
+	for _, page := range pages {
+		content.WriteString(page.Content)
+		content.WriteByte(' ')
+	}
+	class := classifyPage(ctx, sig, content.String())
+	records := make([]map[string]interface{}, 0, len(pages))
+	for _, page := range pages {
+		r := pageRecord(page, prov)
+		if !class.IsZero() {
+			r[classify.MetadataKey] = class.Map()
+		}
+		records = append(records, r)
+	}
+	return records
+}
@@ -0,0 +1,208 @@
+package crawl
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/classify"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/robots"
+	"kirk-ai/internal/sink"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/spf13/pflag"
+)
+
+func collyCrawler(fs *pflag.FlagSet) func(verbose bool) {
+	var urlFile string
+	var parallel int
+	var crawlConfigPath string
+	var jitter float64
+	var maxPerHost int
+	var frontierPath string
+	var auditDir string
+	fs.StringVar(&urlFile, "urls", defaultDiscoveredPath, "file with URLs to fetch, plain text (as written by discover) or JSONL frontier")
+	fs.IntVar(&parallel, "parallel", 4, "colly parallelism per process")
+	fs.StringVar(&crawlConfigPath, "crawl-config", defaultCrawlConfigPath, "crawl config with per-host delays, seeds, and domain filters (JSON, or YAML for .yaml and .yml files)")
+	fs.Float64Var(&jitter, "jitter", -1, "random jitter as a fraction of the per-host delay (overrides crawl config when >= 0)")
+	fs.IntVar(&maxPerHost, "max-per-host", 0, "most requests in flight to one host at a time (overrides the crawl config's max_per_host when > 0; default 2)")
+	fs.StringVar(&frontierPath, "frontier", defaultFrontierPath, "append discovered URLs with depth, referrer, and discovery method to this JSONL file (empty disables)")
+	fs.StringVar(&auditDir, "audit-dir", defaultAuditDir, "write each run's robots decisions and skipped URLs to a JSONL file here (empty disables)")
+	classifyOpts := addClassifyFlags(fs)
+	scopeOpts := addScopeFlags(fs)
+	return func(bool) {
+		useWorkspaceRobots()
+		if err := classifyOpts.open(); err != nil {
+			log.Fatalf("colly: %v", err)
+		}
+
+		frontier, err := openFrontier(frontierPath)
+		if err != nil {
+			log.Fatalf("colly: open frontier: %v", err)
+		}
+		defer frontier.Close()
+
+		cfg, err := loadCrawlConfig(crawlConfigPath)
+		if err != nil {
+			log.Fatalf("colly: %v", err)
+		}
+		if err := scopeOpts.open(cfg); err != nil {
+			log.Fatalf("colly: %v", err)
+		}
+		if jitter >= 0 {
+			cfg.Jitter = jitter
+		}
+		if maxPerHost > 0 {
+			cfg.MaxPerHost = maxPerHost
+		}
+		polite, err := newPoliteness(cfg)
+		if err != nil {
+			log.Fatalf("colly: crawl config: %v", err)
+		}
+
+		runID := provenance.NewRunID()
+		outDir := workspace.Path(workspace.RawHTML)
+		ensureDir(outDir)
+		urlMap, err := urlutil.OpenMapping(filepath.Join(outDir, urlutil.MappingFile))
+		if err != nil {
+			log.Fatalf("colly: open URL mapping: %v", err)
+		}
+		defer urlMap.Close()
+		jsonOut := workspace.Path(workspace.CollyResults)
+
+		setIdentity(cfg)
+		audit, err = openAudit(auditDir, "colly", runID)
+		if err != nil {
+			log.Fatalf("colly: open audit log: %v", err)
+		}
+		// colly enforces the delays itself, so individual waits are not recorded
+		audit.Start(cfg, map[string]interface{}{"urls": urlFile, "parallel": parallel, "waits": "enforced by colly limit rules"})
+		// The seeds and the urls file start the crawl, and links stay within their domains
+		// unless allowed domains are given
+		starts := append([]string(nil), crawlScope.seeds...)
+		if _, err := os.Stat(urlFile); err == nil {
+			if urls, err := readURLsFromFile(urlFile); err == nil {
+				starts = append(starts, urls...)
+			}
+		}
+		crawlScope = crawlScope.confined(starts)
+
+		c := colly.NewCollector(
+			colly.UserAgent(crawlerIdentity.userAgent),
+			colly.MaxDepth(3),
+			colly.Async(true),
+		)
+
+		// Per-host rules first so they take precedence over the catch-all; RandomDelay adds jitter
+		for _, rule := range polite.collyRules(parallel) {
+			if err := c.Limit(rule); err != nil {
+				log.Fatalf("colly: limit rule %s: %v", rule.DomainGlob, err)
+			}
+		}
+		// colly's catch-all rule is shared by every other host, so it takes the per-host cap too
+		catchAll := parallel
+		if cfg.MaxPerHost > 0 && cfg.MaxPerHost < catchAll {
+			catchAll = cfg.MaxPerHost
+		}
+		c.Limit(&colly.LimitRule{DomainGlob: "*", Parallelism: catchAll, Delay: 500 * time.Millisecond,
+			RandomDelay: time.Duration(cfg.Jitter * float64(500*time.Millisecond))})
+
+		// colly runs callbacks concurrently in async mode, so pages go through a shared sink
+		results := sink.New(sink.Options{BufferSize: 50, FlushInterval: 30 * time.Second},
+			sink.NewJSON[map[string]interface{}](jsonOut, false))
+		c.OnHTML("html", func(e *colly.HTMLElement) {
+			sel := e.DOM
+			sig := classify.Signals(e.Request.URL.String(), sel)
+			page := map[string]interface{}{}
+			page["url"] = e.Request.URL.String()
+			page["title"] = strings.TrimSpace(sel.Find("title").Text())
+			page["meta_description"] = strings.TrimSpace(sel.Find("meta[name=description]").AttrOr("content", ""))
+			// collect paragraphs
+			paras := []string{}
+			sel.Find("p").Each(func(i int, s *goquery.Selection) {
+				if t := strings.TrimSpace(s.Text()); t != "" {
+					paras = append(paras, t)
+				}
+			})
+			page["content"] = strings.Join(paras, " ")
+			if class := classifyPage(context.Background(), sig, page["content"].(string)); !class.IsZero() {
+				page[classify.MetadataKey] = class.Map()
+			}
+			page[provenance.MetadataKey] = provenance.Record{CrawlRunID: runID, Crawler: "colly", FetchedAt: provenance.Now()}.Map()
+
+			// Save raw HTML snapshot
+			u := e.Request.URL.String()
+			fname, err := urlMap.Add(u, ".html")
+			if err != nil {
+				log.Printf("warning: could not record file name for %s: %v", u, err)
+			}
+			htmlStr, err := e.DOM.Html()
+			if err != nil {
+				log.Printf("warning: could not obtain html for %s: %v", u, err)
+			} else {
+				htmlPath := filepath.Join(outDir, fname)
+				if err := atomicfile.WriteFile(htmlPath, []byte(htmlStr), 0o644, false); err != nil {
+					log.Printf("warning: could not write html snapshot for %s: %v", u, err)
+				}
+			}
+
+			results.Add(page)
+		})
+
+		c.OnHTML("a[href]", func(e *colly.HTMLElement) {
+			href := e.Attr("href")
+			// resolve and visit
+			if u, err := e.Request.URL.Parse(href); err == nil {
+				// only follow links within the crawl's scope
+				if isCrawlable(u.String()) {
+					frontier.Record(urlutil.Normalize(u.String()), e.Request.Depth, e.Request.URL.String(), discoveredLink)
+					e.Request.Visit(u.String())
+				}
+			}
+		})
+
+		c.OnRequest(func(r *colly.Request) {
+			if reason := excludeReason(r.URL.String()); reason != "" {
+				audit.Skip(r.URL.String(), reason)
+				r.Abort()
+				return
+			}
+			if !robotsAllowed(context.Background(), r.URL.String()) {
+				log.Println("disallowed by robots.txt", r.URL.String())
+				audit.Skip(r.URL.String(), robots.ReasonDisallowed)
+				r.Abort()
+				return
+			}
+			if crawlerIdentity.from != "" {
+				r.Headers.Set("From", crawlerIdentity.from)
+			}
+			log.Println("visiting", r.URL.String())
+		})
+		c.OnResponse(func(r *colly.Response) { audit.Fetch(r.Request.URL.String()) })
+		c.OnError(func(r *colly.Response, err error) {
+			log.Printf("error %s: %v", r.Request.URL.String(), err)
+			audit.Skip(r.Request.URL.String(), "fetch failed: "+err.Error())
+		})
+
+		for _, u := range starts {
+			c.Visit(u)
+		}
+		c.Wait()
+
+		if err := results.Close(); err != nil {
+			log.Fatalf("write results: %v", err)
+		}
+		log.Printf("colly: written %d pages to %s", results.Count(), jsonOut)
+		if err := audit.Close(map[string]interface{}{"pages_saved": results.Count()}); err != nil {
+			log.Printf("colly: write audit log: %v", err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Package crawl holds the crawlers behind the crawl command: they fetch pages politely,
+// honouring robots.txt and per-host delays, and write raw results under the workspace
+package crawl
+
+import (
+	"log"
+
+	"github.com/spf13/pflag"
+)
+
+// Tool is one crawler. Setup registers its flags on fs and returns the function that runs
+// it once they are parsed; verbose is the CLI's --verbose.
+type Tool struct {
+	Name  string
+	Short string
+	Setup func(fs *pflag.FlagSet) func(verbose bool)
+}
+
+// Tools lists the crawlers in the order the CLI shows them
+var Tools = []Tool{
+	{Name: "discover", Short: "Read sitemaps and feeds into the URL list the other crawlers fetch", Setup: discoverTool},
+	{Name: "requests", Short: "Fetch pages with plain HTTP requests, following links from the seeds without --urls", Setup: requestsCrawler},
+	{Name: "hybrid", Short: "Fetch with plain HTTP requests and render JavaScript pages in a headless browser", Setup: hybridCrawler},
+	{Name: "colly", Short: "Crawl with colly, following links within the allowed domains", Setup: collyCrawler},
+	{Name: "chromedp", Short: "Render every page in a headless browser", Setup: chromedpCrawler},
+	{Name: "api", Short: "Check known API endpoints and fetch RSS and Atom feeds", Setup: apiCollector},
+}
+
+// Finish saves state the crawlers share between runs, such as the robots.txt cache
+func Finish() {
+	if err := robotsChecker.Flush(); err != nil {
+		log.Printf("robots: %v", err)
+	}
+}
@@ -0,0 +1,417 @@
+package crawl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/robots"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/spf13/pflag"
+)
+
+// defaultDiscoveredPath is where discover writes the URLs it finds, and where colly and
+// chromedp read their URLs from by default
+var defaultDiscoveredPath = workspace.Default(workspace.DiscoveredURLs)
+
+const (
+	// maxSitemapBytes caps one sitemap or feed download; the sitemap protocol allows 50 MB
+	maxSitemapBytes = 50 << 20
+	// maxSitemapDepth caps how deeply sitemap indexes may nest
+	maxSitemapDepth = 5
+)
+
+// discovered is a page URL found in a sitemap or feed
+type discovered struct {
+	url     string
+	lastmod time.Time // zero when the sitemap or feed gives none
+	source  string    // sitemap or feed listing it
+	method  string    // discoveredSitemap or discoveredFeed
+}
+
+// sitemapDoc is a sitemap (<urlset>) or a sitemap index (<sitemapindex>)
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// discoverer fetches sitemaps and feeds politely and collects the page URLs they list
+type discoverer struct {
+	ctx     context.Context
+	polite  *politeness
+	verbose bool
+
+	seen     map[string]bool // sitemaps and feeds already fetched
+	pages    map[string]*discovered
+	order    []string // page URLs in discovery order
+	fetched  int
+	failures int
+}
+
+// discoverTool reads sitemaps (following sitemap indexes) and RSS or Atom feeds, and writes the
+// page URLs they list, with their last modification times, to a URL file the crawlers read
+func discoverTool(fs *pflag.FlagSet) func(verbose bool) {
+	var sitemaps, feeds, match, exclude []string
+	var outPath, crawlConfigPath, robotsCachePath, frontierPath, auditDir string
+	fs.StringSliceVar(&sitemaps, "sitemaps", nil, "sitemap or sitemap index URLs to read (default: those listed in each seed host's robots.txt, else /sitemap.xml)")
+	fs.StringSliceVar(&feeds, "feeds", nil, "RSS or Atom feed URLs whose item links are added too")
+	fs.StringSliceVar(&match, "match", nil, "keep only URLs matching one of these patterns, over the URL without its scheme, where * matches any run of characters (e.g. example.org/blog/*)")
+	fs.StringSliceVar(&exclude, "exclude", nil, "drop URLs matching one of these patterns, written like --match")
+	fs.StringVar(&outPath, "out", defaultDiscoveredPath, "write the discovered URLs here, one per line with the last modification time after a tab when known")
+	fs.StringVar(&crawlConfigPath, "crawl-config", defaultCrawlConfigPath, "crawl config with per-host delays, seeds, and domain filters (JSON, or YAML for .yaml and .yml files)")
+	fs.StringVar(&robotsCachePath, "robots-cache", workspace.Default(workspace.RobotsCache), "robots.txt cache file shared across crawler processes (empty disables)")
+	fs.StringVar(&frontierPath, "frontier", defaultFrontierPath, "append discovered URLs with referrer and discovery method to this JSONL file (empty disables)")
+	fs.StringVar(&auditDir, "audit-dir", defaultAuditDir, "write each run's robots decisions and skipped URLs to a JSONL file here (empty disables)")
+	scopeOpts := addScopeFlags(fs)
+	return func(verbose bool) {
+		cfg, err := loadCrawlConfig(crawlConfigPath)
+		if err != nil {
+			log.Fatalf("discover: %v", err)
+		}
+		if err := scopeOpts.open(cfg); err != nil {
+			log.Fatalf("discover: %v", err)
+		}
+		keep, err := compileURLPatterns(match)
+		if err != nil {
+			log.Fatalf("discover: --match: %v", err)
+		}
+		drop, err := compileURLPatterns(exclude)
+		if err != nil {
+			log.Fatalf("discover: --exclude: %v", err)
+		}
+		polite, err := newPoliteness(cfg)
+		if err != nil {
+			log.Fatalf("discover: crawl config: %v", err)
+		}
+		robotsChecker = robots.New(httpClient, robotsCachePath)
+		setIdentity(cfg)
+		runID := provenance.NewRunID()
+		audit, err = openAudit(auditDir, "discover", runID)
+		if err != nil {
+			log.Fatalf("discover: open audit log: %v", err)
+		}
+		audit.Start(cfg, map[string]interface{}{"sitemaps": sitemaps, "feeds": feeds, "match": match, "exclude": exclude})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigch := make(chan os.Signal, 1)
+		signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigch
+			log.Println("discover: interrupt received, writing what was found so far...")
+			cancel()
+		}()
+
+		if len(sitemaps) == 0 {
+			sitemaps = seedSitemaps(ctx, crawlScope.seeds)
+		}
+		// Without allowed domains, keep the URLs on the hosts of the seeds and sitemaps
+		crawlScope = crawlScope.confined(append(append([]string(nil), crawlScope.seeds...), sitemaps...))
+		d := &discoverer{ctx: ctx, polite: polite, verbose: verbose, seen: make(map[string]bool), pages: make(map[string]*discovered)}
+		for _, s := range sitemaps {
+			d.sitemap(s, 0)
+		}
+		for _, f := range feeds {
+			d.feed(f)
+		}
+
+		// Newest first, so a crawl cut short has fetched what changed most recently. Sitemaps
+		// may list pages robots.txt disallows, so those are dropped here.
+		var kept []*discovered
+		for _, u := range d.order {
+			p := d.pages[u]
+			if reason := p.filter(keep, drop); reason != "" {
+				audit.Skip(u, reason)
+				continue
+			}
+			if !robotsAllowed(ctx, u) {
+				audit.Skip(u, robots.ReasonDisallowed)
+				continue
+			}
+			kept = append(kept, p)
+		}
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].lastmod.After(kept[j].lastmod) })
+
+		frontier, err := openFrontier(frontierPath)
+		if err != nil {
+			log.Fatalf("discover: open frontier: %v", err)
+		}
+		var buf bytes.Buffer
+		for _, p := range kept {
+			buf.WriteString(p.url)
+			if !p.lastmod.IsZero() {
+				buf.WriteString("\t" + p.lastmod.UTC().Format(time.RFC3339))
+			}
+			buf.WriteByte('\n')
+			frontier.Record(p.url, 1, p.source, p.method)
+		}
+		if err := frontier.Close(); err != nil {
+			log.Printf("discover: write frontier: %v", err)
+		}
+		if i := strings.LastIndex(outPath, "/"); i > 0 {
+			ensureDir(outPath[:i])
+		}
+		if err := atomicfile.WriteFile(outPath, buf.Bytes(), 0o644, true); err != nil {
+			log.Fatalf("discover: %v", err)
+		}
+		log.Printf("discover: wrote %d URLs to %s (%d found in %d sitemaps and feeds, %d failed to load)",
+			len(kept), outPath, len(d.order), d.fetched, d.failures)
+		if err := audit.Close(map[string]interface{}{"urls_found": len(d.order), "urls_written": len(kept), "sources_fetched": d.fetched, "sources_failed": d.failures}); err != nil {
+			log.Printf("discover: write audit log: %v", err)
+		}
+	}
+}
+
+// seedSitemaps returns the sitemaps listed in the robots.txt of each seed's host, or the
+// host's /sitemap.xml when it lists none
+func seedSitemaps(ctx context.Context, seeds []string) []string {
+	var out []string
+	done := make(map[string]bool)
+	for _, seed := range seeds {
+		u, err := url.Parse(seed)
+		if err != nil || done[u.Host] {
+			continue
+		}
+		done[u.Host] = true
+		listed := robotsChecker.Sitemaps(ctx, robotsUserAgent, seed)
+		if len(listed) == 0 {
+			listed = []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}
+		}
+		out = append(out, listed...)
+	}
+	return out
+}
+
+// compileURLPatterns compiles --match and --exclude patterns, written like source weight
+// patterns: over the URL without its scheme and "www.", where '*' matches any run of characters
+func compileURLPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var out []*regexp.Regexp
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if i := strings.Index(p, "://"); i >= 0 {
+			p = p[i+3:]
+		}
+		parts := strings.Split(strings.TrimPrefix(p, "www."), "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// filter says why a discovered URL is left out, or "" when it is kept
+func (p *discovered) filter(keep, drop []*regexp.Regexp) string {
+	if reason := excludeReason(p.url); reason != "" {
+		return reason
+	}
+	bare := strings.ToLower(p.url)
+	if i := strings.Index(bare, "://"); i >= 0 {
+		bare = bare[i+3:]
+	}
+	bare = strings.TrimPrefix(bare, "www.")
+	if len(keep) > 0 && !anyMatch(keep, bare) {
+		return "not matched by --match"
+	}
+	if anyMatch(drop, bare) {
+		return "matched by --exclude"
+	}
+	return ""
+}
+
+func anyMatch(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// add records a page URL, keeping the latest modification time seen for it
+func (d *discoverer) add(raw string, lastmod time.Time, source, method string) {
+	u := urlutil.Normalize(strings.TrimSpace(raw))
+	if u == "" {
+		return
+	}
+	if p, ok := d.pages[u]; ok {
+		if lastmod.After(p.lastmod) {
+			p.lastmod = lastmod
+		}
+		return
+	}
+	d.pages[u] = &discovered{url: u, lastmod: lastmod, source: source, method: method}
+	d.order = append(d.order, u)
+}
+
+// sitemap reads a sitemap, following the sitemaps a sitemap index lists. Feeds and plain-text
+// URL lists given as sitemaps are read too, as the sitemap protocol allows.
+func (d *discoverer) sitemap(u string, depth int) {
+	body, ok := d.fetch(u)
+	if !ok {
+		return
+	}
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		// Plain text: one URL per line
+		for _, line := range strings.Split(string(trimmed), "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "http") {
+				d.add(line, time.Time{}, u, discoveredSitemap)
+			}
+		}
+		return
+	}
+	var doc sitemapDoc
+	if err := xml.Unmarshal(trimmed, &doc); err != nil {
+		d.failed(u, fmt.Errorf("parse: %w", err))
+		return
+	}
+	switch doc.XMLName.Local {
+	case "sitemapindex":
+		if depth >= maxSitemapDepth {
+			audit.Skip(u, "sitemap index nested too deeply")
+			return
+		}
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				d.sitemap(loc, depth+1)
+			}
+		}
+	case "urlset":
+		for _, e := range doc.URLs {
+			d.add(e.Loc, parseLastMod(e.LastMod), u, discoveredSitemap)
+		}
+	default:
+		d.parseFeed(u, trimmed)
+	}
+}
+
+// feed reads an RSS or Atom feed
+func (d *discoverer) feed(u string) {
+	if body, ok := d.fetch(u); ok {
+		d.parseFeed(u, body)
+	}
+}
+
+// parseFeed adds the item links of a feed, dated by their update or publication time
+func (d *discoverer) parseFeed(u string, body []byte) {
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		d.failed(u, fmt.Errorf("not a sitemap or feed: %w", err))
+		return
+	}
+	for _, item := range feed.Items {
+		var at time.Time
+		switch {
+		case item.UpdatedParsed != nil:
+			at = *item.UpdatedParsed
+		case item.PublishedParsed != nil:
+			at = *item.PublishedParsed
+		}
+		d.add(item.Link, at, u, discoveredFeed)
+	}
+}
+
+// fetch downloads a sitemap or feed once, honouring robots.txt and the politeness delays, and
+// decompresses gzipped sitemaps
+func (d *discoverer) fetch(u string) ([]byte, bool) {
+	u = strings.TrimSpace(u)
+	if d.seen[u] || d.ctx.Err() != nil {
+		return nil, false
+	}
+	d.seen[u] = true
+	if !robotsAllowed(d.ctx, u) {
+		audit.Skip(u, robots.ReasonDisallowed)
+		return nil, false
+	}
+	if err := d.polite.Wait(d.ctx, hostOf(u)); err != nil {
+		return nil, false
+	}
+	if d.verbose {
+		log.Println("discover: reading", u)
+	}
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, u, nil)
+	if err != nil {
+		d.failed(u, err)
+		return nil, false
+	}
+	crawlerIdentity.apply(req.Header)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		d.failed(u, err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		d.failed(u, fmt.Errorf("status %s", resp.Status))
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapBytes))
+	if err != nil {
+		d.failed(u, err)
+		return nil, false
+	}
+	// gzip magic number: .xml.gz sitemaps are usually served without Content-Encoding
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err == nil {
+			body, err = io.ReadAll(io.LimitReader(zr, maxSitemapBytes))
+		}
+		if err != nil {
+			d.failed(u, fmt.Errorf("gunzip: %w", err))
+			return nil, false
+		}
+	}
+	audit.Fetch(u)
+	d.fetched++
+	return body, true
+}
+
+// failed logs and audits a sitemap or feed that could not be read
+func (d *discoverer) failed(u string, err error) {
+	d.failures++
+	log.Printf("discover: %s: %v", u, err)
+	audit.Skip(u, "fetch failed: "+err.Error())
+}
+
+// parseLastMod reads a W3C datetime as used in sitemaps; unparseable values are dropped
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
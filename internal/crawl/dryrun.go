@@ -0,0 +1,92 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"kirk-ai/internal/urlutil"
+)
+
+// dryRunSamples is how many URLs per host a dry run lists
+const dryRunSamples = 3
+
+// crawlPlan is what a crawl would fetch, resolved without fetching any pages
+type crawlPlan struct {
+	Seeds      int
+	Duplicates int
+	Excluded   int
+	Disallowed int
+	Hosts      map[string][]string // URLs that would be fetched, by host
+}
+
+// planCrawl normalizes and dedupes seeds and applies the URL filters and robots.txt.
+// robots.txt files are the only network reads.
+func planCrawl(ctx context.Context, seeds []string) *crawlPlan {
+	p := &crawlPlan{Seeds: len(seeds), Hosts: make(map[string][]string)}
+	seen := make(map[string]struct{})
+	for _, u := range seeds {
+		u = urlutil.Normalize(u)
+		if u == "" {
+			p.Excluded++
+			continue
+		}
+		if _, ok := seen[u]; ok {
+			p.Duplicates++
+			continue
+		}
+		seen[u] = struct{}{}
+		if !isCrawlable(u) {
+			p.Excluded++
+			continue
+		}
+		if !robotsChecker.Allowed(ctx, robotsUserAgent, u) {
+			p.Disallowed++
+			continue
+		}
+		host := hostOf(u)
+		p.Hosts[host] = append(p.Hosts[host], u)
+	}
+	return p
+}
+
+// Print reports the plan with request counts and the minimum duration implied by the
+// per-host politeness delays (hosts are crawled concurrently)
+func (p *crawlPlan) Print(polite *politeness, note string) {
+	hosts := make([]string, 0, len(p.Hosts))
+	pages := 0
+	for h, urls := range p.Hosts {
+		hosts = append(hosts, h)
+		pages += len(urls)
+	}
+	sort.Strings(hosts)
+
+	fmt.Println("Dry run: no pages will be fetched and no files written (robots.txt is checked)")
+	fmt.Printf("Seeds: %d (%d duplicates, %d excluded by filters, %d disallowed by robots.txt)\n",
+		p.Seeds, p.Duplicates, p.Excluded, p.Disallowed)
+	fmt.Printf("Would fetch: %d pages on %d hosts\n", pages, len(hosts))
+	var longest time.Duration
+	for _, h := range hosts {
+		urls := p.Hosts[h]
+		hp := polite.effective(h)
+		// expected delay per request is the base delay plus half the maximum jitter
+		d := time.Duration(float64(len(urls)) * float64(hp.delay) * (1 + hp.jitter/2))
+		if d > longest {
+			longest = d
+		}
+		fmt.Printf("  %s: %d pages, delay %v, ~%v\n", h, len(urls), hp.delay, d.Round(time.Second))
+		for i, u := range urls {
+			if i == dryRunSamples {
+				fmt.Printf("    ... %d more\n", len(urls)-i)
+				break
+			}
+			fmt.Printf("    %s\n", u)
+		}
+	}
+	fmt.Printf("Estimated requests: %d page fetches + %d robots.txt\n", pages, len(hosts))
+	fmt.Printf("Estimated minimum duration: ~%v\n", longest.Round(time.Second))
+	if note != "" {
+		fmt.Println(note)
+	}
+}
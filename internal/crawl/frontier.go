@@ -0,0 +1,180 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Discovery methods recorded in the frontier
+const (
+	discoveredSeed    = "seed"
+	discoveredSitemap = "sitemap"
+	discoveredLink    = "link"
+	discoveredFeed    = "feed"
+)
+
+var defaultFrontierPath = workspace.Default(workspace.Frontier)
+
+// frontierEntry is one discovered URL; the frontier file holds one entry per line (JSONL)
+type frontierEntry struct {
+	URL          string    `json:"url"`
+	Depth        int       `json:"depth"`
+	Referrer     string    `json:"referrer,omitempty"`
+	Method       string    `json:"method"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+// readFrontier reads a URL list that is either plain text (one URL per line, anything after
+// the URL such as discover's lastmod ignored) or a JSONL frontier. Entries are returned
+// shallowest first, keeping file order within a depth.
+func readFrontier(path string) ([]frontierEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []frontierEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "{") {
+			var e frontierEntry
+			if err := json.Unmarshal([]byte(line), &e); err != nil || e.URL == "" {
+				continue
+			}
+			entries = append(entries, e)
+			continue
+		}
+		if i := strings.IndexAny(line, " \t"); i > 0 {
+			line = line[:i]
+		}
+		entries = append(entries, frontierEntry{URL: line, Method: discoveredSeed})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Depth < entries[j].Depth })
+	return entries, nil
+}
+
+// frontierWriter appends newly discovered URLs to a JSONL frontier file, skipping URLs
+// already present in the file or recorded earlier in the run
+type frontierWriter struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	seen map[string]bool
+}
+
+// openFrontier opens path for appending; an empty path returns nil, which records nothing
+func openFrontier(path string) (*frontierWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	fw := &frontierWriter{seen: make(map[string]bool)}
+	if existing, err := readFrontier(path); err == nil {
+		for _, e := range existing {
+			fw.seen[e.URL] = true
+		}
+	}
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		ensureDir(path[:i])
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	fw.f = f
+	fw.w = bufio.NewWriter(f)
+	return fw, nil
+}
+
+// Record appends an entry unless the URL is already known
+func (fw *frontierWriter) Record(u string, depth int, referrer, method string) {
+	if fw == nil || u == "" {
+		return
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.seen[u] {
+		return
+	}
+	fw.seen[u] = true
+	b, err := json.Marshal(frontierEntry{URL: u, Depth: depth, Referrer: referrer, Method: method, DiscoveredAt: time.Now().UTC()})
+	if err != nil {
+		return
+	}
+	fw.w.Write(b)
+	fw.w.WriteByte('\n')
+}
+
+// RecordLinks records every crawlable link on a page found at depth
+func (fw *frontierWriter) RecordLinks(doc *goquery.Document, pageURL string, depth int) {
+	if fw == nil {
+		return
+	}
+	for _, l := range pageLinks(doc, pageURL) {
+		fw.Record(l, depth+1, pageURL, discoveredLink)
+	}
+}
+
+// pageLinks returns the normalized, crawlable links on a page in document order
+func pageLinks(doc *goquery.Document, pageURL string) []string {
+	var links []string
+	for _, l := range docLinks(doc, pageURL) {
+		if isCrawlable(l) {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// docLinks returns every link on a page, resolved and normalized, in document order
+func docLinks(doc *goquery.Document, pageURL string) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var links []string
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		ref, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+		if abs := urlutil.Normalize(base.ResolveReference(ref).String()); abs != "" {
+			links = append(links, abs)
+		}
+	})
+	return links
+}
+
+// Close flushes and closes the frontier file
+func (fw *frontierWriter) Close() error {
+	if fw == nil {
+		return nil
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if err := fw.w.Flush(); err != nil {
+		fw.f.Close()
+		return err
+	}
+	return fw.f.Close()
+}
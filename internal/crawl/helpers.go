@@ -1,10 +1,8 @@
-package main
+package crawl
 
 import (
-	"io/ioutil"
 	"log"
 	"os"
-	"strings"
 )
 
 // ensureDir is shared across crawler tools to avoid duplicate definitions
@@ -14,17 +12,15 @@ func ensureDir(p string) {
 	}
 }
 
-// readURLsFromFile returns non-empty trimmed lines from a file or an error.
+// readURLsFromFile returns the URLs of a plain-text URL list or JSONL frontier, shallowest first.
 func readURLsFromFile(path string) ([]string, error) {
-	b, err := ioutil.ReadFile(path)
+	entries, err := readFrontier(path)
 	if err != nil {
 		return nil, err
 	}
-	lines := []string{}
-	for _, l := range strings.Split(string(b), "\n") {
-		if s := strings.TrimSpace(l); s != "" {
-			lines = append(lines, s)
-		}
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, e.URL)
 	}
 	return lines, nil
 }
@@ -0,0 +1,211 @@
+package crawl
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/workspace"
+)
+
+var (
+	defaultHTTPCachePath = workspace.Default(workspace.HTTPCache)
+	defaultChangeLogPath = workspace.Default(workspace.Changes)
+)
+
+// How a page compares with the last crawl, in the change log
+const (
+	changeNew       = "new"
+	changeChanged   = "changed"
+	changeUnchanged = "unchanged"
+)
+
+// cachedPage is what the HTTP cache remembers about a fetched page
+type cachedPage struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Hash         string    `json:"hash"`            // hash of the page's extracted text
+	Links        []string  `json:"links,omitempty"` // the page's links, followed without fetching it again
+	Checked      time.Time `json:"checked"`
+}
+
+// changeEntry is one line of the change log
+type changeEntry struct {
+	Time   string `json:"time"`
+	RunID  string `json:"run_id"`
+	URL    string `json:"url"`
+	Change string `json:"change"`
+	// NotModified is set when the server answered a conditional request with 304
+	NotModified bool `json:"not_modified,omitempty"`
+}
+
+// httpCache remembers the ETag, Last-Modified, and content hash of each page a crawl saved.
+// A re-crawl sends them back as If-None-Match and If-Modified-Since, so unchanged pages cost
+// a 304 and keep the records of the last crawl instead of being extracted again. Every page
+// is logged as new, changed, or unchanged to an append-only change log.
+type httpCache struct {
+	path  string
+	runID string
+
+	mu      sync.Mutex
+	pages   map[string]*cachedPage
+	records map[string][]map[string]interface{} // the last crawl's records by page URL
+	counts  map[string]int
+	log     *os.File
+	w       *bufio.Writer
+}
+
+// openHTTPCache reads the cache at path and the records the last crawl saved in resultsFile,
+// and opens the change log at changesPath for appending. An empty path returns nil, which
+// caches nothing; an empty changesPath logs nothing.
+func openHTTPCache(path, changesPath, resultsFile, runID string) (*httpCache, error) {
+	if path == "" {
+		return nil, nil
+	}
+	c := &httpCache{
+		path:    path,
+		runID:   runID,
+		pages:   make(map[string]*cachedPage),
+		records: make(map[string][]map[string]interface{}),
+		counts:  make(map[string]int),
+	}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &c.pages); err != nil {
+			return nil, fmt.Errorf("read HTTP cache %s: %w", path, err)
+		}
+	}
+	saved, err := readPreviousResults(resultsFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range saved {
+		if u, ok := rec["url"].(string); ok {
+			c.records[u] = append(c.records[u], rec)
+		}
+	}
+	if changesPath != "" {
+		ensureDir(filepath.Dir(changesPath))
+		f, err := os.OpenFile(changesPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		c.log, c.w = f, bufio.NewWriter(f)
+	}
+	return c, nil
+}
+
+// Validators returns the validators to send for u: those of its last fetch, when its records
+// from that crawl are at hand to keep should it be unchanged
+func (c *httpCache) Validators(u string) validators {
+	if c == nil {
+		return validators{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pages[u]
+	if !ok || len(c.records[u]) == 0 {
+		return validators{}
+	}
+	return validators{ETag: p.ETag, LastModified: p.LastModified}
+}
+
+// NotModified records that the server reported u unchanged, and returns the records and
+// links u had in the last crawl
+func (c *httpCache) NotModified(u string) ([]map[string]interface{}, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.pages[u]
+	p.Checked = time.Now().UTC()
+	c.logChange(u, changeUnchanged, true)
+	return c.records[u], p.Links
+}
+
+// Store records a page fetched in full with the validators it came with, logging whether its
+// extracted text differs from the last crawl's
+func (c *httpCache) Store(u string, v validators, records []map[string]interface{}, links []string) {
+	if c == nil {
+		return
+	}
+	h := sha256.New()
+	for _, rec := range records {
+		content, _ := rec["content"].(string)
+		h.Write([]byte(content))
+		h.Write([]byte{0})
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	change := changeNew
+	if old, ok := c.pages[u]; ok {
+		change = changeChanged
+		if old.Hash == hash {
+			change = changeUnchanged
+		}
+	}
+	c.pages[u] = &cachedPage{ETag: v.ETag, LastModified: v.LastModified, Hash: hash, Links: links, Checked: time.Now().UTC()}
+	c.logChange(u, change, false)
+}
+
+// logChange appends a change log line and counts it; the caller holds c.mu
+func (c *httpCache) logChange(u, change string, notModified bool) {
+	c.counts[change]++
+	if notModified {
+		c.counts["not_modified"]++
+	}
+	if c.w == nil {
+		return
+	}
+	b, err := json.Marshal(changeEntry{Time: time.Now().UTC().Format(time.RFC3339Nano), RunID: c.runID, URL: u, Change: change, NotModified: notModified})
+	if err != nil {
+		return
+	}
+	c.w.Write(b)
+	c.w.WriteByte('\n')
+}
+
+// Summary describes what changed in this crawl
+func (c *httpCache) Summary() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("%d new, %d changed, %d unchanged (%d not modified)",
+		c.counts[changeNew], c.counts[changeChanged], c.counts[changeUnchanged], c.counts["not_modified"])
+}
+
+// Close saves the cache and closes the change log
+func (c *httpCache) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.w != nil {
+		if err := c.w.Flush(); err != nil {
+			c.log.Close()
+			return err
+		}
+		if err := c.log.Close(); err != nil {
+			return err
+		}
+	}
+	b, err := json.Marshal(c.pages)
+	if err != nil {
+		return err
+	}
+	ensureDir(filepath.Dir(c.path))
+	return atomicfile.WriteFile(c.path, b, 0o644, true)
+}
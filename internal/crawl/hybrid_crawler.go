@@ -0,0 +1,395 @@
+package crawl
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/classify"
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/robots"
+	"kirk-ai/internal/sink"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/chromedp"
+	"github.com/spf13/pflag"
+)
+
+// defaultMinText is how many characters of extracted text a page with scripts needs to be
+// kept from the cheap fetch instead of being rendered
+const defaultMinText = 200
+
+// spaRootSelector matches the mount points client-rendered apps fill in from JavaScript
+const spaRootSelector = "#root, #app, #__next, #__nuxt, [data-reactroot], [ng-app], app-root"
+
+// crawlState is shared by the stages of a hybrid crawl: each URL is claimed once so no
+// stage fetches it twice, and each distinct page content is saved under the first URL
+// that produced it
+type crawlState struct {
+	mu      sync.Mutex
+	visited map[string]struct{}
+	content map[string]string // content hash -> URL it was first saved under
+}
+
+func newCrawlState() *crawlState {
+	return &crawlState{visited: make(map[string]struct{}), content: make(map[string]string)}
+}
+
+// claim marks u as visited, returning false when it already was
+func (s *crawlState) claim(u string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.visited[u]; ok {
+		return false
+	}
+	s.visited[u] = struct{}{}
+	return true
+}
+
+// claimContent records hash as saved under u, returning the URL it was first saved under
+// and false when another page already had the same content
+func (s *crawlState) claimContent(hash, u string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if first, ok := s.content[hash]; ok {
+		return first, false
+	}
+	s.content[hash] = u
+	return u, true
+}
+
+// needsRendering reports whether a statically fetched page depends on JavaScript for its
+// content: it has an empty app mount point, or it runs scripts and has under minText
+// characters of visible text
+func needsRendering(doc *goquery.Document, minText int) bool {
+	emptyRoot := false
+	doc.Find(spaRootSelector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		emptyRoot = strings.TrimSpace(s.Text()) == ""
+		return !emptyRoot
+	})
+	if emptyRoot {
+		return true
+	}
+	if doc.Find("script").Length() == 0 {
+		return false
+	}
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript").Remove()
+	return utf8.RuneCountInString(strings.TrimSpace(body.Text())) < minText
+}
+
+// renderJob is a page the fetch stage handed to the browser; the static pages are kept if
+// rendering fails
+type renderJob struct {
+	url    string
+	depth  int
+	doc    *goquery.Document
+	sig    classify.Page // markup signals of the static document
+	static []extract.Page
+}
+
+// hybridCrawler fetches every URL with the plain HTTP client and sends only the pages
+// that need JavaScript on to a headless browser, so static pages stay on the cheap path
+func hybridCrawler(fs *pflag.FlagSet) func(verbose bool) {
+	var urlFile string
+	var workers int
+	var tabs int
+	var minText int
+	var crawlConfigPath string
+	var jitter float64
+	var maxPerHost int
+	var robotsCachePath string
+	var frontierPath string
+	var linkGraphPath string
+	var auditDir string
+	extractOpts := extract.DefaultOptions()
+	fs.StringVar(&urlFile, "urls", "", "file with URLs to fetch, plain text or JSONL frontier (default: the requests crawler's seeds)")
+	fs.IntVar(&workers, "workers", 4, "number of parallel fetch workers")
+	fs.IntVar(&tabs, "tabs", 2, "number of browser tabs rendering JavaScript pages in parallel")
+	fs.IntVar(&minText, "min-text", defaultMinText, "pages with scripts and less extracted text than this many characters are rendered in the browser")
+	fs.StringVar(&crawlConfigPath, "crawl-config", defaultCrawlConfigPath, "crawl config with per-host delays, seeds, and domain filters (JSON, or YAML for .yaml and .yml files)")
+	fs.Float64Var(&jitter, "jitter", -1, "random jitter as a fraction of the per-host delay (overrides crawl config when >= 0)")
+	fs.IntVar(&maxPerHost, "max-per-host", 0, "most requests in flight to one host at a time (overrides the crawl config's max_per_host when > 0; default 2)")
+	fs.StringVar(&robotsCachePath, "robots-cache", workspace.Default(workspace.RobotsCache), "robots.txt cache file shared across crawler processes (empty disables)")
+	fs.StringVar(&frontierPath, "frontier", defaultFrontierPath, "append discovered URLs with depth, referrer, and discovery method to this JSONL file (empty disables)")
+	fs.StringVar(&linkGraphPath, "link-graph", defaultLinkGraphPath, "append each page's outgoing links to this JSONL file for authority scoring (empty disables)")
+	fs.StringVar(&auditDir, "audit-dir", defaultAuditDir, "write each run's robots decisions, politeness waits, and skipped URLs to a JSONL file here (empty disables)")
+	fs.IntVar(&extractOpts.MaxLength, "max-content", extract.MaxContentLength, "maximum characters of content kept per page (0 = unlimited)")
+	fs.StringVar(&extractOpts.Truncation, "truncate", extract.TruncateSentence, "how to cap long pages: sentence, hard, or overflow (split into several records)")
+	classifyOpts := addClassifyFlags(fs)
+	scopeOpts := addScopeFlags(fs)
+	return func(verbose bool) {
+
+		if err := extractOpts.Validate(); err != nil {
+			log.Fatalf("hybrid crawler: %v", err)
+		}
+		if err := classifyOpts.open(); err != nil {
+			log.Fatalf("hybrid crawler: %v", err)
+		}
+		cfg, err := loadCrawlConfig(crawlConfigPath)
+		if err != nil {
+			log.Fatalf("hybrid crawler: %v", err)
+		}
+		if err := scopeOpts.open(cfg); err != nil {
+			log.Fatalf("hybrid crawler: %v", err)
+		}
+		if jitter >= 0 {
+			cfg.Jitter = jitter
+		}
+		if maxPerHost > 0 {
+			cfg.MaxPerHost = maxPerHost
+		}
+		polite, err := newPoliteness(cfg)
+		if err != nil {
+			log.Fatalf("hybrid crawler: crawl config: %v", err)
+		}
+
+		entries := make([]frontierEntry, 0, len(crawlScope.seeds))
+		for _, s := range crawlScope.seeds {
+			entries = append(entries, frontierEntry{URL: s, Method: discoveredSeed})
+		}
+		if urlFile != "" {
+			if entries, err = readFrontier(urlFile); err != nil {
+				log.Fatalf("could not read urls file: %v", err)
+			}
+		}
+
+		runID := provenance.NewRunID()
+		fetched := func(crawler string) provenance.Record {
+			return provenance.Record{CrawlRunID: runID, Crawler: crawler, FetchedAt: provenance.Now()}
+		}
+		if verbose {
+			log.Println("hybrid crawler: run", runID)
+		}
+		robotsChecker = robots.New(httpClient, robotsCachePath)
+		setIdentity(cfg)
+		frontier, err := openFrontier(frontierPath)
+		if err != nil {
+			log.Fatalf("hybrid crawler: open frontier: %v", err)
+		}
+		defer frontier.Close()
+		linkGraph, err := openLinkGraph(linkGraphPath)
+		if err != nil {
+			log.Fatalf("hybrid crawler: open link graph: %v", err)
+		}
+		defer linkGraph.Close()
+		audit, err = openAudit(auditDir, "hybrid", runID)
+		if err != nil {
+			log.Fatalf("hybrid crawler: open audit log: %v", err)
+		}
+		audit.Start(cfg, map[string]interface{}{"urls": urlFile, "workers": workers, "tabs": tabs, "min_text": minText})
+
+		// context with cancellation on SIGINT/SIGTERM
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigch := make(chan os.Signal, 1)
+		signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigch
+			log.Println("hybrid crawler: interrupt received, shutting down...")
+			cancel()
+		}()
+
+		resultsFile := workspace.Path(workspace.HybridResults)
+		results := sink.New(sink.Options{BufferSize: 50, FlushInterval: 30 * time.Second},
+			sink.NewJSON[map[string]interface{}](resultsFile, false))
+		state := newCrawlState()
+		var mu sync.Mutex
+		staticPages, renderedPages := 0, 0
+
+		// save records a page's links and its extracted text, unless another URL already
+		// produced the same content
+		save := func(u string, depth int, doc *goquery.Document, sig classify.Page, pages []extract.Page, crawler string) {
+			frontier.RecordLinks(doc, u, depth)
+			linkGraph.Record(doc, u)
+			var content strings.Builder
+			for _, page := range pages {
+				content.WriteString(page.Content)
+			}
+			// Pages without text are all kept; their shared empty hash says nothing about them
+			if content.Len() > 0 {
+				if first, ok := state.claimContent(chunker.ContentHash(content.String()), u); !ok {
+					audit.Skip(u, "duplicate content of "+first)
+					if verbose {
+						log.Printf("hybrid crawler: %s has the same content as %s", u, first)
+					}
+					return
+				}
+			}
+			results.Add(pageRecords(ctx, sig, pages, fetched(crawler))...)
+			mu.Lock()
+			if crawler == "chromedp" {
+				renderedPages++
+			} else {
+				staticPages++
+			}
+			mu.Unlock()
+		}
+
+		// The browser sends the crawler's User-Agent; the From header is not set for browser requests.
+		// It is started up front so every tab shares one browser process.
+		allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx,
+			append(chromedp.DefaultExecAllocatorOptions[:], chromedp.UserAgent(crawlerIdentity.userAgent))...)
+		defer cancelAlloc()
+		browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+		defer cancelBrowser()
+		browserOK := true
+		if err := chromedp.Run(browserCtx); err != nil {
+			log.Printf("hybrid crawler: browser unavailable, keeping static HTML for JavaScript pages: %v", err)
+			browserOK = false
+		}
+
+		jobs := make(chan frontierEntry, 1024)
+		render := make(chan renderJob, 256)
+
+		fetchWorker := func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			for e := range jobs {
+				u := e.URL
+				if ctx.Err() != nil {
+					audit.Skip(u, skipCanceled)
+					continue
+				}
+				release, err := polite.Acquire(ctx, hostOf(u))
+				if err != nil {
+					audit.Skip(u, skipCanceled)
+					continue
+				}
+				doc, err := fetchAndParse(ctx, u)
+				release()
+				if err != nil {
+					audit.Skip(u, "fetch failed: "+err.Error())
+					if verbose {
+						log.Println("error fetching", u, err)
+					}
+					continue
+				}
+				audit.Fetch(u)
+				// Checked before extraction, which strips the scripts from doc
+				js := needsRendering(doc, minText)
+				sig := classify.Signals(u, doc.Selection)
+				pages := extract.FromDocumentWithOptions(u, doc, extractOpts)
+				if js && browserOK {
+					if verbose {
+						log.Println("hybrid crawler: rendering", u)
+					}
+					render <- renderJob{url: u, depth: e.Depth, doc: doc, sig: sig, static: pages}
+					continue
+				}
+				save(u, e.Depth, doc, sig, pages, "requests")
+			}
+		}
+
+		renderWorker := func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			tabCtx, cancelTab := chromedp.NewContext(browserCtx)
+			defer cancelTab()
+			for j := range render {
+				if ctx.Err() != nil {
+					audit.Skip(j.url, skipCanceled)
+					continue
+				}
+				release, err := polite.Acquire(ctx, hostOf(j.url))
+				if err != nil {
+					audit.Skip(j.url, skipCanceled)
+					continue
+				}
+				navCtx, cancelNav := context.WithTimeout(tabCtx, 30*time.Second)
+				var html string
+				err = chromedp.Run(navCtx,
+					chromedp.Navigate(j.url),
+					chromedp.WaitReady("body", chromedp.ByQuery),
+					chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+				)
+				cancelNav()
+				release()
+				var doc *goquery.Document
+				if err == nil {
+					doc, err = goquery.NewDocumentFromReader(strings.NewReader(html))
+				}
+				if err != nil {
+					log.Printf("hybrid crawler: render failed for %s, keeping static HTML: %v", j.url, err)
+					save(j.url, j.depth, j.doc, j.sig, j.static, "requests")
+					continue
+				}
+				audit.Render(j.url)
+				sig := classify.Signals(j.url, doc.Selection)
+				save(j.url, j.depth, doc, sig, extract.FromDocumentWithOptions(j.url, doc, extractOpts), "chromedp")
+			}
+		}
+
+		if workers < 1 {
+			workers = 1
+		}
+		if tabs < 1 {
+			tabs = 1
+		}
+		var fetchWG, renderWG sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			fetchWG.Add(1)
+			go fetchWorker(&fetchWG)
+		}
+		for i := 0; i < tabs; i++ {
+			renderWG.Add(1)
+			go renderWorker(&renderWG)
+		}
+
+		// Each URL is claimed as it is queued, so duplicates in the input are never fetched
+		interrupted := false
+		for _, e := range entries {
+			if interrupted {
+				break
+			}
+			u := urlutil.Normalize(e.URL)
+			if u == "" {
+				audit.Skip(e.URL, skipInvalidURL)
+				continue
+			}
+			if !state.claim(u) {
+				audit.Skip(u, skipDuplicate)
+				continue
+			}
+			if reason := excludeReason(u); reason != "" {
+				audit.Skip(u, reason)
+				continue
+			}
+			if !robotsAllowed(ctx, u) {
+				audit.Skip(u, robots.ReasonDisallowed)
+				continue
+			}
+			e.URL = u
+			select {
+			case jobs <- e:
+			case <-ctx.Done():
+				interrupted = true
+			}
+		}
+		close(jobs)
+		fetchWG.Wait()
+		close(render)
+		renderWG.Wait()
+
+		if err := results.Close(); err != nil {
+			log.Fatalf("write: %v", err)
+		}
+		log.Printf("hybrid crawler: saved %d pages to %s (%d static, %d rendered)",
+			results.Count(), resultsFile, staticPages, renderedPages)
+		if err := audit.Close(map[string]interface{}{
+			"pages_saved": results.Count(), "static": staticPages, "rendered": renderedPages, "interrupted": ctx.Err() != nil,
+		}); err != nil {
+			log.Printf("hybrid crawler: write audit log: %v", err)
+		} else if audit != nil {
+			log.Printf("hybrid crawler: audit log written to %s", audit.path)
+		}
+	}
+}
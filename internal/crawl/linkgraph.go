@@ -0,0 +1,66 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"kirk-ai/internal/graph"
+	"kirk-ai/internal/workspace"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var defaultLinkGraphPath = workspace.Default(workspace.LinkGraph)
+
+// linkGraphWriter appends each crawled page and its outgoing links to a JSONL file that
+// kirk-ai uses to compute authority scores (see search --authority-weight)
+type linkGraphWriter struct {
+	mu sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+}
+
+// openLinkGraph opens path for appending; an empty path returns nil, which records nothing
+func openLinkGraph(path string) (*linkGraphWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	ensureDir(filepath.Dir(path))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &linkGraphWriter{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Record writes the outgoing links of a crawled page
+func (lg *linkGraphWriter) Record(doc *goquery.Document, pageURL string) {
+	if lg == nil {
+		return
+	}
+	b, err := json.Marshal(graph.Record{URL: pageURL, Links: pageLinks(doc, pageURL)})
+	if err != nil {
+		return
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	lg.w.Write(b)
+	lg.w.WriteByte('\n')
+}
+
+// Close flushes and closes the link graph file
+func (lg *linkGraphWriter) Close() error {
+	if lg == nil {
+		return nil
+	}
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if err := lg.w.Flush(); err != nil {
+		lg.f.Close()
+		return err
+	}
+	return lg.f.Close()
+}
@@ -0,0 +1,266 @@
+package crawl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/workspace"
+
+	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// crawlConfig holds per-host politeness settings and the crawl's scope, shared by the
+// crawlers. Durations use Go syntax ("500ms", "2s").
+type crawlConfig struct {
+	DefaultDelay     string               `json:"default_delay" yaml:"default_delay"`
+	Jitter           float64              `json:"jitter" yaml:"jitter"` // fraction of the delay added at random, e.g. 0.5 = up to +50%
+	Hosts            map[string]hostRules `json:"hosts" yaml:"hosts"`
+	MaxPerHost       int                  `json:"max_per_host,omitempty" yaml:"max_per_host,omitempty"`             // requests in flight to one host at a time
+	IgnoreCrawlDelay bool                 `json:"ignore_crawl_delay,omitempty" yaml:"ignore_crawl_delay,omitempty"` // don't stretch delays to robots.txt Crawl-delay
+	Contact          string               `json:"contact,omitempty" yaml:"contact,omitempty"`                       // operator URL or email added to the User-Agent
+	From             string               `json:"from,omitempty" yaml:"from,omitempty"`                             // operator email sent in the From header
+
+	// Scope of the crawl; a list left out keeps the built-in one (see scopeFlags.open)
+	Seeds        []string `json:"seeds,omitempty" yaml:"seeds,omitempty"`
+	AllowDomains []string `json:"allow_domains,omitempty" yaml:"allow_domains,omitempty"`
+	DenyDomains  []string `json:"deny_domains,omitempty" yaml:"deny_domains,omitempty"`
+	DenyPaths    []string `json:"deny_paths,omitempty" yaml:"deny_paths,omitempty"`
+}
+
+// hostRules overrides politeness settings for a single host
+type hostRules struct {
+	Delay      string   `json:"delay" yaml:"delay"`
+	Jitter     *float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	MaxPerHost int      `json:"max_per_host,omitempty" yaml:"max_per_host,omitempty"`
+}
+
+var defaultCrawlConfigPath = workspace.Default(workspace.CrawlConfig)
+
+// defaultMaxPerHost is how many requests may be in flight to one host when neither the
+// crawl config nor --max-per-host says
+const defaultMaxPerHost = 2
+
+// loadCrawlConfig reads the crawl config, as YAML when the file ends in .yaml or .yml and
+// JSON otherwise; a missing file yields the defaults
+func loadCrawlConfig(path string) (*crawlConfig, error) {
+	cfg := &crawlConfig{DefaultDelay: "200ms", Jitter: 0.5, MaxPerHost: defaultMaxPerHost}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, cfg)
+	default:
+		err = json.Unmarshal(b, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if strings.ContainsAny(cfg.Contact, "()\r\n") {
+		return nil, fmt.Errorf("%s: contact may not contain parentheses or line breaks", path)
+	}
+	if cfg.From != "" && (!strings.Contains(cfg.From, "@") || strings.ContainsAny(cfg.From, " \t\r\n")) {
+		return nil, fmt.Errorf("%s: from must be an email address, got %q", path, cfg.From)
+	}
+	return cfg, nil
+}
+
+// identity is how the crawlers introduce themselves: the User-Agent sent with every page
+// and robots.txt request, and an optional From header
+type identity struct {
+	userAgent string
+	from      string
+}
+
+// crawlerIdentity is set from the crawl config by each crawler before it fetches anything
+var crawlerIdentity = identity{userAgent: extract.DefaultUserAgent}
+
+// setIdentity applies the crawl config's contact details to page and robots.txt requests
+func setIdentity(cfg *crawlConfig) {
+	crawlerIdentity = identity{userAgent: extract.UserAgent(cfg.Contact), from: cfg.From}
+	robotsChecker.Identify(crawlerIdentity.userAgent, crawlerIdentity.from)
+}
+
+// apply sets the identity headers on a request
+func (id identity) apply(h http.Header) {
+	h.Set("User-Agent", id.userAgent)
+	if id.from != "" {
+		h.Set("From", id.from)
+	}
+}
+
+// politeness spaces out requests to each host by a base delay plus random jitter, so
+// parallel workers don't fire in lockstep, stretches the delay to the host's robots.txt
+// Crawl-delay, and caps the requests in flight to each host
+type politeness struct {
+	baseDelay        time.Duration
+	jitter           float64
+	maxPerHost       int
+	ignoreCrawlDelay bool
+	hosts            map[string]hostPolicy
+
+	mu    sync.Mutex
+	next  map[string]time.Time     // earliest time the next request to a host may start
+	slots map[string]chan struct{} // requests in flight to a host, one token each
+	rng   *rand.Rand
+}
+
+type hostPolicy struct {
+	delay      time.Duration
+	jitter     float64
+	maxPerHost int
+}
+
+func newPoliteness(cfg *crawlConfig) (*politeness, error) {
+	base, err := time.ParseDuration(cfg.DefaultDelay)
+	if err != nil {
+		return nil, fmt.Errorf("default_delay: %w", err)
+	}
+	if cfg.MaxPerHost < 0 {
+		return nil, fmt.Errorf("max_per_host must not be negative, got %d", cfg.MaxPerHost)
+	}
+	p := &politeness{
+		baseDelay:        base,
+		jitter:           cfg.Jitter,
+		maxPerHost:       cfg.MaxPerHost,
+		ignoreCrawlDelay: cfg.IgnoreCrawlDelay,
+		hosts:            make(map[string]hostPolicy),
+		next:             make(map[string]time.Time),
+		slots:            make(map[string]chan struct{}),
+		rng:              rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for host, rules := range cfg.Hosts {
+		hp := hostPolicy{delay: base, jitter: cfg.Jitter, maxPerHost: cfg.MaxPerHost}
+		if rules.Delay != "" {
+			d, err := time.ParseDuration(rules.Delay)
+			if err != nil {
+				return nil, fmt.Errorf("hosts.%s.delay: %w", host, err)
+			}
+			hp.delay = d
+		}
+		if rules.Jitter != nil {
+			hp.jitter = *rules.Jitter
+		}
+		if rules.MaxPerHost > 0 {
+			hp.maxPerHost = rules.MaxPerHost
+		}
+		p.hosts[strings.ToLower(host)] = hp
+	}
+	return p, nil
+}
+
+// policy returns the rules for host, matching "www." and subdomains of configured hosts
+func (p *politeness) policy(host string) hostPolicy {
+	host = strings.ToLower(host)
+	for h := host; h != ""; {
+		if hp, ok := p.hosts[h]; ok {
+			return hp
+		}
+		i := strings.IndexByte(h, '.')
+		if i < 0 {
+			break
+		}
+		h = h[i+1:]
+	}
+	return hostPolicy{delay: p.baseDelay, jitter: p.jitter, maxPerHost: p.maxPerHost}
+}
+
+// effective is policy with the delay raised to the Crawl-delay of host's robots.txt, which
+// the crawlers check before fetching from a host
+func (p *politeness) effective(host string) hostPolicy {
+	hp := p.policy(host)
+	if p.ignoreCrawlDelay || robotsChecker == nil {
+		return hp
+	}
+	if d := robotsChecker.CrawlDelay(robotsUserAgent, host); d > hp.delay {
+		hp.delay = d
+	}
+	return hp
+}
+
+// Acquire waits for one of host's request slots and then for its delay, and returns the
+// function that frees the slot once the request is done
+func (p *politeness) Acquire(ctx context.Context, host string) (func(), error) {
+	hp := p.policy(host)
+	if hp.maxPerHost <= 0 {
+		return func() {}, p.Wait(ctx, host)
+	}
+	p.mu.Lock()
+	slots, ok := p.slots[host]
+	if !ok {
+		slots = make(chan struct{}, hp.maxPerHost)
+		p.slots[host] = slots
+	}
+	p.mu.Unlock()
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-slots }
+	if err := p.Wait(ctx, host); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// Wait blocks until a request to host may be made, reserving the following slot for the next caller
+func (p *politeness) Wait(ctx context.Context, host string) error {
+	hp := p.effective(host)
+
+	p.mu.Lock()
+	now := time.Now()
+	start := p.next[host]
+	if start.Before(now) {
+		start = now
+	}
+	delay := hp.delay
+	if hp.jitter > 0 {
+		delay += time.Duration(p.rng.Float64() * hp.jitter * float64(hp.delay))
+	}
+	p.next[host] = start.Add(delay)
+	p.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		audit.Wait(host, wait)
+	}
+	select {
+	case <-time.After(time.Until(start)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// collyRules converts the per-host settings into colly limit rules
+func (p *politeness) collyRules(parallel int) []*colly.LimitRule {
+	rules := make([]*colly.LimitRule, 0, len(p.hosts))
+	for host, hp := range p.hosts {
+		n := parallel
+		if hp.maxPerHost > 0 && hp.maxPerHost < n {
+			n = hp.maxPerHost
+		}
+		rules = append(rules, &colly.LimitRule{
+			DomainGlob:  "*" + host,
+			Parallelism: n,
+			Delay:       hp.delay,
+			RandomDelay: time.Duration(hp.jitter * float64(hp.delay)),
+		})
+	}
+	return rules
+}
@@ -0,0 +1,663 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"kirk-ai/internal/classify"
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/license"
+	"kirk-ai/internal/progress"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/robots"
+	"kirk-ai/internal/sink"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/pflag"
+)
+
+// shared http client with timeout and connection reuse
+var httpClient = &http.Client{
+	Timeout: 20 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// robotsUserAgent is the agent the crawlers identify as when honoring robots.txt
+const robotsUserAgent = "kirk-ai-crawler/1.0"
+
+// robotsChecker is shared by the crawler tools; each tool replaces it once its flags are parsed
+var robotsChecker = robots.New(httpClient, workspace.Default(workspace.RobotsCache))
+
+// useWorkspaceRobots points robotsChecker at the workspace's robots.txt cache, for the tools
+// without a --robots-cache flag
+func useWorkspaceRobots() {
+	robotsChecker = robots.New(httpClient, workspace.Path(workspace.RobotsCache))
+}
+
+// hostOf returns the host of a normalized URL, or "" when it cannot be parsed
+func hostOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// pageRecord converts an extracted page into a result record; overflow parts carry their position
+func pageRecord(page extract.Page, prov provenance.Record) map[string]interface{} {
+	r := map[string]interface{}{
+		"url":                  page.URL,
+		"title":                page.Title,
+		"content":              page.Content,
+		provenance.MetadataKey: prov.Map(),
+	}
+	if !page.License.IsZero() {
+		r[license.MetadataKey] = page.License.Map()
+	}
+	if page.Parts > 1 {
+		r["part"] = page.Part
+		r["parts"] = page.Parts
+	}
+	return r
+}
+
+// isHTMLResponse checks content-type header
+func isHTMLResponse(resp *http.Response) bool {
+	ct := resp.Header.Get("Content-Type")
+	return strings.Contains(ct, "text/html")
+}
+
+// simple error type to avoid fmt import
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+// fetchAndParse now accepts a context and does retries + content-type check
+func fetchAndParse(ctx context.Context, u string) (*goquery.Document, error) {
+	page, err := fetchIfModified(ctx, u, validators{})
+	if err != nil {
+		return nil, err
+	}
+	return page.doc, nil
+}
+
+// validators are the cache validators a server sent with a page
+type validators struct {
+	ETag         string
+	LastModified string
+}
+
+// errNotModified is returned by fetchIfModified when the page has not changed since the
+// validators were sent
+var errNotModified = errorString("not modified")
+
+// fetchedPage is a page fetched in full
+type fetchedPage struct {
+	doc        *goquery.Document
+	validators validators     // the validators the server sent with the page
+	body       []byte         // the HTML as received
+	resp       *http.Response // status line and headers; the body is already read
+	at         time.Time
+}
+
+// fetchIfModified is fetchAndParse as a conditional request: with validators from an earlier
+// fetch it asks for the page only if it changed, returning errNotModified when it did not
+func fetchIfModified(ctx context.Context, u string, prev validators) (*fetchedPage, error) {
+	var lastErr error
+	backoff := 500 * time.Millisecond
+	for attempt := 0; attempt < 3; attempt++ {
+		req, _ := http.NewRequestWithContext(ctx, "GET", u, nil)
+		crawlerIdentity.apply(req.Header)
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+		at := time.Now()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+
+		if resp.StatusCode == http.StatusNotModified && (prev.ETag != "" || prev.LastModified != "") {
+			resp.Body.Close()
+			return nil, errNotModified
+		}
+		// ensure body closed and skip non-HTML/status
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, &url.Error{Op: "GET", URL: u, Err: errorString("status " + resp.Status)}
+		}
+		if !isHTMLResponse(resp) {
+			resp.Body.Close()
+			return nil, &url.Error{Op: "GET", URL: u, Err: errorString("non-html content")}
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return &fetchedPage{
+			doc:        doc,
+			validators: validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")},
+			body:       body,
+			resp:       resp,
+			at:         at,
+		}, nil
+	}
+	return nil, lastErr
+}
+
+// maxBFSPages caps the link-following crawl
+const maxBFSPages = 500
+
+// requestsCrawler fetches the --urls file, or follows links from the default seeds, with plain
+// HTTP requests
+func requestsCrawler(fs *pflag.FlagSet) func(verbose bool) {
+	var urlFile string
+	var workers int
+	var crawlConfigPath string
+	var jitter float64
+	var maxPerHost int
+	var robotsCachePath string
+	var frontierPath string
+	var linkGraphPath string
+	var auditDir string
+	var statePath string
+	var resume bool
+	var httpCachePath string
+	var changesPath string
+	var saveRaw bool
+	var warcPath string
+	var dryRun bool
+	extractOpts := extract.DefaultOptions()
+	fs.StringVar(&urlFile, "urls", "", "file with URLs to fetch, plain text or JSONL frontier (each URL fetched once)")
+	fs.IntVar(&workers, "workers", 4, "number of parallel fetch workers for requests crawler when --urls is used")
+	fs.StringVar(&crawlConfigPath, "crawl-config", defaultCrawlConfigPath, "crawl config with per-host delays, seeds, and domain filters (JSON, or YAML for .yaml and .yml files)")
+	fs.Float64Var(&jitter, "jitter", -1, "random jitter as a fraction of the per-host delay (overrides crawl config when >= 0)")
+	fs.IntVar(&maxPerHost, "max-per-host", 0, "most requests in flight to one host at a time (overrides the crawl config's max_per_host when > 0; default 2)")
+	fs.StringVar(&robotsCachePath, "robots-cache", workspace.Default(workspace.RobotsCache), "robots.txt cache file shared across crawler processes (empty disables)")
+	fs.StringVar(&frontierPath, "frontier", defaultFrontierPath, "append discovered URLs with depth, referrer, and discovery method to this JSONL file (empty disables)")
+	fs.StringVar(&linkGraphPath, "link-graph", defaultLinkGraphPath, "append each page's outgoing links to this JSONL file for authority scoring (empty disables)")
+	fs.StringVar(&auditDir, "audit-dir", defaultAuditDir, "write each run's robots decisions, politeness waits, and skipped URLs to a JSONL file here (empty disables)")
+	fs.IntVar(&extractOpts.MaxLength, "max-content", extract.MaxContentLength, "maximum characters of content kept per page (0 = unlimited)")
+	fs.StringVar(&extractOpts.Truncation, "truncate", extract.TruncateSentence, "how to cap long pages: sentence, hard, or overflow (split into several records)")
+	fs.StringVar(&statePath, "state", defaultRequestsStatePath, "record the crawl queue and saved pages in this JSONL file as the crawl goes, for --resume; removed when the crawl finishes (empty disables)")
+	fs.BoolVar(&resume, "resume", false, "continue an interrupted crawl from --state: keep its saved pages, skip the URLs already saved, and fetch the rest of its queue")
+	fs.StringVar(&httpCachePath, "http-cache", defaultHTTPCachePath, "remember each page's ETag and Last-Modified here and re-fetch pages only if they changed, keeping the last crawl's records for the rest (empty disables)")
+	fs.StringVar(&changesPath, "changes", defaultChangeLogPath, "append whether each page is new, changed, or unchanged since the last crawl to this JSONL file (empty disables)")
+	fs.BoolVar(&saveRaw, "raw", false, "also save each page's HTML to raw_html/ in the workspace, so process content can extract it again without fetching it")
+	fs.StringVar(&warcPath, "warc", "", "also append each page to this WARC file as a response record with its HTTP headers and fetch time; a .gz file is compressed per record")
+	fs.BoolVar(&dryRun, "dry-run", false, "resolve seeds, apply filters and robots.txt, and print what would be fetched without crawling or writing files")
+	classifyOpts := addClassifyFlags(fs)
+	scopeOpts := addScopeFlags(fs)
+	return func(verbose bool) {
+
+		if err := extractOpts.Validate(); err != nil {
+			log.Fatalf("requests crawler: %v", err)
+		}
+		if err := classifyOpts.open(); err != nil {
+			log.Fatalf("requests crawler: %v", err)
+		}
+		cfg, err := loadCrawlConfig(crawlConfigPath)
+		if err != nil {
+			log.Fatalf("requests crawler: %v", err)
+		}
+		if err := scopeOpts.open(cfg); err != nil {
+			log.Fatalf("requests crawler: %v", err)
+		}
+		if jitter >= 0 {
+			cfg.Jitter = jitter
+		}
+		if maxPerHost > 0 {
+			cfg.MaxPerHost = maxPerHost
+		}
+		polite, err := newPoliteness(cfg)
+		if err != nil {
+			log.Fatalf("requests crawler: crawl config: %v", err)
+		}
+
+		if dryRun {
+			// Skip the shared robots cache so a dry run leaves no files behind
+			robotsChecker = robots.New(httpClient, "")
+			setIdentity(cfg)
+			seeds := crawlScope.seeds
+			note := fmt.Sprintf("Without --urls the crawler also follows links from these seeds within %s, up to %d pages",
+				strings.Join(crawlScope.confined(seeds).allow, ", "), maxBFSPages)
+			if urlFile != "" {
+				entries, err := readFrontier(urlFile)
+				if err != nil {
+					log.Fatalf("could not read urls file: %v", err)
+				}
+				seeds = make([]string, 0, len(entries))
+				for _, e := range entries {
+					seeds = append(seeds, e.URL)
+				}
+				note = ""
+			}
+			planCrawl(context.Background(), seeds).Print(polite, note)
+			return
+		}
+
+		runID := provenance.NewRunID()
+		fetched := func() provenance.Record {
+			return provenance.Record{CrawlRunID: runID, Crawler: "requests", FetchedAt: provenance.Now()}
+		}
+		if verbose {
+			log.Println("requests crawler: run", runID)
+		}
+		robotsChecker = robots.New(httpClient, robotsCachePath)
+		setIdentity(cfg)
+		frontier, err := openFrontier(frontierPath)
+		if err != nil {
+			log.Fatalf("requests crawler: open frontier: %v", err)
+		}
+		defer frontier.Close()
+		linkGraph, err := openLinkGraph(linkGraphPath)
+		if err != nil {
+			log.Fatalf("requests crawler: open link graph: %v", err)
+		}
+		defer linkGraph.Close()
+		audit, err = openAudit(auditDir, "requests", runID)
+		if err != nil {
+			log.Fatalf("requests crawler: open audit log: %v", err)
+		}
+		audit.Start(cfg, map[string]interface{}{"urls": urlFile, "workers": workers, "resume": resume})
+		state, prev, err := openStateFile(statePath, resume)
+		if err != nil {
+			log.Fatalf("requests crawler: open crawl state: %v", err)
+		}
+		if resume && !prev.found {
+			log.Printf("requests crawler: no crawl state at %s; starting over", statePath)
+		}
+
+		// context with cancellation on SIGINT/SIGTERM
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigch := make(chan os.Signal, 1)
+		signal.Notify(sigch, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigch
+			log.Println("requests crawler: interrupt received, shutting down...")
+			cancel()
+		}()
+
+		// A progress line replaces the per-URL logging of -v; log messages print above it
+		var bar *progress.Bar
+		if !verbose {
+			bar = progress.New(os.Stderr, "Crawling pages", 0)
+			log.SetOutput(bar)
+		}
+
+		// results sink shared by the workers; flushed periodically so an interrupted crawl keeps its pages
+		resultsFile := workspace.Path(workspace.RequestsResults)
+		results := sink.New(sink.Options{BufferSize: 50, FlushInterval: 30 * time.Second},
+			sink.NewJSON[map[string]interface{}](resultsFile, false))
+		cache, err := openHTTPCache(httpCachePath, changesPath, resultsFile, runID)
+		if err != nil {
+			log.Fatalf("requests crawler: open HTTP cache: %v", err)
+		}
+		rawDir := ""
+		if saveRaw {
+			rawDir = workspace.Path(workspace.RawHTML)
+		}
+		raw, err := openRawArchive(rawDir)
+		if err != nil {
+			log.Fatalf("requests crawler: open raw HTML directory: %v", err)
+		}
+		warc, err := openWARC(warcPath, runID)
+		if err != nil {
+			log.Fatalf("requests crawler: open WARC file: %v", err)
+		}
+		// archive keeps the page as received besides the records extracted from it
+		archive := func(u string, page *fetchedPage) {
+			if err := raw.Save(u, page); err != nil {
+				log.Printf("requests crawler: save raw HTML of %s: %v", u, err)
+			}
+			if err := warc.Write(u, page); err != nil {
+				log.Printf("requests crawler: write WARC record for %s: %v", u, err)
+			}
+		}
+		if prev.found {
+			saved, err := readPreviousResults(resultsFile)
+			if err != nil {
+				log.Fatalf("requests crawler: resume: %v", err)
+			}
+			results.Add(saved...)
+			prev.keepSaved(saved)
+			log.Printf("requests crawler: resuming with %d pages saved and %d URLs queued", len(prev.done), len(prev.pending()))
+		}
+		saveResults := func() {
+			if bar != nil {
+				log.SetOutput(os.Stderr)
+				log.Println("requests crawler:", bar.Finish())
+			}
+			if err := results.Close(); err != nil {
+				log.Fatalf("write: %v", err)
+			}
+			log.Printf("requests crawler: saved %d pages to %s", results.Count(), resultsFile)
+			if err := raw.Close(); err != nil {
+				log.Printf("requests crawler: close raw HTML mapping: %v", err)
+			}
+			if err := warc.Close(); err != nil {
+				log.Printf("requests crawler: close WARC file: %v", err)
+			}
+			if err := cache.Close(); err != nil {
+				log.Printf("requests crawler: save HTTP cache: %v", err)
+			} else if cache != nil {
+				log.Printf("requests crawler: %s", cache.Summary())
+			}
+			if err := state.Close(ctx.Err() == nil); err != nil {
+				log.Printf("requests crawler: close crawl state: %v", err)
+			} else if state != nil && ctx.Err() != nil {
+				log.Printf("requests crawler: crawl state kept in %s; rerun with --resume to continue", statePath)
+			}
+			if err := audit.Close(map[string]interface{}{"pages_saved": results.Count(), "interrupted": ctx.Err() != nil}); err != nil {
+				log.Printf("requests crawler: write audit log: %v", err)
+			} else if audit != nil {
+				log.Printf("requests crawler: audit log written to %s", audit.path)
+			}
+		}
+
+		// Buffered jobs; requests are spaced per host by the politeness delay
+		jobs := make(chan string, 1024)
+
+		// depth of each input URL, used to record the depth of links discovered from it
+		inputDepth := make(map[string]int)
+
+		// worker function using fetchAndParse
+		worker := func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			for u := range jobs {
+				select {
+				case <-ctx.Done():
+					audit.Skip(u, skipCanceled)
+					bar.Add(1)
+					continue
+				default:
+				}
+				u = urlutil.Normalize(u)
+				if u == "" {
+					bar.Add(1)
+					continue
+				}
+				if reason := excludeReason(u); reason != "" {
+					audit.Skip(u, reason)
+					if verbose {
+						log.Println("requests crawler: skipping excluded URL:", u)
+					}
+					bar.Add(1)
+					continue
+				}
+				// robots.txt may have changed since the URL was queued
+				if !robotsAllowed(ctx, u) {
+					audit.Skip(u, robots.ReasonDisallowed)
+					if verbose {
+						log.Println("requests crawler: disallowed by robots.txt:", u)
+					}
+					bar.Add(1)
+					continue
+				}
+				release, err := polite.Acquire(ctx, hostOf(u))
+				if err != nil {
+					audit.Skip(u, skipCanceled)
+					bar.Add(1)
+					continue
+				}
+				page, err := fetchIfModified(ctx, u, cache.Validators(u))
+				release()
+				if err == errNotModified {
+					// Unchanged since the last crawl: keep what it saved
+					audit.Fetch(u)
+					bar.Add(1)
+					records, _ := cache.NotModified(u)
+					results.Add(records...)
+					state.Done(u)
+					continue
+				}
+				if err != nil {
+					audit.Skip(u, "fetch failed: "+err.Error())
+					if verbose {
+						log.Println("error fetching", u, err)
+					}
+					bar.Fail(1)
+					continue
+				}
+				audit.Fetch(u)
+				bar.Add(1)
+				prov := fetched()
+				doc := page.doc
+				frontier.RecordLinks(doc, u, inputDepth[u])
+				linkGraph.Record(doc, u)
+				sig := classify.Signals(u, doc.Selection)
+				records := pageRecords(ctx, sig, extract.FromDocumentWithOptions(u, doc, extractOpts), prov)
+				results.Add(records...)
+				archive(u, page)
+				cache.Store(u, page.validators, records, docLinks(doc, u))
+				state.Done(u)
+			}
+		}
+
+		// start workers when urls file provided
+		if urlFile != "" {
+			entries, err := readFrontier(urlFile)
+			if err != nil {
+				log.Fatalf("could not read urls file: %v", err)
+			}
+			urls := make([]string, 0, len(entries))
+			for _, e := range entries {
+				if n := urlutil.Normalize(e.URL); n != "" {
+					if _, ok := inputDepth[n]; !ok {
+						inputDepth[n] = e.Depth
+					}
+				}
+				urls = append(urls, e.URL)
+			}
+			var wg sync.WaitGroup
+			if workers < 1 {
+				workers = 1
+			}
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go worker(&wg)
+			}
+			// deduplicate as we push, avoid enqueuing same URL twice
+			seen := make(map[string]struct{})
+			breakEnqueue := false
+			for _, raw := range urls {
+				u := urlutil.Normalize(raw)
+				if u == "" {
+					audit.Skip(raw, skipInvalidURL)
+					continue
+				}
+				if _, ok := seen[u]; ok {
+					audit.Skip(u, skipDuplicate)
+					continue
+				}
+				seen[u] = struct{}{}
+				if prev.done[u] {
+					continue
+				}
+				if reason := excludeReason(u); reason != "" {
+					audit.Skip(u, reason)
+					if verbose {
+						log.Println("requests crawler: skipping excluded URL from input list:", u)
+					}
+					continue
+				}
+				if !robotsAllowed(ctx, u) {
+					audit.Skip(u, robots.ReasonDisallowed)
+					if verbose {
+						log.Println("requests crawler: disallowed by robots.txt from input list:", u)
+					}
+					continue
+				}
+				bar.AddTotal(1)
+				select {
+				case jobs <- u:
+				case <-ctx.Done():
+					breakEnqueue = true
+				}
+				if breakEnqueue {
+					break
+				}
+			}
+			close(jobs)
+			wg.Wait()
+			saveResults()
+			return
+		}
+
+		// Fallback: improved BFS single-process crawler with dedup-on-enqueue and normalization
+		start := crawlScope.seeds
+		crawlScope = crawlScope.confined(start)
+		visited := map[string]struct{}{}
+		enqueued := map[string]struct{}{}
+		skipped := map[string]struct{}{} // links already rejected, so each is checked and audited once
+		depth := map[string]int{}
+		queue := make([]string, 0)
+		// A resumed crawl starts from the queue it left, with its saved pages counted as visited
+		for u := range prev.done {
+			visited[u] = struct{}{}
+			enqueued[u] = struct{}{}
+		}
+		for _, e := range prev.pending() {
+			queue = append(queue, e.URL)
+			enqueued[e.URL] = struct{}{}
+			depth[e.URL] = e.Depth
+		}
+		if len(queue) == 0 && len(visited) == 0 {
+			for _, s := range start {
+				n := urlutil.Normalize(s)
+				if n != "" {
+					queue = append(queue, n)
+					enqueued[n] = struct{}{}
+					frontier.Record(n, 0, "", discoveredSeed)
+					state.Queued(n, 0)
+				}
+			}
+		}
+		for len(queue) > 0 && len(visited) < maxBFSPages {
+			if ctx.Err() != nil {
+				break
+			}
+			u := queue[0]
+			queue = queue[1:]
+			if _, ok := visited[u]; ok {
+				continue
+			}
+			if err := polite.Wait(ctx, hostOf(u)); err != nil {
+				queue = append([]string{u}, queue...)
+				break
+			}
+			page, err := fetchIfModified(ctx, u, cache.Validators(u))
+			var links []string
+			if err == errNotModified {
+				// Unchanged since the last crawl: keep what it saved and follow the links it had
+				var records []map[string]interface{}
+				records, links = cache.NotModified(u)
+				results.Add(records...)
+			} else if err != nil {
+				audit.Skip(u, "fetch failed: "+err.Error())
+				if verbose {
+					log.Println("error fetching", u, err)
+				}
+				bar.Fail(1)
+				continue
+			} else {
+				prov := fetched()
+				doc := page.doc
+				linkGraph.Record(doc, u)
+				sig := classify.Signals(u, doc.Selection)
+				records := pageRecords(ctx, sig, extract.FromDocumentWithOptions(u, doc, extractOpts), prov)
+				results.Add(records...)
+				archive(u, page)
+				links = docLinks(doc, u)
+				cache.Store(u, page.validators, records, links)
+			}
+			audit.Fetch(u)
+			bar.Add(1)
+			visited[u] = struct{}{}
+
+			// Enqueue links (check scope and robots, and dedupe on enqueue)
+			for _, abs := range links {
+				if _, seen := visited[abs]; seen {
+					continue
+				}
+				if _, enq := enqueued[abs]; enq {
+					continue
+				}
+				if _, skip := skipped[abs]; skip {
+					continue
+				}
+				if reason := excludeReason(abs); reason != "" {
+					skipped[abs] = struct{}{}
+					audit.Skip(abs, reason)
+					continue
+				}
+				if !robotsAllowed(ctx, abs) {
+					skipped[abs] = struct{}{}
+					audit.Skip(abs, robots.ReasonDisallowed)
+					continue
+				}
+				enqueued[abs] = struct{}{}
+				depth[abs] = depth[u] + 1
+				frontier.Record(abs, depth[abs], u, discoveredLink)
+				state.Queued(abs, depth[abs])
+				queue = append(queue, abs)
+			}
+			// Recorded after the page's links, so a resumed crawl has them queued
+			state.Done(u)
+		}
+
+		// Whatever is still queued was found but never fetched
+		reason := skipPageLimit
+		if ctx.Err() != nil {
+			reason = skipCanceled
+		}
+		for _, u := range queue {
+			if _, ok := visited[u]; !ok {
+				audit.Skip(u, reason)
+			}
+		}
+
+		saveResults()
+	}
+}
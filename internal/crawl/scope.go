@@ -0,0 +1,206 @@
+package crawl
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// defaultSeeds start the link-following crawl when neither --seeds nor the crawl config
+// names any
+var defaultSeeds = []string{"https://tpusa.com/", "https://tpusa.com/about/"}
+
+// defaultDenyDomains and defaultDenyPaths keep the crawl off the Rumble channel that TPUSA
+// pages link to, unless the crawl config or flags give their own lists
+var (
+	defaultDenyDomains = []string{"rumble.com"}
+	defaultDenyPaths   = []string{"/c/turningpointusa"}
+)
+
+// skipCrawlRE matches assets and other paths that are never worth fetching as pages
+var skipCrawlRE = regexp.MustCompile(`(?i)\.(pdf|jpg|jpeg|png|gif|css|js|ico|svg|woff2?|zip)$|/wp-admin/|/wp-content/|/feed/|mailto:|/rss/|\#`)
+
+// scope decides which URLs a crawl may fetch and where a link-following crawl starts
+type scope struct {
+	seeds     []string
+	allow     []string         // domains links may lead to, with their subdomains (empty allows any)
+	deny      []string         // domains never fetched, with their subdomains
+	denyPaths []*regexp.Regexp // path patterns never fetched
+}
+
+// crawlScope is shared by the crawler tools; each tool replaces it once its flags are parsed
+var crawlScope = mustScope(defaultSeeds, nil, defaultDenyDomains, defaultDenyPaths)
+
+// newScope normalizes the domains and compiles the deny paths. A deny path matches a URL
+// path that starts with it, where '*' matches any run of characters.
+func newScope(seeds, allow, deny, denyPaths []string) (*scope, error) {
+	s := &scope{allow: normalizeDomains(allow), deny: normalizeDomains(deny)}
+	for _, raw := range seeds {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("seed %q is not an absolute http(s) URL", raw)
+		}
+		s.seeds = append(s.seeds, u.String())
+	}
+	for _, p := range denyPaths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "*") {
+			return nil, fmt.Errorf("deny path %q must start with / or *", p)
+		}
+		parts := strings.Split(p, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		s.denyPaths = append(s.denyPaths, regexp.MustCompile("(?i)^"+strings.Join(parts, ".*")))
+	}
+	return s, nil
+}
+
+func mustScope(seeds, allow, deny, denyPaths []string) *scope {
+	s, err := newScope(seeds, allow, deny, denyPaths)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// normalizeDomains lowercases domains and strips schemes, paths, and a leading "www."
+func normalizeDomains(domains []string) []string {
+	var out []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if i := strings.Index(d, "://"); i >= 0 {
+			d = d[i+3:]
+		}
+		if i := strings.IndexAny(d, "/:"); i >= 0 {
+			d = d[:i]
+		}
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "www."), ".")
+		if d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// matchesDomain reports whether host is one of domains or a subdomain of one
+func matchesDomain(host string, domains []string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// confined returns the scope a link-following crawl from starts uses: without allowed
+// domains of its own, links may only lead to the domains of the start URLs
+func (s *scope) confined(starts []string) *scope {
+	if len(s.allow) > 0 {
+		return s
+	}
+	c := *s
+	c.allow = normalizeDomains(hostsOf(starts))
+	return &c
+}
+
+// hostsOf returns the distinct hosts of urls in order
+func hostsOf(urls []string) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" || seen[u.Hostname()] {
+			continue
+		}
+		seen[u.Hostname()] = true
+		hosts = append(hosts, u.Hostname())
+	}
+	return hosts
+}
+
+// excludeReason says which filter rejects raw, or "" when it may be crawled
+func (s *scope) excludeReason(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return skipInvalidURL
+	}
+	host := parsed.Hostname()
+	if matchesDomain(host, s.deny) {
+		return "excluded host"
+	}
+	if len(s.allow) > 0 && !matchesDomain(host, s.allow) {
+		return "outside allowed domains"
+	}
+	for _, re := range s.denyPaths {
+		if re.MatchString(parsed.Path) {
+			return "excluded path"
+		}
+	}
+	// skip common static asset patterns and other unwanted paths
+	if skipCrawlRE.MatchString(raw) {
+		return "asset or unwanted path"
+	}
+	return ""
+}
+
+// isCrawlable returns false for assets, excluded hosts and paths, and other known non-HTML patterns
+func isCrawlable(raw string) bool {
+	return excludeReason(raw) == ""
+}
+
+// excludeReason checks raw against the crawl's scope
+func excludeReason(raw string) string {
+	return crawlScope.excludeReason(raw)
+}
+
+// scopeFlags holds the seed and domain flags shared by the link-following crawlers
+type scopeFlags struct {
+	seeds, allow, deny, denyPaths []string
+	fs                            *pflag.FlagSet
+}
+
+// addScopeFlags registers the seed and domain flags; call open once they are parsed
+func addScopeFlags(fs *pflag.FlagSet) *scopeFlags {
+	f := &scopeFlags{fs: fs}
+	fs.StringSliceVar(&f.seeds, "seeds", nil, "start URLs of a link-following crawl, comma-separated or repeated (overrides the crawl config's seeds)")
+	fs.StringSliceVar(&f.allow, "allow-domains", nil, "domains links may lead to, with their subdomains (overrides the crawl config; default: the seeds' domains)")
+	fs.StringSliceVar(&f.deny, "deny-domains", nil, "domains never fetched, with their subdomains (overrides the crawl config)")
+	fs.StringSliceVar(&f.denyPaths, "deny-paths", nil, "URL paths never fetched: a path prefix, where * matches any run of characters (overrides the crawl config)")
+	return f
+}
+
+// open sets crawlScope from the crawl config, overridden by the flags that were given, and
+// falls back to the built-in TPUSA scope for anything neither sets
+func (f *scopeFlags) open(cfg *crawlConfig) error {
+	pick := func(flag string, fromFlag, fromConfig, builtIn []string) []string {
+		switch {
+		case f.fs.Changed(flag):
+			return fromFlag
+		case fromConfig != nil:
+			return fromConfig
+		}
+		return builtIn
+	}
+	s, err := newScope(
+		pick("seeds", f.seeds, cfg.Seeds, defaultSeeds),
+		pick("allow-domains", f.allow, cfg.AllowDomains, nil),
+		pick("deny-domains", f.deny, cfg.DenyDomains, defaultDenyDomains),
+		pick("deny-paths", f.denyPaths, cfg.DenyPaths, defaultDenyPaths),
+	)
+	if err != nil {
+		return err
+	}
+	if len(s.seeds) == 0 {
+		return fmt.Errorf("no seed URLs: give --seeds or list seeds in the crawl config")
+	}
+	crawlScope = s
+	return nil
+}
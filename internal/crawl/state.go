@@ -0,0 +1,184 @@
+package crawl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"kirk-ai/internal/sink"
+	"kirk-ai/internal/workspace"
+)
+
+var defaultRequestsStatePath = workspace.Default(workspace.RequestsState)
+
+// stateEntry is one line of a crawl state file: a URL queued at a depth, or a URL whose page
+// was saved
+type stateEntry struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth,omitempty"`
+	Done  bool   `json:"done,omitempty"`
+}
+
+// stateFile appends the queue and the saved pages of a crawl to a JSONL file as it goes, so
+// an interrupted crawl can pick up where it stopped. Each line is written straight to the
+// file; a crash loses at most the line being written.
+type stateFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// resumedCrawl is what an earlier run left in the state file
+type resumedCrawl struct {
+	found  bool            // a state file was read
+	queued []frontierEntry // URLs queued, in the order they were first queued
+	done   map[string]bool // URLs whose pages were saved
+}
+
+// openStateFile starts the state file at path over, or with resume continues it and
+// returns what it holds. A missing file resumes nothing. An empty path returns nil, which
+// records nothing.
+func openStateFile(path string, resume bool) (*stateFile, *resumedCrawl, error) {
+	prev := &resumedCrawl{done: make(map[string]bool)}
+	if path == "" {
+		return nil, prev, nil
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if err := prev.read(path); err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		ensureDir(path[:i])
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &stateFile{path: path, f: f}, prev, nil
+}
+
+// read loads the state file at path. A line cut short by a crash is ignored.
+func (r *resumedCrawl) read(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r.found = true
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e stateEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.URL == "" {
+			continue
+		}
+		if e.Done {
+			r.done[e.URL] = true
+		} else if !seen[e.URL] {
+			seen[e.URL] = true
+			r.queued = append(r.queued, frontierEntry{URL: e.URL, Depth: e.Depth})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read crawl state %s: %w", path, err)
+	}
+	return nil
+}
+
+// keepSaved forgets saved URLs whose pages are missing from records, as when the crawler was
+// killed before its results were flushed, so they are fetched again
+func (r *resumedCrawl) keepSaved(records []map[string]interface{}) {
+	inResults := make(map[string]bool, len(records))
+	for _, rec := range records {
+		if u, ok := rec["url"].(string); ok {
+			inResults[u] = true
+		}
+	}
+	for u := range r.done {
+		if !inResults[u] {
+			delete(r.done, u)
+		}
+	}
+}
+
+// pending returns the queued URLs whose pages were not saved
+func (r *resumedCrawl) pending() []frontierEntry {
+	var out []frontierEntry
+	for _, e := range r.queued {
+		if !r.done[e.URL] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (s *stateFile) append(e stateEntry) {
+	if s == nil {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.f.Write(append(b, '\n'))
+}
+
+// Queued records a URL added to the crawl queue at depth
+func (s *stateFile) Queued(u string, depth int) {
+	s.append(stateEntry{URL: u, Depth: depth})
+}
+
+// Done records a URL whose page was saved, so a resumed crawl does not fetch it again
+func (s *stateFile) Done(u string) {
+	s.append(stateEntry{URL: u, Done: true})
+}
+
+// Close closes the state file, removing it when the crawl finished so the next run starts
+// fresh
+func (s *stateFile) Close(finished bool) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if finished {
+		return os.Remove(s.path)
+	}
+	return nil
+}
+
+// readPreviousResults returns the page records saved at path by an earlier run: the partial
+// results a crashed run left next to path when there are any, else the results file. A
+// missing file returns nothing.
+func readPreviousResults(path string) ([]map[string]interface{}, error) {
+	records, err := sink.ReadPartial[map[string]interface{}](path)
+	if err == nil {
+		return records, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return records, nil
+}
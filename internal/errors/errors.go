@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // APIError represents an error from the Ollama API
 type APIError struct {
@@ -59,3 +62,24 @@ func NewValidationError(field, message string) *ValidationError {
 		Message: message,
 	}
 }
+
+// AsAPIError returns the APIError in err's chain, so one wrapped with %w is found too
+func AsAPIError(err error) (*APIError, bool) {
+	var e *APIError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// IsRetryable reports whether err, or an error it wraps, is transient: a failure to reach
+// the server, a rate limit, or a server-side error. Validation errors and other API errors
+// are not retried.
+func IsRetryable(err error) bool {
+	var netErr *NetworkError
+	if errors.As(err, &netErr) {
+		return netErr.Operation == "send request" || netErr.Operation == "read response"
+	}
+	if e, ok := AsAPIError(err); ok {
+		return e.StatusCode == 429 || e.StatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,454 @@
+package extract
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Article is the main content of a page as a reader would see it, without the navigation,
+// sidebars, share buttons, and comment sections around it
+type Article struct {
+	Title     string
+	Byline    string
+	Published string // RFC 3339, or a bare date, when the page's date could be read; else as the page gives it
+	Content   string // the article body's text, blocks separated by blank lines
+	// Node is the article body within the document, for locating headings and images in it
+	Node *goquery.Selection
+}
+
+var (
+	// unlikelyRE and maybeRE, after Readability, match the class and id of page furniture and
+	// of containers that may still hold the article
+	unlikelyRE = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|share|newsletter|subscribe|cookie`)
+	maybeRE    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	// positiveRE and negativeRE weigh a container's class and id for or against it
+	positiveRE = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeRE = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	bylineRE   = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+	byPrefixRE = regexp.MustCompile(`(?i)^\s*(by|written by|posted by)\s+`)
+	sentenceRE = regexp.MustCompile(`\.( |$)`)
+)
+
+// blockTags separate blocks of text; the rest run inline
+var blockTags = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"figcaption": true, "figure": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"hr": true, "li": true, "main": true, "ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"td": true, "th": true, "tr": true, "ul": true, "br": true,
+}
+
+// Readable finds the article in doc, scoring blocks of text by their length, commas, and
+// link density and the containers around them by class and tag, as Readability does. It
+// removes page furniture from doc as it goes. A page with no clear article returns its body.
+func Readable(doc *goquery.Document) Article {
+	art := Article{
+		Title:     articleTitle(doc),
+		Byline:    articleByline(doc),
+		Published: articlePublished(doc),
+	}
+
+	doc.Find("script, style, noscript, iframe, form, svg, nav, aside, footer, button, select, input, textarea, dialog").Remove()
+	doc.Find("[role=navigation], [role=complementary], [role=banner], [role=contentinfo], [role=dialog], [hidden], [aria-hidden=true]").Remove()
+	doc.Find("header").Each(func(i int, s *goquery.Selection) {
+		if s.ParentsFiltered("article, main").Length() == 0 {
+			s.Remove()
+		}
+	})
+	doc.Find("body *").Each(func(i int, s *goquery.Selection) {
+		switch goquery.NodeName(s) {
+		case "article", "main", "a", "table", "tbody", "tr", "td", "th":
+			return
+		}
+		match := classAndID(s)
+		if match != "" && unlikelyRE.MatchString(match) && !maybeRE.MatchString(match) {
+			s.Remove()
+		}
+	})
+
+	top := topCandidate(doc)
+	if top == nil {
+		body := doc.Find("body")
+		art.Node = body
+		art.Content = blockText(body)
+		return art
+	}
+	art.Node = withSiblings(doc, top)
+	art.Node.Find("div, section, ul, ol, table, figure").Each(func(i int, s *goquery.Selection) {
+		if weight(s) < 0 || (linkDensity(s) > 0.5 && textLength(s) < 1000) {
+			s.Remove()
+		}
+	})
+	art.Content = blockText(art.Node)
+	return art
+}
+
+// candidate is a container scored for how likely it is to hold the article
+type candidate struct {
+	node  *html.Node
+	sel   *goquery.Selection
+	score float64
+}
+
+// topCandidate scores the containers of each block of text and returns the best one
+func topCandidate(doc *goquery.Document) *candidate {
+	scores := make(map[*html.Node]*candidate)
+	var order []*candidate
+	doc.Find("p, pre, td, blockquote, div, section").Each(func(i int, s *goquery.Selection) {
+		name := goquery.NodeName(s)
+		// A div or section counts as a paragraph only when it holds text rather than blocks
+		if (name == "div" || name == "section") && s.Children().FilterFunction(func(i int, c *goquery.Selection) bool {
+			return blockTags[goquery.NodeName(c)] && goquery.NodeName(c) != "br"
+		}).Length() > 0 {
+			return
+		}
+		text := strings.TrimSpace(s.Text())
+		n := len([]rune(text))
+		if n < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(n/100), 3)
+		level := 0
+		for p := s.Parent(); p.Length() > 0 && level < 3; p = p.Parent() {
+			node := p.Get(0)
+			if node.Type != html.ElementNode || node.Data == "html" {
+				break
+			}
+			c, ok := scores[node]
+			if !ok {
+				c = &candidate{node: node, sel: p, score: initialScore(p)}
+				scores[node] = c
+				order = append(order, c)
+			}
+			divider := 1.0
+			if level == 1 {
+				divider = 2
+			} else if level > 1 {
+				divider = float64(level * 3)
+			}
+			c.score += score / divider
+			level++
+		}
+	})
+	var top *candidate
+	for _, c := range order {
+		c.score *= 1 - linkDensity(c.sel)
+		if top == nil || c.score > top.score {
+			top = c
+		}
+	}
+	// A candidate whose parent holds little else besides it is better taken with the parent,
+	// which keeps headings and images that sit next to the text
+	for top != nil && top.node.Data != "body" && top.sel.Parent().Length() > 0 && goquery.NodeName(top.sel.Parent()) != "body" {
+		parent := top.sel.Parent()
+		if textLength(parent) == 0 || float64(textLength(top.sel))/float64(textLength(parent)) < 0.85 {
+			break
+		}
+		top = &candidate{node: parent.Get(0), sel: parent, score: top.score}
+	}
+	return top
+}
+
+// withSiblings returns top with the siblings that continue the article: well-scored
+// containers, and paragraphs with enough text and few links
+func withSiblings(doc *goquery.Document, top *candidate) *goquery.Selection {
+	if top.node.Data == "body" {
+		return top.sel
+	}
+	threshold := math.Max(10, top.score*0.2)
+	topClass, _ := top.sel.Attr("class")
+	var nodes []*html.Node
+	top.sel.Parent().Children().Each(func(i int, s *goquery.Selection) {
+		node := s.Get(0)
+		if node == top.node {
+			nodes = append(nodes, node)
+			return
+		}
+		bonus := 0.0
+		if class, _ := s.Attr("class"); class != "" && class == topClass {
+			bonus = top.score * 0.2
+		}
+		keep := initialScore(s)+bonus >= threshold && textLength(s) > 0
+		if goquery.NodeName(s) == "p" {
+			text := strings.TrimSpace(s.Text())
+			density := linkDensity(s)
+			n := len([]rune(text))
+			keep = (n > 80 && density < 0.25) || (n > 0 && density == 0 && sentenceRE.MatchString(text))
+		}
+		if keep {
+			nodes = append(nodes, node)
+		}
+	})
+	return doc.FindNodes(nodes...)
+}
+
+// initialScore is a container's score before its text is counted: its tag and its class
+// and id
+func initialScore(s *goquery.Selection) float64 {
+	score := float64(weight(s))
+	switch goquery.NodeName(s) {
+	case "div", "article", "main":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+	return score
+}
+
+// weight scores a container's class and id: +25 for each that looks like content, -25 for
+// each that looks like page furniture
+func weight(s *goquery.Selection) int {
+	w := 0
+	for _, attr := range []string{"class", "id"} {
+		v, _ := s.Attr(attr)
+		if v == "" {
+			continue
+		}
+		if negativeRE.MatchString(v) {
+			w -= 25
+		}
+		if positiveRE.MatchString(v) {
+			w += 25
+		}
+	}
+	return w
+}
+
+func classAndID(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	return strings.TrimSpace(class + " " + id)
+}
+
+// textLength is the length of the text in s, in characters
+func textLength(s *goquery.Selection) int {
+	return len([]rune(strings.TrimSpace(s.Text())))
+}
+
+// linkDensity is the share of the text in s that is link text
+func linkDensity(s *goquery.Selection) float64 {
+	n := textLength(s)
+	if n == 0 {
+		return 0
+	}
+	links := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		links += textLength(a)
+	})
+	return float64(links) / float64(n)
+}
+
+// blockText returns the text of sel with a blank line between blocks and runs of
+// whitespace collapsed
+func blockText(sel *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if blockTags[n.Data] {
+				b.WriteString("\n\n")
+				defer b.WriteString("\n\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range sel.Nodes {
+		walk(n)
+	}
+	var blocks []string
+	for _, block := range strings.Split(b.String(), "\n\n") {
+		if block = strings.Join(strings.Fields(block), " "); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// titleSeparators split a page title from the site name appended to it
+var titleSeparators = []string{" | ", " - ", " – ", " — ", " :: ", " » ", " / "}
+
+// articleTitle prefers the Open Graph or structured data headline, then the title tag
+// without the site name, then the first h1
+func articleTitle(doc *goquery.Document) string {
+	if t := metaContent(doc, `meta[property="og:title"], meta[name="twitter:title"]`); t != "" {
+		return t
+	}
+	if t := ldString(doc, "headline"); t != "" {
+		return t
+	}
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+	h1 := strings.TrimSpace(doc.Find("h1").First().Text())
+	for _, sep := range titleSeparators {
+		if i := strings.LastIndex(title, sep); i > 0 && len(strings.Fields(title[:i])) >= 3 {
+			title = strings.TrimSpace(title[:i])
+			break
+		}
+	}
+	if title == "" || (h1 != "" && strings.Contains(title, h1)) {
+		return h1
+	}
+	return title
+}
+
+// articleByline reads the author from meta tags, structured data, or a byline element
+func articleByline(doc *goquery.Document) string {
+	if a := metaContent(doc, `meta[name="author"], meta[property="article:author"], meta[name="parsely-author"]`); a != "" && !strings.HasPrefix(a, "http") {
+		return a
+	}
+	if a := ldString(doc, "author"); a != "" {
+		return a
+	}
+	var byline string
+	doc.Find(`[rel="author"], [itemprop="author"], .byline, .author, [class*="byline"], [class*="author"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if s.Is("[itemprop=author]") {
+			if name := strings.TrimSpace(s.Find("[itemprop=name]").First().Text()); name != "" {
+				byline = name
+				return false
+			}
+		}
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		if text != "" && len(text) < 100 && (s.Is(`[rel="author"], [itemprop="author"]`) || bylineRE.MatchString(classAndID(s))) {
+			byline = byPrefixRE.ReplaceAllString(text, "")
+			return false
+		}
+		return true
+	})
+	return byline
+}
+
+// articlePublished reads the publication date from meta tags, structured data, or a time
+// element
+func articlePublished(doc *goquery.Document) string {
+	raw := metaContent(doc, `meta[property="article:published_time"], meta[itemprop="datePublished"], meta[name="pubdate"], meta[name="publishdate"], meta[name="parsely-pub-date"], meta[name="date"], meta[name="DC.date.issued"], meta[name="dcterms.created"]`)
+	if raw == "" {
+		raw = ldString(doc, "datePublished")
+	}
+	if raw == "" {
+		sel := doc.Find(`[itemprop="datePublished"], article time[datetime], time[pubdate], time[datetime]`).First()
+		raw = sel.AttrOr("datetime", sel.AttrOr("content", strings.TrimSpace(sel.Text())))
+	}
+	return normalizeDate(strings.TrimSpace(raw))
+}
+
+// dateLayouts are the forms of publication date pages commonly give
+var dateLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.RFC1123Z, time.RFC1123,
+}
+
+// dayLayouts give a date without a time of day
+var dayLayouts = []string{"2006-01-02", "January 2, 2006", "Jan 2, 2006", "2 January 2006", "02/01/2006"}
+
+// normalizeDate returns a date as RFC 3339, or as YYYY-MM-DD when it has no time of day;
+// a date in no known form is returned as it is
+func normalizeDate(s string) string {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	for _, layout := range dayLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return s
+}
+
+// metaContent returns the first non-empty content of the meta tags matching selector
+func metaContent(doc *goquery.Document, selector string) string {
+	var v string
+	doc.Find(selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		v = strings.TrimSpace(s.AttrOr("content", ""))
+		return v == ""
+	})
+	return v
+}
+
+// articleTypes are the JSON-LD types that describe an article
+var articleTypes = map[string]bool{
+	"Article": true, "NewsArticle": true, "BlogPosting": true, "Report": true, "ScholarlyArticle": true,
+	"TechArticle": true, "OpinionNewsArticle": true, "AnalysisNewsArticle": true, "WebPage": true,
+}
+
+// ldString returns a field of the page's JSON-LD article as text; an author given as an
+// object or a list gives its names
+func ldString(doc *goquery.Document, field string) string {
+	var found string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var v interface{}
+		if json.Unmarshal([]byte(s.Text()), &v) != nil {
+			return true
+		}
+		found = findLD(v, field)
+		return found == ""
+	})
+	return found
+}
+
+func findLD(v interface{}, field string) string {
+	switch x := v.(type) {
+	case []interface{}:
+		for _, item := range x {
+			if s := findLD(item, field); s != "" {
+				return s
+			}
+		}
+	case map[string]interface{}:
+		if graph, ok := x["@graph"]; ok {
+			return findLD(graph, field)
+		}
+		if isArticleType(x["@type"]) {
+			return ldText(x[field])
+		}
+	}
+	return ""
+}
+
+func isArticleType(t interface{}) bool {
+	switch x := t.(type) {
+	case string:
+		return articleTypes[x]
+	case []interface{}:
+		for _, s := range x {
+			if isArticleType(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ldText renders a JSON-LD value as text: strings as they are, objects by their name, and
+// lists joined with commas
+func ldText(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return strings.TrimSpace(x)
+	case map[string]interface{}:
+		return ldText(x["name"])
+	case []interface{}:
+		var parts []string
+		for _, item := range x {
+			if s := ldText(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
@@ -0,0 +1,317 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/license"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DefaultUserAgent identifies kirk-ai when fetching pages
+const DefaultUserAgent = "kirk-ai-crawler/1.0 (+https://github.com/theaidguild/kirk-ai)"
+
+// UserAgent returns DefaultUserAgent with the operator's contact URL or email added to the
+// comment, so site owners can reach whoever runs the crawl
+func UserAgent(contact string) string {
+	if contact == "" {
+		return DefaultUserAgent
+	}
+	return strings.TrimSuffix(DefaultUserAgent, ")") + "; " + contact + ")"
+}
+
+// MaxContentLength is the default cap on the extracted content of a single page
+const MaxContentLength = 50_000
+
+// Truncation strategies for content longer than the configured limit
+const (
+	TruncateHard     = "hard"     // cut exactly at the limit
+	TruncateSentence = "sentence" // cut at the last sentence (or word) boundary before the limit
+	TruncateOverflow = "overflow" // split into several pages at sentence boundaries, dropping nothing
+)
+
+// Options controls how page content is capped
+type Options struct {
+	MaxLength  int    // 0 disables the cap
+	Truncation string // one of the Truncate* strategies
+}
+
+// DefaultOptions caps content at MaxContentLength on a sentence boundary
+func DefaultOptions() Options {
+	return Options{MaxLength: MaxContentLength, Truncation: TruncateSentence}
+}
+
+// Validate reports an unknown truncation strategy
+func (o Options) Validate() error {
+	switch o.Truncation {
+	case TruncateHard, TruncateSentence, TruncateOverflow:
+		return nil
+	}
+	return fmt.Errorf("unknown truncation strategy %q (want %s, %s, or %s)",
+		o.Truncation, TruncateHard, TruncateSentence, TruncateOverflow)
+}
+
+// Page holds the text extracted from a single HTML document. Part and Parts are set
+// when overflow splitting produced more than one page for the document.
+type Page struct {
+	URL      string
+	Title    string
+	Content  string
+	Sections []chunker.Section // headings located in Content
+	License  license.Info      // license and copyright signals published by the document
+	Part     int
+	Parts    int
+}
+
+// FromDocument extracts the title and the paragraph text of the main content area,
+// capped with DefaultOptions
+func FromDocument(u string, doc *goquery.Document) Page {
+	return FromDocumentWithOptions(u, doc, DefaultOptions())[0]
+}
+
+// FromDocumentWithOptions extracts a document and caps its content according to opts.
+// It always returns at least one page; only TruncateOverflow returns more.
+func FromDocumentWithOptions(u string, doc *goquery.Document, opts Options) []Page {
+	page := Page{
+		URL:     u,
+		Title:   strings.TrimSpace(doc.Find("title").Text()),
+		License: license.Detect(doc),
+	}
+	main := doc.Find("main").First()
+	if main.Length() == 0 {
+		main = doc.Find("body")
+	}
+	// remove scripts/styles from selection
+	main.Find("script, style, noscript").Remove()
+	paras := []string{}
+	main.Find("p").Each(func(i int, s *goquery.Selection) {
+		if t := strings.TrimSpace(s.Text()); t != "" {
+			paras = append(paras, t)
+		}
+	})
+	content := strings.Join(paras, " ")
+	sections := Sections(main, content)
+	if opts.MaxLength <= 0 || len(content) <= opts.MaxLength {
+		page.Content = content
+		page.Sections = sections
+		return []Page{page}
+	}
+
+	switch opts.Truncation {
+	case TruncateHard:
+		page.Content = cutRunes(content, opts.MaxLength)
+	case TruncateOverflow:
+		parts := SplitAtSentences(content, opts.MaxLength)
+		pages := make([]Page, len(parts))
+		cursor := 0
+		for i, p := range parts {
+			start := cursor + strings.Index(content[cursor:], p)
+			cursor = start + len(p)
+			pages[i] = Page{URL: u, Title: page.Title, Content: p, Sections: sectionsWithin(sections, start, cursor), License: page.License, Part: i, Parts: len(parts)}
+		}
+		return pages
+	default:
+		page.Content = TruncateAtSentence(content, opts.MaxLength)
+	}
+	page.Sections = sectionsWithin(sections, 0, len(page.Content))
+	return []Page{page}
+}
+
+// Sections returns the headings in sel, in document order, with the offset in content where
+// each section begins. content is the text extracted from sel: a heading found in it starts
+// its section there; a heading left out of it (paragraph-only extraction) starts its section
+// at the next paragraph.
+func Sections(sel *goquery.Selection, content string) []chunker.Section {
+	var out, pending []chunker.Section
+	cursor := 0
+	sel.Find("h1, h2, h3, h4, h5, h6, p").Each(func(i int, s *goquery.Selection) {
+		at, n := locate(content[cursor:], s.Text())
+		if goquery.NodeName(s) == "p" {
+			if at < 0 {
+				return
+			}
+			for _, h := range pending {
+				h.Offset = cursor + at
+				out = append(out, h)
+			}
+			pending = nil
+			cursor += at + n
+			return
+		}
+		heading := strings.Join(strings.Fields(s.Text()), " ")
+		if heading == "" {
+			return
+		}
+		h := chunker.Section{Heading: heading, Level: int(goquery.NodeName(s)[1] - '0')}
+		if at >= 0 && len(pending) == 0 {
+			h.Offset = cursor + at
+			out = append(out, h)
+			cursor += at + n
+			return
+		}
+		pending = append(pending, h)
+	})
+	return out
+}
+
+// locate finds an element's text in content, as extracted verbatim or with whitespace
+// collapsed, returning its index and matched length (-1 when absent)
+func locate(content, text string) (int, int) {
+	for _, t := range []string{strings.TrimSpace(text), strings.Join(strings.Fields(text), " ")} {
+		if t == "" {
+			continue
+		}
+		if i := strings.Index(content, t); i >= 0 {
+			return i, len(t)
+		}
+	}
+	return -1, 0
+}
+
+// sectionsWithin rebases sections onto the content range [start, end). The section already
+// open at start carries over at offset 0.
+func sectionsWithin(sections []chunker.Section, start, end int) []chunker.Section {
+	var out []chunker.Section
+	for i, sec := range sections {
+		if sec.Offset >= end {
+			break
+		}
+		if sec.Offset < start {
+			if i+1 < len(sections) && sections[i+1].Offset <= start {
+				continue
+			}
+			sec.Offset = start
+		}
+		sec.Offset -= start
+		out = append(out, sec)
+	}
+	return out
+}
+
+// TruncateAtSentence shortens s to at most max bytes, preferring to end on a sentence
+// boundary in the second half of the limit, then on a word boundary
+func TruncateAtSentence(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.TrimSpace(s[:boundary(s, max)])
+}
+
+// SplitAtSentences splits s into pieces of at most max bytes, breaking at sentence or word boundaries
+func SplitAtSentences(s string, max int) []string {
+	var parts []string
+	for len(s) > max {
+		cut := boundary(s, max)
+		if part := strings.TrimSpace(s[:cut]); part != "" {
+			parts = append(parts, part)
+		}
+		s = s[cut:]
+	}
+	if rest := strings.TrimSpace(s); rest != "" || len(parts) == 0 {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// boundary returns the index at which to cut s so the head is at most max bytes
+func boundary(s string, max int) int {
+	head := s[:max]
+	// Sentence end: terminal punctuation followed by whitespace
+	for i := len(head) - 1; i > max/2; i-- {
+		if (head[i] == ' ' || head[i] == '\n') && endsSentence(head[:i]) {
+			return i
+		}
+	}
+	if i := strings.LastIndexAny(head, " \n\t"); i > 0 {
+		return i
+	}
+	return len(cutRunes(s, max))
+}
+
+// endsSentence reports whether s ends with terminal punctuation, optionally followed by a closing quote
+func endsSentence(s string) bool {
+	s = strings.TrimRight(s, `"')`)
+	return strings.HasSuffix(s, ".") || strings.HasSuffix(s, "!") || strings.HasSuffix(s, "?")
+}
+
+// cutRunes cuts s to at most max bytes without splitting a UTF-8 sequence
+func cutRunes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// ImageText returns the alt text of images and the text of figure captions in sel,
+// deduplicated and in document order. Placeholder alt text ("image", file names) is skipped.
+func ImageText(sel *goquery.Selection) []string {
+	seen := make(map[string]bool)
+	var out []string
+	add := func(t string) {
+		t = strings.Join(strings.Fields(t), " ")
+		key := strings.ToLower(t)
+		if len(t) < 4 || seen[key] || isPlaceholderAlt(key) {
+			return
+		}
+		seen[key] = true
+		out = append(out, t)
+	}
+	sel.Find("img[alt], figcaption").Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "img" {
+			add(s.AttrOr("alt", ""))
+			return
+		}
+		add(s.Text())
+	})
+	return out
+}
+
+// isPlaceholderAlt reports alt text that carries no information
+func isPlaceholderAlt(alt string) bool {
+	switch alt {
+	case "image", "photo", "picture", "logo", "icon", "banner", "thumbnail", "placeholder":
+		return true
+	}
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg"} {
+		if strings.HasSuffix(alt, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch downloads and parses a single HTML page. The HTTP status code is returned
+// alongside any error so callers can distinguish removed pages from transient failures.
+func Fetch(ctx context.Context, client *http.Client, u string) (*goquery.Document, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, resp.StatusCode, fmt.Errorf("GET %s: non-html content", u)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	return doc, resp.StatusCode, nil
+}
@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DefaultDamping is the standard PageRank damping factor
+const DefaultDamping = 0.85
+
+// Record is one line of a link graph file: a crawled page and the links found on it
+type Record struct {
+	URL   string   `json:"url"`
+	Links []string `json:"links"`
+}
+
+// Graph maps each page to its outgoing links
+type Graph map[string][]string
+
+// Load reads a JSONL link graph. When a page appears more than once the latest record wins.
+func Load(path string) (Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g := make(Graph)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("parse %s line %d: %w", path, line, err)
+		}
+		if r.URL != "" {
+			g[r.URL] = r.Links
+		}
+	}
+	return g, scanner.Err()
+}
+
+// PageRank computes PageRank over the graph with the given damping factor. Pages that are
+// only linked to (never crawled) are included; rank from pages without outlinks is spread evenly.
+func PageRank(g Graph, damping float64, iterations int) map[string]float64 {
+	nodes := make(map[string]bool)
+	for page, links := range g {
+		nodes[page] = true
+		for _, l := range links {
+			nodes[l] = true
+		}
+	}
+	n := float64(len(nodes))
+	if n == 0 {
+		return map[string]float64{}
+	}
+
+	rank := make(map[string]float64, len(nodes))
+	for node := range nodes {
+		rank[node] = 1 / n
+	}
+
+	for i := 0; i < iterations; i++ {
+		next := make(map[string]float64, len(nodes))
+		var dangling float64
+		for node := range nodes {
+			links := uniqueLinks(g[node], node)
+			if len(links) == 0 {
+				dangling += rank[node]
+				continue
+			}
+			share := rank[node] / float64(len(links))
+			for _, l := range links {
+				next[l] += share
+			}
+		}
+		for node := range nodes {
+			next[node] = (1-damping)/n + damping*(next[node]+dangling/n)
+		}
+		rank = next
+	}
+	return rank
+}
+
+// Authority returns PageRank scores scaled so the highest-ranked page scores 1
+func Authority(g Graph) map[string]float64 {
+	rank := PageRank(g, DefaultDamping, 50)
+	var max float64
+	for _, r := range rank {
+		if r > max {
+			max = r
+		}
+	}
+	if max == 0 {
+		return rank
+	}
+	for k, r := range rank {
+		rank[k] = r / max
+	}
+	return rank
+}
+
+// uniqueLinks drops duplicate links and self-links
+func uniqueLinks(links []string, self string) []string {
+	seen := make(map[string]bool, len(links))
+	out := links[:0:0]
+	for _, l := range links {
+		if l == self || seen[l] {
+			continue
+		}
+		seen[l] = true
+		out = append(out, l)
+	}
+	return out
+}
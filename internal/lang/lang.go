@@ -0,0 +1,188 @@
+// Package lang names languages and tells which one a text is written in, well enough to
+// check that a model answered in the language it was asked to
+package lang
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// language is one language Detect can recognize, by its script or by common words
+type language struct {
+	name   string
+	codes  []string // ISO 639-1 and 639-3 codes and native names accepted by Name
+	script *unicode.RangeTable
+	words  []string // frequent function words, for languages written in the Latin script
+}
+
+var languages = []language{
+	{name: "English", codes: []string{"en", "eng"}, words: strings.Fields(
+		"the and of to is in that it for are was with as be this have not but they you on by from at or which their has an were would there will can been")},
+	{name: "Spanish", codes: []string{"es", "spa", "español", "espanol", "castellano"}, words: strings.Fields(
+		"el la los las de que y en un una por con para es del se lo como pero más sus al está son también fue hay muy sobre ya este esta")},
+	{name: "French", codes: []string{"fr", "fra", "fre", "français", "francais"}, words: strings.Fields(
+		"le la les de des et en un une du est que qui dans pour pas sur au avec ce cette sont il elle ils mais aux ont été fait leur être")},
+	{name: "German", codes: []string{"de", "deu", "ger", "deutsch"}, words: strings.Fields(
+		"der die das und ist nicht ein eine zu den von mit sich des auf für im dem auch es an als wird werden sind hat aus bei oder wie")},
+	{name: "Italian", codes: []string{"it", "ita", "italiano"}, words: strings.Fields(
+		"il lo la gli le di che e è un una per non con del della sono si nel alla anche più come ma questo questa dei delle essere stato")},
+	{name: "Portuguese", codes: []string{"pt", "por", "português", "portugues"}, words: strings.Fields(
+		"o a os as de que e do da em um uma para com não é no na dos das por se mais como mas foi ao são também está pelo pela")},
+	{name: "Dutch", codes: []string{"nl", "nld", "dut", "nederlands"}, words: strings.Fields(
+		"de het een en van is dat op te in niet zijn voor met die er maar ook als bij aan door om wordt worden nog dan naar")},
+	{name: "Russian", codes: []string{"ru", "rus", "русский"}, script: unicode.Cyrillic},
+	{name: "Greek", codes: []string{"el", "ell", "gre", "ελληνικά"}, script: unicode.Greek},
+	{name: "Arabic", codes: []string{"ar", "ara", "العربية"}, script: unicode.Arabic},
+	{name: "Hebrew", codes: []string{"he", "heb", "עברית"}, script: unicode.Hebrew},
+	{name: "Hindi", codes: []string{"hi", "hin", "हिन्दी", "हिंदी"}, script: unicode.Devanagari},
+	{name: "Thai", codes: []string{"th", "tha", "ไทย"}, script: unicode.Thai},
+	{name: "Korean", codes: []string{"ko", "kor", "한국어"}, script: unicode.Hangul},
+	{name: "Japanese", codes: []string{"ja", "jpn", "日本語"}, script: unicode.Hiragana},
+	{name: "Chinese", codes: []string{"zh", "zho", "chi", "中文", "mandarin"}, script: unicode.Han},
+}
+
+// Name returns the canonical name of a language given by name or code, and whether Detect
+// can recognize it. Names it does not know are returned as given.
+func Name(s string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(key, "-_"); i > 0 {
+		key = key[:i] // en-US, pt_BR
+	}
+	for _, l := range languages {
+		if key == strings.ToLower(l.name) {
+			return l.name, true
+		}
+		for _, c := range l.codes {
+			if key == c {
+				return l.name, true
+			}
+		}
+	}
+	return strings.TrimSpace(s), false
+}
+
+// Undetermined is the ISO 639-2 code for text whose language could not be told
+const Undetermined = "und"
+
+// Code returns the ISO 639-1 code of a language given by name or code, such as "es" for
+// Spanish or es-MX. Languages Detect does not know are reduced to their primary subtag,
+// lowercased; an empty s returns "".
+func Code(s string) string {
+	name, ok := Name(s)
+	if !ok {
+		key := strings.ToLower(strings.TrimSpace(s))
+		if i := strings.IndexAny(key, "-_"); i > 0 {
+			key = key[:i]
+		}
+		return key
+	}
+	for _, l := range languages {
+		if l.name == name {
+			return l.codes[0]
+		}
+	}
+	return ""
+}
+
+// Guess is the language Detect settled on
+type Guess struct {
+	Language string // "" when the text is too short or too mixed to tell
+	// Confidence is the share of the evidence for Language: letters in its script, or
+	// function words from its list among all recognized function words
+	Confidence float64
+}
+
+// minWords is how many function words Detect needs before it names a Latin-script language
+const minWords = 4
+
+// noiseRE matches text that says nothing about the language: code, URLs, and citation markers
+var noiseRE = regexp.MustCompile("(?s)```.*?```|`[^`]*`|https?://\\S+|\\[\\d+(?:,\\s*\\d+)*\\]")
+
+// Detect guesses the language of text from its script, or for the Latin script from how
+// many of each language's most frequent function words it uses
+func Detect(text string) Guess {
+	text = noiseRE.ReplaceAllString(text, " ")
+
+	counts := make(map[string]int)
+	letters, latin := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, l := range languages {
+			if l.script != nil && unicode.Is(l.script, r) {
+				counts[l.name]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return Guess{}
+	}
+	// Japanese mixes kana with Han characters, which on their own mean Chinese
+	if counts["Japanese"] > 0 {
+		counts["Japanese"] += counts["Chinese"]
+		delete(counts, "Chinese")
+	}
+	if latin*2 < letters {
+		best := ranked(counts)
+		if len(best) == 0 {
+			return Guess{}
+		}
+		return Guess{Language: best[0], Confidence: float64(counts[best[0]]) / float64(letters)}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	hits := make(map[string]int)
+	total := 0
+	for _, l := range languages {
+		if l.words == nil {
+			continue
+		}
+		set := make(map[string]bool, len(l.words))
+		for _, w := range l.words {
+			set[w] = true
+		}
+		for _, w := range words {
+			if set[w] {
+				hits[l.name]++
+				total++
+			}
+		}
+	}
+	best := ranked(hits)
+	if len(best) == 0 || hits[best[0]] < minWords {
+		return Guess{}
+	}
+	// Closely related languages share many words; only a clear lead counts
+	if len(best) > 1 && hits[best[0]]*2 < hits[best[1]]*3 {
+		return Guess{}
+	}
+	return Guess{Language: best[0], Confidence: float64(hits[best[0]]) / float64(total)}
+}
+
+// ranked returns the keys of counts from the highest count down
+func ranked(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k, n := range counts {
+		if n > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
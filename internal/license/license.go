@@ -0,0 +1,339 @@
+package license
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MetadataKey is the key under which license signals are stored in page records and chunk metadata
+const MetadataKey = "license"
+
+// Info holds the license and copyright signals published by a page. Fields are empty when
+// the page does not state them; an empty Info does not mean the page is free to reuse.
+type Info struct {
+	License    string `json:"license,omitempty"`     // normalized name, e.g. "CC BY-SA 4.0"
+	LicenseURL string `json:"license_url,omitempty"` // the license deed or text the page links to
+	Copyright  string `json:"copyright,omitempty"`   // copyright statement as published
+	Holder     string `json:"holder,omitempty"`
+	Year       string `json:"year,omitempty"`
+	Source     string `json:"source,omitempty"` // where the license was found: link, meta, json-ld, or text
+}
+
+var (
+	// © 2024 Holder, Copyright (c) 2019-2024 Holder. All rights reserved.
+	copyrightRE = regexp.MustCompile(`(?i)(?:©|\(c\)|\bcopyright\b)(?:\s*(?:©|\(c\)))?\s*((?:19|20)\d{2}(?:\s*[-–]\s*(?:19|20)\d{2})?)?,?\s*([^.|©\n]{0,80})`)
+	// Creative Commons Attribution-ShareAlike 4.0 International, CC BY-NC 3.0
+	ccNameRE = regexp.MustCompile(`(?i)creative commons attribution((?:[- ](?:noncommercial|non-commercial|sharealike|share-alike|noderivatives|noderivs|no-derivatives))*)\s*(\d\.\d)?`)
+	ccAbbrRE = regexp.MustCompile(`\bCC[- ]BY((?:-(?:NC|SA|ND))*)(?:\s+(\d\.\d))?\b`)
+	cc0RE    = regexp.MustCompile(`\bCC0(?:\s+(\d\.\d))?\b`)
+	rightsRE = regexp.MustCompile(`(?i)^\.?\s*[,;-]?\s*all rights reserved\.?`)
+	// This work is in the public domain, released into the public domain, Public Domain Dedication
+	publicDomainRE = regexp.MustCompile(`(?i)\b(?:in|into) the public domain\b|\bpublic domain (?:dedication|mark)\b`)
+)
+
+// bodyTail is how much of the end of a page without footer markup is searched for statements
+const bodyTail = 600
+
+// metaNames are the <meta name> values that carry license or rights statements
+var metaNames = []string{"license", "dcterms.license", "dc.rights", "dcterms.rights", "rights", "copyright", "dc.rightsholder"}
+
+// Detect collects license and copyright signals from a page: rel="license" links, rights
+// meta tags, schema.org JSON-LD, and copyright or Creative Commons statements in the footer.
+// Explicit markup wins over text found in the page.
+func Detect(doc *goquery.Document) Info {
+	var info Info
+
+	doc.Find(`link[rel~="license"], a[rel~="license"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" {
+			return true
+		}
+		info.LicenseURL = href
+		info.License = Name(href)
+		if info.License == "" {
+			info.License = normalizeText(s.Text())
+		}
+		info.Source = "link"
+		return false
+	})
+
+	doc.Find("meta[name]").Each(func(i int, s *goquery.Selection) {
+		name := strings.ToLower(s.AttrOr("name", ""))
+		content := strings.TrimSpace(s.AttrOr("content", ""))
+		if content == "" || !contains(metaNames, name) {
+			return
+		}
+		switch name {
+		case "license", "dcterms.license":
+			info.fillLicense(content, "meta")
+		case "dc.rightsholder":
+			if info.Holder == "" {
+				info.Holder = content
+			}
+		default:
+			info.fillText(content, "meta")
+		}
+	})
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &v); err == nil {
+			info.fillJSONLD(v)
+		}
+	})
+
+	footer := doc.Find(`footer, #footer, .footer, .site-footer, .copyright, [class*="copyright"], [id*="copyright"]`)
+	footerText := strings.Join(strings.Fields(footer.Text()), " ")
+	info.fillText(footerText, "text")
+	if info.Copyright == "" || info.License == "" {
+		// Without footer markup, only the end of the page is likely to be boilerplate
+		body := strings.Join(strings.Fields(doc.Find("body").Text()), " ")
+		if len(body) > bodyTail {
+			body = body[len(body)-bodyTail:]
+		}
+		info.fillText(body, "text")
+	}
+	return info
+}
+
+// fillLicense records a license given as a URL or a name, unless one is already known
+func (info *Info) fillLicense(v, source string) {
+	if info.License != "" || info.LicenseURL != "" {
+		return
+	}
+	if u, err := url.Parse(v); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		info.LicenseURL = v
+		info.License = Name(v)
+	} else {
+		info.License = normalizeText(v)
+		if info.License == "" {
+			info.License = v
+		}
+	}
+	info.Source = source
+}
+
+// fillText picks up a copyright statement and a Creative Commons license named in free text
+func (info *Info) fillText(text, source string) {
+	if text == "" {
+		return
+	}
+	if info.Copyright == "" {
+		if m := copyrightRE.FindStringSubmatchIndex(text); m != nil {
+			holder := ""
+			if m[4] >= 0 {
+				holder = text[m[4]:m[5]]
+				if i := strings.Index(strings.ToLower(holder), "all rights reserved"); i >= 0 {
+					holder = holder[:i]
+				}
+			}
+			year := ""
+			if m[2] >= 0 {
+				year = text[m[2]:m[3]]
+			}
+			statement := strings.ToLower(text[m[0]:m[1]])
+			marked := strings.Contains(statement, "©") || strings.Contains(statement, "(c)")
+			// "copyright" alone is too common in prose; require a year or a © mark
+			if (year != "" || marked) && (year != "" || holder != "") {
+				end := m[1]
+				if loc := rightsRE.FindStringIndex(text[end:]); loc != nil {
+					end += loc[1]
+				}
+				info.Copyright = strings.TrimSpace(text[m[0]:end])
+				if info.Year == "" {
+					info.Year = year
+				}
+				if info.Holder == "" {
+					info.Holder = strings.Trim(holder, " ,;-")
+				}
+			}
+		}
+	}
+	if info.License == "" {
+		if name := normalizeText(text); name != "" {
+			info.License = name
+			info.Source = source
+		}
+	}
+}
+
+// fillJSONLD reads schema.org license and copyright properties from a JSON-LD value,
+// descending into arrays and @graph
+func (info *Info) fillJSONLD(v interface{}) {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			info.fillJSONLD(e)
+		}
+	case map[string]interface{}:
+		if g, ok := t["@graph"]; ok {
+			info.fillJSONLD(g)
+		}
+		if lic := jsonLDString(t["license"]); lic != "" {
+			info.fillLicense(lic, "json-ld")
+		}
+		if info.Holder == "" {
+			info.Holder = jsonLDString(t["copyrightHolder"])
+		}
+		if info.Year == "" {
+			info.Year = jsonLDString(t["copyrightYear"])
+		}
+		if notice := jsonLDString(t["copyrightNotice"]); notice != "" && info.Copyright == "" {
+			info.Copyright = notice
+		}
+	}
+}
+
+// jsonLDString reads a property that may be a string, a number, or an object with a name,
+// url, or @id
+func jsonLDString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case map[string]interface{}:
+		for _, k := range []string{"name", "url", "@id"} {
+			if s, ok := t[k].(string); ok && s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		if len(t) > 0 {
+			return jsonLDString(t[0])
+		}
+	}
+	return ""
+}
+
+// Name returns the common name of a well-known license URL, such as "CC BY-SA 4.0" for
+// https://creativecommons.org/licenses/by-sa/4.0/, or "" when the URL is not recognized
+func Name(licenseURL string) string {
+	u, err := url.Parse(licenseURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	parts := strings.FieldsFunc(strings.ToLower(u.Path), func(r rune) bool { return r == '/' })
+	switch {
+	case host == "creativecommons.org" && len(parts) >= 2 && parts[0] == "licenses":
+		name := "CC " + strings.ToUpper(parts[1])
+		if len(parts) >= 3 {
+			name += " " + parts[2]
+		}
+		return name
+	case host == "creativecommons.org" && len(parts) >= 2 && parts[0] == "publicdomain" && parts[1] == "zero":
+		if len(parts) >= 3 {
+			return "CC0 " + parts[2]
+		}
+		return "CC0"
+	case host == "creativecommons.org" && len(parts) >= 2 && parts[0] == "publicdomain" && parts[1] == "mark":
+		return "Public Domain Mark"
+	case host == "opensource.org" && len(parts) >= 2 && parts[0] == "licenses":
+		return strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(parts[1], ".php"), "-license"))
+	case host == "gnu.org" && len(parts) >= 2 && parts[0] == "licenses":
+		return strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(parts[1], ".html"), "-1.3"))
+	}
+	return ""
+}
+
+// normalizeText finds a Creative Commons license named in text and returns its common name
+func normalizeText(text string) string {
+	if m := ccAbbrRE.FindStringSubmatch(text); m != nil {
+		return strings.TrimSpace("CC BY" + m[1] + " " + m[2])
+	}
+	if m := cc0RE.FindStringSubmatch(text); m != nil {
+		return strings.TrimSpace("CC0 " + m[1])
+	}
+	if m := ccNameRE.FindStringSubmatch(text); m != nil {
+		name := "CC BY"
+		mods := strings.ToLower(m[1])
+		if strings.Contains(mods, "noncommercial") || strings.Contains(mods, "non-commercial") {
+			name += "-NC"
+		}
+		if strings.Contains(mods, "sharealike") || strings.Contains(mods, "share-alike") {
+			name += "-SA"
+		}
+		if strings.Contains(mods, "noderiv") || strings.Contains(mods, "no-deriv") {
+			name += "-ND"
+		}
+		return strings.TrimSpace(name + " " + m[2])
+	}
+	if publicDomainRE.MatchString(text) {
+		return "Public Domain"
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IsZero reports whether no license or copyright signal was found
+func (info Info) IsZero() bool {
+	return info == Info{}
+}
+
+// Get reads the license info stored in a record or metadata map; a missing entry yields a zero Info
+func Get(m map[string]interface{}) Info {
+	var info Info
+	if m == nil || m[MetadataKey] == nil {
+		return info
+	}
+	b, err := json.Marshal(m[MetadataKey])
+	if err != nil {
+		return info
+	}
+	_ = json.Unmarshal(b, &info)
+	return info
+}
+
+// Map converts the info into the generic form stored in JSON metadata maps
+func (info Info) Map() map[string]interface{} {
+	out := map[string]interface{}{}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return out
+	}
+	_ = json.Unmarshal(b, &out)
+	return out
+}
+
+// Notice renders the rights line of an attribution: the copyright statement (or holder and
+// year) followed by the license and its URL. Pages that state nothing are reported as such,
+// since unstated rights default to all rights reserved.
+func (info Info) Notice() string {
+	var parts []string
+	switch {
+	case info.Copyright != "":
+		parts = append(parts, info.Copyright)
+	case info.Holder != "" || info.Year != "":
+		parts = append(parts, strings.TrimSpace("© "+info.Year+" "+info.Holder))
+	}
+	phrase := "licensed under " + info.License
+	if info.License == "Public Domain" {
+		phrase = "in the public domain"
+	}
+	switch {
+	case info.License != "" && info.LicenseURL != "":
+		parts = append(parts, phrase+" <"+info.LicenseURL+">")
+	case info.License != "":
+		parts = append(parts, phrase)
+	case info.LicenseURL != "":
+		parts = append(parts, "license: "+info.LicenseURL)
+	}
+	if len(parts) == 0 {
+		return "no license or copyright stated (all rights reserved unless the site says otherwise)"
+	}
+	return strings.Join(parts, " — ")
+}
@@ -0,0 +1,100 @@
+package lineedit
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+// DefaultHistorySize is how many entries a history file keeps
+const DefaultHistorySize = 1000
+
+// History is the list of lines entered at a prompt, oldest first, persisted to a file so
+// it carries over between sessions
+type History struct {
+	path    string
+	max     int
+	entries []string
+}
+
+// LoadHistory reads the history file at path, keeping the newest max entries. A missing
+// file starts an empty history; an empty path keeps the history in memory only.
+func LoadHistory(path string, max int) (*History, error) {
+	if max <= 0 {
+		max = DefaultHistorySize
+	}
+	h := &History{path: path, max: max}
+	if path == "" {
+		return h, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	// Trim a file that grew past the limit so it does not grow forever
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+		if err := h.rewrite(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Entries returns the history, oldest first
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Add appends line to the history and its file. Blank lines and repeats of the previous
+// entry are not recorded.
+func (h *History) Add(line string) error {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" || strings.Contains(line, "\n") {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return nil
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	// Prompts can hold anything the user typed, so the file is private
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (h *History) rewrite() error {
+	return atomicfile.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600, false)
+}
@@ -0,0 +1,380 @@
+// Package lineedit reads lines at an interactive prompt with cursor editing, history recall,
+// and reverse search, falling back to plain line reading when input is not a terminal
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned by ReadLine when Ctrl-C is pressed at the prompt
+var ErrInterrupted = errors.New("interrupted")
+
+// Editor reads lines from a terminal. Keys follow readline's emacs mode: arrows, Home/End,
+// Ctrl-A/E/B/F to move, Backspace/Delete/Ctrl-K/U/W to delete, Up/Down or Ctrl-P/N for
+// history, Ctrl-R to search it, Ctrl-L to clear the screen, and Ctrl-D to end input.
+type Editor struct {
+	in   *bufio.Reader
+	out  io.Writer
+	tty  *os.File // nil when input is not a terminal
+	hist *History
+}
+
+// New returns an editor reading from in and drawing on out. Lines entered at a terminal
+// are added to hist; a nil hist keeps no history.
+func New(in *os.File, out io.Writer, hist *History) *Editor {
+	if hist == nil {
+		hist, _ = LoadHistory("", 0)
+	}
+	e := &Editor{in: bufio.NewReader(in), out: out, hist: hist}
+	if isTerminal(in) {
+		e.tty = in
+	}
+	return e
+}
+
+// ReadLine prints prompt and returns the line entered, without its newline. It returns
+// io.EOF at the end of input and ErrInterrupted when Ctrl-C is pressed.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.tty != nil {
+		restore, cols, err := makeRaw(e.tty)
+		if err == nil {
+			line, err := e.edit(prompt, cols)
+			restore()
+			if err == nil {
+				_ = e.hist.Add(line)
+			}
+			return line, err
+		}
+		// Not a terminal stty can drive (such as /dev/null); read plain lines from now on
+		e.tty = nil
+	}
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func ctrl(c rune) rune {
+	return c & 0x1f
+}
+
+// Keys decoded from escape sequences
+const (
+	keyNone rune = -1 - iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+)
+
+// edit runs one line of raw-mode editing
+func (e *Editor) edit(prompt string, cols int) (string, error) {
+	s := &lineState{out: e.out, cols: cols}
+	entries := e.hist.Entries()
+	idx := len(entries) // position while browsing history; len(entries) is the new line
+	var pending []rune  // the new line, kept while browsing history
+	set := func(line []rune) {
+		s.buf = append([]rune(nil), line...)
+		s.pos = len(s.buf)
+	}
+
+	s.draw(prompt, s.buf, s.pos)
+	for {
+		r, err := e.key()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			s.draw(prompt, s.buf, len(s.buf))
+			fmt.Fprint(e.out, "\r\n")
+			return string(s.buf), nil
+		case ctrl('C'):
+			s.draw(prompt, s.buf, len(s.buf))
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrl('D'):
+			if len(s.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.deleteAt(s.pos)
+		case keyDelete:
+			s.deleteAt(s.pos)
+		case ctrl('H'), 127:
+			if s.pos > 0 {
+				s.pos--
+				s.deleteAt(s.pos)
+			}
+		case ctrl('A'), keyHome:
+			s.pos = 0
+		case ctrl('E'), keyEnd:
+			s.pos = len(s.buf)
+		case ctrl('B'), keyLeft:
+			if s.pos > 0 {
+				s.pos--
+			}
+		case ctrl('F'), keyRight:
+			if s.pos < len(s.buf) {
+				s.pos++
+			}
+		case keyWordLeft:
+			s.pos = s.wordStart()
+		case keyWordRight:
+			for s.pos < len(s.buf) && unicode.IsSpace(s.buf[s.pos]) {
+				s.pos++
+			}
+			for s.pos < len(s.buf) && !unicode.IsSpace(s.buf[s.pos]) {
+				s.pos++
+			}
+		case ctrl('K'):
+			s.buf = s.buf[:s.pos]
+		case ctrl('U'):
+			s.buf = append([]rune(nil), s.buf[s.pos:]...)
+			s.pos = 0
+		case ctrl('W'):
+			start := s.wordStart()
+			s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+			s.pos = start
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			s.row = 0
+		case ctrl('P'), keyUp:
+			if idx > 0 {
+				if idx == len(entries) {
+					pending = append([]rune(nil), s.buf...)
+				}
+				idx--
+				set([]rune(entries[idx]))
+			}
+		case ctrl('N'), keyDown:
+			if idx < len(entries) {
+				idx++
+				if idx == len(entries) {
+					set(pending)
+				} else {
+					set([]rune(entries[idx]))
+				}
+			}
+		case ctrl('R'):
+			line, submit, err := e.search(s, entries)
+			if err != nil {
+				return "", err
+			}
+			if line != nil {
+				set(line)
+				idx = len(entries)
+			}
+			if submit {
+				s.draw(prompt, s.buf, len(s.buf))
+				fmt.Fprint(e.out, "\r\n")
+				return string(s.buf), nil
+			}
+		default:
+			if r >= ' ' && unicode.IsPrint(r) {
+				s.buf = append(s.buf[:s.pos], append([]rune{r}, s.buf[s.pos:]...)...)
+				s.pos++
+			}
+		}
+		s.draw(prompt, s.buf, s.pos)
+	}
+}
+
+// search runs a Ctrl-R incremental search back through entries. Typing narrows the search,
+// Ctrl-R moves to the next older match, Enter submits the match, Ctrl-G or Ctrl-C cancels,
+// and any other key keeps the match for editing. line is nil when nothing was chosen.
+func (e *Editor) search(s *lineState, entries []string) (line []rune, submit bool, err error) {
+	var query []rune
+	match := -1
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(entries[i], string(query)) {
+				match = i
+				return
+			}
+		}
+	}
+	for {
+		prompt := fmt.Sprintf("(reverse-i-search)`%s': ", string(query))
+		var shown []rune
+		pos := 0
+		if match >= 0 {
+			shown = []rune(entries[match])
+			if i := strings.Index(entries[match], string(query)); i >= 0 {
+				pos = utf8.RuneCountInString(entries[match][:i])
+			}
+		}
+		s.draw(prompt, shown, pos)
+
+		r, err := e.key()
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case r == ctrl('R'):
+			from := len(entries) - 1
+			if match >= 0 {
+				from = match - 1
+			}
+			find(from)
+		case r == ctrl('H') || r == 127:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = -1
+				find(len(entries) - 1)
+			}
+		case r == ctrl('G') || r == ctrl('C'):
+			return nil, false, nil
+		case r == '\r' || r == '\n':
+			return shown, match >= 0, nil
+		case r >= ' ' && unicode.IsPrint(r):
+			query = append(query, r)
+			from := len(entries) - 1
+			if match >= 0 {
+				from = match
+			}
+			match = -1
+			find(from)
+		default:
+			return shown, false, nil
+		}
+	}
+}
+
+// key reads one key press, decoding the escape sequences of arrow and editing keys
+func (e *Editor) key() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != 27 {
+		return r, err
+	}
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return keyNone, err
+	}
+	switch next {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case '[', 'O':
+	default:
+		return keyNone, nil
+	}
+	// CSI: parameters, then a final byte in @..~
+	var params strings.Builder
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return keyNone, err
+		}
+		if c >= '@' && c <= '~' {
+			return csiKey(params.String(), c), nil
+		}
+		params.WriteRune(c)
+	}
+}
+
+func csiKey(params string, final rune) rune {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		if strings.HasSuffix(params, ";5") || strings.HasSuffix(params, ";3") {
+			return keyWordRight
+		}
+		return keyRight
+	case 'D':
+		if strings.HasSuffix(params, ";5") || strings.HasSuffix(params, ";3") {
+			return keyWordLeft
+		}
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		}
+	}
+	return keyNone
+}
+
+// lineState is the line being edited and where the cursor was last drawn
+type lineState struct {
+	out  io.Writer
+	cols int
+	buf  []rune
+	pos  int
+	row  int // cursor row relative to the first row of the prompt
+}
+
+func (s *lineState) deleteAt(i int) {
+	if i < len(s.buf) {
+		s.buf = append(s.buf[:i], s.buf[i+1:]...)
+	}
+}
+
+// wordStart returns where the word before the cursor begins
+func (s *lineState) wordStart() int {
+	i := s.pos
+	for i > 0 && unicode.IsSpace(s.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(s.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// draw redraws prompt and line with the cursor at pos. Lines longer than the terminal wrap
+// onto further rows, which are cleared and redrawn together.
+func (s *lineState) draw(prompt string, line []rune, pos int) {
+	var b strings.Builder
+	if s.row > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", s.row)
+	}
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(string(line))
+	b.WriteString("\x1b[J")
+
+	width := utf8.RuneCountInString(prompt)
+	end := width + len(line)
+	// A line ending exactly at the margin leaves the cursor waiting to wrap; move it to the
+	// next row so the position below is the same on every terminal
+	if end > 0 && end%s.cols == 0 {
+		b.WriteString("\r\n")
+	}
+	cursor := width + pos
+	if up := end/s.cols - cursor/s.cols; up > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", up)
+	}
+	b.WriteString("\r")
+	if col := cursor % s.cols; col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	s.row = cursor / s.cols
+	fmt.Fprint(s.out, b.String())
+}
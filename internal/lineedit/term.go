@@ -0,0 +1,45 @@
+package lineedit
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// isTerminal reports whether f is a character device; /dev/null is one too, but putting it
+// in raw mode fails and the editor falls back to plain reading
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// stty runs stty on the terminal f; it is available wherever the REPLs are used
+// interactively and needs no platform-specific system calls
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// makeRaw switches the terminal to raw mode without echo and returns a function restoring
+// its previous settings, along with the terminal's width
+func makeRaw(f *os.File) (restore func(), cols int, err error) {
+	saved, err := stty(f, "-g")
+	if err != nil {
+		return nil, 0, err
+	}
+	cols = 80
+	if size, err := stty(f, "size"); err == nil {
+		if fields := strings.Fields(size); len(fields) == 2 {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				cols = n
+			}
+		}
+	}
+	if _, err := stty(f, "raw", "-echo"); err != nil {
+		return nil, 0, err
+	}
+	return func() { _, _ = stty(f, saved) }, cols, nil
+}
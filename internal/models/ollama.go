@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Message represents a chat message
 type Message struct {
@@ -13,6 +16,82 @@ type ChatRequest struct {
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
 	Stream   bool      `json:"stream"`
+	Options  *Options  `json:"options,omitempty"`
+	// KeepAlive is how long the model stays loaded after the request (e.g. "5m"; "0s" unloads it)
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+// Options holds Ollama generation parameters; unset fields use the model's defaults
+type Options struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	TopK          *int     `json:"top_k,omitempty"`
+	NumPredict    *int     `json:"num_predict,omitempty"` // maximum tokens to generate
+	NumCtx        *int     `json:"num_ctx,omitempty"`     // context window in tokens
+	RepeatPenalty *float64 `json:"repeat_penalty,omitempty"`
+	Seed          *int     `json:"seed,omitempty"` // fixed seed for reproducible output
+	Stop          []string `json:"stop,omitempty"`
+}
+
+// WithDefaults returns o with the fields it leaves unset taken from defaults, or nil when
+// neither sets any. Either may be nil; neither is modified.
+func (o *Options) WithDefaults(defaults *Options) *Options {
+	if o == nil {
+		o = &Options{}
+	}
+	if defaults == nil {
+		defaults = &Options{}
+	}
+	merged := *o
+	if merged.Temperature == nil {
+		merged.Temperature = defaults.Temperature
+	}
+	if merged.TopP == nil {
+		merged.TopP = defaults.TopP
+	}
+	if merged.TopK == nil {
+		merged.TopK = defaults.TopK
+	}
+	if merged.NumPredict == nil {
+		merged.NumPredict = defaults.NumPredict
+	}
+	if merged.NumCtx == nil {
+		merged.NumCtx = defaults.NumCtx
+	}
+	if merged.RepeatPenalty == nil {
+		merged.RepeatPenalty = defaults.RepeatPenalty
+	}
+	if merged.Seed == nil {
+		merged.Seed = defaults.Seed
+	}
+	if merged.Stop == nil {
+		merged.Stop = defaults.Stop
+	}
+	if merged.Temperature == nil && merged.TopP == nil && merged.TopK == nil && merged.NumPredict == nil &&
+		merged.NumCtx == nil && merged.RepeatPenalty == nil && merged.Seed == nil && merged.Stop == nil {
+		return nil
+	}
+	return &merged
+}
+
+// Validate rejects options no model accepts
+func (o *Options) Validate() error {
+	switch {
+	case o == nil:
+	case o.Temperature != nil && *o.Temperature < 0:
+		return fmt.Errorf("temperature must not be negative")
+	case o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1):
+		return fmt.Errorf("top_p must be greater than 0 and at most 1")
+	case o.TopK != nil && *o.TopK <= 0:
+		return fmt.Errorf("top_k must be positive")
+	case o.NumPredict != nil && *o.NumPredict == 0:
+		return fmt.Errorf("num_predict must not be 0")
+	case o.NumCtx != nil && *o.NumCtx <= 0:
+		return fmt.Errorf("num_ctx must be positive")
+	case o.RepeatPenalty != nil && *o.RepeatPenalty <= 0:
+		return fmt.Errorf("repeat_penalty must be positive")
+	}
+	return nil
 }
 
 // ChatResponse represents the response from Ollama chat API
@@ -21,6 +100,7 @@ type ChatResponse struct {
 	CreatedAt          time.Time `json:"created_at"`
 	Message            Message   `json:"message"`
 	Done               bool      `json:"done"`
+	DoneReason         string    `json:"done_reason,omitempty"`
 	TotalDuration      int64     `json:"total_duration"`
 	LoadDuration       int64     `json:"load_duration"`
 	PromptEvalCount    int       `json:"prompt_eval_count"`
@@ -31,8 +111,9 @@ type ChatResponse struct {
 
 // EmbeddingRequest represents the request structure for Ollama embedding API
 type EmbeddingRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	KeepAlive string `json:"keep_alive,omitempty"` // as in ChatRequest
 }
 
 // EmbeddingResponse represents the response from Ollama embedding API
@@ -40,6 +121,18 @@ type EmbeddingResponse struct {
 	Embedding []float64 `json:"embedding"`
 }
 
+// BatchEmbeddingRequest is the body of an /api/embed request, which embeds several inputs at once
+type BatchEmbeddingRequest struct {
+	Model     string   `json:"model"`
+	Input     []string `json:"input"`
+	KeepAlive string   `json:"keep_alive,omitempty"` // as in ChatRequest
+}
+
+// BatchEmbeddingResponse holds one embedding per input, in input order
+type BatchEmbeddingResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
 // ModelsResponse represents the response from Ollama models API
 type ModelsResponse struct {
 	Models []Model `json:"models"`
@@ -47,7 +140,77 @@ type ModelsResponse struct {
 
 // Model represents a single model in the models response
 type Model struct {
-	Name string `json:"name"`
+	Name    string       `json:"name"`
+	Size    int64        `json:"size,omitempty"`   // bytes on disk
+	Digest  string       `json:"digest,omitempty"` // changes when the model is pulled again
+	Details ModelDetails `json:"details,omitempty"`
+}
+
+// ModelDetails describes an installed model's family, size, and quantization
+type ModelDetails struct {
+	Family            string `json:"family,omitempty"`
+	ParameterSize     string `json:"parameter_size,omitempty"`     // e.g. "8.0B"
+	QuantizationLevel string `json:"quantization_level,omitempty"` // e.g. "Q4_K_M", "F16"
+}
+
+// ShowRequest is the body of an /api/show request
+type ShowRequest struct {
+	Model string `json:"model"`
+}
+
+// ShowResponse is the part of an /api/show response kirk-ai reads
+type ShowResponse struct {
+	// Parameters are the Modelfile's PARAMETER lines, e.g. "num_ctx 8192\nstop \"<|eot_id|>\""
+	Parameters string `json:"parameters"`
+	// ModelInfo describes the weights, e.g. "llama.context_length": 131072
+	ModelInfo map[string]interface{} `json:"model_info"`
+	Details   ModelDetails           `json:"details"`
+	Template  string                 `json:"template"`
+	License   string                 `json:"license"`
+	// Capabilities are what the model can do, e.g. "completion", "embedding", "tools",
+	// "insert", "vision", "thinking"; servers before Ollama 0.6 leave them out
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// PullRequest is the body of an /api/pull request
+type PullRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+// PullProgress is one line of a streamed /api/pull response. Downloads report the layer's
+// Digest with Total and Completed bytes; other steps report only a Status, e.g. "pulling
+// manifest" or "success".
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DeleteRequest is the body of an /api/delete request
+type DeleteRequest struct {
+	Model string `json:"model"`
+}
+
+// VersionResponse is the body of an /api/version response
+type VersionResponse struct {
+	Version string `json:"version"`
+}
+
+// RunningModelsResponse represents the response from Ollama's /api/ps
+type RunningModelsResponse struct {
+	Models []RunningModel `json:"models"`
+}
+
+// RunningModel is a model currently loaded in memory
+type RunningModel struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`      // total bytes in memory
+	SizeVRAM int64  `json:"size_vram"` // bytes of Size held in GPU memory
+	// ExpiresAt is when the model unloads unless another request keeps it
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
 }
 
 // StreamingChatResponse represents a single chunk in a streaming response
@@ -56,6 +219,7 @@ type StreamingChatResponse struct {
 	CreatedAt          time.Time `json:"created_at"`
 	Message            Message   `json:"message"`
 	Done               bool      `json:"done"`
+	DoneReason         string    `json:"done_reason,omitempty"`
 	TotalDuration      int64     `json:"total_duration,omitempty"`
 	LoadDuration       int64     `json:"load_duration,omitempty"`
 	PromptEvalCount    int       `json:"prompt_eval_count,omitempty"`
@@ -0,0 +1,154 @@
+// Package neardup finds near-duplicate texts, such as an article syndicated under several
+// URLs or a boilerplate paragraph repeated across pages with small edits. Each text is
+// reduced to a MinHash signature of its word shingles, and signatures are bucketed by
+// locality-sensitive hashing so a text is compared only with the few that share a band.
+package neardup
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultThreshold is the estimated Jaccard similarity of shingles at or above which two
+// texts count as near-duplicates
+const DefaultThreshold = 0.85
+
+const (
+	shingleWords = 5   // words per shingle
+	numHashes    = 128 // MinHash signature length
+	bandRows     = 4   // rows per LSH band: 32 bands, so pairs above ~0.4 similarity meet in one
+)
+
+// seeds are the multipliers and offsets of the signature's hash functions, fixed so a
+// text's signature is the same from run to run
+var seeds = func() (s [numHashes][2]uint64) {
+	x := uint64(0x9e3779b97f4a7c15)
+	next := func() uint64 { // splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+	for i := range s {
+		s[i] = [2]uint64{next() | 1, next()}
+	}
+	return s
+}()
+
+// Match is an earlier text that a new one nearly duplicates
+type Match struct {
+	ID         string
+	Similarity float64 // estimated Jaccard similarity of their shingles
+}
+
+// Index holds the signatures of the texts added so far
+type Index struct {
+	threshold float64
+	ids       []string
+	sigs      [][numHashes]uint64
+	buckets   map[uint64][]int
+}
+
+// New returns an empty index that reports texts at least threshold similar
+func New(threshold float64) *Index {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultThreshold
+	}
+	return &Index{threshold: threshold, buckets: make(map[uint64][]int)}
+}
+
+// Add returns the most similar earlier text that text nearly duplicates. When there is
+// none, text is added under id and ok is false; a duplicate is not added, so later texts
+// are matched against the text that was kept.
+func (x *Index) Add(id, text string) (m Match, ok bool) {
+	sig, empty := signature(text)
+	if empty {
+		return Match{}, false
+	}
+	keys := bandKeys(sig)
+	seen := map[int]bool{}
+	for _, k := range keys {
+		for _, j := range x.buckets[k] {
+			if seen[j] {
+				continue
+			}
+			seen[j] = true
+			if sim := similarity(sig, x.sigs[j]); sim >= x.threshold && sim > m.Similarity {
+				m = Match{ID: x.ids[j], Similarity: sim}
+			}
+		}
+	}
+	if m.ID != "" {
+		return m, true
+	}
+	n := len(x.ids)
+	x.ids = append(x.ids, id)
+	x.sigs = append(x.sigs, sig)
+	for _, k := range keys {
+		x.buckets[k] = append(x.buckets[k], n)
+	}
+	return Match{}, false
+}
+
+// signature is the MinHash signature of text's word shingles; texts shorter than a shingle
+// are one shingle of all their words
+func signature(text string) (sig [numHashes]uint64, empty bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return sig, true
+	}
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+	n := len(words) - shingleWords + 1
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		end := min(i+shingleWords, len(words))
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		v := h.Sum64()
+		for j, s := range seeds {
+			if hv := v*s[0] + s[1]; hv < sig[j] {
+				sig[j] = hv
+			}
+		}
+	}
+	return sig, false
+}
+
+// bandKeys hashes each band of a signature, together with its position, into a bucket key
+func bandKeys(sig [numHashes]uint64) []uint64 {
+	keys := make([]uint64, 0, numHashes/bandRows)
+	var buf [8]byte
+	for b := 0; b < numHashes; b += bandRows {
+		h := fnv.New64a()
+		binary.LittleEndian.PutUint64(buf[:], uint64(b))
+		h.Write(buf[:])
+		for _, v := range sig[b : b+bandRows] {
+			binary.LittleEndian.PutUint64(buf[:], v)
+			h.Write(buf[:])
+		}
+		keys = append(keys, h.Sum64())
+	}
+	return keys
+}
+
+// similarity estimates the Jaccard similarity of two texts as the share of their signatures
+// that agree
+func similarity(a, b [numHashes]uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / numHashes
+}
@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"kirk-ai/internal/models"
+)
+
+// ChatCompletionRequest is the body of an OpenAI-style /v1/chat/completions request.
+// Only the fields kirk-ai can honor are decoded; others are ignored.
+type ChatCompletionRequest struct {
+	Model       string           `json:"model"`
+	Messages    []models.Message `json:"messages"`
+	Stream      bool             `json:"stream,omitempty"`
+	MaxTokens   *int             `json:"max_tokens,omitempty"`
+	Temperature *float64         `json:"temperature,omitempty"`
+	TopP        *float64         `json:"top_p,omitempty"`
+	Stop        StopSequences    `json:"stop,omitempty"`
+	Seed        *int             `json:"seed,omitempty"`
+}
+
+// StopSequences accepts either a single string or an array of strings, as the OpenAI API does
+type StopSequences []string
+
+// UnmarshalJSON decodes "stop": "x" as well as "stop": ["x", "y"]
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*s = nil
+		return nil
+	}
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = StopSequences{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("stop must be a string or an array of strings")
+	}
+	*s = many
+	return nil
+}
+
+// Validate rejects requests Ollama cannot serve faithfully
+func (r *ChatCompletionRequest) Validate() error {
+	if len(r.Messages) == 0 {
+		return fmt.Errorf("messages must not be empty")
+	}
+	if r.MaxTokens != nil && *r.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be positive")
+	}
+	if r.Temperature != nil && (*r.Temperature < 0 || *r.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if len(r.Stop) > 4 {
+		return fmt.Errorf("at most 4 stop sequences are allowed")
+	}
+	return nil
+}
+
+// ToOllama maps the request onto an Ollama chat request, carrying max_tokens, stop,
+// temperature, top_p, seed, and stream over so clients get what they asked for rather than defaults.
+// model is the resolved Ollama model name.
+func (r *ChatCompletionRequest) ToOllama(model string) models.ChatRequest {
+	req := models.ChatRequest{
+		Model:    model,
+		Messages: r.Messages,
+		Stream:   r.Stream,
+	}
+
+	opts := models.Options{
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		NumPredict:  r.MaxTokens,
+		Seed:        r.Seed,
+	}
+	if len(r.Stop) > 0 {
+		opts.Stop = []string(r.Stop)
+	}
+	req.Options = opts.WithDefaults(nil)
+	return req
+}
+
+// FinishReason translates an Ollama done_reason into the OpenAI finish_reason
+func FinishReason(doneReason string) string {
+	if doneReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
@@ -0,0 +1,72 @@
+package openai
+
+import "kirk-ai/internal/models"
+
+// ChatCompletion is the body of a /v1/chat/completions response, or one server-sent event
+// of a streaming one
+type ChatCompletion struct {
+	Model   string   `json:"model"`
+	Created int64    `json:"created"`
+	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+}
+
+// Choice is one completion; Message is set in full responses and Delta in stream chunks
+type Choice struct {
+	Message      models.Message `json:"message"`
+	Delta        models.Message `json:"delta"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+// Usage reports token counts; servers may omit it, notably when streaming
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// EmbeddingRequest is the body of a /v1/embeddings request
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse is the body of a /v1/embeddings response, with one entry per input
+type EmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// ModelList is the body of a /v1/models response
+type ModelList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// FromOllama maps an Ollama chat request onto an OpenAI-style one, the reverse of ToOllama.
+// Options without an OpenAI equivalent, and keep_alive, are dropped.
+func FromOllama(req models.ChatRequest) ChatCompletionRequest {
+	r := ChatCompletionRequest{
+		Model:    req.Model,
+		Messages: req.Messages,
+		Stream:   req.Stream,
+	}
+	if req.Options != nil {
+		r.Temperature = req.Options.Temperature
+		r.TopP = req.Options.TopP
+		r.MaxTokens = req.Options.NumPredict
+		r.Stop = StopSequences(req.Options.Stop)
+		r.Seed = req.Options.Seed
+	}
+	return r
+}
+
+// DoneReason translates an OpenAI finish_reason into the Ollama done_reason, the reverse of FinishReason
+func DoneReason(finishReason *string) string {
+	if finishReason != nil && *finishReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
@@ -0,0 +1,200 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+const manifestFile = "plugin.json"
+
+// Manifest describes a plugin. It lives in <plugins-dir>/<name>/plugin.json next to the executable.
+type Manifest struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Executable  string        `json:"executable"` // relative to the plugin directory
+	Commands    []CommandSpec `json:"commands,omitempty"`
+	Sources     []string      `json:"sources,omitempty"`    // ingestion sources provided
+	Extractors  []string      `json:"extractors,omitempty"` // HTML extraction rules provided
+
+	dir string
+}
+
+// CommandSpec declares a CLI command contributed by a plugin
+type CommandSpec struct {
+	Name  string `json:"name"`
+	Short string `json:"short"`
+	Long  string `json:"long,omitempty"`
+}
+
+// Page is a document produced by an ingestion source or extractor
+type Page struct {
+	URL     string                 `json:"url"`
+	Title   string                 `json:"title"`
+	Content string                 `json:"content"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+}
+
+// CommandResult is the result of running a plugin command
+type CommandResult struct {
+	Output   string `json:"output"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// rpcRequest and rpcResponse follow JSON-RPC 2.0; one request is sent per plugin invocation
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Dir returns the directory the plugin was loaded from
+func (m *Manifest) Dir() string {
+	return m.dir
+}
+
+// Discover loads every plugin manifest found in subdirectories of dir
+func Discover(dir string) ([]*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		pdir := filepath.Join(dir, e.Name())
+		b, err := os.ReadFile(filepath.Join(pdir, manifestFile))
+		if err != nil {
+			continue
+		}
+		var m Manifest
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("plugin %s: invalid %s: %w", e.Name(), manifestFile, err)
+		}
+		if m.Name == "" {
+			m.Name = e.Name()
+		}
+		if m.Executable == "" {
+			return nil, fmt.Errorf("plugin %s: manifest has no executable", m.Name)
+		}
+		m.dir = pdir
+		manifests = append(manifests, &m)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Name < manifests[j].Name })
+	return manifests, nil
+}
+
+// call runs the plugin executable, sends one JSON-RPC request on stdin, and decodes the result.
+// Anything the plugin writes to stderr is passed through to the user.
+func (m *Manifest) call(ctx context.Context, method string, params, result interface{}) error {
+	exe := m.Executable
+	if !filepath.IsAbs(exe) {
+		exe = filepath.Join(m.dir, exe)
+	}
+
+	reqBody, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Dir = m.dir
+	cmd.Stdin = bytes.NewReader(append(reqBody, '\n'))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("plugin %s: %s failed: %w", m.Name, method, err)
+	}
+
+	var resp rpcResponse
+	if err := json.Unmarshal(bytes.TrimSpace(out), &resp); err != nil {
+		return fmt.Errorf("plugin %s: invalid JSON-RPC response: %w", m.Name, err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("plugin %s: %s (code %d)", m.Name, resp.Error.Message, resp.Error.Code)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// RunCommand invokes a plugin command with the raw CLI arguments
+func (m *Manifest) RunCommand(ctx context.Context, command string, args []string) (*CommandResult, error) {
+	var res CommandResult
+	err := m.call(ctx, "command.run", map[string]interface{}{"command": command, "args": args}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Fetch asks an ingestion source for documents
+func (m *Manifest) Fetch(ctx context.Context, source string, args []string) ([]Page, error) {
+	var res struct {
+		Pages []Page `json:"pages"`
+	}
+	err := m.call(ctx, "source.fetch", map[string]interface{}{"source": source, "args": args}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Pages, nil
+}
+
+// Extract asks an extractor to turn raw HTML into a page
+func (m *Manifest) Extract(ctx context.Context, extractor, url, html string) (*Page, error) {
+	var page Page
+	err := m.call(ctx, "extract.page", map[string]interface{}{"extractor": extractor, "url": url, "html": html}, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// FindSource returns the plugin providing the named ingestion source
+func FindSource(manifests []*Manifest, name string) *Manifest {
+	for _, m := range manifests {
+		for _, s := range m.Sources {
+			if s == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// FindExtractor returns the plugin providing the named extractor
+func FindExtractor(manifests []*Manifest, name string) *Manifest {
+	for _, m := range manifests {
+		for _, x := range m.Extractors {
+			if x == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/classify"
+	"kirk-ai/internal/extract"
+	"kirk-ai/internal/lang"
+	"kirk-ai/internal/license"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/sink"
+	"kirk-ai/internal/urlutil"
+	"kirk-ai/internal/workspace"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/spf13/pflag"
+)
+
+var unwantedPatterns = []string{
+	`Share this:.*`, `Like this:.*`, `Related posts:.*`, `Tags:.*`,
+	`Categories:.*`, `Copyright.*`, `All rights reserved.*`,
+}
+
+func cleanHTMLContent(htmlStr string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return ""
+	}
+
+	// Remove unwanted nodes
+	doc.Find("script, style, nav, header, footer, aside, form, iframe, noscript").Each(func(i int, s *goquery.Selection) {
+		s.Remove()
+	})
+
+	unwantedClasses := []string{"sidebar", "widget", "advertisement", "social-share"}
+	for _, cls := range unwantedClasses {
+		doc.Find("." + cls).Each(func(i int, s *goquery.Selection) { s.Remove() })
+	}
+
+	text := strings.TrimSpace(doc.Text())
+	return cleanText(text)
+}
+
+func cleanText(text string) string {
+	// Remove unwanted patterns
+	for _, p := range unwantedPatterns {
+		r := regexp.MustCompile(`(?i)` + p)
+		text = r.ReplaceAllString(text, "")
+	}
+	// Normalize whitespace
+	rws := regexp.MustCompile(`\s+`)
+	text = rws.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
+
+func extractStructuredData(htmlStr string) map[string]interface{} {
+	res := map[string]interface{}{}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return res
+	}
+
+	// Extract JSON-LD
+	jsonLD := []interface{}{}
+	doc.Find("script[type='application/ld+json']").Each(func(i int, s *goquery.Selection) {
+		if t := strings.TrimSpace(s.Text()); t != "" {
+			var v interface{}
+			if err := json.Unmarshal([]byte(t), &v); err == nil {
+				jsonLD = append(jsonLD, v)
+			}
+		}
+	})
+	if len(jsonLD) > 0 {
+		res["json_ld"] = jsonLD
+	}
+
+	// Open Graph
+	og := map[string]string{}
+	doc.Find("meta").Each(func(i int, s *goquery.Selection) {
+		if p, _ := s.Attr("property"); strings.HasPrefix(p, "og:") {
+			og[strings.TrimPrefix(p, "og:")] = s.AttrOr("content", "")
+		}
+	})
+	if len(og) > 0 {
+		res["open_graph"] = og
+	}
+	return res
+}
+
+// extractImageText returns image alt text and figure captions from the page's content area
+func extractImageText(htmlStr string) []string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return nil
+	}
+	doc.Find("nav, header, footer, aside, form").Remove()
+	return extract.ImageText(doc.Selection)
+}
+
+// detectLicense returns the license and copyright signals of the raw page, before the
+// footer and copyright lines are cleaned out of its content
+func detectLicense(htmlStr string) license.Info {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return license.Info{}
+	}
+	return license.Detect(doc)
+}
+
+// readArticle extracts the article of a raw page, cleaning each of its blocks of text on its
+// own so a "Tags:" line drops only itself, and returns it with its cleaned text, blocks
+// separated by blank lines for the chunker to split at
+func readArticle(htmlStr string) (extract.Article, string) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return extract.Article{}, ""
+	}
+	art := extract.Readable(doc)
+	var blocks []string
+	for _, block := range strings.Split(art.Content, "\n\n") {
+		if block = cleanText(block); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return art, strings.Join(blocks, "\n\n")
+}
+
+// pageLanguage returns the ISO 639-1 code of the language content is written in or, when the
+// text is too short or mixed to tell, of the language the page declares
+func pageLanguage(htmlStr, content string) string {
+	if g := lang.Detect(content); g.Language != "" {
+		return lang.Code(g.Language)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return lang.Undetermined
+	}
+	declared := doc.Find("html").AttrOr("lang", "")
+	if declared == "" {
+		declared = doc.Find(`meta[http-equiv="content-language"], meta[http-equiv="Content-Language"], meta[property="og:locale"]`).First().AttrOr("content", "")
+	}
+	if code := lang.Code(declared); code != "" {
+		return code
+	}
+	return lang.Undetermined
+}
+
+// extractSections returns the page's headings located in its cleaned content
+func extractSections(htmlStr, content string) []chunker.Section {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return nil
+	}
+	doc.Find("script, style, nav, header, footer, aside, form, iframe, noscript").Remove()
+	return extract.Sections(doc.Selection, content)
+}
+
+// crawledPage is what the crawler recorded about a page besides its snapshot
+type crawledPage struct {
+	prov  provenance.Record
+	class classify.Result
+}
+
+// loadCrawlProvenance maps page URLs to the provenance and classification the crawler
+// recorded for them. It also returns the URLs by the snapshot names crawlers used before the
+// URL mapping file, so older snapshot directories still resolve. A missing or unreadable
+// results file yields empty maps.
+func loadCrawlProvenance(path string) (byURL map[string]crawledPage, legacy map[string]string) {
+	byURL, legacy = map[string]crawledPage{}, map[string]string{}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return byURL, legacy
+	}
+	var pages []map[string]interface{}
+	if err := json.Unmarshal(b, &pages); err != nil {
+		log.Printf("warning: could not parse %s: %v", path, err)
+		return byURL, legacy
+	}
+	for _, page := range pages {
+		u, _ := page["url"].(string)
+		if u == "" {
+			continue
+		}
+		byURL[u] = crawledPage{prov: provenance.Get(page), class: classify.Get(page)}
+		legacy[urlutil.Sanitize(u)+".html"] = u
+	}
+	return byURL, legacy
+}
+
+// processRawHTMLDir cleans every snapshot in rawDir into a record of outFile. With readable,
+// a record holds only the page's article, with its title, byline, and published date;
+// without it, the page's text less its navigation. Pages the crawler did not classify are
+// classified with cls when it is set.
+func processRawHTMLDir(rawDir, outFile string, readable, withImages bool, cls classify.Classifier) {
+	files, err := ioutil.ReadDir(rawDir)
+	if err != nil {
+		log.Fatalf("read dir: %v", err)
+	}
+	out := sink.New(sink.Options{BufferSize: 100}, sink.NewJSON[map[string]interface{}](outFile, false))
+	urlMap, err := urlutil.LoadMapping(filepath.Join(rawDir, urlutil.MappingFile))
+	if err != nil {
+		log.Printf("warning: could not read URL mapping: %v", err)
+		urlMap = &urlutil.Mapping{}
+	}
+	crawled, legacy := loadCrawlProvenance(workspace.Path(workspace.CollyResults))
+	// crawl requests --raw saves snapshots too
+	fromRequests, _ := loadCrawlProvenance(workspace.Path(workspace.RequestsResults))
+	for u, page := range fromRequests {
+		if _, ok := crawled[u]; !ok {
+			crawled[u] = page
+		}
+	}
+	stage := provenance.Record{ProcessorVersion: provenance.Version()}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), ".html") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(rawDir, f.Name()))
+		if err != nil {
+			log.Printf("read %s: %v", f.Name(), err)
+			continue
+		}
+		h := string(b)
+		var art extract.Article
+		var clean string
+		if readable {
+			art, clean = readArticle(h)
+		} else {
+			clean = cleanHTMLContent(h)
+		}
+		meta := extractStructuredData(h)
+		u, ok := urlMap.URL(f.Name())
+		if !ok {
+			u = legacy[f.Name()]
+		}
+		crawl := crawled[u]
+		rec := map[string]interface{}{"file": f.Name(), "content": clean, "meta": meta, provenance.MetadataKey: crawl.prov.Merge(stage).Map()}
+		if u != "" {
+			rec["url"] = u
+		}
+		for key, v := range map[string]string{"title": art.Title, "byline": art.Byline, "published": art.Published} {
+			if v != "" {
+				rec[key] = v
+			}
+		}
+		rec["lang"] = pageLanguage(h, clean)
+		class := crawl.class
+		if class.IsZero() && cls != nil {
+			class = classifySnapshot(cls, u, h, clean)
+		}
+		if !class.IsZero() {
+			rec[classify.MetadataKey] = class.Map()
+		}
+		var sections []chunker.Section
+		if art.Node != nil {
+			sections = extract.Sections(art.Node, clean)
+		} else {
+			sections = extractSections(h, clean)
+		}
+		if len(sections) > 0 {
+			rec["sections"] = sections
+		}
+		if lic := detectLicense(h); !lic.IsZero() {
+			rec[license.MetadataKey] = lic.Map()
+		}
+		if withImages {
+			var captions []string
+			if art.Node != nil {
+				captions = extract.ImageText(art.Node)
+			} else {
+				captions = extractImageText(h)
+			}
+			if len(captions) > 0 {
+				rec["captions"] = captions
+			}
+		}
+		out.Add(rec)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("write %s: %v", outFile, err)
+	}
+	fmt.Printf("processed %d files -> %s\n", out.Count(), outFile)
+}
+
+// classifySnapshot classifies a raw HTML snapshot whose cleaned text is content
+func classifySnapshot(cls classify.Classifier, u, htmlStr, content string) classify.Result {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return classify.Result{}
+	}
+	sig := classify.Signals(u, doc.Selection)
+	sig.Content = content
+	r, err := cls.Classify(context.Background(), sig)
+	if err != nil {
+		log.Printf("classify %s: %v", u, err)
+		return classify.Result{}
+	}
+	return r
+}
+
+func contentProcessor(fs *pflag.FlagSet) func(verbose bool) {
+	var withImages bool
+	var extraction, method, rulesPath, model, ollamaURL string
+	fs.StringVar(&extraction, "extract", "readability", "how to find a page's text: readability keeps only the article body, title, byline, and date; basic keeps everything but navigation, headers, and footers")
+	fs.BoolVar(&withImages, "images", false, "also extract image alt text and figure captions as auxiliary text")
+	fs.StringVar(&method, "classify", "off", "tag pages the crawler did not classify as article, event, product, or landing page: rules, llm, or off")
+	fs.StringVar(&rulesPath, "classify-rules", "", "JSON file of classification rules replacing the built-in ones")
+	fs.StringVar(&model, "classify-model", classify.DefaultLLMModel, "chat model used by --classify llm")
+	fs.StringVar(&ollamaURL, "ollama-url", "http://localhost:11434", "Ollama server used by --classify llm")
+	return func(bool) {
+		if extraction != "readability" && extraction != "basic" {
+			log.Fatalf("content: unknown --extract %q: use readability or basic", extraction)
+		}
+		cls, err := classify.New(method, rulesPath, model, ollamaURL)
+		if err != nil {
+			log.Fatalf("content: %v", err)
+		}
+		out := workspace.Path(workspace.ProcessedPages)
+		ensureDir(filepath.Dir(out))
+		processRawHTMLDir(workspace.Path(workspace.RawHTML), out, extraction == "readability", withImages, cls)
+	}
+}
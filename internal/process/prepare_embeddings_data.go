@@ -0,0 +1,423 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/classify"
+	"kirk-ai/internal/lang"
+	"kirk-ai/internal/license"
+	"kirk-ai/internal/neardup"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/redact"
+	"kirk-ai/internal/workspace"
+
+	"github.com/spf13/pflag"
+)
+
+// prepOptions are the settings of embedprep
+type prepOptions struct {
+	profiles  map[classify.Type]classify.Profile
+	split     chunker.Options  // strategy, overlap, and tokenizer; the profile sets the chunk size
+	langs     map[string]bool  // languages to keep; all when empty
+	splitLang bool             // also write each language's chunks to a file of their own
+	nearDup   float64          // similarity at which a chunk is dropped as a near-duplicate; 0 drops only exact ones
+	report    string           // where to write the chunks dropped as duplicates
+	redactor  *redact.Redactor // masks personal information in each page before it is chunked; nil keeps it
+	redactAsk redact.Ask       // asks --redact-model for what the patterns miss; nil asks no model
+}
+
+// droppedChunk is an entry of the dedup report: a chunk left out as a copy of one kept
+type droppedChunk struct {
+	ID          string  `json:"id"`
+	SourceURL   string  `json:"source_url,omitempty"`
+	DuplicateOf string  `json:"duplicate_of"`
+	Kind        string  `json:"kind"` // "exact" (same opening) or "near" (similar shingles)
+	Similarity  float64 `json:"similarity,omitempty"`
+	Preview     string  `json:"preview"`
+}
+
+// processForEmbeddings splits the processed pages into embedding-ready chunks. Classified
+// pages are chunked with the profile of their type; unclassified pages use the defaults.
+// Each chunk is tagged with its language, and chunks that repeat an earlier one, exactly or
+// nearly, are dropped and reported.
+func processForEmbeddings(inputFile, outputFile string, opts prepOptions) {
+	profiles, split, langs := opts.profiles, opts.split, opts.langs
+	b, err := os.ReadFile(inputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var pages []map[string]interface{}
+	if err := json.Unmarshal(b, &pages); err != nil {
+		log.Fatal(err)
+	}
+
+	out := []map[string]interface{}{}
+	seenContent := make(map[string]string) // For deduplication: the ID of the chunk each key was first seen in
+	var near *neardup.Index
+	if opts.nearDup > 0 {
+		near = neardup.New(opts.nearDup)
+	}
+	var dropped []droppedChunk
+	drop := func(id string, page map[string]interface{}, c, of, kind string, sim float64) {
+		preview := c
+		if len(preview) > 120 {
+			preview = strings.ToValidUTF8(preview[:120], "") + "..."
+		}
+		u, _ := page["url"].(string)
+		dropped = append(dropped, droppedChunk{ID: id, SourceURL: u, DuplicateOf: of, Kind: kind, Similarity: sim, Preview: preview})
+	}
+	skipped := map[classify.Type]int{}
+	filtered := map[string]int{}
+	redacted, redactedPages := redact.Counts{}, 0
+
+	for pageIndex, page := range pages {
+		content, _ := page["content"].(string)
+		if content == "" {
+			continue
+		}
+		class := classify.Get(page)
+		profile := classify.Profile{MaxTokens: chunker.DefaultMaxTokens, Captions: true}
+		if !class.IsZero() {
+			profile = profiles[class.Type]
+		}
+		if profile.Skip {
+			skipped[class.Type]++
+			continue
+		}
+		stage := provenance.Record{
+			ProcessorVersion: provenance.Version(),
+			Chunker:          &provenance.Chunking{Strategy: split.Strategy, MaxTokens: profile.ChunkTokens(), Profile: string(class.Type)},
+		}
+		if split.Strategy == chunker.SemanticStrategy {
+			stage.Chunker.Overlap = split.Overlap
+		}
+		if name := split.Tokenizer.Name(); name != chunker.Estimate.Name() {
+			stage.Chunker.Tokenizer = name
+		}
+
+		// Get URL or generate a fallback identifier
+		var baseID string
+		if url, ok := page["url"].(string); ok && url != "" {
+			baseID = url
+		} else {
+			// Generate a unique identifier for pages without URLs
+			baseID = fmt.Sprintf("page_%d", pageIndex)
+		}
+		// Pages split by the crawler's overflow truncation share a URL; keep their chunk IDs distinct
+		if part, ok := page["part"].(float64); ok && part > 0 {
+			baseID = fmt.Sprintf("%s#part_%d", baseID, int(part))
+		}
+
+		// Carry the crawl's provenance forward with this run's processing settings
+		prov := provenance.Get(page).Merge(stage).Map()
+		lic := license.Get(page)
+		pageLang, _ := page["lang"].(string)
+		if pageLang == "" {
+			pageLang = lang.Undetermined
+		}
+
+		sections := sectionList(page["sections"])
+		captions := stringList(page["captions"])
+		if opts.redactor != nil {
+			// Masking shifts the text after it, so the section offsets are those of the original
+			// content and would no longer line up; they are dropped for the pages changed
+			var counts redact.Counts
+			content, captions, counts = redactPage(opts, baseID, content, captions)
+			if counts.Total() > 0 {
+				redacted.Add(counts)
+				redactedPages++
+				sections = nil
+			}
+		}
+		opts := split
+		opts.MaxTokens, opts.Sections = profile.ChunkTokens(), sections
+		chunks := chunker.Split(content, opts)
+
+		// Skip pages that produce no valid chunks
+		if len(chunks) == 0 {
+			continue
+		}
+
+		for i, sp := range chunks {
+			c := sp.Text
+			chunkLang := textLanguage(c, pageLang)
+			if len(langs) > 0 && !langs[chunkLang] {
+				filtered[chunkLang]++
+				continue
+			}
+			id := fmt.Sprintf("%s#chunk_%d", baseID, i)
+			// Deduplicate similar content
+			contentKey := strings.ToLower(strings.TrimSpace(c))
+			if len(contentKey) < 50 { // For short content, be more strict about duplicates
+				if of, ok := seenContent[contentKey]; ok {
+					drop(id, page, c, of, "exact", 0)
+					continue
+				}
+				seenContent[contentKey] = id
+			} else {
+				// For longer content, check first 100 characters to avoid near-duplicates
+				keyPrefix := contentKey
+				if len(keyPrefix) > 100 {
+					keyPrefix = keyPrefix[:100]
+				}
+				if of, ok := seenContent[keyPrefix]; ok {
+					drop(id, page, c, of, "exact", 0)
+					continue
+				}
+				seenContent[keyPrefix] = id
+			}
+			// Syndicated or lightly edited copies differ in their opening but share most shingles
+			if near != nil {
+				if m, ok := near.Add(id, c); ok {
+					drop(id, page, c, m.ID, "near", m.Similarity)
+					continue
+				}
+			}
+
+			// Offsets, section and position let citations deep-link into the source page
+			metadata := chunker.SpanMetadata(content, sp, sections)
+			metadata["crawled_at"] = time.Now().Format(time.RFC3339)
+			metadata["source_url"] = page["url"]
+			metadata["title"] = page["title"]
+			metadata["lang"] = chunkLang
+			for _, key := range []string{"byline", "published"} {
+				if v, ok := page[key].(string); ok && v != "" {
+					metadata[key] = v
+				}
+			}
+			metadata["content_hash"] = chunker.ContentHash(c)
+			metadata["word_count"] = len(strings.Fields(c))
+			metadata["char_count"] = len(c)
+			metadata["provenance"] = prov
+			if !class.IsZero() {
+				metadata["page_type"] = string(class.Type)
+			}
+			if !lic.IsZero() {
+				metadata[license.MetadataKey] = lic.Map()
+			}
+
+			doc := map[string]interface{}{
+				"id":           id,
+				"source_url":   page["url"],
+				"title":        page["title"],
+				"content":      c,
+				"chunk_index":  i,
+				"total_chunks": len(chunks),
+				"metadata":     metadata,
+			}
+			out = append(out, doc)
+		}
+
+		// Image alt text and captions become auxiliary chunks of the page, since key facts
+		// often live only there
+		if len(captions) == 0 || !profile.Captions || (len(langs) > 0 && !langs[pageLang]) {
+			continue
+		}
+		opts.Sections = nil
+		auxChunks := chunker.Split("Image descriptions: "+strings.Join(captions, ". "), opts)
+		for i, sp := range auxChunks {
+			c := sp.Text
+			metadata := map[string]interface{}{
+				"crawled_at":   time.Now().Format(time.RFC3339),
+				"source_url":   page["url"],
+				"title":        page["title"],
+				"content_hash": chunker.ContentHash(c),
+				"word_count":   len(strings.Fields(c)),
+				"char_count":   len(c),
+				"token_count":  sp.Tokens,
+				"aux_kind":     "image_text",
+				"lang":         pageLang,
+				"provenance":   prov,
+			}
+			if !class.IsZero() {
+				metadata["page_type"] = string(class.Type)
+			}
+			if !lic.IsZero() {
+				metadata[license.MetadataKey] = lic.Map()
+			}
+			out = append(out, map[string]interface{}{
+				"id":           fmt.Sprintf("%s#aux_%d", baseID, i),
+				"source_url":   page["url"],
+				"title":        page["title"],
+				"content":      c,
+				"chunk_index":  len(chunks) + i,
+				"total_chunks": len(chunks) + len(auxChunks),
+				"metadata":     metadata,
+			})
+		}
+	}
+	writeChunks(outputFile, out)
+	log.Printf("Processed %d chunks for embeddings", len(out))
+	if opts.redactor != nil {
+		if redactedPages > 0 {
+			log.Printf("Redacted %s in %d pages", redacted, redactedPages)
+		} else {
+			log.Printf("Found nothing to redact")
+		}
+	}
+	for t, n := range skipped {
+		log.Printf("Skipped %d %s pages (profile skip)", n, t)
+	}
+	for l, n := range filtered {
+		log.Printf("Left out %d %s chunks (--lang)", n, l)
+	}
+	// The report is rewritten even when empty so it never describes an earlier run
+	if dropped == nil {
+		dropped = []droppedChunk{}
+	}
+	ob, _ := json.MarshalIndent(dropped, "", "  ")
+	if err := atomicfile.WriteFile(opts.report, ob, 0o644, false); err != nil {
+		log.Fatalf("write dedup report: %v", err)
+	}
+	if len(dropped) > 0 {
+		exact := 0
+		for _, d := range dropped {
+			if d.Kind == "exact" {
+				exact++
+			}
+		}
+		log.Printf("Dropped %d duplicate chunks (%d exact, %d near); see %s", len(dropped), exact, len(dropped)-exact, opts.report)
+	}
+	if opts.splitLang {
+		byLang := map[string][]map[string]interface{}{}
+		for _, doc := range out {
+			l, _ := doc["metadata"].(map[string]interface{})["lang"].(string)
+			byLang[l] = append(byLang[l], doc)
+		}
+		for l, docs := range byLang {
+			path := langFile(outputFile, l)
+			writeChunks(path, docs)
+			log.Printf("Wrote %d %s chunks to %s", len(docs), l, path)
+		}
+	}
+}
+
+// redactPage masks personal information in a page's content and captions. A page the model
+// cannot check stops the run, since its chunks would otherwise be embedded unmasked.
+func redactPage(opts prepOptions, id, content string, captions []string) (string, []string, redact.Counts) {
+	counts := redact.Counts{}
+	text, c, err := opts.redactor.RedactWith(content, opts.redactAsk)
+	if err != nil {
+		log.Fatalf("embedprep: redacting %s: %v", id, err)
+	}
+	counts.Add(c)
+	masked := make([]string, len(captions))
+	for i, caption := range captions {
+		if masked[i], c, err = opts.redactor.RedactWith(caption, opts.redactAsk); err != nil {
+			log.Fatalf("embedprep: redacting %s: %v", id, err)
+		}
+		counts.Add(c)
+	}
+	return text, masked, counts
+}
+
+// writeChunks writes embedding-ready chunks to path
+func writeChunks(path string, chunks []map[string]interface{}) {
+	ob, _ := json.MarshalIndent(chunks, "", "  ")
+	if err := atomicfile.WriteFile(path, ob, 0o644, true); err != nil {
+		log.Fatalf("write output: %v", err)
+	}
+}
+
+// langFile names the file of one language's chunks after the output file, such as
+// tpusa_embeddings_ready.es.json
+func langFile(outputFile, code string) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "." + code + ext
+}
+
+// minChunkConfidence is how sure the detector must be of a chunk's language to overrule
+// its page's; short chunks carry little evidence and quotes in other languages are common
+const minChunkConfidence = 0.6
+
+// textLanguage returns the ISO 639-1 code of the language a chunk is written in, or its
+// page's language when the chunk alone does not tell
+func textLanguage(text, pageLang string) string {
+	if g := lang.Detect(text); g.Language != "" && g.Confidence >= minChunkConfidence {
+		return lang.Code(g.Language)
+	}
+	return pageLang
+}
+
+// stringList converts a decoded JSON array into strings, skipping non-string values
+func stringList(v interface{}) []string {
+	arr, _ := v.([]interface{})
+	out := make([]string, 0, len(arr))
+	for _, x := range arr {
+		if s, ok := x.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// sectionList converts the decoded "sections" recorded by the content processor
+func sectionList(v interface{}) []chunker.Section {
+	arr, _ := v.([]interface{})
+	out := make([]chunker.Section, 0, len(arr))
+	for _, x := range arr {
+		m, _ := x.(map[string]interface{})
+		heading, _ := m["heading"].(string)
+		level, _ := m["level"].(float64)
+		offset, _ := m["offset"].(float64)
+		if heading != "" {
+			out = append(out, chunker.Section{Heading: heading, Level: int(level), Offset: int(offset)})
+		}
+	}
+	return out
+}
+
+func prepareEmbeddings(fs *pflag.FlagSet) func(verbose bool) {
+	var profilesPath string
+	var langList []string
+	var tokenizerPath string
+	var redactOn bool
+	var redactModel string
+	var opts prepOptions
+	split := &opts.split
+	fs.StringVar(&split.Strategy, "strategy", chunker.SemanticStrategy, "how pages are split: semantic (sentences packed up to paragraph and heading boundaries, with overlap) or sentence (sentences packed without overlap, as before)")
+	fs.IntVar(&split.Overlap, "overlap", chunker.DefaultOverlap, "tokens at the end of each chunk repeated at the start of the next (semantic strategy)")
+	fs.StringVar(&tokenizerPath, "tokenizer", "", "tokenizer.json or vocab.txt of the embedding model, to count tokens exactly (default: estimate 1.3 tokens per word)")
+	fs.StringVar(&profilesPath, "profiles", "", "JSON file of chunking profiles by page type (max_tokens, captions, skip), merged over the defaults")
+	fs.StringSliceVar(&langList, "lang", nil, "keep only chunks in these languages, as ISO 639-1 codes such as en,es (und = undetermined)")
+	fs.Float64Var(&opts.nearDup, "near-dup", neardup.DefaultThreshold, "drop chunks whose five-word shingles are at least this similar (Jaccard, 0-1) to an earlier chunk's; 0 drops only chunks with the same opening")
+	fs.BoolVar(&opts.splitLang, "split-lang", false, "also write each language's chunks to a file of their own, such as tpusa_embeddings_ready.es.json, for embedding with a model suited to it")
+	fs.BoolVar(&redactOn, "redact", false, "mask email addresses, phone numbers, card and social security numbers, IP addresses, and the patterns under \"redact\" in ~/.kirk-ai/config.json before chunking")
+	fs.StringVar(&redactModel, "redact-model", "", "also ask this chat model for personal information the patterns miss, such as names and street addresses; implies --redact")
+	return func(bool) {
+		opts.langs = map[string]bool{}
+		for _, l := range langList {
+			opts.langs[lang.Code(l)] = true
+		}
+		if split.Strategy != chunker.SemanticStrategy && split.Strategy != chunker.Strategy {
+			log.Fatalf("embedprep: unknown --strategy %q: use %s or %s", split.Strategy, chunker.SemanticStrategy, chunker.Strategy)
+		}
+		var err error
+		if opts.profiles, err = classify.LoadProfiles(profilesPath); err != nil {
+			log.Fatalf("embedprep: %v", err)
+		}
+		if split.Tokenizer, err = chunker.LoadTokenizer(tokenizerPath); err != nil {
+			log.Fatalf("embedprep: %v", err)
+		}
+		if redactOn || redactModel != "" {
+			if opts.redactor, err = redact.Load(); err != nil {
+				log.Fatalf("embedprep: %v", err)
+			}
+		}
+		if redactModel != "" {
+			opts.redactAsk = func(prompt string) (string, error) { return Chat(redactModel, prompt) }
+		}
+		out := workspace.Path(workspace.EmbeddingsReady)
+		ensureDir(filepath.Dir(out))
+		opts.report = workspace.Path(workspace.DedupReport)
+		processForEmbeddings(workspace.Path(workspace.ProcessedPages), out, opts)
+	}
+}
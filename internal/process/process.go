@@ -0,0 +1,23 @@
+// Package process holds the steps behind the process command that turn raw crawl results
+// into cleaned pages and then into chunks ready for embedding
+package process
+
+import "github.com/spf13/pflag"
+
+// Tool is one processing step. Setup registers its flags on fs and returns the function that
+// runs it once they are parsed; verbose is the CLI's --verbose.
+type Tool struct {
+	Name  string
+	Short string
+	Setup func(fs *pflag.FlagSet) func(verbose bool)
+}
+
+// Tools lists the processing steps in the order they run
+var Tools = []Tool{
+	{Name: "content", Short: "Clean raw crawl results into processed page JSON", Setup: contentProcessor},
+	{Name: "embedprep", Short: "Split processed pages into embedding-ready chunks", Setup: prepareEmbeddings},
+}
+
+// Chat sends prompt to a chat model and returns its reply. The CLI sets it to its client, for
+// steps that ask a model, such as embedprep --redact-model.
+var Chat func(model, prompt string) (string, error)
@@ -0,0 +1,232 @@
+// Package progress shows how far a long bulk job (embedding, crawling) has got on a single
+// line: percentage, rate, ETA, and failures, ending with a one-line summary
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redrawInterval limits how often a terminal line is redrawn
+	redrawInterval = 200 * time.Millisecond
+	// logInterval is how often an unknown-length job logs its progress when the output is
+	// not a terminal; jobs of known length log every tenth instead
+	logInterval = 30 * time.Second
+	barWidth    = 24
+)
+
+// Bar tracks a job's progress and renders it. On a terminal the line is redrawn in place;
+// otherwise, as when the output is redirected to a file, a line is written at every tenth of
+// the job so the log stays readable. Messages written through the Bar (it is an io.Writer)
+// appear above the line instead of breaking it. It is safe for concurrent use, and the
+// counting methods of a nil Bar do nothing, so callers need not check for one.
+type Bar struct {
+	mu       sync.Mutex
+	w        io.Writer
+	label    string
+	tty      bool
+	start    time.Time
+	total    int
+	done     int
+	failed   int
+	drawn    int // width of the line on screen, 0 when none is shown
+	lastDraw time.Time
+	logged   int // tenths of the job already logged
+	finished bool
+}
+
+// New starts tracking a job of total units (0 when unknown) under label, rendering to w
+func New(w io.Writer, label string, total int) *Bar {
+	tty := false
+	if f, ok := w.(*os.File); ok {
+		fi, err := f.Stat()
+		tty = err == nil && fi.Mode()&os.ModeCharDevice != 0
+	}
+	return &Bar{w: w, label: label, tty: tty, start: time.Now(), total: total}
+}
+
+// AddTotal grows the job, for jobs that discover their work as they go
+func (b *Bar) AddTotal(n int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total += n
+	b.draw(false)
+}
+
+// Add records n units done
+func (b *Bar) Add(n int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done += n
+	b.draw(false)
+}
+
+// Fail records n units that failed; they count as done
+func (b *Bar) Fail(n int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done += n
+	b.failed += n
+	b.draw(false)
+}
+
+// Write prints p above the progress line
+func (b *Bar) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+	n, err := b.w.Write(p)
+	if !b.finished {
+		b.draw(true)
+	}
+	return n, err
+}
+
+// Finish renders the final state, ends the progress line, and returns the job's summary
+func (b *Bar) Finish() Summary {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.finished {
+		b.draw(true)
+		if b.drawn > 0 {
+			fmt.Fprintln(b.w)
+			b.drawn = 0
+		}
+		b.finished = true
+	}
+	return b.summary()
+}
+
+func (b *Bar) summary() Summary {
+	return Summary{Label: b.label, Total: b.total, Done: b.done, Failed: b.failed, Elapsed: time.Since(b.start)}
+}
+
+// draw renders the line when it is due, or on a terminal always when force is set
+func (b *Bar) draw(force bool) {
+	if b.finished {
+		return
+	}
+	s := b.summary()
+	if !b.tty {
+		// Logged lines are never redrawn, so a forced draw has nothing to refresh
+		if force {
+			return
+		}
+		if b.total > 0 {
+			tenth := b.done * 10 / b.total
+			if tenth <= b.logged {
+				return
+			}
+			b.logged = tenth
+		} else if time.Since(b.lastDraw) < logInterval {
+			return
+		}
+		b.lastDraw = time.Now()
+		fmt.Fprintln(b.w, s.line(false))
+		return
+	}
+	if !force && time.Since(b.lastDraw) < redrawInterval && b.done != b.total {
+		return
+	}
+	b.lastDraw = time.Now()
+	line := s.line(true)
+	pad := ""
+	if n := b.drawn - len(line); n > 0 {
+		pad = strings.Repeat(" ", n)
+	}
+	fmt.Fprint(b.w, "\r"+line+pad)
+	b.drawn = len(line)
+}
+
+// clear blanks the progress line so a message can take its place
+func (b *Bar) clear() {
+	if b.drawn > 0 {
+		fmt.Fprint(b.w, "\r"+strings.Repeat(" ", b.drawn)+"\r")
+		b.drawn = 0
+	}
+}
+
+// Summary is a job's progress at a point in time
+type Summary struct {
+	Label   string
+	Total   int // 0 when unknown
+	Done    int // including failed
+	Failed  int
+	Elapsed time.Duration
+}
+
+// Rate returns the units done per second
+func (s Summary) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Done) / s.Elapsed.Seconds()
+}
+
+// ETA estimates the time left at the current rate, or -1 when it cannot be estimated
+func (s Summary) ETA() time.Duration {
+	rate := s.Rate()
+	if s.Total <= 0 || rate == 0 {
+		return -1
+	}
+	left := s.Total - s.Done
+	if left < 0 {
+		left = 0
+	}
+	return time.Duration(float64(left) / rate * float64(time.Second))
+}
+
+// String reports the finished job, e.g. "Embedding chunks: 2990/3000 in 1m2s (48.2/s), 10 failed"
+func (s Summary) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d", s.Label, s.Done)
+	if s.Total > 0 {
+		fmt.Fprintf(&sb, "/%d", s.Total)
+	}
+	fmt.Fprintf(&sb, " in %v (%.1f/s)", s.Elapsed.Round(time.Second/10), s.Rate())
+	if s.Failed > 0 {
+		fmt.Fprintf(&sb, ", %d failed", s.Failed)
+	}
+	return sb.String()
+}
+
+// line renders the progress, with a bar when drawn on a terminal
+func (s Summary) line(bar bool) string {
+	var sb strings.Builder
+	sb.WriteString(s.Label)
+	if s.Total > 0 {
+		pct := float64(s.Done) / float64(s.Total)
+		if pct > 1 {
+			pct = 1
+		}
+		if bar {
+			filled := int(pct * barWidth)
+			fmt.Fprintf(&sb, " [%s%s]", strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled))
+		}
+		fmt.Fprintf(&sb, " %3.0f%% %d/%d", pct*100, s.Done, s.Total)
+	} else {
+		fmt.Fprintf(&sb, " %d", s.Done)
+	}
+	fmt.Fprintf(&sb, "  %.1f/s", s.Rate())
+	if eta := s.ETA(); eta >= 0 && s.Done < s.Total {
+		fmt.Fprintf(&sb, "  ETA %v", eta.Round(time.Second))
+	}
+	if s.Failed > 0 {
+		fmt.Fprintf(&sb, "  %d failed", s.Failed)
+	}
+	return sb.String()
+}
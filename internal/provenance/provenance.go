@@ -0,0 +1,157 @@
+package provenance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"runtime/debug"
+	"time"
+)
+
+// MetadataKey is the key under which provenance is stored in page records and chunk metadata
+const MetadataKey = "provenance"
+
+// Record traces a chunk back to the crawl, processing, and embedding settings that produced it.
+// Each pipeline stage fills in its own fields and keeps the ones set by earlier stages.
+type Record struct {
+	CrawlRunID       string    `json:"crawl_run_id,omitempty"`
+	Crawler          string    `json:"crawler,omitempty"`
+	FetchedAt        string    `json:"fetched_at,omitempty"`
+	ProcessorVersion string    `json:"processor_version,omitempty"`
+	Chunker          *Chunking `json:"chunker,omitempty"`
+	EmbeddingModel   string    `json:"embedding_model,omitempty"`
+	EmbeddedAt       string    `json:"embedded_at,omitempty"`
+}
+
+// Chunking records the chunker settings used to split a page
+type Chunking struct {
+	Strategy  string `json:"strategy"`
+	MaxTokens int    `json:"max_tokens"`
+	Profile   string `json:"profile,omitempty"` // page type whose profile set MaxTokens
+	Overlap   int    `json:"overlap,omitempty"`
+	Tokenizer string `json:"tokenizer,omitempty"` // vocabulary file tokens were counted with, if any
+}
+
+// NewRunID returns an identifier for a crawl run: a UTC timestamp plus a random suffix
+func NewRunID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// Now returns the current time in the format used for provenance timestamps
+func Now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// Version identifies the build of the running tool: the module version, or the VCS revision
+// for development builds
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	rev, dirty := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if rev == "" {
+		return "devel"
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if dirty {
+		rev += "-dirty"
+	}
+	return rev
+}
+
+// Get reads the provenance stored in a record or metadata map; missing provenance yields a zero Record
+func Get(m map[string]interface{}) Record {
+	var r Record
+	if m == nil || m[MetadataKey] == nil {
+		return r
+	}
+	b, err := json.Marshal(m[MetadataKey])
+	if err != nil {
+		return r
+	}
+	_ = json.Unmarshal(b, &r)
+	return r
+}
+
+// Merge returns r with every non-empty field of update applied on top
+func (r Record) Merge(update Record) Record {
+	if update.CrawlRunID != "" {
+		r.CrawlRunID = update.CrawlRunID
+	}
+	if update.Crawler != "" {
+		r.Crawler = update.Crawler
+	}
+	if update.FetchedAt != "" {
+		r.FetchedAt = update.FetchedAt
+	}
+	if update.ProcessorVersion != "" {
+		r.ProcessorVersion = update.ProcessorVersion
+	}
+	if update.Chunker != nil {
+		r.Chunker = update.Chunker
+	}
+	if update.EmbeddingModel != "" {
+		r.EmbeddingModel = update.EmbeddingModel
+	}
+	if update.EmbeddedAt != "" {
+		r.EmbeddedAt = update.EmbeddedAt
+	}
+	return r
+}
+
+// Map converts the record into the generic form stored in JSON metadata maps
+func (r Record) Map() map[string]interface{} {
+	out := map[string]interface{}{}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return out
+	}
+	_ = json.Unmarshal(b, &out)
+	return out
+}
+
+// Update merges update into the provenance stored in m, creating it if needed
+func Update(m map[string]interface{}, update Record) {
+	if m == nil {
+		return
+	}
+	m[MetadataKey] = Get(m).Merge(update).Map()
+}
+
+// String renders the record compactly for verbose output
+func (r Record) String() string {
+	s := ""
+	add := func(k, v string) {
+		if v == "" {
+			return
+		}
+		if s != "" {
+			s += ", "
+		}
+		s += k + "=" + v
+	}
+	add("run", r.CrawlRunID)
+	add("fetched", r.FetchedAt)
+	add("processor", r.ProcessorVersion)
+	if r.Chunker != nil {
+		add("chunker", r.Chunker.Strategy)
+	}
+	add("model", r.EmbeddingModel)
+	return s
+}
@@ -0,0 +1,63 @@
+package rag
+
+import (
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/vectorstore"
+)
+
+// DefaultAnswerTokens is how many tokens a Budget keeps free for the answer when the
+// answer's length is not capped: the prompts ask for about 250 words
+const DefaultAnswerTokens = 512
+
+// chunkOverhead covers the "[n] " label and blank line around each chunk in a context
+const chunkOverhead = 4
+
+// Budget sizes a RAG context to the chat model's context window: the prompt around the
+// context, the context, and the answer must fit in Window tokens together, or the model
+// silently drops the start of the prompt
+type Budget struct {
+	Window int // the model's context window (num_ctx)
+	Answer int // tokens kept free for the answer
+	// Count counts tokens as the chat model's tokenizer does; nil estimates them, and takes
+	// a chunk's token count from its metadata where the processor recorded one
+	Count func(text string) int
+}
+
+// ContextTokens returns how many tokens of context fit beside prompt, everything sent to
+// the model but the context itself; 0 when prompt and answer already fill the window
+func (b Budget) ContextTokens(prompt string) int {
+	n := b.Window - b.Tokens(prompt) - b.Answer
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Fit returns the leading results whose chunks fit in tokens together. The first result is
+// always kept, as WithinTokens keeps it; tokens <= 0 keeps them all.
+func (b Budget) Fit(results []vectorstore.SearchResult, tokens int) []vectorstore.SearchResult {
+	if tokens <= 0 {
+		return results
+	}
+	total := 0
+	for i, r := range results {
+		if b.Count != nil {
+			total += b.Count(ContentOf(r.Item))
+		} else {
+			total += TokensOf(r.Item)
+		}
+		total += chunkOverhead
+		if total > tokens && i > 0 {
+			return results[:i]
+		}
+	}
+	return results
+}
+
+// Tokens counts the tokens of text with Count, or estimates them
+func (b Budget) Tokens(text string) int {
+	if b.Count != nil {
+		return b.Count(text)
+	}
+	return chunker.EstimateTokens(text)
+}
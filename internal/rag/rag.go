@@ -0,0 +1,280 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+
+	"kirk-ai/internal/chunker"
+	"kirk-ai/internal/license"
+	"kirk-ai/internal/vectorstore"
+)
+
+// DefaultMaxContextLength is the character budget used when none is configured
+const DefaultMaxContextLength = 8000
+
+// ContentOf returns the chunk text of an item, falling back to a "content" metadata field
+func ContentOf(item vectorstore.Item) string {
+	// First try direct content field
+	if content := item.Text(); content != "" {
+		return content
+	}
+
+	// Try to extract content from metadata
+	if item.Metadata != nil {
+		if content, ok := item.Metadata["content"].(string); ok && content != "" {
+			return content
+		}
+	}
+
+	return ""
+}
+
+// TokensOf returns the token count the processor recorded for an item's chunk, or an
+// estimate for chunks from before token counts were recorded
+func TokensOf(item vectorstore.Item) int {
+	switch n := item.Metadata["token_count"].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return chunker.EstimateTokens(ContentOf(item))
+}
+
+// WithinTokens returns the leading results whose chunks fit in maxTokens tokens together, so
+// a context can be sized to a model's window rather than by characters. The first result
+// is always kept; maxTokens <= 0 keeps them all.
+func WithinTokens(results []vectorstore.SearchResult, maxTokens int) []vectorstore.SearchResult {
+	if maxTokens <= 0 {
+		return results
+	}
+	total := 0
+	for i, r := range results {
+		total += TokensOf(r.Item)
+		if total > maxTokens && i > 0 {
+			return results[:i]
+		}
+	}
+	return results
+}
+
+// BuildContext joins the content of search results into a context string of at most
+// maxLength characters, skipping duplicates. It returns the context and the results used.
+func BuildContext(results []vectorstore.SearchResult, maxLength int) (string, []vectorstore.SearchResult) {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxContextLength
+	}
+
+	var contextParts []string
+	var usedResults []vectorstore.SearchResult
+	totalLength := 0
+
+	seenKeys := map[string]bool{}
+	for _, result := range results {
+		// Deduplicate by ID or content prefix
+		key := result.Item.ID
+		if key == "" {
+			key = ContentOf(result.Item)
+			if key == "" {
+				key = fmt.Sprintf("chunk_%d", result.Item.ChunkIndex)
+			}
+			if len(key) > 200 {
+				key = key[:200]
+			}
+		}
+		if seenKeys[key] {
+			continue
+		}
+		seenKeys[key] = true
+
+		content := ContentOf(result.Item)
+		if content != "" {
+			remaining := maxLength - totalLength
+			if remaining <= 0 {
+				break
+			}
+			if len(content) > remaining {
+				if remaining > 100 { // Only add if meaningful
+					content = content[:remaining] + "..."
+					contextParts = append(contextParts, content)
+					totalLength += len(content)
+					usedResults = append(usedResults, result)
+				}
+				break
+			}
+			contextParts = append(contextParts, content)
+			totalLength += len(content)
+			usedResults = append(usedResults, result)
+		}
+	}
+
+	context := strings.Join(contextParts, "\n\n")
+
+	// Extra safety: final truncate to avoid exceeding max
+	if len(context) > maxLength {
+		context = context[:maxLength]
+	}
+
+	return context, usedResults
+}
+
+// BuildCitedContext is BuildContext with every chunk numbered "[n]" so an answer can cite
+// it; the n-th returned result is chunk [n]
+func BuildCitedContext(results []vectorstore.SearchResult, maxLength int) (string, []vectorstore.SearchResult) {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxContextLength
+	}
+	_, used := BuildContext(results, maxLength)
+	parts := make([]string, len(used))
+	for i, r := range used {
+		parts[i] = fmt.Sprintf("[%d] %s", i+1, ContentOf(r.Item))
+	}
+	context := strings.Join(parts, "\n\n")
+	if len(context) > maxLength {
+		context = context[:maxLength]
+	}
+	return context, used
+}
+
+// BuildCitedPrompt builds a RAG prompt over a BuildCitedContext context that asks the model
+// to cite the numbered chunks it relies on
+func BuildCitedPrompt(question, context string) string {
+	return fmt.Sprintf(`Answer concisely (limit ~250 words) using only the numbered excerpts below. After each claim, cite the excerpts that support it as [n]. If the answer is not clearly available in the excerpts, say so.
+
+Excerpts:
+%s
+
+Question: %s
+
+Answer:`, context, question)
+}
+
+// BuildConversationPrompt builds the system message of a RAG conversation over a
+// BuildCitedContext context; the questions and answers so far follow it as chat messages
+func BuildConversationPrompt(context string) string {
+	return fmt.Sprintf(`You are answering a user's questions in a conversation using only the numbered excerpts below. Answer concisely (limit ~250 words). After each claim, cite the excerpts that support it as [n]. Follow-up questions may refer to earlier answers; resolve them from the conversation. If the answer is not clearly available in the excerpts, say so.
+
+Excerpts:
+%s`, context)
+}
+
+// BuildRewritePrompt asks for a follow-up question rewritten as a standalone search query,
+// resolving what it refers to from the conversation so far (transcript)
+func BuildRewritePrompt(transcript, question string) string {
+	return fmt.Sprintf(`Rewrite the follow-up question as a standalone search query. Replace pronouns and references such as "it", "they", or "that" with what they refer to in the conversation, and keep the query short. Reply with the query only.
+
+Conversation:
+%s
+
+Follow-up question: %s
+
+Standalone query:`, transcript, question)
+}
+
+// BuildPrompt builds the RAG prompt with an explicit brevity instruction
+func BuildPrompt(question, context string) string {
+	return fmt.Sprintf(`Answer concisely (limit ~250 words). Based on the following context, please answer the question. If the answer is not clearly available in the context, say so.
+
+Context:
+%s
+
+Question: %s
+
+Answer:`, context, question)
+}
+
+// BuildReferencePrompt prepends retrieved reference material, such as a glossary or API
+// docs, to a task prompt. An empty reference returns the prompt unchanged.
+func BuildReferencePrompt(prompt, reference string) string {
+	if reference == "" {
+		return prompt
+	}
+	return fmt.Sprintf(`Use the following reference material where it is relevant. Prefer its terminology, names, and APIs over your own assumptions.
+
+Reference:
+%s
+
+%s`, reference, prompt)
+}
+
+// Citation is a numbered reference to a chunk used as context; N matches the [n] the chunk
+// carries in a BuildCitedContext context
+type Citation struct {
+	N          int     `json:"n"`
+	ID         string  `json:"id"`
+	Title      string  `json:"title,omitempty"`
+	SourceURL  string  `json:"source_url,omitempty"`
+	ChunkIndex int     `json:"chunk_index"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Citations numbers the results used as context, in order
+func Citations(results []vectorstore.SearchResult) []Citation {
+	out := make([]Citation, len(results))
+	for i, r := range results {
+		title, _ := r.Item.Metadata["title"].(string)
+		out[i] = Citation{
+			N:          i + 1,
+			ID:         r.Item.ID,
+			Title:      title,
+			SourceURL:  vectorstore.SourceURL(r.Item),
+			ChunkIndex: r.Item.ChunkIndex,
+			Similarity: r.Similarity,
+		}
+	}
+	return out
+}
+
+// String renders the citation as "[n] title — url (chunk i)"
+func (c Citation) String() string {
+	var parts []string
+	if c.Title != "" {
+		parts = append(parts, c.Title)
+	}
+	if c.SourceURL != "" {
+		parts = append(parts, c.SourceURL)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, c.ID)
+	}
+	return fmt.Sprintf("[%d] %s (chunk %d)", c.N, strings.Join(parts, " — "), c.ChunkIndex)
+}
+
+// FormatCitations renders one citation per line
+func FormatCitations(citations []Citation) string {
+	var b strings.Builder
+	for _, c := range citations {
+		b.WriteString(c.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Attribution renders one entry per cited source, in citation order: its title, URL, and
+// the copyright and license notice recorded when the page was processed
+func Attribution(results []vectorstore.SearchResult) string {
+	var b strings.Builder
+	seen := map[string]bool{}
+	for _, r := range results {
+		src := vectorstore.SourceURL(r.Item)
+		key := src
+		if key == "" {
+			key = r.Item.ID
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		title, _ := r.Item.Metadata["title"].(string)
+		if title == "" {
+			title = key
+		}
+		fmt.Fprintf(&b, "[%d] %s\n", len(seen), title)
+		if src != "" && src != title {
+			fmt.Fprintf(&b, "    %s\n", src)
+		}
+		fmt.Fprintf(&b, "    %s\n", license.Get(r.Item.Metadata).Notice())
+	}
+	return b.String()
+}
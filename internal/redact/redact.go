@@ -0,0 +1,266 @@
+// Package redact masks personal information, such as email addresses, phone numbers, and
+// patterns configured in the settings file, in crawled content before it is chunked and in
+// generated answers before they are shown. A chat model can be asked to find what the
+// patterns miss, such as the names and street addresses of private individuals.
+package redact
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"kirk-ai/internal/config"
+)
+
+// ModelFound is the name of the items a chat model found, as they are masked and counted
+const ModelFound = "pii"
+
+// maxPiece is the most text sent to a chat model in one request
+const maxPiece = 4000
+
+// rule masks the matches of re, skipping those valid rejects
+type rule struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// builtin are the patterns masked by default. Cards and social security numbers come before
+// phone numbers, whose pattern would otherwise take part of them.
+var builtin = []rule{
+	{name: "email", re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	{name: "credit_card", re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: luhn},
+	{name: "ssn", re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	// +44 20 7946 0958, (555) 123-4567, 555.123.4567
+	{name: "phone", re: regexp.MustCompile(`\+\d{1,3}(?:[\s.-]?\d{2,4}){3,5}\b|(?:\(\d{3}\)\s?|\b\d{3}[\s.-])\d{3}[\s.-]\d{4}\b`)},
+	{name: "ip", re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), valid: func(s string) bool { return net.ParseIP(s) != nil }},
+}
+
+// Redactor masks personal information in text
+type Redactor struct {
+	rules []rule
+}
+
+// New compiles the built-in patterns settings names, all of them when it names none, and
+// its own patterns
+func New(settings config.RedactSettings) (*Redactor, error) {
+	r := &Redactor{}
+	if len(settings.Builtin) == 0 {
+		r.rules = append(r.rules, builtin...)
+	}
+	for _, name := range settings.Builtin {
+		found := false
+		for _, b := range builtin {
+			if b.name == strings.ToLower(strings.TrimSpace(name)) {
+				r.rules = append(r.rules, b)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown built-in pattern %q (have %s)", name, strings.Join(BuiltinNames(), ", "))
+		}
+	}
+	for i, p := range settings.Patterns {
+		if strings.TrimSpace(p.Name) == "" || p.Pattern == "" {
+			return nil, fmt.Errorf("pattern %d needs a name and a pattern", i+1)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %s: %w", p.Name, err)
+		}
+		r.rules = append(r.rules, rule{name: p.Name, re: re})
+	}
+	return r, nil
+}
+
+// Load builds the Redactor the settings file configures
+func Load() (*Redactor, error) {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	r, err := New(settings.Redact)
+	if err != nil {
+		return nil, fmt.Errorf("%s redact: %w", config.SettingsFile, err)
+	}
+	return r, nil
+}
+
+// BuiltinNames lists the built-in patterns
+func BuiltinNames() []string {
+	names := make([]string, len(builtin))
+	for i, b := range builtin {
+		names[i] = b.name
+	}
+	return names
+}
+
+// Counts is how many matches were masked, by pattern name
+type Counts map[string]int
+
+// Add adds other's counts to c
+func (c Counts) Add(other Counts) {
+	for name, n := range other {
+		c[name] += n
+	}
+}
+
+// Total is the number of matches masked
+func (c Counts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// String lists the counts by name, e.g. "3 email, 1 phone"
+func (c Counts) String() string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", c[name], name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func marker(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// Redact masks the matches of every pattern in text
+func (r *Redactor) Redact(text string) (string, Counts) {
+	counts := Counts{}
+	for _, ru := range r.rules {
+		text = ru.re.ReplaceAllStringFunc(text, func(m string) string {
+			if ru.valid != nil && !ru.valid(m) {
+				return m
+			}
+			counts[ru.name]++
+			return marker(ru.name)
+		})
+	}
+	return text, counts
+}
+
+// Ask sends a prompt to a chat model and returns its reply
+type Ask func(prompt string) (string, error)
+
+// RedactWith masks the pattern matches in text and then what ask's model finds in what is
+// left. When the model fails, the text comes back with the pattern matches masked, beside
+// the error.
+func (r *Redactor) RedactWith(text string, ask Ask) (string, Counts, error) {
+	text, counts := r.Redact(text)
+	if ask == nil || strings.TrimSpace(text) == "" {
+		return text, counts, nil
+	}
+	var found []string
+	for _, piece := range pieces(text, maxPiece) {
+		reply, err := ask(Prompt(piece))
+		if err != nil {
+			return text, counts, err
+		}
+		found = append(found, ParseFound(reply, piece)...)
+	}
+	// The longest first, so a name inside a longer item does not leave the rest of it behind
+	sort.Slice(found, func(i, j int) bool { return len(found[i]) > len(found[j]) })
+	for _, item := range found {
+		if n := strings.Count(text, item); n > 0 {
+			text = strings.ReplaceAll(text, item, marker(ModelFound))
+			counts[ModelFound] += n
+		}
+	}
+	return text, counts, nil
+}
+
+// Prompt asks a chat model to list the personal information in text
+func Prompt(text string) string {
+	return "List the personal information in the text below: names of private individuals, street addresses, " +
+		"account and ID numbers, dates of birth, and anything else that identifies or contacts a private person. " +
+		"Leave out public figures, organizations, and anything already marked [REDACTED:...]. Copy each item " +
+		"exactly as written, one per line, with nothing else. Reply NONE if there is none.\n\nText:\n" + text
+}
+
+// ParseFound returns the items listed in a reply to Prompt that occur in text as written
+func ParseFound(reply, text string) []string {
+	var found []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(reply, "\n") {
+		item := strings.TrimSpace(line)
+		item = strings.TrimLeft(item, "-*• ")
+		if i := strings.Index(item, ". "); i > 0 && i <= 3 && strings.Trim(item[:i], "0123456789") == "" {
+			item = item[i+2:]
+		}
+		item = strings.Trim(strings.TrimSpace(item), "\"'`")
+		if len(item) < 3 || strings.EqualFold(item, "none") || strings.Contains(item, "[REDACTED") || seen[item] {
+			continue
+		}
+		if strings.Contains(text, item) {
+			seen[item] = true
+			found = append(found, item)
+		}
+	}
+	return found
+}
+
+// pieces splits text at paragraph breaks into runs of at most max bytes; a longer paragraph
+// is cut at a space where it can be
+func pieces(text string, max int) []string {
+	var out []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			out = append(out, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		for len(para) > max {
+			cut := strings.LastIndex(para[:max], " ")
+			if cut <= 0 {
+				for cut = max; cut > 0 && !utf8.RuneStart(para[cut]); cut-- {
+				}
+			}
+			flush()
+			out = append(out, para[:cut])
+			para = para[cut:]
+		}
+		if cur.Len() > 0 && cur.Len()+2+len(para) > max {
+			flush()
+		}
+		if cur.Len() > 0 {
+			cur.WriteString("\n\n")
+		}
+		cur.WriteString(para)
+	}
+	flush()
+	return out
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum card numbers carry
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,207 @@
+// Package rerank reorders the candidates of a similarity search before they are shown or
+// used as context. MMR trades relevance against redundancy so near-duplicate chunks do not
+// crowd out the rest; LLM asks a chat model to score each candidate against the query.
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"kirk-ai/internal/client"
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/vectorstore"
+)
+
+// Rerank methods
+const (
+	Off = "off"
+	MMR = "mmr"
+	LLM = "llm"
+)
+
+// DefaultLambda weighs relevance against diversity in MMR: 1 is plain similarity order,
+// 0 picks the candidate least like those already picked
+const DefaultLambda = 0.7
+
+// ParseMethod validates a --rerank value; "" means Off
+func ParseMethod(s string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "", Off:
+		return Off, nil
+	case MMR, LLM:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown rerank method %q (use mmr, llm, or off)", s)
+}
+
+// Diversify picks up to topK results by maximal marginal relevance. Each pick maximizes
+// lambda*similarity - (1-lambda)*(its highest similarity to a result already picked).
+// Chunks are compared by embedding when both have one, and by their words otherwise, since
+// results from Qdrant come without vectors. Similarity scores are left unchanged.
+func Diversify(results []vectorstore.SearchResult, topK int, lambda float64) []vectorstore.SearchResult {
+	if topK <= 0 || topK > len(results) {
+		topK = len(results)
+	}
+	words := make([]map[string]bool, len(results))
+	for i, r := range results {
+		if len(r.Item.Embedding) == 0 {
+			words[i] = wordSet(r.Item.Content)
+		}
+	}
+	similar := func(i, j int) float64 {
+		a, b := results[i].Item, results[j].Item
+		if len(a.Embedding) > 0 && len(a.Embedding) == len(b.Embedding) {
+			return vectorstore.CosineSimilarity(a.Embedding, b.Embedding)
+		}
+		if words[i] == nil {
+			words[i] = wordSet(a.Content)
+		}
+		if words[j] == nil {
+			words[j] = wordSet(b.Content)
+		}
+		return jaccard(words[i], words[j])
+	}
+
+	picked := make([]int, 0, topK)
+	used := make([]bool, len(results))
+	// redundancy[i] is candidate i's highest similarity to a picked result
+	redundancy := make([]float64, len(results))
+	for len(picked) < topK {
+		best, bestScore := -1, 0.0
+		for i, r := range results {
+			if used[i] {
+				continue
+			}
+			score := lambda*r.Similarity - (1-lambda)*redundancy[i]
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		used[best] = true
+		picked = append(picked, best)
+		for i := range results {
+			if !used[i] {
+				if s := similar(i, best); s > redundancy[i] {
+					redundancy[i] = s
+				}
+			}
+		}
+	}
+
+	out := make([]vectorstore.SearchResult, len(picked))
+	for i, p := range picked {
+		out[i] = results[p]
+	}
+	return out
+}
+
+func wordSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		set[w] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// passageExcerpt is how much of a chunk the LLM reranker sends
+const passageExcerpt = 2000
+
+const scorePrompt = `Rate how well the passage answers the query, from 0 (unrelated) to 10 (answers it directly).
+Answer with the number only.
+
+Query: %s
+
+Passage: %s`
+
+// Scorer asks a chat model how relevant each candidate is to the query, acting as a
+// cross-encoder that reads the query and the chunk together
+type Scorer struct {
+	Client *client.Client
+	Model  string
+}
+
+// Rerank orders results by the model's score, highest first, and returns up to topK of
+// them. Ties and candidates the model gave no usable score keep their similarity order,
+// after the scored ones. It fails only when no candidate could be scored.
+func (s *Scorer) Rerank(ctx context.Context, query string, results []vectorstore.SearchResult, topK int) ([]vectorstore.SearchResult, error) {
+	scores := make([]float64, len(results))
+	scored := 0
+	var lastErr error
+	for i, r := range results {
+		score, err := s.score(ctx, query, r.Item.Content)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			scores[i], lastErr = -1, err
+			continue
+		}
+		scores[i] = score
+		scored++
+	}
+	if scored == 0 && len(results) > 0 {
+		return nil, fmt.Errorf("reranking with %s: %w", s.Model, lastErr)
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if scores[order[a]] != scores[order[b]] {
+			return scores[order[a]] > scores[order[b]]
+		}
+		return results[order[a]].Similarity > results[order[b]].Similarity
+	})
+	if topK <= 0 || topK > len(order) {
+		topK = len(order)
+	}
+	out := make([]vectorstore.SearchResult, topK)
+	for i := range out {
+		out[i] = results[order[i]]
+	}
+	return out, nil
+}
+
+// score returns the model's 0-10 relevance score for passage
+func (s *Scorer) score(ctx context.Context, query, passage string) (float64, error) {
+	if len(passage) > passageExcerpt {
+		passage = passage[:passageExcerpt]
+	}
+	temperature := 0.0
+	resp, err := s.Client.ChatWithRequest(ctx, models.ChatRequest{
+		Model:    s.Model,
+		Messages: []models.Message{{Role: "user", Content: fmt.Sprintf(scorePrompt, query, passage)}},
+		Options:  &models.Options{Temperature: &temperature},
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Small models sometimes add words around the number; the first number wins
+	for _, field := range strings.Fields(resp.Message.Content) {
+		field = strings.TrimFunc(field, func(r rune) bool { return !unicode.IsDigit(r) })
+		if n, err := strconv.ParseFloat(strings.SplitN(field, "/", 2)[0], 64); err == nil && n >= 0 && n <= 10 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized score %q", resp.Message.Content)
+}
@@ -0,0 +1,355 @@
+package robots
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+const (
+	// CacheTTL is how long a fetched robots.txt is trusted
+	CacheTTL = 30 * time.Minute
+	// NegativeCacheTTL is how long a failed fetch is remembered before retrying
+	NegativeCacheTTL = 10 * time.Minute
+	// FlushInterval is how long a newly fetched robots.txt waits before the cache file is
+	// rewritten; every host fetched in the meantime goes into the same write
+	FlushInterval = 2 * time.Second
+)
+
+// entry is an in-memory cache entry for one host
+type entry struct {
+	data      *robotstxt.RobotsData
+	fetchedAt time.Time
+	failed    bool
+}
+
+// fileEntry is the on-disk form of a cache entry, keeping the raw body so other
+// processes can parse it
+type fileEntry struct {
+	Body      string    `json:"body"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Failed    bool      `json:"failed"`
+}
+
+// Checker answers robots.txt queries with an in-memory cache, a file-backed cache shared
+// across processes, and a single fetch per host at a time. Fetch failures fail open.
+type Checker struct {
+	client    *http.Client
+	cachePath string
+	header    http.Header // identity headers sent with robots.txt requests
+
+	mu       sync.Mutex
+	cache    map[string]*entry
+	file     map[string]*fileEntry
+	inFlight map[string]chan struct{}
+	logged   map[string]bool
+	loadOnce sync.Once
+	writeMu  sync.Mutex
+	dirty    bool        // file has entries not yet written
+	flushing *time.Timer // pending write of the cache file, nil when none is scheduled
+}
+
+// New returns a Checker that fetches with client and persists to cachePath ("" disables the file cache)
+func New(client *http.Client, cachePath string) *Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Checker{
+		client:    client,
+		cachePath: cachePath,
+		cache:     make(map[string]*entry),
+		file:      make(map[string]*fileEntry),
+		inFlight:  make(map[string]chan struct{}),
+		logged:    make(map[string]bool),
+	}
+}
+
+// Identify sets the User-Agent and From headers sent when fetching robots.txt, so sites see
+// the same identity as for page requests. Without it the robots user agent token is sent.
+// Empty values are left out.
+func (c *Checker) Identify(userAgent, from string) {
+	h := http.Header{}
+	if userAgent != "" {
+		h.Set("User-Agent", userAgent)
+	}
+	if from != "" {
+		h.Set("From", from)
+	}
+	c.header = h
+}
+
+// Reasons given in a Decision
+const (
+	ReasonAllowed     = "allowed by robots.txt"
+	ReasonDisallowed  = "disallowed by robots.txt"
+	ReasonNoRules     = "no robots.txt rules for this agent"
+	ReasonUnavailable = "robots.txt unavailable, failing open"
+	ReasonBadURL      = "unparseable URL"
+	ReasonCanceled    = "canceled while waiting for robots.txt, failing open"
+)
+
+// Decision is the outcome of a robots.txt check and the reason for it
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+// Allowed reports whether userAgent may fetch rawURL. Unparseable URLs are disallowed;
+// hosts whose robots.txt cannot be fetched are allowed.
+func (c *Checker) Allowed(ctx context.Context, userAgent, rawURL string) bool {
+	return c.Check(ctx, userAgent, rawURL).Allowed
+}
+
+// Check is like Allowed but also says why, for crawl audit logs
+func (c *Checker) Check(ctx context.Context, userAgent, rawURL string) Decision {
+	c.loadOnce.Do(c.load)
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return Decision{Allowed: false, Reason: ReasonBadURL}
+	}
+	host := parsed.Host // host-only cache key (dedupe http/https)
+
+	for {
+		c.mu.Lock()
+		if e, ok := c.cache[host]; ok {
+			if d, fresh := e.test(userAgent, parsed.Path); fresh {
+				c.mu.Unlock()
+				return d
+			}
+		}
+
+		// Another goroutine is fetching this host: wait for it and re-check the cache
+		if ch, fetching := c.inFlight[host]; fetching {
+			c.mu.Unlock()
+			select {
+			case <-ch:
+				continue
+			case <-ctx.Done():
+				return Decision{Allowed: true, Reason: ReasonCanceled}
+			}
+		}
+
+		ch := make(chan struct{})
+		c.inFlight[host] = ch
+		c.mu.Unlock()
+
+		e := c.fetch(ctx, userAgent, parsed.Scheme, host)
+
+		c.mu.Lock()
+		c.cache[host] = e
+		close(ch)
+		delete(c.inFlight, host)
+		c.mu.Unlock()
+
+		d, _ := e.test(userAgent, parsed.Path)
+		return d
+	}
+}
+
+// Sitemaps returns the sitemap URLs listed in the robots.txt of rawURL's host, fetching it
+// when it is not cached. A host without a robots.txt has none.
+func (c *Checker) Sitemaps(ctx context.Context, userAgent, rawURL string) []string {
+	c.Check(ctx, userAgent, rawURL)
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.cache[parsed.Host]; ok && e.data != nil {
+		return append([]string(nil), e.data.Sitemaps...)
+	}
+	return nil
+}
+
+// CrawlDelay returns the Crawl-delay that the cached robots.txt of host sets for userAgent,
+// or 0 when it sets none or has not been fetched
+func (c *Checker) CrawlDelay(userAgent, host string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[host]
+	if !ok || e.data == nil {
+		return 0
+	}
+	group := e.data.FindGroup(userAgent)
+	if group == nil {
+		return 0
+	}
+	return group.CrawlDelay
+}
+
+// noGroup is the shared empty group robotstxt returns when no group applies to an agent,
+// including for a robots.txt that is missing
+var noGroup = new(robotstxt.RobotsData).FindGroup("")
+
+// test applies the entry to path; fresh is false when the entry has expired
+func (e *entry) test(userAgent, path string) (d Decision, fresh bool) {
+	age := time.Since(e.fetchedAt)
+	if e.failed {
+		return Decision{Allowed: true, Reason: ReasonUnavailable}, age < NegativeCacheTTL
+	}
+	if e.data == nil || age >= CacheTTL {
+		return Decision{Allowed: true, Reason: ReasonUnavailable}, false
+	}
+	// FindGroup falls back to the * group itself, and returns noGroup when neither applies
+	group := e.data.FindGroup(userAgent)
+	if group == noGroup {
+		return Decision{Allowed: true, Reason: ReasonNoRules}, true
+	}
+	if group.Test(path) {
+		return Decision{Allowed: true, Reason: ReasonAllowed}, true
+	}
+	return Decision{Allowed: false, Reason: ReasonDisallowed}, true
+}
+
+// fetch downloads and parses robots.txt for host and records it in the file cache
+func (c *Checker) fetch(ctx context.Context, userAgent, scheme, host string) *entry {
+	now := time.Now()
+	req, err := http.NewRequestWithContext(ctx, "GET", scheme+"://"+host+"/robots.txt", nil)
+	if err != nil {
+		return c.failed(host, now, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return c.failed(host, now, err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return c.failed(host, now, err)
+	}
+
+	// Server errors fail open like network errors; 4xx means there is no robots.txt (allow all)
+	if resp.StatusCode >= 500 {
+		return c.failed(host, now, fmt.Errorf("status %d", resp.StatusCode))
+	}
+	data, err := robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+	if err != nil {
+		c.remember(host, &fileEntry{Body: string(body), FetchedAt: now, Failed: true})
+		return c.failed(host, now, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		c.remember(host, &fileEntry{Body: string(body), FetchedAt: now})
+	}
+	return &entry{data: data, fetchedAt: now}
+}
+
+// failed logs the first fetch error per host and returns a negative cache entry
+func (c *Checker) failed(host string, at time.Time, err error) *entry {
+	c.mu.Lock()
+	if !c.logged[host] {
+		c.logged[host] = true
+		log.Printf("robots: could not fetch robots.txt for %s: %v", host, err)
+	}
+	c.mu.Unlock()
+	return &entry{fetchedAt: at, failed: true}
+}
+
+// load populates the in-memory cache from the cache file, if any
+func (c *Checker) load() {
+	if c.cachePath == "" {
+		return
+	}
+	b, err := os.ReadFile(c.cachePath)
+	if err != nil {
+		return // no file yet is fine
+	}
+	var fileMap map[string]*fileEntry
+	if err := json.Unmarshal(b, &fileMap); err != nil {
+		log.Printf("robots: could not parse cache file %s: %v", c.cachePath, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for host, fe := range fileMap {
+		if fe == nil {
+			continue
+		}
+		c.file[host] = fe
+		age := time.Since(fe.FetchedAt)
+		if fe.Failed && age < NegativeCacheTTL {
+			c.cache[host] = &entry{fetchedAt: fe.FetchedAt, failed: true}
+			continue
+		}
+		if fe.Body != "" && age < CacheTTL {
+			if data, err := robotstxt.FromBytes([]byte(fe.Body)); err == nil {
+				c.cache[host] = &entry{data: data, fetchedAt: fe.FetchedAt}
+			}
+		}
+	}
+}
+
+// remember records a host in the file cache. The file is rewritten in the background at
+// most once per FlushInterval, so a crawl starting on many hosts at once does not rewrite
+// it for each of them.
+func (c *Checker) remember(host string, fe *fileEntry) {
+	if c.cachePath == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.file[host] = fe
+	c.dirty = true
+	if c.flushing == nil {
+		c.flushing = time.AfterFunc(FlushInterval, func() {
+			if err := c.write(); err != nil {
+				log.Printf("robots: %v", err)
+			}
+		})
+	}
+}
+
+// Flush writes entries not yet saved to the cache file. Call it when the crawl ends, since
+// a pending background write does not keep the process running.
+func (c *Checker) Flush() error {
+	if c.cachePath == "" {
+		return nil
+	}
+	c.mu.Lock()
+	if c.flushing != nil {
+		c.flushing.Stop()
+	}
+	c.mu.Unlock()
+	return c.write()
+}
+
+// write rewrites the cache file atomically if it has unsaved entries
+func (c *Checker) write() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	c.flushing = nil
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	b, err := json.MarshalIndent(c.file, "", "  ")
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not marshal cache: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(c.cachePath, b, 0o644, false); err != nil {
+		return fmt.Errorf("could not write cache file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package robots
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// robotsServer serves body with status at /robots.txt and counts the requests for it
+func robotsServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestCheck(t *testing.T) {
+	const rules = "User-agent: kirk\nDisallow: /private\n\nUser-agent: *\nDisallow: /\n"
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		userAgent string
+		path      string
+		want      Decision
+	}{
+		{"allowed", 200, rules, "kirk", "/public", Decision{true, ReasonAllowed}},
+		{"disallowed", 200, rules, "kirk", "/private/page", Decision{false, ReasonDisallowed}},
+		{"wildcard group", 200, rules, "other", "/public", Decision{false, ReasonDisallowed}},
+		{"no group", 200, "User-agent: other\nDisallow: /\n", "kirk", "/private", Decision{true, ReasonNoRules}},
+		{"not found allows all", 404, "", "kirk", "/private", Decision{true, ReasonNoRules}},
+		{"forbidden allows all", 403, "", "kirk", "/private", Decision{true, ReasonNoRules}},
+		{"server error fails open", 503, rules, "kirk", "/private", Decision{true, ReasonUnavailable}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := robotsServer(t, tt.status, tt.body)
+			c := New(srv.Client(), "")
+			for i := 0; i < 2; i++ {
+				if got := c.Check(context.Background(), tt.userAgent, srv.URL+tt.path); got != tt.want {
+					t.Fatalf("check %d: got %+v, want %+v", i, got, tt.want)
+				}
+			}
+			if n := hits.Load(); n != 1 {
+				t.Errorf("robots.txt fetched %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestCheckBadURL(t *testing.T) {
+	c := New(nil, "")
+	if got := c.Check(context.Background(), "kirk", "/relative/path"); got != (Decision{false, ReasonBadURL}) {
+		t.Errorf("got %+v, want a bad URL to be disallowed", got)
+	}
+}
+
+func TestCheckServerErrorRetriesAfterNegativeTTL(t *testing.T) {
+	srv, hits := robotsServer(t, 500, "")
+	c := New(srv.Client(), "")
+	ctx := context.Background()
+
+	c.Check(ctx, "kirk", srv.URL+"/")
+	host := srv.Listener.Addr().String()
+
+	c.mu.Lock()
+	c.cache[host].fetchedAt = time.Now().Add(-NegativeCacheTTL + time.Minute)
+	c.mu.Unlock()
+	c.Check(ctx, "kirk", srv.URL+"/")
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("refetched within the negative cache TTL: %d fetches", n)
+	}
+
+	c.mu.Lock()
+	c.cache[host].fetchedAt = time.Now().Add(-NegativeCacheTTL)
+	c.mu.Unlock()
+	if got := c.Check(ctx, "kirk", srv.URL+"/"); !got.Allowed {
+		t.Errorf("got %+v, want failing open", got)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("got %d fetches after the negative cache TTL, want 2", n)
+	}
+}
+
+func TestCheckSingleFlight(t *testing.T) {
+	release := make(chan struct{})
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer srv.Close()
+	c := New(srv.Client(), "")
+
+	const workers = 20
+	var wg sync.WaitGroup
+	results := make([]Decision, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.Check(context.Background(), "kirk", srv.URL+"/private")
+		}(i)
+	}
+	// Let the callers pile up behind the first fetch before it completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := hits.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1", n)
+	}
+	for i, d := range results {
+		if d != (Decision{false, ReasonDisallowed}) {
+			t.Errorf("caller %d: got %+v", i, d)
+		}
+	}
+}
+
+func TestCheckCanceledWhileWaiting(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	c := New(srv.Client(), "")
+
+	go c.Check(context.Background(), "kirk", srv.URL+"/")
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := c.Check(ctx, "kirk", srv.URL+"/"); got != (Decision{true, ReasonCanceled}) {
+		t.Errorf("got %+v, want failing open on cancel", got)
+	}
+}
+
+func TestFileCacheRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "robots.json")
+	srv, hits := robotsServer(t, 200, "User-agent: *\nDisallow: /private\nSitemap: https://example.com/sitemap.xml\n")
+
+	first := New(srv.Client(), path)
+	if got := first.Check(context.Background(), "kirk", srv.URL+"/private"); got.Allowed {
+		t.Fatalf("got %+v, want disallowed", got)
+	}
+	if err := first.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	second := New(srv.Client(), path)
+	if got := second.Check(context.Background(), "kirk", srv.URL+"/private"); got != (Decision{false, ReasonDisallowed}) {
+		t.Errorf("got %+v from the file cache, want disallowed", got)
+	}
+	if got := second.Sitemaps(context.Background(), "kirk", srv.URL+"/"); len(got) != 1 || got[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("got sitemaps %v from the file cache", got)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1 with the file cache", n)
+	}
+}
@@ -0,0 +1,108 @@
+// Package route picks the collections a question should be answered from, by comparing
+// the question with each collection's centroid embedding or by asking a chat model
+package route
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kirk-ai/internal/client"
+	"kirk-ai/internal/models"
+	"kirk-ai/internal/vectorstore"
+)
+
+// Routing methods
+const (
+	Centroid = "centroid"
+	LLM      = "llm"
+)
+
+// Candidate is a collection a question can be routed to
+type Candidate struct {
+	Name        string
+	Description string
+	Summary     *vectorstore.Summary
+}
+
+// Choice is a routed collection and how well it matched the question
+type Choice struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// ByCentroid ranks candidates by the cosine similarity of their centroid to the question.
+// queries holds the question embedded with each candidate's embedding model, keyed by model
+// name; a candidate without a query for its model is left out.
+func ByCentroid(queries map[string][]float64, candidates []Candidate) []Choice {
+	var out []Choice
+	for _, c := range candidates {
+		q, ok := queries[c.Summary.EmbeddingModel]
+		if !ok || c.Summary.Count == 0 {
+			continue
+		}
+		out = append(out, Choice{Name: c.Name, Score: vectorstore.CosineSimilarity(q, c.Summary.Centroid)})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+const routePrompt = `Which of these collections would best answer the question? Each collection is listed
+with its description and some of its page titles.
+
+%s
+Question: %s
+
+Answer with the collection names only, most relevant first, separated by commas.`
+
+// Classifier asks a chat model which collections fit the question, from their names,
+// descriptions, and page titles
+type Classifier struct {
+	Client *client.Client
+	Model  string
+}
+
+// Route returns the candidates the model named, in its order. Scores fall from 1 for the
+// first name; names the model invented are ignored.
+func (c *Classifier) Route(ctx context.Context, question string, candidates []Candidate) ([]Choice, error) {
+	var list strings.Builder
+	for _, cand := range candidates {
+		fmt.Fprintf(&list, "- %s", cand.Name)
+		if cand.Description != "" {
+			fmt.Fprintf(&list, ": %s", cand.Description)
+		}
+		if cand.Summary != nil && len(cand.Summary.Titles) > 0 {
+			fmt.Fprintf(&list, " (pages: %s)", strings.Join(cand.Summary.Titles, "; "))
+		}
+		list.WriteString("\n")
+	}
+
+	temperature := 0.0
+	resp, err := c.Client.ChatWithRequest(ctx, models.ChatRequest{
+		Model:    c.Model,
+		Messages: []models.Message{{Role: "user", Content: fmt.Sprintf(routePrompt, list.String(), question)}},
+		Options:  &models.Options{Temperature: &temperature},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Choice
+	named := map[string]bool{}
+	for _, field := range strings.FieldsFunc(resp.Message.Content, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		name := strings.Trim(strings.TrimSpace(field), "-*`\"'. ")
+		for _, cand := range candidates {
+			if strings.EqualFold(name, cand.Name) && !named[cand.Name] {
+				named[cand.Name] = true
+				out = append(out, Choice{Name: cand.Name, Score: 1 / float64(len(out)+1)})
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s named no known collection: %q", c.Model, resp.Message.Content)
+	}
+	return out, nil
+}
@@ -0,0 +1,175 @@
+package secure
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+const (
+	// KeyEnvVar holds the encryption key (base64-encoded 32 bytes, or any passphrase)
+	KeyEnvVar = "KIRK_AI_ENCRYPTION_KEY"
+	// KeychainEnvVar enables looking the key up in the OS keychain when set to "1"
+	KeychainEnvVar = "KIRK_AI_KEYCHAIN"
+	// keychainService is the service name used for keychain lookups
+	keychainService = "kirk-ai"
+)
+
+// magic prefixes every encrypted file so reads can detect encryption transparently
+var magic = []byte("KIRKENC1")
+
+// LoadKey returns the configured 32-byte AES key, or nil when encryption is not configured.
+// The key is read from KIRK_AI_ENCRYPTION_KEY first and then, if KIRK_AI_KEYCHAIN=1,
+// from the OS keychain (macOS `security` or Linux `secret-tool`).
+func LoadKey() ([]byte, error) {
+	raw := os.Getenv(KeyEnvVar)
+	if raw == "" && os.Getenv(KeychainEnvVar) == "1" {
+		var err error
+		raw, err = keychainLookup()
+		if err != nil {
+			return nil, fmt.Errorf("keychain lookup failed: %w", err)
+		}
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	return deriveKey(raw), nil
+}
+
+// deriveKey accepts a base64-encoded 32-byte key as-is and hashes anything else into one
+func deriveKey(raw string) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil && len(decoded) == 32 {
+		return decoded
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
+
+func keychainLookup() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService)
+	default:
+		return "", fmt.Errorf("keychain not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsEncrypted reports whether data was produced by Encrypt
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Encrypt seals plaintext with AES-GCM, prefixing the magic header and nonce
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, magic), nil
+}
+
+// Decrypt opens data produced by Encrypt
+func Decrypt(key, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, fmt.Errorf("data is not encrypted")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body := data[len(magic):]
+	if len(body) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce, ciphertext := body[:gcm.NonceSize()], body[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, magic)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong key?): %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Open returns data unchanged when it is plaintext and decrypts it with the configured key otherwise
+func Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	key, err := LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("data is encrypted; set %s to read it", KeyEnvVar)
+	}
+	return Decrypt(key, data)
+}
+
+// ReadFile reads a file, transparently decrypting it when it carries the encryption header
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
+// Seal returns data encrypted with the configured key when encrypt is true, and unchanged otherwise
+func Seal(data []byte, encrypt bool) ([]byte, error) {
+	if !encrypt {
+		return data, nil
+	}
+	key, err := LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("encryption requested but %s is not set", KeyEnvVar)
+	}
+	return Encrypt(key, data)
+}
+
+// WriteFile atomically writes data to path, encrypting it first when encrypt is true
+func WriteFile(path string, data []byte, perm os.FileMode, encrypt bool) error {
+	data, err := Seal(data, encrypt)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, perm, false)
+}
@@ -0,0 +1,387 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/secure"
+	"kirk-ai/internal/vectorstore"
+)
+
+// JSON writes every record as one indented JSON array. Each flush appends its records to
+// PartialPath(path), one per line, so a crashed run leaves its partial results behind without
+// rewriting them all every time; Commit writes the array, replacing path atomically and keeping
+// the previous version at path.bak. An encrypted backend writes nothing before Commit, so no
+// plaintext is left on disk.
+type JSON[T any] struct {
+	Path    string
+	Encrypt bool
+	records []T
+	partial *os.File
+	w       *bufio.Writer
+}
+
+// NewJSON returns a JSON array backend for path
+func NewJSON[T any](path string, encrypt bool) *JSON[T] {
+	return &JSON[T]{Path: path, Encrypt: encrypt, records: []T{}}
+}
+
+// PartialPath is where a JSON backend for path appends the records flushed before Commit
+func PartialPath(path string) string {
+	return path + ".partial.jsonl"
+}
+
+// ReadPartial returns the records a JSON backend for path flushed before its run ended
+// without committing. A line cut short by a crash is skipped.
+func ReadPartial[T any](path string) ([]T, error) {
+	f, err := os.Open(PartialPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []T
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var r T
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+func (j *JSON[T]) Write(records []T) error {
+	j.records = append(j.records, records...)
+	if j.Encrypt {
+		return nil
+	}
+	if j.partial == nil {
+		f, err := os.Create(PartialPath(j.Path))
+		if err != nil {
+			return err
+		}
+		j.partial, j.w = f, bufio.NewWriter(f)
+	}
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		j.w.Write(b)
+		j.w.WriteByte('\n')
+	}
+	return j.w.Flush()
+}
+
+func (j *JSON[T]) Commit() error {
+	// An empty run still produces a valid (empty) array
+	b, err := json.MarshalIndent(j.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if b, err = secure.Seal(b, j.Encrypt); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(j.Path, b, 0o644, true); err != nil {
+		return err
+	}
+	if j.partial != nil {
+		j.partial.Close()
+	}
+	if err := os.Remove(PartialPath(j.Path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// JSONL appends one JSON record per line to a temporary file next to path as records are
+// flushed, holding none of them in memory; Commit syncs it and renames it over path, keeping
+// the previous version at path.bak. A .gz or .zst suffix on path compresses the stream.
+type JSONL[T any] struct {
+	Path string
+	f    *atomicfile.File
+	z    io.WriteCloser
+	w    *bufio.Writer
+}
+
+// NewJSONL returns a newline-delimited JSON backend for path
+func NewJSONL[T any](path string) *JSONL[T] {
+	return &JSONL[T]{Path: path}
+}
+
+func (j *JSONL[T]) open() error {
+	if j.f != nil {
+		return nil
+	}
+	f, err := atomicfile.Create(j.Path, 0o644, true)
+	if err != nil {
+		return err
+	}
+	z, err := vectorstore.CompressWriter(j.Path, f)
+	if err != nil {
+		f.Abort()
+		return err
+	}
+	j.f, j.z = f, z
+	j.w = bufio.NewWriter(z)
+	return nil
+}
+
+func (j *JSONL[T]) Write(records []T) error {
+	if err := j.open(); err != nil {
+		return err
+	}
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		j.w.Write(b)
+		j.w.WriteByte('\n')
+	}
+	return j.w.Flush()
+}
+
+func (j *JSONL[T]) Commit() error {
+	if err := j.open(); err != nil {
+		return err
+	}
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	if err := j.z.Close(); err != nil {
+		j.f.Abort()
+		return err
+	}
+	return j.f.Commit()
+}
+
+// Items writes embedded chunks with vectorstore's codecs, so compression, encryption, and
+// sharding follow the same rules as embed --out. The file is written once, on Commit.
+type Items struct {
+	Path      string
+	ShardSize int // > 0 writes numbered shards plus a manifest at Path
+	Model     string
+	Encrypt   bool
+	Manifest  *vectorstore.ShardManifest // set by Commit when sharding
+	items     []vectorstore.Item
+}
+
+// NewItems returns an embeddings file backend
+func NewItems(path string, shardSize int, model string, encrypt bool) *Items {
+	return &Items{Path: path, ShardSize: shardSize, Model: model, Encrypt: encrypt}
+}
+
+func (it *Items) Write(records []vectorstore.Item) error {
+	it.items = append(it.items, records...)
+	return nil
+}
+
+func (it *Items) Commit() error {
+	if it.ShardSize > 0 {
+		m, err := vectorstore.WriteShards(it.Path, it.items, it.ShardSize, it.Model, it.Encrypt)
+		if err != nil {
+			return err
+		}
+		it.Manifest = m
+		return nil
+	}
+	return vectorstore.WriteItems(it.Path, it.items, it.Encrypt)
+}
+
+// Store upserts embedded chunks into a named collection on Commit
+type Store struct {
+	Store      *vectorstore.Store
+	Collection string
+	Model      string
+	Encrypt    bool
+	Info       *vectorstore.CollectionInfo // set by Commit
+	items      []vectorstore.Item
+}
+
+// NewStore returns a collection backend
+func NewStore(store *vectorstore.Store, collection, model string, encrypt bool) *Store {
+	return &Store{Store: store, Collection: collection, Model: model, Encrypt: encrypt}
+}
+
+func (s *Store) Write(records []vectorstore.Item) error {
+	s.items = append(s.items, records...)
+	return nil
+}
+
+func (s *Store) Commit() error {
+	info, err := s.Store.Upsert(s.Collection, s.Model, s.items, s.Encrypt)
+	if err != nil {
+		return err
+	}
+	s.Info = info
+	return nil
+}
+
+// Qdrant upserts embedded chunks into a Qdrant collection on every flush, so a crashed run
+// keeps what it already wrote
+type Qdrant struct {
+	Client *vectorstore.Qdrant
+	Model  string
+}
+
+// NewQdrant returns a Qdrant collection backend
+func NewQdrant(client *vectorstore.Qdrant, model string) *Qdrant {
+	return &Qdrant{Client: client, Model: model}
+}
+
+func (q *Qdrant) Write(records []vectorstore.Item) error {
+	return q.Client.Upsert(context.Background(), q.Model, records)
+}
+
+func (q *Qdrant) Commit() error {
+	return nil
+}
+
+// Checkpoint appends every successfully embedded item to a JSONL progress file as records
+// are flushed, so an interrupted run can resume without re-embedding them. The file is
+// readable only by its owner, and when encrypting, each line is sealed on its own and
+// base64-encoded. Commit removes the file unless some chunks failed; register it after the
+// run's real outputs so that only happens once they have committed.
+type Checkpoint struct {
+	Path   string
+	Failed int    // chunks that came back with an error; they stay out of the file
+	key    []byte // seals each line when set
+	done   map[string]struct{}
+	f      *os.File
+	w      *bufio.Writer
+}
+
+// ReadCheckpoint returns the items recorded in the progress file at path, decrypting sealed
+// lines with the configured key. A line cut short by a crash is skipped.
+func ReadCheckpoint(path string) ([]vectorstore.Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var items []vectorstore.Item
+	var unreadable error // a sealed line that would not open; only the last line may be cut short
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if unreadable != nil {
+			return nil, fmt.Errorf("%s: %w", path, unreadable)
+		}
+		line := scanner.Bytes()
+		if len(line) > 0 && line[0] != '{' {
+			if line, unreadable = openLine(line); unreadable != nil {
+				continue
+			}
+		}
+		var it vectorstore.Item
+		if err := json.Unmarshal(line, &it); err != nil || it.ID == "" {
+			continue
+		}
+		items = append(items, it)
+	}
+	return items, scanner.Err()
+}
+
+// openLine decodes and decrypts a checkpoint line written while encrypting
+func openLine(line []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil {
+		return nil, err
+	}
+	return secure.Open(sealed)
+}
+
+// NewCheckpoint starts the progress file at path over with the items already embedded by an
+// earlier run (nil for a fresh run); later writes skip those IDs. With encrypt, every line
+// is sealed with the configured key.
+func NewCheckpoint(path string, done []vectorstore.Item, encrypt bool) (*Checkpoint, error) {
+	var key []byte
+	if encrypt {
+		var err error
+		if key, err = secure.LoadKey(); err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, fmt.Errorf("encryption requested but %s is not set", secure.KeyEnvVar)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	// An existing file keeps its mode through O_TRUNC
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return nil, err
+	}
+	c := &Checkpoint{Path: path, key: key, done: make(map[string]struct{}, len(done)), f: f, w: bufio.NewWriter(f)}
+	if err := c.append(done); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// append writes the items not recorded yet and flushes them to the file
+func (c *Checkpoint) append(items []vectorstore.Item) error {
+	for _, it := range items {
+		if _, ok := c.done[it.ID]; ok {
+			continue
+		}
+		b, err := json.Marshal(it)
+		if err != nil {
+			return err
+		}
+		if c.key != nil {
+			sealed, err := secure.Encrypt(c.key, b)
+			if err != nil {
+				return err
+			}
+			b = []byte(base64.StdEncoding.EncodeToString(sealed))
+		}
+		c.w.Write(b)
+		c.w.WriteByte('\n')
+		c.done[it.ID] = struct{}{}
+	}
+	return c.w.Flush()
+}
+
+func (c *Checkpoint) Write(records []vectorstore.Item) error {
+	embedded := make([]vectorstore.Item, 0, len(records))
+	for _, r := range records {
+		// Failed chunks are left out so a resumed run tries them again
+		if r.Error == nil && len(r.Embedding) > 0 {
+			embedded = append(embedded, r)
+		} else {
+			c.Failed++
+		}
+	}
+	return c.append(embedded)
+}
+
+func (c *Checkpoint) Commit() error {
+	if err := c.w.Flush(); err != nil {
+		c.f.Close()
+		return err
+	}
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+	if c.Failed > 0 {
+		return nil
+	}
+	return os.Remove(c.Path)
+}
@@ -0,0 +1,153 @@
+// Package sink collects records from concurrent producers and writes them to one or more
+// backends with buffering, periodic flushes, and an atomic final commit.
+package sink
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Backend persists records handed over by a Sink. Write receives only the records added
+// since the previous flush; Commit is called once when the sink is closed. A Sink serializes
+// calls, so backends need no locking of their own.
+type Backend[T any] interface {
+	Write(records []T) error
+	Commit() error
+}
+
+// Options control when buffered records are flushed to the backends
+type Options struct {
+	// BufferSize flushes once this many records are pending (0 = only on interval or close)
+	BufferSize int
+	// FlushInterval flushes pending records periodically (0 = never in the background)
+	FlushInterval time.Duration
+}
+
+// Sink is a concurrency-safe results writer shared by worker goroutines
+type Sink[T any] struct {
+	opts     Options
+	backends []Backend[T]
+
+	writeMu sync.Mutex // held across backend calls, so they stay serialized and in order
+	mu      sync.Mutex // guards the fields below; never held during backend I/O
+	pending []T
+	count   int
+	err     error
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a sink writing to backends and starts the periodic flusher if configured
+func New[T any](opts Options, backends ...Backend[T]) *Sink[T] {
+	s := &Sink[T]{opts: opts, backends: backends}
+	if opts.FlushInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.flushLoop()
+	}
+	return s
+}
+
+func (s *Sink[T]) flushLoop() {
+	defer close(s.done)
+	t := time.NewTicker(s.opts.FlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.Flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Add buffers records, flushing when the buffer is full. It returns the first error any
+// earlier flush hit so producers can stop early.
+func (s *Sink[T]) Add(records ...T) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errors.New("sink: add after close")
+	}
+	s.pending = append(s.pending, records...)
+	s.count += len(records)
+	full := s.opts.BufferSize > 0 && len(s.pending) >= s.opts.BufferSize
+	err := s.err
+	s.mu.Unlock()
+	if full {
+		return s.Flush()
+	}
+	return err
+}
+
+// Flush writes pending records to every backend. Producers keep adding records while the
+// backends write; only a producer that fills the buffer again waits for the write to finish.
+func (s *Sink[T]) Flush() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.flushLocked()
+}
+
+// flushLocked hands the pending records to the backends; the caller holds writeMu
+func (s *Sink[T]) flushLocked() error {
+	s.mu.Lock()
+	batch, err := s.pending, s.err
+	if err == nil {
+		s.pending = nil
+	}
+	s.mu.Unlock()
+	if err != nil || len(batch) == 0 {
+		return err
+	}
+	for _, b := range s.backends {
+		if err := b.Write(batch); err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// Count returns the number of records added so far
+func (s *Sink[T]) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Close stops the periodic flusher, flushes what is left, and commits every backend
+func (s *Sink[T]) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return s.err
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.flushLocked(); err != nil {
+		return err
+	}
+	for _, b := range s.backends {
+		if err := b.Commit(); err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
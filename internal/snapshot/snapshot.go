@@ -0,0 +1,278 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/vectorstore"
+)
+
+const (
+	manifestFile   = "manifest.json"
+	embeddingsFile = "embeddings.json"
+	shardsDir      = "shards"
+)
+
+// Manifest describes a single snapshot of an embeddings file
+type Manifest struct {
+	ID        string            `json:"id"`
+	Label     string            `json:"label,omitempty"`
+	Source    string            `json:"source"`
+	CreatedAt time.Time         `json:"created_at"`
+	FileHash  string            `json:"file_hash"`
+	DictHash  string            `json:"dict_hash,omitempty"` // content dictionary saved with a compressed file
+	Shards    map[string]string `json:"shards,omitempty"`    // shard file of a sharded file (and its dictionary), relative to it -> file hash
+	ItemCount int               `json:"item_count"`
+	Items     map[string]string `json:"items"` // chunk ID -> content hash
+}
+
+// Diff lists the chunk IDs that differ between two snapshots
+type Diff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// HashContent returns the hex-encoded SHA-256 of a chunk's content
+func HashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create copies the embeddings file, and the content dictionary of a compressed one, into a
+// new timestamped snapshot directory under dir and writes a manifest with per-chunk content
+// hashes. A shard manifest is copied with every shard it lists.
+func Create(dir, source, label string) (*Manifest, error) {
+	// Encrypted files are snapshotted as-is; only the manifest needs the plaintext
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	dict, err := os.ReadFile(vectorstore.DictPath(source))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var items []vectorstore.Item
+	shards := map[string][]byte{}
+	if vectorstore.IsShardManifest(source) {
+		sm, err := vectorstore.ReadShardManifest(source)
+		if err != nil {
+			return nil, err
+		}
+		if items, err = sm.ReadAll(); err != nil {
+			return nil, err
+		}
+		for _, sh := range sm.Shards {
+			if !filepath.IsLocal(sh.File) {
+				return nil, fmt.Errorf("%s: shard %s is outside its directory", source, sh.File)
+			}
+			for _, name := range []string{sh.File, vectorstore.DictPath(sh.File)} {
+				b, err := os.ReadFile(filepath.Join(filepath.Dir(source), name))
+				if os.IsNotExist(err) && name != sh.File {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				shards[name] = b
+			}
+		}
+	} else if items, err = vectorstore.ReadItemsLazy(source); err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now().UTC()
+	manifest := &Manifest{
+		ID:        createdAt.Format("20060102T150405Z"),
+		Label:     label,
+		Source:    source,
+		CreatedAt: createdAt,
+		FileHash:  HashContent(string(data)),
+		ItemCount: len(items),
+		Items:     make(map[string]string, len(items)),
+	}
+	if dict != nil {
+		manifest.DictHash = HashContent(string(dict))
+	}
+	if len(shards) > 0 {
+		manifest.Shards = make(map[string]string, len(shards))
+		for name, b := range shards {
+			manifest.Shards[name] = HashContent(string(b))
+		}
+	}
+	for _, it := range items {
+		key := it.ID
+		if key == "" {
+			key = fmt.Sprintf("chunk_%d", it.ChunkIndex)
+		}
+		manifest.Items[key] = HashContent(it.Text())
+	}
+
+	snapDir := filepath.Join(dir, manifest.ID)
+	if _, err := os.Stat(snapDir); err == nil {
+		return nil, fmt.Errorf("snapshot %s already exists", manifest.ID)
+	}
+	if err := os.MkdirAll(snapDir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(snapDir, embeddingsFile), data, 0o644, false); err != nil {
+		return nil, err
+	}
+	if dict != nil {
+		if err := atomicfile.WriteFile(vectorstore.DictPath(filepath.Join(snapDir, embeddingsFile)), dict, 0o644, false); err != nil {
+			return nil, err
+		}
+	}
+	for name, b := range shards {
+		path := filepath.Join(snapDir, shardsDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := atomicfile.WriteFile(path, b, 0o644, false); err != nil {
+			return nil, err
+		}
+	}
+
+	mb, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(snapDir, manifestFile), mb, 0o644, false); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Load reads the manifest of a snapshot by ID
+func Load(dir, id string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, id, manifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot %s not found", id)
+		}
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest for %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// List returns all snapshots under dir, oldest first
+func List(dir string) ([]*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := Load(dir, e.Name())
+		if err != nil {
+			// Skip directories that aren't snapshots
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.Before(manifests[j].CreatedAt)
+	})
+	return manifests, nil
+}
+
+// Restore copies the snapshot's embeddings file back to dest, replacing it atomically, along
+// with the content dictionary its compressed content needs. The shards of a shard manifest
+// are restored next to dest before the manifest that lists them.
+func Restore(dir, id, dest string) (*Manifest, error) {
+	m, err := Load(dir, id)
+	if err != nil {
+		return nil, err
+	}
+	snapFile := filepath.Join(dir, id, embeddingsFile)
+	data, err := readVerified(snapFile, m.FileHash, id)
+	if err != nil {
+		return nil, err
+	}
+	var dict []byte
+	if m.DictHash != "" {
+		if dict, err = readVerified(vectorstore.DictPath(snapFile), m.DictHash, id); err != nil {
+			return nil, err
+		}
+	}
+	shards := make(map[string][]byte, len(m.Shards))
+	for name, hash := range m.Shards {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("snapshot %s is corrupt: shard %s is outside its directory", id, name)
+		}
+		if shards[name], err = readVerified(filepath.Join(dir, id, shardsDir, name), hash, id); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, b := range shards {
+		if err := atomicfile.WriteFile(filepath.Join(filepath.Dir(dest), name), b, 0o644, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if dict != nil {
+		if err := atomicfile.WriteFile(vectorstore.DictPath(dest), dict, 0o644, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := atomicfile.WriteFile(dest, data, 0o644, true); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readVerified reads a file of snapshot id, checking it still has the hash it was saved with
+func readVerified(path, hash, id string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if HashContent(string(data)) != hash {
+		return nil, fmt.Errorf("snapshot %s is corrupt: hash mismatch for %s", id, filepath.Base(path))
+	}
+	return data, nil
+}
+
+// Compare returns the chunk-level differences going from snapshot a to snapshot b
+func Compare(a, b *Manifest) Diff {
+	var d Diff
+	for id, hash := range b.Items {
+		oldHash, ok := a.Items[id]
+		if !ok {
+			d.Added = append(d.Added, id)
+		} else if oldHash != hash {
+			d.Changed = append(d.Changed, id)
+		}
+	}
+	for id := range a.Items {
+		if _, ok := b.Items[id]; !ok {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
@@ -0,0 +1,327 @@
+// Package sweep measures retrieval quality over a labeled query set across similarity
+// thresholds and top-k values, so search and rag defaults can be chosen per corpus
+package sweep
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kirk-ai/internal/secure"
+	"kirk-ai/internal/vectorstore"
+)
+
+// Query is a labeled query: the chunks or pages that should be retrieved for it. Each
+// relevant entry is a chunk ID or a page's source URL; a page counts as found when any of
+// its chunks is retrieved.
+type Query struct {
+	Query    string   `json:"query"`
+	Relevant []string `json:"relevant"`
+}
+
+// LoadQueries reads a query set written as a JSON array or as one JSON object per line
+func LoadQueries(path string) ([]Query, error) {
+	queries, err := loadRecords[Query](path)
+	if err != nil {
+		return nil, err
+	}
+	for i, q := range queries {
+		if strings.TrimSpace(q.Query) == "" || len(q.Relevant) == 0 {
+			return nil, fmt.Errorf("%s: query %d needs a query and at least one relevant chunk ID or URL", path, i+1)
+		}
+	}
+	return queries, nil
+}
+
+// loadRecords reads records written as a JSON array or as one JSON object per line, skipping
+// blank lines and lines starting with '#'
+func loadRecords[T any](path string) ([]T, error) {
+	data, err := secure.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []T
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		return records, nil
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var r T
+		if err := json.Unmarshal([]byte(text), &r); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		records = append(records, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// QA is a question with the passage that answers it. A Q/A set measures an embedding
+// model on its own: each question should retrieve its answer from among all the answers.
+type QA struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// LoadQA reads a Q/A set written as a JSON array or as one JSON object per line
+func LoadQA(path string) ([]QA, error) {
+	pairs, err := loadRecords[QA](path)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range pairs {
+		if strings.TrimSpace(p.Question) == "" || strings.TrimSpace(p.Answer) == "" {
+			return nil, fmt.Errorf("%s: pair %d needs a question and an answer", path, i+1)
+		}
+	}
+	return pairs, nil
+}
+
+// Case is a question for an end-to-end RAG evaluation: the answer expected of it and,
+// optionally, the chunk IDs or source URLs its context should come from
+type Case struct {
+	Question string   `json:"question"`
+	Expected string   `json:"expected"`
+	Relevant []string `json:"relevant,omitempty"`
+}
+
+// LoadCases reads an evaluation set written as a JSON array or as one JSON object per line.
+// "answer" is accepted for "expected", so a Q/A set can be used as it is.
+func LoadCases(path string) ([]Case, error) {
+	type record struct {
+		Case
+		Answer string `json:"answer"`
+	}
+	records, err := loadRecords[record](path)
+	if err != nil {
+		return nil, err
+	}
+	cases := make([]Case, len(records))
+	for i, r := range records {
+		cases[i] = r.Case
+		if cases[i].Expected == "" {
+			cases[i].Expected = r.Answer
+		}
+		if strings.TrimSpace(cases[i].Question) == "" || strings.TrimSpace(cases[i].Expected) == "" {
+			return nil, fmt.Errorf("%s: case %d needs a question and an expected answer", path, i+1)
+		}
+	}
+	return cases, nil
+}
+
+// Found reports whether any result matches a relevant chunk ID or source URL
+func Found(relevant []string, results []vectorstore.SearchResult) bool {
+	labels := make(map[string]bool, len(relevant))
+	for _, l := range relevant {
+		labels[l] = false
+	}
+	for _, res := range results {
+		if matchLabels(labels, res.Item) {
+			return true
+		}
+	}
+	return false
+}
+
+// QAScore is how well question embeddings find their answers among all the answers
+type QAScore struct {
+	Top1 float64 `json:"top1"` // share of questions whose answer ranks first
+	// HitAtK is the share of questions whose answer ranks in the first K
+	HitAtK float64 `json:"hit_at_k"`
+	K      int     `json:"k"`
+	MRR    float64 `json:"mrr"` // mean reciprocal rank of the answer
+	// Margin is the mean similarity of each question to its answer minus that to the best
+	// other answer; the wider, the more robust a similarity threshold
+	Margin float64 `json:"margin"`
+}
+
+// EvaluateQA ranks every answer for each question, where answers[i] answers questions[i]
+func EvaluateQA(questions, answers [][]float64, k int) QAScore {
+	score := QAScore{K: k}
+	if len(questions) == 0 {
+		return score
+	}
+	for i, q := range questions {
+		own := vectorstore.CosineSimilarity(q, answers[i])
+		rank, bestOther := 1, math.Inf(-1)
+		for j, a := range answers {
+			if j == i {
+				continue
+			}
+			sim := vectorstore.CosineSimilarity(q, a)
+			if sim > own {
+				rank++
+			}
+			bestOther = math.Max(bestOther, sim)
+		}
+		if rank == 1 {
+			score.Top1++
+		}
+		if rank <= k {
+			score.HitAtK++
+		}
+		score.MRR += 1 / float64(rank)
+		if len(answers) > 1 {
+			score.Margin += own - bestOther
+		}
+	}
+	n := float64(len(questions))
+	score.Top1 /= n
+	score.HitAtK /= n
+	score.MRR /= n
+	score.Margin /= n
+	return score
+}
+
+// Ranked is a query with the results retrieved for it, best first
+type Ranked struct {
+	Query   Query
+	Results []vectorstore.SearchResult
+}
+
+// Point is retrieval quality at one threshold and top-k
+type Point struct {
+	Threshold float64 `json:"threshold"`
+	TopK      int     `json:"top_k"`
+	// Precision is the share of retrieved chunks that match a label, over all queries
+	Precision float64 `json:"precision"`
+	// Recall is the mean share of each query's labels that were found
+	Recall float64 `json:"recall"`
+	F1     float64 `json:"f1"`
+	// HitRate is the share of queries with at least one relevant chunk retrieved
+	HitRate float64 `json:"hit_rate"`
+	// Empty is the share of queries for which nothing passed the threshold
+	Empty float64 `json:"empty"`
+	// Retrieved is the mean number of chunks returned per query
+	Retrieved float64 `json:"retrieved"`
+}
+
+// Evaluate scores every combination of threshold and top-k. Each query's results must be
+// sorted by similarity and hold at least the largest top-k at the lowest threshold.
+func Evaluate(ranked []Ranked, thresholds []float64, topKs []int) []Point {
+	var points []Point
+	for _, k := range topKs {
+		for _, t := range thresholds {
+			points = append(points, evaluate(ranked, t, k))
+		}
+	}
+	return points
+}
+
+func evaluate(ranked []Ranked, threshold float64, topK int) Point {
+	p := Point{Threshold: threshold, TopK: topK}
+	if len(ranked) == 0 {
+		return p
+	}
+	var retrieved, relevant int
+	var recall float64
+	for _, r := range ranked {
+		labels := make(map[string]bool, len(r.Query.Relevant))
+		for _, l := range r.Query.Relevant {
+			labels[l] = false
+		}
+		n := 0
+		for _, res := range r.Results {
+			if n == topK || res.Similarity < threshold {
+				break
+			}
+			n++
+			if matchLabels(labels, res.Item) {
+				relevant++
+			}
+		}
+		retrieved += n
+		found := 0
+		for _, ok := range labels {
+			if ok {
+				found++
+			}
+		}
+		recall += float64(found) / float64(len(labels))
+		if found > 0 {
+			p.HitRate++
+		}
+		if n == 0 {
+			p.Empty++
+		}
+	}
+	q := float64(len(ranked))
+	if retrieved > 0 {
+		p.Precision = float64(relevant) / float64(retrieved)
+	}
+	p.Recall = recall / q
+	if p.Precision+p.Recall > 0 {
+		p.F1 = 2 * p.Precision * p.Recall / (p.Precision + p.Recall)
+	}
+	p.HitRate /= q
+	p.Empty /= q
+	p.Retrieved = float64(retrieved) / q
+	return p
+}
+
+// matchLabels marks the labels it satisfies, by chunk ID or source URL, and reports whether
+// it satisfied any
+func matchLabels(labels map[string]bool, it vectorstore.Item) bool {
+	hit := false
+	for _, key := range []string{it.ID, sourceURL(it)} {
+		if _, ok := labels[key]; ok && key != "" {
+			labels[key] = true
+			hit = true
+		}
+	}
+	return hit
+}
+
+func sourceURL(it vectorstore.Item) string {
+	s, _ := it.Metadata["source_url"].(string)
+	return s
+}
+
+// Best returns the point with the highest F1, preferring the higher threshold and then the
+// smaller top-k on ties, since they retrieve less for the same quality
+func Best(points []Point) Point {
+	sorted := append([]Point(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.F1 != b.F1 {
+			return a.F1 > b.F1
+		}
+		if a.Threshold != b.Threshold {
+			return a.Threshold > b.Threshold
+		}
+		return a.TopK < b.TopK
+	})
+	if len(sorted) == 0 {
+		return Point{}
+	}
+	return sorted[0]
+}
+
+// WriteCSV writes the points as a CSV table with a header row
+func WriteCSV(w io.Writer, points []Point) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"threshold", "top_k", "precision", "recall", "f1", "hit_rate", "empty", "retrieved"})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, p := range points {
+		cw.Write([]string{f(p.Threshold), strconv.Itoa(p.TopK), f(p.Precision), f(p.Recall), f(p.F1), f(p.HitRate), f(p.Empty), f(p.Retrieved)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,177 @@
+// Package urlutil normalizes crawled URLs and maps them to safe file names, with a mapping
+// file for finding the URL a saved file came from
+package urlutil
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MaxFilenameLength bounds the names Filename returns, leaving room for an extension within
+// the common 255-byte file name limit
+const MaxFilenameLength = 150
+
+// MappingFile is the name of the URL mapping kept next to saved files
+const MappingFile = "urls.jsonl"
+
+// hashLength is how many hex digits of the URL's SHA-256 a file name ends with
+const hashLength = 12
+
+var unsafeFilenameRE = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// Normalize removes the fragment and trailing slashes of an absolute URL, so the same page
+// reached by different links gets one form. Relative or unparsable URLs return "".
+func Normalize(raw string) string {
+	r := strings.TrimSpace(raw)
+	if r == "" {
+		return ""
+	}
+	u, err := url.Parse(r)
+	if err != nil {
+		return ""
+	}
+	// Ensure scheme and host exist for relative inputs
+	if !u.IsAbs() {
+		return ""
+	}
+	u.Fragment = ""
+	// collapse duplicate slashes at end
+	u.Path = strings.TrimRight(u.Path, "/")
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// Sanitize replaces every character outside [A-Za-z0-9._-] with '_'. It is how snapshot
+// files were named before Filename; different URLs can sanitize to the same name.
+func Sanitize(s string) string {
+	return unsafeFilenameRE.ReplaceAllString(s, "_")
+}
+
+// Filename returns a file name (without extension) for u: the sanitized URL, cut to fit
+// MaxFilenameLength, followed by a hash of the full URL so that URLs which sanitize or
+// truncate to the same text still get distinct names
+func Filename(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	suffix := "-" + hex.EncodeToString(sum[:])[:hashLength]
+	name := Sanitize(u)
+	if limit := MaxFilenameLength - len(suffix); len(name) > limit {
+		name = name[:limit]
+	}
+	return name + suffix
+}
+
+// mappingEntry is one line of a mapping file
+type mappingEntry struct {
+	File string `json:"file"`
+	URL  string `json:"url"`
+}
+
+// Mapping records which URL each saved file came from and which file each URL was saved
+// to. Entries added to an opened mapping are appended to its file at once, so a crawl that
+// dies keeps the mapping of everything it saved. It is safe for concurrent use.
+type Mapping struct {
+	mu     sync.Mutex
+	byFile map[string]string
+	byURL  map[string]string
+	f      *os.File
+}
+
+// LoadMapping reads the mapping file at path; a missing file yields an empty mapping
+func LoadMapping(path string) (*Mapping, error) {
+	m := &Mapping{byFile: map[string]string{}, byURL: map[string]string{}}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e mappingEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.File == "" || e.URL == "" {
+			continue
+		}
+		m.byFile[e.File] = e.URL
+		m.byURL[e.URL] = e.File
+	}
+	return m, scanner.Err()
+}
+
+// OpenMapping loads the mapping file at path and opens it for appending, creating it and
+// its directory if needed
+func OpenMapping(path string) (*Mapping, error) {
+	m, err := LoadMapping(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if m.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add returns the file name for u with ext appended, recording it in the mapping the first
+// time u is seen
+func (m *Mapping) Add(u, ext string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if file, ok := m.byURL[u]; ok {
+		return file, nil
+	}
+	file := Filename(u) + ext
+	m.byFile[file] = u
+	m.byURL[u] = file
+	if m.f == nil {
+		return file, nil
+	}
+	b, err := json.Marshal(mappingEntry{File: file, URL: u})
+	if err != nil {
+		return file, err
+	}
+	_, err = m.f.Write(append(b, '\n'))
+	return file, err
+}
+
+// URL returns the URL file was saved from
+func (m *Mapping) URL(file string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.byFile[file]
+	return u, ok
+}
+
+// File returns the file u was saved to
+func (m *Mapping) File(u string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.byURL[u]
+	return file, ok
+}
+
+// Close closes the mapping file of an opened mapping
+func (m *Mapping) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.f == nil {
+		return nil
+	}
+	err := m.f.Close()
+	m.f = nil
+	return err
+}
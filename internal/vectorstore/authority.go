@@ -0,0 +1,43 @@
+package vectorstore
+
+import (
+	"strings"
+)
+
+// SourceURL returns the page an item was chunked from: metadata source_url, or the ID up to '#'
+func SourceURL(it Item) string {
+	if it.Metadata != nil {
+		if s, ok := it.Metadata["source_url"].(string); ok && s != "" {
+			return s
+		}
+	}
+	if i := strings.Index(it.ID, "#"); i > 0 {
+		return it.ID[:i]
+	}
+	return ""
+}
+
+// DeepLink returns a link to the chunk's location on its source page, using the text
+// fragment recorded when it was chunked, or the plain source URL when none was recorded
+func DeepLink(it Item) string {
+	src := SourceURL(it)
+	if src == "" || strings.Contains(src, "#") {
+		return src
+	}
+	if frag, ok := it.Metadata["text_fragment"].(string); ok && frag != "" {
+		return src + "#:~:text=" + frag
+	}
+	return src
+}
+
+// BlendAuthority re-ranks results by (1-weight)*similarity + weight*authority of the item's
+// source page, replacing Similarity with the blended score, and returns up to topK results.
+// Pages missing from authority score 0.
+func BlendAuthority(results []SearchResult, authority map[string]float64, weight float64, topK int) []SearchResult {
+	blended := make([]SearchResult, len(results))
+	for i, r := range results {
+		r.Similarity = (1-weight)*r.Similarity + weight*authority[SourceURL(r.Item)]
+		blended[i] = r
+	}
+	return rank(blended, topK)
+}
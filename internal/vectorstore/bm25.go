@@ -0,0 +1,211 @@
+package vectorstore
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+const collectionKeywordFile = "index.bm25"
+
+// BM25 parameters: K1 sets how quickly repeated terms stop adding to a score, B how much
+// long chunks are penalized
+const (
+	DefaultBM25K1 = 1.2
+	DefaultBM25B  = 0.75
+)
+
+// DefaultRRFK is the usual reciprocal rank fusion constant; larger values flatten the
+// advantage of top ranks
+const DefaultRRFK = 60
+
+// Posting is one chunk containing a term and how often it does
+type Posting struct {
+	Doc  int32
+	Freq int32
+}
+
+// KeywordIndex is a BM25 index over the content and titles of the items of an embeddings
+// file or collection. It finds exact names and quotes that embeddings blur together. Like
+// HNSW, only the postings are persisted and Attach checks the items against Fingerprint.
+type KeywordIndex struct {
+	Fingerprint string
+	K1          float64
+	B           float64
+	Lengths     []int32 // token count of each item
+	AvgLength   float64
+	Postings    map[string][]Posting
+
+	items []Item
+}
+
+// Tokenize splits text into the lowercase words and numbers the keyword index matches on
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// indexedText is what the keyword index reads of an item: its title and content
+func indexedText(it Item) string {
+	if title, ok := it.Metadata["title"].(string); ok && title != "" {
+		return title + "\n" + it.Text()
+	}
+	return it.Text()
+}
+
+// BuildKeywordIndex indexes the text of items
+func BuildKeywordIndex(items []Item) *KeywordIndex {
+	k := &KeywordIndex{
+		Fingerprint: Fingerprint(items),
+		K1:          DefaultBM25K1,
+		B:           DefaultBM25B,
+		Lengths:     make([]int32, len(items)),
+		Postings:    map[string][]Posting{},
+		items:       items,
+	}
+	total := 0
+	for i, it := range items {
+		tokens := Tokenize(indexedText(it))
+		k.Lengths[i] = int32(len(tokens))
+		total += len(tokens)
+		freq := map[string]int32{}
+		for _, t := range tokens {
+			freq[t]++
+		}
+		for t, n := range freq {
+			k.Postings[t] = append(k.Postings[t], Posting{Doc: int32(i), Freq: n})
+		}
+	}
+	if len(items) > 0 {
+		k.AvgLength = float64(total) / float64(len(items))
+	}
+	return k
+}
+
+// Attach supplies the items the index was built from, failing when they have changed
+func (k *KeywordIndex) Attach(items []Item) error {
+	if Fingerprint(items) != k.Fingerprint || len(items) != len(k.Lengths) {
+		return fmt.Errorf("index is stale: the embeddings changed since it was built")
+	}
+	k.items = items
+	return nil
+}
+
+// Len returns the number of indexed items
+func (k *KeywordIndex) Len() int {
+	return len(k.Lengths)
+}
+
+// Search returns up to topK items containing words of query, best BM25 score first. The
+// score is returned as Similarity; it is not on the 0-1 scale of cosine similarity.
+func (k *KeywordIndex) Search(query string, topK int) []SearchResult {
+	scores := map[int32]float64{}
+	n := float64(len(k.Lengths))
+	seen := map[string]bool{}
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		postings := k.Postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.Freq)
+			norm := 1 - k.B
+			if k.AvgLength > 0 {
+				norm += k.B * float64(k.Lengths[p.Doc]) / k.AvgLength
+			}
+			scores[p.Doc] += idf * tf * (k.K1 + 1) / (tf + k.K1*norm)
+		}
+	}
+	candidates := make([]SearchResult, 0, len(scores))
+	for doc, score := range scores {
+		candidates = append(candidates, SearchResult{Item: k.items[doc], Similarity: score})
+	}
+	return rank(candidates, topK)
+}
+
+// FuseRanks merges ranked result lists by reciprocal rank fusion: an item scores the sum of
+// 1/(k+rank) over the lists it appears in, so items ranked well by several lists come
+// first whatever their scores are measured in. Each result keeps the Similarity of the
+// first list it appears in. Up to topK results are returned.
+func FuseRanks(k float64, topK int, lists ...[]SearchResult) []SearchResult {
+	fused := map[string]float64{}
+	first := map[string]SearchResult{}
+	var order []string
+	for _, list := range lists {
+		for pos, r := range list {
+			key := DedupKey(r.Item)
+			if _, ok := first[key]; !ok {
+				first[key] = r
+				order = append(order, key)
+			}
+			fused[key] += 1 / (k + float64(pos+1))
+		}
+	}
+	// Stable on the order of first appearance, so the first list breaks ties
+	out := make([]SearchResult, len(order))
+	for i, key := range order {
+		out[i] = first[key]
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return fused[DedupKey(out[i].Item)] > fused[DedupKey(out[j].Item)]
+	})
+	if topK > 0 && len(out) > topK {
+		out = out[:topK]
+	}
+	return out
+}
+
+// KeywordPath returns the keyword index file kept next to an embeddings file
+func KeywordPath(embeddingsPath string) string {
+	return embeddingsPath + ".bm25"
+}
+
+// KeywordPath returns the keyword index file of a collection
+func (s *Store) KeywordPath(name string) string {
+	return filepath.Join(s.collectionDir(name), collectionKeywordFile)
+}
+
+// WriteKeywordIndex atomically writes the index to path
+func WriteKeywordIndex(path string, k *KeywordIndex) error {
+	f, err := atomicfile.Create(path, 0o644, false)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if err := gob.NewEncoder(f).Encode(k); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// ReadKeywordIndex reads an index written by WriteKeywordIndex. A missing file returns a
+// nil index and no error.
+func ReadKeywordIndex(path string) (*KeywordIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var k KeywordIndex
+	if err := gob.NewDecoder(f).Decode(&k); err != nil {
+		return nil, fmt.Errorf("parse index %s: %w", path, err)
+	}
+	return &k, nil
+}
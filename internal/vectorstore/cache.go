@@ -0,0 +1,79 @@
+package vectorstore
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// ResultCache keeps the results of recent searches so a repeated query against the same
+// index is answered without scanning it again. It is safe for concurrent use; the least
+// recently used entry is evicted once size entries are held. A nil cache holds nothing.
+type ResultCache struct {
+	size    int
+	mu      sync.Mutex
+	order   *list.List // most recently used first; values are *cacheEntry
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	results []SearchResult
+}
+
+// NewResultCache returns a cache holding up to size searches
+func NewResultCache(size int) *ResultCache {
+	return &ResultCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// CacheKey identifies a search: the query embedding, the result count and threshold, and
+// the version of the index searched, so results are never served from an older index
+func CacheKey(queryEmbedding []float64, topK int, threshold float64, indexVersion string) string {
+	h := sha256.New()
+	var b [8]byte
+	for _, v := range queryEmbedding {
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+		h.Write(b[:])
+	}
+	return fmt.Sprintf("%s/%d/%g/%s", hex.EncodeToString(h.Sum(nil)), topK, threshold, indexVersion)
+}
+
+// Get returns a copy of the cached results for key
+func (c *ResultCache) Get(key string) ([]SearchResult, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return append([]SearchResult(nil), el.Value.(*cacheEntry).results...), true
+}
+
+// Put stores the results of the search identified by key
+func (c *ResultCache) Put(key string, results []SearchResult) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results = append([]SearchResult(nil), results...)
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).results = results
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, results: results})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,147 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+const collectionCalibrationFile = "calibration.json"
+
+// Calibration records similarity thresholds measured for one corpus and embedding model
+// by `embeddings calibrate`, replacing the generic defaults for search and rag.
+type Calibration struct {
+	EmbeddingModel string       `json:"embedding_model"`
+	Samples        int          `json:"samples"`
+	Positive       Distribution `json:"positive"` // query vs. the chunk it was written from
+	Negative       Distribution `json:"negative"` // query vs. unrelated chunks
+	Search         float64      `json:"search_threshold"`
+	RAG            float64      `json:"rag_threshold"`
+	RAGStrict      float64      `json:"rag_strict_threshold"` // large or progressive rag contexts
+	CalibratedAt   time.Time    `json:"calibrated_at"`
+}
+
+// Distribution summarizes a set of similarity scores
+type Distribution struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	Min   float64 `json:"min"`
+	P10   float64 `json:"p10"`
+	P25   float64 `json:"p25"`
+	P50   float64 `json:"p50"`
+	P75   float64 `json:"p75"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	Max   float64 `json:"max"`
+}
+
+// Distribute computes the distribution of scores
+func Distribute(scores []float64) Distribution {
+	if len(scores) == 0 {
+		return Distribution{}
+	}
+	s := append([]float64(nil), scores...)
+	sort.Float64s(s)
+	sum := 0.0
+	for _, v := range s {
+		sum += v
+	}
+	return Distribution{
+		Count: len(s),
+		Mean:  sum / float64(len(s)),
+		Min:   s[0],
+		P10:   percentile(s, 0.10),
+		P25:   percentile(s, 0.25),
+		P50:   percentile(s, 0.50),
+		P75:   percentile(s, 0.75),
+		P90:   percentile(s, 0.90),
+		P95:   percentile(s, 0.95),
+		Max:   s[len(s)-1],
+	}
+}
+
+// percentile interpolates the p-th percentile of sorted scores
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// Recommend derives thresholds from the positive and negative score distributions. The rag
+// threshold sits just above almost all unrelated chunks but never drops more than a quarter
+// of the true matches; the strict rag and search thresholds trade recall for precision.
+func (c *Calibration) Recommend() {
+	pos, neg := c.Positive, c.Negative
+	c.RAG = math.Min(neg.P95, pos.P25)
+	c.RAGStrict = math.Max(c.RAG, (neg.P95+pos.P50)/2)
+	c.Search = math.Max(c.RAGStrict, math.Min(neg.Max, pos.P50))
+	c.RAG, c.RAGStrict, c.Search = round2(c.RAG), round2(c.RAGStrict), round2(c.Search)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Separation reports the share of positive scores above the rag threshold and the share of
+// negative scores below it; values near 1 mean the model separates the corpus well
+func (c *Calibration) Separation(positive, negative []float64) (recall, rejection float64) {
+	for _, s := range positive {
+		if s >= c.RAG {
+			recall++
+		}
+	}
+	for _, s := range negative {
+		if s < c.RAG {
+			rejection++
+		}
+	}
+	if len(positive) > 0 {
+		recall /= float64(len(positive))
+	}
+	if len(negative) > 0 {
+		rejection /= float64(len(negative))
+	}
+	return recall, rejection
+}
+
+// CalibrationPath returns the calibration file kept next to an embeddings file or manifest
+func CalibrationPath(embeddingsPath string) string {
+	return embeddingsPath + ".calibration.json"
+}
+
+// ReadCalibration reads a calibration file; a missing file returns nil without error
+func ReadCalibration(path string) (*Calibration, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var c Calibration
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse calibration %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// WriteCalibration atomically writes a calibration file
+func WriteCalibration(path string, c *Calibration) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, b, 0o644, false)
+}
+
+// CalibrationPath returns the calibration file of a collection
+func (s *Store) CalibrationPath(name string) string {
+	return filepath.Join(s.collectionDir(name), collectionCalibrationFile)
+}
@@ -0,0 +1,154 @@
+package vectorstore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats recognized from the file extension on write and the magic bytes on read
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// isJSONL reports whether path names a newline-delimited file (.jsonl, .jsonl.gz, .jsonl.zst)
+func isJSONL(path string) bool {
+	p := strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".zst")
+	return strings.HasSuffix(p, ".jsonl")
+}
+
+// decompress returns data unchanged unless it starts with a gzip or zstd header
+func decompress(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case bytes.HasPrefix(data, zstdMagic):
+		d, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return d.DecodeAll(data, nil)
+	}
+	return data, nil
+}
+
+// compress applies the compression implied by path's extension
+func compress(path string, data []byte) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case strings.HasSuffix(path, ".zst"):
+		e, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer e.Close()
+		return e.EncodeAll(data, nil), nil
+	}
+	return data, nil
+}
+
+// nopWriteCloser lets an uncompressed stream share the compressors' Close
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// CompressWriter wraps w in the streaming compressor implied by path's extension, or in
+// nothing for other paths. Closing it ends the compressed stream but leaves w open.
+func CompressWriter(path string, w io.Writer) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return gzip.NewWriter(w), nil
+	case strings.HasSuffix(path, ".zst"):
+		return zstd.NewWriter(w)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// ParseItems decodes decrypted file contents into items. Compressed data is detected by its
+// header; JSON arrays and newline-delimited JSON are both accepted.
+func ParseItems(name string, data []byte) ([]Item, error) {
+	data, err := decompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %w", name, err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] == '[' {
+		var items []Item
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return items, nil
+	}
+
+	var items []Item
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var it Item
+		if err := json.Unmarshal(b, &it); err != nil {
+			return nil, fmt.Errorf("parse %s line %d: %w", name, line, err)
+		}
+		items = append(items, it)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return items, nil
+}
+
+// encodeItems serializes items in the format implied by path: JSONL for .jsonl, compact JSON
+// when compressed, and pretty-printed JSON otherwise.
+func encodeItems(path string, items []Item) ([]byte, error) {
+	var data []byte
+	switch {
+	case isJSONL(path):
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, it := range items {
+			if err := enc.Encode(it); err != nil {
+				return nil, err
+			}
+		}
+		data = buf.Bytes()
+	case strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".zst"):
+		b, err := json.Marshal(items)
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	default:
+		b, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+	return compress(path, data)
+}
@@ -0,0 +1,208 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+const (
+	collectionInfoFile  = "collection.json"
+	collectionItemsFile = "embeddings.json"
+)
+
+var collectionNameRE = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// CollectionInfo records per-collection metadata, most importantly the embedding model
+// used to build it so queries are embedded with the same model.
+type CollectionInfo struct {
+	Name           string    `json:"name"`
+	EmbeddingModel string    `json:"embedding_model"`
+	Dimension      int       `json:"dimension"`
+	Count          int       `json:"count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Store is a directory holding named collections, one subdirectory per collection
+type Store struct {
+	Dir string
+}
+
+// NewStore returns a store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// ValidateName checks that a collection name is safe to use as a directory name
+func ValidateName(name string) error {
+	if !collectionNameRE.MatchString(name) {
+		return fmt.Errorf("invalid collection name %q (use letters, digits, '.', '_' or '-')", name)
+	}
+	return nil
+}
+
+func (s *Store) collectionDir(name string) string {
+	return filepath.Join(s.Dir, name)
+}
+
+// Info returns the metadata of a collection
+func (s *Store) Info(name string) (*CollectionInfo, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(s.collectionDir(name), collectionInfoFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("collection %q not found in %s", name, s.Dir)
+		}
+		return nil, err
+	}
+	var info CollectionInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, fmt.Errorf("parse collection %q: %w", name, err)
+	}
+	return &info, nil
+}
+
+// List returns metadata for every collection in the store, sorted by name
+func (s *Store) List() ([]*CollectionInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var infos []*CollectionInfo
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := s.Info(e.Name())
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// Load returns every item of a collection together with its metadata
+func (s *Store) Load(name string) ([]Item, *CollectionInfo, error) {
+	info, err := s.Info(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := ReadItems(filepath.Join(s.collectionDir(name), collectionItemsFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, info, nil
+}
+
+// LoadLazy is Load with compressed content left compressed, as ReadItemsLazy leaves it
+func (s *Store) LoadLazy(name string) ([]Item, *CollectionInfo, error) {
+	info, err := s.Info(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := ReadItemsLazy(filepath.Join(s.collectionDir(name), collectionItemsFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, info, nil
+}
+
+// Upsert adds items to a collection (creating it if needed), replacing items with the same ID.
+// It refuses to mix embedding models within one collection.
+func (s *Store) Upsert(name, embeddingModel string, items []Item, encrypt bool) (*CollectionInfo, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	info, err := s.Info(name)
+	var existing []Item
+	if err != nil {
+		info = &CollectionInfo{Name: name, EmbeddingModel: embeddingModel, CreatedAt: now}
+	} else {
+		if info.EmbeddingModel != "" && embeddingModel != "" && info.EmbeddingModel != embeddingModel {
+			return nil, fmt.Errorf("collection %q was built with embedding model %s, not %s",
+				name, info.EmbeddingModel, embeddingModel)
+		}
+		existing, _, err = s.Load(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	merged := mergeItems(existing, items)
+
+	dim := info.Dimension
+	for _, it := range merged {
+		if len(it.Embedding) > 0 {
+			if dim != 0 && dim != len(it.Embedding) {
+				return nil, fmt.Errorf("collection %q has dimension %d but item %s has %d",
+					name, dim, it.ID, len(it.Embedding))
+			}
+			dim = len(it.Embedding)
+		}
+	}
+
+	info.Dimension = dim
+	info.Count = len(merged)
+	info.UpdatedAt = now
+	if info.EmbeddingModel == "" {
+		info.EmbeddingModel = embeddingModel
+	}
+
+	if err := os.MkdirAll(s.collectionDir(name), 0o755); err != nil {
+		return nil, err
+	}
+	if err := WriteItems(filepath.Join(s.collectionDir(name), collectionItemsFile), merged, encrypt); err != nil {
+		return nil, err
+	}
+	ib, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(s.collectionDir(name), collectionInfoFile), ib, 0o644, false); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Delete removes a collection and all of its data
+func (s *Store) Delete(name string) error {
+	if _, err := s.Info(name); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.collectionDir(name))
+}
+
+// mergeItems replaces existing items by ID and appends new ones, preserving order
+func mergeItems(existing, updates []Item) []Item {
+	pos := make(map[string]int, len(existing))
+	merged := make([]Item, len(existing), len(existing)+len(updates))
+	copy(merged, existing)
+	for i, it := range merged {
+		pos[it.ID] = i
+	}
+	for _, it := range updates {
+		if i, ok := pos[it.ID]; ok && it.ID != "" {
+			merged[i] = it
+			continue
+		}
+		pos[it.ID] = len(merged)
+		merged = append(merged, it)
+	}
+	return merged
+}
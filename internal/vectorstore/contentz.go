@@ -0,0 +1,211 @@
+package vectorstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/secure"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// maxDictSize caps the trained dictionary; zstd gains little from larger ones
+	maxDictSize = 110 << 10
+	// maxDictSamples caps how much content the dictionary is trained on
+	maxDictSamples = 64 << 20
+)
+
+// DictPath returns where the zstd dictionary for the content of an embeddings file lives
+func DictPath(path string) string {
+	return path + ".dict"
+}
+
+// contentDict compresses and decompresses chunk content with a dictionary trained on the
+// corpus, which shrinks short chunks far more than compressing each one on its own
+type contentDict struct {
+	id  uint32 // recorded in the frame of everything compressed with the dictionary
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+func newContentDict(raw []byte) (*contentDict, error) {
+	info, err := zstd.InspectDictionary(raw)
+	if err != nil {
+		return nil, fmt.Errorf("load content dictionary: %w", err)
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderDict(raw), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("load content dictionary: %w", err)
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(raw), zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		return nil, fmt.Errorf("load content dictionary: %w", err)
+	}
+	return &contentDict{id: info.ID(), enc: enc, dec: dec}, nil
+}
+
+// readDict loads the content dictionary of an embeddings file, or nil when it has none
+func readDict(path string) (*contentDict, error) {
+	raw, err := secure.ReadFile(DictPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return newContentDict(raw)
+}
+
+// attachDict gives items with compressed content the dictionary to read it with. Content
+// compressed with another dictionary, such as one a later compress run replaced, is an
+// error rather than text that cannot be read.
+func attachDict(path string, items []Item) error {
+	compressed := false
+	for _, it := range items {
+		if it.ContentZ != nil {
+			compressed = true
+			break
+		}
+	}
+	if !compressed {
+		return nil
+	}
+	d, err := readDict(path)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return fmt.Errorf("%s has compressed content but no dictionary at %s", path, DictPath(path))
+	}
+	for i := range items {
+		if items[i].ContentZ == nil {
+			continue
+		}
+		var h zstd.Header
+		if err := h.Decode(items[i].ContentZ); err != nil {
+			return fmt.Errorf("%s: compressed content of item %s: %w", path, items[i].ID, err)
+		}
+		if h.DictionaryID != d.id {
+			return fmt.Errorf("%s: item %s was compressed with another dictionary than %s", path, items[i].ID, DictPath(path))
+		}
+		items[i].dict = d
+	}
+	return nil
+}
+
+// Text returns the item's content, decompressing it when it is stored compressed. Content
+// that cannot be decompressed reads as a note saying why, never as empty, so the loss shows
+// wherever the text does.
+func (it Item) Text() string {
+	if it.ContentZ == nil {
+		return it.Content
+	}
+	if it.dict == nil {
+		return fmt.Sprintf("[compressed content of %s unreadable: no dictionary]", it.ID)
+	}
+	b, err := it.dict.dec.DecodeAll(it.ContentZ, nil)
+	if err != nil {
+		return fmt.Sprintf("[compressed content of %s unreadable: %v]", it.ID, err)
+	}
+	return string(b)
+}
+
+// inflate replaces compressed content with the text it holds
+func (it *Item) inflate() error {
+	if it.ContentZ == nil {
+		return nil
+	}
+	if it.dict == nil {
+		return fmt.Errorf("item %s has compressed content but no dictionary", it.ID)
+	}
+	b, err := it.dict.dec.DecodeAll(it.ContentZ, nil)
+	if err != nil {
+		return fmt.Errorf("decompress content of item %s: %w", it.ID, err)
+	}
+	it.Content, it.ContentZ, it.dict = string(b), nil, nil
+	return nil
+}
+
+// compressWithDict returns items with their content compressed by the dictionary of the
+// file at path, or items unchanged when the file has none
+func compressWithDict(path string, items []Item) ([]Item, error) {
+	d, err := readDict(path)
+	if err != nil || d == nil {
+		return items, err
+	}
+	out := make([]Item, len(items))
+	for i, it := range items {
+		text := it.Text()
+		it.Content, it.ContentZ, it.dict = "", nil, nil
+		if text != "" {
+			it.ContentZ = d.enc.EncodeAll([]byte(text), nil)
+		}
+		out[i] = it
+	}
+	return out, nil
+}
+
+// TrainDict builds a zstd dictionary from the content of items
+func TrainDict(items []Item) ([]byte, error) {
+	var samples [][]byte
+	total := 0
+	for _, it := range items {
+		text := it.Text()
+		if text == "" {
+			continue
+		}
+		if total += len(text); total > maxDictSamples {
+			break
+		}
+		samples = append(samples, []byte(text))
+	}
+	if len(samples) < 2 {
+		return nil, errors.New("too little content to train a dictionary")
+	}
+	raw, err := dict.BuildZstdDict(samples, dict.Options{MaxDictSize: maxDictSize, HashBytes: 6})
+	if err != nil {
+		return nil, fmt.Errorf("train content dictionary: %w", err)
+	}
+	return raw, nil
+}
+
+// CompressContent trains a dictionary on the content of the embeddings file at path, saves
+// it at DictPath(path), and rewrites the file with each item's content compressed by it.
+// Later writes to the file keep compressing with the dictionary until DecompressContent.
+func CompressContent(path string, encrypt bool) error {
+	items, err := ReadItems(path)
+	if err != nil {
+		return err
+	}
+	raw, err := TrainDict(items)
+	if err != nil {
+		return err
+	}
+	if raw, err = secure.Seal(raw, encrypt); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(DictPath(path), raw, 0644, true); err != nil {
+		return err
+	}
+	return WriteItems(path, items, encrypt)
+}
+
+// DecompressContent rewrites the embeddings file at path with its content in plain text and
+// removes its dictionary
+func DecompressContent(path string, encrypt bool) error {
+	items, err := ReadItems(path)
+	if err != nil {
+		return err
+	}
+	if err := writeItems(path, items, encrypt); err != nil {
+		return err
+	}
+	if err := os.Remove(DictPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
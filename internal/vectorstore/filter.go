@@ -0,0 +1,151 @@
+package vectorstore
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is a set of conditions on chunk metadata; an item passes when it meets all of them
+type Filter []Condition
+
+// Condition compares one field of an item with a value. Field is a metadata key, a dotted
+// path into nested metadata (provenance.crawler), or id, content, or chunk_index.
+type Condition struct {
+	Field string
+	Op    string // =, !=, ~ (contains), !~, >, >=, <, <=
+	Value string
+}
+
+var conditionRE = regexp.MustCompile(`^\s*([A-Za-z0-9_.-]+)\s*(!=|!~|>=|<=|=|~|>|<)\s*(.*?)\s*$`)
+
+// ParseFilter parses conditions such as source_url~"tpusa.com/events" or
+// crawled_at>2024-01-01. Values may be quoted.
+func ParseFilter(exprs []string) (Filter, error) {
+	var f Filter
+	for _, expr := range exprs {
+		m := conditionRE.FindStringSubmatch(expr)
+		if m == nil {
+			return nil, fmt.Errorf("invalid filter %q (use field=value, field~text, or field>value)", expr)
+		}
+		value := m[3]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		f = append(f, Condition{Field: m[1], Op: m[2], Value: value})
+	}
+	return f, nil
+}
+
+// Match reports whether it meets every condition
+func (f Filter) Match(it Item) bool {
+	for _, c := range f {
+		if !c.Match(it) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the items that pass the filter; an empty filter returns items unchanged
+func (f Filter) Apply(items []Item) []Item {
+	if len(f) == 0 {
+		return items
+	}
+	out := make([]Item, 0, len(items))
+	for _, it := range items {
+		if f.Match(it) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// Match reports whether it meets the condition. A missing field fails every comparison
+// except != and !~.
+func (c Condition) Match(it Item) bool {
+	field, ok := fieldValue(it, c.Field)
+	if !ok {
+		return c.Op == "!=" || c.Op == "!~"
+	}
+	switch c.Op {
+	case "~":
+		return strings.Contains(strings.ToLower(field), strings.ToLower(c.Value))
+	case "!~":
+		return !strings.Contains(strings.ToLower(field), strings.ToLower(c.Value))
+	case "=":
+		return compare(field, c.Value) == 0
+	case "!=":
+		return compare(field, c.Value) != 0
+	case ">":
+		return compare(field, c.Value) > 0
+	case ">=":
+		return compare(field, c.Value) >= 0
+	case "<":
+		return compare(field, c.Value) < 0
+	case "<=":
+		return compare(field, c.Value) <= 0
+	}
+	return false
+}
+
+// fieldValue returns the named field of it as text
+func fieldValue(it Item, name string) (string, bool) {
+	switch name {
+	case "id":
+		return it.ID, true
+	case "content":
+		return it.Text(), true
+	case "chunk_index":
+		return strconv.Itoa(it.ChunkIndex), true
+	}
+	var v interface{} = it.Metadata
+	for _, key := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = m[key]; !ok || v == nil {
+			return "", false
+		}
+	}
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64), true
+	}
+	return fmt.Sprint(v), true
+}
+
+var filterTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// compare orders a field value against a filter value: as numbers when both are numbers,
+// as times when both are dates, and as text otherwise
+func compare(field, value string) int {
+	if a, err := strconv.ParseFloat(field, 64); err == nil {
+		if b, err := strconv.ParseFloat(value, 64); err == nil {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	}
+	if a, ok := parseFilterTime(field); ok {
+		if b, ok := parseFilterTime(value); ok {
+			return a.Compare(b)
+		}
+	}
+	return strings.Compare(field, value)
+}
+
+func parseFilterTime(s string) (time.Time, bool) {
+	for _, layout := range filterTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,442 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+const collectionANNFile = "index.hnsw"
+
+// Default HNSW parameters
+const (
+	DefaultHNSWM              = 16
+	DefaultHNSWEfConstruction = 100
+	DefaultHNSWEfSearch       = 64
+)
+
+// HNSWParams tune an HNSW graph: M is the number of links per node (twice that on the base
+// layer), EfConstruction the candidate list size while building, and EfSearch the minimum
+// candidate list size while searching. Larger values trade speed for recall.
+type HNSWParams struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+}
+
+// DefaultHNSWParams returns parameters that reach high recall on typical corpora
+func DefaultHNSWParams() HNSWParams {
+	return HNSWParams{M: DefaultHNSWM, EfConstruction: DefaultHNSWEfConstruction, EfSearch: DefaultHNSWEfSearch}
+}
+
+// HNSW is an approximate nearest-neighbor index over the items of an embeddings file or
+// collection. Only the graph is persisted; vectors come from the items it was built from,
+// which Attach checks against the fingerprint recorded at build time. An attached index may be
+// searched by several goroutines at once.
+type HNSW struct {
+	Params      HNSWParams
+	Fingerprint string
+	Entry       int
+	MaxLevel    int
+	Links       [][][]int32 // Links[node][level] are the node's neighbors on that level
+
+	items   []Item
+	vectors [][]float64 // unit-length copies of the item embeddings
+	marks   sync.Pool   // *visitMarks, one per search in flight
+}
+
+// visitMarks are the nodes one search has visited: a node is visited when its mark equals
+// epoch, so the next search only bumps epoch instead of clearing the marks
+type visitMarks struct {
+	seen  []uint32
+	epoch uint32
+}
+
+// Fingerprint identifies a set of items by count, dimension, and, in order, each item's ID,
+// content hash, and embedding, so an index built from them can tell when the file has
+// changed since, including chunks re-embedded or rewritten under the same IDs
+func Fingerprint(items []Item) string {
+	h := sha256.New()
+	dim := 0
+	if len(items) > 0 {
+		dim = len(items[0].Embedding)
+	}
+	fmt.Fprintf(h, "%d:%d\n", len(items), dim)
+	var buf [8]byte
+	for _, it := range items {
+		fmt.Fprintf(h, "%s\x00%d\x00%s\n", it.ID, it.ChunkIndex, contentHash(it))
+		for _, x := range it.Embedding {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(x))
+			h.Write(buf[:])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contentHash returns the content hash recorded in an item's metadata, or hashes its text
+// when none was recorded
+func contentHash(it Item) string {
+	if hash, ok := it.Metadata["content_hash"].(string); ok && hash != "" {
+		return hash
+	}
+	sum := sha256.Sum256([]byte(it.Text()))
+	return hex.EncodeToString(sum[:])
+}
+
+// BuildHNSW indexes items, which must all be searchable and share one dimension
+func BuildHNSW(items []Item, params HNSWParams) (*HNSW, error) {
+	if params.M < 2 {
+		params.M = DefaultHNSWM
+	}
+	if params.EfConstruction < params.M {
+		params.EfConstruction = DefaultHNSWEfConstruction
+	}
+	if params.EfSearch <= 0 {
+		params.EfSearch = DefaultHNSWEfSearch
+	}
+	h := &HNSW{Params: params, Fingerprint: Fingerprint(items), Entry: -1}
+	if err := h.setItems(items); err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	levelMult := 1 / math.Log(float64(params.M))
+	h.Links = make([][][]int32, len(items))
+	for i := range items {
+		level := int(-math.Log(1-rng.Float64()) * levelMult)
+		h.insert(i, level)
+	}
+	return h, nil
+}
+
+// setItems keeps the items and their normalized vectors for searching
+func (h *HNSW) setItems(items []Item) error {
+	h.items = items
+	h.vectors = make([][]float64, len(items))
+	for i, it := range items {
+		if len(it.Embedding) == 0 {
+			return fmt.Errorf("item %q has no embedding", it.ID)
+		}
+		if len(it.Embedding) != len(items[0].Embedding) {
+			return fmt.Errorf("item %q has dimension %d, expected %d", it.ID, len(it.Embedding), len(items[0].Embedding))
+		}
+		var norm float64
+		for _, x := range it.Embedding {
+			norm += x * x
+		}
+		norm = math.Sqrt(norm)
+		v := make([]float64, len(it.Embedding))
+		if norm > 0 {
+			for j, x := range it.Embedding {
+				v[j] = x / norm
+			}
+		}
+		h.vectors[i] = v
+	}
+	return nil
+}
+
+// Attach binds a loaded index to the items it was built from, failing when they differ
+func (h *HNSW) Attach(items []Item) error {
+	if Fingerprint(items) != h.Fingerprint || len(items) != len(h.Links) {
+		return fmt.Errorf("index is stale: the embeddings changed since it was built")
+	}
+	return h.setItems(items)
+}
+
+// Len returns the number of indexed items
+func (h *HNSW) Len() int {
+	return len(h.Links)
+}
+
+func (h *HNSW) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.Params.M
+	}
+	return h.Params.M
+}
+
+func dot(a, b []float64) float64 {
+	var s float64
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+func (h *HNSW) insert(node, level int) {
+	h.Links[node] = make([][]int32, level+1)
+	if h.Entry < 0 {
+		h.Entry, h.MaxLevel = node, level
+		return
+	}
+	q := h.vectors[node]
+	ep := h.Entry
+	for l := h.MaxLevel; l > level; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	entries := []int{ep}
+	for l := min(level, h.MaxLevel); l >= 0; l-- {
+		found := h.searchLayer(q, entries, h.Params.EfConstruction, l)
+		for _, n := range h.selectNeighbors(found, h.Params.M) {
+			h.Links[node][l] = append(h.Links[node][l], int32(n))
+			h.link(n, node, l)
+		}
+		entries = entries[:0]
+		for _, n := range found {
+			entries = append(entries, n.id)
+		}
+	}
+	if level > h.MaxLevel {
+		h.Entry, h.MaxLevel = node, level
+	}
+}
+
+// link adds to -> from on level, re-selecting from's neighbors when the list is full
+func (h *HNSW) link(from, to, level int) {
+	links := append(h.Links[from][level], int32(to))
+	if len(links) > h.maxLinks(level) {
+		v := h.vectors[from]
+		cands := make([]scored, len(links))
+		for i, n := range links {
+			cands[i] = scored{id: int(n), sim: dot(v, h.vectors[n])}
+		}
+		sortScored(cands)
+		links = links[:0]
+		for _, n := range h.selectNeighbors(cands, h.maxLinks(level)) {
+			links = append(links, int32(n))
+		}
+	}
+	h.Links[from][level] = links
+}
+
+// selectNeighbors picks up to m of the candidates (most similar first), preferring ones
+// closer to the new node than to any neighbor already picked so links spread across
+// clusters instead of piling into the nearest one; the rest of the slots are filled with the
+// closest skipped candidates
+func (h *HNSW) selectNeighbors(cands []scored, m int) []int {
+	picked := make([]int, 0, m)
+	var skipped []int
+	for _, c := range cands {
+		if len(picked) == m {
+			break
+		}
+		diverse := true
+		for _, p := range picked {
+			if dot(h.vectors[c.id], h.vectors[p]) > c.sim {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			picked = append(picked, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+	for _, id := range skipped {
+		if len(picked) == m {
+			break
+		}
+		picked = append(picked, id)
+	}
+	return picked
+}
+
+// greedy walks level from ep towards q and returns the closest node found
+func (h *HNSW) greedy(q []float64, ep, level int) int {
+	best := dot(q, h.vectors[ep])
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.Links[ep][level] {
+			if s := dot(q, h.vectors[n]); s > best {
+				best, ep, changed = s, int(n), true
+			}
+		}
+	}
+	return ep
+}
+
+// visitMarks returns marks for one search, reusing those of a finished search when it can
+func (h *HNSW) visitMarks() *visitMarks {
+	v, _ := h.marks.Get().(*visitMarks)
+	if v == nil || len(v.seen) != len(h.vectors) {
+		v = &visitMarks{seen: make([]uint32, len(h.vectors))}
+	}
+	v.epoch++
+	if v.epoch == 0 {
+		clear(v.seen)
+		v.epoch = 1
+	}
+	return v
+}
+
+// searchLayer returns up to ef nodes of level closest to q, most similar first
+func (h *HNSW) searchLayer(q []float64, entries []int, ef, level int) []scored {
+	v := h.visitMarks()
+	defer h.marks.Put(v)
+	candidates := &maxHeap{}
+	results := &minHeap{}
+	for _, e := range entries {
+		if v.seen[e] == v.epoch {
+			continue
+		}
+		v.seen[e] = v.epoch
+		s := scored{id: e, sim: dot(q, h.vectors[e])}
+		heap.Push(candidates, s)
+		heap.Push(results, s)
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(scored)
+		if results.Len() >= ef && c.sim < (*results)[0].sim {
+			break
+		}
+		if level >= len(h.Links[c.id]) {
+			continue
+		}
+		for _, n := range h.Links[c.id][level] {
+			id := int(n)
+			if v.seen[id] == v.epoch {
+				continue
+			}
+			v.seen[id] = v.epoch
+			s := scored{id: id, sim: dot(q, h.vectors[id])}
+			if results.Len() < ef || s.sim > (*results)[0].sim {
+				heap.Push(candidates, s)
+				heap.Push(results, s)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	out := make([]scored, results.Len())
+	copy(out, *results)
+	sortScored(out)
+	return out
+}
+
+// Search returns up to topK deduplicated results at or above threshold, most similar first.
+// It explores max(topK, EfSearch) candidates; topK must be positive.
+func (h *HNSW) Search(queryEmbedding []float64, topK int, threshold float64) []SearchResult {
+	if h.Entry < 0 || topK <= 0 {
+		return nil
+	}
+	var norm float64
+	for _, x := range queryEmbedding {
+		norm += x * x
+	}
+	if norm == 0 || len(queryEmbedding) != len(h.vectors[0]) {
+		return nil
+	}
+	q := make([]float64, len(queryEmbedding))
+	for i, x := range queryEmbedding {
+		q[i] = x / math.Sqrt(norm)
+	}
+
+	ep := h.Entry
+	for l := h.MaxLevel; l > 0; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	ef := h.Params.EfSearch
+	if topK > ef {
+		ef = topK
+	}
+	candidates := make([]SearchResult, 0, ef)
+	for _, s := range h.searchLayer(q, []int{ep}, ef, 0) {
+		if s.sim >= threshold {
+			candidates = append(candidates, SearchResult{Item: h.items[s.id], Similarity: s.sim})
+		}
+	}
+	return rank(candidates, topK)
+}
+
+// ANNPath returns the HNSW index file kept next to an embeddings file
+func ANNPath(embeddingsPath string) string {
+	return embeddingsPath + ".hnsw"
+}
+
+// ANNPath returns the HNSW index file of a collection
+func (s *Store) ANNPath(name string) string {
+	return filepath.Join(s.collectionDir(name), collectionANNFile)
+}
+
+// WriteHNSW atomically writes the index graph to path
+func WriteHNSW(path string, h *HNSW) error {
+	f, err := atomicfile.Create(path, 0o644, false)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if err := gob.NewEncoder(f).Encode(h); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// ReadHNSW loads an index graph written by WriteHNSW; a missing file returns nil without
+// error. The index must be attached to its items before searching.
+func ReadHNSW(path string) (*HNSW, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var h HNSW
+	if err := gob.NewDecoder(f).Decode(&h); err != nil {
+		return nil, fmt.Errorf("parse index %s: %w", path, err)
+	}
+	return &h, nil
+}
+
+// scored is a node and its similarity to the query
+type scored struct {
+	id  int
+	sim float64
+}
+
+func sortScored(s []scored) {
+	sort.Slice(s, func(i, j int) bool { return s[i].sim > s[j].sim })
+}
+
+// maxHeap pops the most similar node first
+type maxHeap []scored
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i].sim > h[j].sim }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(scored)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// minHeap pops the least similar node first
+type minHeap []scored
+
+func (h minHeap) Len() int            { return len(h) }
+func (h minHeap) Less(i, j int) bool  { return h[i].sim < h[j].sim }
+func (h minHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x interface{}) { *h = append(*h, x.(scored)) }
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
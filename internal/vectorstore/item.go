@@ -0,0 +1,103 @@
+package vectorstore
+
+import (
+	"fmt"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/secure"
+)
+
+// Item is a single embedded chunk as stored in an embeddings file or collection
+type Item struct {
+	ID         string                 `json:"id"`
+	ChunkIndex int                    `json:"chunk_index"`
+	Content    string                 `json:"content,omitempty"`   // Store original content
+	ContentZ   []byte                 `json:"content_z,omitempty"` // Content compressed with the file's dictionary; see Text
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`  // Store metadata
+	Embedding  []float64              `json:"embedding,omitempty"`
+	Error      *ItemError             `json:"error,omitempty"`      // why the chunk has no embedding
+	Tombstoned bool                   `json:"tombstoned,omitempty"` // source page was removed
+
+	dict *contentDict // decompresses ContentZ of items read by ReadItemsLazy
+}
+
+// Searchable reports whether the item has a usable embedding
+func (it Item) Searchable() bool {
+	return it.Error == nil && !it.Tombstoned && len(it.Embedding) > 0
+}
+
+// ReuseEmbedding gives it the vector of prev, an earlier item with the same content. Reused
+// vectors keep the embedding provenance they were created with; model is recorded for prev
+// items that predate embedding provenance.
+func (it *Item) ReuseEmbedding(prev Item, model string) {
+	was := provenance.Get(prev.Metadata)
+	if was.EmbeddingModel == "" {
+		was.EmbeddingModel = model
+	}
+	if it.Metadata == nil {
+		it.Metadata = map[string]interface{}{}
+	}
+	it.Embedding = prev.Embedding
+	provenance.Update(it.Metadata, provenance.Record{EmbeddingModel: was.EmbeddingModel, EmbeddedAt: was.EmbeddedAt})
+}
+
+// ReadItems loads every item from an embeddings file or shard manifest, decrypting and
+// decompressing it if needed
+func ReadItems(path string) ([]Item, error) {
+	if IsShardManifest(path) {
+		m, err := ReadShardManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		return m.ReadAll()
+	}
+	items, err := ReadItemsLazy(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if err := items[i].inflate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return items, nil
+}
+
+// ReadItemsLazy is ReadItems for a file, leaving compressed content compressed until Text
+// reads it or the item is returned as a search result
+func ReadItemsLazy(path string) ([]Item, error) {
+	data, err := secure.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	items, err := ParseItems(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return items, attachDict(path, items)
+}
+
+// WriteItems atomically writes items to an embeddings file, optionally encrypting it, and
+// keeps the version it replaces at path.bak. A .gz or .zst suffix compresses the output and
+// .jsonl writes one item per line. When the file has a content dictionary (see
+// CompressContent), the content of every item is compressed with it.
+func WriteItems(path string, items []Item, encrypt bool) error {
+	items, err := compressWithDict(path, items)
+	if err != nil {
+		return err
+	}
+	return writeItems(path, items, encrypt)
+}
+
+// writeItems is WriteItems with the content written as the items hold it
+func writeItems(path string, items []Item, encrypt bool) error {
+	data, err := encodeItems(path, items)
+	if err != nil {
+		return err
+	}
+	if data, err = secure.Seal(data, encrypt); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644, true)
+}
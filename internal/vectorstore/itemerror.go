@@ -0,0 +1,126 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	apierrors "kirk-ai/internal/errors"
+)
+
+// ErrorKind is the category of an embedding failure
+type ErrorKind string
+
+const (
+	KindTimeout     ErrorKind = "timeout"      // the request timed out
+	KindRateLimited ErrorKind = "rate_limited" // the server answered 429 Too Many Requests
+	KindTooLong     ErrorKind = "too_long"     // the chunk does not fit the model's context
+	KindModel       ErrorKind = "model_error"  // the server rejected or failed the request
+	KindNoContent   ErrorKind = "no_content"   // there was no stored text to embed
+	KindOther       ErrorKind = "other"        // anything else, such as a refused connection
+)
+
+// ItemError records why a chunk has no embedding. A failed chunk is still written to the
+// output, with its content and metadata but no embedding, so a later run can retry it and
+// readers can tell a partial corpus from a complete one.
+type ItemError struct {
+	Kind    ErrorKind `json:"kind"`
+	Message string    `json:"message,omitempty"`
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// UnmarshalJSON also accepts the bare message string written before errors had kinds; its
+// kind is inferred from the message
+func (e *ItemError) UnmarshalJSON(b []byte) error {
+	var msg string
+	if err := json.Unmarshal(b, &msg); err == nil {
+		*e = ItemError{Kind: kindOfMessage(msg), Message: msg}
+		return nil
+	}
+	type plain ItemError
+	return json.Unmarshal(b, (*plain)(e))
+}
+
+// NewItemError categorizes an embedding request's failure
+func NewItemError(err error) *ItemError {
+	msg := err.Error()
+	var apiErr *apierrors.APIError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return &ItemError{Kind: KindTimeout, Message: msg}
+	case errors.As(err, &apiErr) && apiErr.StatusCode == 429:
+		return &ItemError{Kind: KindRateLimited, Message: msg}
+	case errors.As(err, &apiErr) && tooLong(apiErr.Message):
+		return &ItemError{Kind: KindTooLong, Message: msg}
+	case errors.As(err, &apiErr):
+		return &ItemError{Kind: KindModel, Message: msg}
+	}
+	return &ItemError{Kind: kindOfMessage(msg), Message: msg}
+}
+
+// kindOfMessage infers the kind of a failure from its message alone
+func kindOfMessage(msg string) ErrorKind {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "timeout") || strings.Contains(lower, "deadline exceeded"):
+		return KindTimeout
+	case strings.Contains(lower, "status 429"):
+		return KindRateLimited
+	case tooLong(lower):
+		return KindTooLong
+	case strings.Contains(lower, "api request failed"):
+		return KindModel
+	case strings.Contains(lower, "no stored content"):
+		return KindNoContent
+	}
+	return KindOther
+}
+
+// tooLong reports whether a server's error message says the input exceeded the context
+func tooLong(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, marker := range []string{"context length", "too long", "input length", "maximum context", "too many tokens"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorCounts counts the failed items by kind
+func ErrorCounts(items []Item) map[ErrorKind]int {
+	counts := map[ErrorKind]int{}
+	for _, it := range items {
+		if it.Error != nil {
+			counts[it.Error.Kind]++
+		}
+	}
+	return counts
+}
+
+// FormatErrorCounts renders counts as "3 timeout, 1 too_long", most frequent first
+func FormatErrorCounts(counts map[ErrorKind]int) string {
+	kinds := make([]ErrorKind, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", counts[k], k)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,277 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// QdrantScheme prefixes --out and --store values that address a Qdrant server
+const QdrantScheme = "qdrant://"
+
+// DefaultQdrantURL is the Qdrant server used when a qdrant:// address names only a collection
+const DefaultQdrantURL = "http://localhost:6333"
+
+// Environment variables configuring Qdrant access
+const (
+	QdrantURLEnvVar    = "QDRANT_URL"
+	QdrantAPIKeyEnvVar = "QDRANT_API_KEY"
+)
+
+// qdrantUpsertBatch is the number of points sent per upsert request
+const qdrantUpsertBatch = 256
+
+// IsQdrant reports whether s is a qdrant:// address
+func IsQdrant(s string) bool {
+	return strings.HasPrefix(s, QdrantScheme)
+}
+
+// ParseQdrant splits a qdrant:// address into the server URL and collection name:
+//
+//	qdrant://docs                  -> $QDRANT_URL (or localhost:6333), collection "docs"
+//	qdrant://host:6333             -> http://host:6333, no collection
+//	qdrant://host:6333/docs        -> http://host:6333, collection "docs"
+//
+// A single segment is a server when it contains a port or a dot, or is "localhost".
+func ParseQdrant(s string) (server, collection string, err error) {
+	rest := strings.Trim(strings.TrimPrefix(s, QdrantScheme), "/")
+	if rest == "" {
+		return "", "", fmt.Errorf("empty qdrant address %q", s)
+	}
+	host, path, hasPath := strings.Cut(rest, "/")
+	if !hasPath && !strings.ContainsAny(host, ":.") && host != "localhost" {
+		host, path = "", host
+	}
+	if path != "" {
+		if err := ValidateName(path); err != nil {
+			return "", "", err
+		}
+	}
+	if host == "" {
+		server = os.Getenv(QdrantURLEnvVar)
+		if server == "" {
+			server = DefaultQdrantURL
+		}
+		return strings.TrimRight(server, "/"), path, nil
+	}
+	return "http://" + host, path, nil
+}
+
+// Qdrant is a collection on a Qdrant server. Items are stored as points whose payload
+// holds the item (without its vector) and the embedding model.
+type Qdrant struct {
+	URL        string
+	Collection string
+	APIKey     string
+	HTTP       *http.Client
+}
+
+// NewQdrant returns a client for collection on the server at url, authenticating with
+// $QDRANT_API_KEY when set
+func NewQdrant(url, collection string) *Qdrant {
+	return &Qdrant{
+		URL:        strings.TrimRight(url, "/"),
+		Collection: collection,
+		APIKey:     os.Getenv(QdrantAPIKeyEnvVar),
+		HTTP:       &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// qdrantPayload is the payload stored with every point
+type qdrantPayload struct {
+	Item
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+}
+
+// qdrantPointID derives a stable UUID from an item ID, since Qdrant only accepts
+// integers and UUIDs as point IDs
+func qdrantPointID(it Item) string {
+	sum := sha256.Sum256([]byte(DedupKey(it)))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // version 5 layout
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func (q *Qdrant) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, q.URL+path, r)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.APIKey != "" {
+		req.Header.Set("api-key", q.APIKey)
+	}
+	resp, err := q.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("qdrant %s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("qdrant %s %s: parse response: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Count returns the number of points in the collection
+func (q *Qdrant) Count(ctx context.Context) (int, error) {
+	var resp struct {
+		Result struct {
+			PointsCount int `json:"points_count"`
+		} `json:"result"`
+	}
+	status, err := q.do(ctx, http.MethodGet, "/collections/"+q.Collection, nil, &resp)
+	if status == http.StatusNotFound {
+		return 0, fmt.Errorf("qdrant collection %q not found at %s", q.Collection, q.URL)
+	}
+	return resp.Result.PointsCount, err
+}
+
+// Dimension returns the vector size the collection was created with
+func (q *Qdrant) Dimension(ctx context.Context) (int, error) {
+	var resp struct {
+		Result struct {
+			Config struct {
+				Params struct {
+					Vectors struct {
+						Size int `json:"size"`
+					} `json:"vectors"`
+				} `json:"params"`
+			} `json:"config"`
+		} `json:"result"`
+	}
+	status, err := q.do(ctx, http.MethodGet, "/collections/"+q.Collection, nil, &resp)
+	if status == http.StatusNotFound {
+		return 0, fmt.Errorf("qdrant collection %q not found at %s", q.Collection, q.URL)
+	}
+	return resp.Result.Config.Params.Vectors.Size, err
+}
+
+// ensureCollection creates the collection with cosine distance when it does not exist yet
+func (q *Qdrant) ensureCollection(ctx context.Context, dimension int) error {
+	status, err := q.do(ctx, http.MethodGet, "/collections/"+q.Collection, nil, nil)
+	if err == nil {
+		return nil
+	}
+	if status != http.StatusNotFound {
+		return err
+	}
+	create := map[string]interface{}{
+		"vectors": map[string]interface{}{"size": dimension, "distance": "Cosine"},
+	}
+	_, err = q.do(ctx, http.MethodPut, "/collections/"+q.Collection, create, nil)
+	return err
+}
+
+// Upsert writes items as points, creating the collection on first use. Items without a
+// usable embedding are skipped. It refuses to mix embedding models within one collection.
+func (q *Qdrant) Upsert(ctx context.Context, embeddingModel string, items []Item) error {
+	type point struct {
+		ID      string        `json:"id"`
+		Vector  []float64     `json:"vector"`
+		Payload qdrantPayload `json:"payload"`
+	}
+	points := make([]point, 0, len(items))
+	for _, it := range items {
+		if !it.Searchable() {
+			continue
+		}
+		payload := qdrantPayload{Item: it, EmbeddingModel: embeddingModel}
+		payload.Embedding = nil
+		points = append(points, point{ID: qdrantPointID(it), Vector: it.Embedding, Payload: payload})
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := q.ensureCollection(ctx, len(points[0].Vector)); err != nil {
+		return err
+	}
+	if existing, err := q.EmbeddingModel(ctx); err != nil {
+		return err
+	} else if existing != "" && embeddingModel != "" && existing != embeddingModel {
+		return fmt.Errorf("qdrant collection %q was built with %s, not %s", q.Collection, existing, embeddingModel)
+	}
+
+	for start := 0; start < len(points); start += qdrantUpsertBatch {
+		end := start + qdrantUpsertBatch
+		if end > len(points) {
+			end = len(points)
+		}
+		body := map[string]interface{}{"points": points[start:end]}
+		if _, err := q.do(ctx, http.MethodPut, "/collections/"+q.Collection+"/points?wait=true", body, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EmbeddingModel returns the model recorded in the collection's points ("" when empty or unknown)
+func (q *Qdrant) EmbeddingModel(ctx context.Context) (string, error) {
+	var resp struct {
+		Result struct {
+			Points []struct {
+				Payload qdrantPayload `json:"payload"`
+			} `json:"points"`
+		} `json:"result"`
+	}
+	body := map[string]interface{}{"limit": 1, "with_payload": true, "with_vector": false}
+	if _, err := q.do(ctx, http.MethodPost, "/collections/"+q.Collection+"/points/scroll", body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Result.Points) == 0 {
+		return "", nil
+	}
+	return resp.Result.Points[0].Payload.EmbeddingModel, nil
+}
+
+// Search returns up to topK points at or above threshold, most similar first. Results
+// carry the stored item without its vector. topK <= 0 returns up to 1000 results.
+func (q *Qdrant) Search(ctx context.Context, queryEmbedding []float64, topK int, threshold float64) ([]SearchResult, error) {
+	if topK <= 0 {
+		topK = 1000
+	}
+	var resp struct {
+		Result []struct {
+			Score   float64       `json:"score"`
+			Payload qdrantPayload `json:"payload"`
+		} `json:"result"`
+	}
+	body := map[string]interface{}{
+		"vector":          queryEmbedding,
+		"limit":           topK,
+		"score_threshold": threshold,
+		"with_payload":    true,
+	}
+	if _, err := q.do(ctx, http.MethodPost, "/collections/"+q.Collection+"/points/search", body, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(resp.Result))
+	for i, r := range resp.Result {
+		results[i] = SearchResult{Item: r.Payload.Item, Similarity: r.Score}
+	}
+	return results, nil
+}
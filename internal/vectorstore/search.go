@@ -0,0 +1,117 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SearchResult pairs an item with its similarity to the query
+type SearchResult struct {
+	Item       Item
+	Similarity float64
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors, or 0 when their dimensions differ
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dotProduct, normA, normB float64
+	for i := range a {
+		dotProduct += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Dimension returns the embedding dimension of the items that have an embedding, or 0 when
+// none do. Items of different dimensions come from different models and cannot be searched
+// together, so they are an error.
+func Dimension(items []Item) (int, error) {
+	dim := 0
+	for _, it := range items {
+		if len(it.Embedding) == 0 {
+			continue
+		}
+		if dim != 0 && len(it.Embedding) != dim {
+			return 0, fmt.Errorf("item %s has dimension %d but earlier items have %d; the embeddings mix models", it.ID, len(it.Embedding), dim)
+		}
+		dim = len(it.Embedding)
+	}
+	return dim, nil
+}
+
+// DedupKey returns the key used to collapse duplicate results: the ID, or a content prefix when the ID is empty
+func DedupKey(item Item) string {
+	key := item.ID
+	if key == "" {
+		// Fallback to content prefix for deduplication; include chunk index if content missing
+		key = item.Text()
+		if key == "" {
+			key = fmt.Sprintf("chunk_%d", item.ChunkIndex)
+		}
+		// Limit key length to avoid excessive map keys
+		if len(key) > 200 {
+			key = key[:200]
+		}
+	}
+	return key
+}
+
+// Search scores every item against the query embedding by brute force and returns up to
+// topK deduplicated results at or above threshold, most similar first (topK <= 0 means no limit).
+func Search(queryEmbedding []float64, items []Item, topK int, threshold float64) []SearchResult {
+	candidates := []SearchResult{}
+
+	for _, item := range items {
+		if len(item.Embedding) == 0 {
+			continue
+		}
+
+		similarity := CosineSimilarity(queryEmbedding, item.Embedding)
+		if similarity >= threshold {
+			candidates = append(candidates, SearchResult{Item: item, Similarity: similarity})
+		}
+	}
+
+	return rank(candidates, topK)
+}
+
+// rank sorts candidates by similarity and returns up to topK deduplicated results
+func rank(candidates []SearchResult, topK int) []SearchResult {
+	// Sort by similarity (descending)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Similarity > candidates[j].Similarity
+	})
+
+	// Deduplicate by ID or content prefix and limit to topK
+	seen := map[string]bool{}
+	out := make([]SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		if topK > 0 && len(out) >= topK {
+			break
+		}
+
+		key := DedupKey(c.Item)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		// Compressed content is only decompressed for the chunks that make the results
+		if c.Item.ContentZ != nil {
+			c.Item.Content = c.Item.Text()
+			c.Item.ContentZ, c.Item.dict = nil, nil
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
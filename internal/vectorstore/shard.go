@@ -0,0 +1,185 @@
+package vectorstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+	"kirk-ai/internal/secure"
+)
+
+// ShardFormat identifies a shard manifest file
+const ShardFormat = "kirk-ai-shards/v1"
+
+// ShardManifest lists the shard files that together make up one embeddings index
+type ShardManifest struct {
+	Format         string `json:"format"`
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+	Dimension      int    `json:"dimension,omitempty"`
+	Total          int    `json:"total"`
+	// Errors counts the items in Total that failed to embed, by kind
+	Errors    map[ErrorKind]int `json:"errors,omitempty"`
+	ShardSize int               `json:"shard_size"`
+	Shards    []Shard           `json:"shards"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	dir string
+}
+
+// Shard is one file of a sharded index; File is relative to the manifest
+type Shard struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+// shardName derives the name of shard i from the manifest path, keeping its extension so
+// compression and JSONL settings carry over (embeddings.json.gz -> embeddings-00003.json.gz)
+func shardName(manifestPath string, i int) string {
+	base := filepath.Base(manifestPath)
+	ext := ""
+	for _, e := range []string{".gz", ".zst", ".jsonl", ".json"} {
+		if strings.HasSuffix(base, e) {
+			base = strings.TrimSuffix(base, e)
+			ext = e + ext
+		}
+	}
+	if ext == "" {
+		ext = ".json"
+	}
+	return fmt.Sprintf("%s-%05d%s", base, i, ext)
+}
+
+// WriteShards splits items into files of at most shardSize items next to manifestPath and
+// writes a manifest listing them at manifestPath. The manifest itself is never encrypted.
+func WriteShards(manifestPath string, items []Item, shardSize int, model string, encrypt bool) (*ShardManifest, error) {
+	if shardSize <= 0 {
+		return nil, fmt.Errorf("shard size must be positive")
+	}
+
+	m := &ShardManifest{
+		Format:         ShardFormat,
+		EmbeddingModel: model,
+		Total:          len(items),
+		ShardSize:      shardSize,
+		CreatedAt:      time.Now().UTC(),
+		dir:            filepath.Dir(manifestPath),
+	}
+	if counts := ErrorCounts(items); len(counts) > 0 {
+		m.Errors = counts
+	}
+	for _, it := range items {
+		if it.Searchable() {
+			m.Dimension = len(it.Embedding)
+			break
+		}
+	}
+
+	for i := 0; i*shardSize < len(items); i++ {
+		end := (i + 1) * shardSize
+		if end > len(items) {
+			end = len(items)
+		}
+		name := shardName(manifestPath, i)
+		if err := WriteItems(filepath.Join(m.dir, name), items[i*shardSize:end], encrypt); err != nil {
+			return nil, err
+		}
+		m.Shards = append(m.Shards, Shard{File: name, Count: end - i*shardSize})
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(manifestPath, b, 0644, true); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// IsShardManifest reports whether path is a shard manifest. Only the start of the file is read,
+// so this is cheap even for large embeddings files.
+func IsShardManifest(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, 256)
+	n, _ := io.ReadFull(f, head)
+	head = bytes.TrimSpace(head[:n])
+	return bytes.HasPrefix(head, []byte("{")) && bytes.Contains(head, []byte(`"`+ShardFormat+`"`))
+}
+
+// ReadShardManifest loads a shard manifest
+func ReadShardManifest(path string) (*ShardManifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m ShardManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if m.Format != ShardFormat {
+		return nil, fmt.Errorf("%s is not a shard manifest", path)
+	}
+	m.dir = filepath.Dir(path)
+	return &m, nil
+}
+
+// Each loads the shards one at a time and calls fn with the items of each, so at most one
+// shard is held in memory
+func (m *ShardManifest) Each(fn func(items []Item) error) error {
+	for _, s := range m.Shards {
+		path := filepath.Join(m.dir, s.File)
+		data, err := secure.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		items, err := ParseItems(path, data)
+		if err != nil {
+			return err
+		}
+		if err := attachDict(path, items); err != nil {
+			return err
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAll loads every shard into a single slice
+func (m *ShardManifest) ReadAll() ([]Item, error) {
+	all := make([]Item, 0, m.Total)
+	err := m.Each(func(items []Item) error {
+		all = append(all, items...)
+		return nil
+	})
+	return all, err
+}
+
+// Search streams the shards, keeping only the running top results between shards. Only
+// items passing filter are compared.
+func (m *ShardManifest) Search(queryEmbedding []float64, topK int, threshold float64, filter Filter) ([]SearchResult, error) {
+	var best []SearchResult
+	err := m.Each(func(items []Item) error {
+		searchable := items[:0]
+		for _, it := range items {
+			if it.Searchable() && filter.Match(it) {
+				searchable = append(searchable, it)
+			}
+		}
+		best = rank(append(best, Search(queryEmbedding, searchable, topK, threshold)...), topK)
+		return nil
+	})
+	return best, err
+}
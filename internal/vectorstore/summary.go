@@ -0,0 +1,125 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"kirk-ai/internal/atomicfile"
+)
+
+const collectionSummaryFile = "summary.json"
+
+// summaryTitles is how many page titles a summary keeps
+const summaryTitles = 10
+
+// Summary describes a corpus in brief for routing questions between collections: the mean
+// direction of its embeddings and the titles of its largest pages. It is saved next to the
+// embeddings and recomputed when their file changes.
+type Summary struct {
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	Count          int       `json:"count"`
+	Centroid       []float64 `json:"centroid"`
+	Titles         []string  `json:"titles,omitempty"`
+	// SourceSize and SourceModTime identify the embeddings file the summary was made from
+	SourceSize    int64     `json:"source_size"`
+	SourceModTime time.Time `json:"source_mod_time"`
+}
+
+// Summarize computes the summary of items embedded with model. The centroid averages the
+// unit-length embeddings, so long and short chunks count the same.
+func Summarize(items []Item, model string) *Summary {
+	s := &Summary{EmbeddingModel: model}
+	pages := map[string]int{}
+	for _, it := range items {
+		if !it.Searchable() {
+			continue
+		}
+		if s.Centroid == nil {
+			s.Centroid = make([]float64, len(it.Embedding))
+		}
+		if len(it.Embedding) != len(s.Centroid) {
+			continue
+		}
+		var norm float64
+		for _, v := range it.Embedding {
+			norm += v * v
+		}
+		if norm == 0 {
+			continue
+		}
+		norm = math.Sqrt(norm)
+		for i, v := range it.Embedding {
+			s.Centroid[i] += v / norm
+		}
+		s.Count++
+		if title, ok := it.Metadata["title"].(string); ok && title != "" {
+			pages[title]++
+		}
+	}
+	for i := range s.Centroid {
+		s.Centroid[i] /= float64(s.Count)
+	}
+	for title := range pages {
+		s.Titles = append(s.Titles, title)
+	}
+	sort.Slice(s.Titles, func(i, j int) bool {
+		if pages[s.Titles[i]] != pages[s.Titles[j]] {
+			return pages[s.Titles[i]] > pages[s.Titles[j]]
+		}
+		return s.Titles[i] < s.Titles[j]
+	})
+	if len(s.Titles) > summaryTitles {
+		s.Titles = s.Titles[:summaryTitles]
+	}
+	return s
+}
+
+// Current reports whether the summary was made from the embeddings file described by fi
+func (s *Summary) Current(fi os.FileInfo) bool {
+	return s.SourceSize == fi.Size() && s.SourceModTime.Equal(fi.ModTime())
+}
+
+// SummaryPath returns the summary file kept next to an embeddings file
+func SummaryPath(embeddingsPath string) string {
+	return embeddingsPath + ".summary.json"
+}
+
+// SummaryPath returns the summary file of a collection
+func (s *Store) SummaryPath(name string) string {
+	return filepath.Join(s.collectionDir(name), collectionSummaryFile)
+}
+
+// ItemsPath returns the embeddings file of a collection
+func (s *Store) ItemsPath(name string) string {
+	return filepath.Join(s.collectionDir(name), collectionItemsFile)
+}
+
+// ReadSummary reads a summary file; a missing file returns nil without error
+func ReadSummary(path string) (*Summary, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var s Summary
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parse summary %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// WriteSummary atomically writes a summary file
+func WriteSummary(path string, s *Summary) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, b, 0o644, false)
+}
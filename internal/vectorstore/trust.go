@@ -0,0 +1,86 @@
+package vectorstore
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"kirk-ai/internal/config"
+)
+
+// SourceTrust weights retrieval scores by where a chunk came from, so authoritative pages
+// outrank syndicated copies and comment-heavy pages of similar content
+type SourceTrust struct {
+	rules []trustRule
+}
+
+type trustRule struct {
+	domain  string         // host the rule covers, with its subdomains ("" for a pattern)
+	pattern *regexp.Regexp // URL pattern without the scheme (nil for a domain)
+	weight  float64
+}
+
+// NewSourceTrust compiles source weights. A match without '/' or '*' is a domain; anything
+// else is a pattern over the URL without its scheme, where '*' matches any run of characters.
+func NewSourceTrust(weights []config.SourceWeight) (*SourceTrust, error) {
+	t := &SourceTrust{}
+	for i, w := range weights {
+		match := strings.ToLower(strings.TrimSpace(w.Match))
+		if match == "" {
+			return nil, fmt.Errorf("source weight %d has no match", i+1)
+		}
+		if w.Weight < 0 {
+			return nil, fmt.Errorf("source weight for %q is negative", w.Match)
+		}
+		if i := strings.Index(match, "://"); i >= 0 {
+			match = match[i+3:]
+		}
+		match = strings.TrimPrefix(match, "www.")
+		r := trustRule{weight: w.Weight}
+		if !strings.ContainsAny(match, "/*") {
+			r.domain = match
+		} else {
+			parts := strings.Split(match, "*")
+			for j, p := range parts {
+				parts[j] = regexp.QuoteMeta(p)
+			}
+			r.pattern = regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+		}
+		t.rules = append(t.rules, r)
+	}
+	return t, nil
+}
+
+// Weight returns the weight of the first rule matching source, or 1 when none does
+func (t *SourceTrust) Weight(source string) float64 {
+	if t == nil || source == "" {
+		return 1
+	}
+	u, err := url.Parse(strings.ToLower(source))
+	if err != nil {
+		return 1
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	bare := strings.TrimPrefix(strings.TrimPrefix(u.String(), u.Scheme+"://"), "www.")
+	for _, r := range t.rules {
+		if r.domain != "" && (host == r.domain || strings.HasSuffix(host, "."+r.domain)) {
+			return r.weight
+		}
+		if r.pattern != nil && r.pattern.MatchString(bare) {
+			return r.weight
+		}
+	}
+	return 1
+}
+
+// ApplyTrust multiplies each result's score by the weight of its source page and returns
+// up to topK results, re-ranked
+func ApplyTrust(results []SearchResult, t *SourceTrust, topK int) []SearchResult {
+	weighted := make([]SearchResult, len(results))
+	for i, r := range results {
+		r.Similarity *= t.Weight(SourceURL(r.Item))
+		weighted[i] = r
+	}
+	return rank(weighted, topK)
+}
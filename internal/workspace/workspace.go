@@ -0,0 +1,107 @@
+// Package workspace lays out where the pipeline keeps its files. Crawl output, processed
+// pages, embeddings, collections, snapshots, caches, and audit logs all live under one
+// workspace directory, tpusa_crawl unless --workspace or KIRK_AI_WORKSPACE names another:
+//
+//	crawl_config.json          crawl config: seeds, domain filters, per-host delays
+//	discovered_urls.txt        URLs found by crawl discover
+//	frontier.jsonl             URLs the crawlers discovered, with depth and referrer
+//	link_graph.jsonl           each crawled page's outgoing links
+//	requests_state.jsonl       queue of an interrupted requests crawl, for --resume
+//	robots_cache.json          robots.txt cache shared by crawler processes
+//	http_cache.json            ETag, Last-Modified, and content hash of each page crawled
+//	changes.jsonl              which pages each crawl found new, changed, or unchanged
+//	raw_html/                  page snapshots saved by colly, chromedp, api, and requests --raw
+//	requests_results.json      pages extracted by crawl requests
+//	hybrid_results.json        pages extracted by crawl hybrid
+//	colly_results.json         provenance of the pages colly saved
+//	api_endpoints.json         API endpoints found by crawl api
+//	feed_items.json            feed items fetched by crawl api
+//	processed_data/            pages cleaned by process content
+//	embeddings/                chunks prepared by process embedprep, its dedup report, and embeddings
+//	store/                     named embedding collections
+//	snapshots/                 embeddings snapshots
+//	audit/                     per-run crawl audit logs
+//
+// Settings and state that belong to the user rather than a corpus, such as config.json,
+// the chat history, and the doc cache, stay in the configuration directory (see config.Dir).
+package workspace
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// DefaultDir is the workspace used when none is given
+const DefaultDir = "tpusa_crawl"
+
+// EnvVar names the workspace when --workspace is not given
+const EnvVar = "KIRK_AI_WORKSPACE"
+
+// The files and directories of a workspace, relative to its root
+const (
+	CrawlConfig     = "crawl_config.json"
+	DiscoveredURLs  = "discovered_urls.txt"
+	Frontier        = "frontier.jsonl"
+	LinkGraph       = "link_graph.jsonl"
+	RequestsState   = "requests_state.jsonl"
+	RobotsCache     = "robots_cache.json"
+	HTTPCache       = "http_cache.json"
+	Changes         = "changes.jsonl"
+	RawHTML         = "raw_html"
+	RequestsResults = "requests_results.json"
+	HybridResults   = "hybrid_results.json"
+	CollyResults    = "colly_results.json"
+	APIEndpoints    = "api_endpoints.json"
+	FeedItems       = "feed_items.json"
+	ProcessedPages  = "processed_data/processed_pages.json"
+	EmbeddingsReady = "embeddings/tpusa_embeddings_ready.json"
+	DedupReport     = "embeddings/dedup_report.json"
+	Store           = "store"
+	Snapshots       = "snapshots"
+	Audit           = "audit"
+)
+
+var dir = DefaultDir
+
+// Dir returns the workspace directory
+func Dir() string {
+	return dir
+}
+
+// SetDir makes d the workspace directory; an empty d restores the default
+func SetDir(d string) {
+	if d == "" {
+		d = DefaultDir
+	}
+	dir = filepath.Clean(d)
+}
+
+// Path returns a path inside the workspace, such as Path(Frontier)
+func Path(elem ...string) string {
+	return filepath.Join(append([]string{dir}, elem...)...)
+}
+
+// Default returns a path inside the default workspace, for flag defaults registered before
+// the workspace is known; Rebase moves them into the workspace in use
+func Default(name string) string {
+	return DefaultDir + "/" + name
+}
+
+// Rebase moves the flags of fs that were left at a default inside the default workspace into
+// the current workspace, so "--workspace site" turns the default tpusa_crawl/frontier.jsonl
+// into site/frontier.jsonl. Flags given on the command line are left alone.
+func Rebase(fs *pflag.FlagSet) {
+	if dir == DefaultDir {
+		return
+	}
+	prefix := DefaultDir + "/"
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Changed || !strings.HasPrefix(f.DefValue, prefix) || f.Value.String() != f.DefValue {
+			return
+		}
+		// Value.Set, unlike FlagSet.Set, leaves the flag marked as not given
+		f.Value.Set(Path(strings.TrimPrefix(f.DefValue, prefix)))
+	})
+}
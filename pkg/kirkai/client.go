@@ -0,0 +1,118 @@
+// Package kirkai exposes kirk-ai's Ollama client, embeddings index, retrieval, and RAG
+// pipeline as an importable Go API, so other programs can embed the functionality
+// without exec-ing the CLI.
+package kirkai
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"kirk-ai/internal/client"
+	"kirk-ai/internal/models"
+)
+
+// DefaultBaseURL is the default Ollama server address
+const DefaultBaseURL = "http://localhost:11434"
+
+// ChatResponse is the response of a chat request
+type ChatResponse = models.ChatResponse
+
+// StreamChunk is a single chunk of a streaming chat response
+type StreamChunk = models.StreamingChatResponse
+
+// Client talks to an Ollama server
+type Client struct {
+	llm *client.Client
+}
+
+// Batch marks requests made with the returned context as bulk work, such as background
+// re-embedding: they wait while any other request on the same Client is in flight, so
+// interactive chat and retrieval are not stuck behind them
+func Batch(ctx context.Context) context.Context {
+	return client.WithPriority(ctx, client.PriorityBatch)
+}
+
+// ClientOption configures a Client
+type ClientOption func(*client.OllamaClient)
+
+// WithBaseURL sets the Ollama server URL
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *client.OllamaClient) { c.BaseURL = baseURL }
+}
+
+// WithTimeout sets the HTTP timeout for non-streaming requests
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *client.OllamaClient) { c.Client.Timeout = timeout }
+}
+
+// WithRetries retries requests that fail with a connection error, 429, or 5xx up to n times
+func WithRetries(n int) ClientOption {
+	return func(c *client.OllamaClient) { c.Retries = n }
+}
+
+// RetryPolicy sets how many times failed requests are retried, the backoff between
+// attempts, and which HTTP statuses count as transient
+type RetryPolicy = client.RetryPolicy
+
+// DefaultRetryPolicy returns the policy a new Client starts with, for adjusting
+func DefaultRetryPolicy() RetryPolicy {
+	return client.DefaultRetryPolicy()
+}
+
+// WithRetryPolicy replaces the retry policy, e.g. to retry only 503 with a longer backoff
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *client.OllamaClient) { c.RetryPolicy = p }
+}
+
+// WithHTTPClient replaces the underlying HTTP client
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *client.OllamaClient) { c.Client = httpClient }
+}
+
+// NewClient creates a client for the Ollama server at DefaultBaseURL unless overridden
+func NewClient(opts ...ClientOption) *Client {
+	oc := client.NewOllamaClient(DefaultBaseURL)
+	for _, opt := range opts {
+		opt(oc)
+	}
+	return &Client{llm: client.New(oc)}
+}
+
+// Chat sends a single prompt and returns the full response
+func (c *Client) Chat(ctx context.Context, model, prompt string) (*ChatResponse, error) {
+	return c.llm.ChatContext(ctx, model, prompt)
+}
+
+// ChatStream sends a prompt and invokes fn for every streamed chunk
+func (c *Client) ChatStream(ctx context.Context, model, prompt string, fn func(*StreamChunk) error) (*ChatResponse, error) {
+	return c.llm.ChatStreamContext(ctx, model, prompt, fn)
+}
+
+// Embed returns the embedding vector for text
+func (c *Client) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	resp, err := c.llm.EmbeddingContext(ctx, model, text)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Embedding, nil
+}
+
+// EmbedBatch returns the embedding vectors for texts, in order, from a single request
+func (c *Client) EmbedBatch(ctx context.Context, model string, texts []string) ([][]float64, error) {
+	return c.llm.EmbeddingBatchContext(ctx, model, texts)
+}
+
+// ListModels returns the names of the installed models
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	return c.llm.ListModelsContext(ctx)
+}
+
+// SelectModel picks the best installed model for a capability ("chat", "embedding", or "rag")
+func (c *Client) SelectModel(ctx context.Context, capability string) (string, error) {
+	names, err := c.ListModels(ctx)
+	if err != nil {
+		return "", err
+	}
+	return c.llm.SelectModelByCapability(names, capability), nil
+}
@@ -0,0 +1,122 @@
+package kirkai
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"kirk-ai/internal/provenance"
+	"kirk-ai/internal/vectorstore"
+)
+
+// Item is a single embedded chunk
+type Item = vectorstore.Item
+
+// Result is an item scored against a query
+type Result = vectorstore.SearchResult
+
+// Index is an in-memory set of embedded chunks that can be searched by similarity.
+// It is never modified after loading, so one Index can serve concurrent searches.
+type Index struct {
+	items          []Item
+	embeddingModel string
+	threshold      float64 // calibrated similarity threshold (0 = not calibrated)
+	version        uint64
+}
+
+// indexVersions numbers the indexes loaded by this process
+var indexVersions atomic.Uint64
+
+// NewIndex builds an index from items, skipping those without a usable embedding.
+// embeddingModel records the model the items were embedded with ("" if unknown).
+func NewIndex(items []Item, embeddingModel string) *Index {
+	usable := make([]Item, 0, len(items))
+	for _, it := range items {
+		if it.Searchable() {
+			usable = append(usable, it)
+		}
+	}
+	return &Index{items: usable, embeddingModel: embeddingModel, version: indexVersions.Add(1)}
+}
+
+// LoadIndex loads an embeddings JSON file as produced by `kirk-ai embed --out`, taking the
+// embedding model from the items' provenance
+func LoadIndex(path string) (*Index, error) {
+	items, err := vectorstore.ReadItems(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := NewIndex(items, recordedModel(items))
+	return idx, idx.loadCalibration(vectorstore.CalibrationPath(path))
+}
+
+// LoadCollection loads a named collection from a store directory
+func LoadCollection(storeDir, name string) (*Index, error) {
+	store := vectorstore.NewStore(storeDir)
+	items, info, err := store.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	idx := NewIndex(items, info.EmbeddingModel)
+	return idx, idx.loadCalibration(store.CalibrationPath(name))
+}
+
+// recordedModel returns the embedding model named in the items' provenance, or "" when
+// none is recorded or the items disagree
+func recordedModel(items []Item) string {
+	model := ""
+	for _, it := range items {
+		switch m := provenance.Get(it.Metadata).EmbeddingModel; {
+		case m == "":
+		case model == "":
+			model = m
+		case m != model:
+			return ""
+		}
+	}
+	return model
+}
+
+// loadCalibration picks up the threshold recorded by `kirk-ai embeddings calibrate`, if any
+func (idx *Index) loadCalibration(path string) error {
+	cal, err := vectorstore.ReadCalibration(path)
+	if err != nil || cal == nil {
+		return err
+	}
+	if idx.embeddingModel == "" || cal.EmbeddingModel == idx.embeddingModel {
+		idx.threshold = cal.RAG
+	}
+	return nil
+}
+
+// Len returns the number of searchable items
+func (idx *Index) Len() int {
+	return len(idx.items)
+}
+
+// EmbeddingModel returns the model the index was built with, if recorded
+func (idx *Index) EmbeddingModel() string {
+	return idx.embeddingModel
+}
+
+// Threshold returns the calibrated similarity threshold for retrieval, or 0 when the index
+// has not been calibrated
+func (idx *Index) Threshold() float64 {
+	return idx.threshold
+}
+
+// Version identifies this index among those loaded by the process. An Index never changes,
+// so reloading changed embeddings always yields a new version.
+func (idx *Index) Version() string {
+	return strconv.FormatUint(idx.version, 10)
+}
+
+// Search returns up to topK results at or above threshold, most similar first
+func (idx *Index) Search(queryEmbedding []float64, topK int, threshold float64) []Result {
+	return vectorstore.Search(queryEmbedding, idx.items, topK, threshold)
+}
+
+// DeepLink returns a link to an item's location on its source page, highlighting the chunk
+// with a text fragment when its position was recorded during embedprep
+func DeepLink(it Item) string {
+	return vectorstore.DeepLink(it)
+}
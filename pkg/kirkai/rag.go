@@ -0,0 +1,147 @@
+package kirkai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"kirk-ai/internal/rag"
+)
+
+// Answer is a generated answer together with the chunks used as context
+type Answer struct {
+	Text    string
+	Sources []Result
+	Model   string
+}
+
+// RAGOption configures a RAGEngine
+type RAGOption func(*RAGEngine)
+
+// WithChatModel sets the model used to generate answers
+func WithChatModel(model string) RAGOption {
+	return func(e *RAGEngine) { e.ChatModel = model }
+}
+
+// WithMaxContextLength sets the character budget for retrieved context
+func WithMaxContextLength(n int) RAGOption {
+	return func(e *RAGEngine) { e.MaxContextLength = n }
+}
+
+// WithMaxConcurrent bounds how many answers are generated at once; retrieval is not limited.
+// Requests beyond the limit wait for a free slot or for their context to end.
+func WithMaxConcurrent(n int) RAGOption {
+	return func(e *RAGEngine) { e.MaxConcurrent = n }
+}
+
+// RAGEngine answers questions using retrieved context. It is safe for concurrent use: the
+// index is shared read-only across requests, the auto-selected chat model is resolved once,
+// and generation runs in a pool of at most MaxConcurrent slots.
+type RAGEngine struct {
+	Retriever        *Retriever
+	ChatModel        string
+	MaxContextLength int
+	MaxConcurrent    int // 0 = unbounded
+
+	slots     chan struct{}
+	modelMu   sync.Mutex
+	autoModel string
+}
+
+// NewRAGEngine creates a RAG engine on top of a retriever
+func NewRAGEngine(r *Retriever, opts ...RAGOption) *RAGEngine {
+	e := &RAGEngine{
+		Retriever:        r,
+		MaxContextLength: rag.DefaultMaxContextLength,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.MaxConcurrent > 0 {
+		e.slots = make(chan struct{}, e.MaxConcurrent)
+	}
+	return e
+}
+
+// chatModel returns ChatModel or the auto-selected model, selecting it on first use
+func (e *RAGEngine) chatModel(ctx context.Context) (string, error) {
+	if e.ChatModel != "" {
+		return e.ChatModel, nil
+	}
+	e.modelMu.Lock()
+	defer e.modelMu.Unlock()
+	if e.autoModel != "" {
+		return e.autoModel, nil
+	}
+	selected, err := e.Retriever.Client.SelectModel(ctx, "rag")
+	if err != nil {
+		return "", err
+	}
+	if selected == "" {
+		return "", fmt.Errorf("no suitable chat model found")
+	}
+	e.autoModel = selected
+	return selected, nil
+}
+
+// acquire waits for a generation slot; the returned func releases it
+func (e *RAGEngine) acquire(ctx context.Context) (func(), error) {
+	if e.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case e.slots <- struct{}{}:
+		return func() { <-e.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Ask retrieves context for question and generates an answer from it
+func (e *RAGEngine) Ask(ctx context.Context, question string) (*Answer, error) {
+	results, err := e.Retriever.Retrieve(ctx, question)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no relevant context found for question")
+	}
+
+	contextText, used := rag.BuildContext(results, e.MaxContextLength)
+	if len(used) == 0 {
+		return nil, fmt.Errorf("found similar embeddings but no content available for context")
+	}
+
+	model, err := e.chatModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := e.Retriever.Client.Chat(ctx, model, rag.BuildPrompt(question, contextText))
+	if err != nil {
+		return nil, err
+	}
+	return &Answer{Text: resp.Message.Content, Sources: used, Model: model}, nil
+}
+
+// AskAll answers questions concurrently, sharing the index and the generation pool.
+// answers[i] and errs[i] hold the outcome of questions[i].
+func (e *RAGEngine) AskAll(ctx context.Context, questions []string) (answers []*Answer, errs []error) {
+	answers = make([]*Answer, len(questions))
+	errs = make([]error, len(questions))
+	var wg sync.WaitGroup
+	for i, q := range questions {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			answers[i], errs[i] = e.Ask(ctx, q)
+		}(i, q)
+	}
+	wg.Wait()
+	return answers, errs
+}
@@ -0,0 +1,111 @@
+package kirkai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"kirk-ai/internal/vectorstore"
+)
+
+// DefaultCacheSize is how many searches a Retriever remembers unless WithCache says otherwise
+const DefaultCacheSize = 256
+
+// RetrieverOption configures a Retriever
+type RetrieverOption func(*Retriever)
+
+// WithTopK sets the maximum number of results returned
+func WithTopK(topK int) RetrieverOption {
+	return func(r *Retriever) { r.TopK = topK }
+}
+
+// WithThreshold sets the minimum similarity of returned results
+func WithThreshold(threshold float64) RetrieverOption {
+	return func(r *Retriever) { r.Threshold = threshold }
+}
+
+// WithEmbeddingModel sets the model used to embed queries
+func WithEmbeddingModel(model string) RetrieverOption {
+	return func(r *Retriever) { r.EmbeddingModel = model }
+}
+
+// WithCache sets how many searches the retriever remembers (0 disables the cache)
+func WithCache(size int) RetrieverOption {
+	return func(r *Retriever) { r.cache = vectorstore.NewResultCache(size) }
+}
+
+// Retriever embeds queries and searches an Index. It is safe for concurrent use. Results
+// are cached per query embedding, TopK, Threshold, and index version, so a repeated
+// question skips the search, and replacing Index with a reloaded one invalidates the cache.
+type Retriever struct {
+	Client         *Client
+	Index          *Index
+	EmbeddingModel string
+	TopK           int
+	Threshold      float64
+
+	modelMu   sync.Mutex
+	autoModel string
+	cache     *vectorstore.ResultCache
+}
+
+// NewRetriever creates a retriever; the query embedding model defaults to the index's model
+// and the threshold to the index's calibrated threshold, or 0.3
+func NewRetriever(c *Client, idx *Index, opts ...RetrieverOption) *Retriever {
+	r := &Retriever{
+		Client:         c,
+		Index:          idx,
+		EmbeddingModel: idx.EmbeddingModel(),
+		TopK:           5,
+		Threshold:      0.3,
+		cache:          vectorstore.NewResultCache(DefaultCacheSize),
+	}
+	if t := idx.Threshold(); t > 0 {
+		r.Threshold = t
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Retrieve returns the chunks most similar to query
+func (r *Retriever) Retrieve(ctx context.Context, query string) ([]Result, error) {
+	model, err := r.embeddingModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryEmbedding, err := r.Client.Embed(ctx, model, query)
+	if err != nil {
+		return nil, err
+	}
+	key := vectorstore.CacheKey(queryEmbedding, r.TopK, r.Threshold, r.Index.Version())
+	if results, ok := r.cache.Get(key); ok {
+		return results, nil
+	}
+	results := r.Index.Search(queryEmbedding, r.TopK, r.Threshold)
+	r.cache.Put(key, results)
+	return results, nil
+}
+
+// embeddingModel returns EmbeddingModel or the auto-selected model, selecting it on first use
+func (r *Retriever) embeddingModel(ctx context.Context) (string, error) {
+	if r.EmbeddingModel != "" {
+		return r.EmbeddingModel, nil
+	}
+	r.modelMu.Lock()
+	defer r.modelMu.Unlock()
+	if r.autoModel != "" {
+		return r.autoModel, nil
+	}
+	selected, err := r.Client.SelectModel(ctx, "embedding")
+	if err != nil {
+		return "", err
+	}
+	if selected == "" {
+		return "", fmt.Errorf("no suitable embedding model found")
+	}
+	r.autoModel = selected
+	return selected, nil
+}
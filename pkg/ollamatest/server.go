@@ -0,0 +1,543 @@
+// Package ollamatest provides an httptest-based fake Ollama server with scriptable
+// responses, latencies, and failures for deterministic integration tests of code
+// that talks to Ollama (the kirk-ai CLI, pkg/kirkai, or third-party users of the client).
+package ollamatest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kirk-ai/internal/models"
+)
+
+// DefaultDimension is the size of the deterministic embeddings returned by default
+const DefaultDimension = 8
+
+// Request records a single request received by the fake server
+type Request struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// failure is a scripted error response for a path
+type failure struct {
+	status int // 0 drops the connection without a response
+	body   string
+}
+
+// Server is a fake Ollama server. Create it with NewServer and point clients at Server.URL.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	models    []string
+	running   map[string]time.Time // loaded models and when they unload
+	version   string
+	latency   time.Duration
+	dimension int
+	chatFn    func(req models.ChatRequest) string
+	embedFn   func(model, text string) []float64
+	showFn    func(model string) *models.ShowResponse
+	pullFn    func(model string) []models.PullProgress
+	failures  map[string][]failure
+	requests  []Request
+}
+
+// DefaultVersion is the Ollama version reported by /api/version by default
+const DefaultVersion = "0.6.2"
+
+// defaultKeepAlive is how long a model stays loaded after a request that sets no keep_alive
+const defaultKeepAlive = 5 * time.Minute
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithModels sets the models reported by /api/tags
+func WithModels(names ...string) Option {
+	return func(s *Server) { s.models = append([]string(nil), names...) }
+}
+
+// WithRunning sets the models reported by /api/ps as loaded. Chat and embedding requests
+// load their model too, and a keep_alive of 0 unloads it.
+func WithRunning(names ...string) Option {
+	return func(s *Server) {
+		for _, name := range names {
+			s.running[name] = time.Now().Add(defaultKeepAlive)
+		}
+	}
+}
+
+// WithVersion sets the version reported by /api/version
+func WithVersion(v string) Option {
+	return func(s *Server) { s.version = v }
+}
+
+// WithShow scripts the /api/show description of an installed model; a nil description
+// answers 404 as for a missing model
+func WithShow(fn func(model string) *models.ShowResponse) Option {
+	return func(s *Server) { s.showFn = fn }
+}
+
+// WithPull scripts the progress lines /api/pull streams for a model. The model is installed
+// once a line reports "success"; a line with an Error fails the pull.
+func WithPull(fn func(model string) []models.PullProgress) Option {
+	return func(s *Server) { s.pullFn = fn }
+}
+
+// WithLatency delays every response by d
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) { s.latency = d }
+}
+
+// WithDimension sets the dimension of the default deterministic embeddings
+func WithDimension(n int) Option {
+	return func(s *Server) { s.dimension = n }
+}
+
+// WithChatResponse scripts the assistant reply for /api/chat
+func WithChatResponse(fn func(req models.ChatRequest) string) Option {
+	return func(s *Server) { s.chatFn = fn }
+}
+
+// WithEmbedding scripts the vectors returned by /api/embeddings and /api/embed
+func WithEmbedding(fn func(model, text string) []float64) Option {
+	return func(s *Server) { s.embedFn = fn }
+}
+
+// NewServer starts a fake Ollama server. Callers must Close it when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		models:    []string{"gemma3:4b", "embeddinggemma:latest"},
+		running:   make(map[string]time.Time),
+		version:   DefaultVersion,
+		dimension: DefaultDimension,
+		failures:  make(map[string][]failure),
+	}
+	s.chatFn = func(req models.ChatRequest) string {
+		if len(req.Messages) == 0 {
+			return ""
+		}
+		return "echo: " + req.Messages[len(req.Messages)-1].Content
+	}
+	s.embedFn = func(model, text string) []float64 {
+		return DeterministicEmbedding(text, s.dimension)
+	}
+	s.showFn = func(model string) *models.ShowResponse {
+		return DefaultShow(model, s.dimension)
+	}
+	s.pullFn = DefaultPull
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleRoot)
+	mux.HandleFunc("/api/tags", s.handleTags)
+	mux.HandleFunc("/api/chat", s.handleChat)
+	mux.HandleFunc("/api/embeddings", s.handleEmbeddings)
+	mux.HandleFunc("/api/embed", s.handleEmbed)
+	mux.HandleFunc("/api/show", s.handleShow)
+	mux.HandleFunc("/api/ps", s.handlePs)
+	mux.HandleFunc("/api/version", s.handleVersion)
+	mux.HandleFunc("/api/pull", s.handlePull)
+	mux.HandleFunc("/api/delete", s.handleDelete)
+	s.Server = httptest.NewServer(s.wrap(mux))
+	return s
+}
+
+// DeterministicEmbedding derives a stable unit-free vector from text, so equal texts embed identically
+func DeterministicEmbedding(text string, dim int) []float64 {
+	vec := make([]float64, dim)
+	sum := sha256.Sum256([]byte(text))
+	for i := range vec {
+		vec[i] = (float64(sum[i%len(sum)]) - 128) / 128
+	}
+	return vec
+}
+
+// DefaultShow describes model as an embedding model of dimension dim when its name contains
+// "embed", and as a chat model otherwise
+func DefaultShow(model string, dim int) *models.ShowResponse {
+	if strings.Contains(model, "embed") {
+		return &models.ShowResponse{
+			ModelInfo: map[string]interface{}{
+				"bert.context_length":   2048.0,
+				"bert.embedding_length": float64(dim),
+				"bert.pooling_type":     1.0,
+			},
+			Details:      models.ModelDetails{Family: "bert", ParameterSize: "300M", QuantizationLevel: "F16"},
+			Capabilities: []string{"embedding"},
+		}
+	}
+	return &models.ShowResponse{
+		Parameters: "num_ctx 8192",
+		ModelInfo: map[string]interface{}{
+			"llama.context_length":   131072.0,
+			"llama.embedding_length": 3072.0,
+		},
+		Details:      models.ModelDetails{Family: "llama", ParameterSize: "4B", QuantizationLevel: "Q4_K_M"},
+		Template:     "{{ .Prompt }}",
+		Capabilities: []string{"completion"},
+	}
+}
+
+// DefaultPull reports the steps of pulling model: the manifest, one layer downloaded in two
+// parts, and success
+func DefaultPull(model string) []models.PullProgress {
+	digest := "sha256:" + digestOf(model)
+	return []models.PullProgress{
+		{Status: "pulling manifest"},
+		{Status: "pulling " + digest[7:19], Digest: digest, Total: 4 << 20, Completed: 2 << 20},
+		{Status: "pulling " + digest[7:19], Digest: digest, Total: 4 << 20, Completed: 4 << 20},
+		{Status: "verifying sha256 digest"},
+		{Status: "success"},
+	}
+}
+
+// digestOf derives a stable hex digest for a model name
+func digestOf(name string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+}
+
+// Models returns the installed models, including those pulled and without those deleted
+func (s *Server) Models() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.models...)
+}
+
+// Running returns the loaded models in name order
+func (s *Server) Running() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runningLocked()
+}
+
+func (s *Server) runningLocked() []string {
+	names := make([]string, 0, len(s.running))
+	for name := range s.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// installedLocked returns the installed model called name, with or without its ":latest"
+// tag as Ollama accepts, and its position in the list
+func (s *Server) installedLocked(name string) (string, int) {
+	for i, m := range s.models {
+		if m == name || m == name+":latest" {
+			return m, i
+		}
+	}
+	return "", -1
+}
+
+// touch loads model for keepAlive as a request to it does: a duration or a number of
+// seconds, where 0 unloads the model and a negative value keeps it until the server stops
+func (s *Server) touch(model, keepAlive string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := defaultKeepAlive
+	if n, err := strconv.Atoi(keepAlive); err == nil {
+		d = time.Duration(n) * time.Second
+	} else if parsed, err := time.ParseDuration(keepAlive); err == nil {
+		d = parsed
+	}
+	switch {
+	case d == 0:
+		delete(s.running, model)
+	case d < 0:
+		s.running[model] = time.Now().AddDate(100, 0, 0)
+	default:
+		s.running[model] = time.Now().Add(d)
+	}
+}
+
+// FailNext makes the next n requests to path fail with status and body.
+// A status of 0 closes the connection without responding, simulating a connection reset.
+func (s *Server) FailNext(path string, n int, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures[path] = append(s.failures[path], failure{status: status, body: body})
+	}
+}
+
+// SetLatency changes the per-response delay
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns a copy of every request received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Request, len(s.requests))
+	copy(out, s.requests)
+	return out
+}
+
+// wrap records requests, applies latency, and serves scripted failures before delegating
+func (s *Server) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = readAll(r)
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Body: body})
+		latency := s.latency
+		var fail *failure
+		if queue := s.failures[r.URL.Path]; len(queue) > 0 {
+			fail = &queue[0]
+			s.failures[r.URL.Path] = queue[1:]
+		}
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if fail != nil {
+			if fail.status == 0 {
+				if hj, ok := w.(http.Hijacker); ok {
+					if conn, _, err := hj.Hijack(); err == nil {
+						conn.Close()
+						return
+					}
+				}
+				fail.status = http.StatusInternalServerError
+			}
+			http.Error(w, fail.body, fail.status)
+			return
+		}
+
+		r.Body = newBodyReader(body)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	io.WriteString(w, "Ollama is running")
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := models.ModelsResponse{}
+	for _, name := range s.models {
+		resp.Models = append(resp.Models, models.Model{Name: name, Size: 4 << 20, Digest: digestOf(name)})
+	}
+	s.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req models.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.touch(req.Model, req.KeepAlive)
+	reply := s.chatFn(req)
+
+	if !req.Stream {
+		writeJSON(w, models.ChatResponse{
+			Model:     req.Model,
+			CreatedAt: time.Now(),
+			Message:   models.Message{Role: "assistant", Content: reply},
+			Done:      true,
+			EvalCount: len(strings.Fields(reply)),
+		})
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	words := strings.SplitAfter(reply, " ")
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		_ = enc.Encode(models.StreamingChatResponse{
+			Model:     req.Model,
+			CreatedAt: time.Now(),
+			Message:   models.Message{Role: "assistant", Content: word},
+		})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	_ = enc.Encode(models.StreamingChatResponse{
+		Model:     req.Model,
+		CreatedAt: time.Now(),
+		Message:   models.Message{Role: "assistant"},
+		Done:      true,
+		EvalCount: len(words),
+	})
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req models.EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.touch(req.Model, req.KeepAlive)
+	writeJSON(w, models.EmbeddingResponse{Embedding: s.embedFn(req.Model, req.Prompt)})
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchEmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.touch(req.Model, req.KeepAlive)
+	resp := models.BatchEmbeddingResponse{}
+	for _, text := range req.Input {
+		resp.Embeddings = append(resp.Embeddings, s.embedFn(req.Model, text))
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	var req models.ShowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	name, _ := s.installedLocked(req.Model)
+	s.mu.Unlock()
+	var info *models.ShowResponse
+	if name != "" {
+		info = s.showFn(name)
+	}
+	if info == nil {
+		notFound(w, req.Model)
+		return
+	}
+	writeJSON(w, info)
+}
+
+func (s *Server) handlePs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := models.RunningModelsResponse{Models: []models.RunningModel{}}
+	for _, name := range s.runningLocked() {
+		resp.Models = append(resp.Models, models.RunningModel{Name: name, Size: 4 << 20, SizeVRAM: 4 << 20, ExpiresAt: s.running[name]})
+	}
+	s.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := models.VersionResponse{Version: s.version}
+	s.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	var req models.PullRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lines := s.pullFn(req.Model)
+	if !req.Stream && len(lines) > 0 {
+		lines = lines[len(lines)-1:]
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for _, line := range lines {
+		if line.Status == "success" {
+			s.mu.Lock()
+			if name, _ := s.installedLocked(req.Model); name == "" {
+				s.models = append(s.models, tagged(req.Model))
+			}
+			s.mu.Unlock()
+		}
+		_ = enc.Encode(line)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if line.Error != "" {
+			return
+		}
+	}
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req models.DeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	name, i := s.installedLocked(req.Model)
+	if name != "" {
+		s.models = append(s.models[:i:i], s.models[i+1:]...)
+		delete(s.running, name)
+	}
+	s.mu.Unlock()
+	if name == "" {
+		notFound(w, req.Model)
+	}
+}
+
+// tagged adds the ":latest" tag Ollama gives a model name without one
+func tagged(name string) string {
+	if strings.Contains(name, ":") {
+		return name
+	}
+	return name + ":latest"
+}
+
+// notFound answers as Ollama does for a model that is not installed
+func notFound(w http.ResponseWriter, model string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("model '%s' not found", model)})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func readAll(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+	return io.ReadAll(r.Body)
+}
+
+func newBodyReader(b []byte) io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(b))
+}
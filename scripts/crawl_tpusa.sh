@@ -11,7 +11,7 @@ SKIP_CHROMEDP="${SKIP_CHROMEDP:-0}"   # set to 1 to skip chromedp step
 SKIP_COLLY="${SKIP_COLLY:-0}"         # set to 1 to skip colly crawler
 SKIP_REQUESTS="${SKIP_REQUESTS:-0}"   # set to 1 to skip requests crawler
 SKIP_API="${SKIP_API:-0}"             # set to 1 to skip API collector
-BUILD_DIR="./build/tools"
+BUILD_DIR="./build"
 
 # Crawler concurrency knobs
 CRAWLER_PROCS="${CRAWLER_PROCS:-6}"         # total number of crawler processes to use when splitting
@@ -32,13 +32,11 @@ else
   go mod download >/dev/null || true
 fi
 
-# Build tools once to avoid repeated compilation cost of 'go run'
-echo "Building crawler and processor tools (one-time)..."
-go build -o "$BUILD_DIR/crawler" ./tools/crawler
-go build -o "$BUILD_DIR/processor" ./tools/processor
+# Build the CLI once to avoid repeated compilation cost of 'go run'
+echo "Building kirk-ai (one-time)..."
+go build -o "$BUILD_DIR/kirk-ai" .
 
-CRAWLER_BIN="$BUILD_DIR/crawler"
-PROCESSOR_BIN="$BUILD_DIR/processor"
+KIRK_BIN="$BUILD_DIR/kirk-ai"
 
 # Kill children on exit
 pids=()
@@ -77,9 +75,8 @@ cleanup() {
 }
 trap cleanup EXIT
 
-echo "Discovering URLs via sitemap..."
-# Use sed instead of grep -P for portability (macOS grep doesn't support -P by default)
-curl -s https://tpusa.com/sitemap.xml | sed -n 's:.*<loc>\(.*\)</loc>.*:\1:p' > tpusa_crawl/discovered_urls.txt || true
+echo "Discovering URLs via sitemaps and feeds..."
+"$KIRK_BIN" crawl discover --feeds https://tpusa.com/feed/ 2>&1 | tee tpusa_crawl/logs/discover.log || true
 
 # Fallback if sitemap fetch failed or returned no entries
 if [ ! -s tpusa_crawl/discovered_urls.txt ]; then
@@ -104,7 +101,7 @@ EXCLUDE_PATH_REGEX="${EXCLUDE_PATH_REGEX:-/search|/tag/|/author/|/calendar|/feed
 HEAD_CONC="${HEAD_CONC:-10}"
 
 echo "Filtering discovered URLs (host whitelist, host blacklist, ext blacklist, path excludes, dedupe)..."
-cat tpusa_crawl/discovered_urls.txt \
+cut -f1 tpusa_crawl/discovered_urls.txt \
   | sed -E 's/^[[:space:]]+//;s/[[:space:]]+$//' \
   | sed -E 's/([?&])(utm_[^&]+|gclid|fbclid)=[^&]*(&|$)/\1/g' \
   | sed -E 's/[?&]$//' \
@@ -192,26 +189,26 @@ launch_workers() {
     [ -s "$part" ] || continue
     log="tpusa_crawl/logs/${mode}.$(basename "$part").log"
     echo "Launching $mode worker for $(wc -l < "$part") URLs -> $log"
-    "$CRAWLER_BIN" "$mode" -urls "$part" $extra_args 2>&1 | tee "$log" &
+    "$KIRK_BIN" crawl "$mode" --urls "$part" $extra_args 2>&1 | tee "$log" &
     pids+=($!)
   done
 }
 
 # Launch colly workers (skip if requested)
 if [ "${SKIP_COLLY:-0}" != "1" ]; then
-  launch_workers colly "$COLLY_PROCS" "-parallel ${COLLY_PARALLEL}"
+  launch_workers colly "$COLLY_PROCS" "--parallel ${COLLY_PARALLEL}"
 else
   echo "Skipping colly workers (SKIP_COLLY=1)"
 fi
 # Launch requests workers (skip if requested)
 if [ "${SKIP_REQUESTS:-0}" != "1" ]; then
-  launch_workers requests "$REQUESTS_PROCS" "-workers ${REQUESTS_WORKERS}"
+  launch_workers requests "$REQUESTS_PROCS" "--workers ${REQUESTS_WORKERS}"
 else
   echo "Skipping requests workers (SKIP_REQUESTS=1)"
 fi
 # Run API collector once (lightweight) — skip if requested
 if [ "${SKIP_API:-0}" != "1" ]; then
-  "$CRAWLER_BIN" api 2>&1 | tee tpusa_crawl/logs/api_collector.log &
+  "$KIRK_BIN" crawl api 2>&1 | tee tpusa_crawl/logs/api_collector.log &
   pids+=($!)
 else
   echo "Skipping api collector (SKIP_API=1)"
@@ -291,7 +288,7 @@ if [ "${SKIP_CHROMEDP}" != "1" ] && [ -s tpusa_crawl/discovered_urls.txt ]; then
       for part in ${split_prefix}*; do
         [ -s "$part" ] || continue
         log="tpusa_crawl/logs/chromedp.$(basename "$part").log"
-        "$CRAWLER_BIN" chromedp -urls "$part" 2>&1 | tee "$log" &
+        "$KIRK_BIN" crawl chromedp --urls "$part" 2>&1 | tee "$log" &
         pids+=($!)
       done
 
@@ -317,10 +314,10 @@ fi
 
 # Process raw HTML -> processed JSON
 echo "Processing raw HTML into cleaned JSON..."
-"$PROCESSOR_BIN" content 2>&1 | tee tpusa_crawl/logs/processor.log
+"$KIRK_BIN" process content 2>&1 | tee tpusa_crawl/logs/processor.log
 
 # Prepare embeddings-ready JSON
 echo "Preparing embeddings data (chunking + dedupe + metadata)..."
-"$PROCESSOR_BIN" embedprep 2>&1 | tee tpusa_crawl/logs/prepare_embeddings.log
+"$KIRK_BIN" process embedprep 2>&1 | tee tpusa_crawl/logs/prepare_embeddings.log
 
 echo "Pipeline complete. Outputs are under tpusa_crawl/"
\ No newline at end of file